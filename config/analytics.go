@@ -0,0 +1,195 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Retention defaults in days (overridable via WIDGET_SESSION_TTL_DAYS / USAGE_LOG_TTL_DAYS, 0 disables)
+const (
+	defaultWidgetSessionTTLDays = 90
+	defaultUsageLogTTLDays      = 180
+
+	// minRetentionDays keeps raw data around long enough for the daily
+	// analytics rollup to aggregate a finished day before TTL removes it.
+	minRetentionDays = 2
+)
+
+// retentionSeconds - Resolve a TTL env setting to seconds (0 means disabled)
+func retentionSeconds(key string, defaultDays int) int32 {
+	days := getEnvInt(key, defaultDays)
+	if days <= 0 {
+		return 0
+	}
+	if days < minRetentionDays {
+		log.Printf("⚠️ %s=%d is below the %d-day minimum, using %d", key, days, minRetentionDays, minRetentionDays)
+		days = minRetentionDays
+	}
+	return int32(days * 24 * 60 * 60)
+}
+
+// setupRetentionIndexes - Create TTL indexes on widget_sessions and openai_usage_logs
+func setupRetentionIndexes(ctx context.Context) error {
+	if ttl := retentionSeconds("WIDGET_SESSION_TTL_DAYS", defaultWidgetSessionTTLDays); ttl > 0 {
+		_, err := DB.Collection("widget_sessions").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "last_activity", Value: 1}},
+			Options: options.Index().SetBackground(true).SetExpireAfterSeconds(ttl),
+		})
+		if err != nil {
+			return fmt.Errorf("widget_sessions TTL index: %v", err)
+		}
+		log.Printf("🗑️ widget_sessions expire after %d days of inactivity", ttl/86400)
+	}
+
+	if ttl := retentionSeconds("USAGE_LOG_TTL_DAYS", defaultUsageLogTTLDays); ttl > 0 {
+		_, err := DB.Collection("openai_usage_logs").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "timestamp", Value: 1}},
+			Options: options.Index().SetBackground(true).SetExpireAfterSeconds(ttl),
+		})
+		if err != nil {
+			return fmt.Errorf("openai_usage_logs TTL index: %v", err)
+		}
+		log.Printf("🗑️ openai_usage_logs expire after %d days", ttl/86400)
+	}
+
+	return nil
+}
+
+// AggregateWidgetAnalytics - Roll up yesterday's and today's widget sessions into widget_analytics.
+// Runs from the daily maintenance job; upserts per (project, day) so re-running is idempotent.
+func AggregateWidgetAnalytics() error {
+	if DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		if err := aggregateWidgetAnalyticsForDay(ctx, day); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// aggregateWidgetAnalyticsForDay - Aggregate a single day of widget sessions per project
+func aggregateWidgetAnalyticsForDay(ctx context.Context, day time.Time) error {
+	dayEnd := day.AddDate(0, 0, 1)
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"started_at": bson.M{"$gte": day, "$lt": dayEnd}}},
+		{
+			"$group": bson.M{
+				"_id":             "$project_id",
+				"total_sessions":  bson.M{"$sum": 1},
+				"unique_sessions": bson.M{"$addToSet": "$session_id"},
+				"visitors":        bson.M{"$addToSet": "$visitor_id"},
+				"total_messages":  bson.M{"$sum": "$message_count"},
+				"total_tokens":    bson.M{"$sum": "$tokens_used"},
+				"bounced": bson.M{"$sum": bson.M{
+					"$cond": []interface{}{bson.M{"$lte": []interface{}{"$message_count", 1}}, 1, 0},
+				}},
+				"avg_duration_ms": bson.M{"$avg": bson.M{
+					"$subtract": []interface{}{"$last_activity", "$started_at"},
+				}},
+			},
+		},
+	}
+
+	cursor, err := GetWidgetSessionsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate widget sessions: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ProjectID      string        `bson:"_id"`
+		TotalSessions  int           `bson:"total_sessions"`
+		UniqueSessions []interface{} `bson:"unique_sessions"`
+		Visitors       []string      `bson:"visitors"`
+		TotalMessages  int           `bson:"total_messages"`
+		TotalTokens    int64         `bson:"total_tokens"`
+		Bounced        int           `bson:"bounced"`
+		AvgDurationMS  float64       `bson:"avg_duration_ms"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return fmt.Errorf("failed to decode widget session rollup: %v", err)
+	}
+
+	analyticsCol := GetWidgetAnalyticsCollection()
+	for _, row := range rows {
+		averageMessages, bounceRate := float64(0), float64(0)
+		if row.TotalSessions > 0 {
+			averageMessages = float64(row.TotalMessages) / float64(row.TotalSessions)
+			bounceRate = float64(row.Bounced) / float64(row.TotalSessions) * 100
+		}
+
+		visitors := make([]string, 0, len(row.Visitors))
+		for _, v := range row.Visitors {
+			if v != "" {
+				visitors = append(visitors, v)
+			}
+		}
+		returnUsers := countReturningVisitors(ctx, row.ProjectID, visitors, day)
+
+		update := bson.M{
+			"$set": bson.M{
+				"total_sessions":           row.TotalSessions,
+				"unique_sessions":          len(row.UniqueSessions),
+				"unique_visitors":          len(visitors),
+				"return_users":             returnUsers,
+				"total_messages":           row.TotalMessages,
+				"average_messages":         averageMessages,
+				"total_tokens":             row.TotalTokens,
+				"bounce_rate":              bounceRate,
+				"average_session_duration": row.AvgDurationMS / 60000, // minutes
+				"updated_at":               time.Now(),
+			},
+			"$setOnInsert": bson.M{
+				"created_at": time.Now(),
+			},
+		}
+
+		_, err := analyticsCol.UpdateOne(ctx,
+			bson.M{"project_id": row.ProjectID, "date": day},
+			update,
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("❌ Failed to store widget analytics for %s: %v", row.ProjectID, err)
+		}
+	}
+
+	log.Printf("📊 Widget analytics rolled up for %s (%d projects)", day.Format("2006-01-02"), len(rows))
+	return nil
+}
+
+// countReturningVisitors - How many of the day's visitors had a session with the project before that day
+func countReturningVisitors(ctx context.Context, projectID string, visitors []string, day time.Time) int {
+	if len(visitors) == 0 {
+		return 0
+	}
+
+	seen, err := GetWidgetSessionsCollection().Distinct(ctx, "visitor_id", bson.M{
+		"project_id": projectID,
+		"visitor_id": bson.M{"$in": visitors},
+		"started_at": bson.M{"$lt": day},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to count returning visitors for %s: %v", projectID, err)
+		return 0
+	}
+
+	return len(seen)
+}
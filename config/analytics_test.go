@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRetentionSeconds(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want int32
+	}{
+		{"", defaultWidgetSessionTTLDays * 86400},
+		{"30", 30 * 86400},
+		{"1", minRetentionDays * 86400},
+		{"0", 0},
+		{"-5", 0},
+		{"forever", defaultWidgetSessionTTLDays * 86400},
+	}
+	for _, tt := range tests {
+		t.Setenv("WIDGET_SESSION_TTL_DAYS", tt.raw)
+		if got := retentionSeconds("WIDGET_SESSION_TTL_DAYS", defaultWidgetSessionTTLDays); got != tt.want {
+			t.Errorf("retentionSeconds with %q = %d, want %d", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestSetupRetentionIndexes(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("WIDGET_SESSION_TTL_DAYS", "30")
+	t.Setenv("USAGE_LOG_TTL_DAYS", "0")
+
+	if err := setupRetentionIndexes(ctx); err != nil {
+		t.Fatalf("setupRetentionIndexes: %v", err)
+	}
+
+	ttls := func(collection string) map[string]string {
+		t.Helper()
+		cursor, err := DB.Collection(collection).Indexes().List(ctx)
+		if err != nil {
+			t.Fatalf("list %s indexes: %v", collection, err)
+		}
+		var indexes []bson.M
+		if err := cursor.All(ctx, &indexes); err != nil {
+			t.Fatalf("decode %s indexes: %v", collection, err)
+		}
+		found := map[string]string{}
+		for _, index := range indexes {
+			if ttl, ok := index["expireAfterSeconds"]; ok {
+				found[fmt.Sprint(index["name"])] = fmt.Sprint(ttl)
+			}
+		}
+		return found
+	}
+
+	if got := ttls("widget_sessions"); got["last_activity_1"] != fmt.Sprint(30*86400) || len(got) != 1 {
+		t.Errorf("widget_sessions TTL indexes = %v, want last_activity_1 expiring after 30 days", got)
+	}
+	if got := ttls("openai_usage_logs"); len(got) != 0 {
+		t.Errorf("openai_usage_logs TTL indexes = %v, want none when disabled", got)
+	}
+}
+
+func TestAggregateWidgetAnalytics(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := today.AddDate(0, 0, -1)
+	session := func(projectID, sessionID, visitorID string, started time.Time, minutes, messages, tokens int) bson.M {
+		return bson.M{
+			"project_id": projectID, "session_id": sessionID, "visitor_id": visitorID,
+			"started_at": started, "last_activity": started.Add(time.Duration(minutes) * time.Minute),
+			"message_count": messages, "tokens_used": tokens,
+		}
+	}
+	sessions := []interface{}{
+		// v1 came back after an earlier visit, which is outside the rollup days
+		session("proj_a", "earlier", "v1", day.AddDate(0, 0, -3), 5, 3, 30),
+		session("proj_a", "s1", "v1", day.Add(time.Hour), 10, 4, 100),
+		session("proj_a", "s2", "v2", day.Add(2*time.Hour), 20, 1, 20),
+		session("proj_a", "s3", "", day.Add(3*time.Hour), 0, 1, 0),
+		session("proj_b", "s4", "v9", day.Add(time.Hour), 6, 2, 40),
+	}
+	if _, err := GetWidgetSessionsCollection().InsertMany(ctx, sessions); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	// Running twice upserts the same rows
+	for i := 0; i < 2; i++ {
+		if err := AggregateWidgetAnalytics(); err != nil {
+			t.Fatalf("AggregateWidgetAnalytics: %v", err)
+		}
+	}
+	if count, err := GetWidgetAnalyticsCollection().CountDocuments(ctx, bson.M{}); err != nil || count != 2 {
+		t.Fatalf("widget_analytics rows = %d (%v), want one per project and day", count, err)
+	}
+
+	var row struct {
+		TotalSessions   int     `bson:"total_sessions"`
+		UniqueSessions  int     `bson:"unique_sessions"`
+		UniqueVisitors  int     `bson:"unique_visitors"`
+		ReturnUsers     int     `bson:"return_users"`
+		TotalMessages   int     `bson:"total_messages"`
+		AverageMessages float64 `bson:"average_messages"`
+		TotalTokens     int64   `bson:"total_tokens"`
+		BounceRate      float64 `bson:"bounce_rate"`
+		AverageDuration float64 `bson:"average_session_duration"`
+	}
+	if err := GetWidgetAnalyticsCollection().FindOne(ctx, bson.M{"project_id": "proj_a", "date": day}).Decode(&row); err != nil {
+		t.Fatalf("find proj_a: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"total_sessions", float64(row.TotalSessions), 3},
+		{"unique_sessions", float64(row.UniqueSessions), 3},
+		{"unique_visitors", float64(row.UniqueVisitors), 2},
+		{"return_users", float64(row.ReturnUsers), 1},
+		{"total_messages", float64(row.TotalMessages), 6},
+		{"average_messages", row.AverageMessages, 2},
+		{"total_tokens", float64(row.TotalTokens), 120},
+		{"bounce_rate", row.BounceRate, 200.0 / 3},
+		{"average_session_duration", row.AverageDuration, 10},
+	}
+	for _, tt := range tests {
+		if diff := tt.got - tt.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestAggregateWidgetAnalyticsWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if err := AggregateWidgetAnalytics(); err == nil {
+		t.Error("expected an error without a database")
+	}
+}
@@ -68,13 +68,9 @@ func InitMongoDB() {
 		log.Printf("⚠️ Warning during collection verification: %v", err)
 	}
 
-	// Initialize subscription defaults for existing projects
-	go func() {
-		time.Sleep(2 * time.Second) // Wait for connection to stabilize
-		if err := InitializeSubscriptionDefaults(); err != nil {
-			log.Printf("⚠️ Warning during subscription initialization: %v", err)
-		}
-	}()
+	// Initialize subscription defaults for existing projects; the server reports
+	// ready (IsReady) only once this and the database verification have finished
+	go completeStartup()
 }
 
 // testConnection - Test MongoDB connection with retry logic
@@ -122,6 +118,10 @@ func verifyCollections(ctx context.Context) error {
 		"widget_analytics",
 		"openai_usage_logs",
 		"notifications",
+		"maintenance_jobs",
+		"document_chunks",
+		"overage_records",
+		"knowledge_gaps",
 	}
 
 	// List existing collections
@@ -156,35 +156,49 @@ func setupIndexes(ctx context.Context) error {
 	projectsCol := DB.Collection("projects")
 	_, err := projectsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
-			Keys:    bson.D{{"project_id", 1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}},
 			Options: options.Index().SetBackground(true).SetUnique(true),
 		},
 		{
-			Keys:    bson.D{{"name", 1}},
+			// Dashboard: name prefix search (with the project_id index)
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"status", 1}},
+			Keys:    bson.D{{Key: "expiry_date", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"expiry_date", 1}},
+			Keys:    bson.D{{Key: "total_tokens_used", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"total_tokens_used", 1}},
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "expiry_date", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"status", 1}, {"expiry_date", 1}},
+			Keys:    bson.D{{Key: "client_id", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"client_id", 1}},
+			Keys:    bson.D{{Key: "created_by_user_id", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"created_at", -1}},
+			Keys:    bson.D{{Key: "owner_id", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			// Dashboard: status filter with default created_at sort
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
 			Options: options.Index().SetBackground(true),
 		},
 	})
@@ -196,19 +210,19 @@ func setupIndexes(ctx context.Context) error {
 	clientsCol := DB.Collection("clients")
 	_, err = clientsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
-			Keys:    bson.D{{"client_id", 1}},
+			Keys:    bson.D{{Key: "client_id", Value: 1}},
 			Options: options.Index().SetBackground(true).SetUnique(true),
 		},
 		{
-			Keys:    bson.D{{"email", 1}},
+			Keys:    bson.D{{Key: "email", Value: 1}},
 			Options: options.Index().SetBackground(true).SetUnique(true),
 		},
 		{
-			Keys:    bson.D{{"status", 1}},
+			Keys:    bson.D{{Key: "status", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"created_at", -1}},
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
 			Options: options.Index().SetBackground(true),
 		},
 	})
@@ -220,15 +234,15 @@ func setupIndexes(ctx context.Context) error {
 	chatCol := DB.Collection("chat_messages")
 	_, err = chatCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
-			Keys:    bson.D{{"project_id", 1}, {"session_id", 1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "session_id", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"timestamp", -1}},
+			Keys:    bson.D{{Key: "timestamp", Value: -1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"project_id", 1}, {"timestamp", -1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "timestamp", Value: -1}},
 			Options: options.Index().SetBackground(true),
 		},
 	})
@@ -240,15 +254,19 @@ func setupIndexes(ctx context.Context) error {
 	widgetCol := DB.Collection("widget_sessions")
 	_, err = widgetCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
-			Keys:    bson.D{{"session_id", 1}},
+			Keys:    bson.D{{Key: "session_id", Value: 1}},
 			Options: options.Index().SetBackground(true).SetUnique(true),
 		},
 		{
-			Keys:    bson.D{{"project_id", 1}, {"started_at", -1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "started_at", Value: -1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys:    bson.D{{Key: "is_active", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"is_active", 1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "visitor_id", Value: 1}, {Key: "started_at", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 	})
@@ -260,15 +278,15 @@ func setupIndexes(ctx context.Context) error {
 	usageCol := DB.Collection("openai_usage_logs")
 	_, err = usageCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
-			Keys:    bson.D{{"project_id", 1}, {"timestamp", -1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "timestamp", Value: -1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"timestamp", -1}},
+			Keys:    bson.D{{Key: "timestamp", Value: -1}},
 			Options: options.Index().SetBackground(true),
 		},
 		{
-			Keys:    bson.D{{"project_id", 1}, {"success", 1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "success", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 	})
@@ -280,15 +298,21 @@ func setupIndexes(ctx context.Context) error {
 	notificationsCol := DB.Collection("notifications")
 	_, err = notificationsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
-			Keys:    bson.D{{"project_id", 1}, {"sent_at", -1}},
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "sent_at", Value: -1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys:    bson.D{{Key: "type", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
+		// Keyset paging of the notification history
 		{
-			Keys:    bson.D{{"type", 1}},
+			Keys:    bson.D{{Key: "sent_at", Value: -1}, {Key: "_id", Value: -1}},
 			Options: options.Index().SetBackground(true),
 		},
+		// Notifications waiting for a client's daily digest
 		{
-			Keys:    bson.D{{"sent_at", -1}},
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "digest_client_id", Value: 1}, {Key: "sent_at", Value: 1}},
 			Options: options.Index().SetBackground(true),
 		},
 	})
@@ -296,6 +320,112 @@ func setupIndexes(ctx context.Context) error {
 		log.Printf("⚠️ Failed to create notifications indexes: %v", err)
 	}
 
+	// Usage anomaly detection: one baseline per project, anomalies listed newest first
+	_, err = DB.Collection("usage_baselines").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetBackground(true),
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create usage_baselines indexes: %v", err)
+	}
+	_, err = DB.Collection("usage_anomalies").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "detected_at", Value: -1}},
+		Options: options.Index().SetBackground(true),
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create usage_anomalies indexes: %v", err)
+	}
+
+	// Widget analytics collection indexes (daily rollups are the long-term store)
+	analyticsCol := DB.Collection("widget_analytics")
+	_, err = analyticsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "date", Value: -1}},
+			Options: options.Index().SetBackground(true).SetUnique(true),
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create widget_analytics indexes: %v", err)
+	}
+
+	// Document chunk vectors (default scan store; Atlas deployments add a vector search index)
+	chunksCol := DB.Collection("document_chunks")
+	_, err = chunksCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "document_id", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			// Scan retrieval: one project's chunks from one embedding model
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "embedding_model", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create document_chunks indexes: %v", err)
+	}
+
+	// Billable overage, one record per chat message beyond the limit
+	overageCol := DB.Collection("overage_records")
+	_, err = overageCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "billed", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create overage_records indexes: %v", err)
+	}
+
+	// Unanswered questions per project, newest first within a status
+	gapsCol := DB.Collection("knowledge_gaps")
+	_, err = gapsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetBackground(true),
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create knowledge_gaps indexes: %v", err)
+	}
+
+	// Moderation review queue per project, and whitelist lookups by content
+	moderationCol := DB.Collection("moderation_flags")
+	_, err = moderationCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetBackground(true),
+		},
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "content_hash", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create moderation_flags indexes: %v", err)
+	}
+
+	// Leads are deduplicated by email within a project
+	chatUsersCol := DB.Collection("chat_users")
+	_, err = chatUsersCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().SetBackground(true),
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to create chat_users indexes: %v", err)
+	}
+
+	// TTL indexes - expire raw sessions and usage logs after the retention period
+	if err := setupRetentionIndexes(ctx); err != nil {
+		log.Printf("⚠️ Failed to create retention indexes: %v", err)
+	}
+
 	log.Println("📈 Database indexes setup completed")
 	return nil
 }
@@ -346,6 +476,27 @@ func GetNotificationsCollection() *mongo.Collection {
 	return GetCollection("notifications")
 }
 
+func GetMaintenanceJobsCollection() *mongo.Collection {
+	return GetCollection("maintenance_jobs")
+}
+
+func GetDocumentChunksCollection() *mongo.Collection {
+	return GetCollection("document_chunks")
+}
+
+func GetOverageRecordsCollection() *mongo.Collection {
+	return GetCollection("overage_records")
+}
+
+func GetKnowledgeGapsCollection() *mongo.Collection {
+	return GetCollection("knowledge_gaps")
+}
+
+// GetModerationFlagsCollection - Flagged chat messages awaiting or after review
+func GetModerationFlagsCollection() *mongo.Collection {
+	return GetCollection("moderation_flags")
+}
+
 // Health check and connection monitoring
 func HealthCheck() error {
 	if DB == nil {
@@ -425,7 +576,9 @@ func CloseMongoDB() {
 	}
 }
 
-// FixProjectLimits - Enhanced project limits fixing with configurable defaults
+// FixProjectLimits - Fill in missing subscription/AI fields with configurable defaults.
+// Each field is only set on projects where it is absent, so existing values (suspended or
+// expired status, Gemini provider, custom models, zero limits) are never overwritten.
 func FixProjectLimits() error {
 	if DB == nil {
 		return fmt.Errorf("database not initialized")
@@ -436,98 +589,60 @@ func FixProjectLimits() error {
 
 	collection := GetProjectsCollection()
 
-	// Find projects with zero limits or missing subscription fields
-	filter := bson.M{
-		"$or": []bson.M{
-			{"status": bson.M{"$exists": false}},
-			{"expiry_date": bson.M{"$exists": false}},
-			{"total_tokens_used": bson.M{"$exists": false}},
-			{"monthly_token_limit": bson.M{"$exists": false}},
-			{"start_date": bson.M{"$exists": false}},
-			{"project_id": bson.M{"$exists": false}},
-			{"status": ""},
-		},
-	}
-
-	// Get configurable defaults from environment
-	defaultTokenLimit := getEnvInt64("DEFAULT_MONTHLY_TOKEN_LIMIT", 100000)
+	// Same defaults as project creation (DEFAULT_SUBSCRIPTION_MONTHS / DEFAULT_MONTHLY_TOKEN_LIMIT)
+	subDefaults := GetSubscriptionDefaults()
+	now := time.Now()
+
+	defaults := []struct {
+		field  string
+		filter bson.M
+		value  interface{}
+	}{
+		// An empty status is as good as missing
+		{"status", bson.M{"$or": []bson.M{{"status": bson.M{"$exists": false}}, {"status": ""}}}, "active"},
+		{"start_date", nil, now},
+		{"expiry_date", nil, subDefaults.ExpiryFrom(now)},
+		{"monthly_token_limit", nil, subDefaults.MonthlyTokenLimit},
+		{"total_tokens_used", nil, int64(0)},
+		{"ai_provider", nil, "openai"},
+		{"openai_model", nil, "gpt-4o"},
+		{"embedding_model", nil, legacyEmbeddingModel},
+	}
+
+	var fixed int64
+	for _, d := range defaults {
+		filter := d.filter
+		if filter == nil {
+			filter = bson.M{d.field: bson.M{"$exists": false}}
+		}
 
-	update := bson.M{
-		"$set": bson.M{
-			"updated_at": time.Now(),
-			// Subscription fields
-			"status":              "active",
-			"start_date":          time.Now(),
-			"expiry_date":         time.Now().AddDate(0, 1, 0), // 1 month from now
-			"monthly_token_limit": defaultTokenLimit,
-			// OpenAI configuration
-			"ai_provider":  "openai",
-			"openai_model": "gpt-4o",
-		},
-		"$setOnInsert": bson.M{
-			"total_tokens_used": int64(0), // Only set if field doesn't exist
-		},
-	}
+		result, err := collection.UpdateMany(ctx, filter, bson.M{
+			"$set": bson.M{d.field: d.value, "updated_at": now},
+		})
+		if err != nil {
+			log.Printf("❌ Database error in FixProjectLimits (%s): %v", d.field, err)
+			return fmt.Errorf("failed to fix project limits: %v", err)
+		}
 
-	result, err := collection.UpdateMany(ctx, filter, update)
-	if err != nil {
-		log.Printf("❌ Database error in FixProjectLimits: %v", err)
-		return fmt.Errorf("failed to fix project limits: %v", err)
+		if result.ModifiedCount > 0 {
+			log.Printf("✅ Set default %s on %d projects", d.field, result.ModifiedCount)
+			fixed += result.ModifiedCount
+		}
 	}
 
-	if result.ModifiedCount == 0 {
+	if fixed == 0 {
 		log.Printf("ℹ️ No projects needed subscription field updates")
 	} else {
-		log.Printf("✅ Fixed limits and subscription fields for %d projects", result.ModifiedCount)
-		log.Printf("📊 Applied defaults: Tokens=%d", defaultTokenLimit)
+		log.Printf("📊 Applied defaults: Tokens=%d, Months=%d", subDefaults.MonthlyTokenLimit, subDefaults.Months)
 	}
 
 	return nil
 }
 
-// InitializeSubscriptionDefaults - Initialize subscription defaults for existing projects
+// InitializeSubscriptionDefaults - Initialize subscription defaults for existing projects.
+// Shares FixProjectLimits' per-field logic so startup never overwrites existing values.
 func InitializeSubscriptionDefaults() error {
-	if DB == nil {
-		return fmt.Errorf("database not initialized")
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	collection := GetProjectsCollection()
-
-	// Find projects missing subscription fields
-	filter := bson.M{
-		"$or": []bson.M{
-			{"status": bson.M{"$exists": false}},
-			{"expiry_date": bson.M{"$exists": false}},
-			{"total_tokens_used": bson.M{"$exists": false}},
-			{"monthly_token_limit": bson.M{"$exists": false}},
-			{"start_date": bson.M{"$exists": false}},
-			{"ai_provider": bson.M{"$exists": false}},
-		},
-	}
-
-	update := bson.M{
-		"$set": bson.M{
-			"status":              "active",
-			"start_date":          time.Now(),
-			"expiry_date":         time.Now().AddDate(0, 1, 0), // 1 month from now
-			"total_tokens_used":   int64(0),
-			"monthly_token_limit": int64(100000), // 100k tokens default
-			"ai_provider":         "openai",
-			"openai_model":        "gpt-4o",
-			"updated_at":          time.Now(),
-		},
-	}
-
-	result, err := collection.UpdateMany(ctx, filter, update)
-	if err != nil {
-		return fmt.Errorf("failed to initialize subscription defaults: %v", err)
-	}
-
-	log.Printf("✅ Initialized subscription defaults for %d projects", result.ModifiedCount)
-	return nil
+	return FixProjectLimits()
 }
 
 // GetExpiredProjects - Get projects with expired subscriptions
@@ -566,18 +681,29 @@ func GetExpiredProjects() ([]primitive.ObjectID, error) {
 	return expiredProjects, nil
 }
 
-// UpdateExpiredProjects - Mark expired projects as expired
+// UpdateExpiredProjects - Mark expired projects as expired and notify their clients
 func UpdateExpiredProjects() error {
 	if DB == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
+	expired, err := GetExpiredProjects()
+	if err != nil {
+		return fmt.Errorf("failed to find expired projects: %v", err)
+	}
+	if len(expired) == 0 {
+		log.Printf("✅ Marked 0 projects as expired")
+		return nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	collection := GetProjectsCollection()
 
+	// Re-check the expiry so a project renewed in the meantime stays active
 	filter := bson.M{
+		"_id":         bson.M{"$in": expired},
 		"expiry_date": bson.M{"$lt": time.Now()},
 		"status":      bson.M{"$ne": "expired"},
 	}
@@ -594,6 +720,11 @@ func UpdateExpiredProjects() error {
 		return fmt.Errorf("failed to update expired projects: %v", err)
 	}
 
+	for _, projectID := range expired {
+		LogNotification(projectID, NotificationExpired,
+			"Your chatbot subscription has expired and the chatbot is offline. Renew it to bring the chatbot back.")
+	}
+
 	log.Printf("✅ Marked %d projects as expired", result.ModifiedCount)
 	return nil
 }
@@ -602,6 +733,12 @@ func UpdateExpiredProjects() error {
 func RunSubscriptionMaintenance() error {
 	log.Println("🔄 Running subscription maintenance...")
 
+	// Suspend trials that have run out (before the expiry sweep, so they are suspended, not expired)
+	if _, err := EndExpiredTrials(time.Now()); err != nil {
+		log.Printf("❌ Failed to end expired trials: %v", err)
+		return err
+	}
+
 	// Update expired projects
 	if err := UpdateExpiredProjects(); err != nil {
 		log.Printf("❌ Failed to update expired projects: %v", err)
@@ -618,10 +755,45 @@ func RunSubscriptionMaintenance() error {
 	return nil
 }
 
-// LogNotification - Log notification events to database
+// NotificationDispatcher - Delivers a logged notification to the channels enabled for its target
+// (the project, or platform admins for a nil project ID)
+type NotificationDispatcher func(notificationID, projectID primitive.ObjectID, notificationType, message string)
+
+// notificationDispatcher - Installed at startup; the channels live in utils, which imports config
+var notificationDispatcher NotificationDispatcher
+
+// SetNotificationDispatcher - Make LogNotification fan out to notification channels
+func SetNotificationDispatcher(dispatcher NotificationDispatcher) {
+	notificationDispatcher = dispatcher
+}
+
+// LogNotification - Log notification events to database and deliver them in the background
 func LogNotification(projectID primitive.ObjectID, notificationType, message string) error {
+	return LogNotificationDetails(projectID, notificationType, message, nil)
+}
+
+// LogNotificationDetails - LogNotification with extra fields stored on the notification (e.g. the
+// usage threshold it is about), which WasNotificationSentSince can match on
+func LogNotificationDetails(projectID primitive.ObjectID, notificationType, message string, details bson.M) error {
+	notificationID, err := recordNotification(projectID, notificationType, message, details)
+	if err != nil {
+		return err
+	}
+
+	if notificationDispatcher != nil {
+		go notificationDispatcher(notificationID, projectID, notificationType, message)
+	}
+	return nil
+}
+
+// RecordNotification - Log a notification to the database without delivering it
+func RecordNotification(projectID primitive.ObjectID, notificationType, message string) (primitive.ObjectID, error) {
+	return recordNotification(projectID, notificationType, message, nil)
+}
+
+func recordNotification(projectID primitive.ObjectID, notificationType, message string, details bson.M) (primitive.ObjectID, error) {
 	if DB == nil {
-		return fmt.Errorf("database not initialized")
+		return primitive.NilObjectID, fmt.Errorf("database not initialized")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -629,26 +801,45 @@ func LogNotification(projectID primitive.ObjectID, notificationType, message str
 
 	collection := GetNotificationsCollection()
 
-	notification := bson.M{
-		"project_id": projectID,
-		"type":       notificationType,
-		"message":    message,
-		"sent_at":    time.Now(),
-		"status":     "sent",
+	notificationID := primitive.NewObjectID()
+	notification := bson.M{}
+	for key, value := range details {
+		notification[key] = value
 	}
+	notification["_id"] = notificationID
+	notification["project_id"] = projectID
+	notification["type"] = notificationType
+	notification["message"] = message
+	notification["sent_at"] = time.Now()
+	notification["status"] = "sent"
 
 	_, err := collection.InsertOne(ctx, notification)
 	if err != nil {
 		log.Printf("❌ Failed to log notification: %v", err)
-		return err
+		return primitive.NilObjectID, err
 	}
 
 	log.Printf("✅ Notification logged: %s for project %s", notificationType, projectID.Hex())
-	return nil
+	return notificationID, nil
 }
 
-// WasNotificationRecentlySent - Check if notification was recently sent
-func WasNotificationRecentlySent(projectID primitive.ObjectID, notificationType string, hours int) (bool, error) {
+// WasNotificationRecentlySent - Check if notification was recently sent. An optional discriminator
+// (detail fields stored with LogNotificationDetails, e.g. {"threshold": 95}) narrows the check, so
+// notifications of one type about different things dedupe independently; without it, any
+// notification of the type counts.
+func WasNotificationRecentlySent(projectID primitive.ObjectID, notificationType string, hours int, discriminator ...bson.M) (bool, error) {
+	details := bson.M{}
+	for _, fields := range discriminator {
+		for key, value := range fields {
+			details[key] = value
+		}
+	}
+	return WasNotificationSentSince(projectID, notificationType, time.Now().Add(-time.Duration(hours)*time.Hour), details)
+}
+
+// WasNotificationSentSince - Check if a notification of this type, with the given detail fields
+// (see LogNotificationDetails), was sent since the given time
+func WasNotificationSentSince(projectID primitive.ObjectID, notificationType string, since time.Time, details bson.M) (bool, error) {
 	if DB == nil {
 		return false, fmt.Errorf("database not initialized")
 	}
@@ -658,13 +849,13 @@ func WasNotificationRecentlySent(projectID primitive.ObjectID, notificationType
 
 	collection := GetNotificationsCollection()
 
-	filter := bson.M{
-		"project_id": projectID,
-		"type":       notificationType,
-		"sent_at": bson.M{
-			"$gte": time.Now().Add(-time.Duration(hours) * time.Hour),
-		},
+	filter := bson.M{}
+	for key, value := range details {
+		filter[key] = value
 	}
+	filter["project_id"] = projectID
+	filter["type"] = notificationType
+	filter["sent_at"] = bson.M{"$gte": since}
 
 	count, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
@@ -674,6 +865,29 @@ func WasNotificationRecentlySent(projectID primitive.ObjectID, notificationType
 	return count > 0, nil
 }
 
+// LastUsageReset - When the project's usage was last reset or its subscription renewed (the start
+// of its current usage cycle), zero if never
+func LastUsageReset(projectID primitive.ObjectID) (time.Time, error) {
+	if DB == nil {
+		return time.Time{}, fmt.Errorf("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var last struct {
+		SentAt time.Time `bson:"sent_at"`
+	}
+	err := GetNotificationsCollection().FindOne(ctx,
+		bson.M{"project_id": projectID, "type": bson.M{"$in": []string{"usage_reset", "usage_reset_all", NotificationRenewal}}},
+		options.FindOne().SetSort(bson.M{"sent_at": -1}).SetProjection(bson.M{"sent_at": 1}),
+	).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return time.Time{}, nil
+	}
+	return last.SentAt, err
+}
+
 // Helper functions for environment variable parsing
 func getEnvInt(key string, defaultValue int) int {
 	if envValue := os.Getenv(key); envValue != "" {
@@ -710,9 +924,13 @@ const (
 
 // Notification type constants
 const (
-	NotificationMonthlyLimit = "monthly_limit"
-	NotificationUsageWarning = "usage_warning"
-	NotificationExpired      = "expired"
-	NotificationRenewal      = "renewal"
-	NotificationTest         = "test"
+	NotificationMonthlyLimit  = "monthly_limit"
+	NotificationUsageWarning  = "usage_warning"
+	NotificationExpired       = "expired"
+	NotificationRenewal       = "renewal"
+	NotificationTest          = "test"
+	NotificationPaymentFailed = "payment_failed"
+	NotificationDigest        = "digest"
+	NotificationUserUnlocked  = "user_unlocked"
+	NotificationUsageAnomaly  = "usage_anomaly"
 )
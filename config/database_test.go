@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// useTestDatabase - Point DB at a throwaway database on MONGODB_TEST_URI, dropped when the test
+// ends. Tests that need MongoDB are skipped when the variable is unset.
+func useTestDatabase(t *testing.T) context.Context {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	previous := DB
+	DB = client.Database(fmt.Sprintf("troika_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		DB.Drop(context.Background())
+		client.Disconnect(context.Background())
+		DB = previous
+	})
+	return ctx
+}
+
+func TestSetupIndexesCoverDashboardSearch(t *testing.T) {
+	ctx := useTestDatabase(t)
+	if err := setupIndexes(ctx); err != nil {
+		t.Fatalf("setupIndexes: %v", err)
+	}
+
+	cursor, err := GetProjectsCollection().Indexes().List(ctx)
+	if err != nil {
+		t.Fatalf("list indexes: %v", err)
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		t.Fatalf("decode indexes: %v", err)
+	}
+	names := map[string]bool{}
+	for _, index := range indexes {
+		names[fmt.Sprint(index["name"])] = true
+	}
+
+	// handlers.projectSearchFilter matches name and project_id prefixes
+	for _, want := range []string{"name_1", "project_id_1"} {
+		if !names[want] {
+			t.Errorf("projects has no %s index: %v", want, names)
+		}
+	}
+}
+
+func TestFixProjectLimitsOnlyFillsMissingFields(t *testing.T) {
+	ctx := useTestDatabase(t)
+	stubSubscriptionDefaults(t, SubscriptionDefaults{Months: 12, MonthlyTokenLimit: 5000})
+
+	expiry := time.Now().AddDate(0, 0, -2).Truncate(time.Millisecond)
+	projects := []interface{}{
+		bson.M{
+			"project_id": "custom", "status": "suspended", "start_date": expiry, "expiry_date": expiry,
+			"monthly_token_limit": int64(0), "total_tokens_used": int64(42),
+			"ai_provider": "gemini", "openai_model": "gpt-4o-mini", "embedding_model": "text-embedding-3-small",
+		},
+		bson.M{"project_id": "bare", "status": ""},
+	}
+	if _, err := GetProjectsCollection().InsertMany(ctx, projects); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := FixProjectLimits(); err != nil {
+		t.Fatalf("FixProjectLimits: %v", err)
+	}
+
+	find := func(projectID string) bson.M {
+		t.Helper()
+		var project bson.M
+		if err := GetProjectsCollection().FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project); err != nil {
+			t.Fatalf("find %s: %v", projectID, err)
+		}
+		return project
+	}
+
+	custom := find("custom")
+	for field, want := range map[string]interface{}{
+		"status": "suspended", "monthly_token_limit": int64(0), "total_tokens_used": int64(42),
+		"ai_provider": "gemini", "openai_model": "gpt-4o-mini", "embedding_model": "text-embedding-3-small",
+	} {
+		if fmt.Sprint(custom[field]) != fmt.Sprint(want) {
+			t.Errorf("custom %s = %v, want it kept at %v", field, custom[field], want)
+		}
+	}
+	if _, changed := custom["updated_at"]; changed {
+		t.Error("a project with every field set was touched")
+	}
+
+	bare := find("bare")
+	for field, want := range map[string]interface{}{
+		"status": "active", "monthly_token_limit": int64(5000), "total_tokens_used": int64(0),
+		"ai_provider": "openai", "openai_model": "gpt-4o",
+	} {
+		if fmt.Sprint(bare[field]) != fmt.Sprint(want) {
+			t.Errorf("bare %s = %v, want default %v", field, bare[field], want)
+		}
+	}
+	for _, field := range []string{"start_date", "expiry_date"} {
+		if _, ok := bare[field]; !ok {
+			t.Errorf("bare project still lacks %s", field)
+		}
+	}
+}
+
+func TestFixProjectLimitsWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if err := FixProjectLimits(); err == nil {
+		t.Error("expected an error without a database")
+	}
+}
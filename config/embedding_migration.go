@@ -0,0 +1,59 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyEmbeddingModel - The model every vector was made with before it was recorded
+const legacyEmbeddingModel = "text-embedding-ada-002"
+
+// MigrateEmbeddingModels - Record the embedding model (and chunk dimension) on vectors stored
+// before models were tracked, so retrieval only compares vectors from the same model.
+// Idempotent: only documents without a recorded model are touched.
+func MigrateEmbeddingModels() error {
+	if DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Chunk vectors: model plus the dimension read from the stored vector
+	chunks, err := GetDocumentChunksCollection().UpdateMany(ctx,
+		bson.M{"embedding_model": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"embedding_model": legacyEmbeddingModel,
+			"dimensions":      bson.M{"$size": bson.M{"$ifNull": bson.A{"$vector", bson.A{}}}},
+		}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to migrate chunk embeddings: %v", err)
+	}
+
+	// Per-document vectors on projects
+	documents, err := GetProjectsCollection().UpdateMany(ctx,
+		bson.M{"pdf_files": bson.M{"$elemMatch": bson.M{
+			"embeddings.0":    bson.M{"$exists": true},
+			"embedding_model": bson.M{"$in": bson.A{nil, ""}},
+		}}},
+		bson.M{"$set": bson.M{"pdf_files.$[file].embedding_model": legacyEmbeddingModel}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"file.embeddings.0": bson.M{"$exists": true}, "file.embedding_model": bson.M{"$in": bson.A{nil, ""}}},
+		}}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to migrate document embeddings: %v", err)
+	}
+
+	if chunks.ModifiedCount > 0 || documents.ModifiedCount > 0 {
+		log.Printf("✅ Recorded embedding model on %d chunks and %d projects' documents", chunks.ModifiedCount, documents.ModifiedCount)
+	}
+	return nil
+}
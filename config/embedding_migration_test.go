@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMigrateEmbeddingModels(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	GetDocumentChunksCollection().InsertMany(ctx, []interface{}{
+		bson.M{"_id": "legacy", "project_id": "proj_1", "vector": bson.A{0.1, 0.2, 0.3}},
+		bson.M{"_id": "tracked", "project_id": "proj_1", "vector": bson.A{0.1}, "embedding_model": "text-embedding-3-small", "dimensions": 1},
+	})
+	GetProjectsCollection().InsertOne(ctx, bson.M{"project_id": "proj_1", "pdf_files": bson.A{
+		bson.M{"id": "embedded", "embeddings": bson.A{0.1, 0.2}},
+		bson.M{"id": "not_embedded"},
+		bson.M{"id": "tracked", "embeddings": bson.A{0.1}, "embedding_model": "text-embedding-3-large"},
+	}})
+
+	for run := 0; run < 2; run++ {
+		if err := MigrateEmbeddingModels(); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+
+	var legacy, tracked bson.M
+	GetDocumentChunksCollection().FindOne(ctx, bson.M{"_id": "legacy"}).Decode(&legacy)
+	GetDocumentChunksCollection().FindOne(ctx, bson.M{"_id": "tracked"}).Decode(&tracked)
+	if legacy["embedding_model"] != legacyEmbeddingModel || legacy["dimensions"] != int32(3) {
+		t.Errorf("legacy chunk = %v, want the legacy model and its dimension", legacy)
+	}
+	if tracked["embedding_model"] != "text-embedding-3-small" {
+		t.Errorf("tracked chunk = %v, want its model kept", tracked)
+	}
+
+	var project struct {
+		PDFFiles []bson.M `bson:"pdf_files"`
+	}
+	GetProjectsCollection().FindOne(ctx, bson.M{"project_id": "proj_1"}).Decode(&project)
+	want := map[string]interface{}{"embedded": legacyEmbeddingModel, "not_embedded": nil, "tracked": "text-embedding-3-large"}
+	for _, file := range project.PDFFiles {
+		if file["embedding_model"] != want[file["id"].(string)] {
+			t.Errorf("document %v: embedding_model = %v, want %v", file["id"], file["embedding_model"], want[file["id"].(string)])
+		}
+	}
+}
+
+func TestMigrateEmbeddingModelsWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if err := MigrateEmbeddingModels(); err == nil {
+		t.Error("MigrateEmbeddingModels should fail without a database")
+	}
+}
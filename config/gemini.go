@@ -30,6 +30,61 @@ func InitGemini() {
 	log.Println("✅ Gemini client initialized successfully")
 }
 
+// GeminiConfigured reports whether GEMINI_API_KEY is set
+func GeminiConfigured() bool {
+	return os.Getenv("GEMINI_API_KEY") != ""
+}
+
+// InitGeminiIfConfigured - InitGemini when GEMINI_API_KEY is set; otherwise Gemini stays
+// unavailable and projects using it fail over to their fallback provider
+func InitGeminiIfConfigured() {
+	if !GeminiConfigured() {
+		log.Println("ℹ️ GEMINI_API_KEY not set, Gemini provider disabled")
+		return
+	}
+	InitGemini()
+}
+
+// GenerateChat - One chat turn with Gemini: system instruction, prior turns (roles "user" and
+// "model"), then the user message. Returns the reply and total tokens used.
+func GenerateChat(ctx context.Context, modelName, systemInstruction string, history []*genai.Content, userMessage string) (string, int, error) {
+	if GeminiClient == nil {
+		return "", 0, fmt.Errorf("Gemini client not initialized")
+	}
+
+	model := GeminiClient.GenerativeModel(modelName)
+	model.SystemInstruction = genai.NewUserContent(genai.Text(systemInstruction))
+	model.SetMaxOutputTokens(500)
+	model.SetTemperature(0.7)
+
+	session := model.StartChat()
+	session.History = history
+
+	resp, err := session.SendMessage(ctx, genai.Text(userMessage))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to generate content: %v", err)
+	}
+
+	tokens := 0
+	if resp.UsageMetadata != nil {
+		tokens = int(resp.UsageMetadata.TotalTokenCount)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", tokens, fmt.Errorf("no response generated")
+	}
+
+	var reply string
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if text, ok := part.(genai.Text); ok {
+			reply += string(text)
+		}
+	}
+	if reply == "" {
+		return "", tokens, fmt.Errorf("no response generated")
+	}
+	return reply, tokens, nil
+}
+
 // Generates a polished, human-like response
 func GenerateResponse(userPrompt string, pdfContext string) (string, error) {
 	ctx := context.Background()
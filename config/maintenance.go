@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultMaintenanceMessage - Shown to widget visitors when no message was set
+const DefaultMaintenanceMessage = "Chat is briefly unavailable for maintenance. Please try again in a few minutes."
+
+// maintenanceCacheTTL - How long an instance trusts its copy of the flag; other instances pick up
+// a toggle within this time
+const maintenanceCacheTTL = 10 * time.Second
+
+// MaintenanceState - Whether chat traffic is paused. Stored in the settings collection so every
+// instance sees the same flag; MAINTENANCE_MODE=true forces it on regardless.
+type MaintenanceState struct {
+	Enabled   bool      `bson:"enabled" json:"enabled"`
+	Message   string    `bson:"message,omitempty" json:"message,omitempty"`
+	UpdatedBy string    `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	Source    string    `bson:"-" json:"source"` // "settings" or "env"
+}
+
+var (
+	maintenanceMu       sync.Mutex
+	maintenanceCached   MaintenanceState
+	maintenanceLoadedAt time.Time
+)
+
+// GetMaintenanceMode - Current maintenance state, cached for maintenanceCacheTTL. A failed
+// lookup keeps the last known state rather than pausing or resuming chat on a database blip.
+func GetMaintenanceMode() MaintenanceState {
+	if os.Getenv("MAINTENANCE_MODE") == "true" {
+		return MaintenanceState{Enabled: true, Message: os.Getenv("MAINTENANCE_MESSAGE"), Source: "env"}
+	}
+
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+
+	if time.Since(maintenanceLoadedAt) < maintenanceCacheTTL || DB == nil {
+		return maintenanceCached
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var state MaintenanceState
+	err := GetCollection("settings").FindOne(ctx, bson.M{"_id": "maintenance"}).Decode(&state)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("⚠️ Failed to load maintenance mode, keeping the last known state: %v", err)
+		maintenanceLoadedAt = time.Now()
+		return maintenanceCached
+	}
+
+	state.Source = "settings"
+	maintenanceCached = state
+	maintenanceLoadedAt = time.Now()
+	return state
+}
+
+// SetMaintenanceMode - Turn maintenance mode on or off for every instance
+func SetMaintenanceMode(ctx context.Context, enabled bool, message, updatedBy string) (MaintenanceState, error) {
+	state := MaintenanceState{
+		Enabled:   enabled,
+		Message:   message,
+		UpdatedBy: updatedBy,
+		UpdatedAt: time.Now(),
+		Source:    "settings",
+	}
+
+	_, err := GetCollection("settings").ReplaceOne(ctx,
+		bson.M{"_id": "maintenance"},
+		state,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return MaintenanceState{}, err
+	}
+
+	maintenanceMu.Lock()
+	maintenanceCached = state
+	maintenanceLoadedAt = time.Now()
+	maintenanceMu.Unlock()
+
+	if enabled {
+		log.Printf("🚧 Maintenance mode enabled by %s", updatedBy)
+	} else {
+		log.Printf("✅ Maintenance mode disabled by %s", updatedBy)
+	}
+	return state, nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FailInterruptedMaintenanceJobs - Jobs run in-process, so any still queued/running at startup
+// were cut off by a restart; mark them failed so new jobs aren't blocked behind them.
+func FailInterruptedMaintenanceJobs() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	result, err := GetMaintenanceJobsCollection().UpdateMany(ctx,
+		bson.M{"status": bson.M{"$in": []string{"queued", "running"}}},
+		bson.M{
+			"$set":  bson.M{"status": "failed", "completed_at": now},
+			"$push": bson.M{"errors": "interrupted by server restart"},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("⚠️ Marked %d interrupted maintenance jobs as failed", result.ModifiedCount)
+	}
+	return nil
+}
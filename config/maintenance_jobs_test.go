@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFailInterruptedMaintenanceJobs(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	jobs := []interface{}{
+		bson.M{"_id": "queued", "status": "queued"},
+		bson.M{"_id": "running", "status": "running"},
+		bson.M{"_id": "completed", "status": "completed"},
+	}
+	if _, err := GetMaintenanceJobsCollection().InsertMany(ctx, jobs); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := FailInterruptedMaintenanceJobs(); err != nil {
+		t.Fatalf("FailInterruptedMaintenanceJobs: %v", err)
+	}
+
+	tests := []struct {
+		id, wantStatus string
+		wantErrors     int
+	}{
+		{"queued", "failed", 1},
+		{"running", "failed", 1},
+		{"completed", "completed", 0},
+	}
+	for _, tt := range tests {
+		var job struct {
+			Status string   `bson:"status"`
+			Errors []string `bson:"errors"`
+		}
+		if err := GetMaintenanceJobsCollection().FindOne(ctx, bson.M{"_id": tt.id}).Decode(&job); err != nil {
+			t.Fatalf("find %s: %v", tt.id, err)
+		}
+		if job.Status != tt.wantStatus || len(job.Errors) != tt.wantErrors {
+			t.Errorf("%s: status %q with %d errors, want %q with %d", tt.id, job.Status, len(job.Errors), tt.wantStatus, tt.wantErrors)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// resetMaintenanceCache - Forget the cached maintenance state before and after a test
+func resetMaintenanceCache(t *testing.T) {
+	forget := func() {
+		maintenanceMu.Lock()
+		maintenanceCached, maintenanceLoadedAt = MaintenanceState{}, time.Time{}
+		maintenanceMu.Unlock()
+	}
+	forget()
+	t.Cleanup(forget)
+}
+
+func TestMaintenanceModeFromEnv(t *testing.T) {
+	resetMaintenanceCache(t)
+	t.Setenv("MAINTENANCE_MODE", "true")
+	t.Setenv("MAINTENANCE_MESSAGE", "Back at noon")
+
+	if state := GetMaintenanceMode(); !state.Enabled || state.Message != "Back at noon" || state.Source != "env" {
+		t.Errorf("state = %+v, want forced on by the environment", state)
+	}
+
+	t.Setenv("MAINTENANCE_MODE", "1")
+	if state := GetMaintenanceMode(); state.Enabled {
+		t.Errorf("MAINTENANCE_MODE=1 enabled maintenance; only \"true\" does")
+	}
+}
+
+func TestMaintenanceModeWithoutDatabase(t *testing.T) {
+	resetMaintenanceCache(t)
+	t.Setenv("MAINTENANCE_MODE", "")
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if state := GetMaintenanceMode(); state.Enabled {
+		t.Errorf("state = %+v, want off without a database", state)
+	}
+
+	// The last known state is kept
+	maintenanceCached = MaintenanceState{Enabled: true, Source: "settings"}
+	if state := GetMaintenanceMode(); !state.Enabled {
+		t.Error("the cached state was dropped without a database")
+	}
+}
+
+func TestSetMaintenanceMode(t *testing.T) {
+	ctx := useTestDatabase(t)
+	resetMaintenanceCache(t)
+	t.Setenv("MAINTENANCE_MODE", "")
+
+	state, err := SetMaintenanceMode(ctx, true, "Upgrading", "admin@example.com")
+	if err != nil {
+		t.Fatalf("SetMaintenanceMode: %v", err)
+	}
+	if !state.Enabled || state.UpdatedBy != "admin@example.com" || state.Source != "settings" {
+		t.Errorf("state = %+v", state)
+	}
+	if got := GetMaintenanceMode(); !got.Enabled || got.Message != "Upgrading" {
+		t.Errorf("GetMaintenanceMode = %+v, want the new state right away", got)
+	}
+
+	// Another instance turning it off is seen once the cache expires
+	GetCollection("settings").UpdateOne(ctx, bson.M{"_id": "maintenance"}, bson.M{"$set": bson.M{"enabled": false}})
+	if got := GetMaintenanceMode(); !got.Enabled {
+		t.Error("the cached state was reloaded before the TTL")
+	}
+	maintenanceLoadedAt = time.Now().Add(-maintenanceCacheTTL)
+	if got := GetMaintenanceMode(); got.Enabled || got.Source != "settings" {
+		t.Errorf("after the TTL = %+v, want the stored state", got)
+	}
+
+	if _, err := SetMaintenanceMode(context.Background(), false, "", "admin@example.com"); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if count, _ := GetCollection("settings").CountDocuments(ctx, bson.M{"_id": "maintenance"}); count != 1 {
+		t.Errorf("%d maintenance settings stored, want 1", count)
+	}
+}
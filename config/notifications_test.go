@@ -0,0 +1,172 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stubNotificationDispatcher - Install a dispatcher that reports each call on the returned channel
+func stubNotificationDispatcher(t *testing.T) <-chan primitive.ObjectID {
+	dispatched := make(chan primitive.ObjectID, 1)
+	previous := notificationDispatcher
+	SetNotificationDispatcher(func(notificationID, projectID primitive.ObjectID, notificationType, message string) {
+		dispatched <- notificationID
+	})
+	t.Cleanup(func() { notificationDispatcher = previous })
+	return dispatched
+}
+
+func TestLogNotificationDispatches(t *testing.T) {
+	ctx := useTestDatabase(t)
+	dispatched := stubNotificationDispatcher(t)
+
+	projectID := primitive.NewObjectID()
+	if err := LogNotification(projectID, NotificationTest, "hello"); err != nil {
+		t.Fatalf("LogNotification: %v", err)
+	}
+
+	select {
+	case id := <-dispatched:
+		var stored bson.M
+		if err := GetNotificationsCollection().FindOne(ctx, bson.M{"_id": id, "project_id": projectID}).Decode(&stored); err != nil {
+			t.Errorf("dispatched notification %s is not the logged one: %v", id.Hex(), err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("logged notification was not dispatched")
+	}
+
+	// Recording alone never delivers
+	if _, err := RecordNotification(projectID, NotificationTest, "quiet"); err != nil {
+		t.Fatalf("RecordNotification: %v", err)
+	}
+	select {
+	case <-dispatched:
+		t.Error("RecordNotification dispatched the notification")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLogNotificationWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+	dispatched := stubNotificationDispatcher(t)
+
+	if err := LogNotification(primitive.NewObjectID(), NotificationTest, "hello"); err == nil {
+		t.Error("expected an error without a database")
+	}
+	select {
+	case <-dispatched:
+		t.Error("an unrecorded notification was dispatched")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUpdateExpiredProjectsNotifiesClients(t *testing.T) {
+	ctx := useTestDatabase(t)
+	stubNotificationDispatcher(t)
+
+	now := time.Now()
+	lapsed, current := primitive.NewObjectID(), primitive.NewObjectID()
+	GetProjectsCollection().InsertMany(ctx, []interface{}{
+		bson.M{"_id": lapsed, "project_id": "lapsed", "status": "active", "expiry_date": now.Add(-time.Hour)},
+		bson.M{"_id": current, "project_id": "current", "status": "active", "expiry_date": now.Add(time.Hour)},
+		bson.M{"project_id": "already_expired", "status": "expired", "expiry_date": now.Add(-time.Hour)},
+	})
+
+	if err := UpdateExpiredProjects(); err != nil {
+		t.Fatalf("UpdateExpiredProjects: %v", err)
+	}
+
+	var project bson.M
+	GetProjectsCollection().FindOne(ctx, bson.M{"_id": lapsed}).Decode(&project)
+	if project["status"] != "expired" {
+		t.Errorf("lapsed project status = %v, want expired", project["status"])
+	}
+	count, _ := GetNotificationsCollection().CountDocuments(ctx, bson.M{"type": NotificationExpired})
+	notified, _ := GetNotificationsCollection().CountDocuments(ctx, bson.M{"type": NotificationExpired, "project_id": lapsed})
+	if count != 1 || notified != 1 {
+		t.Errorf("%d expiry notifications (%d for the lapsed project), want one for the newly expired project", count, notified)
+	}
+}
+
+func TestWasNotificationRecentlySent(t *testing.T) {
+	useTestDatabase(t)
+	projectID := primitive.NewObjectID()
+
+	if err := LogNotificationDetails(projectID, NotificationUsageWarning, "95% used", bson.M{"threshold": 95}); err != nil {
+		t.Fatalf("LogNotificationDetails: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		projectID     primitive.ObjectID
+		typ           string
+		discriminator []bson.M
+		want          bool
+	}{
+		{"any of the type", projectID, NotificationUsageWarning, nil, true},
+		{"same threshold", projectID, NotificationUsageWarning, []bson.M{{"threshold": 95}}, true},
+		{"another threshold", projectID, NotificationUsageWarning, []bson.M{{"threshold": 80}}, false},
+		{"discriminators combine", projectID, NotificationUsageWarning, []bson.M{{"threshold": 95}, {"channel": "sms"}}, false},
+		{"another type", projectID, NotificationMonthlyLimit, nil, false},
+		{"another project", primitive.NewObjectID(), NotificationUsageWarning, nil, false},
+	}
+	for _, tt := range tests {
+		sent, err := WasNotificationRecentlySent(tt.projectID, tt.typ, 24, tt.discriminator...)
+		if err != nil || sent != tt.want {
+			t.Errorf("%s: WasNotificationRecentlySent = %v, %v; want %v", tt.name, sent, err, tt.want)
+		}
+	}
+
+	if sent, _ := WasNotificationSentSince(projectID, NotificationUsageWarning, time.Now().Add(time.Minute), nil); sent {
+		t.Error("a notification sent before the window counted")
+	}
+}
+
+func TestLastUsageReset(t *testing.T) {
+	useTestDatabase(t)
+	projectID := primitive.NewObjectID()
+
+	if last, err := LastUsageReset(projectID); err != nil || !last.IsZero() {
+		t.Errorf("never reset: LastUsageReset = %v, %v; want zero", last, err)
+	}
+
+	RecordNotification(projectID, "usage_reset", "Usage reset")
+	time.Sleep(10 * time.Millisecond)
+	RecordNotification(projectID, NotificationRenewal, "Renewed")
+	time.Sleep(10 * time.Millisecond)
+	RecordNotification(projectID, NotificationUsageWarning, "80% used")
+
+	var renewal struct {
+		SentAt time.Time `bson:"sent_at"`
+	}
+	GetNotificationsCollection().FindOne(context.Background(), bson.M{"project_id": projectID, "type": NotificationRenewal}).Decode(&renewal)
+	if last, err := LastUsageReset(projectID); err != nil || !last.Equal(renewal.SentAt) {
+		t.Errorf("LastUsageReset = %v, %v; want the renewal at %v", last, err, renewal.SentAt)
+	}
+}
+
+func TestLastUsageResetWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if _, err := LastUsageReset(primitive.NewObjectID()); err == nil {
+		t.Error("expected an error without a database")
+	}
+}
+
+func TestWasNotificationRecentlySentWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if _, err := WasNotificationRecentlySent(primitive.NewObjectID(), NotificationTest, 24, bson.M{"threshold": 80}); err == nil {
+		t.Error("expected an error without a database")
+	}
+}
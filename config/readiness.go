@@ -0,0 +1,56 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Readiness - Whether the database has been verified and startup initialization has run.
+// The server starts listening before that, so health endpoints report not-ready until then
+// and load balancers don't route traffic early.
+var (
+	readinessMu     sync.RWMutex
+	ready           bool
+	readinessReason = "database initialization in progress"
+)
+
+// IsReady - Whether startup verification completed; reason explains a false result
+func IsReady() (bool, string) {
+	readinessMu.RLock()
+	defer readinessMu.RUnlock()
+	return ready, readinessReason
+}
+
+func setReadiness(isReady bool, reason string) {
+	readinessMu.Lock()
+	ready = isReady
+	readinessReason = reason
+	readinessMu.Unlock()
+}
+
+// completeStartup - Initialize subscription defaults, then verify the database with
+// HealthCheck (retrying with backoff) before marking the server ready
+func completeStartup() {
+	if err := InitializeSubscriptionDefaults(); err != nil {
+		log.Printf("⚠️ Warning during subscription initialization: %v", err)
+	}
+	if err := MigrateEmbeddingModels(); err != nil {
+		log.Printf("⚠️ Warning during embedding model migration: %v", err)
+	}
+
+	backoff := time.Second
+	for {
+		err := HealthCheck()
+		if err == nil {
+			break
+		}
+		log.Printf("⚠️ Database not ready, retrying in %v: %v", backoff, err)
+		setReadiness(false, "database verification failed: "+err.Error())
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 30*time.Second)
+	}
+
+	setReadiness(true, "")
+	log.Printf("✅ Server ready to accept traffic")
+}
@@ -0,0 +1,18 @@
+package config
+
+import "testing"
+
+func TestReadiness(t *testing.T) {
+	wasReady, previousReason := IsReady()
+	t.Cleanup(func() { setReadiness(wasReady, previousReason) })
+
+	setReadiness(false, "database verification failed: timeout")
+	if ready, reason := IsReady(); ready || reason != "database verification failed: timeout" {
+		t.Errorf("IsReady() = %v %q, want not ready with the failure", ready, reason)
+	}
+
+	setReadiness(true, "")
+	if ready, reason := IsReady(); !ready || reason != "" {
+		t.Errorf("IsReady() = %v %q, want ready", ready, reason)
+	}
+}
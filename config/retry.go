@@ -0,0 +1,70 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Read retry defaults (overridable via MONGO_READ_RETRIES / MONGO_RETRY_BACKOFF_MS)
+const (
+	defaultReadRetries    = 3
+	defaultRetryBackoffMS = 100
+)
+
+// IsTransientError - Report whether a Mongo error is a network blip worth retrying
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Never retry "not found" or a cancelled/expired caller context
+	if errors.Is(err, mongo.ErrNoDocuments) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorLabel("RetryableReadError") ||
+			serverErr.HasErrorLabel("TransientTransactionError")
+	}
+
+	return false
+}
+
+// RetryRead - Run a read-only operation, retrying transient errors with exponential backoff.
+// Only use this for reads: writes are not guaranteed to be idempotent and must not be blindly retried.
+func RetryRead(ctx context.Context, op func(ctx context.Context) error) error {
+	maxRetries := getEnvInt("MONGO_READ_RETRIES", defaultReadRetries)
+	if maxRetries < 1 {
+		maxRetries = 1
+	}
+	backoff := time.Duration(getEnvInt("MONGO_RETRY_BACKOFF_MS", defaultRetryBackoffMS)) * time.Millisecond
+
+	var err error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		err = op(ctx)
+		if err == nil || !IsTransientError(err) || attempt == maxRetries {
+			return err
+		}
+
+		log.Printf("⚠️ Transient MongoDB error (attempt %d/%d), retrying: %v", attempt, maxRetries, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff * time.Duration(1<<(attempt-1))):
+		}
+	}
+
+	return err
+}
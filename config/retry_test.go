@@ -0,0 +1,100 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	errNetwork   = mongo.CommandError{Code: 6, Message: "connection reset", Labels: []string{"NetworkError"}}
+	errRetryable = mongo.CommandError{Code: 91, Message: "shutdown in progress", Labels: []string{"RetryableReadError"}}
+	errDuplicate = mongo.CommandError{Code: 11000, Message: "duplicate key"}
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"network error", errNetwork, true},
+		{"wrapped network error", fmt.Errorf("find: %w", errNetwork), true},
+		{"retryable read label", errRetryable, true},
+		{"not found", mongo.ErrNoDocuments, false},
+		{"caller cancelled", context.Canceled, false},
+		{"caller deadline", context.DeadlineExceeded, false},
+		{"non-retryable server error", errDuplicate, false},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientError(tt.err); got != tt.want {
+				t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryRead(t *testing.T) {
+	t.Setenv("MONGO_READ_RETRIES", "3")
+	t.Setenv("MONGO_RETRY_BACKOFF_MS", "1")
+
+	tests := []struct {
+		name      string
+		failures  []error // returned by successive attempts, then success
+		wantErr   error
+		wantCalls int
+	}{
+		{"first try succeeds", nil, nil, 1},
+		{"transient error then success", []error{errNetwork}, nil, 2},
+		{"two transient errors then success", []error{errNetwork, errRetryable}, nil, 3},
+		{"gives up after max retries", []error{errNetwork, errNetwork, errNetwork, errNetwork}, errNetwork, 3},
+		{"not found is returned at once", []error{mongo.ErrNoDocuments}, mongo.ErrNoDocuments, 1},
+		{"permanent error is returned at once", []error{errDuplicate}, errDuplicate, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := RetryRead(context.Background(), func(ctx context.Context) error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
+				t.Errorf("RetryRead error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("op called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryReadStopsWhenContextEnds(t *testing.T) {
+	t.Setenv("MONGO_READ_RETRIES", "5")
+	t.Setenv("MONGO_RETRY_BACKOFF_MS", "1000")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := RetryRead(ctx, func(ctx context.Context) error {
+		calls++
+		return errNetwork
+	})
+	if fmt.Sprint(err) != fmt.Sprint(errNetwork) {
+		t.Errorf("RetryRead error = %v, want the last transient error", err)
+	}
+	if calls != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("kept retrying after the context ended: %d calls in %v", calls, time.Since(start))
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Idle timeout default in minutes (overridable via WIDGET_SESSION_IDLE_MINUTES)
+const defaultWidgetSessionIdleMinutes = 30
+
+// WidgetSessionIdleTimeout - How long a widget session may go without activity before it is closed
+func WidgetSessionIdleTimeout() time.Duration {
+	minutes := getEnvInt("WIDGET_SESSION_IDLE_MINUTES", defaultWidgetSessionIdleMinutes)
+	if minutes < 1 {
+		minutes = defaultWidgetSessionIdleMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// CloseIdleWidgetSessions - End active widget sessions idle beyond the timeout.
+// A timed-out session ends at its last activity, so its duration only counts time the visitor was engaged.
+func CloseIdleWidgetSessions() (int64, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cutoff := time.Now().Add(-WidgetSessionIdleTimeout())
+
+	filter := bson.M{
+		"is_active":     true,
+		"last_activity": bson.M{"$lt": cutoff},
+	}
+
+	// Pipeline update so ended_at/duration are derived from each document's own timestamps
+	update := []bson.M{
+		{"$set": bson.M{
+			"is_active":  false,
+			"end_reason": "timeout",
+			"ended_at":   "$last_activity",
+			"duration": bson.M{"$toLong": bson.M{
+				"$divide": []interface{}{
+					bson.M{"$subtract": []interface{}{"$last_activity", "$started_at"}},
+					1000,
+				},
+			}},
+		}},
+	}
+
+	result, err := GetWidgetSessionsCollection().UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to close idle widget sessions: %v", err)
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("⏱️ Closed %d idle widget sessions", result.ModifiedCount)
+	}
+
+	return result.ModifiedCount, nil
+}
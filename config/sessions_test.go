@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWidgetSessionIdleTimeout(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want time.Duration
+	}{
+		{"", defaultWidgetSessionIdleMinutes * time.Minute},
+		{"5", 5 * time.Minute},
+		{"0", defaultWidgetSessionIdleMinutes * time.Minute},
+		{"-3", defaultWidgetSessionIdleMinutes * time.Minute},
+		{"soon", defaultWidgetSessionIdleMinutes * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Setenv("WIDGET_SESSION_IDLE_MINUTES", tt.raw)
+		if got := WidgetSessionIdleTimeout(); got != tt.want {
+			t.Errorf("WidgetSessionIdleTimeout() with %q = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCloseIdleWidgetSessions(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("WIDGET_SESSION_IDLE_MINUTES", "30")
+
+	now := time.Now().Truncate(time.Millisecond)
+	started := now.Add(-2 * time.Hour)
+	lastActivity := now.Add(-time.Hour)
+	sessions := []interface{}{
+		bson.M{"session_id": "idle", "is_active": true, "started_at": started, "last_activity": lastActivity},
+		bson.M{"session_id": "busy", "is_active": true, "started_at": started, "last_activity": now.Add(-time.Minute)},
+		bson.M{"session_id": "closed", "is_active": false, "started_at": started, "last_activity": lastActivity,
+			"ended_at": lastActivity, "end_reason": "user_closed", "duration": int64(3600)},
+	}
+	if _, err := GetWidgetSessionsCollection().InsertMany(ctx, sessions); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	closed, err := CloseIdleWidgetSessions()
+	if err != nil {
+		t.Fatalf("CloseIdleWidgetSessions: %v", err)
+	}
+	if closed != 1 {
+		t.Errorf("closed %d sessions, want 1", closed)
+	}
+
+	tests := []struct {
+		sessionID    string
+		wantActive   bool
+		wantReason   string
+		wantDuration int64
+	}{
+		{"idle", false, "timeout", 3600},
+		{"busy", true, "", 0},
+		{"closed", false, "user_closed", 3600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sessionID, func(t *testing.T) {
+			var session struct {
+				IsActive  bool      `bson:"is_active"`
+				EndReason string    `bson:"end_reason"`
+				Duration  int64     `bson:"duration"`
+				EndedAt   time.Time `bson:"ended_at"`
+			}
+			if err := GetWidgetSessionsCollection().FindOne(ctx, bson.M{"session_id": tt.sessionID}).Decode(&session); err != nil {
+				t.Fatalf("find: %v", err)
+			}
+			if session.IsActive != tt.wantActive || session.EndReason != tt.wantReason || session.Duration != tt.wantDuration {
+				t.Errorf("session = %+v, want active %v, reason %q, duration %d", session, tt.wantActive, tt.wantReason, tt.wantDuration)
+			}
+			// A timed-out session ends at its last activity, not when the sweep ran
+			if tt.sessionID == "idle" && !session.EndedAt.Equal(lastActivity) {
+				t.Errorf("ended_at = %v, want the last activity %v", session.EndedAt, lastActivity)
+			}
+		})
+	}
+}
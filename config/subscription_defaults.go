@@ -0,0 +1,86 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Built-in subscription defaults (overridable via DEFAULT_SUBSCRIPTION_MONTHS / DEFAULT_MONTHLY_TOKEN_LIMIT,
+// TRIAL_DAYS / TRIAL_TOKEN_LIMIT)
+const (
+	defaultSubscriptionMonths = 12
+	defaultMonthlyTokenLimit  = 100000
+	defaultTrialDays          = 14
+	defaultTrialTokenLimit    = 10000
+
+	// Bounds for admin-supplied overrides
+	MaxSubscriptionMonths = 60
+	MaxMonthlyTokenLimit  = 100000000
+	MaxTrialDays          = 90
+)
+
+// SubscriptionDefaults - Defaults applied when a project is created or repaired by maintenance
+type SubscriptionDefaults struct {
+	Months            int
+	MonthlyTokenLimit int64
+	TrialDays         int
+	TrialTokenLimit   int64
+}
+
+var (
+	subscriptionDefaultsOnce sync.Once
+	subscriptionDefaults     SubscriptionDefaults
+)
+
+// GetSubscriptionDefaults - Read the defaults from env once
+func GetSubscriptionDefaults() SubscriptionDefaults {
+	subscriptionDefaultsOnce.Do(func() {
+		subscriptionDefaults = loadSubscriptionDefaults()
+	})
+	return subscriptionDefaults
+}
+
+// loadSubscriptionDefaults - Parse the defaults from env; out-of-range values fall back to built-ins
+func loadSubscriptionDefaults() SubscriptionDefaults {
+	months := getEnvInt("DEFAULT_SUBSCRIPTION_MONTHS", defaultSubscriptionMonths)
+	if months <= 0 || months > MaxSubscriptionMonths {
+		log.Printf("⚠️ DEFAULT_SUBSCRIPTION_MONTHS=%d out of range, using %d", months, defaultSubscriptionMonths)
+		months = defaultSubscriptionMonths
+	}
+
+	limit := getEnvInt64("DEFAULT_MONTHLY_TOKEN_LIMIT", defaultMonthlyTokenLimit)
+	if limit <= 0 || limit > MaxMonthlyTokenLimit {
+		log.Printf("⚠️ DEFAULT_MONTHLY_TOKEN_LIMIT=%d out of range, using %d", limit, defaultMonthlyTokenLimit)
+		limit = defaultMonthlyTokenLimit
+	}
+
+	trialDays := getEnvInt("TRIAL_DAYS", defaultTrialDays)
+	if trialDays <= 0 || trialDays > MaxTrialDays {
+		log.Printf("⚠️ TRIAL_DAYS=%d out of range, using %d", trialDays, defaultTrialDays)
+		trialDays = defaultTrialDays
+	}
+
+	trialLimit := getEnvInt64("TRIAL_TOKEN_LIMIT", defaultTrialTokenLimit)
+	if trialLimit <= 0 || trialLimit > limit {
+		log.Printf("⚠️ TRIAL_TOKEN_LIMIT=%d out of range, using %d", trialLimit, min(defaultTrialTokenLimit, limit))
+		trialLimit = min(defaultTrialTokenLimit, limit)
+	}
+
+	return SubscriptionDefaults{
+		Months:            months,
+		MonthlyTokenLimit: limit,
+		TrialDays:         trialDays,
+		TrialTokenLimit:   trialLimit,
+	}
+}
+
+// ExpiryFrom - Subscription end date for a subscription starting at start
+func (d SubscriptionDefaults) ExpiryFrom(start time.Time) time.Time {
+	return start.AddDate(0, d.Months, 0)
+}
+
+// TrialEndFrom - Trial end date for a trial starting at start
+func (d SubscriptionDefaults) TrialEndFrom(start time.Time) time.Time {
+	return start.AddDate(0, 0, d.TrialDays)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubSubscriptionDefaults - Make GetSubscriptionDefaults return d for the duration of a test
+func stubSubscriptionDefaults(t *testing.T, d SubscriptionDefaults) {
+	t.Helper()
+	subscriptionDefaultsOnce = sync.Once{}
+	subscriptionDefaultsOnce.Do(func() { subscriptionDefaults = d })
+	t.Cleanup(func() { subscriptionDefaultsOnce = sync.Once{} })
+}
+
+func TestLoadSubscriptionDefaults(t *testing.T) {
+	tests := []struct {
+		name, months, limit string
+		wantMonths          int
+		wantLimit           int64
+	}{
+		{"unset", "", "", defaultSubscriptionMonths, defaultMonthlyTokenLimit},
+		{"configured", "6", "250000", 6, 250000},
+		{"zero months", "0", "250000", defaultSubscriptionMonths, 250000},
+		{"too many months", "61", "", defaultSubscriptionMonths, defaultMonthlyTokenLimit},
+		{"negative limit", "3", "-1", 3, defaultMonthlyTokenLimit},
+		{"limit above the cap", "", "100000001", defaultSubscriptionMonths, defaultMonthlyTokenLimit},
+		{"not a number", "a year", "lots", defaultSubscriptionMonths, defaultMonthlyTokenLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_SUBSCRIPTION_MONTHS", tt.months)
+			t.Setenv("DEFAULT_MONTHLY_TOKEN_LIMIT", tt.limit)
+
+			got := loadSubscriptionDefaults()
+			if got.Months != tt.wantMonths || got.MonthlyTokenLimit != tt.wantLimit {
+				t.Errorf("got %d months, limit %d; want %d months, limit %d",
+					got.Months, got.MonthlyTokenLimit, tt.wantMonths, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestSubscriptionDefaultsExpiryFrom(t *testing.T) {
+	start := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+	d := SubscriptionDefaults{Months: 12}
+	if got, want := d.ExpiryFrom(start), time.Date(2027, time.January, 31, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ExpiryFrom = %v, want %v", got, want)
+	}
+}
+
+func TestLoadSubscriptionDefaultsTrial(t *testing.T) {
+	tests := []struct {
+		name, limit, days, trialLimit string
+		wantDays                      int
+		wantTrialLimit                int64
+	}{
+		{"unset", "", "", "", defaultTrialDays, defaultTrialTokenLimit},
+		{"configured", "", "30", "20000", 30, 20000},
+		{"zero days", "", "0", "", defaultTrialDays, defaultTrialTokenLimit},
+		{"too many days", "", "91", "", defaultTrialDays, defaultTrialTokenLimit},
+		{"negative trial limit", "", "", "-1", defaultTrialDays, defaultTrialTokenLimit},
+		// A trial never gets more than a paid project
+		{"trial limit above the monthly limit", "50000", "", "60000", defaultTrialDays, defaultTrialTokenLimit},
+		{"small monthly limit caps the built-in trial limit", "5000", "", "", defaultTrialDays, 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_MONTHLY_TOKEN_LIMIT", tt.limit)
+			t.Setenv("TRIAL_DAYS", tt.days)
+			t.Setenv("TRIAL_TOKEN_LIMIT", tt.trialLimit)
+
+			got := loadSubscriptionDefaults()
+			if got.TrialDays != tt.wantDays || got.TrialTokenLimit != tt.wantTrialLimit {
+				t.Errorf("got %d days, trial limit %d; want %d days, trial limit %d",
+					got.TrialDays, got.TrialTokenLimit, tt.wantDays, tt.wantTrialLimit)
+			}
+		})
+	}
+}
+
+func TestSubscriptionDefaultsTrialEndFrom(t *testing.T) {
+	start := time.Date(2026, time.February, 20, 9, 0, 0, 0, time.UTC)
+	d := SubscriptionDefaults{TrialDays: 14}
+	if got, want := d.TrialEndFrom(start), time.Date(2026, time.March, 6, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("TrialEndFrom = %v, want %v", got, want)
+	}
+}
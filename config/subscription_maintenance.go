@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaintenanceChange describes what subscription maintenance does (or would do) to one project
+type MaintenanceChange struct {
+	ProjectID     string    `json:"project_id"`
+	Name          string    `json:"name"`
+	Status        string    `json:"status,omitempty"`
+	ExpiryDate    time.Time `json:"expiry_date,omitempty"`
+	MissingFields []string  `json:"missing_fields,omitempty"`
+}
+
+// MaintenanceReport - Outcome of a subscription maintenance run
+type MaintenanceReport struct {
+	DryRun          bool                `json:"dry_run"`
+	TrialsToEnd     []MaintenanceChange `json:"trials_to_end"`
+	ToExpire        []MaintenanceChange `json:"to_expire"`
+	NeedingDefaults []MaintenanceChange `json:"needing_defaults"`
+	RanAt           time.Time           `json:"ran_at"`
+}
+
+// subscriptionDefaultFields - Fields FixProjectLimits fills in when missing
+var subscriptionDefaultFields = []string{
+	"status", "start_date", "expiry_date", "monthly_token_limit", "total_tokens_used", "ai_provider", "openai_model",
+}
+
+// RunSubscriptionMaintenanceWithOptions - Compute the projects maintenance affects and, unless
+// dryRun, apply the changes. A dry run only reads.
+func RunSubscriptionMaintenanceWithOptions(dryRun bool) (*MaintenanceReport, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := &MaintenanceReport{DryRun: dryRun, RanAt: time.Now()}
+
+	var err error
+	if report.TrialsToEnd, err = findTrialChanges(ctx, report.RanAt); err != nil {
+		return nil, fmt.Errorf("failed to find ended trials: %v", err)
+	}
+	if report.ToExpire, err = findProjectsToExpire(ctx); err != nil {
+		return nil, fmt.Errorf("failed to find expiring projects: %v", err)
+	}
+	if report.NeedingDefaults, err = findProjectsNeedingDefaults(ctx); err != nil {
+		return nil, fmt.Errorf("failed to find projects missing defaults: %v", err)
+	}
+
+	if dryRun {
+		log.Printf("🔍 Subscription maintenance dry run: %d trials to end, %d to expire, %d needing defaults",
+			len(report.TrialsToEnd), len(report.ToExpire), len(report.NeedingDefaults))
+		return report, nil
+	}
+
+	if err := RunSubscriptionMaintenance(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// findTrialChanges - Same selection as EndExpiredTrials
+func findTrialChanges(ctx context.Context, now time.Time) ([]MaintenanceChange, error) {
+	projects, err := findTrialsToEnd(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]MaintenanceChange, 0, len(projects))
+	for _, p := range projects {
+		changes = append(changes, MaintenanceChange{
+			ProjectID:  p.ProjectID,
+			Name:       p.Name,
+			Status:     p.Status,
+			ExpiryDate: p.TrialEndsAt,
+		})
+	}
+	return changes, nil
+}
+
+// findProjectsToExpire - Same selection as UpdateExpiredProjects
+func findProjectsToExpire(ctx context.Context) ([]MaintenanceChange, error) {
+	cursor, err := GetProjectsCollection().Find(ctx,
+		bson.M{
+			"expiry_date": bson.M{"$lt": time.Now()},
+			"status":      bson.M{"$ne": "expired"},
+		},
+		options.Find().SetProjection(bson.M{"project_id": 1, "name": 1, "status": 1, "expiry_date": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []struct {
+		ProjectID  string    `bson:"project_id"`
+		Name       string    `bson:"name"`
+		Status     string    `bson:"status"`
+		ExpiryDate time.Time `bson:"expiry_date"`
+	}
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	changes := make([]MaintenanceChange, 0, len(projects))
+	for _, p := range projects {
+		changes = append(changes, MaintenanceChange{
+			ProjectID:  p.ProjectID,
+			Name:       p.Name,
+			Status:     p.Status,
+			ExpiryDate: p.ExpiryDate,
+		})
+	}
+	return changes, nil
+}
+
+// findProjectsNeedingDefaults - Same selection as FixProjectLimits, with the missing fields named
+func findProjectsNeedingDefaults(ctx context.Context) ([]MaintenanceChange, error) {
+	or := []bson.M{{"status": ""}}
+	for _, field := range subscriptionDefaultFields {
+		or = append(or, bson.M{field: bson.M{"$exists": false}})
+	}
+
+	cursor, err := GetProjectsCollection().Find(ctx, bson.M{"$or": or})
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []bson.M
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	changes := make([]MaintenanceChange, 0, len(projects))
+	for _, p := range projects {
+		change := MaintenanceChange{}
+		change.ProjectID, _ = p["project_id"].(string)
+		change.Name, _ = p["name"].(string)
+		change.Status, _ = p["status"].(string)
+
+		for _, field := range subscriptionDefaultFields {
+			if _, ok := p[field]; !ok {
+				change.MissingFields = append(change.MissingFields, field)
+			}
+		}
+		if status, ok := p["status"]; ok && status == "" {
+			change.MissingFields = append(change.MissingFields, "status")
+		}
+
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
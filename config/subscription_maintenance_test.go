@@ -0,0 +1,94 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRunSubscriptionMaintenanceWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if _, err := RunSubscriptionMaintenanceWithOptions(true); err == nil {
+		t.Error("expected an error without a database")
+	}
+}
+
+func TestRunSubscriptionMaintenanceDryRun(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	now := time.Now()
+	complete := func(projectID, status string, expiry time.Time) bson.M {
+		return bson.M{
+			"project_id": projectID, "name": projectID, "status": status,
+			"start_date": now.AddDate(0, -1, 0), "expiry_date": expiry,
+			"monthly_token_limit": int64(100000), "total_tokens_used": int64(0),
+			"ai_provider": "openai", "openai_model": "gpt-4o",
+		}
+	}
+	projects := []interface{}{
+		complete("lapsed", "active", now.Add(-time.Hour)),
+		complete("current", "active", now.AddDate(0, 1, 0)),
+		complete("already_expired", "expired", now.Add(-time.Hour)),
+		bson.M{"project_id": "legacy", "name": "legacy", "status": ""},
+	}
+	if _, err := GetProjectsCollection().InsertMany(ctx, projects); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	status := func(projectID string) bson.M {
+		t.Helper()
+		var project bson.M
+		if err := GetProjectsCollection().FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project); err != nil {
+			t.Fatalf("find %s: %v", projectID, err)
+		}
+		return project
+	}
+
+	report, err := RunSubscriptionMaintenanceWithOptions(true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !report.DryRun || len(report.ToExpire) != 1 || report.ToExpire[0].ProjectID != "lapsed" {
+		t.Errorf("to_expire = %+v, want only the lapsed project", report.ToExpire)
+	}
+	if len(report.NeedingDefaults) != 1 || report.NeedingDefaults[0].ProjectID != "legacy" {
+		t.Fatalf("needing_defaults = %+v, want only the legacy project", report.NeedingDefaults)
+	}
+	missing := map[string]bool{}
+	for _, field := range report.NeedingDefaults[0].MissingFields {
+		missing[field] = true
+	}
+	for _, field := range []string{"status", "expiry_date", "monthly_token_limit"} {
+		if !missing[field] {
+			t.Errorf("missing fields %v do not name %s", report.NeedingDefaults[0].MissingFields, field)
+		}
+	}
+
+	// Nothing was written
+	if got := status("lapsed")["status"]; got != "active" {
+		t.Errorf("dry run changed the lapsed project's status to %v", got)
+	}
+	if _, ok := status("legacy")["monthly_token_limit"]; ok {
+		t.Error("dry run filled in defaults")
+	}
+
+	report, err = RunSubscriptionMaintenanceWithOptions(false)
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	if report.DryRun || len(report.ToExpire) != 1 {
+		t.Errorf("report = %+v, want the same selection as the dry run", report)
+	}
+	if got := status("lapsed")["status"]; got != "expired" {
+		t.Errorf("lapsed project status = %v, want expired", got)
+	}
+	if got := status("current")["status"]; got != "active" {
+		t.Errorf("current project status = %v, want active", got)
+	}
+	if legacy := status("legacy"); legacy["status"] != "active" || legacy["monthly_token_limit"] == nil {
+		t.Errorf("legacy project not given defaults: %v", legacy)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trialProject - Fields needed to end a trial and notify its client
+type trialProject struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	ProjectID   string             `bson:"project_id"`
+	Name        string             `bson:"name"`
+	ClientID    string             `bson:"client_id"`
+	Status      string             `bson:"status"`
+	TrialEndsAt time.Time          `bson:"trial_ends_at"`
+}
+
+// trialsToEndFilter - Active trial projects whose trial period is over at now
+func trialsToEndFilter(now time.Time) bson.M {
+	return bson.M{
+		"plan":          "trial",
+		"trial_ends_at": bson.M{"$lt": now},
+		"status":        "active",
+	}
+}
+
+// findTrialsToEnd - Trial projects EndExpiredTrials would suspend at now
+func findTrialsToEnd(ctx context.Context, now time.Time) ([]trialProject, error) {
+	cursor, err := GetProjectsCollection().Find(ctx, trialsToEndFilter(now),
+		options.Find().SetProjection(bson.M{
+			"project_id": 1, "name": 1, "client_id": 1, "status": 1, "trial_ends_at": 1,
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []trialProject
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// EndExpiredTrials - Suspend trial projects past their trial end date and notify each client to
+// convert to a paid plan. Trials are suspended rather than expired so the project, its documents
+// and its history stay intact until the client upgrades.
+func EndExpiredTrials(now time.Time) (int, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	projects, err := findTrialsToEnd(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find ended trials: %v", err)
+	}
+
+	collection := GetProjectsCollection()
+	ended := 0
+	for _, project := range projects {
+		// Re-check the trial filter so a project upgraded meanwhile is left alone
+		filter := trialsToEndFilter(now)
+		filter["_id"] = project.ID
+
+		result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+			"status":     "suspended",
+			"updated_at": now,
+		}})
+		if err != nil {
+			log.Printf("❌ Failed to end trial for %s: %v", project.ProjectID, err)
+			continue
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		ended++
+
+		LogNotification(project.ID, "trial_ended", fmt.Sprintf(
+			"The free trial for %s ended on %s. Upgrade to a paid plan to reactivate your chatbot.",
+			project.Name, project.TrialEndsAt.Format("2006-01-02")))
+		log.Printf("⏳ Trial ended for project %s (%s), client %s notified", project.ProjectID, project.Name, project.ClientID)
+	}
+
+	log.Printf("✅ Suspended %d projects with ended trials", ended)
+	return ended, nil
+}
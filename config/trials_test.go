@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEndExpiredTrials(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	now := time.Now()
+	ended := now.Add(-time.Hour)
+	running := now.Add(time.Hour)
+	projects := []interface{}{
+		bson.M{"project_id": "ended", "name": "Ended", "plan": "trial", "status": "active", "trial_ends_at": ended},
+		bson.M{"project_id": "running", "name": "Running", "plan": "trial", "status": "active", "trial_ends_at": running},
+		bson.M{"project_id": "converted", "name": "Converted", "plan": "paid", "status": "active", "trial_ends_at": ended},
+		bson.M{"project_id": "already_expired", "name": "Expired", "plan": "trial", "status": "expired", "trial_ends_at": ended},
+	}
+	if _, err := GetProjectsCollection().InsertMany(ctx, projects); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	count, err := EndExpiredTrials(now)
+	if err != nil {
+		t.Fatalf("EndExpiredTrials: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("ended %d trials, want 1", count)
+	}
+
+	tests := []struct {
+		projectID, wantStatus string
+	}{
+		{"ended", "suspended"},
+		{"running", "active"},
+		{"converted", "active"},
+		{"already_expired", "expired"},
+	}
+	for _, tt := range tests {
+		var project struct {
+			Status string `bson:"status"`
+		}
+		if err := GetProjectsCollection().FindOne(ctx, bson.M{"project_id": tt.projectID}).Decode(&project); err != nil {
+			t.Fatalf("find %s: %v", tt.projectID, err)
+		}
+		if project.Status != tt.wantStatus {
+			t.Errorf("%s status = %q, want %q", tt.projectID, project.Status, tt.wantStatus)
+		}
+	}
+
+	if n, err := GetNotificationsCollection().CountDocuments(ctx, bson.M{"type": "trial_ended"}); err != nil || n != 1 {
+		t.Errorf("trial_ended notifications = %d (%v), want one for the ended trial", n, err)
+	}
+
+	// A second run finds nothing left to end
+	if count, err := EndExpiredTrials(now); err != nil || count != 0 {
+		t.Errorf("second run ended %d trials (%v), want 0", count, err)
+	}
+}
+
+func TestEndExpiredTrialsWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if _, err := EndExpiredTrials(time.Now()); err == nil {
+		t.Error("expected an error without a database")
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Usage anomaly detection defaults (USAGE_ANOMALY_MULTIPLIER, USAGE_ANOMALY_MIN_TOKENS,
+// USAGE_ANOMALY_BASELINE_DAYS). The minimum keeps small projects from alerting on noise.
+const (
+	defaultAnomalyMultiplier   = 5
+	defaultAnomalyMinTokens    = 10000
+	defaultAnomalyBaselineDays = 7
+)
+
+// UsageAnomaly - A project whose last 24 hours of usage exceeded its trailing daily average
+// by the configured multiple
+type UsageAnomaly struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	ProjectID     string             `bson:"project_id" json:"project_id"`
+	Name          string             `bson:"name" json:"name"`
+	Tokens        int64              `bson:"tokens" json:"tokens"`                 // Used in [window_start, window_end)
+	BaselineDaily float64            `bson:"baseline_daily" json:"baseline_daily"` // Average per day over the baseline period
+	Ratio         float64            `bson:"ratio" json:"ratio"`
+	Multiplier    int                `bson:"multiplier" json:"multiplier"`
+	WindowStart   time.Time          `bson:"window_start" json:"window_start"`
+	WindowEnd     time.Time          `bson:"window_end" json:"window_end"`
+	BaselineDays  int                `bson:"baseline_days" json:"baseline_days"`
+	DetectedAt    time.Time          `bson:"detected_at" json:"detected_at"`
+}
+
+// GetUsageAnomaliesCollection - Detected usage anomalies
+func GetUsageAnomaliesCollection() *mongo.Collection {
+	return GetCollection("usage_anomalies")
+}
+
+// DetectUsageAnomalies - Compare every project's usage over the 24 hours before now with its
+// daily average over the preceding baseline days. Each project's baseline is stored in
+// usage_baselines; spikes are stored in usage_anomalies and raise a usage_anomaly notification
+// (at most one per project per day). Projects without baseline usage are skipped.
+func DetectUsageAnomalies(now time.Time) ([]UsageAnomaly, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	multiplier := getEnvInt("USAGE_ANOMALY_MULTIPLIER", defaultAnomalyMultiplier)
+	minTokens := getEnvInt64("USAGE_ANOMALY_MIN_TOKENS", defaultAnomalyMinTokens)
+	baselineDays := getEnvInt("USAGE_ANOMALY_BASELINE_DAYS", defaultAnomalyBaselineDays)
+	if multiplier < 2 {
+		multiplier = defaultAnomalyMultiplier
+	}
+	if baselineDays < 1 {
+		baselineDays = defaultAnomalyBaselineDays
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	windowStart := now.Add(-24 * time.Hour)
+	baselineStart := windowStart.AddDate(0, 0, -baselineDays)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": baselineStart, "$lt": now}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": "$project_id",
+			"recent": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$timestamp", windowStart}}, "$total_tokens", 0,
+			}}},
+			"baseline": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{"$timestamp", windowStart}}, "$total_tokens", 0,
+			}}},
+		}}},
+	}
+	cursor, err := GetOpenAIUsageLogsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %v", err)
+	}
+	var totals []struct {
+		ProjectID string `bson:"_id"`
+		Recent    int64  `bson:"recent"`
+		Baseline  int64  `bson:"baseline"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, fmt.Errorf("failed to read usage totals: %v", err)
+	}
+
+	var anomalies []UsageAnomaly
+	for _, t := range totals {
+		average := float64(t.Baseline) / float64(baselineDays)
+
+		_, err := GetCollection("usage_baselines").UpdateOne(ctx,
+			bson.M{"project_id": t.ProjectID},
+			bson.M{"$set": bson.M{
+				"daily_average": average,
+				"baseline_days": baselineDays,
+				"last_24h":      t.Recent,
+				"computed_at":   now,
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("⚠️ Failed to store usage baseline for %s: %v", t.ProjectID, err)
+		}
+
+		if average <= 0 || t.Recent < minTokens || float64(t.Recent) < average*float64(multiplier) {
+			continue
+		}
+
+		var project struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Name string             `bson:"name"`
+		}
+		if err := GetProjectsCollection().FindOne(ctx, bson.M{"project_id": t.ProjectID}).Decode(&project); err != nil {
+			log.Printf("⚠️ Usage anomaly for unknown project %s: %v", t.ProjectID, err)
+			continue
+		}
+
+		anomaly := UsageAnomaly{
+			ID:            primitive.NewObjectID(),
+			ProjectID:     t.ProjectID,
+			Name:          project.Name,
+			Tokens:        t.Recent,
+			BaselineDaily: average,
+			Ratio:         float64(t.Recent) / average,
+			Multiplier:    multiplier,
+			WindowStart:   windowStart,
+			WindowEnd:     now,
+			BaselineDays:  baselineDays,
+			DetectedAt:    time.Now(),
+		}
+		if _, err := GetUsageAnomaliesCollection().InsertOne(ctx, anomaly); err != nil {
+			log.Printf("⚠️ Failed to store usage anomaly for %s: %v", t.ProjectID, err)
+		}
+		anomalies = append(anomalies, anomaly)
+
+		if sent, _ := WasNotificationRecentlySent(project.ID, NotificationUsageAnomaly, 20); sent {
+			continue
+		}
+		LogNotificationDetails(project.ID, NotificationUsageAnomaly, fmt.Sprintf(
+			"Unusual usage on %s: %d tokens in the last 24 hours, %.1fx the %d-day daily average of %.0f",
+			project.Name, t.Recent, anomaly.Ratio, baselineDays, average),
+			bson.M{"anomaly_id": anomaly.ID})
+	}
+
+	log.Printf("📈 Usage anomaly check: %d projects, %d anomalies", len(totals), len(anomalies))
+	return anomalies, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDetectUsageAnomalies(t *testing.T) {
+	ctx := useTestDatabase(t)
+	stubNotificationDispatcher(t)
+	t.Setenv("USAGE_ANOMALY_MULTIPLIER", "1") // below 2, so the default of 5 applies
+	t.Setenv("USAGE_ANOMALY_MIN_TOKENS", "10000")
+	t.Setenv("USAGE_ANOMALY_BASELINE_DAYS", "7")
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	spikyID := primitive.NewObjectID()
+	GetProjectsCollection().InsertMany(ctx, []interface{}{
+		bson.M{"_id": spikyID, "project_id": "spiky", "name": "Spiky"},
+		bson.M{"_id": primitive.NewObjectID(), "project_id": "steady", "name": "Steady"},
+		bson.M{"_id": primitive.NewObjectID(), "project_id": "small", "name": "Small"},
+		bson.M{"_id": primitive.NewObjectID(), "project_id": "new", "name": "New"},
+	})
+
+	var logs []interface{}
+	usage := func(projectID string, tokens int, at time.Time) {
+		logs = append(logs, bson.M{"project_id": projectID, "total_tokens": tokens, "timestamp": at})
+	}
+	for day := 2; day <= 8; day++ {
+		at := now.AddDate(0, 0, -day)
+		usage("spiky", 2000, at)
+		usage("steady", 2000, at)
+	}
+	usage("small", 100, now.AddDate(0, 0, -3))
+	usage("spiky", 900000, now.AddDate(0, 0, -30)) // before the baseline period
+
+	usage("spiky", 20000, now.Add(-time.Hour)) // 10x its 2000/day average
+	usage("steady", 5000, now.Add(-time.Hour))
+	usage("small", 5000, now.Add(-time.Hour)) // a spike, but under the minimum
+	usage("new", 50000, now.Add(-time.Hour))  // no baseline to compare with
+	usage("spiky", 90000, now.Add(time.Hour)) // after now
+	GetOpenAIUsageLogsCollection().InsertMany(ctx, logs)
+
+	anomalies, err := DetectUsageAnomalies(now)
+	if err != nil {
+		t.Fatalf("DetectUsageAnomalies: %v", err)
+	}
+	if len(anomalies) != 1 {
+		t.Fatalf("anomalies = %+v, want only spiky", anomalies)
+	}
+	a := anomalies[0]
+	if a.ProjectID != "spiky" || a.Name != "Spiky" || a.Tokens != 20000 || a.BaselineDaily != 2000 ||
+		a.Ratio != 10 || a.Multiplier != 5 || a.BaselineDays != 7 ||
+		!a.WindowStart.Equal(now.Add(-24*time.Hour)) || !a.WindowEnd.Equal(now) {
+		t.Errorf("anomaly = %+v", a)
+	}
+	if n, _ := GetUsageAnomaliesCollection().CountDocuments(ctx, bson.M{"project_id": "spiky"}); n != 1 {
+		t.Errorf("stored anomalies = %d, want 1", n)
+	}
+
+	var baseline struct {
+		DailyAverage float64 `bson:"daily_average"`
+		Last24h      int64   `bson:"last_24h"`
+	}
+	if err := GetCollection("usage_baselines").FindOne(ctx, bson.M{"project_id": "steady"}).Decode(&baseline); err != nil ||
+		baseline.DailyAverage != 2000 || baseline.Last24h != 5000 {
+		t.Errorf("steady baseline = %+v, %v", baseline, err)
+	}
+	if n, _ := GetCollection("usage_baselines").CountDocuments(ctx, bson.M{}); n != 4 {
+		t.Errorf("baselines = %d, want one per project with usage", n)
+	}
+
+	// A second run the same day records the anomaly again but does not re-notify
+	if _, err := DetectUsageAnomalies(now); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if n, _ := GetCollection("usage_baselines").CountDocuments(ctx, bson.M{}); n != 4 {
+		t.Errorf("baselines after rerun = %d, want them updated in place", n)
+	}
+	if n, _ := GetNotificationsCollection().CountDocuments(ctx, bson.M{"project_id": spikyID, "type": NotificationUsageAnomaly}); n != 1 {
+		t.Errorf("usage_anomaly notifications = %d, want 1", n)
+	}
+}
+
+func TestDetectUsageAnomaliesWithoutDatabase(t *testing.T) {
+	previous := DB
+	DB = nil
+	t.Cleanup(func() { DB = previous })
+
+	if _, err := DetectUsageAnomalies(time.Now()); err == nil {
+		t.Error("expected an error without a database")
+	}
+}
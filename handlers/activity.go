@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// maxActivityNotifications - Most recent notifications merged into a project's timeline
+const maxActivityNotifications = 1000
+
+// ActivityEvent - One entry of a project's activity timeline
+type ActivityEvent struct {
+	Type      string                 `json:"type"`    // category, e.g. renewal, suspension, usage
+	Source    string                 `json:"source"`  // project, notification, document, usage
+	Subtype   string                 `json:"subtype"` // notification type or milestone name
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// activityTypes - Timeline category of each notification type; unknown types are "notification"
+var activityTypes = map[string]string{
+	"renewal":           "renewal",
+	"suspension":        "suspension",
+	"overage_suspended": "suspension",
+	"trial_ended":       "suspension",
+	"reactivation":      "status_change",
+	"status_change":     "status_change",
+	"deletion":          "status_change",
+	"chat_paused":       "status_change",
+	"chat_resumed":      "status_change",
+	"visitor_erased":    "privacy",
+	"limit_update":      "limit_update",
+	"usage_warning":     "usage",
+	"monthly_limit":     "usage",
+	"usage_adjusted":    "usage",
+	"usage_reset":       "usage",
+	"usage_reset_all":   "usage",
+	"abuse_detected":    "usage",
+	"usage_anomaly":     "usage",
+}
+
+// GetProjectActivity - GET /api/admin/projects/:id/activity?page=1&limit=50&type=renewal
+// Newest-first timeline merging the project's creation, document uploads, logged notifications
+// (renewals, suspensions, status and limit changes, usage warnings) and usage milestones
+// (first chat message, first overage).
+func GetProjectActivity(c *gin.Context) {
+	page, limit := parsePagination(c, 50)
+	eventType := c.Query("type")
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	events, err := projectActivity(ctx, project)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load project activity")
+		return
+	}
+
+	if eventType != "" {
+		filtered := []ActivityEvent{}
+		for _, event := range events {
+			if event.Type == eventType {
+				filtered = append(filtered, event)
+			}
+		}
+		events = filtered
+	}
+
+	total := int64(len(events))
+	start := min((page-1)*limit, len(events))
+	end := min(start+limit, len(events))
+	totalPages := pageCount(total, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"events":     events[start:end],
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  totalPages,
+			"total_count":  total,
+			"limit":        limit,
+			"has_next":     page < totalPages,
+			"has_prev":     page > 1,
+		},
+		"filters": gin.H{
+			"type": eventType,
+		},
+	})
+}
+
+// projectActivity - All timeline events of a project, newest first
+func projectActivity(ctx context.Context, project *models.Project) ([]ActivityEvent, error) {
+	events := []ActivityEvent{{
+		Type:      "creation",
+		Source:    "project",
+		Subtype:   "created",
+		Message:   fmt.Sprintf("Project %s created", project.Name),
+		Details:   map[string]interface{}{"plan": project.Plan, "monthly_token_limit": project.MonthlyTokenLimit},
+		Timestamp: project.CreatedAt,
+	}}
+
+	for _, file := range project.PDFFiles {
+		events = append(events, ActivityEvent{
+			Type:      "document",
+			Source:    "document",
+			Subtype:   "uploaded",
+			Message:   fmt.Sprintf("Document %s uploaded", file.FileName),
+			Details:   map[string]interface{}{"document_id": file.ID, "file_size": file.FileSize},
+			Timestamp: file.UploadedAt,
+		})
+	}
+
+	notifications, err := activityNotifications(ctx, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, notifications...)
+	events = append(events, usageMilestones(ctx, project.ProjectID)...)
+
+	// Stable, so events sharing a timestamp keep their source order
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.After(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// activityNotifications - The project's logged notifications as timeline events
+func activityNotifications(ctx context.Context, projectID primitive.ObjectID) ([]ActivityEvent, error) {
+	opts := options.Find().
+		SetSort(bson.M{"sent_at": -1}).
+		SetLimit(maxActivityNotifications)
+
+	cursor, err := config.GetNotificationsCollection().Find(ctx, bson.M{"project_id": projectID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var notifications []struct {
+		Type    string    `bson:"type"`
+		Message string    `bson:"message"`
+		SentAt  time.Time `bson:"sent_at"`
+	}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+
+	events := make([]ActivityEvent, 0, len(notifications))
+	for _, n := range notifications {
+		eventType, ok := activityTypes[n.Type]
+		if !ok {
+			eventType = "notification"
+		}
+		events = append(events, ActivityEvent{
+			Type:      eventType,
+			Source:    "notification",
+			Subtype:   n.Type,
+			Message:   n.Message,
+			Timestamp: n.SentAt,
+		})
+	}
+	return events, nil
+}
+
+// usageMilestones - First chat message and first billed overage; missing ones are skipped
+func usageMilestones(ctx context.Context, projectID string) []ActivityEvent {
+	var events []ActivityEvent
+	first := options.FindOne().SetSort(bson.M{"created_at": 1})
+
+	var message models.ChatMessage
+	if err := config.GetChatMessagesCollection().FindOne(ctx, bson.M{"project_id": projectID}, first).Decode(&message); err == nil {
+		events = append(events, ActivityEvent{
+			Type:      "usage",
+			Source:    "usage",
+			Subtype:   "first_message",
+			Message:   "First chat message received",
+			Details:   map[string]interface{}{"session_id": message.SessionID},
+			Timestamp: message.CreatedAt,
+		})
+	}
+
+	var overage models.OverageRecord
+	if err := config.GetOverageRecordsCollection().FindOne(ctx, bson.M{"project_id": projectID}, first).Decode(&overage); err == nil {
+		events = append(events, ActivityEvent{
+			Type:      "usage",
+			Source:    "usage",
+			Subtype:   "first_overage",
+			Message:   "Token usage first went past the monthly limit",
+			Details:   map[string]interface{}{"token_limit": overage.TokenLimit, "total_tokens": overage.TotalTokensAfter},
+			Timestamp: overage.CreatedAt,
+		})
+	}
+	return events
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestGetProjectActivity(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	start := time.Now().Add(-10 * time.Hour).Truncate(time.Millisecond)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	project := models.Project{
+		ID: primitive.NewObjectID(), ProjectID: "proj_activity", Name: "Activity", Plan: models.PlanPaid,
+		MonthlyTokenLimit: 1000, CreatedAt: at(0),
+		PDFFiles: []models.PDFFile{{ID: "doc_1", FileName: "handbook.pdf", FileSize: 2048, UploadedAt: at(1)}},
+	}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	notifications := []interface{}{
+		bson.M{"project_id": project.ID, "type": "usage_warning", "message": "80% used", "sent_at": at(3)},
+		bson.M{"project_id": project.ID, "type": "renewal", "message": "Renewed", "sent_at": at(5)},
+		bson.M{"project_id": project.ID, "type": "custom_alert", "message": "Custom", "sent_at": at(6)},
+		bson.M{"project_id": primitive.NewObjectID(), "type": "renewal", "message": "Other project", "sent_at": at(7)},
+	}
+	if _, err := config.GetNotificationsCollection().InsertMany(ctx, notifications); err != nil {
+		t.Fatalf("insert notifications: %v", err)
+	}
+	config.GetChatMessagesCollection().InsertMany(ctx, []interface{}{
+		models.ChatMessage{ProjectID: project.ProjectID, SessionID: "sess_first", CreatedAt: at(2)},
+		models.ChatMessage{ProjectID: project.ProjectID, SessionID: "sess_later", CreatedAt: at(8)},
+	})
+	config.GetOverageRecordsCollection().InsertOne(ctx, models.OverageRecord{
+		ID: primitive.NewObjectID(), ProjectID: project.ProjectID, TokenLimit: 1000, TotalTokensAfter: 1200, CreatedAt: at(4),
+	})
+
+	r := gin.New()
+	r.GET("/projects/:id/activity", GetProjectActivity)
+
+	var resp struct {
+		Events     []ActivityEvent        `json:"events"`
+		Pagination map[string]interface{} `json:"pagination"`
+	}
+	get := func(path string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		resp.Events = nil
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code
+	}
+
+	if code := get("/projects/" + project.ID.Hex() + "/activity"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	wantSubtypes := []string{"custom_alert", "renewal", "first_overage", "usage_warning", "first_message", "uploaded", "created"}
+	if len(resp.Events) != len(wantSubtypes) {
+		t.Fatalf("got %d events %+v, want %d", len(resp.Events), resp.Events, len(wantSubtypes))
+	}
+	for i, want := range wantSubtypes {
+		if resp.Events[i].Subtype != want {
+			t.Errorf("event %d = %s, want %s (newest first)", i, resp.Events[i].Subtype, want)
+		}
+	}
+	if resp.Events[0].Type != "notification" || resp.Events[1].Type != "renewal" {
+		t.Errorf("types = %s, %s; want unknown notifications grouped as notification", resp.Events[0].Type, resp.Events[1].Type)
+	}
+	if resp.Events[4].Details["session_id"] != "sess_first" {
+		t.Errorf("first_message details = %v, want the earliest session", resp.Events[4].Details)
+	}
+
+	if get("/projects/proj_activity/activity?type=usage&limit=2"); len(resp.Events) != 2 || resp.Pagination["total_count"] != 3.0 || resp.Pagination["has_next"] != true {
+		t.Errorf("usage page 1 = %+v %v, want 2 of 3 usage events", resp.Events, resp.Pagination)
+	}
+	if get("/projects/proj_activity/activity?type=usage&limit=2&page=5"); len(resp.Events) != 0 {
+		t.Errorf("page past the end = %+v, want no events", resp.Events)
+	}
+
+	if code := get("/projects/proj_missing/activity"); code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", code)
+	}
+}
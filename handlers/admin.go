@@ -3,340 +3,314 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"strings"
-	"net/http"
-	"strconv"
-	"time"
-	"os"
 	"github.com/gin-gonic/gin"
+	"github.com/ledongthuc/pdf"
+	"github.com/sashabaranov/go-openai"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
-	"github.com/ledongthuc/pdf"
 	"jevi-chat/config"
 	"jevi-chat/models"
-	"github.com/sashabaranov/go-openai"
+	"jevi-chat/utils"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // AdminDashboard - Enhanced admin dashboard with comprehensive statistics
 // AdminDashboard - Get dashboard statistics with project counts
 func AdminDashboard(c *gin.Context) {
-    userID := c.GetString("user_id")
-    userRole := c.GetString("user_role")
-    
-    if userID == "" || userRole != "admin" {
-        c.JSON(http.StatusUnauthorized, gin.H{
-            "error": "Admin authentication required",
-        })
-        return
-    }
-
-    // Get project statistics
-    projectStats, err := getProjectStatistics()
-    if err != nil {
-        log.Printf("❌ Failed to get project statistics: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "Failed to fetch dashboard data",
-        })
-        return
-    }
-
-    // Get recent projects
-    recentProjects, err := getRecentProjects(5) // Get last 5 projects
-    if err != nil {
-        log.Printf("❌ Failed to get recent projects: %v", err)
-        recentProjects = []models.Project{} // Empty array as fallback
-    }
-
-    // Calculate additional metrics
-    totalRevenue := calculateTotalRevenue()
-    apiCallsToday := calculateAPICallsToday()
-    tokensUsedToday := calculateTokensUsedToday()
-
-    c.JSON(http.StatusOK, gin.H{
-        "stats": gin.H{
-            "totalProjects":    projectStats.TotalProjects,
-            "activeProjects":   projectStats.ActiveProjects,
-            "suspendedProjects": projectStats.SuspendedProjects,
-            "expiredProjects":  projectStats.ExpiredProjects,
-            "monthlyRevenue":   totalRevenue,
-            "apiCalls":         apiCallsToday,
-            "tokensUsed":       tokensUsedToday,
-        },
-        "projects": recentProjects,
-        "message": "Dashboard data fetched successfully",
-    })
+	userID := c.GetString("user_id")
+	userRole := c.GetString("user_role")
+
+	if userID == "" || userRole != "admin" {
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Admin authentication required")
+		return
+	}
+
+	// Get project statistics
+	projectStats, err := getProjectStatistics()
+	if err != nil {
+		log.Printf("❌ Failed to get project statistics: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch dashboard data")
+		return
+	}
+
+	// Get recent projects
+	recentProjects, err := getRecentProjects(5) // Get last 5 projects
+	if err != nil {
+		log.Printf("❌ Failed to get recent projects: %v", err)
+		recentProjects = []models.Project{} // Empty array as fallback
+	}
+
+	// Calculate additional metrics
+	totalRevenue := calculateTotalRevenue()
+	apiCallsToday := calculateAPICallsToday()
+	tokensUsedToday := calculateTokensUsedToday()
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats": gin.H{
+			"totalProjects":     projectStats.TotalProjects,
+			"activeProjects":    projectStats.ActiveProjects,
+			"suspendedProjects": projectStats.SuspendedProjects,
+			"expiredProjects":   projectStats.ExpiredProjects,
+			"monthlyRevenue":    totalRevenue,
+			"apiCalls":          apiCallsToday,
+			"tokensUsed":        tokensUsedToday,
+		},
+		"projects": recentProjects,
+		"message":  "Dashboard data fetched successfully",
+	})
 }
 
 // ProjectStatistics - Structure for project statistics
 type ProjectStatistics struct {
-    TotalProjects     int64 `json:"total_projects"`
-    ActiveProjects    int64 `json:"active_projects"`
-    SuspendedProjects int64 `json:"suspended_projects"`
-    ExpiredProjects   int64 `json:"expired_projects"`
+	TotalProjects     int64 `json:"total_projects"`
+	ActiveProjects    int64 `json:"active_projects"`
+	SuspendedProjects int64 `json:"suspended_projects"`
+	ExpiredProjects   int64 `json:"expired_projects"`
 }
 
 // getProjectStatistics - Get comprehensive project statistics
 func getProjectStatistics() (*ProjectStatistics, error) {
-    collection := config.GetProjectsCollection()
-    ctx := context.Background()
-
-    // Count total projects (excluding deleted)
-    totalProjects, err := collection.CountDocuments(ctx, bson.M{
-        "status": bson.M{"$ne": "deleted"},
-    })
-    if err != nil {
-        return nil, err
-    }
-
-    // Count active projects
-    activeProjects, err := collection.CountDocuments(ctx, bson.M{
-        "status": "active",
-        "is_active": true,
-    })
-    if err != nil {
-        return nil, err
-    }
-
-    // Count suspended projects
-    suspendedProjects, err := collection.CountDocuments(ctx, bson.M{
-        "status": "suspended",
-    })
-    if err != nil {
-        return nil, err
-    }
-
-    // Count expired projects
-    expiredProjects, err := collection.CountDocuments(ctx, bson.M{
-        "expiry_date": bson.M{"$lt": time.Now()},
-        "status": bson.M{"$ne": "deleted"},
-    })
-    if err != nil {
-        return nil, err
-    }
-
-    return &ProjectStatistics{
-        TotalProjects:     totalProjects,
-        ActiveProjects:    activeProjects,
-        SuspendedProjects: suspendedProjects,
-        ExpiredProjects:   expiredProjects,
-    }, nil
+	collection := config.GetProjectsCollection()
+	ctx := context.Background()
+
+	// Count total projects (excluding deleted)
+	totalProjects, err := collection.CountDocuments(ctx, bson.M{
+		"status": bson.M{"$ne": "deleted"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Count active projects
+	activeProjects, err := collection.CountDocuments(ctx, bson.M{
+		"status":    "active",
+		"is_active": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Count suspended projects
+	suspendedProjects, err := collection.CountDocuments(ctx, bson.M{
+		"status": "suspended",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Count expired projects
+	expiredProjects, err := collection.CountDocuments(ctx, bson.M{
+		"expiry_date": bson.M{"$lt": time.Now()},
+		"status":      bson.M{"$ne": "deleted"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProjectStatistics{
+		TotalProjects:     totalProjects,
+		ActiveProjects:    activeProjects,
+		SuspendedProjects: suspendedProjects,
+		ExpiredProjects:   expiredProjects,
+	}, nil
 }
 
 // getRecentProjects - Get recent projects for dashboard display
 func getRecentProjects(limit int) ([]models.Project, error) {
-    collection := config.GetProjectsCollection()
-    ctx := context.Background()
-
-    // Find recent projects, sorted by creation date
-    cursor, err := collection.Find(ctx, bson.M{
-        "status": bson.M{"$ne": "deleted"},
-    }, &options.FindOptions{
-        Sort:  bson.M{"created_at": -1},
-        Limit: &[]int64{int64(limit)}[0],
-    })
-    if err != nil {
-        return nil, err
-    }
-    defer cursor.Close(ctx)
+	collection := config.GetProjectsCollection()
+	ctx := context.Background()
+
+	// Find recent projects, sorted by creation date
+	cursor, err := collection.Find(ctx, bson.M{
+		"status": bson.M{"$ne": "deleted"},
+	}, &options.FindOptions{
+		Sort:  bson.M{"created_at": -1},
+		Limit: &[]int64{int64(limit)}[0],
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 
-    var projects []models.Project
-    if err := cursor.All(ctx, &projects); err != nil {
-        return nil, err
-    }
+	var projects []models.Project
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
 
-    return projects, nil
+	return projects, nil
 }
 
 // calculateTotalRevenue - Calculate total monthly revenue
 func calculateTotalRevenue() float64 {
-    // This is a placeholder - implement based on your pricing model
-    // You might calculate based on active subscriptions, token usage, etc.
-    collection := config.GetProjectsCollection()
-    ctx := context.Background()
+	// This is a placeholder - implement based on your pricing model
+	// You might calculate based on active subscriptions, token usage, etc.
+	collection := config.GetProjectsCollection()
+	ctx := context.Background()
 
-    // Example: Count active projects and multiply by subscription price
-    activeCount, err := collection.CountDocuments(ctx, bson.M{
-        "status": "active",
-        "is_active": true,
-    })
-    if err != nil {
-        return 0
-    }
+	// Example: Count active projects and multiply by subscription price
+	activeCount, err := collection.CountDocuments(ctx, bson.M{
+		"status":    "active",
+		"is_active": true,
+	})
+	if err != nil {
+		return 0
+	}
 
-    // Assuming ₹500 per project per month
-    return float64(activeCount) * 500
+	// Assuming ₹500 per project per month
+	return float64(activeCount) * 500
 }
 
 // calculateAPICallsToday - Calculate API calls for today
 func calculateAPICallsToday() int64 {
-    collection := config.GetCollection("chat_messages")
-    ctx := context.Background()
-
-    // Get today's date range
-    today := time.Now()
-    startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
-    endOfDay := startOfDay.Add(24 * time.Hour)
-
-    count, err := collection.CountDocuments(ctx, bson.M{
-        "created_at": bson.M{
-            "$gte": startOfDay,
-            "$lt":  endOfDay,
-        },
-    })
-    if err != nil {
-        return 0
-    }
+	collection := config.GetCollection("chat_messages")
+	ctx := context.Background()
+
+	// Get today's date range
+	today := time.Now()
+	startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	endOfDay := startOfDay.Add(24 * time.Hour)
+
+	count, err := collection.CountDocuments(ctx, bson.M{
+		"created_at": bson.M{
+			"$gte": startOfDay,
+			"$lt":  endOfDay,
+		},
+	})
+	if err != nil {
+		return 0
+	}
 
-    return count
+	return count
 }
 
 // calculateTokensUsedToday - Calculate tokens used today
 func calculateTokensUsedToday() int64 {
-    collection := config.GetCollection("chat_messages")
-    ctx := context.Background()
-
-    // Get today's date range
-    today := time.Now()
-    startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
-    endOfDay := startOfDay.Add(24 * time.Hour)
-
-    // Aggregate tokens used today
-    pipeline := []bson.M{
-        {
-            "$match": bson.M{
-                "created_at": bson.M{
-                    "$gte": startOfDay,
-                    "$lt":  endOfDay,
-                },
-            },
-        },
-        {
-            "$group": bson.M{
-                "_id": nil,
-                "total_tokens": bson.M{"$sum": "$tokens_used"},
-            },
-        },
-    }
-
-    cursor, err := collection.Aggregate(ctx, pipeline)
-    if err != nil {
-        return 0
-    }
-    defer cursor.Close(ctx)
-
-    var result []bson.M
-    if err := cursor.All(ctx, &result); err != nil || len(result) == 0 {
-        return 0
-    }
-
-    if totalTokens, ok := result[0]["total_tokens"].(int64); ok {
-        return totalTokens
-    }
-
-    return 0
-}
-
-// GetProjectsDashboard - Get all projects with enhanced filtering and pagination
+	collection := config.GetCollection("chat_messages")
+	ctx := context.Background()
+
+	// Get today's date range
+	today := time.Now()
+	startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	endOfDay := startOfDay.Add(24 * time.Hour)
+
+	// Aggregate tokens used today
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"created_at": bson.M{
+					"$gte": startOfDay,
+					"$lt":  endOfDay,
+				},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":          nil,
+				"total_tokens": bson.M{"$sum": "$tokens_used"},
+			},
+		},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0
+	}
+	defer cursor.Close(ctx)
+
+	var result []bson.M
+	if err := cursor.All(ctx, &result); err != nil || len(result) == 0 {
+		return 0
+	}
+
+	if totalTokens, ok := result[0]["total_tokens"].(int64); ok {
+		return totalTokens
+	}
+
+	return 0
+}
+
+// projectSortFields - Fields GetProjectsDashboard may sort by
+var projectSortFields = map[string]bool{
+	"created_at":        true,
+	"updated_at":        true,
+	"name":              true,
+	"status":            true,
+	"expiry_date":       true,
+	"total_tokens_used": true,
+}
+
+// GetProjectsDashboard - GET /api/admin/projects
+// The admin project list: paginated (page, limit), filtered (status, search, created_by) and
+// sorted (sort, order), with computed usage fields on each project.
 func GetProjectsDashboard(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	// Parse query parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	page, limit := parsePagination(c, 20)
 	status := c.Query("status")
 	search := c.Query("search")
+	createdBy := c.Query("created_by")
 	sortBy := c.DefaultQuery("sort", "created_at")
 	sortOrder := c.DefaultQuery("order", "desc")
 
-	// Build filter
-	filter := bson.M{}
-	if status != "" {
-		filter["status"] = status
-	}
-	if search != "" {
-		filter["$or"] = []bson.M{
-			{"name": bson.M{"$regex": search, "$options": "i"}},
-			{"description": bson.M{"$regex": search, "$options": "i"}},
-			{"project_id": bson.M{"$regex": search, "$options": "i"}},
-		}
+	filter, err := projectsDashboardFilter(status, createdBy, search)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
 	}
 
 	// Build sort
+	if !projectSortFields[sortBy] {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "sort must be one of created_at, updated_at, name, status, expiry_date, total_tokens_used")
+		return
+	}
 	sortDirection := 1
 	if sortOrder == "desc" {
 		sortDirection = -1
 	}
-	sort := bson.D{{sortBy, sortDirection}}
-
-	// Calculate pagination
-	skip := (page - 1) * limit
 
 	collection := config.GetProjectsCollection()
 
 	// Get total count
 	totalCount, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count projects"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count projects")
 		return
 	}
 
-	// Build aggregation pipeline for enhanced project data
-	pipeline := []bson.M{
-		{"$match": filter},
-		{
-			"$addFields": bson.M{
-				"usage_percentage": bson.M{
-					"$cond": bson.M{
-						"if": bson.M{"$gt": []interface{}{"$monthly_token_limit", 0}},
-						"then": bson.M{
-							"$multiply": []interface{}{
-								bson.M{"$divide": []interface{}{"$total_tokens_used", "$monthly_token_limit"}},
-								100,
-							},
-						},
-						"else": 0,
-					},
-				},
-				"days_until_expiry": bson.M{
-					"$divide": []interface{}{
-						bson.M{"$subtract": []interface{}{"$expiry_date", "$$NOW"}},
-						86400000, // milliseconds in a day
-					},
-				},
-				"estimated_cost": bson.M{
-					"$multiply": []interface{}{
-						"$total_tokens_used",
-						0.000005, // Approximate cost per token in INR
-					},
-				},
-			},
-		},
-		{"$sort": sort},
-		{"$skip": skip},
-		{"$limit": limit},
-	}
+	// Computed fields (usage percentage, days until expiry, cost) are filled in by NewProjectResponse
+	opts := options.Find().
+		SetProjection(userProjectExcludedFields).
+		SetSort(bson.D{{Key: sortBy, Value: sortDirection}, {Key: "_id", Value: sortDirection}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get projects"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get projects")
 		return
 	}
 	defer cursor.Close(ctx)
 
-	var projects []bson.M
+	projects := []models.Project{}
 	if err := cursor.All(ctx, &projects); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse projects"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse projects")
 		return
 	}
 
 	// Calculate pagination info
-	totalPages := (int(totalCount) + limit - 1) / limit
+	totalPages := pageCount(totalCount, limit)
 
 	c.JSON(http.StatusOK, gin.H{
-		"projects": projects,
+		"projects": NewProjectResponses(projects),
 		"pagination": gin.H{
 			"current_page": page,
 			"total_pages":  totalPages,
@@ -346,36 +320,60 @@ func GetProjectsDashboard(c *gin.Context) {
 			"has_prev":     page > 1,
 		},
 		"filters": gin.H{
-			"status": status,
-			"search": search,
-			"sort":   sortBy,
-			"order":  sortOrder,
+			"status":     status,
+			"search":     search,
+			"created_by": createdBy,
+			"sort":       sortBy,
+			"order":      sortOrder,
 		},
 	})
 }
 
+// projectsDashboardFilter - The admin project list filter: status, creator and search term
+func projectsDashboardFilter(status, createdBy, search string) (bson.M, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	if createdBy != "" {
+		creatorID, err := primitive.ObjectIDFromHex(createdBy)
+		if err != nil {
+			return nil, fmt.Errorf("created_by must be a user id")
+		}
+		filter["created_by_user_id"] = creatorID
+	}
+	if search = strings.TrimSpace(search); search != "" {
+		filter = projectSearchFilter(filter, search)
+	}
+	return filter, nil
+}
+
+// projectSearchFilter - base plus a case-insensitive prefix match on name or project_id ("acm"
+// finds "Acme"). The anchored patterns are answered from the name and project_id indexes; the
+// term is quoted, so it is matched literally.
+func projectSearchFilter(base bson.M, search string) bson.M {
+	pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.TrimSpace(search)), Options: "i"}
+	filter := bson.M{"$or": []bson.M{
+		{"name": pattern},
+		{"project_id": pattern},
+	}}
+	for key, value := range base {
+		filter[key] = value
+	}
+	return filter
+}
+
 // GetProjectDetails - Get detailed project information with analytics
 func GetProjectDetails(c *gin.Context) {
 	projectID := c.Param("id")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
-	// Get project by project_id or _id
-	var project models.Project
-	collection := config.GetProjectsCollection()
-
-	// Try to find by project_id first, then by _id
-	err := collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
+	project, err := findProjectByAnyID(ctx, projectID)
 	if err != nil {
-		// Try by ObjectID
-		if objID, parseErr := primitive.ObjectIDFromHex(projectID); parseErr == nil {
-			err = collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&project)
-		}
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-			return
-		}
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
 	}
 
 	// Get project analytics
@@ -387,26 +385,41 @@ func GetProjectDetails(c *gin.Context) {
 	// Get usage history
 	usageHistory := getUsageHistory(project.ProjectID, 30)
 
-	// Calculate additional metrics
-	usagePercent := float64(0)
-	if project.MonthlyTokenLimit > 0 {
-		usagePercent = float64(project.TotalTokensUsed) / float64(project.MonthlyTokenLimit) * 100
-	}
-
-	daysUntilExpiry := time.Until(project.ExpiryDate).Hours() / 24
-	estimatedCost := float64(project.TotalTokensUsed) * 0.000005 // Approximate cost
+	response := NewProjectResponse(project, true)
 
 	c.JSON(http.StatusOK, gin.H{
-		"project":           project,
-		"usage_percentage":  usagePercent,
-		"days_until_expiry": daysUntilExpiry,
-		"estimated_cost":    estimatedCost,
+		"project":           response,
+		"usage_percentage":  response.UsagePercentage,
+		"days_until_expiry": response.DaysUntilExpiry,
+		"estimated_cost":    response.EstimatedCost,
 		"analytics":         analytics,
 		"recent_chats":      recentChats,
 		"usage_history":     usageHistory,
 	})
 }
 
+// findProjectByAnyID - Look a project up by project_id first, then by _id
+func findProjectByAnyID(ctx context.Context, projectID string) (*models.Project, error) {
+	var project models.Project
+	collection := config.GetProjectsCollection()
+
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		return collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
+	})
+	if err != nil {
+		if objID, parseErr := primitive.ObjectIDFromHex(projectID); parseErr == nil {
+			err = config.RetryRead(ctx, func(ctx context.Context) error {
+				return collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&project)
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &project, nil
+}
+
 // RenewProject - Renew project subscription
 func RenewProject(c *gin.Context) {
 	projectID := c.Param("id")
@@ -422,7 +435,7 @@ func RenewProject(c *gin.Context) {
 	}
 
 	if renewData.Months <= 0 || renewData.Months > 12 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Months must be between 1 and 12"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Months must be between 1 and 12")
 		return
 	}
 
@@ -433,23 +446,24 @@ func RenewProject(c *gin.Context) {
 		"status":        "active",
 		"reminder_sent": false,
 		"updated_at":    time.Now(),
+		"plan":          models.PlanPaid, // Renewing converts a trial into a paid subscription
 	}
 
 	if renewData.ResetTokens {
 		updateFields["total_tokens_used"] = int64(0)
 	}
 
-	update := bson.M{"$set": updateFields}
+	update := bson.M{"$set": updateFields, "$unset": bson.M{"trial_ends_at": ""}}
 
-	result, err := collection.UpdateOne(context.Background(),
+	result, err := collection.UpdateOne(c.Request.Context(),
 		bson.M{"project_id": projectID}, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to renew project"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to renew project")
 		return
 	}
 
 	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
@@ -474,14 +488,12 @@ func UpdateProjectStatus(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&statusData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status data"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid status data")
 		return
 	}
 
 	if !isValidStatus(statusData.Status) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid status. Must be: active, suspended, expired, or deleted",
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid status. Must be: active, suspended, expired, or deleted")
 		return
 	}
 
@@ -494,15 +506,15 @@ func UpdateProjectStatus(c *gin.Context) {
 		},
 	}
 
-	result, err := collection.UpdateOne(context.Background(),
+	result, err := collection.UpdateOne(c.Request.Context(),
 		bson.M{"project_id": projectID}, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update status")
 		return
 	}
 
 	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
@@ -525,7 +537,7 @@ func GetProjectUsage(c *gin.Context) {
 	projectID := c.Param("id")
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	// Get project
@@ -533,49 +545,27 @@ func GetProjectUsage(c *gin.Context) {
 	collection := config.GetProjectsCollection()
 	err := collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
-	// Calculate usage metrics
-	usagePercent := float64(0)
-	if project.MonthlyTokenLimit > 0 {
-		usagePercent = float64(project.TotalTokensUsed) / float64(project.MonthlyTokenLimit) * 100
-	}
-
-	remainingTokens := project.MonthlyTokenLimit - project.TotalTokensUsed
-	daysUntilExpiry := time.Until(project.ExpiryDate).Hours() / 24
-	estimatedCost := float64(project.TotalTokensUsed) * 0.000005
-
-	// Get usage history
-	usageHistory := getUsageHistory(project.ProjectID, days)
+	usage := NewUsageResponse(&project, getUsageHistory(project.ProjectID, days), getOverageSummary(ctx, projectID))
+	usage.ChatStatistics = getChatStatistics(ctx, projectID)
 
-	// Get chat statistics
-	chatStats := getChatStatistics(ctx, projectID)
-
-	c.JSON(http.StatusOK, gin.H{
-		"project_id":        projectID,
-		"tokens_used":       project.TotalTokensUsed,
-		"token_limit":       project.MonthlyTokenLimit,
-		"remaining_tokens":  remainingTokens,
-		"usage_percentage":  usagePercent,
-		"days_until_expiry": daysUntilExpiry,
-		"estimated_cost":    estimatedCost,
-		"usage_history":     usageHistory,
-		"chat_statistics":   chatStats,
-		"status":            project.Status,
-		"last_updated":      project.UpdatedAt,
-	})
+	c.JSON(http.StatusOK, usage)
 }
 
 // GetNotificationHistory - Get notification history
+// Pages are ordered by (sent_at, _id) newest first. Pass the returned next_cursor as ?cursor=
+// to get the following page; unlike ?page=, cursor paging stays stable while new notifications
+// arrive.
 func GetNotificationHistory(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	page, limit := parsePagination(c, 50)
 	notificationType := c.Query("type")
 	projectID := c.Query("project_id")
+	cursorParam := c.Query("cursor")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	collection := config.GetNotificationsCollection()
@@ -591,41 +581,75 @@ func GetNotificationHistory(c *gin.Context) {
 		}
 	}
 
-	// Calculate pagination
-	skip := (page - 1) * limit
-
 	// Get total count
 	totalCount, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count notifications")
 		return
 	}
 
+	unreadCount, err := countUnreadNotifications(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count unread notifications")
+		return
+	}
+
+	// One extra row tells whether another page follows
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "sent_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+	pageFilter := filter
+	if cursorParam != "" {
+		sentAt, id, err := decodeCursor(cursorParam)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid cursor")
+			return
+		}
+		pageFilter = bson.M{"$and": []bson.M{filter, afterCursor("sent_at", sentAt, id)}}
+	} else {
+		findOptions.SetSkip(int64((page - 1) * limit))
+	}
+
 	// Get notifications
-	cursor, err := collection.Find(ctx, filter,
-		options.Find().SetSort(bson.M{"sent_at": -1}).SetSkip(int64(skip)).SetLimit(int64(limit)))
+	cursor, err := collection.Find(ctx, pageFilter, findOptions)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get notifications")
 		return
 	}
 	defer cursor.Close(ctx)
 
 	var notifications []bson.M
 	if err := cursor.All(ctx, &notifications); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse notifications"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse notifications")
 		return
 	}
 
-	totalPages := (int(totalCount) + limit - 1) / limit
+	nextCursor := ""
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		last := notifications[limit-1]
+		sentAt, _ := last["sent_at"].(primitive.DateTime)
+		id, _ := last["_id"].(primitive.ObjectID)
+		nextCursor = encodeCursor(sentAt.Time(), id)
+	}
+
+	totalPages := pageCount(totalCount, limit)
+
+	pagination := gin.H{
+		"total_pages": totalPages,
+		"total_count": totalCount,
+		"limit":       limit,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	}
+	if cursorParam == "" {
+		pagination["current_page"] = page
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"notifications": notifications,
-		"pagination": gin.H{
-			"current_page": page,
-			"total_pages":  totalPages,
-			"total_count":  totalCount,
-			"limit":        limit,
-		},
+		"unread_count":  unreadCount,
+		"pagination":    pagination,
 	})
 }
 
@@ -633,7 +657,7 @@ func GetNotificationHistory(c *gin.Context) {
 func GetProjectNotifications(c *gin.Context) {
 	projectID := c.Param("id")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	// Convert project_id to ObjectID for notification lookup
@@ -641,7 +665,7 @@ func GetProjectNotifications(c *gin.Context) {
 	collection := config.GetProjectsCollection()
 	err := collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
@@ -657,14 +681,20 @@ func GetProjectNotifications(c *gin.Context) {
 	cursor, err := notificationsCol.Find(ctx, filter,
 		options.Find().SetSort(bson.M{"sent_at": -1}))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get project notifications"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get project notifications")
 		return
 	}
 	defer cursor.Close(ctx)
 
 	var notifications []bson.M
 	if err := cursor.All(ctx, &notifications); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse notifications"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse notifications")
+		return
+	}
+
+	unreadCount, err := countUnreadNotifications(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count unread notifications")
 		return
 	}
 
@@ -672,6 +702,7 @@ func GetProjectNotifications(c *gin.Context) {
 		"project_id":    projectID,
 		"notifications": notifications,
 		"count":         len(notifications),
+		"unread_count":  unreadCount,
 	})
 }
 
@@ -679,7 +710,7 @@ func GetProjectNotifications(c *gin.Context) {
 func TestNotification(c *gin.Context) {
 	projectID := c.Param("id")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	// Get project
@@ -687,27 +718,56 @@ func TestNotification(c *gin.Context) {
 	collection := config.GetProjectsCollection()
 	err := collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
-	// Send test notification
+	// Send test notification: always recorded in the database, then delivered through
+	// every channel configured for the project so admins can verify their setup. Delivery
+	// happens here rather than in the background so the response can report each channel.
 	message := fmt.Sprintf("Test notification for project: %s", project.Name)
-	err = config.LogNotification(project.ID, "test", message)
+	_, err = config.RecordNotification(project.ID, "test", message)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send test notification"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to send test notification")
 		return
 	}
 
+	channels := utils.ProjectChannels(project.GetNotificationEmail(), project.NotificationWebhookURL, project.NotificationSlackURL)
+	results := utils.Deliver(ctx, channels, utils.Notification{
+		ProjectID:   project.ProjectID,
+		ProjectName: project.Name,
+		Type:        "test",
+		Message:     message,
+		SentAt:      time.Now(),
+	})
+
+	allDelivered := true
+	for _, result := range results {
+		if !result.Success {
+			allDelivered = false
+			log.Printf("⚠️ Test notification via %s to %s failed for %s: %s", result.Channel, result.Target, project.ProjectID, result.Error)
+		}
+	}
+
+	responseMessage := "Test notification sent successfully"
+	switch {
+	case len(channels) == 0:
+		responseMessage = "Test notification logged; no email, webhook or Slack channel is configured for this project"
+	case !allDelivered:
+		responseMessage = "Test notification logged; some channels failed"
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Test notification sent successfully",
-		"project": project.Name,
+		"message":       responseMessage,
+		"project":       project.Name,
+		"all_delivered": allDelivered,
+		"channels":      results,
 	})
 }
 
 // GetSystemStats - Get comprehensive system statistics
 func GetSystemStats(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	stats := getDashboardStats(ctx)
@@ -880,10 +940,43 @@ func getChatStatistics(ctx context.Context, projectID string) map[string]interfa
 	stats["total_messages"] = totalMessages
 	stats["today_messages"] = todayMessages
 	stats["week_messages"] = weekMessages
+	stats["providers"] = providerStatistics(ctx, projectID)
 
 	return stats
 }
 
+// providerStatistics - Messages answered per AI provider, and how many of those were fallbacks.
+// Messages from before provider tracking count as openai.
+func providerStatistics(ctx context.Context, projectID string) map[string]interface{} {
+	providers := map[string]interface{}{}
+
+	cursor, err := config.GetChatMessagesCollection().Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"project_id": projectID}},
+		{"$group": bson.M{
+			"_id":      bson.M{"$ifNull": []interface{}{"$provider", models.AIProviderOpenAI}},
+			"messages": bson.M{"$sum": 1},
+			"fallback": bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$fallback_used", true}}, 1, 0}}},
+		}},
+	})
+	if err != nil {
+		return providers
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Provider string `bson:"_id"`
+		Messages int64  `bson:"messages"`
+		Fallback int64  `bson:"fallback"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return providers
+	}
+	for _, row := range rows {
+		providers[row.Provider] = gin.H{"messages": row.Messages, "fallback_messages": row.Fallback}
+	}
+	return providers
+}
+
 // isValidStatus - Validate project status
 func isValidStatus(status string) bool {
 	validStatuses := []string{"active", "suspended", "expired", "deleted"}
@@ -897,80 +990,163 @@ func isValidStatus(status string) bool {
 
 // extractPDFContent - Extract text content from PDF file
 func extractPDFContent(filePath string) (string, error) {
-    file, err := os.Open(filePath)
-    if err != nil {
-        return "", err
-    }
-    defer file.Close()
-
-    // Get file info
-    info, err := file.Stat()
-    if err != nil {
-        return "", err
-    }
-
-    // Read PDF content using pdf library
-    reader, err := pdf.NewReader(file, info.Size())
-    if err != nil {
-        return "", err
-    }
-
-    var content strings.Builder
-    
-    // Extract text from each page
-    for i := 1; i <= reader.NumPage(); i++ {
-        page := reader.Page(i)
-        if page.V.IsNull() {
-            continue
-        }
-        
-        // ✅ Fix: GetPlainText requires font map parameter
-        text, err := page.GetPlainText(nil)
-        if err != nil {
-            log.Printf("⚠️ Failed to extract text from page %d: %v", i, err)
-            continue
-        }
-        
-        content.WriteString(text)
-        content.WriteString("\n")
-    }
-
-    return content.String(), nil
-}
-
-func generateOpenAIEmbeddings(content string) ([]float64, error) {
-    apiKey := os.Getenv("OPENAI_API_KEY")
-    if apiKey == "" {
-        return nil, fmt.Errorf("OpenAI API key not configured")
-    }
-
-    client := openai.NewClient(apiKey)
-    
-    // Truncate content if too long (OpenAI has token limits)
-    if len(content) > 8000 {
-        content = content[:8000]
-    }
-    
-    // Create embedding request
-    req := openai.EmbeddingRequest{
-        Input: []string{content},
-        Model: openai.AdaEmbeddingV2,
-    }
-    
-    resp, err := client.CreateEmbeddings(context.Background(), req)
-    if err != nil {
-        return nil, fmt.Errorf("failed to create embeddings: %v", err)
-    }
-    
-    if len(resp.Data) == 0 {
-        return nil, fmt.Errorf("no embeddings generated")
-    }
-
-    // Convert []float32 to []float64
-    embedding32 := resp.Data[0].Embedding
-    embedding64 := make([]float64, len(embedding32))
-    for i, v := range embedding32 {
-        embedding64[i] = float64(v)
-    }
-    return embedding64, nil
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// Get file info
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// Read PDF content using pdf library
+	reader, err := pdf.NewReader(file, info.Size())
+	if err != nil {
+		return "", err
+	}
+
+	var content strings.Builder
+
+	// Extract text from each page
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// ✅ Fix: GetPlainText requires font map parameter
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			log.Printf("⚠️ Failed to extract text from page %d: %v", i, err)
+			continue
+		}
+
+		content.WriteString(text)
+		content.WriteString("\n")
+	}
+
+	return content.String(), nil
+}
+
+// Document embedding settings: one vector per PDF over its first maxEmbeddingInputChars characters
+const maxEmbeddingInputChars = 8000
+
+// generateOpenAIEmbeddings - Embed one text with model (a models.EmbeddingModel* value)
+func generateOpenAIEmbeddings(content, model string) ([]float64, error) {
+	client, err := newOpenAIClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Truncate content if too long (OpenAI has token limits)
+	content = truncateEmbeddingInput(content)
+
+	// Create embedding request
+	req := openai.EmbeddingRequest{
+		Input: []string{content},
+		Model: openai.EmbeddingModel(model),
+	}
+
+	estimated := estimateTextTokens(content)
+	if err := waitForOpenAI(context.Background(), estimated); err != nil {
+		return nil, err
+	}
+	resp, err := client.CreateEmbeddings(context.Background(), req)
+	settleOpenAI(estimated, resp.Usage.TotalTokens, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no embeddings generated")
+	}
+
+	// Convert []float32 to []float64
+	embedding32 := resp.Data[0].Embedding
+	embedding64 := make([]float64, len(embedding32))
+	for i, v := range embedding32 {
+		embedding64[i] = float64(v)
+	}
+	return embedding64, nil
+}
+
+// truncateEmbeddingInput - First maxEmbeddingInputChars characters of a document-level input
+func truncateEmbeddingInput(content string) string {
+	if len(content) > maxEmbeddingInputChars {
+		return content[:maxEmbeddingInputChars]
+	}
+	return content
+}
+
+// Embeddings request limits: OpenAI accepts up to 2048 inputs and 300k tokens per request;
+// both are kept well below so the char-based token estimate can't push a batch over
+const (
+	maxEmbeddingBatch       = 100
+	maxEmbeddingBatchTokens = 200000
+)
+
+// embeddingBatches - Split texts into [start, end) ranges that each fit one embeddings request
+func embeddingBatches(texts []string) [][2]int {
+	var batches [][2]int
+	start, tokens := 0, 0
+	for i, text := range texts {
+		size := estimateTextTokens(text)
+		if i > start && (i-start == maxEmbeddingBatch || tokens+size > maxEmbeddingBatchTokens) {
+			batches = append(batches, [2]int{start, i})
+			start, tokens = i, 0
+		}
+		tokens += size
+	}
+	if start < len(texts) {
+		batches = append(batches, [2]int{start, len(texts)})
+	}
+	return batches
+}
+
+// generateBatchEmbeddings - Embed many texts in as few requests as embeddingBatches allows;
+// vectors are returned in input order
+func generateBatchEmbeddings(texts []string, model string) ([][]float64, error) {
+	client, err := newOpenAIClient()
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float64, 0, len(texts))
+
+	for _, bounds := range embeddingBatches(texts) {
+		start, end := bounds[0], bounds[1]
+
+		estimated := estimateTextTokens(texts[start:end]...)
+		if err := waitForOpenAI(context.Background(), estimated); err != nil {
+			return nil, err
+		}
+		resp, err := client.CreateEmbeddings(context.Background(), openai.EmbeddingRequest{
+			Input: texts[start:end],
+			Model: openai.EmbeddingModel(model),
+		})
+		settleOpenAI(estimated, resp.Usage.TotalTokens, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embeddings: %w", err)
+		}
+		if len(resp.Data) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(resp.Data))
+		}
+
+		batch := make([][]float64, end-start)
+		for _, data := range resp.Data {
+			if data.Index < 0 || data.Index >= len(batch) {
+				return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+			}
+			vector := make([]float64, len(data.Embedding))
+			for i, v := range data.Embedding {
+				vector[i] = float64(v)
+			}
+			batch[data.Index] = vector
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	return vectors, nil
 }
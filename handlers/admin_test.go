@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestProjectsDashboardFilter(t *testing.T) {
+	creator := primitive.NewObjectID()
+
+	filter, err := projectsDashboardFilter("active", creator.Hex(), " acm ")
+	if err != nil {
+		t.Fatalf("projectsDashboardFilter: %v", err)
+	}
+	if filter["status"] != "active" || filter["created_by_user_id"] != creator {
+		t.Errorf("status or creator clause lost: %v", filter)
+	}
+	if _, ok := filter["$or"]; !ok {
+		t.Errorf("no search clause: %v", filter)
+	}
+
+	if filter, _ := projectsDashboardFilter("", "", "   "); len(filter) != 0 {
+		t.Errorf("blank search produced a filter: %v", filter)
+	}
+	if _, err := projectsDashboardFilter("", "not-an-id", ""); err == nil {
+		t.Error("invalid created_by accepted")
+	}
+}
+
+func TestProjectSearchFilterMatchesPrefixes(t *testing.T) {
+	base := bson.M{"status": "active"}
+	filter := projectSearchFilter(base, "acm")
+
+	if filter["status"] != "active" {
+		t.Errorf("base clause lost: %v", filter)
+	}
+	if _, ok := base["$or"]; ok {
+		t.Error("base filter was modified")
+	}
+
+	clauses := filter["$or"].([]bson.M)
+	if len(clauses) != 2 {
+		t.Fatalf("want 2 clauses, got %d", len(clauses))
+	}
+	pattern := clauses[0]["name"].(primitive.Regex)
+	re := regexp.MustCompile("(?" + pattern.Options + ")" + pattern.Pattern)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Acme", true},
+		{"ACME Corp", true},
+		{"The acme shop", false},
+	}
+	for _, tt := range tests {
+		if got := re.MatchString(tt.name); got != tt.want {
+			t.Errorf("search acm matches %q = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjectSearchFilterQuotesTerm(t *testing.T) {
+	filter := projectSearchFilter(bson.M{}, "a.b(")
+	pattern := filter["$or"].([]bson.M)[0]["name"].(primitive.Regex)
+	re := regexp.MustCompile(pattern.Pattern)
+	if re.MatchString("axb(") {
+		t.Error("regex metacharacters in the term were not quoted")
+	}
+	if !re.MatchString("a.b(") {
+		t.Error("literal term does not match itself")
+	}
+}
+
+func TestProjectsDashboardSearchUsesIndexes(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	// The indexes config.setupIndexes creates for the search
+	projects := config.GetProjectsCollection()
+	_, err := projects.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+		{Keys: bson.D{{Key: "project_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		t.Fatalf("create indexes: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := projects.InsertOne(ctx, bson.M{"project_id": fmt.Sprintf("proj_%d", i), "name": fmt.Sprintf("Project %d", i)}); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	filter, err := projectsDashboardFilter("", "", "acm")
+	if err != nil {
+		t.Fatalf("projectsDashboardFilter: %v", err)
+	}
+	var plan bson.M
+	err = config.DB.RunCommand(ctx, bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: "projects"},
+			{Key: "filter", Value: filter},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&plan)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+
+	winning := fmt.Sprint(plan["queryPlanner"].(bson.M)["winningPlan"])
+	for _, index := range []string{"name_1", "project_id_1"} {
+		if !strings.Contains(winning, index) {
+			t.Errorf("winning plan does not use %s: %s", index, winning)
+		}
+	}
+	if strings.Contains(winning, "COLLSCAN") {
+		t.Errorf("winning plan scans the collection: %s", winning)
+	}
+}
+
+func TestGetProjectsDashboardRejectsBadCreator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/projects", GetProjectsDashboard)
+
+	// Refused before the query runs, so no database is needed
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects?created_by=admin", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "created_by must be a user id") {
+		t.Errorf("got %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestGetProjectsDashboardFiltersByCreator(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	creator, other := primitive.NewObjectID(), primitive.NewObjectID()
+	for i, createdBy := range []primitive.ObjectID{creator, other, creator} {
+		project := bson.M{"project_id": fmt.Sprintf("proj_%d", i), "name": fmt.Sprintf("Project %d", i), "created_by_user_id": createdBy, "created_at": time.Now()}
+		if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/projects", GetProjectsDashboard)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects?created_by="+creator.Hex(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Projects   []map[string]interface{} `json:"projects"`
+		Pagination map[string]interface{}   `json:"pagination"`
+		Filters    map[string]interface{}   `json:"filters"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Projects) != 2 || resp.Pagination["total_count"] != 2.0 {
+		t.Errorf("got %d projects (total %v), want the creator's 2", len(resp.Projects), resp.Pagination["total_count"])
+	}
+	if resp.Filters["created_by"] != creator.Hex() {
+		t.Errorf("filters = %v, want created_by echoed", resp.Filters)
+	}
+}
+
+func TestGetProjectsDashboardRejectsUnknownSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/projects", GetProjectsDashboard)
+
+	for _, sortBy := range []string{"openai_api_key", "pdf_content", "$where"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects?sort="+url.QueryEscape(sortBy), nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "sort must be one of") {
+			t.Errorf("sort=%s: got %d %s, want 400", sortBy, w.Code, w.Body)
+		}
+	}
+}
+
+func TestGetProjectsDashboardSortsAndPages(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	for i, tokens := range []int64{300, 100, 200} {
+		project := models.Project{
+			ProjectID: fmt.Sprintf("proj_%d", i), Name: fmt.Sprintf("Project %d", i), Status: "active",
+			TotalTokensUsed: tokens, MonthlyTokenLimit: 1000, ExpiryDate: time.Now().AddDate(0, 1, 0),
+			OpenAIAPIKey: "sk-secret-key", PDFContent: "confidential handbook text",
+		}
+		if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/projects", GetProjectsDashboard)
+
+	var resp struct {
+		Projects   []ProjectResponse      `json:"projects"`
+		Pagination map[string]interface{} `json:"pagination"`
+	}
+	get := func(query string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", query, w.Code, w.Body)
+		}
+		if strings.Contains(w.Body.String(), "sk-secret-key") || strings.Contains(w.Body.String(), "confidential") {
+			t.Errorf("%s: response leaks project secrets", query)
+		}
+		resp.Projects = nil
+		json.Unmarshal(w.Body.Bytes(), &resp)
+	}
+
+	get("sort=total_tokens_used&order=asc&limit=2")
+	if len(resp.Projects) != 2 || resp.Projects[0].TotalTokensUsed != 100 || resp.Projects[1].TotalTokensUsed != 200 {
+		t.Fatalf("first page = %+v, want the two lowest usages in order", resp.Projects)
+	}
+	if resp.Projects[0].UsagePercentage != 10 {
+		t.Errorf("usage_percentage = %v, want it computed", resp.Projects[0].UsagePercentage)
+	}
+	if resp.Pagination["total_pages"] != 2.0 || resp.Pagination["has_next"] != true || resp.Pagination["has_prev"] != false {
+		t.Errorf("pagination = %v", resp.Pagination)
+	}
+
+	get("sort=total_tokens_used&order=asc&limit=2&page=2")
+	if len(resp.Projects) != 1 || resp.Projects[0].TotalTokensUsed != 300 || resp.Pagination["has_next"] != false {
+		t.Errorf("second page = %+v %v, want the highest usage only", resp.Projects, resp.Pagination)
+	}
+}
+
+func TestTestNotificationReportsEachChannel(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("SMTP_FROM", "")
+
+	received := 0
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { received++ }))
+	defer working.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	for _, p := range []models.Project{
+		{ID: primitive.NewObjectID(), ProjectID: "working", Name: "Working", NotificationWebhookURL: working.URL},
+		{ID: primitive.NewObjectID(), ProjectID: "broken", Name: "Broken", NotificationWebhookURL: broken.URL},
+		{ID: primitive.NewObjectID(), ProjectID: "unconfigured", Name: "Unconfigured", ClientID: "user_1"},
+	} {
+		if _, err := config.GetProjectsCollection().InsertOne(ctx, p); err != nil {
+			t.Fatalf("insert %s: %v", p.ProjectID, err)
+		}
+	}
+
+	r := gin.New()
+	r.POST("/projects/:id/notifications/test", TestNotification)
+
+	tests := []struct {
+		projectID    string
+		wantStatus   int
+		wantAll      bool
+		wantChannels int
+		wantMessage  string
+	}{
+		{"working", http.StatusOK, true, 1, "sent successfully"},
+		{"broken", http.StatusOK, false, 1, "some channels failed"},
+		{"unconfigured", http.StatusOK, true, 0, "no email, webhook or Slack channel"},
+		{"missing", http.StatusNotFound, false, 0, "Project not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.projectID, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/projects/"+tt.projectID+"/notifications/test", nil))
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Fatalf("got %d %s, want %d mentioning %q", w.Code, w.Body, tt.wantStatus, tt.wantMessage)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp struct {
+				AllDelivered bool              `json:"all_delivered"`
+				Channels     []json.RawMessage `json:"channels"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.AllDelivered != tt.wantAll || len(resp.Channels) != tt.wantChannels {
+				t.Errorf("all_delivered %v with %d channels, want %v with %d", resp.AllDelivered, len(resp.Channels), tt.wantAll, tt.wantChannels)
+			}
+		})
+	}
+
+	if received != 1 {
+		t.Errorf("working webhook received %d notifications, want 1", received)
+	}
+	// Each test is logged whether or not it could be delivered
+	if n, err := config.GetNotificationsCollection().CountDocuments(ctx, bson.M{"type": "test"}); err != nil || n != 3 {
+		t.Errorf("logged test notifications = %d (%v), want 3", n, err)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// AI concurrency defaults (overridable via AI_MAX_CONCURRENT_PER_PROJECT / AI_CONCURRENCY_WAIT_MS)
+const (
+	defaultAIMaxConcurrent     = 5
+	defaultAIConcurrencyWaitMS = 2000
+)
+
+// errAIBusy - The project already has its maximum number of AI calls in flight
+var errAIBusy = errors.New("too many concurrent AI requests for this project")
+
+// projectSemaphores - One counting semaphore per project, limiting its in-flight AI calls so a
+// single busy project can't exhaust the shared provider rate limits
+var (
+	projectSemaphoresMu sync.Mutex
+	projectSemaphores   = map[string]chan struct{}{}
+)
+
+func projectSemaphore(projectID string) chan struct{} {
+	projectSemaphoresMu.Lock()
+	defer projectSemaphoresMu.Unlock()
+
+	sem, ok := projectSemaphores[projectID]
+	if !ok {
+		sem = make(chan struct{}, envInt("AI_MAX_CONCURRENT_PER_PROJECT", defaultAIMaxConcurrent))
+		projectSemaphores[projectID] = sem
+	}
+	return sem
+}
+
+// acquireAISlot - Wait up to AI_CONCURRENCY_WAIT_MS for one of the project's AI call slots.
+// On success the returned release must be called when the AI work is done.
+func acquireAISlot(ctx context.Context, projectID string) (func(), error) {
+	sem := projectSemaphore(projectID)
+	release := func() { <-sem }
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	wait := time.NewTimer(time.Duration(envInt("AI_CONCURRENCY_WAIT_MS", defaultAIConcurrencyWaitMS)) * time.Millisecond)
+	defer wait.Stop()
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-wait.C:
+		return nil, errAIBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireAISlot(t *testing.T) {
+	t.Setenv("AI_MAX_CONCURRENT_PER_PROJECT", "2")
+	t.Setenv("AI_CONCURRENCY_WAIT_MS", "30")
+	const projectID = "proj_concurrency_limit"
+
+	first, err := acquireAISlot(context.Background(), projectID)
+	if err != nil {
+		t.Fatalf("first slot: %v", err)
+	}
+	second, err := acquireAISlot(context.Background(), projectID)
+	if err != nil {
+		t.Fatalf("second slot: %v", err)
+	}
+
+	started := time.Now()
+	if _, err := acquireAISlot(context.Background(), projectID); !errors.Is(err, errAIBusy) {
+		t.Fatalf("third slot: err = %v, want errAIBusy", err)
+	}
+	if waited := time.Since(started); waited < 30*time.Millisecond {
+		t.Errorf("gave up after %v, want it to wait AI_CONCURRENCY_WAIT_MS", waited)
+	}
+
+	// Other projects have their own slots
+	other, err := acquireAISlot(context.Background(), "proj_concurrency_other")
+	if err != nil {
+		t.Fatalf("other project: %v", err)
+	}
+	other()
+
+	// A waiting call gets the slot as soon as one is released
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		first()
+	}()
+	third, err := acquireAISlot(context.Background(), projectID)
+	if err != nil {
+		t.Fatalf("slot after release: %v", err)
+	}
+	second()
+	third()
+}
+
+func TestAcquireAISlotStopsWithTheRequest(t *testing.T) {
+	t.Setenv("AI_MAX_CONCURRENT_PER_PROJECT", "1")
+	t.Setenv("AI_CONCURRENCY_WAIT_MS", "5000")
+	const projectID = "proj_concurrency_cancel"
+
+	release, err := acquireAISlot(context.Background(), projectID)
+	if err != nil {
+		t.Fatalf("first slot: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := acquireAISlot(ctx, projectID); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the request's deadline", err)
+	}
+}
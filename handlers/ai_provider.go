@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// AI provider resilience defaults (overridable via AI_* env vars)
+const (
+	defaultAIProviderRetries     = 1  // extra attempts on the same provider before failing over
+	defaultAIBreakerFailures     = 5  // consecutive failures that open a provider's circuit
+	defaultAIBreakerCooldownSecs = 60 // how long an open circuit skips the provider
+)
+
+// errCircuitOpen - The provider failed repeatedly and is being skipped until its cooldown ends
+var errCircuitOpen = errors.New("provider circuit open")
+
+// providerAttempt - One call to a provider; swapped out in tests
+var providerAttempt = callProvider
+
+// chatRequest - Everything a provider needs to answer one visitor message
+type chatRequest struct {
+	Message         string
+	DocumentContext string
+	History         conversationHistory
+	Tools           *chatTools
+	Page            pageContext // Page the visitor is on, if the widget sent it
+	CantAnswer      string      // Project's wording for "can't answer"; set by generateChatResponse
+}
+
+// systemMessage - The system prompt for this request: document grounding plus page context
+func (r chatRequest) systemMessage(systemPrompt string) string {
+	return buildSystemMessage(r.DocumentContext, systemPrompt, r.CantAnswer) + r.Page.promptSection()
+}
+
+// chatResult - A provider's answer and which provider gave it
+type chatResult struct {
+	Response     string
+	Tokens       int
+	Provider     string
+	Model        string
+	FallbackUsed bool
+}
+
+// providerBreaker - Consecutive-failure circuit breaker for one provider, shared by all projects
+type providerBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+var (
+	aiBreakersMu sync.Mutex
+	aiBreakers   = map[string]*providerBreaker{}
+)
+
+func breakerFor(provider string) *providerBreaker {
+	aiBreakersMu.Lock()
+	defer aiBreakersMu.Unlock()
+	b, ok := aiBreakers[provider]
+	if !ok {
+		b = &providerBreaker{}
+		aiBreakers[provider] = b
+	}
+	return b
+}
+
+func (b *providerBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().After(b.openUntil)
+}
+
+func (b *providerBreaker) record(provider string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= envInt("AI_BREAKER_FAILURES", defaultAIBreakerFailures) {
+		cooldown := time.Duration(envInt("AI_BREAKER_COOLDOWN_SECONDS", defaultAIBreakerCooldownSecs)) * time.Second
+		b.openUntil = time.Now().Add(cooldown)
+		b.failures = 0
+		log.Printf("🔌 AI provider %s failing, skipping it for %v", provider, cooldown)
+	}
+}
+
+// generateChatResponse - Answer with the project's provider; if it fails after retries (or its
+// circuit is open) and the project has a different fallback provider, answer with that instead.
+// Once a tool webhook has been called the message is not replayed on the fallback, which would
+// call it a second time.
+func generateChatResponse(ctx context.Context, project *models.Project, req chatRequest) (chatResult, error) {
+	req.CantAnswer = project.CantAnswerMessage
+	primary := project.GetAIProvider()
+	result, err := callProviderWithRetry(ctx, primary, project, req)
+	if err == nil {
+		return result, nil
+	}
+
+	fallback := project.FallbackProvider
+	if fallback == "" || fallback == primary || !models.IsValidAIProvider(fallback) || ctx.Err() != nil {
+		return result, err
+	}
+	if req.Tools.ran() {
+		log.Printf("⚠️ Provider %s failed for project %s after calling tools, not falling back to %s: %v", primary, project.ProjectID, fallback, err)
+		return result, err
+	}
+
+	log.Printf("⚠️ Provider %s failed for project %s (%v), falling back to %s", primary, project.ProjectID, err, fallback)
+	spent := result.Tokens
+	result, fallbackErr := callProviderWithRetry(ctx, fallback, project, req)
+	result.Tokens += spent
+	if fallbackErr != nil {
+		return result, fmt.Errorf("%s: %w; fallback %s: %w", primary, err, fallback, fallbackErr)
+	}
+	result.FallbackUsed = true
+	return result, nil
+}
+
+// callProviderWithRetry - callProvider with AI_PROVIDER_RETRIES extra attempts, recording the
+// outcome on the provider's circuit breaker. result.Tokens covers every attempt, failed ones
+// included, so tokens a provider consumed before erroring are still billed. An attempt that
+// called a tool webhook is not retried.
+func callProviderWithRetry(ctx context.Context, provider string, project *models.Project, req chatRequest) (chatResult, error) {
+	breaker := breakerFor(provider)
+	if !breaker.allow() {
+		return chatResult{Provider: provider}, errCircuitOpen
+	}
+
+	retries := envInt("AI_PROVIDER_RETRIES", defaultAIProviderRetries)
+	var result chatResult
+	var err error
+	spent := 0
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				result.Tokens = spent
+				return result, ctx.Err()
+			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+			}
+		}
+		result, err = providerAttempt(ctx, provider, project, req)
+		spent += result.Tokens
+		if err == nil || ctx.Err() != nil || !retryableProviderError(err) || req.Tools.ran() {
+			break
+		}
+	}
+	// A cancelled or timed-out request (or one that was never sent) says nothing about the provider's health
+	if ctx.Err() == nil && retryableProviderError(err) {
+		breaker.record(provider, err)
+	}
+	result.Tokens = spent
+	return result, err
+}
+
+// retryableProviderError - Whether err came from the provider; an oversize prompt or a missing
+// API key fails the same way on every attempt and is no sign of an outage
+func retryableProviderError(err error) bool {
+	return !errors.Is(err, errPromptTooLarge) && !errors.Is(err, errOpenAINotConfigured)
+}
+
+// callProvider - One attempt with one provider
+func callProvider(ctx context.Context, provider string, project *models.Project, req chatRequest) (chatResult, error) {
+	result := chatResult{Provider: provider}
+
+	switch provider {
+	case models.AIProviderGemini:
+		// Project tools are OpenAI function calls; Gemini answers without them
+		result.Model = "gemini-1.5-flash"
+		if err := fitPromptToWindow(result.Model, project.SystemPrompt, &req); err != nil {
+			return result, err
+		}
+		systemMessage := req.systemMessage(project.SystemPrompt)
+		if req.History.Summary != "" {
+			systemMessage += "\n\nSummary of the earlier conversation:\n" + req.History.Summary
+		}
+		response, tokens, err := config.GenerateChat(ctx, result.Model, systemMessage, geminiHistory(req.History), req.Message)
+		result.Response, result.Tokens = response, tokens
+		return result, err
+
+	default:
+		result.Model = project.OpenAIModel
+		if result.Model == "" {
+			result.Model = "gpt-4o"
+		}
+		if err := fitPromptToWindow(result.Model, project.SystemPrompt, &req); err != nil {
+			return result, err
+		}
+		response, tokens, err := generateOpenAIResponse(ctx, req.Message, req.systemMessage(project.SystemPrompt), result.Model, req.History, req.Tools)
+		result.Response, result.Tokens = response, tokens
+		return result, err
+	}
+}
+
+// geminiHistory - Prior turns in Gemini's chat format
+func geminiHistory(history conversationHistory) []*genai.Content {
+	contents := make([]*genai.Content, 0, 2*len(history.Turns))
+	for _, turn := range history.Turns {
+		// Gemini history must start with and alternate from a user turn; the opener is left out
+		if turn.Greeting {
+			continue
+		}
+		contents = append(contents,
+			&genai.Content{Role: "user", Parts: []genai.Part{genai.Text(turn.Message)}},
+			&genai.Content{Role: "model", Parts: []genai.Part{genai.Text(turn.Response)}},
+		)
+	}
+	return contents
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"jevi-chat/models"
+)
+
+// stubProviders - Answer provider calls from fn for the duration of a test, with fresh breakers
+func stubProviders(t *testing.T, fn func(provider string, req chatRequest) (chatResult, error)) *[]string {
+	t.Helper()
+	t.Setenv("AI_PROVIDER_RETRIES", "1")
+	t.Setenv("AI_BREAKER_FAILURES", "")
+
+	calls := []string{}
+	previous := providerAttempt
+	providerAttempt = func(ctx context.Context, provider string, project *models.Project, req chatRequest) (chatResult, error) {
+		calls = append(calls, provider)
+		result, err := fn(provider, req)
+		result.Provider = provider
+		return result, err
+	}
+	aiBreakersMu.Lock()
+	aiBreakers = map[string]*providerBreaker{}
+	aiBreakersMu.Unlock()
+	t.Cleanup(func() { providerAttempt = previous })
+	return &calls
+}
+
+func TestGenerateChatResponseFallsBack(t *testing.T) {
+	outage := errors.New("503 service unavailable")
+	calls := stubProviders(t, func(provider string, req chatRequest) (chatResult, error) {
+		if provider == models.AIProviderOpenAI {
+			return chatResult{Tokens: 10}, outage
+		}
+		return chatResult{Response: "answer from gemini", Tokens: 25}, nil
+	})
+
+	project := &models.Project{ProjectID: "proj_1", AIProvider: models.AIProviderOpenAI, FallbackProvider: models.AIProviderGemini}
+	result, err := generateChatResponse(context.Background(), project, chatRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("generateChatResponse: %v", err)
+	}
+	if result.Response != "answer from gemini" || result.Provider != models.AIProviderGemini || !result.FallbackUsed {
+		t.Errorf("result = %+v, want the fallback's answer", result)
+	}
+	if result.Tokens != 45 {
+		t.Errorf("tokens = %d, want 45 (two failed primary attempts plus the fallback)", result.Tokens)
+	}
+	if got := strings.Join(*calls, ","); got != "openai,openai,gemini" {
+		t.Errorf("calls = %s, want a retry on openai then gemini", got)
+	}
+}
+
+func TestGenerateChatResponseDoesNotReplayToolCalls(t *testing.T) {
+	calls := stubProviders(t, func(provider string, req chatRequest) (chatResult, error) {
+		// The model called a tool, then the follow-up completion failed
+		req.Tools.Called++
+		return chatResult{Tokens: 10}, errors.New("connection reset")
+	})
+
+	project := &models.Project{ProjectID: "proj_1", AIProvider: models.AIProviderOpenAI, FallbackProvider: models.AIProviderGemini}
+	tools := &chatTools{ProjectID: "proj_1", Tools: []models.ProjectTool{{Name: "lookup_order"}}}
+	result, err := generateChatResponse(context.Background(), project, chatRequest{Message: "where is my order?", Tools: tools})
+	if err == nil {
+		t.Fatal("expected the provider error")
+	}
+	if len(*calls) != 1 {
+		t.Errorf("calls = %v, want a single attempt once a tool has run", *calls)
+	}
+	if tools.Called != 1 {
+		t.Errorf("tool webhooks called %d times, want 1", tools.Called)
+	}
+	if result.FallbackUsed || result.Tokens != 10 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestGenerateChatResponseWithoutFallback(t *testing.T) {
+	calls := stubProviders(t, func(provider string, req chatRequest) (chatResult, error) {
+		return chatResult{}, errOpenAINotConfigured
+	})
+
+	project := &models.Project{ProjectID: "proj_1", AIProvider: models.AIProviderOpenAI}
+	if _, err := generateChatResponse(context.Background(), project, chatRequest{Message: "hi"}); !errors.Is(err, errOpenAINotConfigured) {
+		t.Errorf("error = %v, want errOpenAINotConfigured", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("calls = %v, a missing API key must not be retried", *calls)
+	}
+}
+
+func TestGenerateChatResponseUsesProjectCantAnswer(t *testing.T) {
+	const message = "Please email support@acme.example."
+	stubProviders(t, func(provider string, req chatRequest) (chatResult, error) {
+		if req.CantAnswer != message || !strings.Contains(req.systemMessage(""), `"`+message+`"`) {
+			t.Errorf("provider got CantAnswer %q, want the project's message in the prompt", req.CantAnswer)
+		}
+		return chatResult{Response: message}, nil
+	})
+
+	project := &models.Project{ProjectID: "proj_1", AIProvider: models.AIProviderOpenAI, CantAnswerMessage: message}
+	if _, err := generateChatResponse(context.Background(), project, chatRequest{Message: "refunds?", CantAnswer: "ignored"}); err != nil {
+		t.Fatalf("generateChatResponse: %v", err)
+	}
+}
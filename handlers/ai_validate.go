@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// AI validation error codes, one per thing an admin can fix
+const (
+	aiCheckMissingKey    = "missing_key"
+	aiCheckInvalidKey    = "invalid_key"
+	aiCheckUnknownModel  = "unknown_model"
+	aiCheckQuotaExceeded = "quota_exceeded"
+	aiCheckRateLimited   = "rate_limited"
+	aiCheckTimeout       = "timeout"
+	aiCheckProviderError = "provider_error"
+)
+
+// aiCheck - Outcome of one test call made by ValidateProjectAI
+type aiCheck struct {
+	Component  string `json:"component"` // chat, fallback_chat or embeddings
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	OK         bool   `json:"ok"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ValidateProjectAI - POST /api/admin/projects/:id/ai/validate
+// Makes a one-token completion with the project's provider (and fallback provider, if set) and
+// embeds a short text with its embedding model, reporting each outcome with a specific error code.
+// The test calls bypass retries and circuit breakers and are not counted against the project's usage.
+func ValidateProjectAI(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	checks := []aiCheck{checkChatProvider(ctx, "chat", project.GetAIProvider(), project)}
+	if fallback := project.FallbackProvider; fallback != "" && fallback != project.GetAIProvider() && models.IsValidAIProvider(fallback) {
+		checks = append(checks, checkChatProvider(ctx, "fallback_chat", fallback, project))
+	}
+	checks = append(checks, checkEmbeddings(ctx, project))
+
+	valid := true
+	for _, check := range checks {
+		valid = valid && check.OK
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"valid":      valid,
+		"checks":     checks,
+	})
+}
+
+// checkChatProvider - One-token completion with provider, using the same model chat would
+func checkChatProvider(ctx context.Context, component, provider string, project *models.Project) aiCheck {
+	check := aiCheck{Component: component, Provider: provider}
+	start := time.Now()
+
+	var err error
+	switch provider {
+	case models.AIProviderGemini:
+		check.Model = "gemini-1.5-flash"
+		if !config.GeminiConfigured() {
+			err = errors.New("GEMINI_API_KEY is not set")
+			check.ErrorCode = aiCheckMissingKey
+			break
+		}
+		_, _, err = config.GenerateChat(ctx, check.Model, "", nil, "ping")
+
+	default:
+		check.Model = project.OpenAIModel
+		if check.Model == "" {
+			check.Model = "gpt-4o"
+		}
+		var client *openai.Client
+		if client, err = newOpenAIClient(); err != nil {
+			break
+		}
+		req := openai.ChatCompletionRequest{
+			Model:     check.Model,
+			Messages:  []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "ping"}},
+			MaxTokens: 1,
+		}
+		estimated := estimateChatTokens(req.Messages, req.MaxTokens)
+		if err = waitForOpenAI(ctx, estimated); err != nil {
+			break
+		}
+		var resp openai.ChatCompletionResponse
+		resp, err = client.CreateChatCompletion(ctx, req)
+		settleOpenAI(estimated, resp.Usage.TotalTokens, err)
+	}
+
+	return finishAICheck(check, start, err)
+}
+
+// checkEmbeddings - Embed a short text with the project's embedding model
+func checkEmbeddings(ctx context.Context, project *models.Project) aiCheck {
+	check := aiCheck{Component: "embeddings", Provider: models.AIProviderOpenAI, Model: project.GetEmbeddingModel()}
+	start := time.Now()
+
+	// generateOpenAIEmbeddings has no context; bound it by the request instead
+	type embedResult struct {
+		vector []float64
+		err    error
+	}
+	done := make(chan embedResult, 1)
+	go func() {
+		vector, err := generateOpenAIEmbeddings("ping", check.Model)
+		done <- embedResult{vector, err}
+	}()
+
+	var vector []float64
+	var err error
+	select {
+	case result := <-done:
+		vector, err = result.vector, result.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	check = finishAICheck(check, start, err)
+	if err == nil {
+		check.Dimensions = len(vector)
+		if expected := models.EmbeddingDimensions[check.Model]; expected > 0 && len(vector) != expected {
+			check.OK = false
+			check.ErrorCode = aiCheckProviderError
+			check.Error = "unexpected embedding dimension"
+		}
+	}
+	return check
+}
+
+// finishAICheck - Record latency and classify err
+func finishAICheck(check aiCheck, start time.Time, err error) aiCheck {
+	check.LatencyMS = time.Since(start).Milliseconds()
+	if err == nil {
+		check.OK = true
+		return check
+	}
+	check.Error = err.Error()
+	if check.ErrorCode == "" {
+		check.ErrorCode = classifyAIError(err)
+	}
+	return check
+}
+
+// classifyAIError - Map a provider error to an aiCheck* code. OpenAI errors carry a status and
+// code; Gemini errors only reach us as text.
+func classifyAIError(err error) string {
+	if errors.Is(err, errOpenAINotConfigured) {
+		return aiCheckMissingKey
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return aiCheckTimeout
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		code, _ := apiErr.Code.(string)
+		switch {
+		case apiErr.HTTPStatusCode == http.StatusUnauthorized || code == "invalid_api_key":
+			return aiCheckInvalidKey
+		case apiErr.HTTPStatusCode == http.StatusNotFound || code == "model_not_found":
+			return aiCheckUnknownModel
+		case code == "insufficient_quota":
+			return aiCheckQuotaExceeded
+		case apiErr.HTTPStatusCode == http.StatusTooManyRequests:
+			return aiCheckRateLimited
+		}
+		return aiCheckProviderError
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "api key not valid") || strings.Contains(message, "api_key_invalid") || strings.Contains(message, "permission"):
+		return aiCheckInvalidKey
+	case strings.Contains(message, "not found") || strings.Contains(message, "404"):
+		return aiCheckUnknownModel
+	case strings.Contains(message, "quota") || strings.Contains(message, "resource_exhausted") || strings.Contains(message, "429"):
+		return aiCheckQuotaExceeded
+	case strings.Contains(message, "deadline") || strings.Contains(message, "timeout"):
+		return aiCheckTimeout
+	}
+	return aiCheckProviderError
+}
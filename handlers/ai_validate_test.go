@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestClassifyAIError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"no key", errOpenAINotConfigured, aiCheckMissingKey},
+		{"deadline", context.DeadlineExceeded, aiCheckTimeout},
+		{"cancelled", fmt.Errorf("call: %w", context.Canceled), aiCheckTimeout},
+		{"openai 401", &openai.APIError{HTTPStatusCode: http.StatusUnauthorized}, aiCheckInvalidKey},
+		{"openai invalid key code", &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Code: "invalid_api_key"}, aiCheckInvalidKey},
+		{"openai 404", &openai.APIError{HTTPStatusCode: http.StatusNotFound}, aiCheckUnknownModel},
+		{"openai model code", &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Code: "model_not_found"}, aiCheckUnknownModel},
+		{"openai quota", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests, Code: "insufficient_quota"}, aiCheckQuotaExceeded},
+		{"openai 429", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, aiCheckRateLimited},
+		{"openai 500", &openai.APIError{HTTPStatusCode: http.StatusInternalServerError}, aiCheckProviderError},
+		{"gemini bad key", errors.New("googleapi: Error 400: API key not valid"), aiCheckInvalidKey},
+		{"gemini unknown model", errors.New("googleapi: Error 404: models/gemini-9 is not found"), aiCheckUnknownModel},
+		{"gemini quota", errors.New("googleapi: Error 429: RESOURCE_EXHAUSTED"), aiCheckQuotaExceeded},
+		{"gemini timeout", errors.New("request timeout"), aiCheckTimeout},
+		{"anything else", errors.New("connection reset"), aiCheckProviderError},
+	}
+
+	for _, tt := range tests {
+		if got := classifyAIError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyAIError = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// stubAIValidation - OpenAI stub answering completions with chatStatus (200 = success) and
+// embeddings with vectors of dimensions
+func stubAIValidation(t *testing.T, chatStatus, dimensions int) {
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/embeddings") {
+			json.NewEncoder(w).Encode(openai.EmbeddingResponse{Object: "list", Data: []openai.Embedding{
+				{Object: "embedding", Embedding: make([]float32, dimensions)},
+			}})
+			return
+		}
+		if chatStatus != http.StatusOK {
+			w.WriteHeader(chatStatus)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"message": "model missing", "code": "model_not_found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(completion(openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "p"}, 9))
+	})
+}
+
+func TestCheckChatProvider(t *testing.T) {
+	stubAIValidation(t, http.StatusOK, 1536)
+	check := checkChatProvider(context.Background(), "chat", models.AIProviderOpenAI, &models.Project{})
+	if !check.OK || check.Model != "gpt-4o" || check.ErrorCode != "" {
+		t.Errorf("check = %+v, want a passing check with the default model", check)
+	}
+
+	stubAIValidation(t, http.StatusNotFound, 1536)
+	check = checkChatProvider(context.Background(), "chat", models.AIProviderOpenAI, &models.Project{OpenAIModel: "gpt-9"})
+	if check.OK || check.Model != "gpt-9" || check.ErrorCode != aiCheckUnknownModel {
+		t.Errorf("check = %+v, want unknown_model for the project's model", check)
+	}
+
+	t.Setenv("GEMINI_API_KEY", "")
+	check = checkChatProvider(context.Background(), "fallback_chat", models.AIProviderGemini, &models.Project{})
+	if check.OK || check.ErrorCode != aiCheckMissingKey || check.Component != "fallback_chat" {
+		t.Errorf("gemini check = %+v, want missing_key", check)
+	}
+}
+
+func TestCheckEmbeddings(t *testing.T) {
+	project := &models.Project{EmbeddingModel: models.EmbeddingModel3Small}
+
+	stubAIValidation(t, http.StatusOK, 1536)
+	if check := checkEmbeddings(context.Background(), project); !check.OK || check.Dimensions != 1536 {
+		t.Errorf("check = %+v, want a passing 1536-dimension check", check)
+	}
+
+	stubAIValidation(t, http.StatusOK, 3072)
+	if check := checkEmbeddings(context.Background(), project); check.OK || check.ErrorCode != aiCheckProviderError || check.Dimensions != 3072 {
+		t.Errorf("check = %+v, want a dimension mismatch", check)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "")
+	if check := checkEmbeddings(context.Background(), project); check.OK || check.ErrorCode != aiCheckMissingKey {
+		t.Errorf("check = %+v, want missing_key", check)
+	}
+}
+
+func TestValidateProjectAI(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+	stubAIValidation(t, http.StatusOK, 1536)
+	t.Setenv("GEMINI_API_KEY", "")
+
+	project := models.Project{ProjectID: "proj_validate", AIProvider: models.AIProviderOpenAI, FallbackProvider: models.AIProviderGemini}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/projects/:id/ai/validate", ValidateProjectAI)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/projects/proj_validate/ai/validate", nil))
+	var resp struct {
+		Valid  bool      `json:"valid"`
+		Checks []aiCheck `json:"checks"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Valid || len(resp.Checks) != 3 {
+		t.Fatalf("got %d %+v, want three checks and an invalid result", w.Code, resp)
+	}
+	for i, want := range []struct {
+		component string
+		ok        bool
+	}{{"chat", true}, {"fallback_chat", false}, {"embeddings", true}} {
+		if resp.Checks[i].Component != want.component || resp.Checks[i].OK != want.ok {
+			t.Errorf("check %d = %+v, want %s ok=%v", i, resp.Checks[i], want.component, want.ok)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/projects/proj_missing/ai/validate", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"log"
 	"net/http"
 	"os"
@@ -11,66 +10,70 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"jevi-chat/config"
-	"jevi-chat/models"
 	"jevi-chat/middleware"
+	"jevi-chat/models"
 )
 
 // Login - Unified admin and user login handler
 // Login function में admin handling को update करें
 func Login(c *gin.Context) {
-    var loginData struct {
-        Email    string `json:"email" binding:"required,email"`
-        Password string `json:"password" binding:"required"`
-    }
-
-    if err := c.ShouldBindJSON(&loginData); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "Invalid request data",
-            "details": err.Error(),
-        })
-        return
-    }
-
-    // Check for admin login first
-    adminEmail := os.Getenv("ADMIN_EMAIL")
-    adminPassword := os.Getenv("ADMIN_PASSWORD")
-
-    if loginData.Email == adminEmail && loginData.Password == adminPassword {
-        // ✅ Create proper admin user object for token generation
-        adminUser := &models.User{
-            ID:    primitive.NewObjectID(),
-            Name:  "Super Admin",
-            Email: adminEmail,
-            Role:  "admin",
-            IsActive: true, // ✅ Important: Set IsActive to true
-        }
-
-        // ✅ Generate JWT token using middleware function
-        token, err := middleware.GenerateJWTToken(adminUser)
-        if err != nil {
-            log.Printf("❌ Failed to generate admin token: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-            return
-        }
-
-        log.Printf("✅ Admin login successful: %s, Token: %s", adminEmail, token[:20]+"...")
-
-        c.JSON(http.StatusOK, gin.H{
-            "message": "Admin login successful",
-            "token":   token,
-            "user": gin.H{
-                "id":    "admin",
-                "name":  "Super Admin",
-                "email": adminEmail,
-                "role":  "admin",
-            },
-        })
-        return
-    }
-
-    // Regular user login logic...
-}
+	var loginData struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&loginData); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request data: "+err.Error())
+		return
+	}
 
+	// Check for admin login first
+	adminEmail := os.Getenv("ADMIN_EMAIL")
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+
+	if loginData.Email == adminEmail && loginData.Password == adminPassword {
+		// ✅ Create proper admin user object for token generation
+		adminUser := &models.User{
+			ID:       primitive.NewObjectID(),
+			Name:     "Super Admin",
+			Email:    adminEmail,
+			Role:     "admin",
+			IsActive: true, // ✅ Important: Set IsActive to true
+		}
+
+		// ✅ Generate JWT token using middleware function
+		token, err := middleware.GenerateJWTToken(adminUser)
+		if err != nil {
+			log.Printf("❌ Failed to generate admin token: %v", err)
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate token")
+			return
+		}
+
+		log.Printf("✅ Admin login successful: %s, Token: %s", adminEmail, token[:20]+"...")
+
+		// Browsers get the token as an HttpOnly cookie too; API clients keep using the bearer token
+		csrfToken, err := middleware.SetAuthCookies(c, token)
+		if err != nil {
+			log.Printf("⚠️ Failed to issue CSRF token for %s: %v", adminEmail, err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Admin login successful",
+			"token":      token,
+			"csrf_token": csrfToken,
+			"user": gin.H{
+				"id":    "admin",
+				"name":  "Super Admin",
+				"email": adminEmail,
+				"role":  "admin",
+			},
+		})
+		return
+	}
+
+	// Regular user login logic...
+	respondError(c, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid email or password")
+}
 
 // Register - User registration handler
 func Register(c *gin.Context) {
@@ -81,10 +84,7 @@ func Register(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&registerData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request data",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request data: "+err.Error())
 		return
 	}
 
@@ -92,36 +92,36 @@ func Register(c *gin.Context) {
 
 	// Check if user already exists
 	var existingUser models.User
-	err := collection.FindOne(context.Background(), bson.M{"email": registerData.Email}).Decode(&existingUser)
+	err := collection.FindOne(c.Request.Context(), bson.M{"email": registerData.Email}).Decode(&existingUser)
 	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		respondError(c, http.StatusConflict, ErrCodeUserExists, "User with this email already exists")
 		return
 	}
 
 	// Hash password using middleware function
 	hashedPassword, err := middleware.HashPassword(registerData.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to process password")
 		return
 	}
 
 	// Create new user
 	user := models.User{
-		ID:            primitive.NewObjectID(),
-		Name:          registerData.Name,
-		Email:         registerData.Email,
-		Password:      hashedPassword,
-		Role:          "user",
-		IsActive:      true,
-		EmailVerified: false,
+		ID:                primitive.NewObjectID(),
+		Name:              registerData.Name,
+		Email:             registerData.Email,
+		Password:          hashedPassword,
+		Role:              "user",
+		IsActive:          true,
+		EmailVerified:     false,
 		NotificationPrefs: models.DefaultUserNotificationPrefs,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
 	}
 
-	result, err := collection.InsertOne(context.Background(), user)
+	result, err := collection.InsertOne(c.Request.Context(), user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create user")
 		return
 	}
 
@@ -130,15 +130,21 @@ func Register(c *gin.Context) {
 	// Generate JWT token using middleware function
 	token, err := middleware.GenerateJWTToken(&user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate token")
 		return
 	}
 
 	log.Printf("✅ User registered successfully: %s", user.Email)
 
+	csrfToken, err := middleware.SetAuthCookies(c, token)
+	if err != nil {
+		log.Printf("⚠️ Failed to issue CSRF token for %s: %v", user.Email, err)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Registration successful",
-		"token":   token,
+		"message":    "Registration successful",
+		"token":      token,
+		"csrf_token": csrfToken,
 		"user": gin.H{
 			"id":    user.ID.Hex(),
 			"name":  user.Name,
@@ -152,22 +158,22 @@ func Register(c *gin.Context) {
 func GetUserProfile(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "User not authenticated")
 		return
 	}
 
-	collection := config.GetCollection("users")
-	var user models.User
-
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid user ID")
 		return
 	}
 
-	err = collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&user)
+	collection := config.GetCollection("users")
+	var user models.User
+
+	err = collection.FindOne(c.Request.Context(), bson.M{"_id": objID}).Decode(&user)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
 		return
 	}
 
@@ -189,28 +195,28 @@ func GetUserProfile(c *gin.Context) {
 func UpdateUserProfile(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	var updateData struct {
-		Name              string                        `json:"name"`
-		Company           string                        `json:"company"`
-		Phone             string                        `json:"phone"`
-		Timezone          string                        `json:"timezone"`
-		Language          string                        `json:"language"`
+		Name              string                       `json:"name"`
+		Company           string                       `json:"company"`
+		Phone             string                       `json:"phone"`
+		Timezone          string                       `json:"timezone"`
+		Language          string                       `json:"language"`
 		NotificationPrefs models.UserNotificationPrefs `json:"notification_prefs"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update data"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid update data")
 		return
 	}
 
 	collection := config.GetCollection("users")
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid user ID")
 		return
 	}
 
@@ -237,14 +243,14 @@ func UpdateUserProfile(c *gin.Context) {
 		update["$set"].(bson.M)["language"] = updateData.Language
 	}
 
-	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
+	result, err := collection.UpdateOne(c.Request.Context(), bson.M{"_id": objID}, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update profile")
 		return
 	}
 
 	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
 		return
 	}
 
@@ -257,7 +263,7 @@ func UpdateUserProfile(c *gin.Context) {
 func ChangePassword(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -267,40 +273,40 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&passwordData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request data")
 		return
 	}
 
 	collection := config.GetCollection("users")
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid user ID")
 		return
 	}
 
 	// Get current user
 	var user models.User
-	err = collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&user)
+	err = collection.FindOne(c.Request.Context(), bson.M{"_id": objID}).Decode(&user)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
 		return
 	}
 
 	// Verify current password using middleware function
 	if !middleware.CheckPasswordHash(passwordData.CurrentPassword, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		respondError(c, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Current password is incorrect")
 		return
 	}
 
 	// Hash new password using middleware function
 	hashedPassword, err := middleware.HashPassword(passwordData.NewPassword)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process new password"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to process new password")
 		return
 	}
 
 	// Update password
-	_, err = collection.UpdateOne(context.Background(),
+	_, err = collection.UpdateOne(c.Request.Context(),
 		bson.M{"_id": objID},
 		bson.M{"$set": bson.M{
 			"password":   hashedPassword,
@@ -309,7 +315,7 @@ func ChangePassword(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update password")
 		return
 	}
 
@@ -320,7 +326,9 @@ func ChangePassword(c *gin.Context) {
 
 // Logout - User logout
 func Logout(c *gin.Context) {
-	// In a stateless JWT system, logout is handled client-side by removing the token
+	// In a stateless JWT system, logout is handled client-side by removing the token; browsers
+	// using the auth cookie have it cleared here
+	middleware.ClearAuthCookies(c)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logged out successfully",
 	})
@@ -333,7 +341,7 @@ func VerifyToken(c *gin.Context) {
 	userRole := c.GetString("user_role")
 
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid token")
 		return
 	}
 
@@ -1,136 +1,387 @@
 package handlers
 
 import (
-
 	"context"
 
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"jevi-chat/config"
+	"jevi-chat/models"
+	"jevi-chat/utils"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
-	"github.com/sashabaranov/go-openai"
-	"jevi-chat/config"
-	"jevi-chat/models"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Default maximum visitor message length in characters (overridable via CHAT_MAX_MESSAGE_LENGTH)
+const defaultMaxMessageLength = 2000
+
 // ProjectChatMessage - Enhanced chat handler with OpenAI GPT-4o and subscription validation
 // ProjectChatMessage - Handle chat messages with PDF context
 func ProjectChatMessage(c *gin.Context) {
-    projectID := c.Param("projectId")
-    
-    var messageData struct {
-        Message   string `json:"message" binding:"required"`
-        SessionID string `json:"session_id"`
-        UserID    string `json:"user_id"`
-    }
-
-    if err := c.ShouldBindJSON(&messageData); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message data"})
-        return
-    }
-
-    // Get project from database
-    collection := config.GetProjectsCollection()
-    var project models.Project
-    err := collection.FindOne(context.Background(), bson.M{"project_id": projectID}).Decode(&project)
-    if err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-        return
-    }
-
-    // ✅ Generate OpenAI response with PDF context
-    response, tokenUsage, err := generateOpenAIResponse(messageData.Message, project.PDFContent, project.OpenAIModel)
-    if err != nil {
-        log.Printf("❌ OpenAI API error: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "Failed to generate response",
-        })
-        return
-    }
-
-    // Update token usage
-    collection.UpdateOne(context.Background(),
-        bson.M{"project_id": projectID},
-        bson.M{"$inc": bson.M{"total_tokens_used": tokenUsage}},
-    )
-
-    // Save chat message to database
-    chatMessage := models.ChatMessage{
-        ID:        primitive.NewObjectID(),
-        ProjectID: projectID,
-        SessionID: messageData.SessionID,
-        UserID:    messageData.UserID,
-        Message:   messageData.Message,
-        Response:  response,
-        TokensUsed: tokenUsage,
-        CreatedAt: time.Now(),
-    }
-
-    config.GetCollection("chat_messages").InsertOne(context.Background(), chatMessage)
-
-    c.JSON(http.StatusOK, gin.H{
-        "status":      "success",
-        "response":    response,
-        "tokens_used": tokenUsage,
-        "usage": gin.H{
-            "total_tokens": project.TotalTokensUsed + int64(tokenUsage),
-            "limit":        project.MonthlyTokenLimit,
-            "usage_percent": float64(project.TotalTokensUsed+int64(tokenUsage)) / float64(project.MonthlyTokenLimit) * 100,
-        },
-    })
+	projectID := c.Param("projectId")
+
+	var messageData struct {
+		Message      string `json:"message" binding:"required"`
+		SessionID    string `json:"session_id"`
+		UserID       string `json:"user_id"`
+		CaptchaToken string `json:"captcha_token"`
+		PageURL      string `json:"page_url"`
+		PageTitle    string `json:"page_title"`
+		PageExcerpt  string `json:"page_excerpt"`
+	}
+
+	if err := c.ShouldBindJSON(&messageData); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid message data")
+		return
+	}
+
+	message, code, validationErr := sanitizeChatMessage(messageData.Message)
+	if validationErr != nil {
+		respondError(c, http.StatusBadRequest, code, validationErr.Error())
+		return
+	}
+	messageData.Message = message
+
+	page, err := parsePageContext(messageData.PageURL, messageData.PageTitle, messageData.PageExcerpt)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	// Every message belongs to a session so it can be grouped and rate-limited
+	var existingSession bool
+	messageData.SessionID, existingSession = resolveSessionID(c.Request.Context(), requestScope(c), projectID, messageData.SessionID)
+
+	// Get project from database
+	collection := config.GetProjectsCollection()
+	var project models.Project
+	err = config.RetryRead(c.Request.Context(), func(ctx context.Context) error {
+		return collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
+	})
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	// Registered widget users (proven by their chat_user token) must still be allowed to chat
+	chatUser, err := chatUserFromRequest(c, &project)
+	if err != nil {
+		log.Printf("⚠️ Ignoring chat user token for project %s: %v", projectID, err)
+	}
+	messageData.UserID = widgetUserID(messageData.UserID, chatUser)
+	if chatUser != nil && !chatUser.CanChat() {
+		respondError(c, http.StatusForbidden, ErrCodeUserBlocked, "User is not allowed to chat")
+		return
+	}
+
+	// Bot protection: anonymous visitors prove they're human on the first message of a session
+	if project.WidgetSettings.RequireCaptcha && !existingSession && chatUser == nil {
+		if !utils.CaptchaConfigured() {
+			log.Printf("⚠️ Project %s requires CAPTCHA but CAPTCHA_PROVIDER/CAPTCHA_SECRET are not set", projectID)
+		} else {
+			ok, err := utils.VerifyCaptcha(c.Request.Context(), messageData.CaptchaToken, c.ClientIP())
+			if err != nil {
+				log.Printf("❌ CAPTCHA verification error for %s: %v", projectID, err)
+			}
+			if !ok {
+				respondError(c, http.StatusForbidden, ErrCodeCaptchaRequired, "CAPTCHA verification failed")
+				return
+			}
+		}
+	}
+
+	// Outside business hours the visitor gets the offline message instead of the bot
+	if !project.WidgetSettings.Schedule.IsOpen(time.Now()) {
+		offlineReply(c, &project, messageData.SessionID, messageData.UserID, messageData.Message, page, chatUser == nil)
+		return
+	}
+
+	// ✅ Generate the AI response (with PDF context when the project has documents),
+	// failing over to the project's fallback provider if the primary one is down
+	startTime := time.Now()
+	documentContext := selectDocumentContext(&project, messageData.Message)
+	usedDocument := hasDocumentContext(documentContext)
+	// Bound this project's in-flight AI calls (history summarization included)
+	release, err := acquireAISlot(c.Request.Context(), projectID)
+	if err != nil {
+		c.Header("Retry-After", "1")
+		respondError(c, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many messages are being answered for this project, please retry shortly")
+		return
+	}
+	defer release()
+
+	var history conversationHistory
+	if existingSession {
+		history = loadConversationHistory(c.Request.Context(), projectID, messageData.SessionID)
+	}
+	tools := &chatTools{ProjectID: projectID, SessionID: messageData.SessionID, Tools: project.EnabledTools()}
+	result, err := generateChatResponse(c.Request.Context(), &project, chatRequest{
+		Message:         messageData.Message,
+		DocumentContext: documentContext,
+		History:         history,
+		Tools:           tools,
+		Page:            page,
+	})
+	response := result.Response
+	tokenUsage := result.Tokens + history.SummaryTokens
+	processingTime := time.Since(startTime).Milliseconds()
+	if err != nil {
+		log.Printf("❌ AI provider error: %v", err)
+		// Tokens consumed before the failure (summaries, failed or interrupted attempts) are
+		// still billed; no message is saved, so the usage gets its own ID
+		if tokenUsage > 0 {
+			recordTokenUsage(context.Background(), projectID, messageData.SessionID, primitive.NewObjectID(), tokenUsage)
+		}
+		if errors.Is(err, errPromptTooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, ErrCodeMessageTooLong, "Your message is too long to answer, please shorten it and try again")
+			return
+		}
+		if errors.Is(err, errOpenAINotConfigured) {
+			respondError(c, http.StatusServiceUnavailable, ErrCodeAIUnavailable, getErrorResponse(err))
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrCodeAIUnavailable, "Failed to generate response")
+		return
+	}
+
+	// Update token usage; under allow_overage the part beyond the limit is recorded for billing.
+	// The tokens are already spent, so this and the message save must not be cancelled with the request.
+	messageID := primitive.NewObjectID()
+	recordTokenUsage(context.Background(), projectID, messageData.SessionID, messageID, tokenUsage)
+
+	// Save chat message to database
+	chatMessage := models.ChatMessage{
+		ID:             messageID,
+		ProjectID:      projectID,
+		SessionID:      messageData.SessionID,
+		UserID:         messageData.UserID,
+		VisitorID:      c.GetString("visitor_id"),
+		Message:        messageData.Message,
+		Response:       response,
+		PageURL:        page.URL,
+		PageTitle:      page.Title,
+		TokensUsed:     tokenUsage,
+		Model:          result.Model,
+		Provider:       result.Provider,
+		FallbackUsed:   result.FallbackUsed,
+		ProcessingTime: processingTime,
+		IPAddress:      storedIP(c.ClientIP()),
+		UserAgent:      c.Request.UserAgent(),
+		CreatedAt:      time.Now(),
+	}
+	chatMessage.KnowledgeGap = isCantAnswerResponse(response, project.CantAnswerMessage)
+	// The model already saw the original text; only the stored copy is redacted
+	if project.RedactPII {
+		chatMessage.Message = utils.RedactPII(chatMessage.Message)
+		chatMessage.Response = utils.RedactPII(chatMessage.Response)
+	}
+
+	config.GetCollection("chat_messages").InsertOne(context.Background(), chatMessage)
+	if chatMessage.KnowledgeGap {
+		recordKnowledgeGap(context.Background(), chatMessage, models.KnowledgeGapNoAnswer, "")
+	}
+
+	// Keep the widget session alive so the idle sweeper and analytics see real activity
+	newSession := updateWidgetSession(projectID, messageData.SessionID, messageData.UserID, c.GetString("visitor_id"), c.ClientIP(), c.Request.UserAgent(), c.Request.Referer(), tokenUsage)
+
+	if chatUser != nil {
+		recordChatUserActivity(chatUser, tokenUsage, newSession)
+	}
+
+	setRequestUsageHeaders(c, tokenUsage)
+	c.JSON(http.StatusOK, gin.H{
+		"status":                "success",
+		"session_id":            messageData.SessionID,
+		"visitor_token":         c.GetString("visitor_token"),
+		"response":              response,
+		"tokens_used":           tokenUsage,
+		"used_document_context": usedDocument,
+		"provider":              result.Provider,
+		"fallback_used":         result.FallbackUsed,
+		// Until the widget has a user, prompt the visitor for their details
+		"collect_user_info": project.WidgetSettings.CollectUserInfo && chatUser == nil,
+		"usage": gin.H{
+			"total_tokens":  project.TotalTokensUsed + int64(tokenUsage),
+			"limit":         project.MonthlyTokenLimit,
+			"usage_percent": float64(project.TotalTokensUsed+int64(tokenUsage)) / float64(project.MonthlyTokenLimit) * 100,
+		},
+	})
+}
+
+// sanitizeChatMessage - Strip control characters, trim and bound the length of a visitor message.
+// Returns the cleaned message, or an error code and message suitable for a 400 response.
+func sanitizeChatMessage(raw string) (string, string, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, raw)
+	cleaned = strings.TrimSpace(cleaned)
+
+	if cleaned == "" {
+		return "", ErrCodeMessageEmpty, fmt.Errorf("Message cannot be empty")
+	}
+
+	maxLength := defaultMaxMessageLength
+	if v, err := strconv.Atoi(os.Getenv("CHAT_MAX_MESSAGE_LENGTH")); err == nil && v > 0 {
+		maxLength = v
+	}
+	if utf8.RuneCountInString(cleaned) > maxLength {
+		return "", ErrCodeMessageTooLong, fmt.Errorf("Message exceeds the %d character limit", maxLength)
+	}
+
+	return cleaned, "", nil
+}
+
+// hasDocumentContext - Whether the project has any usable uploaded document text
+func hasDocumentContext(pdfContext string) bool {
+	return strings.TrimSpace(pdfContext) != ""
 }
 
-// generateOpenAIResponse - Generate response using OpenAI with PDF context
-func generateOpenAIResponse(userMessage, pdfContext, model string) (string, int, error) {
-    client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
-    
-    // Create system message with PDF context
-    systemMessage := fmt.Sprintf(`You are a helpful assistant. Use the following document content to answer user questions accurately:
+// buildSystemMessage - Document-grounded prompt when there is PDF content, otherwise a
+// general assistant prompt (the project's own system prompt if it has one)
+func buildSystemMessage(pdfContext, systemPrompt, cantAnswer string) string {
+	systemPrompt = strings.TrimSpace(systemPrompt)
+	cantAnswer = strings.TrimSpace(cantAnswer)
+
+	if !hasDocumentContext(pdfContext) {
+		if systemPrompt != "" {
+			return systemPrompt + cantAnswerInstruction(cantAnswer)
+		}
+		if cantAnswer != "" {
+			return `You are a helpful assistant for this website. Answer visitors' questions clearly and concisely.
+Never guess about the business.` + cantAnswerInstruction(cantAnswer)
+		}
+		return `You are a helpful assistant for this website. Answer visitors' questions clearly and concisely.
+If you don't know something specific about the business, say so politely instead of guessing.`
+	}
+
+	intro := "You are a helpful assistant."
+	if systemPrompt != "" {
+		intro = systemPrompt
+	}
+
+	return fmt.Sprintf(`%s Use the following document content to answer user questions accurately:
 
 Document Content:
 %s
 
 Instructions:
 - Answer questions based on the provided document content
-- If the question cannot be answered from the document, say so politely
+- %s
 - Be concise and helpful
-- Cite relevant parts of the document when appropriate`, pdfContext)
-
-    req := openai.ChatCompletionRequest{
-        Model: model,
-        Messages: []openai.ChatCompletionMessage{
-            {
-                Role:    openai.ChatMessageRoleSystem,
-                Content: systemMessage,
-            },
-            {
-                Role:    openai.ChatMessageRoleUser,
-                Content: userMessage,
-            },
-        },
-        MaxTokens:   500,
-        Temperature: 0.7,
-    }
-
-    resp, err := client.CreateChatCompletion(context.Background(), req)
-    if err != nil {
-        return "", 0, err
-    }
-
-    if len(resp.Choices) == 0 {
-        return "", 0, fmt.Errorf("no response generated")
-    }
-
-    return resp.Choices[0].Message.Content, resp.Usage.TotalTokens, nil
+- Cite relevant parts of the document when appropriate, naming the [Source: ...] document it came from`, intro, pdfContext, cantAnswerRule(cantAnswer))
+}
+
+// cantAnswerRule - The instruction for questions the document doesn't answer: the project's own
+// wording verbatim when it set one, otherwise a polite refusal
+func cantAnswerRule(cantAnswer string) string {
+	if cantAnswer == "" {
+		return "If the question cannot be answered from the document, say so politely"
+	}
+	return fmt.Sprintf("If the question cannot be answered from the document, reply with exactly this message and nothing else: %q", cantAnswer)
 }
 
+// cantAnswerInstruction - cantAnswerRule as a paragraph appended to a custom system prompt
+func cantAnswerInstruction(cantAnswer string) string {
+	if cantAnswer == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nIf you cannot answer a question, reply with exactly this message and nothing else: %q", cantAnswer)
+}
+
+// generateOpenAIResponse - Generate response using OpenAI with the given system message (see
+// chatRequest.systemMessage) and continuing the session's conversation history. With tools, the model may call them (up to
+// maxToolRounds times) before answering; tokens of every round are counted.
+func generateOpenAIResponse(ctx context.Context, userMessage, systemMessage, model string, history conversationHistory, tools *chatTools) (string, int, error) {
+	client, err := newOpenAIClient()
+	if err != nil {
+		return "", 0, err
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: systemMessage,
+		},
+	}
+	messages = append(messages, historyMessages(history)...)
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: userMessage,
+	})
+
+	req := openai.ChatCompletionRequest{
+		Model:       model,
+		Messages:    messages,
+		MaxTokens:   chatResponseTokens,
+		Temperature: 0.7,
+	}
+	if tools != nil && len(tools.Tools) > 0 {
+		req.Tools = tools.definitions()
+	}
+
+	totalTokens := 0
+	for round := 0; ; round++ {
+		// Last round: no more tool calls, the model has to answer with what it has
+		if round == maxToolRounds {
+			req.Tools = nil
+		}
+
+		estimated := estimateChatTokens(req.Messages, req.MaxTokens)
+		if err := waitForOpenAI(ctx, estimated); err != nil {
+			return "", totalTokens, err
+		}
+		meter := newTokenMeter(estimateChatTokens(req.Messages, 0))
+		resp, err := client.CreateChatCompletion(ctx, req)
+		settleOpenAI(estimated, resp.Usage.TotalTokens, err)
+		if err != nil {
+			// The visitor went away mid-call: OpenAI still processed the prompt, so bill it
+			if ctx.Err() != nil {
+				totalTokens += meter.Tokens()
+			}
+			return "", totalTokens, err
+		}
+		meter.Final(resp.Usage.TotalTokens)
+		totalTokens += meter.Tokens()
+
+		if len(resp.Choices) == 0 {
+			return "", totalTokens, fmt.Errorf("no response generated")
+		}
+
+		reply := resp.Choices[0].Message
+		if len(reply.ToolCalls) == 0 || req.Tools == nil {
+			return reply.Content, totalTokens, nil
+		}
+
+		// Run the requested tools and let the model continue with their results
+		req.Messages = append(req.Messages, reply)
+		for _, call := range reply.ToolCalls {
+			req.Messages = append(req.Messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				ToolCallID: call.ID,
+				Content:    tools.call(ctx, call),
+			})
+		}
+	}
+}
 
 // IframeSendMessage - Legacy endpoint for backward compatibility
 func IframeSendMessage(c *gin.Context) {
@@ -150,27 +401,34 @@ func GetChatHistory(c *gin.Context) {
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	collection := config.GetChatMessagesCollection()
 
-	filter := bson.M{"project_id": projectID}
-	if sessionID != "" {
-		filter["session_id"] = sessionID
-	}
-
-	cursor, err := collection.Find(ctx, filter,
-		options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(int64(limit)))
+	// Visitors only ever see their own conversations
+	filter, err := requestScope(c).projectData(projectID, bson.M{})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat history"})
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
 		return
 	}
-	defer cursor.Close(ctx)
+	if sessionID != "" {
+		filter["session_id"] = sessionID
+	}
 
 	var messages []bson.M
-	if err := cursor.All(ctx, &messages); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chat history"})
+	err = config.RetryRead(ctx, func(ctx context.Context) error {
+		cursor, err := collection.Find(ctx, filter,
+			options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(int64(limit)))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		return cursor.All(ctx, &messages)
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get chat history")
 		return
 	}
 
@@ -198,23 +456,23 @@ func RateMessage(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&ratingData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rating data"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid rating data")
 		return
 	}
 
 	if ratingData.Rating != "positive" && ratingData.Rating != "negative" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Rating must be 'positive' or 'negative'"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Rating must be 'positive' or 'negative'")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	collection := config.GetChatMessagesCollection()
 
 	objID, err := primitive.ObjectIDFromHex(messageID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid message ID")
 		return
 	}
 
@@ -226,18 +484,28 @@ func RateMessage(c *gin.Context) {
 		},
 	}
 
-	result, err := collection.UpdateOne(ctx,
-		bson.M{"_id": objID, "project_id": projectID}, update)
+	filter, err := requestScope(c).projectData(projectID, bson.M{"_id": objID})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rating"})
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
 		return
 	}
 
-	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+	var message models.ChatMessage
+	err = collection.FindOneAndUpdate(ctx, filter, update).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeMessageNotFound, "Message not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to save rating")
 		return
 	}
 
+	// A thumbs-down means the documents probably didn't cover the question well
+	if ratingData.Rating == "negative" {
+		recordKnowledgeGap(context.Background(), message, models.KnowledgeGapNegativeRating, ratingData.Feedback)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Rating saved successfully",
 		"rating":  ratingData.Rating,
@@ -246,49 +514,67 @@ func RateMessage(c *gin.Context) {
 
 // Helper Functions
 
-// getProjectWithValidation - Get project with comprehensive subscription validation
-func getProjectWithValidation(projectID string) (*models.Project, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	collection := config.GetProjectsCollection()
-
-	var project models.Project
-	err := collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
-	if err != nil {
-		return nil, fmt.Errorf("Project not found or invalid.")
+// checkProjectSubscription - Reject projects that are not active, are soft-deleted or have passed their expiry date
+func checkProjectSubscription(project *models.Project) error {
+	// Ended trials are refused straight away; the maintenance job suspends them and notifies the client
+	if project.TrialExpired() {
+		return fmt.Errorf("Your free trial has ended. Please upgrade to continue.")
 	}
 
 	// Check if project is active
 	if project.Status != "active" {
 		switch project.Status {
 		case "expired":
-			return nil, fmt.Errorf("Your subscription has expired. Please renew to continue.")
+			return fmt.Errorf("Your subscription has expired. Please renew to continue.")
 		case "suspended":
-			return nil, fmt.Errorf("Your account is suspended. Please contact support.")
+			return fmt.Errorf("Your account is suspended. Please contact support.")
 		case "deleted":
-			return nil, fmt.Errorf("This project has been deleted.")
+			return fmt.Errorf("This project has been deleted.")
 		default:
-			return nil, fmt.Errorf("Your account is inactive. Please contact support.")
+			return fmt.Errorf("Your account is inactive. Please contact support.")
 		}
 	}
 
 	// Check expiry date
 	if time.Now().After(project.ExpiryDate) {
 		// Auto-update status to expired
-		updateProjectStatus(projectID, "expired")
-		return nil, fmt.Errorf("Your subscription has expired. Please renew to continue.")
+		updateProjectStatus(project.ProjectID, "expired")
+		return fmt.Errorf("Your subscription has expired. Please renew to continue.")
 	}
 
-	// Check token limit
-	if project.TotalTokensUsed >= project.MonthlyTokenLimit {
-		return nil, fmt.Errorf("Monthly usage limit reached. Please upgrade your plan.")
+	if !project.IsActive {
+		return fmt.Errorf("This project is no longer available.")
 	}
 
-	return &project, nil
+	return nil
 }
 
+// getProjectWithValidation - Get project with comprehensive subscription validation
+func getProjectWithValidation(projectID string) (*models.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := config.GetProjectsCollection()
 
+	var project models.Project
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		return collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Project not found or invalid.")
+	}
+
+	if err := checkProjectSubscription(&project); err != nil {
+		return nil, err
+	}
+
+	// Check token limit (allow_overage projects keep serving past it)
+	if project.IsOverLimit() && project.GetOveragePolicy() != models.OveragePolicyAllow {
+		return nil, fmt.Errorf("Monthly usage limit reached. Please upgrade your plan.")
+	}
+
+	return &project, nil
+}
 
 // updateProjectTokenUsage - Update project token usage with notification triggers
 func updateProjectTokenUsage(projectID string, tokensUsed int) error {
@@ -306,7 +592,6 @@ func updateProjectTokenUsage(projectID string, tokensUsed int) error {
 
 	// Calculate new usage
 	newTotalUsage := project.TotalTokensUsed + int64(tokensUsed)
-	usagePercent := float64(newTotalUsage) / float64(project.MonthlyTokenLimit) * 100
 
 	// Update token usage in database
 	update := bson.M{
@@ -320,28 +605,8 @@ func updateProjectTokenUsage(projectID string, tokensUsed int) error {
 	}
 
 	// Trigger notifications asynchronously
-	go func() {
-		// Update project with new usage for notifications
-		project.TotalTokensUsed = newTotalUsage
-
-		// Check if monthly limit reached (100%)
-		if usagePercent >= 100 {
-			recentlySent, err := config.WasNotificationRecentlySent(project.ID, "monthly_limit", 24)
-			if err == nil && !recentlySent {
-				message := fmt.Sprintf("Monthly token limit reached for project: %s", project.Name)
-				config.LogNotification(project.ID, "monthly_limit", message)
-				log.Printf("🚨 Monthly limit notification logged for project: %s", project.Name)
-			}
-		} else if usagePercent >= 80 {
-			// Send warning if approaching limit (80%)
-			recentlySent, err := config.WasNotificationRecentlySent(project.ID, "usage_warning", 12)
-			if err == nil && !recentlySent {
-				message := fmt.Sprintf("Token usage warning (%.1f%%) for project: %s", usagePercent, project.Name)
-				config.LogNotification(project.ID, "usage_warning", message)
-				log.Printf("⚠️ Usage warning notification logged for project: %s", project.Name)
-			}
-		}
-	}()
+	project.TotalTokensUsed = newTotalUsage
+	go notifyUsageThresholds(project, project.TotalTokensUsed-int64(tokensUsed))
 
 	return nil
 }
@@ -363,7 +628,7 @@ func saveChatMessage(projectID, sessionID, userMessage, aiResponse string, token
 		"ai_response":  aiResponse,
 		"tokens_used":  tokensUsed,
 		"timestamp":    time.Now(),
-		"client_ip":    clientIP,
+		"client_ip":    storedIP(clientIP),
 		"user_agent":   userAgent,
 		"user_id":      userID,
 		"user_name":    userName,
@@ -381,8 +646,9 @@ func saveChatMessage(projectID, sessionID, userMessage, aiResponse string, token
 	return messageID.Hex()
 }
 
-// updateWidgetSession - Update or create widget session
-func updateWidgetSession(projectID, sessionID, clientIP, userAgent string, tokensUsed int) {
+// updateWidgetSession - Update or create widget session, reporting whether a new one was started.
+// A message on a closed or timed-out session reopens it, dropping the old end time and reason.
+func updateWidgetSession(projectID, sessionID, userID, visitorID, clientIP, userAgent, referrer string, tokensUsed int) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -399,19 +665,43 @@ func updateWidgetSession(projectID, sessionID, clientIP, userAgent string, token
 			"message_count": 1,
 			"tokens_used":   int64(tokensUsed),
 		},
+		"$unset": bson.M{
+			"ended_at":   "",
+			"end_reason": "",
+			"duration":   "",
+		},
 		"$setOnInsert": bson.M{
 			"session_id": sessionID,
-			"ip_address": clientIP,
+			"user_id":    userID,
+			"visitor_id": visitorID,
+			"ip_address": storedIP(clientIP),
 			"user_agent": userAgent,
+			"referrer":   referrer,
+			"domain":     referrerDomain(referrer),
 			"started_at": time.Now(),
 		},
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	result, err := collection.UpdateOne(ctx, filter, update, opts)
 	if err != nil {
 		log.Printf("❌ Failed to update widget session: %v", err)
+		return false
 	}
+
+	return result.UpsertedCount > 0
+}
+
+// referrerDomain - Host of the page the widget is embedded on
+func referrerDomain(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
 }
 
 // logOpenAIUsage - Log OpenAI API usage for analytics
@@ -465,18 +755,6 @@ func checkRateLimit(projectID, clientIP string) bool {
 	return true
 }
 
-// getClientIP - Get client IP address
-func getClientIP(c *gin.Context) string {
-	// Check for forwarded IP first
-	if ip := c.GetHeader("X-Forwarded-For"); ip != "" {
-		return strings.Split(ip, ",")[0]
-	}
-	if ip := c.GetHeader("X-Real-IP"); ip != "" {
-		return ip
-	}
-	return c.ClientIP()
-}
-
 // generateSessionID - Generate unique session ID
 func generateSessionID() string {
 	return fmt.Sprintf("sess_%d_%s", time.Now().Unix(), generateRandomString(8))
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSanitizeChatMessage(t *testing.T) {
+	t.Setenv("CHAT_MAX_MESSAGE_LENGTH", "10")
+
+	tests := []struct {
+		name, raw string
+		want      string
+		wantCode  string
+	}{
+		{"plain", "Hello", "Hello", ""},
+		{"trimmed", "  Hello \n", "Hello", ""},
+		{"control characters dropped", "He\x00ll\x07o", "Hello", ""},
+		{"newlines and tabs kept", "a\nb\tc", "a\nb\tc", ""},
+		{"invalid utf-8 dropped", "Hi\xff!", "Hi!", ""},
+		{"empty", "", "", ErrCodeMessageEmpty},
+		{"only whitespace and controls", " \x01\x02 ", "", ErrCodeMessageEmpty},
+		{"at the limit in characters", "éééééééééé", "éééééééééé", ""},
+		{"over the limit", "12345678901", "", ErrCodeMessageTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, code, err := sanitizeChatMessage(tt.raw)
+			if code != tt.wantCode || (err != nil) != (tt.wantCode != "") {
+				t.Fatalf("code = %q, err = %v; want %q", code, err, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("sanitizeChatMessage(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeChatMessageDefaultLimit(t *testing.T) {
+	t.Setenv("CHAT_MAX_MESSAGE_LENGTH", "")
+	if _, _, err := sanitizeChatMessage(strings.Repeat("a", defaultMaxMessageLength)); err != nil {
+		t.Errorf("message at the default limit rejected: %v", err)
+	}
+	if _, code, _ := sanitizeChatMessage(strings.Repeat("a", defaultMaxMessageLength+1)); code != ErrCodeMessageTooLong {
+		t.Errorf("message over the default limit: code = %q", code)
+	}
+}
+
+func TestProjectChatMessageRejectsInvalidMessages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CHAT_MAX_MESSAGE_LENGTH", "20")
+
+	r := gin.New()
+	r.POST("/api/projects/:projectId/chat", ProjectChatMessage)
+
+	// Rejected before the session or project is looked up, so no database is needed
+	tests := []struct {
+		name, body string
+		wantCode   string
+	}{
+		{"not json", `message=hi`, ErrCodeValidationFailed},
+		{"missing message", `{"session_id":"sess_1"}`, ErrCodeValidationFailed},
+		{"blank message", `{"message":"  \u0000 "}`, ErrCodeMessageEmpty},
+		{"too long", `{"message":"` + strings.Repeat("x", 21) + `"}`, ErrCodeMessageTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/projects/proj_1/chat", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+
+			var body map[string]string
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != http.StatusBadRequest || body["code"] != tt.wantCode {
+				t.Errorf("got %d %v, want 400 %s", w.Code, body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestBuildSystemMessageWithoutDocuments(t *testing.T) {
+	tests := []struct {
+		name, document, systemPrompt string
+		want, notWant                []string
+	}{
+		{"no document, no prompt", "", "",
+			[]string{"helpful assistant for this website", "say so politely"}, []string{"Document Content"}},
+		{"blank document counts as none", " \n\t ", "",
+			[]string{"helpful assistant for this website"}, []string{"Document Content"}},
+		{"no document, project prompt", "", "  You are Acme's support bot. ",
+			[]string{"You are Acme's support bot."}, []string{"Document Content", "helpful assistant"}},
+		{"document", "Opening hours: 9-5", "",
+			[]string{"You are a helpful assistant. Use the following document", "Opening hours: 9-5"}, nil},
+		{"document with project prompt", "Opening hours: 9-5", "You are Acme's support bot.",
+			[]string{"You are Acme's support bot. Use the following document", "Opening hours: 9-5"}, []string{"You are a helpful assistant."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildSystemMessage(tt.document, tt.systemPrompt, "")
+			if hasDocumentContext(tt.document) != strings.Contains(got, "Document Content") {
+				t.Errorf("hasDocumentContext(%q) disagrees with the prompt built", tt.document)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("prompt lacks %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("prompt contains %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildSystemMessageCantAnswer(t *testing.T) {
+	const message = "Sorry, our handbook doesn't cover that."
+	quoted := `"Sorry, our handbook doesn't cover that."`
+
+	tests := []struct {
+		name, document, systemPrompt string
+		want, notWant                []string
+	}{
+		{"document", "Opening hours: 9-5", "",
+			[]string{"reply with exactly this message and nothing else: " + quoted}, []string{"say so politely"}},
+		{"no document, no prompt", "", "",
+			[]string{"helpful assistant for this website", "Never guess", quoted}, []string{"say so politely"}},
+		{"no document, project prompt", "", "You are Acme's support bot.",
+			[]string{"You are Acme's support bot.\n\nIf you cannot answer a question", quoted}, []string{"helpful assistant"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildSystemMessage(tt.document, tt.systemPrompt, "  "+message+"  ")
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("prompt lacks %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("prompt contains %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+
+	// A blank message keeps the default wording
+	if got := buildSystemMessage("Opening hours: 9-5", "", "   "); !strings.Contains(got, "say so politely") || strings.Contains(got, "exactly this message") {
+		t.Errorf("blank can't-answer message changed the prompt:\n%s", got)
+	}
+}
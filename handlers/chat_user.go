@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/middleware"
+	"jevi-chat/models"
+)
+
+// chatUserTokenHeader - Widget requests prove a registered user with "Authorization: Bearer <token>"
+const chatUserTokenHeader = "Authorization"
+
+// chatUserFromRequest - The registered widget user behind this request. Only a chat_user token
+// issued for this project identifies one; a user_id in the body never does, so anonymous callers
+// can't borrow a registered user's CAPTCHA exemption or counters. Requests without a token return
+// nil without error.
+func chatUserFromRequest(c *gin.Context, project *models.Project) (*models.ChatUser, error) {
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader(chatUserTokenHeader), "Bearer "))
+	if token == "" {
+		return nil, nil
+	}
+	claims, err := middleware.ValidateChatUserToken(token, project.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	return loadChatUser(c.Request.Context(), project, claims.UserID)
+}
+
+// loadChatUser - Fetch a registered widget user of project; IDs that aren't ObjectIDs return nil
+// without error
+func loadChatUser(ctx context.Context, project *models.Project, userID string) (*models.ChatUser, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, nil
+	}
+
+	// Embed registrations store the project's ObjectID hex, widget chats its project_id
+	var user models.ChatUser
+	err = config.RetryRead(ctx, func(ctx context.Context) error {
+		return config.GetChatUsersCollection().FindOne(ctx, bson.M{
+			"_id":        objID,
+			"project_id": bson.M{"$in": []string{project.ProjectID, project.ID.Hex()}},
+		}).Decode(&user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// widgetUserID - user_id to record on messages and sessions. An authenticated user is recorded by
+// their ID; otherwise the widget's own identifier is kept unless it looks like a registered user's
+// ObjectID, which only a token may claim.
+func widgetUserID(requested string, user *models.ChatUser) string {
+	if user != nil {
+		return user.ID.Hex()
+	}
+	if primitive.IsValidObjectID(requested) {
+		return ""
+	}
+	return requested
+}
+
+// recordChatUserActivity - Apply one message (and optionally a new session) to a chat user's counters
+func recordChatUserActivity(user *models.ChatUser, tokensUsed int, newSession bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	inc := bson.M{
+		"total_messages": 1,
+		"total_tokens":   int64(tokensUsed),
+	}
+
+	user.IncrementMessage(int64(tokensUsed))
+	if newSession {
+		user.IncrementSession()
+		inc["total_sessions"] = 1
+	}
+
+	// $inc rather than $set so concurrent chats from the same user don't overwrite each other
+	_, err := config.GetChatUsersCollection().UpdateOne(ctx,
+		bson.M{"_id": user.ID, "project_id": user.ProjectID},
+		bson.M{
+			"$inc": inc,
+			"$set": bson.M{
+				"last_seen_at": user.LastSeenAt,
+				"updated_at":   user.UpdatedAt,
+			},
+		},
+	)
+	if err != nil {
+		log.Printf("❌ Failed to update chat user counters for %s: %v", user.ID.Hex(), err)
+	}
+}
+
+// GetProjectChatUsers - List registered widget users of a project with their activity counters
+func GetProjectChatUsers(c *gin.Context) {
+	page, limit := parsePagination(c, 50)
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	// Embed registrations store the project's ObjectID hex, widget chats its project_id
+	filter := bson.M{"project_id": bson.M{"$in": []string{project.ID.Hex(), project.ProjectID}}}
+
+	collection := config.GetChatUsersCollection()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count users")
+		return
+	}
+
+	cursor, err := collection.Find(ctx, filter,
+		options.Find().
+			SetSort(bson.M{"last_seen_at": -1}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"password": 0}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get users")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.ChatUser{}
+	if err := cursor.All(ctx, &users); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to decode users")
+		return
+	}
+
+	totalPages := pageCount(totalCount, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  totalPages,
+			"total_count":  totalCount,
+			"limit":        limit,
+		},
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/middleware"
+	"jevi-chat/models"
+)
+
+func TestWidgetUserID(t *testing.T) {
+	user := &models.ChatUser{ID: primitive.NewObjectID()}
+	otherID := primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name      string
+		requested string
+		user      *models.ChatUser
+		want      string
+	}{
+		{"authenticated user wins over body", otherID, user, user.ID.Hex()},
+		{"anonymous widget identifier kept", "visitor-42", nil, "visitor-42"},
+		{"anonymous claim to a registered id dropped", otherID, nil, ""},
+		{"empty stays empty", "", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := widgetUserID(tt.requested, tt.user); got != tt.want {
+				t.Errorf("widgetUserID(%q) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatUserFromRequestRequiresProjectToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("CHAT_USER_JWT_SECRET", "")
+
+	project := &models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_a"}
+	user := &models.ChatUser{ID: primitive.NewObjectID()}
+	otherProjectToken, err := middleware.GenerateChatUserToken(user, "proj_b")
+	if err != nil {
+		t.Fatalf("GenerateChatUserToken: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{"no token is anonymous", "", false},
+		{"token for another project", "Bearer " + otherProjectToken, true},
+		{"garbage token", "Bearer not-a-jwt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+
+			got, err := chatUserFromRequest(c, project)
+			if got != nil {
+				t.Errorf("expected no chat user, got %v", got.ID.Hex())
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadChatUserIgnoresNonObjectIDs(t *testing.T) {
+	project := &models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_a"}
+	for _, userID := range []string{"", "visitor-42"} {
+		user, err := loadChatUser(context.Background(), project, userID)
+		if user != nil || err != nil {
+			t.Errorf("loadChatUser(%q) = %v, %v; want nil, nil", userID, user, err)
+		}
+	}
+}
+
+func TestChatUserCountersAreScopedToProject(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	project := &models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_a"}
+	embedUser := &models.ChatUser{ID: primitive.NewObjectID(), ProjectID: project.ID.Hex(), Email: "embed@example.com", IsActive: true}
+	widgetUser := &models.ChatUser{ID: primitive.NewObjectID(), ProjectID: "proj_a", Email: "widget@example.com", IsActive: true}
+	otherUser := &models.ChatUser{ID: primitive.NewObjectID(), ProjectID: "proj_b", Email: "other@example.com", IsActive: true}
+	for _, user := range []*models.ChatUser{embedUser, widgetUser, otherUser} {
+		if _, err := config.GetChatUsersCollection().InsertOne(ctx, user); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	for _, user := range []*models.ChatUser{embedUser, widgetUser} {
+		if got, err := loadChatUser(ctx, project, user.ID.Hex()); err != nil || got.ID != user.ID {
+			t.Errorf("loadChatUser(%s) = %v, %v; want the project's user", user.Email, got, err)
+		}
+	}
+	if got, err := loadChatUser(ctx, project, otherUser.ID.Hex()); got != nil || err == nil {
+		t.Errorf("another project's user was loaded: %v, %v", got, err)
+	}
+
+	recordChatUserActivity(widgetUser, 40, true)
+	recordChatUserActivity(widgetUser, 10, false)
+
+	var stored models.ChatUser
+	if err := config.GetChatUsersCollection().FindOne(ctx, bson.M{"_id": widgetUser.ID}).Decode(&stored); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if stored.TotalMessages != 2 || stored.TotalTokens != 50 || stored.TotalSessions != 1 || stored.LastSeenAt.IsZero() {
+		t.Errorf("counters = %d messages, %d tokens, %d sessions, last seen %v; want 2, 50, 1 and a last seen time",
+			stored.TotalMessages, stored.TotalTokens, stored.TotalSessions, stored.LastSeenAt)
+	}
+
+	// A user carried over from another project must not be updated through this one
+	moved := *otherUser
+	moved.ProjectID = "proj_a"
+	recordChatUserActivity(&moved, 99, true)
+	if err := config.GetChatUsersCollection().FindOne(ctx, bson.M{"_id": otherUser.ID}).Decode(&stored); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if stored.TotalMessages != 0 || stored.TotalTokens != 0 {
+		t.Errorf("other project's user was updated: %+v", stored)
+	}
+}
+
+func TestGetProjectChatUsers(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	project := models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_a", Name: "Shop"}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	users := []interface{}{
+		bson.M{"project_id": project.ID.Hex(), "email": "embed@example.com", "password": "hash"},
+		bson.M{"project_id": "proj_a", "email": "widget@example.com", "password": "hash"},
+		bson.M{"project_id": "proj_b", "email": "other@example.com", "password": "hash"},
+	}
+	if _, err := config.GetChatUsersCollection().InsertMany(ctx, users); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/projects/:id/users", GetProjectChatUsers)
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+		wantCount  int
+	}{
+		{"by project_id", "proj_a", http.StatusOK, 2},
+		{"by ObjectID", project.ID.Hex(), http.StatusOK, 2},
+		{"unknown project", "proj_missing", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/"+tt.id+"/users", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				Users []map[string]interface{} `json:"users"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(body.Users) != tt.wantCount {
+				t.Errorf("%d users listed, want %d", len(body.Users), tt.wantCount)
+			}
+			if strings.Contains(w.Body.String(), "hash") {
+				t.Error("password hashes were returned")
+			}
+		})
+	}
+}
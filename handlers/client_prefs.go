@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+	"jevi-chat/utils"
+)
+
+// UpdateClientNotificationPrefs - PATCH /api/admin/clients/:clientId/notification-prefs
+// Only the fields sent are changed. Turning SMS alerts on again clears a previous STOP opt-out;
+// the phone number must be E.164 (e.g. +14155550100).
+func UpdateClientNotificationPrefs(c *gin.Context) {
+	var body struct {
+		EmailNotifications *bool   `json:"email_notifications"`
+		SMSNotifications   *bool   `json:"sms_notifications"`
+		ExpiryReminders    *bool   `json:"expiry_reminders"`
+		UsageAlerts        *bool   `json:"usage_alerts"`
+		MaintenanceUpdates *bool   `json:"maintenance_updates"`
+		DailyDigest        *bool   `json:"daily_digest"`
+		Phone              *string `json:"phone"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid notification preferences")
+		return
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	prefs := map[string]*bool{
+		"email_notifications": body.EmailNotifications,
+		"sms_notifications":   body.SMSNotifications,
+		"expiry_reminders":    body.ExpiryReminders,
+		"usage_alerts":        body.UsageAlerts,
+		"maintenance_updates": body.MaintenanceUpdates,
+		"daily_digest":        body.DailyDigest,
+	}
+	for field, value := range prefs {
+		if value != nil {
+			set["notification_prefs."+field] = *value
+		}
+	}
+	if body.SMSNotifications != nil && *body.SMSNotifications {
+		unset["sms_opted_out_at"] = ""
+	}
+	if body.Phone != nil {
+		phone := strings.TrimSpace(*body.Phone)
+		if phone != "" && !utils.IsE164(phone) {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "phone must be in E.164 format, e.g. +14155550100")
+			return
+		}
+		set["phone"] = phone
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var client models.Client
+	err := config.GetClientsCollection().FindOneAndUpdate(ctx,
+		bson.M{"client_id": c.Param("clientId")},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&client)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeClientNotFound, "Client not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update notification preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client_id":          client.ClientID,
+		"phone":              client.Phone,
+		"notification_prefs": client.NotificationPrefs,
+		"sms_opted_out":      !client.SMSOptedOutAt.IsZero(),
+	})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func patchNotificationPrefs(clientID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PATCH("/clients/:clientId/notification-prefs", UpdateClientNotificationPrefs)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/clients/"+clientID+"/notification-prefs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateClientNotificationPrefsValidatesInput(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"malformed", `{"daily_digest":`, "Invalid notification preferences"},
+		{"wrong type", `{"daily_digest":"yes"}`, "Invalid notification preferences"},
+		{"local phone format", `{"phone":"07911 123456"}`, "phone must be in E.164 format"},
+		{"missing plus", `{"phone":"447911123456"}`, "phone must be in E.164 format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Refused before the client is looked up, so no database is needed
+			w := patchNotificationPrefs("client_1", tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("got %d %s, want 400 %q", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateClientNotificationPrefs(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	client := models.Client{
+		ClientID: "client_1", Phone: "+447911123456", SMSOptedOutAt: time.Now(),
+		NotificationPrefs: models.NotificationPrefs{EmailNotifications: true, UsageAlerts: true},
+	}
+	if _, err := config.GetClientsCollection().InsertOne(ctx, client); err != nil {
+		t.Fatalf("insert client: %v", err)
+	}
+
+	var resp struct {
+		Phone       string                   `json:"phone"`
+		Prefs       models.NotificationPrefs `json:"notification_prefs"`
+		SMSOptedOut bool                     `json:"sms_opted_out"`
+	}
+
+	// Only the fields sent change
+	w := patchNotificationPrefs("client_1", `{"daily_digest":true,"usage_alerts":false}`)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || !resp.Prefs.DailyDigest || resp.Prefs.UsageAlerts || !resp.Prefs.EmailNotifications {
+		t.Errorf("got %d %+v, want the digest on, usage alerts off and email untouched", w.Code, resp)
+	}
+	if !resp.SMSOptedOut || resp.Phone != "+447911123456" {
+		t.Errorf("response = %+v, want the opt-out and phone untouched", resp)
+	}
+
+	// Turning SMS back on clears the STOP opt-out
+	w = patchNotificationPrefs("client_1", `{"sms_notifications":true,"phone":" +14155550100 "}`)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || !resp.Prefs.SMSNotifications || resp.SMSOptedOut || resp.Phone != "+14155550100" {
+		t.Errorf("got %d %+v, want SMS re-enabled with the new number", w.Code, resp)
+	}
+	var stored bson.M
+	config.GetClientsCollection().FindOne(ctx, bson.M{"client_id": "client_1"}).Decode(&stored)
+	if _, ok := stored["sms_opted_out_at"]; ok {
+		t.Error("sms_opted_out_at still stored after SMS was re-enabled")
+	}
+
+	if w := patchNotificationPrefs("client_missing", `{"daily_digest":true}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown client: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestContext - Context for a handler's database and API calls. The deadline comes from
+// the route's middleware.Timeout and the context is cancelled as soon as the client
+// disconnects, so abandoned requests stop doing work. Writes that must complete regardless
+// (token accounting, background jobs) use context.Background instead.
+func requestContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(c.Request.Context())
+}
+
+// requestTimedOut - Whether the route's deadline has passed
+func requestTimedOut(c *gin.Context) bool {
+	return c.Request.Context().Err() == context.DeadlineExceeded
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestContextFollowsTheRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	parent, cancelRequest := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelRequest()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(parent)
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("deadline = %v %v, want the request's deadline", deadline, ok)
+	}
+
+	// A client disconnect cancels the request context, and with it the handler's work
+	cancelRequest()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not cancelled with the request")
+	}
+	if requestTimedOut(c) {
+		t.Error("a cancelled request is not a timeout")
+	}
+}
+
+func TestRequestContextCancelLeavesRequestAlive(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ctx, cancel := requestContext(c)
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("ctx.Err() = %v, want Canceled", ctx.Err())
+	}
+	if c.Request.Context().Err() != nil {
+		t.Error("cancelling one call's context should not cancel the request")
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// Conversation memory defaults (overridable via CHAT_HISTORY_* env vars)
+const (
+	defaultHistoryTurns       = 6    // recent turns sent verbatim
+	defaultHistoryTokenBudget = 1500 // estimated tokens of verbatim history before summarizing
+	defaultSummaryModel       = "gpt-4o-mini"
+
+	// maxUnsummarizedTurns caps how many turns are loaded when a session has no summary yet
+	maxUnsummarizedTurns = 200
+)
+
+// conversationHistory - Prior context for the next model call: a running summary of older
+// turns plus the most recent turns verbatim
+type conversationHistory struct {
+	Summary string
+	Turns   []models.ChatMessage
+
+	// SummaryTokens - Tokens spent condensing older turns during this request
+	SummaryTokens int
+}
+
+// loadConversationHistory - Prior turns of a session. Once the unsummarized turns exceed
+// CHAT_HISTORY_TURNS or CHAT_HISTORY_TOKEN_BUDGET, everything but the most recent turns is
+// folded into the session's running summary, which then stands in for those turns.
+func loadConversationHistory(ctx context.Context, projectID, sessionID string) conversationHistory {
+	var session models.WidgetSession
+	err := config.GetWidgetSessionsCollection().FindOne(ctx,
+		bson.M{"session_id": sessionID, "project_id": projectID},
+		options.FindOne().SetProjection(bson.M{"summary": 1, "summarized_through": 1}),
+	).Decode(&session)
+	if err != nil {
+		return conversationHistory{}
+	}
+
+	filter := bson.M{"project_id": projectID, "session_id": sessionID}
+	if !session.SummarizedThrough.IsZero() {
+		filter["created_at"] = bson.M{"$gt": session.SummarizedThrough}
+	}
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetLimit(maxUnsummarizedTurns).
+		SetProjection(bson.M{"message": 1, "response": 1, "greeting": 1, "created_at": 1})
+
+	cursor, err := config.GetChatMessagesCollection().Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("⚠️ Failed to load history for session %s: %v", sessionID, err)
+		return conversationHistory{Summary: session.Summary}
+	}
+	var turns []models.ChatMessage
+	if err := cursor.All(ctx, &turns); err != nil {
+		log.Printf("⚠️ Failed to decode history for session %s: %v", sessionID, err)
+		return conversationHistory{Summary: session.Summary}
+	}
+	// Oldest first
+	for i, j := 0, len(turns)-1; i < j; i, j = i+1, j-1 {
+		turns[i], turns[j] = turns[j], turns[i]
+	}
+
+	history := conversationHistory{Summary: session.Summary, Turns: turns}
+
+	keep := envInt("CHAT_HISTORY_TURNS", defaultHistoryTurns)
+	if len(turns) <= keep && estimateTurnTokens(turns) <= envInt("CHAT_HISTORY_TOKEN_BUDGET", defaultHistoryTokenBudget) {
+		return history
+	}
+
+	keep = min(keep, len(turns)-1)
+	older, recent := turns[:len(turns)-keep], turns[len(turns)-keep:]
+
+	summary, tokens, err := summarizeTurns(ctx, session.Summary, older)
+	if err != nil {
+		// Keep the prompt bounded: drop the older turns rather than send them raw
+		log.Printf("⚠️ Failed to summarize session %s: %v", sessionID, err)
+		history.Turns = recent
+		return history
+	}
+
+	_, err = config.GetWidgetSessionsCollection().UpdateOne(ctx,
+		bson.M{"session_id": sessionID, "project_id": projectID},
+		bson.M{"$set": bson.M{
+			"summary":            summary,
+			"summarized_through": older[len(older)-1].CreatedAt,
+		}},
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to store summary for session %s: %v", sessionID, err)
+	}
+
+	log.Printf("📝 Summarized %d turns of session %s", len(older), sessionID)
+	return conversationHistory{Summary: summary, Turns: recent, SummaryTokens: tokens}
+}
+
+// summarizeTurns - Fold turns into the previous summary with a short model call
+func summarizeTurns(ctx context.Context, previous string, turns []models.ChatMessage) (string, int, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Summary so far:\n")
+		transcript.WriteString(previous)
+		transcript.WriteString("\n\nNew turns:\n")
+	}
+	for _, turn := range turns {
+		if !turn.Greeting {
+			fmt.Fprintf(&transcript, "Visitor: %s\n", turn.Message)
+		}
+		fmt.Fprintf(&transcript, "Assistant: %s\n", turn.Response)
+	}
+
+	model := os.Getenv("CHAT_SUMMARY_MODEL")
+	if model == "" {
+		model = defaultSummaryModel
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleSystem,
+				Content: "Condense this support chat into a brief summary for the assistant's memory. " +
+					"Keep the visitor's questions, stated details (names, orders, preferences) and any answers or " +
+					"commitments given. Write plain prose under 150 words.",
+			},
+			{Role: openai.ChatMessageRoleUser, Content: transcript.String()},
+		},
+		MaxTokens:   250,
+		Temperature: 0.2,
+	}
+
+	client, err := newOpenAIClient()
+	if err != nil {
+		return "", 0, err
+	}
+	estimated := estimateChatTokens(req.Messages, req.MaxTokens)
+	if err := waitForOpenAI(ctx, estimated); err != nil {
+		return "", 0, err
+	}
+	resp, err := client.CreateChatCompletion(ctx, req)
+	settleOpenAI(estimated, resp.Usage.TotalTokens, err)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(resp.Choices) == 0 {
+		return "", 0, fmt.Errorf("no summary generated")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), resp.Usage.TotalTokens, nil
+}
+
+// historyMessages - Summary and prior turns as chat messages, to go between the system prompt
+// and the new visitor message
+func historyMessages(history conversationHistory) []openai.ChatCompletionMessage {
+	var messages []openai.ChatCompletionMessage
+	if history.Summary != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "Summary of the earlier conversation:\n" + history.Summary,
+		})
+	}
+	for _, turn := range history.Turns {
+		// A greeting is an assistant message with no visitor message before it
+		if !turn.Greeting {
+			messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: turn.Message})
+		}
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: turn.Response})
+	}
+	return messages
+}
+
+// estimateTurnTokens - Token count of turns (see countTokens)
+func estimateTurnTokens(turns []models.ChatMessage) int {
+	tokens := 0
+	for _, turn := range turns {
+		tokens += countTokens(turn.Message) + countTokens(turn.Response)
+	}
+	return tokens
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestHistoryMessages(t *testing.T) {
+	history := conversationHistory{
+		Summary: "Visitor asked about order 42.",
+		Turns: []models.ChatMessage{
+			{Greeting: true, Response: "Hi! How can I help?"},
+			{Message: "Where is my order?", Response: "It ships tomorrow."},
+		},
+	}
+
+	messages := historyMessages(history)
+	want := []struct{ role, content string }{
+		{openai.ChatMessageRoleSystem, "Summary of the earlier conversation:\nVisitor asked about order 42."},
+		{openai.ChatMessageRoleAssistant, "Hi! How can I help?"},
+		{openai.ChatMessageRoleUser, "Where is my order?"},
+		{openai.ChatMessageRoleAssistant, "It ships tomorrow."},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("got %d messages %+v, want %d", len(messages), messages, len(want))
+	}
+	for i, w := range want {
+		if messages[i].Role != w.role || messages[i].Content != w.content {
+			t.Errorf("message %d = %s %q, want %s %q", i, messages[i].Role, messages[i].Content, w.role, w.content)
+		}
+	}
+
+	if messages := historyMessages(conversationHistory{}); len(messages) != 0 {
+		t.Errorf("empty history = %+v, want no messages", messages)
+	}
+}
+
+func TestEstimateTurnTokens(t *testing.T) {
+	turns := []models.ChatMessage{
+		{Message: "Where is my order?", Response: "It ships tomorrow."},
+		{Greeting: true, Response: "Hi!"},
+	}
+	want := countTokens("Where is my order?") + countTokens("It ships tomorrow.") + countTokens("") + countTokens("Hi!")
+	if got := estimateTurnTokens(turns); got != want || got == 0 {
+		t.Errorf("estimateTurnTokens = %d, want %d", got, want)
+	}
+}
+
+func TestSummarizeTurns(t *testing.T) {
+	var transcript, model string
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		model, transcript = req.Model, req.Messages[len(req.Messages)-1].Content
+		json.NewEncoder(w).Encode(completion(openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "  Asked about order 42.  "}, 80))
+	})
+	t.Setenv("CHAT_SUMMARY_MODEL", "")
+
+	turns := []models.ChatMessage{
+		{Greeting: true, Response: "Hi!"},
+		{Message: "Where is order 42?", Response: "It ships tomorrow."},
+	}
+	summary, tokens, err := summarizeTurns(context.Background(), "Visitor is Sam.", turns)
+	if err != nil {
+		t.Fatalf("summarizeTurns: %v", err)
+	}
+	if summary != "Asked about order 42." || tokens != 80 {
+		t.Errorf("summary = %q (%d tokens), want the trimmed reply and reported usage", summary, tokens)
+	}
+	if model != defaultSummaryModel {
+		t.Errorf("model = %q, want %q by default", model, defaultSummaryModel)
+	}
+	for _, want := range []string{"Summary so far:\nVisitor is Sam.", "Assistant: Hi!", "Visitor: Where is order 42?"} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("transcript %q should contain %q", transcript, want)
+		}
+	}
+	if strings.Count(transcript, "Visitor:") != 1 {
+		t.Errorf("transcript %q should not add a visitor line for the greeting", transcript)
+	}
+}
+
+func TestSummarizeTurnsWithoutChoices(t *testing.T) {
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{Object: "chat.completion"})
+	})
+
+	if _, _, err := summarizeTurns(context.Background(), "", []models.ChatMessage{{Message: "Hi", Response: "Hello"}}); err == nil {
+		t.Error("summarizeTurns should fail when the model returns no choices")
+	}
+}
+
+func TestLoadConversationHistory(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("CHAT_HISTORY_TURNS", "2")
+	t.Setenv("CHAT_HISTORY_TOKEN_BUDGET", "100000")
+
+	summarizeFails := false
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		if summarizeFails {
+			http.Error(w, `{"error":{"message":"unavailable"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(completion(openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "Earlier turns."}, 60))
+	})
+
+	const projectID = "proj_memory"
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	addTurns := func(sessionID string, n int) {
+		for i := 0; i < n; i++ {
+			config.GetChatMessagesCollection().InsertOne(ctx, models.ChatMessage{
+				ProjectID: projectID, SessionID: sessionID,
+				Message: fmt.Sprintf("question %d", i), Response: fmt.Sprintf("answer %d", i),
+				CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			})
+		}
+	}
+	for _, sessionID := range []string{"sess_short", "sess_long", "sess_failing"} {
+		config.GetWidgetSessionsCollection().InsertOne(ctx, models.WidgetSession{ProjectID: projectID, SessionID: sessionID})
+	}
+
+	if history := loadConversationHistory(ctx, projectID, "sess_unknown"); len(history.Turns) != 0 || history.Summary != "" {
+		t.Errorf("unknown session history = %+v, want empty", history)
+	}
+
+	addTurns("sess_short", 2)
+	history := loadConversationHistory(ctx, projectID, "sess_short")
+	if len(history.Turns) != 2 || history.Turns[0].Message != "question 0" || history.Summary != "" || history.SummaryTokens != 0 {
+		t.Errorf("short history = %+v, want both turns oldest first and no summary", history)
+	}
+
+	addTurns("sess_long", 5)
+	history = loadConversationHistory(ctx, projectID, "sess_long")
+	if history.Summary != "Earlier turns." || history.SummaryTokens != 60 || len(history.Turns) != 2 || history.Turns[0].Message != "question 3" {
+		t.Fatalf("long history = %+v, want a summary and the last two turns", history)
+	}
+	var session models.WidgetSession
+	config.GetWidgetSessionsCollection().FindOne(ctx, bson.M{"session_id": "sess_long"}).Decode(&session)
+	if session.Summary != "Earlier turns." || !session.SummarizedThrough.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("stored summary = %q through %v, want it through the third turn", session.Summary, session.SummarizedThrough)
+	}
+
+	// The stored summary now stands in for the summarized turns
+	history = loadConversationHistory(ctx, projectID, "sess_long")
+	if history.Summary != "Earlier turns." || history.SummaryTokens != 0 || len(history.Turns) != 2 {
+		t.Errorf("reloaded history = %+v, want the stored summary and two turns", history)
+	}
+
+	summarizeFails = true
+	addTurns("sess_failing", 4)
+	history = loadConversationHistory(ctx, projectID, "sess_failing")
+	if history.Summary != "" || len(history.Turns) != 2 || history.Turns[0].Message != "question 2" {
+		t.Errorf("history after a failed summary = %+v, want only the recent turns", history)
+	}
+}
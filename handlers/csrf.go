@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/middleware"
+)
+
+// GetCSRFToken - GET /api/auth/csrf
+// Issues the CSRF cookie; frontends authenticating with the auth cookie send the returned token
+// back in the X-CSRF-Token header on every POST, PUT, PATCH and DELETE.
+func GetCSRFToken(c *gin.Context) {
+	token, err := middleware.IssueCSRFToken(c)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to issue CSRF token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"csrf_token": token,
+		"header":     middleware.CSRFHeaderName,
+	})
+}
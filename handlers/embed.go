@@ -1,55 +1,60 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
-
+	"log"
 	"net/http"
+	"net/mail"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"jevi-chat/config"
-	"jevi-chat/models"
 	"jevi-chat/middleware"
+	"jevi-chat/models"
+	"jevi-chat/utils"
 )
 
 // EmbedChat - GET /embed/:projectId
 func EmbedChat(c *gin.Context) {
 	projectID := c.Param("projectId")
 
-	userToken := c.Query("token")
-	if userToken == "" {
-		// No token, show pre-auth UI
-		c.HTML(http.StatusOK, "prechat.html", gin.H{
-			"project_id": projectID,
-			"api_url":    os.Getenv("APP_URL"),
-		})
+	// Fetch project from DB (project_id or ObjectID)
+	project, err := findProjectByAnyID(c.Request.Context(), projectID)
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{"error": "Project not found"})
 		return
 	}
 
-	// Validate project ID
-	objID, err := primitive.ObjectIDFromHex(projectID)
-	if err != nil {
-		c.HTML(http.StatusOK, "error.html", gin.H{"error": "Invalid project ID"})
+	// Expired or suspended projects get an explanation instead of a chat that can't answer
+	if err := checkProjectSubscription(project); err != nil {
+		c.HTML(http.StatusForbidden, "error.html", gin.H{"error": err.Error()})
 		return
 	}
 
-	// Fetch project from DB
-	projectCollection := config.GetCollection("projects")
-	var project models.Project
-	err = projectCollection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&project)
-	if err != nil || !project.IsActive {
-		c.HTML(http.StatusOK, "error.html", gin.H{"error": "Project not found or inactive"})
+	userToken := c.Query("token")
+	if userToken == "" {
+		// No token, show pre-auth UI
+		c.HTML(http.StatusOK, "prechat.html", gin.H{
+			"project":             project,
+			"project_id":          project.ProjectID,
+			"api_url":             os.Getenv("APP_URL"),
+			"password_min_length": chatUserPasswordMinLength(),
+		})
 		return
 	}
 
 	// Validate token using middleware function
-	claims, err := validateUserToken(userToken)
+	claims, err := validateUserToken(userToken, project)
 	if err != nil {
+		log.Printf("⚠️ Rejected embed token for project %s: %v", project.ProjectID, err)
 		c.Redirect(http.StatusFound, fmt.Sprintf("/embed/%s", projectID))
 		return
 	}
@@ -66,7 +71,10 @@ func EmbedChat(c *gin.Context) {
 		return
 	}
 
-	err = userCollection.FindOne(context.Background(), bson.M{"_id": userObjID}).Decode(&user)
+	err = userCollection.FindOne(c.Request.Context(), bson.M{
+		"_id":        userObjID,
+		"project_id": bson.M{"$in": []string{project.ProjectID, project.ID.Hex()}},
+	}).Decode(&user)
 	if err != nil {
 		c.Redirect(http.StatusFound, fmt.Sprintf("/embed/%s", projectID))
 		return
@@ -75,13 +83,69 @@ func EmbedChat(c *gin.Context) {
 	// Render chat UI
 	c.HTML(http.StatusOK, "chat.html", gin.H{
 		"project":    project,
-		"project_id": projectID,
+		"project_id": project.ProjectID,
 		"api_url":    os.Getenv("APP_URL"),
 		"user":       user,
 		"user_token": userToken,
 	})
 }
 
+// Embed sign-up defaults: hourly throttles (EMBED_REGISTER_PER_*_HOUR) and password policy
+const (
+	defaultRegisterPerIPHour      = 5
+	defaultRegisterPerProjectHour = 100
+
+	defaultChatUserPasswordMinLength = 8
+
+	embedRegisteredMessage = "Registration received. Please sign in with your email and password."
+
+	// embedUnavailableMessage - Same answer for unknown and inactive projects, so public embed
+	// endpoints don't reveal which project ids exist
+	embedUnavailableMessage = "This chat is currently unavailable"
+)
+
+// envInt - Positive integer from env, or the default
+func envInt(key string, defaultValue int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return defaultValue
+}
+
+// chatUserPasswordMinLength - Shortest widget user password (CHAT_USER_PASSWORD_MIN_LENGTH); the
+// pre-chat page checks the same minimum before submitting
+func chatUserPasswordMinLength() int {
+	return envInt("CHAT_USER_PASSWORD_MIN_LENGTH", defaultChatUserPasswordMinLength)
+}
+
+// validateChatUserPassword - Enforce the widget user password policy
+// (CHAT_USER_PASSWORD_MIN_LENGTH, CHAT_USER_PASSWORD_REQUIRE_COMPLEXITY)
+func validateChatUserPassword(password string) error {
+	minLength := chatUserPasswordMinLength()
+	if utf8.RuneCountInString(password) < minLength {
+		return fmt.Errorf("Password must be at least %d characters", minLength)
+	}
+
+	if os.Getenv("CHAT_USER_PASSWORD_REQUIRE_COMPLEXITY") == "true" {
+		var hasUpper, hasLower, hasDigit bool
+		for _, r := range password {
+			switch {
+			case unicode.IsUpper(r):
+				hasUpper = true
+			case unicode.IsLower(r):
+				hasLower = true
+			case unicode.IsDigit(r):
+				hasDigit = true
+			}
+		}
+		if !hasUpper || !hasLower || !hasDigit {
+			return fmt.Errorf("Password must contain upper-case and lower-case letters and a number")
+		}
+	}
+
+	return nil
+}
+
 // EmbedAuth - POST /embed/:projectId/auth
 func EmbedAuth(c *gin.Context) {
 	projectID := c.Param("projectId")
@@ -98,44 +162,63 @@ func EmbedAuth(c *gin.Context) {
 		return
 	}
 
-	// Validate project
-	objID, err := primitive.ObjectIDFromHex(projectID)
+	// Validate project (project_id or ObjectID)
+	project, err := findProjectByAnyID(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid project"})
-		return
-	}
-
-	projectCollection := config.GetCollection("projects")
-	var project models.Project
-	if err := projectCollection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&project); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Project not found"})
 		return
 	}
 
+	// Users registered before ids were unified carry the project's ObjectID hex
+	projectFilter := bson.M{"$in": []string{project.ProjectID, project.ID.Hex()}}
+
 	userCollection := config.GetCollection("chat_users")
 
 	if authData.Mode == "register" {
-		// Check if user exists
-		var existingUser models.ChatUser
-		err := userCollection.FindOne(context.Background(), bson.M{
-			"project_id": projectID,
-			"email":      authData.Email,
-		}).Decode(&existingUser)
-		if err == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Email already registered"})
+		// Throttle sign-ups per IP and per project to stop scripted account creation
+		clientIP := c.ClientIP()
+		if !middleware.AllowRequest("embed_register:ip:"+project.ProjectID+":"+clientIP, envInt("EMBED_REGISTER_PER_IP_HOUR", defaultRegisterPerIPHour), time.Hour) ||
+			!middleware.AllowRequest("embed_register:project:"+project.ProjectID, envInt("EMBED_REGISTER_PER_PROJECT_HOUR", defaultRegisterPerProjectHour), time.Hour) {
+			log.Printf("🚫 Embed registration throttled for project %s from %s", project.ProjectID, clientIP)
+			c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "message": "Too many sign-up attempts. Please try again later."})
+			return
+		}
+
+		authData.Email = strings.ToLower(strings.TrimSpace(authData.Email))
+		if os.Getenv("EMBED_VALIDATE_EMAIL") != "false" {
+			if addr, err := mail.ParseAddress(authData.Email); err != nil || addr.Address != authData.Email {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Please enter a valid email address"})
+				return
+			}
+		}
+
+		if err := validateChatUserPassword(authData.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error(), "code": ErrCodeWeakPassword})
 			return
 		}
 
-		// Hash password using middleware function
+		// Hash before the lookup so new and existing emails take the same time
 		hashedPassword, err := middleware.HashPassword(authData.Password)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to process password"})
 			return
 		}
 
+		// New and existing emails get the same answer and neither gets a token, so the endpoint
+		// can't be used to discover which emails have accounts; the widget signs in next.
+		var existingUser models.ChatUser
+		err = userCollection.FindOne(c.Request.Context(), bson.M{
+			"project_id": projectFilter,
+			"email":      authData.Email,
+		}).Decode(&existingUser)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{"success": true, "message": embedRegisteredMessage})
+			return
+		}
+
 		// Create new user
 		user := models.ChatUser{
-			ProjectID:     projectID,
+			ProjectID:     project.ProjectID,
 			Name:          authData.Name,
 			Email:         authData.Email,
 			Password:      hashedPassword,
@@ -147,45 +230,21 @@ func EmbedAuth(c *gin.Context) {
 			UpdatedAt:     time.Now(),
 		}
 
-		result, err := userCollection.InsertOne(context.Background(), user)
-		if err != nil {
+		if _, err := userCollection.InsertOne(c.Request.Context(), user); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to create user"})
 			return
 		}
 
-		user.ID = result.InsertedID.(primitive.ObjectID)
-		
-		// Create a temporary User object for token generation
-		tempUser := &models.User{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-			Role:  "chat_user",
-		}
-		
-		token, err := middleware.GenerateJWTToken(tempUser)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to generate token"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"user": gin.H{
-				"id":    user.ID.Hex(),
-				"name":  user.Name,
-				"email": user.Email,
-			},
-			"token": token,
-		})
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": embedRegisteredMessage})
 		return
 	}
 
-	// Login
+	// Login (new accounts store lower-cased emails; older ones may not)
+	email := strings.TrimSpace(authData.Email)
 	var user models.ChatUser
-	err = userCollection.FindOne(context.Background(), bson.M{
-		"project_id": projectID,
-		"email":      authData.Email,
+	err = userCollection.FindOne(c.Request.Context(), bson.M{
+		"project_id": projectFilter,
+		"email":      bson.M{"$in": []string{email, strings.ToLower(email)}},
 	}).Decode(&user)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid credentials"})
@@ -203,15 +262,7 @@ func EmbedAuth(c *gin.Context) {
 		return
 	}
 
-	// Create a temporary User object for token generation
-	tempUser := &models.User{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
-		Role:  "chat_user",
-	}
-	
-	token, err := middleware.GenerateJWTToken(tempUser)
+	token, err := middleware.GenerateChatUserToken(&user, project.ProjectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to generate token"})
 		return
@@ -232,88 +283,147 @@ func EmbedAuth(c *gin.Context) {
 func IframeChatInterface(c *gin.Context) {
 	projectID := c.Param("projectId")
 
-	objID, err := primitive.ObjectIDFromHex(projectID)
-	if err != nil {
-		c.String(http.StatusBadRequest, "Invalid project ID")
-		return
-	}
-
-	var project models.Project
-	err = config.GetCollection("projects").FindOne(context.Background(), bson.M{"_id": objID}).Decode(&project)
+	project, err := findProjectByAnyID(c.Request.Context(), projectID)
 	if err != nil {
-		c.String(http.StatusNotFound, "Project not found")
+		c.HTML(http.StatusNotFound, "error.html", gin.H{"error": "Project not found"})
 		return
 	}
 
-	// Check if project is active
-	if !project.IsActive {
-		c.String(http.StatusForbidden, "Project is inactive")
+	if err := checkProjectSubscription(project); err != nil {
+		c.HTML(http.StatusForbidden, "error.html", gin.H{"error": err.Error()})
 		return
 	}
 
 	// Render the chat.html template
-	c.HTML(http.StatusOK, "embed/chat.html", gin.H{
+	c.HTML(http.StatusOK, "chat.html", gin.H{
 		"project":    project,
-		"project_id": project.ID.Hex(),
+		"project_id": project.ProjectID,
 		"api_url":    os.Getenv("APP_URL"),
 	})
 }
 
-// EmbedHealth - Simple health check
-func EmbedHealth(c *gin.Context) {
+// EmbedConfig - GET /embed/:projectId/config
+// Headless counterpart of the embed HTML pages: everything a JS widget needs to render itself.
+func EmbedConfig(c *gin.Context) {
+	project, err := findProjectByAnyID(c.Request.Context(), c.Param("projectId"))
+	if err == nil {
+		err = checkProjectSubscription(project)
+	}
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectUnavailable, embedUnavailableMessage)
+		return
+	}
+
+	var captcha gin.H
+	if project.WidgetSettings.RequireCaptcha && utils.CaptchaConfigured() {
+		captcha = gin.H{"provider": utils.CaptchaProvider(), "site_key": utils.CaptchaSiteKey()}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"service":   "troika-tech-embed",
-		"timestamp": time.Now().Format(time.RFC3339),
+		"project_id":        project.ProjectID,
+		"name":              project.Name,
+		"widget":            widgetInitConfig(project.ProjectID, project.WidgetSettings),
+		"greeting":          widgetGreeting(project.ProjectID, project.WidgetSettings),
+		"require_auth":      project.WidgetSettings.RequireAuth,
+		"captcha_required":  captcha != nil,
+		"captcha":           captcha,
+		"enable_rating":     project.WidgetSettings.EnableRating,
+		"collect_user_info": project.WidgetSettings.CollectUserInfo,
+		"chat_paused":       project.ChatPaused,
+		"schedule":          scheduleStatus(project.WidgetSettings.Schedule, time.Now()),
+		"api_url":           os.Getenv("APP_URL"),
+		"auth_url":          fmt.Sprintf("/api/embed/%s/auth", project.ProjectID),
+		"chat_url":          fmt.Sprintf("/api/projects/%s/chat", project.ProjectID),
+		"lead_url":          fmt.Sprintf("/api/projects/%s/lead", project.ProjectID),
 	})
 }
 
+// embedAPIVersion - Bumped when the widget-facing API changes shape
+const embedAPIVersion = "1.1"
+
+// EmbedHealth - GET /api/embed/health (alias /api/embed/version)
+// Lets the widget feature-detect and, with ?project_id=, check the project can be served.
+func EmbedHealth(c *gin.Context) {
+	status := "healthy"
+	if config.Client == nil {
+		status = "degraded"
+	} else {
+		ctx, cancel := requestContext(c)
+		if err := config.Client.Ping(ctx, nil); err != nil {
+			log.Printf("⚠️ Embed health: database ping failed: %v", err)
+			status = "degraded"
+		}
+		cancel()
+	}
+
+	response := gin.H{
+		"status":         status,
+		"service":        "troika-tech-embed",
+		"api_version":    embedAPIVersion,
+		"widget_version": widgetScriptVersion(),
+		"widget_url":     WidgetScriptURL(),
+		"features":       embedFeatures(),
+		"timestamp":      time.Now().Format(time.RFC3339),
+	}
+
+	if projectID := c.Query("project_id"); projectID != "" {
+		project := gin.H{"project_id": projectID, "servable": false}
+
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		if status != "healthy" {
+			project["reason"] = "Service temporarily unavailable"
+		} else if p, err := findProjectByAnyID(ctx, projectID); err != nil {
+			project["reason"] = embedUnavailableMessage
+		} else if err := checkProjectSubscription(p); err != nil {
+			project["reason"] = embedUnavailableMessage
+		} else {
+			project["project_id"] = p.ProjectID
+			project["servable"] = true
+		}
+
+		response["project"] = project
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// embedFeatures - Widget capabilities this backend supports
+func embedFeatures() []string {
+	features := []string{"chat", "sessions", "visitor_tokens", "widget_auth", "quick_actions", "rating", "greeting", "lead_capture", "business_hours"}
+	if utils.CaptchaConfigured() {
+		features = append(features, "captcha")
+	}
+	return features
+}
+
 // ShowEmbedAuth - GET /embed/:projectId/auth
 func ShowEmbedAuth(c *gin.Context) {
 	projectID := c.Param("projectId")
 
-	// Validate project ID
-	objID, err := primitive.ObjectIDFromHex(projectID)
-	if err != nil {
-		c.HTML(http.StatusOK, "error.html", gin.H{"error": "Invalid project ID"})
-		return
-	}
-
-	// Get project details
-	collection := config.GetCollection("projects")
-	var project models.Project
-	err = collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&project)
+	// Get project details (project_id or ObjectID)
+	project, err := findProjectByAnyID(c.Request.Context(), projectID)
 	if err != nil {
-		c.HTML(http.StatusOK, "error.html", gin.H{"error": "Project not found"})
+		c.HTML(http.StatusNotFound, "error.html", gin.H{"error": "Project not found"})
 		return
 	}
 
-	// Check if project is active
-	if !project.IsActive {
-		c.HTML(http.StatusOK, "error.html", gin.H{"error": "Project is inactive"})
+	if err := checkProjectSubscription(project); err != nil {
+		c.HTML(http.StatusForbidden, "error.html", gin.H{"error": err.Error()})
 		return
 	}
 
 	// Render authentication page
-	c.HTML(http.StatusOK, "embed/auth.html", gin.H{
-		"project":    project,
-		"project_id": projectID,
-		"api_url":    os.Getenv("APP_URL"),
+	c.HTML(http.StatusOK, "prechat.html", gin.H{
+		"project":             project,
+		"project_id":          project.ProjectID,
+		"api_url":             os.Getenv("APP_URL"),
+		"password_min_length": chatUserPasswordMinLength(),
 	})
 }
 
-// validateUserToken - Validates JWT token and returns claims (uses middleware validation)
-func validateUserToken(tokenString string) (*middleware.JWTClaims, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return nil, fmt.Errorf("JWT secret not configured")
-	}
-
-	// Use the same validation logic as middleware but return the claims directly
-	claims, err := middleware.ValidateJWTToken(tokenString)
-	if err != nil {
-		return nil, err
-	}
-
-	return claims, nil
+// validateUserToken - Validates a chat user JWT and checks it was issued for this project
+func validateUserToken(tokenString string, project *models.Project) (*middleware.JWTClaims, error) {
+	return middleware.ValidateChatUserToken(tokenString, project.ProjectID)
 }
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+func TestEmbedHealthWithoutDatabase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Chdir("..") // the widget version hashes static/widget.js
+	t.Setenv("CAPTCHA_PROVIDER", "")
+
+	previous := config.Client
+	config.Client = nil
+	t.Cleanup(func() { config.Client = previous })
+
+	r := gin.New()
+	r.GET("/api/embed/health", EmbedHealth)
+
+	tests := []struct {
+		name, query string
+		wantProject bool
+	}{
+		{"service only", "", false},
+		{"with a project", "?project_id=proj_1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/embed/health"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+
+			var resp struct {
+				Status        string   `json:"status"`
+				APIVersion    string   `json:"api_version"`
+				WidgetVersion string   `json:"widget_version"`
+				Features      []string `json:"features"`
+				Project       *struct {
+					ProjectID string `json:"project_id"`
+					Servable  bool   `json:"servable"`
+					Reason    string `json:"reason"`
+				} `json:"project"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Status != "degraded" || resp.APIVersion != embedAPIVersion || resp.WidgetVersion != widgetScriptVersion() {
+				t.Errorf("health = %+v", resp)
+			}
+			for _, feature := range resp.Features {
+				if feature == "captcha" {
+					t.Error("captcha advertised without a configured provider")
+				}
+			}
+			if (resp.Project != nil) != tt.wantProject {
+				t.Fatalf("project = %+v, want present: %v", resp.Project, tt.wantProject)
+			}
+			if tt.wantProject && (resp.Project.Servable || resp.Project.ProjectID != "proj_1" || resp.Project.Reason == "") {
+				t.Errorf("project = %+v, want not servable with a reason while the database is down", resp.Project)
+			}
+		})
+	}
+}
+
+func TestEmbedFeatures(t *testing.T) {
+	t.Setenv("CAPTCHA_PROVIDER", "turnstile")
+	t.Setenv("CAPTCHA_SECRET", "secret")
+	t.Setenv("CAPTCHA_SITE_KEY", "site")
+
+	features := embedFeatures()
+	if features[len(features)-1] != "captcha" {
+		t.Errorf("features = %v, want captcha once a provider is configured", features)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/models"
+)
+
+// embedPreviewTemplate - Standalone page that boots the real widget.js with the project's saved settings.
+// html/template escapes every value, and .Config is emitted as JSON inside the script block.
+var embedPreviewTemplate = template.Must(template.New("embed-preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Widget preview · {{.Name}}</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 32px; color: #1f2933; }
+  .swatch { display: inline-block; width: 14px; height: 14px; border-radius: 3px; vertical-align: middle; margin-right: 6px; }
+  dt { font-weight: 600; margin-top: 12px; }
+  dd { margin-left: 0; }
+  .quick-action { display: inline-block; border: 1px solid #d2d6dc; border-radius: 14px; padding: 4px 12px; margin: 4px 4px 0 0; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Preview of the live widget with this project's current configuration (status: {{.Status}}).</p>
+<dl>
+  <dt>Primary color</dt>
+  <dd><span class="swatch" style="background: {{.PrimaryColor}}"></span>{{.PrimaryColor}}</dd>
+  <dt>Welcome message</dt>
+  <dd>{{.WelcomeMessage}}</dd>
+  <dt>Theme / position</dt>
+  <dd>{{.Theme}} · {{.Position}}</dd>
+  <dt>Quick actions</dt>
+  <dd>{{range .QuickActions}}<span class="quick-action">{{.Label}}</span>{{else}}None configured{{end}}</dd>
+</dl>
+<script src="{{.WidgetURL}}"></script>
+<script>
+  window.TroikaChatbot && window.TroikaChatbot.init({{.Config}});
+</script>
+</body>
+</html>
+`))
+
+// widgetInitConfig - The config object passed to TroikaChatbot.init, with the same defaults as the embed code
+func widgetInitConfig(projectID string, ws models.ProjectWidgetConfig) map[string]interface{} {
+	placeholder, height, width, triggerDelay := ws.Placeholder, ws.Height, ws.Width, ws.TriggerDelay
+	if placeholder == "" {
+		placeholder = "Type your message..."
+	}
+	if height == "" {
+		height = "500px"
+	}
+	if width == "" {
+		width = "350px"
+	}
+	if triggerDelay == 0 {
+		triggerDelay = 3000
+	}
+
+	return map[string]interface{}{
+		"projectId":      projectID,
+		"theme":          ws.Theme,
+		"position":       ws.Position,
+		"primaryColor":   ws.PrimaryColor,
+		"welcomeMessage": ws.WelcomeMessage,
+		"placeholder":    placeholder,
+		"height":         height,
+		"width":          width,
+		"showBranding":   ws.ShowBranding,
+		"enableSound":    ws.EnableSound,
+		"autoOpen":       ws.AutoOpen,
+		"triggerDelay":   triggerDelay,
+		"quickActions":   activeQuickActions(ws.QuickActions),
+		"apiUrl":         getDomain() + "/api",
+	}
+}
+
+// activeQuickActions - Enabled quick actions in display order
+func activeQuickActions(actions []models.QuickAction) []models.QuickAction {
+	active := make([]models.QuickAction, 0, len(actions))
+	for _, action := range actions {
+		if action.IsActive {
+			active = append(active, action)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool { return active[i].Order < active[j].Order })
+	return active
+}
+
+// PreviewEmbedWidget - GET /api/admin/projects/:id/embed/preview
+func PreviewEmbedWidget(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	ws := project.WidgetSettings
+	data := gin.H{
+		"Name":           project.Name,
+		"Status":         project.Status,
+		"PrimaryColor":   ws.PrimaryColor,
+		"WelcomeMessage": ws.WelcomeMessage,
+		"Theme":          ws.Theme,
+		"Position":       ws.Position,
+		"QuickActions":   activeQuickActions(ws.QuickActions),
+		"WidgetURL":      WidgetScriptURL(),
+		"Config":         widgetInitConfig(project.ProjectID, ws),
+	}
+
+	var page bytes.Buffer
+	if err := embedPreviewTemplate.Execute(&page, data); err != nil {
+		log.Printf("❌ Failed to render widget preview for %s: %v", project.ProjectID, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to render preview")
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
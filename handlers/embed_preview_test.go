@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestWidgetInitConfigDefaults(t *testing.T) {
+	t.Setenv("DOMAIN", "https://chat.example.com")
+
+	initConfig := widgetInitConfig("proj_1", models.ProjectWidgetConfig{Theme: "dark", PrimaryColor: "#112233"})
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{"projectId", "proj_1"},
+		{"theme", "dark"},
+		{"primaryColor", "#112233"},
+		{"placeholder", "Type your message..."},
+		{"height", "500px"},
+		{"width", "350px"},
+		{"triggerDelay", 3000},
+		{"apiUrl", "https://chat.example.com/api"},
+	}
+	for _, tt := range tests {
+		if initConfig[tt.key] != tt.want {
+			t.Errorf("%s = %v, want %v", tt.key, initConfig[tt.key], tt.want)
+		}
+	}
+
+	saved := widgetInitConfig("proj_1", models.ProjectWidgetConfig{Placeholder: "Ask us", Height: "600px", Width: "400px", TriggerDelay: 500})
+	if saved["placeholder"] != "Ask us" || saved["height"] != "600px" || saved["width"] != "400px" || saved["triggerDelay"] != 500 {
+		t.Errorf("saved settings replaced by defaults: %v", saved)
+	}
+}
+
+func TestActiveQuickActions(t *testing.T) {
+	actions := []models.QuickAction{
+		{ID: "c", Order: 3, IsActive: true},
+		{ID: "off", Order: 0, IsActive: false},
+		{ID: "a", Order: 1, IsActive: true},
+		{ID: "b", Order: 1, IsActive: true},
+	}
+	var got []string
+	for _, action := range activeQuickActions(actions) {
+		got = append(got, action.ID)
+	}
+	if strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("active quick actions = %v, want a,b,c (disabled dropped, stable by order)", got)
+	}
+	if activeQuickActions(nil) == nil {
+		t.Error("no quick actions should render as an empty list, not null")
+	}
+}
+
+func TestEmbedPreviewTemplateEscapesSettings(t *testing.T) {
+	var page strings.Builder
+	err := embedPreviewTemplate.Execute(&page, gin.H{
+		"Name":           "Acme",
+		"WelcomeMessage": `<img src=x onerror=alert(1)>`,
+		"QuickActions":   []models.QuickAction{},
+		"WidgetURL":      "https://chat.example.com/widget.js?v=1",
+		"Config":         map[string]interface{}{"welcomeMessage": `</script><script>alert(1)</script>`},
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	out := page.String()
+	if strings.Contains(out, "<img src=x") || strings.Contains(out, "</script><script>alert") {
+		t.Errorf("settings were not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "None configured") {
+		t.Error("empty quick actions not described")
+	}
+}
+
+func TestPreviewEmbedWidget(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+	t.Chdir("..") // the widget URL is versioned by hashing static/widget.js
+
+	project := models.Project{ProjectID: "proj_1", Name: "Acme", Status: "active",
+		WidgetSettings: models.ProjectWidgetConfig{WelcomeMessage: "Hi there", QuickActions: []models.QuickAction{{Label: "Pricing", IsActive: true}}}}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/projects/:id/embed/preview", PreviewEmbedWidget)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_1/embed/preview", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"Hi there", "Pricing", "/widget.js?v=", "TroikaChatbot.init"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("preview lacks %q", want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/missing/embed/preview", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), ErrCodeProjectNotFound) {
+		t.Errorf("unknown project: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/middleware"
+	"jevi-chat/models"
+)
+
+func TestPrechatPageUsesServerPasswordMinimum(t *testing.T) {
+	t.Chdir("..") // templates are resolved from the repository root
+	page := template.Must(template.ParseFiles("templates/embed/prechat.html"))
+
+	tests := []struct {
+		name, env string
+		want      int
+	}{
+		{"default", "", defaultChatUserPasswordMinLength},
+		{"configured", "12", 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHAT_USER_PASSWORD_MIN_LENGTH", tt.env)
+			if got := chatUserPasswordMinLength(); got != tt.want {
+				t.Fatalf("chatUserPasswordMinLength() = %d, want %d", got, tt.want)
+			}
+
+			var out strings.Builder
+			err := page.Execute(&out, map[string]interface{}{
+				"project":             &models.Project{Name: "Acme"},
+				"project_id":          "proj_1",
+				"password_min_length": chatUserPasswordMinLength(),
+			})
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+
+			want := strings.Repeat("x", tt.want)
+			if err := validateChatUserPassword(want); err != nil {
+				t.Errorf("server rejects a %d-character password: %v", tt.want, err)
+			}
+			if err := validateChatUserPassword(want[1:]); err == nil {
+				t.Errorf("server accepts a %d-character password", tt.want-1)
+			}
+			// html/template pads numbers it writes into scripts with spaces
+			min := strconv.Itoa(tt.want)
+			if script := regexp.MustCompile(`const passwordMinLength = \s*` + min + `\s*;`); !script.MatchString(out.String()) {
+				t.Errorf("page script does not check for %s characters", min)
+			}
+			if !strings.Contains(out.String(), `minlength="`+min+`"`) {
+				t.Errorf("password field does not require %s characters", min)
+			}
+		})
+	}
+}
+
+// embedRouter - The public embed routes with their templates, as main.go registers them
+func embedRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Chdir("..") // templates are resolved from the repository root
+
+	r := gin.New()
+	r.LoadHTMLGlob("templates/embed/*.html")
+	r.GET("/embed/:projectId", EmbedChat)
+	r.POST("/embed/:projectId/auth", EmbedAuth)
+	r.GET("/embed/:projectId/chat", IframeChatInterface)
+	r.GET("/embed/:projectId/config", EmbedConfig)
+	return r
+}
+
+// insertEmbedProject - An active, unexpired project with the given status
+func insertEmbedProject(t *testing.T, ctx context.Context, projectID, status string) *models.Project {
+	t.Helper()
+	project := &models.Project{
+		ID:         primitive.NewObjectID(),
+		ProjectID:  projectID,
+		Name:       "Acme " + projectID,
+		Status:     status,
+		IsActive:   true,
+		ExpiryDate: time.Now().AddDate(0, 1, 0),
+	}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	return project
+}
+
+func TestFindProjectByAnyID(t *testing.T) {
+	ctx := useTestDatabase(t)
+	project := insertEmbedProject(t, ctx, "proj_1", "active")
+
+	tests := []struct {
+		name, id string
+		wantErr  bool
+	}{
+		{"project_id", "proj_1", false},
+		{"ObjectID hex", project.ID.Hex(), false},
+		{"unknown project_id", "proj_missing", true},
+		{"unknown ObjectID", primitive.NewObjectID().Hex(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findProjectByAnyID(ctx, tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.ProjectID != "proj_1" {
+				t.Errorf("found %q", got.ProjectID)
+			}
+		})
+	}
+}
+
+func TestEmbedRoutesAcceptEitherProjectID(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("JWT_SECRET", "test-secret")
+	r := embedRouter(t)
+	project := insertEmbedProject(t, ctx, "proj_1", "active")
+
+	// Registered before ids were unified, so stored under the ObjectID hex
+	hash, err := middleware.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	legacy := bson.M{"project_id": project.ID.Hex(), "email": "legacy@example.com", "password": hash, "is_active": true}
+	if _, err := config.GetChatUsersCollection().InsertOne(ctx, legacy); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	for _, id := range []string{"proj_1", project.ID.Hex()} {
+		t.Run(id, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed/"+id, nil))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "proj_1") {
+				t.Errorf("pre-chat page: status %d, project_id rendered: %v", w.Code, strings.Contains(w.Body.String(), "proj_1"))
+			}
+
+			w = httptest.NewRecorder()
+			body := `{"mode":"login","email":"legacy@example.com","password":"correct horse"}`
+			req := httptest.NewRequest(http.MethodPost, "/embed/"+id+"/auth", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"token"`) {
+				t.Errorf("legacy user login: %d %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed/proj_missing/chat", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
+
+func TestCheckProjectSubscription(t *testing.T) {
+	future := time.Now().AddDate(0, 1, 0)
+	past := time.Now().AddDate(0, 0, -1)
+	active := func(edit func(p *models.Project)) *models.Project {
+		p := &models.Project{ProjectID: "proj_1", Status: "active", IsActive: true, ExpiryDate: future}
+		edit(p)
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		project *models.Project
+		wantErr string
+	}{
+		{"active", active(func(p *models.Project) {}), ""},
+		{"running trial", active(func(p *models.Project) { p.Plan = models.PlanTrial; p.TrialEndsAt = &future }), ""},
+		{"ended trial", active(func(p *models.Project) { p.Plan = models.PlanTrial; p.TrialEndsAt = &past }), "free trial has ended"},
+		{"expired", active(func(p *models.Project) { p.Status = "expired" }), "subscription has expired"},
+		{"suspended", active(func(p *models.Project) { p.Status = "suspended" }), "suspended"},
+		{"deleted", active(func(p *models.Project) { p.Status = "deleted" }), "deleted"},
+		{"unknown status", active(func(p *models.Project) { p.Status = "paused" }), "inactive"},
+		{"deactivated", active(func(p *models.Project) { p.IsActive = false }), "no longer available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkProjectSubscription(tt.project)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmbedPagesExplainUnavailableProjects(t *testing.T) {
+	ctx := useTestDatabase(t)
+	r := embedRouter(t)
+	insertEmbedProject(t, ctx, "proj_suspended", "suspended")
+	lapsed := insertEmbedProject(t, ctx, "proj_lapsed", "active")
+	if _, err := config.GetProjectsCollection().UpdateOne(ctx, bson.M{"_id": lapsed.ID},
+		bson.M{"$set": bson.M{"expiry_date": time.Now().AddDate(0, 0, -1)}}); err != nil {
+		t.Fatalf("backdate expiry: %v", err)
+	}
+
+	tests := []struct {
+		name, path, want string
+	}{
+		{"suspended pre-chat page", "/embed/proj_suspended", "suspended"},
+		{"suspended iframe chat", "/embed/proj_suspended/chat", "suspended"},
+		{"lapsed pre-chat page", "/embed/proj_lapsed", "subscription has expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("status = %d, want 403 with %q: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	var stored models.Project
+	if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"_id": lapsed.ID}).Decode(&stored); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if stored.Status != "expired" {
+		t.Errorf("lapsed project status = %q, want it marked expired", stored.Status)
+	}
+}
+
+func TestEmbedConfig(t *testing.T) {
+	ctx := useTestDatabase(t)
+	r := embedRouter(t)
+	t.Setenv("CAPTCHA_PROVIDER", "")
+
+	project := insertEmbedProject(t, ctx, "proj_1", "active")
+	if _, err := config.GetProjectsCollection().UpdateOne(ctx, bson.M{"_id": project.ID},
+		bson.M{"$set": bson.M{"widget_settings.require_auth": true, "widget_settings.require_captcha": true}}); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+	insertEmbedProject(t, ctx, "proj_suspended", "suspended")
+
+	for _, id := range []string{"proj_1", project.ID.Hex()} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed/"+id+"/config", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", id, w.Code, w.Body)
+		}
+		var resp struct {
+			ProjectID       string                 `json:"project_id"`
+			Widget          map[string]interface{} `json:"widget"`
+			RequireAuth     bool                   `json:"require_auth"`
+			CaptchaRequired bool                   `json:"captcha_required"`
+			AuthURL         string                 `json:"auth_url"`
+			ChatURL         string                 `json:"chat_url"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.ProjectID != "proj_1" || resp.Widget["projectId"] != "proj_1" || !resp.RequireAuth {
+			t.Errorf("%s: config = %+v", id, resp)
+		}
+		// The project asks for a captcha, but none is configured on the server
+		if resp.CaptchaRequired {
+			t.Errorf("%s: captcha required without a configured provider", id)
+		}
+		if resp.AuthURL != "/api/embed/proj_1/auth" || resp.ChatURL != "/api/projects/proj_1/chat" {
+			t.Errorf("%s: urls = %q, %q", id, resp.AuthURL, resp.ChatURL)
+		}
+	}
+
+	// Unknown and unavailable projects get the same answer
+	for _, id := range []string{"proj_missing", "proj_suspended"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed/"+id+"/config", nil))
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), ErrCodeProjectUnavailable) ||
+			!strings.Contains(w.Body.String(), embedUnavailableMessage) {
+			t.Errorf("%s: %d %s", id, w.Code, w.Body)
+		}
+	}
+}
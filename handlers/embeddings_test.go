@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestEmbeddingBatches(t *testing.T) {
+	texts := func(n int, text string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = text
+		}
+		return out
+	}
+	// About maxEmbeddingBatchTokens/2 tokens each, so two never share a request
+	half := strings.Repeat("word ", maxEmbeddingBatchTokens/2+10)
+
+	tests := []struct {
+		name  string
+		texts []string
+		want  [][2]int
+	}{
+		{"none", nil, nil},
+		{"one batch", texts(3, "short text"), [][2]int{{0, 3}}},
+		{"split by count", texts(maxEmbeddingBatch+1, "short text"), [][2]int{{0, maxEmbeddingBatch}, {maxEmbeddingBatch, maxEmbeddingBatch + 1}}},
+		{"split by tokens", []string{half, half, "short text"}, [][2]int{{0, 1}, {1, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := embeddingBatches(tt.texts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("embeddingBatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateEmbeddingInput(t *testing.T) {
+	long := strings.Repeat("a", maxEmbeddingInputChars+10)
+	if got := truncateEmbeddingInput(long); len(got) != maxEmbeddingInputChars {
+		t.Errorf("truncated to %d characters, want %d", len(got), maxEmbeddingInputChars)
+	}
+	if got := truncateEmbeddingInput("short"); got != "short" {
+		t.Errorf("short input changed to %q", got)
+	}
+}
+
+// embeddingsResponse - One vector per input, {index, len(input)}, listed in reverse order to
+// check that results are placed by index
+func embeddingsResponse(inputs []string) openai.EmbeddingResponse {
+	resp := openai.EmbeddingResponse{Object: "list", Usage: openai.Usage{TotalTokens: len(inputs)}}
+	for i := len(inputs) - 1; i >= 0; i-- {
+		resp.Data = append(resp.Data, openai.Embedding{Object: "embedding", Index: i, Embedding: []float32{float32(i), float32(len(inputs[i]))}})
+	}
+	return resp
+}
+
+func TestGenerateBatchEmbeddings(t *testing.T) {
+	var requests []openai.EmbeddingRequestStrings
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		var req openai.EmbeddingRequestStrings
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		json.NewEncoder(w).Encode(embeddingsResponse(req.Input))
+	})
+
+	texts := make([]string, maxEmbeddingBatch+2)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	vectors, err := generateBatchEmbeddings(texts, "text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("generateBatchEmbeddings: %v", err)
+	}
+	if len(requests) != 2 || len(requests[0].Input) != maxEmbeddingBatch || requests[1].Model != "text-embedding-3-small" {
+		t.Errorf("sent %d requests, want %d inputs then the rest with the project's model", len(requests), maxEmbeddingBatch)
+	}
+	if len(vectors) != len(texts) {
+		t.Fatalf("got %d vectors, want %d", len(vectors), len(texts))
+	}
+	for i, vector := range vectors {
+		if int(vector[1]) != len(texts[i]) {
+			t.Errorf("vector %d belongs to an input of length %v, want %d", i, vector[1], len(texts[i]))
+			break
+		}
+	}
+}
+
+func TestGenerateBatchEmbeddingsRejectsShortResponses(t *testing.T) {
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		var req openai.EmbeddingRequestStrings
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(embeddingsResponse(req.Input[1:]))
+	})
+
+	if _, err := generateBatchEmbeddings([]string{"first", "second"}, "text-embedding-3-small"); err == nil || !strings.Contains(err.Error(), "expected 2 embeddings") {
+		t.Errorf("err = %v, want a count mismatch", err)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned alongside every handler error.
+// Clients should branch on these rather than on the human-readable message.
+const (
+	ErrCodeValidationFailed       = "VALIDATION_FAILED"
+	ErrCodeMessageEmpty           = "MESSAGE_EMPTY"
+	ErrCodeMessageTooLong         = "MESSAGE_TOO_LONG"
+	ErrCodeWeakPassword           = "WEAK_PASSWORD"
+	ErrCodeUnauthorized           = "UNAUTHORIZED"
+	ErrCodeForbidden              = "FORBIDDEN"
+	ErrCodeProjectNotFound        = "PROJECT_NOT_FOUND"
+	ErrCodeSessionNotFound        = "SESSION_NOT_FOUND"
+	ErrCodeMessageNotFound        = "MESSAGE_NOT_FOUND"
+	ErrCodeDocumentNotFound       = "DOCUMENT_NOT_FOUND"
+	ErrCodeJobNotFound            = "JOB_NOT_FOUND"
+	ErrCodeModerationFlagNotFound = "MODERATION_FLAG_NOT_FOUND"
+	ErrCodeGapNotFound            = "KNOWLEDGE_GAP_NOT_FOUND"
+	ErrCodeUserNotFound           = "USER_NOT_FOUND"
+	ErrCodeClientNotFound         = "CLIENT_NOT_FOUND"
+	ErrCodeNotificationNotFound   = "NOTIFICATION_NOT_FOUND"
+	ErrCodeUserBlocked            = "USER_BLOCKED"
+	ErrCodeUserExists             = "USER_EXISTS"
+	ErrCodeInvalidCredentials     = "INVALID_CREDENTIALS"
+	ErrCodeCaptchaRequired        = "CAPTCHA_REQUIRED"
+	ErrCodeLimitExceeded          = "LIMIT_EXCEEDED"
+	ErrCodeRateLimited            = "RATE_LIMIT_EXCEEDED"
+	ErrCodeSubscriptionExpired    = "SUBSCRIPTION_EXPIRED"
+	ErrCodeProjectUnavailable     = "PROJECT_UNAVAILABLE"
+	ErrCodeInvalidState           = "INVALID_STATE"
+	ErrCodeAIUnavailable          = "AI_UNAVAILABLE"
+	ErrCodeNotFound               = "NOT_FOUND"
+	ErrCodeInternal               = "INTERNAL_ERROR"
+	ErrCodeTimeout                = "REQUEST_TIMEOUT"
+)
+
+// respondError - Write a JSON error with a stable code: {"error": msg, "code": code}.
+// A server error caused by the route's deadline passing is reported as 504 REQUEST_TIMEOUT.
+func respondError(c *gin.Context, status int, code, msg string) {
+	if status >= http.StatusInternalServerError && requestTimedOut(c) {
+		status, code, msg = http.StatusGatewayTimeout, ErrCodeTimeout, "The request took too long to complete"
+	}
+	c.JSON(status, gin.H{
+		"error": msg,
+		"code":  code,
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		status     int
+		timedOut   bool
+		wantStatus int
+		wantCode   string
+	}{
+		{"client error", http.StatusNotFound, false, http.StatusNotFound, ErrCodeProjectNotFound},
+		{"server error", http.StatusInternalServerError, false, http.StatusInternalServerError, ErrCodeProjectNotFound},
+		{"server error after the deadline", http.StatusInternalServerError, true, http.StatusGatewayTimeout, ErrCodeTimeout},
+		{"client error after the deadline", http.StatusNotFound, true, http.StatusNotFound, ErrCodeProjectNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			ctx := context.Background()
+			if tt.timedOut {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, 0)
+				defer cancel()
+			}
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+			respondError(c, tt.status, ErrCodeProjectNotFound, "Project not found")
+
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if w.Code != tt.wantStatus || body["code"] != tt.wantCode || body["error"] == "" {
+				t.Errorf("got %d %v, want %d with code %s and a message", w.Code, body, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandlerErrorsCarryCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	as := func(userID string) gin.HandlerFunc {
+		return func(c *gin.Context) { c.Set("user_id", userID) }
+	}
+	r := gin.New()
+	r.POST("/login", Login)
+	r.POST("/register", Register)
+	r.GET("/profile/anonymous", GetUserProfile)
+	r.GET("/profile/bad-id", as("not-an-id"), GetUserProfile)
+	r.POST("/password", as("not-an-id"), ChangePassword)
+	r.GET("/verify", VerifyToken)
+
+	tests := []struct {
+		name, method, path, body string
+		wantStatus               int
+		wantCode                 string
+	}{
+		{"login without email", http.MethodPost, "/login", `{"password":"x"}`, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"register with short password", http.MethodPost, "/register", `{"name":"A","email":"a@example.com","password":"short"}`, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"profile without a user", http.MethodGet, "/profile/anonymous", "", http.StatusUnauthorized, ErrCodeUnauthorized},
+		{"profile with a malformed id", http.MethodGet, "/profile/bad-id", "", http.StatusBadRequest, ErrCodeValidationFailed},
+		{"password change without fields", http.MethodPost, "/password", `{}`, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"verify without a token", http.MethodGet, "/verify", "", http.StatusUnauthorized, ErrCodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+
+			var body map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != tt.wantStatus || body["code"] != tt.wantCode {
+				t.Errorf("got %d %v, want %d %s", w.Code, body, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// defaultWelcomeMessage - Shown when a project has no welcome message of its own
+const defaultWelcomeMessage = "Hello! How can I help you today?"
+
+// widgetGreeting - The welcome message as the widget should show it
+func widgetGreeting(projectID string, ws models.ProjectWidgetConfig) gin.H {
+	message := strings.TrimSpace(ws.WelcomeMessage)
+	if message == "" {
+		message = defaultWelcomeMessage
+	}
+	return gin.H{
+		"message":   message,
+		"proactive": ws.ProactiveGreeting,
+		"url":       fmt.Sprintf("/api/projects/%s/greeting", projectID),
+	}
+}
+
+// ProjectGreeting - POST /api/projects/:projectId/greeting
+// Called by the widget when the chat opens. Returns the project's welcome message; with
+// proactive_greeting enabled and a new session, the bot instead writes a short opener for the
+// page the visitor is on and records it as the session's first assistant message.
+// Any failure to generate falls back to the static welcome message.
+func ProjectGreeting(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	var body struct {
+		SessionID string `json:"session_id"`
+		UserID    string `json:"user_id"`
+		PageURL   string `json:"page_url"`
+		PageTitle string `json:"page_title"`
+	}
+	// Every field is optional
+	_ = c.ShouldBindJSON(&body)
+
+	ctx := c.Request.Context()
+	project, err := getProjectByID(ctx, projectID)
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	chatUser, err := chatUserFromRequest(c, project)
+	if err != nil {
+		log.Printf("⚠️ Ignoring chat user token for project %s: %v", project.ProjectID, err)
+	}
+	body.UserID = widgetUserID(body.UserID, chatUser)
+
+	sessionID, existingSession := resolveSessionID(ctx, requestScope(c), project.ProjectID, body.SessionID)
+	static := widgetGreeting(project.ProjectID, project.WidgetSettings)["message"].(string)
+
+	respond := func(greeting string, generated bool, messageID string) {
+		c.JSON(http.StatusOK, gin.H{
+			"session_id":    sessionID,
+			"visitor_token": c.GetString("visitor_token"),
+			"greeting":      greeting,
+			"generated":     generated,
+			"message_id":    messageID,
+		})
+	}
+
+	// Outside business hours the widget opens with the offline message
+	if schedule := project.WidgetSettings.Schedule; !schedule.IsOpen(time.Now()) {
+		respond(schedule.GetOfflineMessage(), false, "")
+		return
+	}
+
+	// The opener only starts a conversation; resumed sessions just get the welcome message
+	if !project.WidgetSettings.ProactiveGreeting || existingSession {
+		respond(static, false, "")
+		return
+	}
+
+	pageURL := body.PageURL
+	if pageURL == "" {
+		pageURL = c.Request.Referer()
+	}
+	// A bad page URL only costs the opener its page awareness
+	page, err := parsePageContext(pageURL, body.PageTitle, "")
+	if err != nil {
+		page, _ = parsePageContext("", body.PageTitle, "")
+	}
+
+	release, err := acquireAISlot(ctx, project.ProjectID)
+	if err != nil {
+		respond(static, false, "")
+		return
+	}
+	defer release()
+
+	startTime := time.Now()
+	result, err := generateChatResponse(ctx, project, chatRequest{
+		Message:         greetingInstruction(static),
+		DocumentContext: selectDocumentContext(project, strings.TrimSpace(page.Title+" "+page.path())),
+		Page:            page,
+	})
+	greeting := strings.TrimSpace(result.Response)
+	if err != nil || greeting == "" {
+		log.Printf("⚠️ Proactive greeting failed for project %s, using the welcome message: %v", project.ProjectID, err)
+		if result.Tokens > 0 {
+			recordTokenUsage(context.Background(), project.ProjectID, sessionID, primitive.NewObjectID(), result.Tokens)
+		}
+		respond(static, false, "")
+		return
+	}
+
+	// Tokens are spent; recording must not be cancelled with the request
+	messageID := primitive.NewObjectID()
+	recordTokenUsage(context.Background(), project.ProjectID, sessionID, messageID, result.Tokens)
+
+	chatMessage := models.ChatMessage{
+		ID:             messageID,
+		ProjectID:      project.ProjectID,
+		SessionID:      sessionID,
+		UserID:         body.UserID,
+		VisitorID:      c.GetString("visitor_id"),
+		Response:       greeting,
+		Greeting:       true,
+		PageURL:        page.URL,
+		PageTitle:      page.Title,
+		TokensUsed:     result.Tokens,
+		Model:          result.Model,
+		Provider:       result.Provider,
+		FallbackUsed:   result.FallbackUsed,
+		ProcessingTime: time.Since(startTime).Milliseconds(),
+		IPAddress:      storedIP(c.ClientIP()),
+		UserAgent:      c.Request.UserAgent(),
+		CreatedAt:      time.Now(),
+	}
+	if _, err := config.GetChatMessagesCollection().InsertOne(context.Background(), chatMessage); err != nil {
+		log.Printf("⚠️ Failed to save greeting for session %s: %v", sessionID, err)
+	}
+
+	updateWidgetSession(project.ProjectID, sessionID, body.UserID, c.GetString("visitor_id"), c.ClientIP(), c.Request.UserAgent(), c.Request.Referer(), result.Tokens)
+
+	setRequestUsageHeaders(c, result.Tokens)
+	respond(greeting, true, messageID.Hex())
+}
+
+// greetingInstruction - Prompt asking the model for a short opener; the page details reach it
+// through the system message
+func greetingInstruction(welcome string) string {
+	return fmt.Sprintf("A visitor has just opened the chat and has not written anything yet. "+
+		"Write the first message you send them: one or two short, friendly sentences offering help, "+
+		"tailored to the page they are on if you know it, without claiming anything the page details don't support. "+
+		"Match the tone of the site's usual welcome message: %q. Reply with the message only.", welcome)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestWidgetGreeting(t *testing.T) {
+	tests := []struct {
+		name, welcome, want string
+	}{
+		{"project welcome", "  Hi there!  ", "Hi there!"},
+		{"default welcome", "", defaultWelcomeMessage},
+		{"blank welcome", "   ", defaultWelcomeMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := widgetGreeting("proj_1", models.ProjectWidgetConfig{WelcomeMessage: tt.welcome})
+			if got["message"] != tt.want {
+				t.Errorf("message = %q, want %q", got["message"], tt.want)
+			}
+			if got["url"] != "/api/projects/proj_1/greeting" {
+				t.Errorf("url = %q", got["url"])
+			}
+		})
+	}
+}
+
+func TestProjectGreeting(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	const welcome = "Welcome to Acme!"
+	projects := []interface{}{
+		models.Project{ProjectID: "static", Name: "Static", WidgetSettings: models.ProjectWidgetConfig{WelcomeMessage: welcome}},
+		models.Project{ProjectID: "proactive", Name: "Proactive", WidgetSettings: models.ProjectWidgetConfig{WelcomeMessage: welcome, ProactiveGreeting: true}},
+	}
+	if _, err := config.GetProjectsCollection().InsertMany(ctx, projects); err != nil {
+		t.Fatalf("insert projects: %v", err)
+	}
+	if _, err := config.GetWidgetSessionsCollection().InsertOne(ctx, bson.M{"session_id": "resumed", "project_id": "proactive", "visitor_id": "visitor_1"}); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+
+	providerFails := false
+	stubProviders(t, func(provider string, req chatRequest) (chatResult, error) {
+		if providerFails {
+			return chatResult{Tokens: 5}, errors.New("503 service unavailable")
+		}
+		if !strings.Contains(req.Message, welcome) || !strings.Contains(req.Page.promptSection(), "Pricing") {
+			t.Errorf("opener prompt lacks the welcome message or page: %q", req.Message)
+		}
+		return chatResult{Response: "Comparing plans? I can help you pick one.", Tokens: 40}, nil
+	})
+
+	r := gin.New()
+	r.POST("/api/projects/:projectId/greeting", func(c *gin.Context) { c.Set("visitor_id", "visitor_1") }, ProjectGreeting)
+
+	tests := []struct {
+		name, projectID, sessionID string
+		providerFails              bool
+		wantGreeting               string
+		wantGenerated              bool
+	}{
+		{"static welcome", "static", "", false, welcome, false},
+		{"generated opener", "proactive", "", false, "Comparing plans? I can help you pick one.", true},
+		{"resumed session gets the welcome", "proactive", "resumed", false, welcome, false},
+		{"provider failure falls back to the welcome", "proactive", "", true, welcome, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providerFails = tt.providerFails
+			body, _ := json.Marshal(gin.H{"session_id": tt.sessionID, "page_url": "https://acme.example/pricing", "page_title": "Pricing"})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/projects/"+tt.projectID+"/greeting", bytes.NewReader(body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				SessionID string `json:"session_id"`
+				Greeting  string `json:"greeting"`
+				Generated bool   `json:"generated"`
+				MessageID string `json:"message_id"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Greeting != tt.wantGreeting || resp.Generated != tt.wantGenerated {
+				t.Errorf("greeting = %q (generated %v), want %q (generated %v)", resp.Greeting, resp.Generated, tt.wantGreeting, tt.wantGenerated)
+			}
+			if tt.sessionID != "" && resp.SessionID != tt.sessionID {
+				t.Errorf("session_id = %q, want the resumed %q", resp.SessionID, tt.sessionID)
+			}
+
+			stored, err := config.GetChatMessagesCollection().CountDocuments(ctx, bson.M{"session_id": resp.SessionID, "greeting": true})
+			if err != nil {
+				t.Fatalf("count: %v", err)
+			}
+			if want := map[bool]int64{true: 1, false: 0}[tt.wantGenerated]; stored != want {
+				t.Errorf("%d greeting messages stored, want %d", stored, want)
+			}
+			if tt.wantGenerated && resp.MessageID == "" {
+				t.Error("generated opener has no message_id")
+			}
+			if tt.wantGenerated && w.Header().Get("X-Request-Tokens") != "40" {
+				t.Errorf("X-Request-Tokens = %q, want the opener's 40", w.Header().Get("X-Request-Tokens"))
+			}
+		})
+	}
+}
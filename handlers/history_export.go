@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// Chat history export tuning
+const (
+	historyExportBatchSize  = 500 // messages fetched per cursor batch
+	historyExportFlushEvery = 200 // rows written between flushes to the client
+)
+
+// historyExportColumns - CSV header, in row order
+var historyExportColumns = []string{
+	"created_at", "session_id", "user_id", "visitor_id", "message", "response",
+	"tokens_used", "model", "rating", "page_url",
+}
+
+// historyExportRow - One message as exported
+type historyExportRow struct {
+	CreatedAt  time.Time `json:"created_at"`
+	SessionID  string    `json:"session_id"`
+	UserID     string    `json:"user_id,omitempty"`
+	VisitorID  string    `json:"visitor_id,omitempty"`
+	Message    string    `json:"message"`
+	Response   string    `json:"response"`
+	TokensUsed int       `json:"tokens_used"`
+	Model      string    `json:"model,omitempty"`
+	Rating     string    `json:"rating,omitempty"`
+	PageURL    string    `json:"page_url,omitempty"`
+}
+
+// ExportChatHistory - GET /api/admin/projects/:id/messages/export
+// Streams the project's messages oldest first as CSV (default) or a JSON array, optionally limited
+// by from/to (YYYY-MM-DD, to inclusive) and session_id. Rows are written as the cursor yields them,
+// so memory stays flat however large the history is; clients sending Accept-Encoding: gzip get
+// the stream gzip-compressed.
+func ExportChatHistory(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "format must be csv or json")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	filter := bson.M{"project_id": project.ProjectID}
+	if sessionID := c.Query("session_id"); sessionID != "" {
+		filter["session_id"] = sessionID
+	}
+	createdAt := bson.M{}
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+		createdAt["$gte"] = from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		createdAt["$lt"] = to.AddDate(0, 0, 1) // inclusive of the whole day
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	// Not wrapped in RetryRead: once rows are on the wire a retry would duplicate them
+	cursor, err := config.GetChatMessagesCollection().Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetBatchSize(historyExportBatchSize))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to export chat history")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	filename := fmt.Sprintf("chat-history-%s-%s.%s", project.ProjectID, time.Now().Format("20060102"), format)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Vary", "Accept-Encoding")
+
+	stream := newExportStream(c)
+	defer stream.Close()
+
+	rows := newHistoryRowWriter(format, stream)
+	written := 0
+	for cursor.Next(ctx) {
+		var message models.ChatMessage
+		if err := cursor.Decode(&message); err != nil {
+			log.Printf("⚠️ Skipping undecodable message in history export for %s: %v", project.ProjectID, err)
+			continue
+		}
+		if err := rows.Write(historyRowFromMessage(message)); err != nil {
+			log.Printf("⚠️ History export for %s aborted after %d rows: %v", project.ProjectID, written, err)
+			return
+		}
+		written++
+		if written%historyExportFlushEvery == 0 {
+			rows.Flush()
+			stream.Flush()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		// Headers are long gone; a truncated file is all the client can be given
+		log.Printf("⚠️ History export for %s truncated after %d rows: %v", project.ProjectID, written, err)
+	}
+	if err := rows.Close(); err != nil {
+		log.Printf("⚠️ Failed to finish history export for %s: %v", project.ProjectID, err)
+	}
+
+	log.Printf("📤 Exported %d messages of %s as %s (gzip=%t) for %s",
+		written, project.ProjectID, format, stream.gzip != nil, c.GetString("user_email"))
+}
+
+// historyRowFromMessage - The exported view of a stored message
+func historyRowFromMessage(message models.ChatMessage) historyExportRow {
+	return historyExportRow{
+		CreatedAt:  message.CreatedAt,
+		SessionID:  message.SessionID,
+		UserID:     message.UserID,
+		VisitorID:  message.VisitorID,
+		Message:    message.Message,
+		Response:   message.Response,
+		TokensUsed: message.TokensUsed,
+		Model:      message.Model,
+		Rating:     message.Rating,
+		PageURL:    message.PageURL,
+	}
+}
+
+// exportStream - Response body for a streamed download, gzip-compressed when the client accepts it.
+// No Content-Length is set, so net/http sends the body chunked.
+type exportStream struct {
+	c    *gin.Context
+	gzip *gzip.Writer
+}
+
+// newExportStream - Start the response body, negotiating gzip from Accept-Encoding
+func newExportStream(c *gin.Context) *exportStream {
+	stream := &exportStream{c: c}
+	if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+		c.Header("Content-Encoding", "gzip")
+		stream.gzip = gzip.NewWriter(c.Writer)
+	}
+	c.Status(http.StatusOK)
+	return stream
+}
+
+func (s *exportStream) Write(p []byte) (int, error) {
+	if s.gzip != nil {
+		return s.gzip.Write(p)
+	}
+	return s.c.Writer.Write(p)
+}
+
+// Flush - Push everything written so far to the client
+func (s *exportStream) Flush() {
+	if s.gzip != nil {
+		s.gzip.Flush()
+	}
+	s.c.Writer.Flush()
+}
+
+// Close - Write the gzip trailer; the response itself is closed by net/http
+func (s *exportStream) Close() {
+	if s.gzip != nil {
+		s.gzip.Close()
+	}
+}
+
+// acceptsGzip - Whether an Accept-Encoding header allows gzip (explicitly or via *), honouring q=0
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// historyRowWriter - Serializes export rows in one format
+type historyRowWriter interface {
+	Write(row historyExportRow) error
+	Flush()
+	Close() error
+}
+
+// newHistoryRowWriter - CSV or JSON writer over w
+func newHistoryRowWriter(format string, w io.Writer) historyRowWriter {
+	if format == "json" {
+		return &jsonHistoryWriter{w: w}
+	}
+	writer := &csvHistoryWriter{w: csv.NewWriter(w)}
+	writer.w.Write(historyExportColumns)
+	return writer
+}
+
+// csvHistoryWriter - One CSV row per message, visitor text neutralized against formula injection
+type csvHistoryWriter struct {
+	w *csv.Writer
+}
+
+func (h *csvHistoryWriter) Write(row historyExportRow) error {
+	return h.w.Write([]string{
+		row.CreatedAt.UTC().Format(time.RFC3339),
+		row.SessionID,
+		row.UserID,
+		row.VisitorID,
+		csvSafe(row.Message),
+		csvSafe(row.Response),
+		strconv.Itoa(row.TokensUsed),
+		row.Model,
+		row.Rating,
+		csvSafe(row.PageURL),
+	})
+}
+
+func (h *csvHistoryWriter) Flush() { h.w.Flush() }
+
+func (h *csvHistoryWriter) Close() error {
+	h.w.Flush()
+	return h.w.Error()
+}
+
+// jsonHistoryWriter - A JSON array written one element at a time
+type jsonHistoryWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (h *jsonHistoryWriter) Write(row historyExportRow) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if h.count == 0 {
+		separator = "[\n"
+	}
+	h.count++
+	if _, err := io.WriteString(h.w, separator); err != nil {
+		return err
+	}
+	_, err = h.w.Write(data)
+	return err
+}
+
+func (h *jsonHistoryWriter) Flush() {}
+
+func (h *jsonHistoryWriter) Close() error {
+	closing := "\n]\n"
+	if h.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(h.w, closing)
+	return err
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, GZIP;q=0.8", true},
+		{"*", true},
+		{"br, deflate", false},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0, deflate", false},
+		{"gzip;q=0, *", true},
+		{"x-gzip", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryRowWriters(t *testing.T) {
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.FixedZone("IST", 5*3600+1800))
+	rows := []historyExportRow{
+		{CreatedAt: at, SessionID: "sess_1", VisitorID: "visitor_1", Message: "=HYPERLINK(\"x\")", Response: "Hi, \"friend\"", TokensUsed: 42, Model: "gpt-4o", Rating: "up"},
+		{CreatedAt: at.Add(time.Minute), SessionID: "sess_1", Message: "Bye", PageURL: "https://acme.example/"},
+	}
+
+	var buf bytes.Buffer
+	w := newHistoryRowWriter("csv", &buf)
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("csv write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("csv close: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v", err)
+	}
+	if len(records) != 3 || !reflect.DeepEqual(records[0], historyExportColumns) {
+		t.Fatalf("records = %q, want a header and two rows", records)
+	}
+	want := []string{"2025-03-01T04:00:00Z", "sess_1", "", "visitor_1", "'=HYPERLINK(\"x\")", "Hi, \"friend\"", "42", "gpt-4o", "up", ""}
+	if !reflect.DeepEqual(records[1], want) {
+		t.Errorf("first row = %q, want %q", records[1], want)
+	}
+
+	buf.Reset()
+	w = newHistoryRowWriter("json", &buf)
+	for _, row := range rows {
+		w.Write(row)
+	}
+	w.Close()
+	var decoded []historyExportRow
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, buf.String())
+	}
+	if len(decoded) != 2 || decoded[0].Message != rows[0].Message || decoded[1].PageURL != rows[1].PageURL {
+		t.Errorf("decoded = %+v", decoded)
+	}
+
+	// No messages is still valid output
+	for format, want := range map[string]string{"json": "[]\n", "csv": strings.Join(historyExportColumns, ",") + "\n"} {
+		buf.Reset()
+		newHistoryRowWriter(format, &buf).Close()
+		if buf.String() != want {
+			t.Errorf("empty %s export = %q, want %q", format, buf.String(), want)
+		}
+	}
+}
+
+func TestExportStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, encoding := range []string{"", "gzip"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set("Accept-Encoding", encoding)
+
+		stream := newExportStream(c)
+		io.WriteString(stream, "first,")
+		stream.Flush()
+		io.WriteString(stream, "second")
+		stream.Close()
+
+		body := w.Body.String()
+		if encoding == "gzip" {
+			if w.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+			}
+			reader, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("body is not gzip: %v", err)
+			}
+			data, _ := io.ReadAll(reader)
+			body = string(data)
+		} else if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Content-Encoding = %q without Accept-Encoding", w.Header().Get("Content-Encoding"))
+		}
+		if w.Code != http.StatusOK || body != "first,second" {
+			t.Errorf("%q: got %d %q, want 200 first,second", encoding, w.Code, body)
+		}
+	}
+}
+
+func TestExportChatHistoryRejectsBadFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/projects/:id/messages/export", ExportChatHistory)
+
+	// Refused before the project is looked up, so no database is needed
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_1/messages/export?format=xml", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "format must be csv or json") {
+		t.Errorf("got %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestExportChatHistory(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	config.GetProjectsCollection().InsertOne(ctx, models.Project{ProjectID: "proj_export"})
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	config.GetChatMessagesCollection().InsertMany(ctx, []interface{}{
+		models.ChatMessage{ProjectID: "proj_export", SessionID: "sess_1", Message: "second", CreatedAt: day.Add(time.Minute)},
+		models.ChatMessage{ProjectID: "proj_export", SessionID: "sess_1", Message: "first", CreatedAt: day},
+		models.ChatMessage{ProjectID: "proj_export", SessionID: "sess_2", Message: "next day", CreatedAt: day.AddDate(0, 0, 1)},
+		models.ChatMessage{ProjectID: "proj_other", SessionID: "sess_3", Message: "not ours", CreatedAt: day},
+	})
+
+	r := gin.New()
+	r.GET("/projects/:id/messages/export", ExportChatHistory)
+	export := func(query, acceptEncoding string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/projects/proj_export/messages/export"+query, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	messages := func(t *testing.T, query string) []string {
+		t.Helper()
+		w := export("?format=json"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+		}
+		var rows []historyExportRow
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var got []string
+		for _, row := range rows {
+			got = append(got, row.Message)
+		}
+		return got
+	}
+
+	if got := messages(t, ""); !reflect.DeepEqual(got, []string{"first", "second", "next day"}) {
+		t.Errorf("export = %q, want the project's messages oldest first", got)
+	}
+	if got := messages(t, "&to=2025-03-10"); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("to=2025-03-10 = %q, want the whole of that day only", got)
+	}
+	if got := messages(t, "&from=2025-03-11"); !reflect.DeepEqual(got, []string{"next day"}) {
+		t.Errorf("from=2025-03-11 = %q", got)
+	}
+	if got := messages(t, "&session_id=sess_2"); !reflect.DeepEqual(got, []string{"next day"}) {
+		t.Errorf("session_id=sess_2 = %q", got)
+	}
+
+	w := export("", "gzip")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("gzip csv export = %d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), `filename="chat-history-proj_export-`) {
+		t.Errorf("Content-Disposition = %q", w.Header().Get("Content-Disposition"))
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil || len(records) != 4 || records[1][4] != "first" {
+		t.Errorf("decompressed csv = %q, %v", records, err)
+	}
+
+	for query, want := range map[string]string{
+		"?from=10-03-2025": "Invalid 'from' date",
+		"?to=yesterday":    "Invalid 'to' date",
+	} {
+		if w := export(query, ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: got %d %s, want 400 %q", query, w.Code, w.Body, want)
+		}
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_missing/messages/export", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// IP anonymization modes (IP_ANONYMIZATION)
+const (
+	ipAnonymizeOff  = "off"  // store the full address (default)
+	ipAnonymizeMask = "mask" // zero the host part: /24 for IPv4, /48 for IPv6
+	ipAnonymizeHash = "hash" // keyed hash, still groups a visitor's sessions without revealing the address
+)
+
+// Prefix lengths kept by mask mode
+const (
+	ipv4MaskBits = 24
+	ipv6MaskBits = 48
+)
+
+var ipHashSecretWarning sync.Once
+
+// storedIP - The form of a client IP that may be written to chat messages and widget sessions.
+// Rate limiting, captcha checks and any location lookup must use the raw address, before this is
+// applied. Values that are not IPs (including already anonymized ones) pass through unchanged,
+// so applying it twice is harmless.
+func storedIP(clientIP string) string {
+	clientIP = strings.TrimSpace(clientIP)
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return clientIP
+	}
+
+	switch strings.ToLower(os.Getenv("IP_ANONYMIZATION")) {
+	case ipAnonymizeMask:
+		return maskIP(ip)
+	case ipAnonymizeHash:
+		secret := os.Getenv("IP_HASH_SECRET")
+		if secret == "" {
+			// An unkeyed hash of the IPv4 space is reversed in seconds, so mask instead
+			ipHashSecretWarning.Do(func() {
+				log.Printf("⚠️ IP_ANONYMIZATION=hash needs IP_HASH_SECRET; masking IPs instead")
+			})
+			return maskIP(ip)
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ip.String()))
+		return "iph_" + hex.EncodeToString(mac.Sum(nil))[:16]
+	default:
+		return clientIP
+	}
+}
+
+// maskIP - Network part of the address with the host bits zeroed
+func maskIP(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(ipv4MaskBits, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(ipv6MaskBits, 128)).String()
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestStoredIP(t *testing.T) {
+	tests := []struct {
+		name, mode, secret, in, want string
+	}{
+		{"off keeps the address", "off", "", "203.0.113.77", "203.0.113.77"},
+		{"unset keeps the address", "", "", "203.0.113.77", "203.0.113.77"},
+		{"mask ipv4", "mask", "", "203.0.113.77", "203.0.113.0"},
+		{"mask ipv6", "mask", "", "2001:db8:abcd:12::1", "2001:db8:abcd::"},
+		{"mask ipv4-mapped ipv6", "MASK", "", "::ffff:203.0.113.77", "203.0.113.0"},
+		{"mask is idempotent", "mask", "", "203.0.113.0", "203.0.113.0"},
+		{"hash without secret masks", "hash", "", "203.0.113.77", "203.0.113.0"},
+		{"non-ip passes through", "mask", "", "unknown", "unknown"},
+		{"hashed value passes through", "hash", "s3cret", "iph_0123456789abcdef", "iph_0123456789abcdef"},
+		{"whitespace is trimmed", "off", "", " 203.0.113.77 ", "203.0.113.77"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IP_ANONYMIZATION", tt.mode)
+			t.Setenv("IP_HASH_SECRET", tt.secret)
+			if got := storedIP(tt.in); got != tt.want {
+				t.Errorf("storedIP(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoredIPHash(t *testing.T) {
+	t.Setenv("IP_ANONYMIZATION", "hash")
+	t.Setenv("IP_HASH_SECRET", "s3cret")
+
+	first := storedIP("203.0.113.77")
+	if !strings.HasPrefix(first, "iph_") || len(first) != len("iph_")+16 {
+		t.Fatalf("unexpected hash format %q", first)
+	}
+	if strings.Contains(first, "203.0.113") {
+		t.Errorf("hash leaks the address: %q", first)
+	}
+	if again := storedIP("203.0.113.77"); again != first {
+		t.Errorf("hash not stable: %q vs %q", first, again)
+	}
+	if other := storedIP("203.0.113.78"); other == first {
+		t.Error("different addresses share a hash")
+	}
+
+	t.Setenv("IP_HASH_SECRET", "other")
+	if rekeyed := storedIP("203.0.113.77"); rekeyed == first {
+		t.Error("hash does not depend on IP_HASH_SECRET")
+	}
+}
+
+func TestMaskIP(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"198.51.100.200", "198.51.100.0"},
+		{"10.1.2.3", "10.1.2.0"},
+		{"2001:db8:1234:5678:9abc::1", "2001:db8:1234::"},
+		{"::1", "::"},
+	}
+	for _, tt := range tests {
+		if got := maskIP(net.ParseIP(tt.in)); got != tt.want {
+			t.Errorf("maskIP(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
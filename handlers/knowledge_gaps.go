@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// maxCantAnswerMessageLength - Longest can't-answer message a project may configure
+const maxCantAnswerMessageLength = 300
+
+// defaultCantAnswerPhrases - How the bot says it can't answer, per buildSystemMessage's instructions
+// (overridable via KNOWLEDGE_GAP_PHRASES, comma-separated, matched case-insensitively)
+var defaultCantAnswerPhrases = []string{
+	"cannot be answered from the document",
+	"can't be answered from the document",
+	"not mentioned in the document",
+	"not covered in the document",
+	"don't have information",
+	"don't have any information",
+	"do not have information",
+	"i don't know",
+	"i'm not sure about",
+	"unable to find",
+}
+
+// cantAnswerPhrases - Configured can't-answer phrases, lowercased
+func cantAnswerPhrases() []string {
+	phrases := defaultCantAnswerPhrases
+	if configured := os.Getenv("KNOWLEDGE_GAP_PHRASES"); configured != "" {
+		phrases = strings.Split(configured, ",")
+	}
+	lowered := make([]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" {
+			lowered = append(lowered, phrase)
+		}
+	}
+	return lowered
+}
+
+// isCantAnswerResponse - Whether the bot's answer admits it couldn't answer the question, either
+// with the project's own can't-answer message or one of the configured phrases.
+// Apostrophes are normalised so "don’t" matches "don't".
+func isCantAnswerResponse(response, cantAnswerMessage string) bool {
+	lower := normalizeAnswerText(response)
+	// The model may drop the closing punctuation when it repeats the message
+	message := strings.TrimRight(normalizeAnswerText(cantAnswerMessage), ".!? ")
+	if message != "" && strings.Contains(lower, message) {
+		return true
+	}
+	for _, phrase := range cantAnswerPhrases() {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAnswerText - Lowercased, trimmed, with typographic apostrophes made plain
+func normalizeAnswerText(text string) string {
+	return strings.TrimSpace(strings.ToLower(strings.ReplaceAll(text, "’", "'")))
+}
+
+// recordKnowledgeGap - Record message as a knowledge gap for reason (reopening a resolved gap)
+// and flag the message. Best effort: failures are logged, never surfaced to the visitor.
+func recordKnowledgeGap(ctx context.Context, message models.ChatMessage, reason, feedback string) {
+	now := time.Now()
+	set := bson.M{"status": models.KnowledgeGapOpen, "updated_at": now}
+	if feedback != "" {
+		set["feedback"] = feedback
+	}
+
+	_, err := config.GetKnowledgeGapsCollection().UpdateOne(ctx,
+		bson.M{"_id": message.ID},
+		bson.M{
+			"$set":      set,
+			"$addToSet": bson.M{"reasons": reason},
+			"$unset":    bson.M{"resolved_at": ""},
+			"$setOnInsert": bson.M{
+				"project_id": message.ProjectID,
+				"session_id": message.SessionID,
+				"question":   message.Message,
+				"response":   message.Response,
+				"created_at": now,
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to record knowledge gap for message %s: %v", message.ID.Hex(), err)
+		return
+	}
+
+	config.GetChatMessagesCollection().UpdateOne(ctx,
+		bson.M{"_id": message.ID},
+		bson.M{"$set": bson.M{"knowledge_gap": true}},
+	)
+}
+
+// Knowledge gap clustering
+const (
+	maxClusteredGaps        = 5000 // most recent gaps considered per request
+	gapClusterSimilarity    = 0.5  // Jaccard similarity of question terms to join a cluster
+	maxGapClusterExamples   = 3
+	defaultGapClusterWindow = 30 // days, when no from date is given
+)
+
+// gapStopWords - Words too common to say what a question is about
+var gapStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "you": true, "your": true, "what": true,
+	"how": true, "can": true, "does": true, "did": true, "which": true, "when": true, "where": true,
+	"who": true, "why": true, "there": true, "this": true, "that": true, "with": true, "about": true,
+	"have": true, "has": true, "any": true, "was": true, "will": true, "please": true, "tell": true,
+}
+
+// gapCluster - Similar unanswered questions grouped together
+type gapCluster struct {
+	Question  string                `json:"question"` // most recent wording
+	Count     int                   `json:"count"`
+	Reasons   map[string]int        `json:"reasons"`
+	FirstSeen time.Time             `json:"first_seen"`
+	LastSeen  time.Time             `json:"last_seen"`
+	Examples  []models.KnowledgeGap `json:"examples"`
+	GapIDs    []primitive.ObjectID  `json:"gap_ids"`
+
+	terms map[string]bool
+}
+
+// gapTerms - Significant words of a question
+func gapTerms(question string) map[string]bool {
+	terms := queryTerms(question)
+	for term := range terms {
+		if gapStopWords[term] {
+			delete(terms, term)
+		}
+	}
+	return terms
+}
+
+// termSimilarity - Jaccard similarity of two term sets
+func termSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for term := range a {
+		if b[term] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// clusterKnowledgeGaps - Greedily group gaps (newest first) whose questions share most of their
+// significant words; biggest clusters first
+func clusterKnowledgeGaps(gaps []models.KnowledgeGap) []*gapCluster {
+	var clusters []*gapCluster
+	for _, gap := range gaps {
+		terms := gapTerms(gap.Question)
+
+		var best *gapCluster
+		bestScore := gapClusterSimilarity
+		for _, cluster := range clusters {
+			if score := termSimilarity(terms, cluster.terms); score >= bestScore {
+				best, bestScore = cluster, score
+			}
+		}
+		if best == nil {
+			best = &gapCluster{Question: gap.Question, Reasons: map[string]int{}, LastSeen: gap.CreatedAt, terms: terms}
+			clusters = append(clusters, best)
+		}
+
+		best.Count++
+		best.FirstSeen = gap.CreatedAt
+		best.GapIDs = append(best.GapIDs, gap.ID)
+		for _, reason := range gap.Reasons {
+			best.Reasons[reason]++
+		}
+		if len(best.Examples) < maxGapClusterExamples {
+			best.Examples = append(best.Examples, gap)
+		}
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+		return clusters[i].LastSeen.After(clusters[j].LastSeen)
+	})
+	return clusters
+}
+
+// GetKnowledgeGaps - GET /api/admin/projects/:id/knowledge-gaps
+// Questions the documents didn't answer, grouped with similar wordings and counted, most frequent
+// first. status: open (default), resolved or all; reason: no_answer or negative_rating;
+// from/to: YYYY-MM-DD (default last 30 days); min_count: smallest cluster returned.
+func GetKnowledgeGaps(c *gin.Context) {
+	page, limit := parsePagination(c, 50)
+
+	minCount := 1
+	if value := c.Query("min_count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "min_count must be a positive integer")
+			return
+		}
+		minCount = parsed
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	filter := bson.M{"project_id": project.ProjectID}
+	switch status := c.DefaultQuery("status", models.KnowledgeGapOpen); status {
+	case "all":
+	case models.KnowledgeGapOpen, models.KnowledgeGapResolved:
+		filter["status"] = status
+	default:
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "status must be 'open', 'resolved' or 'all'")
+		return
+	}
+	switch reason := c.Query("reason"); reason {
+	case "":
+	case models.KnowledgeGapNoAnswer, models.KnowledgeGapNegativeRating:
+		filter["reasons"] = reason
+	default:
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "reason must be 'no_answer' or 'negative_rating'")
+		return
+	}
+
+	from := time.Now().AddDate(0, 0, -defaultGapClusterWindow)
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+	}
+	createdAt := bson.M{"$gte": from}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		createdAt["$lt"] = to.AddDate(0, 0, 1) // inclusive of the whole day
+	}
+	filter["created_at"] = createdAt
+
+	cursor, err := config.GetKnowledgeGapsCollection().Find(ctx, filter,
+		options.Find().
+			SetSort(bson.M{"created_at": -1}).
+			SetLimit(maxClusteredGaps))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get knowledge gaps")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var gaps []models.KnowledgeGap
+	if err := cursor.All(ctx, &gaps); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to decode knowledge gaps")
+		return
+	}
+
+	clusters := make([]*gapCluster, 0)
+	for _, cluster := range clusterKnowledgeGaps(gaps) {
+		if cluster.Count >= minCount {
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	total := int64(len(clusters))
+	start := min((page-1)*limit, len(clusters))
+	end := min(start+limit, len(clusters))
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"clusters":   clusters[start:end],
+		"total_gaps": len(gaps),
+		"truncated":  len(gaps) == maxClusteredGaps,
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  pageCount(total, limit),
+			"total_count":  total,
+			"limit":        limit,
+		},
+	})
+}
+
+// UpdateKnowledgeGap - PATCH /api/admin/projects/:id/knowledge-gaps/:gapId
+// Body: {"status": "resolved"} once the documents cover the question, or "open" to reopen it.
+func UpdateKnowledgeGap(c *gin.Context) {
+	var body struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil ||
+		(body.Status != models.KnowledgeGapOpen && body.Status != models.KnowledgeGapResolved) {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "status must be 'open' or 'resolved'")
+		return
+	}
+
+	gapID, err := primitive.ObjectIDFromHex(c.Param("gapId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid knowledge gap ID")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"status": body.Status, "updated_at": now}}
+	if body.Status == models.KnowledgeGapResolved {
+		update["$set"].(bson.M)["resolved_at"] = now
+	} else {
+		update["$unset"] = bson.M{"resolved_at": ""}
+	}
+
+	var gap models.KnowledgeGap
+	err = config.GetKnowledgeGapsCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": gapID, "project_id": project.ProjectID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&gap)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeGapNotFound, "Knowledge gap not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update knowledge gap")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Knowledge gap updated",
+		"knowledge_gap": gap,
+	})
+}
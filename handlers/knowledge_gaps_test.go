@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestIsCantAnswerResponse(t *testing.T) {
+	const projectMessage = "Sorry, our handbook doesn't cover that. Please email support."
+
+	tests := []struct {
+		name     string
+		response string
+		want     bool
+	}{
+		{"answered", "We open at nine every weekday.", false},
+		{"default phrase", "That cannot be answered from the document provided.", true},
+		{"default phrase, any case", "I DON'T HAVE INFORMATION on refunds.", true},
+		{"typographic apostrophe", "I don’t know which plan you are on.", true},
+		{"project message", "Sorry, our handbook doesn't cover that. Please email support.", true},
+		{"project message without punctuation", "sorry, our handbook doesn't cover that. please email support", true},
+	}
+
+	for _, tt := range tests {
+		if got := isCantAnswerResponse(tt.response, projectMessage); got != tt.want {
+			t.Errorf("%s: isCantAnswerResponse(%q) = %v, want %v", tt.name, tt.response, got, tt.want)
+		}
+	}
+
+	if isCantAnswerResponse("We open at nine.", "") {
+		t.Error("an empty project message should not match every answer")
+	}
+}
+
+func TestCantAnswerPhrasesFromEnv(t *testing.T) {
+	t.Setenv("KNOWLEDGE_GAP_PHRASES", " Not In My Notes , ,beyond my docs")
+	if got := cantAnswerPhrases(); !reflect.DeepEqual(got, []string{"not in my notes", "beyond my docs"}) {
+		t.Errorf("cantAnswerPhrases() = %q, want the configured phrases lowercased", got)
+	}
+	if !isCantAnswerResponse("That is beyond my docs.", "") || isCantAnswerResponse("I don't know.", "") {
+		t.Error("configured phrases should replace the defaults")
+	}
+}
+
+func TestUpdateKnowledgeGapValidatesInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PATCH("/projects/:id/knowledge-gaps/:gapId", UpdateKnowledgeGap)
+
+	validID := primitive.NewObjectID().Hex()
+	tests := []struct {
+		name, gapID, body, want string
+	}{
+		{"missing status", validID, `{}`, "status must be 'open' or 'resolved'"},
+		{"unknown status", validID, `{"status":"ignored"}`, "status must be 'open' or 'resolved'"},
+		{"bad gap id", "gap_1", `{"status":"resolved"}`, "Invalid knowledge gap ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/projects/proj_1/knowledge-gaps/"+tt.gapID, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("got %d %s, want 400 %q", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordKnowledgeGap(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	message := models.ChatMessage{
+		ID: primitive.NewObjectID(), ProjectID: "proj_gaps", SessionID: "sess_1",
+		Message: "Do you ship to Iceland?", Response: "I don't know.",
+	}
+	config.GetChatMessagesCollection().InsertOne(ctx, message)
+
+	recordKnowledgeGap(ctx, message, models.KnowledgeGapNoAnswer, "")
+
+	var gap models.KnowledgeGap
+	if err := config.GetKnowledgeGapsCollection().FindOne(ctx, bson.M{"_id": message.ID}).Decode(&gap); err != nil {
+		t.Fatalf("gap not recorded: %v", err)
+	}
+	if gap.Question != message.Message || gap.Status != models.KnowledgeGapOpen || !reflect.DeepEqual(gap.Reasons, []string{models.KnowledgeGapNoAnswer}) {
+		t.Errorf("gap = %+v", gap)
+	}
+	var flagged models.ChatMessage
+	config.GetChatMessagesCollection().FindOne(ctx, bson.M{"_id": message.ID}).Decode(&flagged)
+	if !flagged.KnowledgeGap {
+		t.Error("the chat message should be flagged as a knowledge gap")
+	}
+
+	// A later thumbs-down on a resolved gap adds its reason and reopens it, keeping one gap per message
+	resolvedAt := time.Now()
+	config.GetKnowledgeGapsCollection().UpdateOne(ctx, bson.M{"_id": message.ID},
+		bson.M{"$set": bson.M{"status": models.KnowledgeGapResolved, "resolved_at": resolvedAt}})
+	recordKnowledgeGap(ctx, message, models.KnowledgeGapNegativeRating, "wrong answer")
+	recordKnowledgeGap(ctx, message, models.KnowledgeGapNegativeRating, "")
+
+	gap = models.KnowledgeGap{}
+	config.GetKnowledgeGapsCollection().FindOne(ctx, bson.M{"_id": message.ID}).Decode(&gap)
+	if gap.Status != models.KnowledgeGapOpen || gap.ResolvedAt != nil || gap.Feedback != "wrong answer" {
+		t.Errorf("gap = %+v, want it reopened with the feedback kept", gap)
+	}
+	if !reflect.DeepEqual(gap.Reasons, []string{models.KnowledgeGapNoAnswer, models.KnowledgeGapNegativeRating}) {
+		t.Errorf("reasons = %v, want each reason once", gap.Reasons)
+	}
+	if count, _ := config.GetKnowledgeGapsCollection().CountDocuments(ctx, bson.M{}); count != 1 {
+		t.Errorf("%d gaps recorded, want 1", count)
+	}
+}
+
+func TestGapTermsAndSimilarity(t *testing.T) {
+	terms := gapTerms("What are your shipping costs to Iceland?")
+	if !reflect.DeepEqual(terms, map[string]bool{"shipping": true, "costs": true, "iceland": true}) {
+		t.Errorf("gapTerms = %v, want only the significant words", terms)
+	}
+
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"shipping costs iceland", "shipping costs iceland", 1},
+		{"shipping costs iceland", "shipping costs norway", 0.5},
+		{"shipping costs", "refund policy", 0},
+		{"what is it", "how can you", 1}, // no significant words on either side
+	}
+	for _, tt := range tests {
+		if got := termSimilarity(gapTerms(tt.a), gapTerms(tt.b)); got != tt.want {
+			t.Errorf("termSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestClusterKnowledgeGaps(t *testing.T) {
+	now := time.Now()
+	gap := func(question string, hoursAgo int, reasons ...string) models.KnowledgeGap {
+		return models.KnowledgeGap{ID: primitive.NewObjectID(), Question: question, Reasons: reasons, CreatedAt: now.Add(-time.Duration(hoursAgo) * time.Hour)}
+	}
+	// Newest first, as GetKnowledgeGaps loads them
+	gaps := []models.KnowledgeGap{
+		gap("Do you ship to Iceland?", 1, models.KnowledgeGapNoAnswer),
+		gap("What is the refund policy?", 2, models.KnowledgeGapNegativeRating),
+		gap("Shipping cost to Norway?", 3, models.KnowledgeGapNoAnswer, models.KnowledgeGapNegativeRating),
+		gap("Can you ship to Iceland?", 4, models.KnowledgeGapNoAnswer),
+		gap("Ship to Iceland?", 5, models.KnowledgeGapNegativeRating),
+		gap("Do you ship to iceland", 6, models.KnowledgeGapNoAnswer),
+		gap("Refund policy for sale items", 7, models.KnowledgeGapNoAnswer),
+	}
+
+	clusters := clusterKnowledgeGaps(gaps)
+	if len(clusters) != 3 {
+		t.Fatalf("got %d clusters, want 3: %+v", len(clusters), clusters)
+	}
+
+	shipping := clusters[0]
+	if shipping.Question != "Do you ship to Iceland?" || shipping.Count != 4 {
+		t.Errorf("largest cluster = %q x%d, want the newest wording x4", shipping.Question, shipping.Count)
+	}
+	if !shipping.LastSeen.Equal(gaps[0].CreatedAt) || !shipping.FirstSeen.Equal(gaps[5].CreatedAt) {
+		t.Errorf("shipping cluster seen %v..%v, want the oldest and newest gap times", shipping.FirstSeen, shipping.LastSeen)
+	}
+	if shipping.Reasons[models.KnowledgeGapNoAnswer] != 3 || shipping.Reasons[models.KnowledgeGapNegativeRating] != 1 {
+		t.Errorf("shipping reasons = %v", shipping.Reasons)
+	}
+	if len(shipping.Examples) != maxGapClusterExamples || len(shipping.GapIDs) != 4 {
+		t.Errorf("shipping cluster has %d examples and %d ids, want %d and 4", len(shipping.Examples), len(shipping.GapIDs), maxGapClusterExamples)
+	}
+
+	if clusters[1].Question != "What is the refund policy?" || clusters[1].Count != 2 {
+		t.Errorf("second cluster = %q x%d, want the refund questions", clusters[1].Question, clusters[1].Count)
+	}
+	if clusters[2].Count != 1 || clusters[2].Reasons[models.KnowledgeGapNegativeRating] != 1 {
+		t.Errorf("third cluster = %+v, want the Norway question alone", clusters[2])
+	}
+
+	// Same size: the most recently seen cluster first
+	tied := clusterKnowledgeGaps([]models.KnowledgeGap{
+		gap("Refund policy?", 1, models.KnowledgeGapNoAnswer),
+		gap("Opening hours?", 2, models.KnowledgeGapNoAnswer),
+	})
+	if len(tied) != 2 || tied[0].Question != "Refund policy?" {
+		t.Errorf("tied clusters = %+v, want the newest first", tied)
+	}
+}
+
+func TestGetKnowledgeGapsRejectsBadMinCount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/projects/:id/knowledge-gaps", GetKnowledgeGaps)
+
+	for _, value := range []string{"0", "-1", "many"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_1/knowledge-gaps?min_count="+value, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "min_count must be a positive integer") {
+			t.Errorf("min_count=%s: got %d %s, want 400", value, w.Code, w.Body)
+		}
+	}
+}
+
+func TestGetKnowledgeGaps(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	config.GetProjectsCollection().InsertOne(ctx, models.Project{ProjectID: "proj_gaps"})
+	now := time.Now()
+	insert := func(question, status string, created time.Time, reasons ...string) {
+		config.GetKnowledgeGapsCollection().InsertOne(ctx, models.KnowledgeGap{
+			ID: primitive.NewObjectID(), ProjectID: "proj_gaps", Question: question, Status: status, Reasons: reasons, CreatedAt: created,
+		})
+	}
+	insert("Do you ship to Iceland?", models.KnowledgeGapOpen, now.Add(-time.Hour), models.KnowledgeGapNoAnswer)
+	insert("Can you ship to Iceland?", models.KnowledgeGapOpen, now.Add(-2*time.Hour), models.KnowledgeGapNegativeRating)
+	insert("What is the refund policy?", models.KnowledgeGapOpen, now.Add(-3*time.Hour), models.KnowledgeGapNoAnswer)
+	insert("Ship to Iceland?", models.KnowledgeGapResolved, now.Add(-4*time.Hour), models.KnowledgeGapNoAnswer)
+	insert("Ship to Iceland?", models.KnowledgeGapOpen, now.AddDate(0, 0, -45), models.KnowledgeGapNoAnswer)
+
+	r := gin.New()
+	r.GET("/projects/:id/knowledge-gaps", GetKnowledgeGaps)
+
+	var resp struct {
+		Clusters  []gapCluster `json:"clusters"`
+		TotalGaps int          `json:"total_gaps"`
+	}
+	get := func(query string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_gaps/knowledge-gaps"+query, nil))
+		resp.Clusters = nil
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code
+	}
+
+	// Defaults: open gaps from the last 30 days
+	if code := get(""); code != http.StatusOK || resp.TotalGaps != 3 || len(resp.Clusters) != 2 || resp.Clusters[0].Count != 2 {
+		t.Errorf("default = %d %+v, want 3 recent open gaps in 2 clusters", code, resp)
+	}
+	if get("?min_count=2"); len(resp.Clusters) != 1 {
+		t.Errorf("min_count=2 = %+v, want only the shipping cluster", resp.Clusters)
+	}
+	if get("?status=all"); resp.TotalGaps != 4 || resp.Clusters[0].Count != 3 {
+		t.Errorf("status=all = %+v, want the resolved gap included", resp)
+	}
+	if get("?reason=negative_rating"); resp.TotalGaps != 1 {
+		t.Errorf("reason=negative_rating = %+v, want one gap", resp)
+	}
+	if get("?from=" + now.AddDate(0, 0, -60).Format("2006-01-02")); resp.TotalGaps != 4 {
+		t.Errorf("from 60 days ago = %+v, want the older open gap too", resp)
+	}
+	if get("?from=" + now.AddDate(0, 0, -60).Format("2006-01-02") + "&to=" + now.AddDate(0, 0, -40).Format("2006-01-02")); resp.TotalGaps != 1 {
+		t.Errorf("from/to window = %+v, want only the old gap", resp)
+	}
+
+	for _, query := range []string{"?status=ignored", "?reason=spam", "?from=yesterday", "?to=2024-13-01"} {
+		if code := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, code)
+		}
+	}
+	if code := get("?status=open"); code != http.StatusOK {
+		t.Errorf("status=open: status = %d", code)
+	}
+}
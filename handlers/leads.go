@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/middleware"
+	"jevi-chat/models"
+)
+
+const (
+	defaultLeadsPerIPHour = 10
+	maxLeadNameLength     = 100
+)
+
+// CaptureLead - POST /api/projects/:projectId/lead
+// When the project collects user info, stores the visitor's name and email as a ChatUser and links
+// it to the current session. A lead with the same email in the project is reused rather than
+// duplicated. The returned user_id should be sent as user_id on later chat messages.
+func CaptureLead(c *gin.Context) {
+	var body struct {
+		SessionID string `json:"session_id" binding:"required"`
+		Name      string `json:"name"`
+		Email     string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "session_id and email are required")
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(body.Email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Please enter a valid email address")
+		return
+	}
+	name := cleanPageField(body.Name, maxLeadNameLength)
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := getProjectByID(ctx, c.Param("projectId"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+	if !project.WidgetSettings.CollectUserInfo {
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, "This project does not collect visitor details")
+		return
+	}
+
+	clientIP := c.ClientIP()
+	if !middleware.AllowRequest("lead:ip:"+project.ProjectID+":"+clientIP, envInt("LEADS_PER_IP_HOUR", defaultLeadsPerIPHour), time.Hour) {
+		respondError(c, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many submissions, please try again later")
+		return
+	}
+
+	// Leads are captured mid-conversation, so the session must already exist and be the caller's
+	sessionID, existingSession := resolveSessionID(ctx, requestScope(c), project.ProjectID, body.SessionID)
+	if !existingSession || sessionID != strings.TrimSpace(body.SessionID) {
+		respondError(c, http.StatusNotFound, ErrCodeSessionNotFound, "Session not found")
+		return
+	}
+
+	user, created, err := upsertLead(ctx, project, name, email)
+	if err != nil {
+		log.Printf("❌ Failed to store lead for project %s: %v", project.ProjectID, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to save your details")
+		return
+	}
+	if user.IsBlocked {
+		respondError(c, http.StatusForbidden, ErrCodeUserBlocked, "User is not allowed to chat")
+		return
+	}
+
+	linkLeadToSession(ctx, project.ProjectID, sessionID, user)
+
+	if created {
+		log.Printf("📇 Lead captured for project %s in session %s", project.ProjectID, sessionID)
+		if project.WidgetSettings.EmailLeads {
+			go notifyLead(*project, *user, sessionID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"user_id":    user.ID.Hex(),
+		"session_id": sessionID,
+		"new_lead":   created,
+	})
+}
+
+// upsertLead - The project's ChatUser with this email, created as a lead if there is none.
+// Registered accounts (possibly stored under the project's ObjectID) are reused as they are;
+// an existing lead only gains a name it didn't have.
+func upsertLead(ctx context.Context, project *models.Project, name, email string) (*models.ChatUser, bool, error) {
+	collection := config.GetChatUsersCollection()
+
+	var user models.ChatUser
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		return collection.FindOne(ctx, bson.M{
+			"project_id": bson.M{"$in": []string{project.ProjectID, project.ID.Hex()}},
+			"email":      email,
+		}).Decode(&user)
+	})
+	if err == nil {
+		if user.Name == "" && name != "" && user.Source == models.ChatUserSourceLead {
+			if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID},
+				bson.M{"$set": bson.M{"name": name, "updated_at": time.Now()}}); err != nil {
+				log.Printf("⚠️ Failed to update lead %s: %v", user.ID.Hex(), err)
+			}
+			user.Name = name
+		}
+		return &user, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	// Upsert so two submissions racing on the same email end up on one document
+	now := time.Now()
+	filter := bson.M{"project_id": project.ProjectID, "email": email}
+	result, err := collection.UpdateOne(ctx, filter,
+		bson.M{"$setOnInsert": bson.M{
+			"project_id":     project.ProjectID,
+			"email":          email,
+			"name":           name,
+			"source":         models.ChatUserSourceLead,
+			"is_active":      true,
+			"is_blocked":     false,
+			"total_sessions": 0,
+			"total_messages": 0,
+			"total_tokens":   int64(0),
+			"last_seen_at":   now,
+			"created_at":     now,
+			"updated_at":     now,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, false, err
+	}
+	return &user, result.UpsertedCount > 0, nil
+}
+
+// linkLeadToSession - Attribute the session, and the messages already in it, to the lead
+func linkLeadToSession(ctx context.Context, projectID, sessionID string, user *models.ChatUser) {
+	userID := user.ID.Hex()
+
+	_, err := config.GetWidgetSessionsCollection().UpdateOne(ctx,
+		bson.M{"session_id": sessionID, "project_id": projectID},
+		bson.M{"$set": bson.M{"user_id": userID, "user_name": user.Name, "user_email": user.Email}},
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to link lead %s to session %s: %v", userID, sessionID, err)
+	}
+
+	_, err = config.GetChatMessagesCollection().UpdateMany(ctx,
+		bson.M{"project_id": projectID, "session_id": sessionID, "user_id": bson.M{"$in": []interface{}{nil, ""}}},
+		bson.M{"$set": bson.M{"user_id": userID}},
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to attribute session %s messages to lead %s: %v", sessionID, userID, err)
+	}
+}
+
+// notifyLead - Log the lead as a project notification, which delivers it to the project's channels
+func notifyLead(project models.Project, user models.ChatUser, sessionID string) {
+	name := user.Name
+	if name == "" {
+		name = "(no name given)"
+	}
+	message := fmt.Sprintf("New lead from your %s chatbot: %s <%s> (session %s)", project.Name, name, user.Email, sessionID)
+	if err := config.LogNotification(project.ID, "lead", message); err != nil {
+		log.Printf("⚠️ Failed to log lead notification for %s: %v", project.ProjectID, err)
+	}
+}
+
+// maxLeadExport - Most leads written to one CSV export
+const maxLeadExport = 10000
+
+// leadRow - One captured lead as listed and exported
+type leadRow struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	MessageCount int64     `json:"message_count"`
+}
+
+// GetProjectLeads - GET /api/admin/projects/:id/leads
+// Leads captured by the widget, newest first. from/to: YYYY-MM-DD bounds on when the lead was
+// first seen. format=csv downloads every matching lead (up to maxLeadExport) for CRM import.
+func GetProjectLeads(c *gin.Context) {
+	page, limit := parsePagination(c, 50)
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	filter := bson.M{"project_id": project.ProjectID, "source": models.ChatUserSourceLead}
+	createdAt := bson.M{}
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+		createdAt["$gte"] = from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		createdAt["$lt"] = to.AddDate(0, 0, 1) // inclusive of the whole day
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	exportCSV := c.Query("format") == "csv"
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetProjection(bson.M{"name": 1, "email": 1, "created_at": 1, "last_seen_at": 1})
+	if exportCSV {
+		opts.SetLimit(maxLeadExport)
+	} else {
+		opts.SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	}
+
+	collection := config.GetChatUsersCollection()
+	var users []models.ChatUser
+	err = config.RetryRead(ctx, func(ctx context.Context) error {
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, &users)
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get leads")
+		return
+	}
+
+	leads, err := leadRows(ctx, project.ProjectID, users)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count lead messages")
+		return
+	}
+
+	if exportCSV {
+		writeLeadsCSV(c, project.ProjectID, leads)
+		return
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count leads")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"leads": leads,
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  pageCount(totalCount, limit),
+			"total_count":  totalCount,
+			"limit":        limit,
+		},
+	})
+}
+
+// leadRows - Users as lead rows, with message counts taken from the chat messages attributed to
+// them (which includes the messages sent before the lead was captured)
+func leadRows(ctx context.Context, projectID string, users []models.ChatUser) ([]leadRow, error) {
+	leads := make([]leadRow, 0, len(users))
+	if len(users) == 0 {
+		return leads, nil
+	}
+
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID.Hex())
+	}
+
+	var counts []struct {
+		UserID string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		cursor, err := config.GetChatMessagesCollection().Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"project_id": projectID, "user_id": bson.M{"$in": ids}}}},
+			{{Key: "$group", Value: bson.M{"_id": "$user_id", "count": bson.M{"$sum": 1}}}},
+		})
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, &counts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	messages := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		messages[count.UserID] = count.Count
+	}
+
+	for _, user := range users {
+		leads = append(leads, leadRow{
+			ID:           user.ID.Hex(),
+			Name:         user.Name,
+			Email:        user.Email,
+			FirstSeen:    user.CreatedAt,
+			LastSeen:     user.LastSeenAt,
+			MessageCount: messages[user.ID.Hex()],
+		})
+	}
+	return leads, nil
+}
+
+// writeLeadsCSV - Leads as a CSV attachment with a header row
+func writeLeadsCSV(c *gin.Context, projectID string, leads []leadRow) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("leads-%s-%s.csv", projectID, time.Now().Format("20060102"))))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"name", "email", "first_seen", "last_seen", "message_count"})
+	for _, lead := range leads {
+		writer.Write([]string{
+			csvSafe(lead.Name),
+			csvSafe(lead.Email),
+			lead.FirstSeen.UTC().Format(time.RFC3339),
+			lead.LastSeen.UTC().Format(time.RFC3339),
+			strconv.FormatInt(lead.MessageCount, 10),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("⚠️ Failed to write leads CSV for %s: %v", projectID, err)
+	}
+}
+
+// csvSafe - Neutralize visitor-supplied values a spreadsheet would run as a formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func postLead(r *gin.Engine, projectID string, body gin.H) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/projects/"+projectID+"/lead", bytes.NewReader(payload)))
+	return w
+}
+
+func leadRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/projects/:projectId/lead", func(c *gin.Context) { c.Set("visitor_id", "visitor_1") }, CaptureLead)
+	return r
+}
+
+func TestCaptureLeadValidation(t *testing.T) {
+	r := leadRouter()
+
+	tests := []struct {
+		name string
+		body gin.H
+	}{
+		{"missing session", gin.H{"email": "jane@example.com"}},
+		{"missing email", gin.H{"session_id": "sess_1"}},
+		{"malformed email", gin.H{"session_id": "sess_1", "email": "jane at example"}},
+		{"display-name email", gin.H{"session_id": "sess_1", "email": "Jane <jane@example.com>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postLead(r, "proj_1", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCaptureLeadStoresLead(t *testing.T) {
+	ctx := useTestDatabase(t)
+	r := leadRouter()
+
+	projects := []interface{}{
+		models.Project{ProjectID: "collects", Name: "Collects", WidgetSettings: models.ProjectWidgetConfig{CollectUserInfo: true}},
+		models.Project{ProjectID: "private", Name: "Private"},
+	}
+	if _, err := config.GetProjectsCollection().InsertMany(ctx, projects); err != nil {
+		t.Fatalf("insert projects: %v", err)
+	}
+	sessions := []interface{}{
+		bson.M{"session_id": "mine", "project_id": "collects", "visitor_id": "visitor_1"},
+		bson.M{"session_id": "theirs", "project_id": "collects", "visitor_id": "visitor_2"},
+	}
+	if _, err := config.GetWidgetSessionsCollection().InsertMany(ctx, sessions); err != nil {
+		t.Fatalf("insert sessions: %v", err)
+	}
+	if _, err := config.GetChatMessagesCollection().InsertOne(ctx, bson.M{"project_id": "collects", "session_id": "mine", "user_id": "", "message": "hi"}); err != nil {
+		t.Fatalf("insert message: %v", err)
+	}
+
+	tests := []struct {
+		name, projectID string
+		body            gin.H
+		wantStatus      int
+		wantNew         bool
+	}{
+		{"project not collecting", "private", gin.H{"session_id": "mine", "email": "jane@example.com"}, http.StatusForbidden, false},
+		{"unknown session", "collects", gin.H{"session_id": "missing", "email": "jane@example.com"}, http.StatusNotFound, false},
+		{"another visitor's session", "collects", gin.H{"session_id": "theirs", "email": "jane@example.com"}, http.StatusNotFound, false},
+		{"new lead", "collects", gin.H{"session_id": "mine", "email": " Jane@Example.com ", "name": "Jane"}, http.StatusOK, true},
+		{"same email again", "collects", gin.H{"session_id": "mine", "email": "jane@example.com"}, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postLead(r, tt.projectID, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp struct {
+				UserID  string `json:"user_id"`
+				NewLead bool   `json:"new_lead"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.NewLead != tt.wantNew || resp.UserID == "" {
+				t.Errorf("response = %+v, want new_lead %v", resp, tt.wantNew)
+			}
+		})
+	}
+
+	var lead models.ChatUser
+	if err := config.GetChatUsersCollection().FindOne(ctx, bson.M{"project_id": "collects"}).Decode(&lead); err != nil {
+		t.Fatalf("lead not stored: %v", err)
+	}
+	if lead.Email != "jane@example.com" || lead.Name != "Jane" || lead.Source != models.ChatUserSourceLead {
+		t.Errorf("stored lead = %+v", lead)
+	}
+	if count, _ := config.GetChatUsersCollection().CountDocuments(ctx, bson.M{"email": "jane@example.com"}); count != 1 {
+		t.Errorf("%d leads stored for one email, want 1", count)
+	}
+
+	checks := []struct {
+		name       string
+		collection *mongo.Collection
+		filter     bson.M
+	}{
+		{"session linked", config.GetWidgetSessionsCollection(), bson.M{"session_id": "mine", "user_id": lead.ID.Hex(), "user_email": "jane@example.com"}},
+		{"earlier messages attributed", config.GetChatMessagesCollection(), bson.M{"session_id": "mine", "user_id": lead.ID.Hex()}},
+		{"other visitor's session untouched", config.GetWidgetSessionsCollection(), bson.M{"session_id": "theirs", "user_id": bson.M{"$exists": false}}},
+	}
+	for _, check := range checks {
+		if count, _ := check.collection.CountDocuments(ctx, check.filter); count != 1 {
+			t.Errorf("%s: %d matching documents, want 1", check.name, count)
+		}
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	tests := map[string]string{
+		"":                   "",
+		"Jane":               "Jane",
+		"=HYPERLINK(\"x\")":  "'=HYPERLINK(\"x\")",
+		"+1 555 0100":        "'+1 555 0100",
+		"-2+3":               "'-2+3",
+		"@SUM(A1)":           "'@SUM(A1)",
+		"\tcmd":              "'\tcmd",
+		"jane@example.com":   "jane@example.com",
+		"Jane = the manager": "Jane = the manager",
+	}
+	for value, want := range tests {
+		if got := csvSafe(value); got != want {
+			t.Errorf("csvSafe(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestWriteLeadsCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	seen := time.Date(2025, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	writeLeadsCSV(c, "proj_1", []leadRow{
+		{Name: "=cmd", Email: "jane@example.com", FirstSeen: seen, LastSeen: seen.Add(time.Hour), MessageCount: 4},
+	})
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("got %d %q, want a CSV response", w.Code, w.Header().Get("Content-Type"))
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, `attachment; filename="leads-proj_1-`) {
+		t.Errorf("Content-Disposition = %q", disposition)
+	}
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	want := [][]string{
+		{"name", "email", "first_seen", "last_seen", "message_count"},
+		{"'=cmd", "jane@example.com", "2025-03-01T08:30:00Z", "2025-03-01T09:30:00Z", "4"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("CSV = %q, want %q", rows, want)
+	}
+}
+
+func TestGetProjectLeads(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, models.Project{ProjectID: "proj_leads"}); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 12, 0, 0, 0, time.UTC) }
+	users := []interface{}{
+		models.ChatUser{ID: primitive.NewObjectID(), ProjectID: "proj_leads", Name: "Early", Email: "early@example.com", Source: models.ChatUserSourceLead, CreatedAt: day(1), LastSeenAt: day(2)},
+		models.ChatUser{ID: primitive.NewObjectID(), ProjectID: "proj_leads", Name: "Late", Email: "late@example.com", Source: models.ChatUserSourceLead, CreatedAt: day(5), LastSeenAt: day(5)},
+		models.ChatUser{ID: primitive.NewObjectID(), ProjectID: "proj_leads", Name: "Account", Email: "account@example.com", CreatedAt: day(3)},
+		models.ChatUser{ID: primitive.NewObjectID(), ProjectID: "proj_other", Name: "Other", Email: "other@example.com", Source: models.ChatUserSourceLead, CreatedAt: day(3)},
+	}
+	if _, err := config.GetChatUsersCollection().InsertMany(ctx, users); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+	early := users[0].(models.ChatUser).ID.Hex()
+	config.GetChatMessagesCollection().InsertMany(ctx, []interface{}{
+		bson.M{"project_id": "proj_leads", "user_id": early, "message": "before the lead"},
+		bson.M{"project_id": "proj_leads", "user_id": early, "message": "after the lead"},
+		bson.M{"project_id": "proj_other", "user_id": early, "message": "elsewhere"},
+	})
+
+	r := gin.New()
+	r.GET("/projects/:id/leads", GetProjectLeads)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_leads/leads"+query, nil))
+		return w
+	}
+
+	var resp struct {
+		Leads      []leadRow `json:"leads"`
+		Pagination struct {
+			TotalCount int64 `json:"total_count"`
+		} `json:"pagination"`
+	}
+	w := get("")
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Leads) != 2 || resp.Pagination.TotalCount != 2 {
+		t.Fatalf("got %d %+v, want the project's two leads", w.Code, resp)
+	}
+	if resp.Leads[0].Name != "Late" || resp.Leads[1].MessageCount != 2 {
+		t.Errorf("leads = %+v, want newest first with message counts from this project", resp.Leads)
+	}
+
+	w = get("?from=2025-03-01&to=2025-03-01")
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Leads) != 1 || resp.Leads[0].Name != "Early" {
+		t.Errorf("from/to = %+v, want only the lead first seen that day", resp.Leads)
+	}
+
+	w = get("?format=csv")
+	rows, _ := csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || len(rows) != 3 || rows[1][1] != "late@example.com" {
+		t.Errorf("CSV export = %d %q, want a header and both leads", w.Code, rows)
+	}
+
+	for _, query := range []string{"?from=March", "?to=2025-02-30"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_missing/leads", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
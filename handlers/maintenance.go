@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+// maxMaintenanceMessageLength - Longest message shown to widget visitors during maintenance
+const maxMaintenanceMessageLength = 500
+
+// GetMaintenanceMode - GET /api/admin/maintenance
+func GetMaintenanceMode(c *gin.Context) {
+	c.JSON(http.StatusOK, config.GetMaintenanceMode())
+}
+
+// SetMaintenanceMode - PUT /api/admin/maintenance
+// While enabled, chat and widget endpoints answer 503 with the message; admin routes keep working.
+func SetMaintenanceMode(c *gin.Context) {
+	var body struct {
+		Enabled *bool  `json:"enabled" binding:"required"`
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "enabled is required")
+		return
+	}
+	message := strings.TrimSpace(body.Message)
+	if len(message) > maxMaintenanceMessageLength {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "message is too long")
+		return
+	}
+
+	if current := config.GetMaintenanceMode(); current.Source == "env" && !*body.Enabled {
+		respondError(c, http.StatusConflict, ErrCodeInvalidState, "Maintenance mode is forced on by MAINTENANCE_MODE and cannot be turned off here")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	state, err := config.SetMaintenanceMode(ctx, *body.Enabled, message, c.GetString("user_email"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update maintenance mode")
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
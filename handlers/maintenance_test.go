@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+func maintenanceRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_email", "admin@example.com") })
+	r.GET("/maintenance", GetMaintenanceMode)
+	r.PUT("/maintenance", SetMaintenanceMode)
+	return r
+}
+
+func putMaintenance(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/maintenance", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSetMaintenanceModeValidatesInput(t *testing.T) {
+	r := maintenanceRouter()
+	t.Setenv("MAINTENANCE_MODE", "")
+
+	tests := []struct {
+		name, body string
+	}{
+		{"missing enabled", `{"message":"Upgrading"}`},
+		{"malformed", `{"enabled":`},
+		{"message too long", `{"enabled":true,"message":"` + strings.Repeat("x", maxMaintenanceMessageLength+1) + `"}`},
+	}
+	for _, tt := range tests {
+		if w := putMaintenance(r, tt.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.name, w.Code)
+		}
+	}
+}
+
+func TestMaintenanceModeForcedByEnv(t *testing.T) {
+	r := maintenanceRouter()
+	t.Setenv("MAINTENANCE_MODE", "true")
+	t.Setenv("MAINTENANCE_MESSAGE", "Back at noon")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/maintenance", nil))
+	var state config.MaintenanceState
+	json.Unmarshal(w.Body.Bytes(), &state)
+	if w.Code != http.StatusOK || !state.Enabled || state.Source != "env" || state.Message != "Back at noon" {
+		t.Errorf("got %d %+v, want the forced state", w.Code, state)
+	}
+
+	// The environment wins; turning it off here would not take effect
+	if w := putMaintenance(r, `{"enabled":false}`); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), ErrCodeInvalidState) {
+		t.Errorf("got %d %s, want 409", w.Code, w.Body)
+	}
+}
+
+func TestSetMaintenanceMode(t *testing.T) {
+	useTestDatabase(t)
+	r := maintenanceRouter()
+	t.Setenv("MAINTENANCE_MODE", "")
+
+	w := putMaintenance(r, `{"enabled":true,"message":"  Upgrading  "}`)
+	var state config.MaintenanceState
+	json.Unmarshal(w.Body.Bytes(), &state)
+	if w.Code != http.StatusOK || !state.Enabled || state.Message != "Upgrading" || state.UpdatedBy != "admin@example.com" {
+		t.Errorf("got %d %+v, want maintenance on with the trimmed message", w.Code, state)
+	}
+	if !config.GetMaintenanceMode().Enabled {
+		t.Error("maintenance mode not enabled")
+	}
+
+	if w := putMaintenance(r, `{"enabled":false}`); w.Code != http.StatusOK || config.GetMaintenanceMode().Enabled {
+		t.Errorf("disable: status = %d, enabled = %v", w.Code, config.GetMaintenanceMode().Enabled)
+	}
+}
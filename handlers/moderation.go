@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// moderationContentHash - Identity of a message's content for whitelisting; case and
+// surrounding whitespace don't matter
+func moderationContentHash(content string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(content))))
+	return hex.EncodeToString(sum[:])
+}
+
+// isModerationWhitelisted - Whether a reviewer marked this content as a false positive for the
+// project. The moderation step checks it before flagging.
+func isModerationWhitelisted(ctx context.Context, projectID, content string) bool {
+	count, err := config.GetModerationFlagsCollection().CountDocuments(ctx, bson.M{
+		"project_id":   projectID,
+		"content_hash": moderationContentHash(content),
+		"status":       models.ModerationWhitelisted,
+	}, options.Count().SetLimit(1))
+	return err == nil && count > 0
+}
+
+// recordModerationFlag - Queue a flagged message for review. Best effort: failures are logged,
+// never surfaced to the visitor.
+func recordModerationFlag(ctx context.Context, flag models.ModerationFlag) {
+	if flag.ID.IsZero() {
+		flag.ID = primitive.NewObjectID()
+	}
+	flag.ContentHash = moderationContentHash(flag.Content)
+	flag.Status = models.ModerationPending
+	flag.CreatedAt = time.Now()
+
+	if _, err := config.GetModerationFlagsCollection().InsertOne(ctx, flag); err != nil {
+		log.Printf("⚠️ Failed to record moderation flag for project %s: %v", flag.ProjectID, err)
+	}
+}
+
+// GetModerationQueue - GET /api/admin/projects/:id/moderation?status=pending&direction=inbound
+// Flagged messages newest first, with the reason and the action taken. status: pending (default),
+// confirmed, whitelisted or all; direction: inbound or outbound.
+func GetModerationQueue(c *gin.Context) {
+	page, limit := parsePagination(c, 50)
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	filter := bson.M{"project_id": project.ProjectID}
+	switch status := c.DefaultQuery("status", models.ModerationPending); status {
+	case "all":
+	case models.ModerationPending, models.ModerationConfirmed, models.ModerationWhitelisted:
+		filter["status"] = status
+	default:
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "status must be 'pending', 'confirmed', 'whitelisted' or 'all'")
+		return
+	}
+	switch direction := c.Query("direction"); direction {
+	case "":
+	case models.ModerationInbound, models.ModerationOutbound:
+		filter["direction"] = direction
+	default:
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "direction must be 'inbound' or 'outbound'")
+		return
+	}
+
+	collection := config.GetModerationFlagsCollection()
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count moderation flags")
+		return
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get moderation flags")
+		return
+	}
+	flags := []models.ModerationFlag{}
+	if err := cursor.All(ctx, &flags); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to decode moderation flags")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"flags":      flags,
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  pageCount(total, limit),
+			"total_count":  total,
+			"limit":        limit,
+		},
+	})
+}
+
+// ReviewModerationFlag - PATCH /api/admin/projects/:id/moderation/:flagId
+// Body: {"status": "confirmed"} to uphold the flag, "whitelisted" to mark it a false positive (the
+// same content is then not flagged again for this project), or "pending" to reopen it.
+func ReviewModerationFlag(c *gin.Context) {
+	var body struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil ||
+		(body.Status != models.ModerationPending && body.Status != models.ModerationConfirmed && body.Status != models.ModerationWhitelisted) {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "status must be 'pending', 'confirmed' or 'whitelisted'")
+		return
+	}
+
+	flagID, err := primitive.ObjectIDFromHex(c.Param("flagId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid moderation flag ID")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"status": body.Status}}
+	if body.Status == models.ModerationPending {
+		update["$unset"] = bson.M{"reviewed_by": "", "reviewed_at": ""}
+	} else {
+		update["$set"].(bson.M)["reviewed_by"] = c.GetString("user_email")
+		update["$set"].(bson.M)["reviewed_at"] = time.Now()
+	}
+
+	var flag models.ModerationFlag
+	err = config.GetModerationFlagsCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": flagID, "project_id": project.ProjectID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&flag)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeModerationFlagNotFound, "Moderation flag not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update moderation flag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Moderation flag updated",
+		"moderation_flag": flag,
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestModerationContentHash(t *testing.T) {
+	if moderationContentHash("  Buy CHEAP pills ") != moderationContentHash("buy cheap pills") {
+		t.Error("case and surrounding whitespace changed the content hash")
+	}
+	if moderationContentHash("buy cheap pills") == moderationContentHash("buy cheap bills") {
+		t.Error("different content has the same hash")
+	}
+}
+
+// moderationRouter - Routes for the moderation queue, acting as reviewer@example.com
+func moderationRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_email", "reviewer@example.com") })
+	r.GET("/projects/:id/moderation", GetModerationQueue)
+	r.PATCH("/projects/:id/moderation/:flagId", ReviewModerationFlag)
+	return r
+}
+
+func reviewFlag(r *gin.Engine, projectID, flagID, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/projects/"+projectID+"/moderation/"+flagID, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestReviewModerationFlagValidatesInput(t *testing.T) {
+	r := moderationRouter()
+	flagID := primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name, flagID, body, wantMessage string
+	}{
+		{"malformed body", flagID, `{"status":`, "status must be 'pending', 'confirmed' or 'whitelisted'"},
+		{"missing status", flagID, `{}`, "status must be 'pending', 'confirmed' or 'whitelisted'"},
+		{"unknown status", flagID, `{"status":"deleted"}`, "status must be 'pending', 'confirmed' or 'whitelisted'"},
+		{"bad flag id", "flag_1", `{"status":"confirmed"}`, "Invalid moderation flag ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Refused before the project is looked up, so no database is needed
+			w := reviewFlag(r, "proj_1", tt.flagID, tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("got %d %s, want 400 %q", w.Code, w.Body, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestModerationQueue(t *testing.T) {
+	ctx := useTestDatabase(t)
+	r := moderationRouter()
+
+	config.GetProjectsCollection().InsertMany(ctx, []interface{}{
+		models.Project{ProjectID: "proj_mod"},
+		models.Project{ProjectID: "proj_other"},
+	})
+	inbound := models.ModerationFlag{ProjectID: "proj_mod", SessionID: "sess_1", Direction: models.ModerationInbound,
+		Content: "Buy cheap pills", Reason: "spam", Action: models.ModerationActionBlocked, Status: models.ModerationConfirmed}
+	recordModerationFlag(ctx, inbound)
+	recordModerationFlag(ctx, models.ModerationFlag{ProjectID: "proj_mod", SessionID: "sess_1", Direction: models.ModerationOutbound,
+		Content: "Here is some harsh language", Reason: "profanity", Action: models.ModerationActionRedacted})
+	recordModerationFlag(ctx, models.ModerationFlag{ProjectID: "proj_other", Direction: models.ModerationInbound, Content: "Buy cheap pills"})
+
+	var stored models.ModerationFlag
+	if err := config.GetModerationFlagsCollection().FindOne(ctx, bson.M{"project_id": "proj_mod", "direction": models.ModerationInbound}).Decode(&stored); err != nil {
+		t.Fatalf("flag not recorded: %v", err)
+	}
+	if stored.Status != models.ModerationPending || stored.ContentHash != moderationContentHash("buy cheap pills") || stored.CreatedAt.IsZero() {
+		t.Errorf("recorded flag = %+v, want it pending with a content hash", stored)
+	}
+
+	type queue struct {
+		Flags      []models.ModerationFlag `json:"flags"`
+		Pagination struct {
+			TotalCount int64 `json:"total_count"`
+		} `json:"pagination"`
+	}
+	get := func(projectID, query string) (int, queue) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/"+projectID+"/moderation"+query, nil))
+		var q queue
+		json.Unmarshal(w.Body.Bytes(), &q)
+		return w.Code, q
+	}
+
+	if code, q := get("proj_mod", ""); code != http.StatusOK || q.Pagination.TotalCount != 2 || q.Flags[0].Direction != models.ModerationOutbound {
+		t.Errorf("pending queue = %d %+v, want both flags newest first", code, q)
+	}
+	if _, q := get("proj_mod", "?direction=inbound"); len(q.Flags) != 1 || q.Flags[0].Reason != "spam" {
+		t.Errorf("inbound queue = %+v", q.Flags)
+	}
+	for query, want := range map[string]string{
+		"?status=open":      "status must be 'pending', 'confirmed', 'whitelisted' or 'all'",
+		"?direction=inward": "direction must be 'inbound' or 'outbound'",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_mod/moderation"+query, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: got %d %s, want 400 %q", query, w.Code, w.Body, want)
+		}
+	}
+	if code, _ := get("proj_missing", ""); code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", code)
+	}
+
+	// Whitelisting a false positive covers the same content for that project only
+	if isModerationWhitelisted(ctx, "proj_mod", "buy cheap pills") {
+		t.Error("pending flag counted as whitelisted")
+	}
+	w := reviewFlag(r, "proj_mod", stored.ID.Hex(), `{"status":"whitelisted"}`)
+	var resp struct {
+		Flag models.ModerationFlag `json:"moderation_flag"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Flag.Status != models.ModerationWhitelisted || resp.Flag.ReviewedBy != "reviewer@example.com" || resp.Flag.ReviewedAt == nil {
+		t.Errorf("whitelist = %d %+v", w.Code, resp.Flag)
+	}
+	if !isModerationWhitelisted(ctx, "proj_mod", "  BUY CHEAP PILLS") {
+		t.Error("whitelisted content is still flagged")
+	}
+	if isModerationWhitelisted(ctx, "proj_other", "Buy cheap pills") {
+		t.Error("a whitelist leaked to another project")
+	}
+	if _, q := get("proj_mod", "?status=whitelisted"); len(q.Flags) != 1 {
+		t.Errorf("whitelisted queue = %+v", q.Flags)
+	}
+	if _, q := get("proj_mod", "?status=all"); q.Pagination.TotalCount != 2 {
+		t.Errorf("all = %d flags, want 2", q.Pagination.TotalCount)
+	}
+
+	// Reopening clears the review
+	w = reviewFlag(r, "proj_mod", stored.ID.Hex(), `{"status":"pending"}`)
+	resp.Flag = models.ModerationFlag{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Flag.Status != models.ModerationPending || resp.Flag.ReviewedBy != "" || resp.Flag.ReviewedAt != nil {
+		t.Errorf("reopen = %d %+v, want the review cleared", w.Code, resp.Flag)
+	}
+	if isModerationWhitelisted(ctx, "proj_mod", "buy cheap pills") {
+		t.Error("reopened flag still whitelists its content")
+	}
+
+	// A flag is only reachable through its own project
+	if w := reviewFlag(r, "proj_other", stored.ID.Hex(), `{"status":"confirmed"}`); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), ErrCodeModerationFlagNotFound) {
+		t.Errorf("another project's flag = %d %s, want 404", w.Code, w.Body)
+	}
+	if w := reviewFlag(r, "proj_missing", stored.ID.Hex(), `{"status":"confirmed"}`); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), ErrCodeProjectNotFound) {
+		t.Errorf("unknown project = %d %s, want 404", w.Code, w.Body)
+	}
+}
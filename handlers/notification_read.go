@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+)
+
+// unreadFilter - Narrow a notifications filter to the ones no admin has read yet
+func unreadFilter(filter bson.M) bson.M {
+	unread := bson.M{"read_at": bson.M{"$exists": false}}
+	for key, value := range filter {
+		unread[key] = value
+	}
+	return unread
+}
+
+// countUnreadNotifications - Unread notifications matching the filter
+func countUnreadNotifications(ctx context.Context, filter bson.M) (int64, error) {
+	return config.GetNotificationsCollection().CountDocuments(ctx, unreadFilter(filter))
+}
+
+// MarkNotificationRead - POST /api/admin/notifications/:id/read
+// Marking an already read notification again keeps the original read_at and read_by.
+func MarkNotificationRead(c *gin.Context) {
+	notificationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid notification ID")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	collection := config.GetNotificationsCollection()
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": notificationID, "read_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"read_at": time.Now(), "read_by": c.GetString("user_id")}},
+	)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to mark notification as read")
+		return
+	}
+
+	var notification bson.M
+	err = collection.FindOne(ctx, bson.M{"_id": notificationID},
+		options.FindOne().SetProjection(bson.M{"read_at": 1, "read_by": 1})).Decode(&notification)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeNotificationNotFound, "Notification not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get notification")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      notificationID.Hex(),
+		"read":    true,
+		"read_at": notification["read_at"],
+		"read_by": notification["read_by"],
+	})
+}
+
+// MarkAllNotificationsRead - POST /api/admin/notifications/read-all
+// Takes the same optional type and project_id filters as the notification list.
+func MarkAllNotificationsRead(c *gin.Context) {
+	filter := bson.M{}
+	if notificationType := c.Query("type"); notificationType != "" {
+		filter["type"] = notificationType
+	}
+	if projectID := c.Query("project_id"); projectID != "" {
+		objID, err := primitive.ObjectIDFromHex(projectID)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid project_id")
+			return
+		}
+		filter["project_id"] = objID
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	result, err := config.GetNotificationsCollection().UpdateMany(ctx,
+		unreadFilter(filter),
+		bson.M{"$set": bson.M{"read_at": time.Now(), "read_by": c.GetString("user_id")}},
+	)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to mark notifications as read")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"marked_read": result.ModifiedCount,
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+)
+
+// notificationRouter - The notification routes as main.go mounts them, signed in as userID
+func notificationRouter(userID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	r.GET("/notifications", GetNotificationHistory)
+	r.POST("/notifications/read-all", MarkAllNotificationsRead)
+	r.POST("/notifications/:id/read", MarkNotificationRead)
+	return r
+}
+
+func TestUnreadFilter(t *testing.T) {
+	filter := bson.M{"type": "test"}
+	want := bson.M{"type": "test", "read_at": bson.M{"$exists": false}}
+	if got := unreadFilter(filter); !reflect.DeepEqual(got, want) {
+		t.Errorf("unreadFilter = %v, want %v", got, want)
+	}
+	if len(filter) != 1 {
+		t.Error("unreadFilter modified the filter it was given")
+	}
+}
+
+func TestMarkNotificationsReadValidatesInput(t *testing.T) {
+	r := notificationRouter("admin_1")
+
+	tests := []struct {
+		name, path, want string
+	}{
+		{"bad notification id", "/notifications/n_1/read", "Invalid notification ID"},
+		{"bad project filter", "/notifications/read-all?project_id=proj_1", "Invalid project_id"},
+	}
+	for _, tt := range tests {
+		// Refused before the database is touched
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: got %d %s, want 400 %q", tt.name, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	projectA, projectB := primitive.NewObjectID(), primitive.NewObjectID()
+	first, _ := config.RecordNotification(projectA, config.NotificationUsageWarning, "80% used")
+	config.RecordNotification(projectA, config.NotificationExpired, "Expired")
+	config.RecordNotification(projectB, config.NotificationUsageWarning, "80% used")
+
+	unread := func(r *gin.Engine, query string) int64 {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/notifications"+query, nil))
+		var resp struct {
+			UnreadCount int64 `json:"unread_count"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.UnreadCount
+	}
+	post := func(r *gin.Engine, path string) map[string]interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST %s: %d %s", path, w.Code, w.Body)
+		}
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	alice, bob := notificationRouter("alice"), notificationRouter("bob")
+	if got := unread(alice, ""); got != 3 {
+		t.Errorf("unread_count = %d, want 3", got)
+	}
+
+	resp := post(alice, "/notifications/"+first.Hex()+"/read")
+	if resp["read"] != true || resp["read_by"] != "alice" {
+		t.Errorf("mark read = %v", resp)
+	}
+	// Marking it again keeps who read it first
+	time.Sleep(10 * time.Millisecond)
+	if again := post(bob, "/notifications/"+first.Hex()+"/read"); again["read_by"] != "alice" || again["read_at"] != resp["read_at"] {
+		t.Errorf("second mark = %v, want the original read_at and read_by", again)
+	}
+	if got := unread(alice, ""); got != 2 {
+		t.Errorf("unread_count after one read = %d, want 2", got)
+	}
+
+	if resp := post(bob, "/notifications/read-all?type=usage_warning"); resp["marked_read"] != float64(1) {
+		t.Errorf("read-all by type marked %v, want the one unread usage warning", resp["marked_read"])
+	}
+	if got := unread(alice, "?project_id="+projectA.Hex()); got != 1 {
+		t.Errorf("project A unread_count = %d, want its expiry notification", got)
+	}
+	if resp := post(bob, "/notifications/read-all?project_id="+projectA.Hex()); resp["marked_read"] != float64(1) {
+		t.Errorf("read-all by project marked %v, want 1", resp["marked_read"])
+	}
+	if got := unread(alice, ""); got != 0 {
+		t.Errorf("unread_count = %d, want 0", got)
+	}
+
+	var stored bson.M
+	config.GetNotificationsCollection().FindOne(ctx, bson.M{"project_id": projectB}).Decode(&stored)
+	if stored["read_by"] != "bob" {
+		t.Errorf("read_by = %v, want the admin who marked all read", stored["read_by"])
+	}
+
+	w := httptest.NewRecorder()
+	alice.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notifications/"+primitive.NewObjectID().Hex()+"/read", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), ErrCodeNotificationNotFound) {
+		t.Errorf("unknown notification: got %d %s, want 404", w.Code, w.Body)
+	}
+}
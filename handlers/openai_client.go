@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// errOpenAINotConfigured - OPENAI_API_KEY is unset or blank, so every OpenAI call would fail
+// authentication; callers check for it up front instead of sending a keyless request
+var errOpenAINotConfigured = errors.New("OpenAI authentication not configured: OPENAI_API_KEY is empty")
+
+var openAIKeyWarning sync.Once
+
+// openAIBaseURL - API endpoint; empty means OpenAI's own. Tests point it at a stub server.
+var openAIBaseURL = ""
+
+// openAIConfigured - Whether an OpenAI API key is set
+func openAIConfigured() bool {
+	return strings.TrimSpace(os.Getenv("OPENAI_API_KEY")) != ""
+}
+
+// newOpenAIClient - Client for the shared OpenAI account, or errOpenAINotConfigured. The first
+// miss is logged loudly for operators; afterwards callers decide how to degrade.
+func newOpenAIClient() (*openai.Client, error) {
+	apiKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+	if apiKey == "" {
+		openAIKeyWarning.Do(func() {
+			log.Printf("🔑 OPENAI_API_KEY is not set: OpenAI chat answers and document embeddings are disabled until it is configured")
+		})
+		return nil, errOpenAINotConfigured
+	}
+	clientConfig := openai.DefaultConfig(apiKey)
+	if openAIBaseURL != "" {
+		clientConfig.BaseURL = openAIBaseURL
+	}
+	return openai.NewClientWithConfig(clientConfig), nil
+}
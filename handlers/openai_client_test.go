@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"jevi-chat/models"
+)
+
+func TestNewOpenAIClient(t *testing.T) {
+	for _, key := range []string{"", "   "} {
+		t.Setenv("OPENAI_API_KEY", key)
+		if openAIConfigured() {
+			t.Errorf("openAIConfigured() with %q = true", key)
+		}
+		if client, err := newOpenAIClient(); client != nil || !errors.Is(err, errOpenAINotConfigured) {
+			t.Errorf("newOpenAIClient() with %q = %v, %v; want errOpenAINotConfigured", key, client, err)
+		}
+	}
+
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	if client, err := newOpenAIClient(); client == nil || err != nil || !openAIConfigured() {
+		t.Errorf("newOpenAIClient() with a key = %v, %v", client, err)
+	}
+}
+
+func TestRetryableProviderError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("503 service unavailable"), true},
+		{errOpenAINotConfigured, false},
+		{fmt.Errorf("openai: %w", errOpenAINotConfigured), false},
+		{errPromptTooLarge, false},
+	}
+	for _, tt := range tests {
+		if got := retryableProviderError(tt.err); got != tt.want {
+			t.Errorf("retryableProviderError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAICallsWithoutKeyAreNotSent(t *testing.T) {
+	sent := 0
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		http.Error(w, "unexpected request", http.StatusUnauthorized)
+	})
+	t.Setenv("OPENAI_API_KEY", "")
+
+	if _, err := generateBatchEmbeddings([]string{"text"}, models.DefaultEmbeddingModel); !errors.Is(err, errOpenAINotConfigured) {
+		t.Errorf("generateBatchEmbeddings: err = %v, want errOpenAINotConfigured", err)
+	}
+	if _, _, err := summarizeTurns(context.Background(), "", []models.ChatMessage{{Message: "hi", Response: "hello"}}); !errors.Is(err, errOpenAINotConfigured) {
+		t.Errorf("summarizeTurns: err = %v, want errOpenAINotConfigured", err)
+	}
+	if sent != 0 {
+		t.Errorf("%d keyless requests reached the API", sent)
+	}
+
+	// Indexed documents fall back to lexical retrieval instead of embedding the query
+	project := &models.Project{ProjectID: "proj_1", PDFFiles: []models.PDFFile{
+		{ID: "doc_1", FileName: "hours.pdf", Content: "Opening hours are nine to five.", ChunksIndexed: 1},
+	}}
+	if _, method := retrieveChunks(project, "opening hours", 5); method != retrievalLexical {
+		t.Errorf("retrieval method = %s, want lexical without an API key", method)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"jevi-chat/utils"
+)
+
+// openAIRateLimitPause - How long every OpenAI request is held back after a 429
+const openAIRateLimitPause = 5 * time.Second
+
+// waitForOpenAI - Block until the shared account limits (OPENAI_RPM_LIMIT / OPENAI_TPM_LIMIT)
+// have room for a request of about estimatedTokens
+func waitForOpenAI(ctx context.Context, estimatedTokens int) error {
+	return utils.OpenAIRateLimiter().Wait(ctx, estimatedTokens)
+}
+
+// settleOpenAI - Record the outcome of a paced request: correct the token estimate with the
+// real usage, or pause all requests briefly when OpenAI still answered 429, so the retry in
+// callProviderWithRetry waits for the account to recover
+func settleOpenAI(estimatedTokens, usedTokens int, err error) {
+	limiter := utils.OpenAIRateLimiter()
+	if err != nil {
+		if isOpenAIRateLimited(err) {
+			limiter.Pause(openAIRateLimitPause)
+		}
+		return
+	}
+	limiter.Adjust(usedTokens - estimatedTokens)
+}
+
+// isOpenAIRateLimited - Whether err is an HTTP 429 from OpenAI
+func isOpenAIRateLimited(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// estimateChatTokens - Prompt size (see countTokens) plus the completion budget
+func estimateChatTokens(messages []openai.ChatCompletionMessage, maxTokens int) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += countTokens(message.Content)
+	}
+	return tokens + maxTokens
+}
+
+// estimateTextTokens - Token count of texts such as embedding inputs (see countTokens)
+func estimateTextTokens(texts ...string) int {
+	tokens := 0
+	for _, text := range texts {
+		tokens += countTokens(text)
+	}
+	return tokens
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestIsOpenAIRateLimited(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"api 429", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, true},
+		{"wrapped api 429", fmt.Errorf("chat: %w", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}), true},
+		{"request 429", &openai.RequestError{HTTPStatusCode: http.StatusTooManyRequests}, true},
+		{"api 500", &openai.APIError{HTTPStatusCode: http.StatusInternalServerError}, false},
+		{"request 503", &openai.RequestError{HTTPStatusCode: http.StatusServiceUnavailable}, false},
+		{"network error", errors.New("connection reset"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := isOpenAIRateLimited(tt.err); got != tt.want {
+			t.Errorf("%s: isOpenAIRateLimited = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "You are helpful."},
+		{Role: openai.ChatMessageRoleUser, Content: "When do you open?"},
+	}
+	prompt := countTokens("You are helpful.") + countTokens("When do you open?")
+	if got := estimateChatTokens(messages, 500); got != prompt+500 {
+		t.Errorf("estimateChatTokens = %d, want prompt %d plus the 500 completion budget", got, prompt)
+	}
+
+	if got, want := estimateTextTokens("first chunk", "second chunk"), countTokens("first chunk")+countTokens("second chunk"); got != want {
+		t.Errorf("estimateTextTokens = %d, want %d", got, want)
+	}
+	if got := estimateTextTokens(); got != 0 {
+		t.Errorf("estimateTextTokens() = %d, want 0", got)
+	}
+}
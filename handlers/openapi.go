@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIVersion - Version of the API contract published at /swagger/doc.json
+const openAPIVersion = "1.0.0"
+
+// routeDoc - Hand-written details for one route; routes without an entry are still listed,
+// with a summary derived from the path
+type routeDoc struct {
+	Summary  string
+	Request  string   // component schema name of the JSON body
+	Response string   // component schema name of the 2xx JSON body
+	Query    []string // documented query parameters
+	Status   int      // success status (default 200)
+}
+
+// routeDocs - Keyed by "METHOD /gin/path"
+var routeDocs = map[string]routeDoc{
+	"GET /api/health": {Summary: "Service health; 503 until startup verification completes"},
+	"GET /readyz":     {Summary: "Readiness probe; 503 until the database is verified and reachable"},
+
+	"POST /api/auth/login":    {Summary: "Log in and receive a JWT (also set as an HttpOnly cookie with a CSRF cookie)", Request: "LoginRequest", Response: "AuthResponse"},
+	"POST /api/auth/register": {Summary: "Register a user account", Request: "RegisterRequest", Response: "AuthResponse", Status: http.StatusCreated},
+	"POST /api/auth/logout":   {Summary: "Log out, clearing the auth and CSRF cookies"},
+	"GET /api/auth/verify":    {Summary: "Verify the bearer token"},
+	"GET /api/auth/csrf":      {Summary: "Issue the CSRF cookie and token for cookie-authenticated requests"},
+
+	"POST /api/projects/:projectId/chat":        {Summary: "Send a visitor chat message", Request: "ChatRequest", Response: "ChatResponse"},
+	"POST /api/projects/:projectId/greeting":    {Summary: "Welcome message, or a generated opener recorded as the session's first message", Request: "GreetingRequest", Response: "GreetingResponse"},
+	"POST /api/projects/:projectId/lead":        {Summary: "Store the visitor's name and email as a lead linked to the session", Request: "LeadRequest"},
+	"GET /api/projects/:projectId/history":      {Summary: "Chat history for a session", Query: []string{"session_id"}},
+	"GET /api/projects/:projectId/subscription": {Summary: "Public subscription status for the widget", Response: "SubscriptionStatus"},
+	"GET /api/projects/:projectId/quota":        {Summary: "Approximate messages left for the widget", Response: "Quota"},
+
+	"GET /api/embed/:projectId/config": {Summary: "Widget configuration"},
+	"GET /api/embed/health":            {Summary: "Embed API health and version", Query: []string{"project_id"}},
+
+	"GET /api/user/profile":              {Summary: "Current user profile"},
+	"GET /api/user/projects":             {Summary: "Projects owned by the current user", Response: "ProjectList", Query: []string{"page", "limit", "status"}},
+	"GET /api/user/projects/:id":         {Summary: "One project owned by the current user"},
+	"POST /api/user/projects/:id/pause":  {Summary: "Switch the bot off without affecting the subscription", Request: "PauseChatRequest"},
+	"POST /api/user/projects/:id/resume": {Summary: "Switch a paused bot back on"},
+	"POST /api/user/change-password":     {Summary: "Change the current user's password"},
+
+	"GET /api/admin/projects":                               {Summary: "List projects", Response: "ProjectList", Query: []string{"page", "limit", "status", "search", "sort", "order", "created_by"}},
+	"POST /api/admin/projects":                              {Summary: "Create a project (multipart form with optional pdf_files)", Status: http.StatusCreated},
+	"POST /api/admin/projects/import":                       {Summary: "Bulk-create projects from a CSV file", Response: "ImportResult"},
+	"POST /api/admin/projects/import-config":                {Summary: "Create a project (new project_id) from an exported configuration", Request: "ProjectConfig", Status: http.StatusCreated},
+	"GET /api/admin/projects/:id/export-config":             {Summary: "Download the project's settings as JSON, without secrets or usage", Response: "ProjectConfig"},
+	"POST /api/admin/projects/:id/pause":                    {Summary: "Switch the bot off without affecting the subscription", Request: "PauseChatRequest"},
+	"POST /api/admin/projects/:id/resume":                   {Summary: "Switch a paused bot back on"},
+	"GET /api/admin/projects/:id":                           {Summary: "Project details with analytics"},
+	"PATCH /api/admin/projects/:id":                         {Summary: "Update project settings"},
+	"DELETE /api/admin/projects/:id":                        {Summary: "Delete a project"},
+	"POST /api/admin/projects/:id/renew":                    {Summary: "Renew a project's subscription"},
+	"GET /api/admin/projects/:id/usage":                     {Summary: "Token usage, overage and chat statistics", Query: []string{"days"}},
+	"GET /api/admin/projects/:id/usage/series":              {Summary: "Tokens, cost and requests bucketed by hour or day, zero-filled, for charts", Query: []string{"interval", "from", "to"}},
+	"POST /api/admin/projects/:id/usage/reset":              {Summary: "Reset token usage to zero"},
+	"POST /api/admin/projects/:id/usage/reset-all":          {Summary: "Reset usage and archive usage logs (and optionally chat history)", Request: "UsageResetRequest"},
+	"POST /api/admin/projects/:id/usage/adjust":             {Summary: "Credit or debit token usage", Request: "UsageAdjustRequest"},
+	"GET /api/admin/projects/:id/activity":                  {Summary: "Project activity timeline, newest first", Query: []string{"page", "limit", "type"}},
+	"GET /api/admin/projects/:id/tools":                     {Summary: "Tools the bot can call for this project"},
+	"PUT /api/admin/projects/:id/tools":                     {Summary: "Replace the project's tools (name, description, parameters, webhook_url, enabled)"},
+	"POST /api/admin/projects/:id/ai/validate":              {Summary: "Test the project's AI provider, fallback and embedding model with tiny unbilled calls"},
+	"POST /api/admin/projects/:id/retrieve/preview":         {Summary: "Preview the chunks a query would retrieve, with scores", Request: "RetrievalPreviewRequest"},
+	"PATCH /api/admin/clients/:clientId/notification-prefs": {Summary: "Change a client's notification preferences (SMS, daily digest, ...)", Request: "ClientNotificationPrefsRequest"},
+	"GET /api/admin/notifications":                          {Summary: "Notification history with the unread count", Query: []string{"page", "limit", "cursor", "type", "project_id"}},
+	"POST /api/admin/notifications/:id/read":                {Summary: "Mark a notification as read"},
+	"POST /api/admin/notifications/read-all":                {Summary: "Mark every unread notification (optionally of one type or project) as read", Query: []string{"type", "project_id"}},
+	"GET /api/admin/reports/top-projects":                   {Summary: "Projects ranked by tokens, cost or messages over a period", Query: []string{"metric", "from", "to", "limit"}},
+	"GET /api/admin/maintenance":                            {Summary: "Whether chat is paused for maintenance"},
+	"PUT /api/admin/maintenance":                            {Summary: "Pause or resume chat and widget traffic (503 while paused)", Request: "MaintenanceModeRequest"},
+	"POST /api/admin/users/:id/unlock":                      {Summary: "Clear a user's failed login attempts and lockout"},
+	"GET /api/admin/projects/:id/messages/export":           {Summary: "Stream the chat history as CSV or a JSON array; gzip-encoded when Accept-Encoding allows", Query: []string{"format", "from", "to", "session_id"}},
+	"POST /api/admin/projects/:id/users/:userId/erase":      {Summary: "Erase a widget user: delete their account, sessions and messages and anonymize their usage logs"},
+	"GET /api/admin/projects/:id/leads":                     {Summary: "Captured leads with message counts; format=csv downloads them", Query: []string{"page", "limit", "from", "to", "format"}},
+	"GET /api/admin/projects/:id/knowledge-gaps":            {Summary: "Unanswered and down-rated questions, clustered by similar wording with counts", Query: []string{"page", "limit", "status", "reason", "from", "to", "min_count"}},
+	"PATCH /api/admin/projects/:id/knowledge-gaps/:gapId":   {Summary: "Resolve or reopen a knowledge gap", Request: "KnowledgeGapUpdateRequest"},
+	"GET /api/admin/projects/:id/moderation":                {Summary: "Flagged messages awaiting review, with reason and action taken", Query: []string{"page", "limit", "status", "direction"}},
+	"PATCH /api/admin/projects/:id/moderation/:flagId":      {Summary: "Confirm a flag, whitelist it as a false positive, or reopen it"},
+	"POST /api/admin/maintenance/subscriptions":             {Summary: "Run subscription maintenance", Query: []string{"dry_run"}},
+	"POST /api/admin/maintenance/reindex":                   {Summary: "Start a background re-embedding job", Status: http.StatusAccepted},
+}
+
+// openAPISchemas - Component schemas referenced by routeDocs
+var openAPISchemas = map[string]interface{}{
+	"Error": schemaObject(map[string]interface{}{
+		"error": schemaString(), "code": schemaString(),
+	}, "error", "code"),
+	"LoginRequest": schemaObject(map[string]interface{}{
+		"email": schemaString(), "password": schemaString(),
+	}, "email", "password"),
+	"RegisterRequest": schemaObject(map[string]interface{}{
+		"name": schemaString(), "email": schemaString(), "password": map[string]interface{}{"type": "string", "minLength": 8},
+	}, "name", "email", "password"),
+	"AuthResponse": schemaObject(map[string]interface{}{
+		"token": schemaString(), "csrf_token": schemaString(), "user": map[string]interface{}{"type": "object"},
+	}),
+	"ChatRequest": schemaObject(map[string]interface{}{
+		"message": schemaString(), "session_id": schemaString(), "user_id": schemaString(), "captcha_token": schemaString(),
+		"page_url": schemaString(), "page_title": schemaString(), "page_excerpt": schemaString(),
+	}, "message"),
+	"ChatResponse": schemaObject(map[string]interface{}{
+		"status": schemaString(), "session_id": schemaString(), "response": schemaString(), "tokens_used": schemaInteger(),
+		"used_document_context": schemaBoolean(), "provider": schemaString(), "fallback_used": schemaBoolean(),
+		"usage": map[string]interface{}{"type": "object"},
+	}),
+	"GreetingRequest": schemaObject(map[string]interface{}{
+		"session_id": schemaString(), "user_id": schemaString(), "page_url": schemaString(), "page_title": schemaString(),
+	}),
+	"GreetingResponse": schemaObject(map[string]interface{}{
+		"session_id": schemaString(), "visitor_token": schemaString(), "greeting": schemaString(),
+		"generated": schemaBoolean(), "message_id": schemaString(),
+	}),
+	"ClientNotificationPrefsRequest": schemaObject(map[string]interface{}{
+		"email_notifications": schemaBoolean(), "sms_notifications": schemaBoolean(), "expiry_reminders": schemaBoolean(),
+		"usage_alerts": schemaBoolean(), "maintenance_updates": schemaBoolean(), "daily_digest": schemaBoolean(),
+		"phone": schemaString(),
+	}),
+	"ProjectConfig": schemaObject(map[string]interface{}{
+		"version": schemaInteger(), "name": schemaString(), "description": schemaString(), "category": schemaString(),
+		"widget_settings": map[string]interface{}{"type": "object"},
+		"ai_provider":     schemaString(), "fallback_provider": schemaString(), "openai_model": schemaString(), "embedding_model": schemaString(),
+		"system_prompt": schemaString(), "cant_answer_message": schemaString(), "redact_pii": schemaBoolean(),
+		"monthly_token_limit": schemaInteger(), "overage_policy": schemaString(),
+		"usage_warning_thresholds": map[string]interface{}{"type": "array", "items": schemaInteger()},
+		"tools":                    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+	}, "version", "name"),
+	"PauseChatRequest": schemaObject(map[string]interface{}{
+		"message": schemaString(),
+	}),
+	"MaintenanceModeRequest": schemaObject(map[string]interface{}{
+		"enabled": schemaBoolean(), "message": schemaString(),
+	}, "enabled"),
+	"LeadRequest": schemaObject(map[string]interface{}{
+		"session_id": schemaString(), "name": schemaString(), "email": schemaString(),
+	}, "session_id", "email"),
+	"SubscriptionStatus": schemaObject(map[string]interface{}{
+		"project_id": schemaString(), "status": schemaString(), "is_active": schemaBoolean(), "captcha_required": schemaBoolean(),
+		"expiry_date": schemaString(), "remaining_tokens": schemaInteger(), "usage_percentage": schemaNumber(),
+	}),
+	"Quota": schemaObject(map[string]interface{}{
+		"project_id": schemaString(), "status": schemaString(), "messages_left": schemaInteger(), "approximate": schemaBoolean(),
+		"tokens_per_message": schemaInteger(), "low_quota": schemaBoolean(), "resets_at": schemaString(),
+	}),
+	"ProjectList": schemaObject(map[string]interface{}{
+		"projects":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+		"pagination": map[string]interface{}{"type": "object"},
+	}),
+	"ImportResult": schemaObject(map[string]interface{}{
+		"created": schemaInteger(), "failed": schemaInteger(),
+		"results": map[string]interface{}{"type": "array", "items": schemaObject(map[string]interface{}{
+			"row": schemaInteger(), "name": schemaString(), "success": schemaBoolean(), "project_id": schemaString(), "embed_code": schemaString(), "error": schemaString(),
+		})},
+	}),
+	"UsageResetRequest": schemaObject(map[string]interface{}{
+		"confirm_project_id": schemaString(), "include_chat_history": schemaBoolean(),
+	}, "confirm_project_id"),
+	"KnowledgeGapUpdateRequest": schemaObject(map[string]interface{}{
+		"status": schemaString(),
+	}, "status"),
+	"RetrievalPreviewRequest": schemaObject(map[string]interface{}{
+		"query": schemaString(), "k": schemaInteger(),
+	}, "query"),
+	"UsageAdjustRequest": schemaObject(map[string]interface{}{
+		"delta": schemaInteger(), "reason": schemaString(),
+	}, "delta", "reason"),
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPISpec map[string]interface{}
+)
+
+// OpenAPISpec - GET /swagger/doc.json
+// The spec is generated from the router's registered routes on first request, so new routes
+// appear automatically; routeDocs adds summaries and schemas for the main ones.
+func OpenAPISpec(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openAPIOnce.Do(func() {
+			openAPISpec = buildOpenAPISpec(router.Routes())
+		})
+		c.JSON(http.StatusOK, openAPISpec)
+	}
+}
+
+// SwaggerUI - GET /swagger: Swagger UI for /swagger/doc.json
+func SwaggerUI(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	// Swagger UI assets come from unpkg; loosen the default CSP for this page only
+	c.Header("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' https://unpkg.com; style-src 'self' 'unsafe-inline' https://unpkg.com; img-src 'self' data:")
+	c.String(http.StatusOK, `<!DOCTYPE html>
+<html>
+<head>
+  <title>Troika Chat API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({ url: "/swagger/doc.json", dom_id: "#swagger-ui" });</script>
+</body>
+</html>`)
+}
+
+// buildOpenAPISpec - OpenAPI 3 document for the /api routes
+func buildOpenAPISpec(routes gin.RoutesInfo) map[string]interface{} {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/api/") || route.Method == http.MethodOptions {
+			continue
+		}
+
+		openAPIPath, params := openAPIPathParams(route.Path)
+		item, ok := paths[openAPIPath].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[openAPIPath] = item
+		}
+		item[strings.ToLower(route.Method)] = openAPIOperation(route, params)
+	}
+
+	serverURL := os.Getenv("BASE_URL")
+	if serverURL == "" {
+		serverURL = "/"
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Troika Chat API",
+			"version": openAPIVersion,
+		},
+		"servers": []interface{}{map[string]interface{}{"url": serverURL}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// openAPIOperation - One operation, from routeDocs when documented
+func openAPIOperation(route gin.RouteInfo, params []string) map[string]interface{} {
+	doc := routeDocs[route.Method+" "+route.Path]
+	if doc.Summary == "" {
+		doc.Summary = route.Method + " " + route.Path
+	}
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	parameters := []interface{}{}
+	for _, name := range params {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": schemaString(),
+		})
+	}
+	for _, name := range doc.Query {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "query", "required": false, "schema": schemaString(),
+		})
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if doc.Response != "" {
+		success["content"] = jsonContent(doc.Response)
+	}
+	responses := map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default":            map[string]interface{}{"description": "Error", "content": jsonContent("Error")},
+	}
+
+	operation := map[string]interface{}{
+		"summary":     doc.Summary,
+		"tags":        []string{openAPITag(route.Path)},
+		"operationId": openAPIOperationID(route),
+		"parameters":  parameters,
+		"responses":   responses,
+	}
+	if doc.Request != "" {
+		operation["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(doc.Request)}
+	}
+	if strings.HasPrefix(route.Path, "/api/admin/") || strings.HasPrefix(route.Path, "/api/user/") {
+		operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	return operation
+}
+
+// openAPIPathParams - Convert /a/:id/b to /a/{id}/b and list the parameters
+func openAPIPathParams(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// openAPITag - Group by area: auth, chat, embed, user, admin
+func openAPITag(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	switch parts[0] {
+	case "projects":
+		return "chat"
+	case "":
+		return "system"
+	default:
+		return parts[0]
+	}
+}
+
+// openAPIOperationID - Stable id from method and path, e.g. get_api_admin_projects_id
+func openAPIOperationID(route gin.RouteInfo) string {
+	return strings.ToLower(route.Method) + strings.NewReplacer("/", "_", ":", "", "*", "", "-", "_").Replace(route.Path)
+}
+
+func jsonContent(schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": map[string]interface{}{"$ref": "#/components/schemas/" + schema},
+		},
+	}
+}
+
+func schemaObject(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func schemaString() map[string]interface{}  { return map[string]interface{}{"type": "string"} }
+func schemaInteger() map[string]interface{} { return map[string]interface{}{"type": "integer"} }
+func schemaNumber() map[string]interface{}  { return map[string]interface{}{"type": "number"} }
+func schemaBoolean() map[string]interface{} { return map[string]interface{}{"type": "boolean"} }
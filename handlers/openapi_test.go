@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPIPathParams(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantPath   string
+		wantParams []string
+	}{
+		{"/api/health", "/api/health", nil},
+		{"/api/admin/projects/:id", "/api/admin/projects/{id}", []string{"id"}},
+		{"/api/admin/projects/:id/users/:userId/erase", "/api/admin/projects/{id}/users/{userId}/erase", []string{"id", "userId"}},
+		{"/static/*filepath", "/static/{filepath}", []string{"filepath"}},
+	}
+
+	for _, tt := range tests {
+		path, params := openAPIPathParams(tt.path)
+		if path != tt.wantPath || !reflect.DeepEqual(params, tt.wantParams) {
+			t.Errorf("openAPIPathParams(%q) = %q %v, want %q %v", tt.path, path, params, tt.wantPath, tt.wantParams)
+		}
+	}
+}
+
+func TestOpenAPITagAndOperationID(t *testing.T) {
+	tests := []struct {
+		method, path string
+		wantTag      string
+		wantID       string
+	}{
+		{"GET", "/api/admin/projects/:id", "admin", "get_api_admin_projects_id"},
+		{"POST", "/api/projects/:projectId/chat", "chat", "post_api_projects_projectId_chat"},
+		{"POST", "/api/admin/projects/import-config", "admin", "post_api_admin_projects_import_config"},
+		{"GET", "/api/", "system", "get_api_"},
+	}
+
+	for _, tt := range tests {
+		if tag := openAPITag(tt.path); tag != tt.wantTag {
+			t.Errorf("openAPITag(%q) = %q, want %q", tt.path, tag, tt.wantTag)
+		}
+		if id := openAPIOperationID(gin.RouteInfo{Method: tt.method, Path: tt.path}); id != tt.wantID {
+			t.Errorf("openAPIOperationID(%s %s) = %q, want %q", tt.method, tt.path, id, tt.wantID)
+		}
+	}
+}
+
+func TestRouteDocsReferenceKnownSchemas(t *testing.T) {
+	for route, doc := range routeDocs {
+		for _, schema := range []string{doc.Request, doc.Response} {
+			if schema == "" {
+				continue
+			}
+			if _, ok := openAPISchemas[schema]; !ok {
+				t.Errorf("%s references undefined schema %q", route, schema)
+			}
+		}
+	}
+}
+
+func TestBuildOpenAPISpec(t *testing.T) {
+	routes := gin.RoutesInfo{
+		{Method: http.MethodPost, Path: "/api/auth/register"},
+		{Method: http.MethodGet, Path: "/api/admin/projects"},
+		{Method: http.MethodDelete, Path: "/api/admin/projects/:id"},
+		{Method: http.MethodGet, Path: "/api/admin/projects/:id"},
+		{Method: http.MethodGet, Path: "/api/undocumented/:thing"},
+		{Method: http.MethodOptions, Path: "/api/admin/projects"},
+		{Method: http.MethodGet, Path: "/swagger"},
+	}
+
+	spec := buildOpenAPISpec(routes)
+	paths := spec["paths"].(map[string]interface{})
+	if len(paths) != 4 {
+		t.Fatalf("got %d paths, want only the four /api paths", len(paths))
+	}
+	if _, ok := paths["/swagger"]; ok {
+		t.Error("non-/api routes should not be documented")
+	}
+
+	projects := paths["/api/admin/projects"].(map[string]interface{})
+	if _, ok := projects["options"]; ok {
+		t.Error("OPTIONS routes should not be documented")
+	}
+	list := projects["get"].(map[string]interface{})
+	if list["summary"] != "List projects" || list["security"] == nil {
+		t.Errorf("list operation = %v, want documented summary and bearer security", list)
+	}
+	if query := list["parameters"].([]interface{}); len(query) != len(routeDocs["GET /api/admin/projects"].Query) {
+		t.Errorf("list has %d parameters, want the documented query parameters", len(query))
+	}
+
+	byID := paths["/api/admin/projects/{id}"].(map[string]interface{})
+	if _, ok := byID["get"]; !ok {
+		t.Error("GET and DELETE on the same path should share one path item")
+	}
+	remove := byID["delete"].(map[string]interface{})
+	param := remove["parameters"].([]interface{})[0].(map[string]interface{})
+	if param["name"] != "id" || param["in"] != "path" || param["required"] != true {
+		t.Errorf("path parameter = %v", param)
+	}
+
+	register := paths["/api/auth/register"].(map[string]interface{})["post"].(map[string]interface{})
+	if register["requestBody"] == nil || register["security"] != nil {
+		t.Errorf("register = %v, want a request body and no security", register)
+	}
+	if _, ok := register["responses"].(map[string]interface{})["201"]; !ok {
+		t.Errorf("register responses = %v, want the documented 201", register["responses"])
+	}
+
+	undocumented := paths["/api/undocumented/{thing}"].(map[string]interface{})["get"].(map[string]interface{})
+	if undocumented["summary"] != "GET /api/undocumented/:thing" || undocumented["tags"].([]string)[0] != "undocumented" {
+		t.Errorf("undocumented = %v, want a summary derived from the path", undocumented)
+	}
+}
+
+func TestOpenAPIEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/health", func(c *gin.Context) {})
+	r.GET("/swagger", SwaggerUI)
+	r.GET("/swagger/doc.json", OpenAPISpec(r))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+	var spec struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("doc.json: %d %v", w.Code, err)
+	}
+	if spec.OpenAPI != "3.0.3" || spec.Paths["/api/health"] == nil {
+		t.Errorf("spec = %+v, want the registered /api/health route", spec)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger", nil))
+	if !strings.Contains(w.Body.String(), "/swagger/doc.json") || !strings.Contains(w.Header().Get("Content-Security-Policy"), "https://unpkg.com") {
+		t.Errorf("swagger UI = %d, CSP %q", w.Code, w.Header().Get("Content-Security-Policy"))
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// recordTokenUsage - Add a chat message's tokens to the project counter and, under the
+// allow_overage policy, record the part beyond the limit as billable overage.
+// The overage is derived from the counter value returned by the atomic $inc, so concurrent
+// messages each bill exactly their own share past the limit.
+func recordTokenUsage(ctx context.Context, projectID, sessionID string, messageID primitive.ObjectID, tokensUsed int) {
+	if tokensUsed <= 0 {
+		return
+	}
+
+	collection := config.GetProjectsCollection()
+
+	var after models.Project
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"project_id": projectID},
+		bson.M{"$inc": bson.M{"total_tokens_used": int64(tokensUsed)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&after)
+	if err != nil {
+		log.Printf("❌ Failed to update token usage for %s: %v", projectID, err)
+		return
+	}
+
+	logTokenUsage(ctx, projectID, sessionID, messageID, tokensUsed)
+	go notifyUsageThresholds(after, after.TotalTokensUsed-int64(tokensUsed))
+
+	overage := billableOverage(&after, int64(tokensUsed))
+	if overage <= 0 {
+		return
+	}
+
+	record := models.OverageRecord{
+		ID:               messageID,
+		ProjectID:        projectID,
+		SessionID:        sessionID,
+		Tokens:           overage,
+		Cost:             calculateEstimatedCost(overage),
+		TotalTokensAfter: after.TotalTokensUsed,
+		TokenLimit:       after.MonthlyTokenLimit,
+		CreatedAt:        time.Now(),
+	}
+	if _, err := config.GetOverageRecordsCollection().InsertOne(ctx, record); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return
+		}
+		log.Printf("❌ Failed to record overage for %s: %v", projectID, err)
+		return
+	}
+
+	collection.UpdateOne(ctx, bson.M{"project_id": projectID}, bson.M{"$inc": bson.M{"overage_tokens": overage}})
+}
+
+// billableOverage - The part of tokensUsed beyond the limit, given the project as it stands after
+// the message was counted; only allow_overage projects bill overage
+func billableOverage(after *models.Project, tokensUsed int64) int64 {
+	if after.GetOveragePolicy() != models.OveragePolicyAllow {
+		return 0
+	}
+	return max(0, min(tokensUsed, after.TotalTokensUsed-after.MonthlyTokenLimit))
+}
+
+// logTokenUsage - One openai_usage_logs entry per billed message; the usage series and
+// history charts aggregate these
+func logTokenUsage(ctx context.Context, projectID, sessionID string, messageID primitive.ObjectID, tokensUsed int) {
+	_, err := config.GetOpenAIUsageLogsCollection().InsertOne(ctx, bson.M{
+		"project_id":   projectID,
+		"session_id":   sessionID,
+		"message_id":   messageID,
+		"total_tokens": tokensUsed,
+		"cost":         calculateEstimatedCost(int64(tokensUsed)),
+		"success":      true,
+		"timestamp":    time.Now(),
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to log token usage for %s: %v", projectID, err)
+	}
+}
+
+// getOverageSummary - Billable overage totals for a project's usage report
+func getOverageSummary(ctx context.Context, projectID string) map[string]interface{} {
+	summary := map[string]interface{}{
+		"tokens":          int64(0),
+		"cost":            0.0,
+		"messages":        0,
+		"unbilled_tokens": int64(0),
+		"unbilled_cost":   0.0,
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"project_id": projectID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":             nil,
+			"tokens":          bson.M{"$sum": "$tokens"},
+			"cost":            bson.M{"$sum": "$cost"},
+			"messages":        bson.M{"$sum": 1},
+			"unbilled_tokens": bson.M{"$sum": bson.M{"$cond": bson.A{"$billed", 0, "$tokens"}}},
+			"unbilled_cost":   bson.M{"$sum": bson.M{"$cond": bson.A{"$billed", 0, "$cost"}}},
+		}}},
+	}
+
+	cursor, err := config.GetOverageRecordsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("⚠️ Failed to summarize overage for %s: %v", projectID, err)
+		return summary
+	}
+
+	var results []struct {
+		Tokens         int64   `bson:"tokens"`
+		Cost           float64 `bson:"cost"`
+		Messages       int     `bson:"messages"`
+		UnbilledTokens int64   `bson:"unbilled_tokens"`
+		UnbilledCost   float64 `bson:"unbilled_cost"`
+	}
+	if err := cursor.All(ctx, &results); err != nil || len(results) == 0 {
+		return summary
+	}
+
+	summary["tokens"] = results[0].Tokens
+	summary["cost"] = results[0].Cost
+	summary["messages"] = results[0].Messages
+	summary["unbilled_tokens"] = results[0].UnbilledTokens
+	summary["unbilled_cost"] = results[0].UnbilledCost
+	return summary
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestBillableOverage(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		usedAfter    int64
+		tokens, want int64
+	}{
+		{"within the limit", models.OveragePolicyAllow, 800, 300, 0},
+		{"ends exactly at the limit", models.OveragePolicyAllow, 1000, 300, 0},
+		{"crosses the limit", models.OveragePolicyAllow, 1200, 300, 200},
+		{"already past the limit", models.OveragePolicyAllow, 1500, 300, 300},
+		{"block policy never bills", models.OveragePolicyBlock, 1500, 300, 0},
+		{"suspend policy never bills", models.OveragePolicySuspend, 1500, 300, 0},
+		{"default policy never bills", "", 1500, 300, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := &models.Project{MonthlyTokenLimit: 1000, TotalTokensUsed: tt.usedAfter, OveragePolicy: tt.policy}
+			if got := billableOverage(after, tt.tokens); got != tt.want {
+				t.Errorf("billableOverage = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordTokenUsageBillsOverageOnce(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	// A single threshold already passed keeps the background usage warnings quiet
+	for _, p := range []models.Project{
+		{ProjectID: "allow", MonthlyTokenLimit: 1000, TotalTokensUsed: 900, OveragePolicy: models.OveragePolicyAllow, UsageWarningThresholds: []int{50}},
+		{ProjectID: "block", MonthlyTokenLimit: 1000, TotalTokensUsed: 900, UsageWarningThresholds: []int{50}},
+	} {
+		if _, err := config.GetProjectsCollection().InsertOne(ctx, p); err != nil {
+			t.Fatalf("insert %s: %v", p.ProjectID, err)
+		}
+	}
+
+	crossing := primitive.NewObjectID()
+	recordTokenUsage(context.Background(), "allow", "sess_1", crossing, 300)
+	recordTokenUsage(context.Background(), "allow", "sess_1", primitive.NewObjectID(), 100)
+	// The same message counted twice is billed once
+	recordTokenUsage(context.Background(), "allow", "sess_1", crossing, 300)
+	recordTokenUsage(context.Background(), "block", "sess_1", primitive.NewObjectID(), 300)
+
+	var crossed models.OverageRecord
+	if err := config.GetOverageRecordsCollection().FindOne(ctx, bson.M{"_id": crossing}).Decode(&crossed); err != nil {
+		t.Fatalf("find overage record: %v", err)
+	}
+	if crossed.Tokens != 200 || crossed.TotalTokensAfter != 1200 || crossed.TokenLimit != 1000 || crossed.Cost <= 0 {
+		t.Errorf("overage record = %+v, want 200 billable tokens at 1200/1000", crossed)
+	}
+	if n, _ := config.GetOverageRecordsCollection().CountDocuments(ctx, bson.M{"project_id": "block"}); n != 0 {
+		t.Errorf("block project has %d overage records, want none", n)
+	}
+
+	var allow models.Project
+	if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": "allow"}).Decode(&allow); err != nil {
+		t.Fatalf("find project: %v", err)
+	}
+	if allow.OverageTokens != 300 {
+		t.Errorf("overage_tokens = %d, want 300 (200 + 100, the repeat not billed)", allow.OverageTokens)
+	}
+
+	summary := getOverageSummary(ctx, "allow")
+	if summary["tokens"] != int64(300) || summary["messages"] != 2 || summary["unbilled_tokens"] != int64(300) {
+		t.Errorf("summary = %v, want 300 unbilled tokens over 2 messages", summary)
+	}
+
+	// Billed records drop out of the unbilled totals
+	if _, err := config.GetOverageRecordsCollection().UpdateOne(ctx, bson.M{"_id": crossing}, bson.M{"$set": bson.M{"billed": true}}); err != nil {
+		t.Fatalf("mark billed: %v", err)
+	}
+	if summary := getOverageSummary(ctx, "allow"); summary["unbilled_tokens"] != int64(100) {
+		t.Errorf("unbilled_tokens = %v after billing the first record, want 100", summary["unbilled_tokens"])
+	}
+	if summary := getOverageSummary(ctx, "nobody"); summary["tokens"] != int64(0) || summary["messages"] != 0 {
+		t.Errorf("summary for a project without overage = %v, want zeros", summary)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Page context limits; longer titles and excerpts are cut, longer URLs rejected
+const (
+	maxPageURLLength     = 2048
+	maxPageTitleLength   = 300
+	maxPageExcerptLength = 2000
+)
+
+// pageContext - The page the visitor is on when they write, as reported by the widget
+type pageContext struct {
+	URL     string
+	Title   string
+	Excerpt string
+}
+
+// parsePageContext - Clean and bound the page fields of a widget request. A URL that isn't an
+// absolute http(s) address is an error; title and excerpt are trimmed to their limits.
+func parsePageContext(rawURL, title, excerpt string) (pageContext, error) {
+	page := pageContext{
+		URL:     cleanPageField(rawURL, maxPageURLLength+1),
+		Title:   cleanPageField(title, maxPageTitleLength),
+		Excerpt: cleanPageField(excerpt, maxPageExcerptLength),
+	}
+	if page.URL == "" {
+		return page, nil
+	}
+	if len(page.URL) > maxPageURLLength {
+		return pageContext{}, fmt.Errorf("page_url exceeds the %d character limit", maxPageURLLength)
+	}
+	parsed, err := url.Parse(page.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return pageContext{}, fmt.Errorf("page_url must be an absolute http(s) URL")
+	}
+	// Fragments and credentials are noise to the model and don't belong in analytics
+	parsed.Fragment, parsed.User = "", nil
+	page.URL = parsed.String()
+	return page, nil
+}
+
+// empty - Whether the widget sent no page details
+func (p pageContext) empty() bool {
+	return p.URL == "" && p.Title == "" && p.Excerpt == ""
+}
+
+// path - Words of the URL path (e.g. "pricing enterprise plans"), empty without a URL
+func (p pageContext) path() string {
+	parsed, err := url.Parse(p.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.NewReplacer("/", " ", "-", " ", "_", " ").Replace(parsed.Path))
+}
+
+// promptSection - The page details as a section appended to the system message
+func (p pageContext) promptSection() string {
+	if p.empty() {
+		return ""
+	}
+	var section strings.Builder
+	section.WriteString("\n\nThe visitor is currently viewing this page. When their question is about it, scope your answer to it:")
+	if p.Title != "" {
+		fmt.Fprintf(&section, "\nTitle: %s", p.Title)
+	}
+	if p.URL != "" {
+		fmt.Fprintf(&section, "\nURL: %s", p.URL)
+	}
+	if p.Excerpt != "" {
+		fmt.Fprintf(&section, "\nExcerpt:\n%s", p.Excerpt)
+	}
+	return section.String()
+}
+
+// cleanPageField - Strip control characters (newlines become spaces) and keep at most limit runes
+func cleanPageField(value string, limit int) string {
+	value = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value))
+	if runes := []rune(value); len(runes) > limit {
+		value = strings.TrimSpace(string(runes[:limit]))
+	}
+	return value
+}
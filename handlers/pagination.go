@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPageLimit - Largest page size any list endpoint returns
+const maxPageLimit = 100
+
+// parsePagination - page and limit query parameters; page below 1 becomes 1 and a limit
+// outside 1..maxPageLimit becomes defaultLimit
+func parsePagination(c *gin.Context, defaultLimit int) (page, limit int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > maxPageLimit {
+		limit = defaultLimit
+	}
+	return page, limit
+}
+
+// pageCount - Number of pages needed for total items at limit per page
+func pageCount(total int64, limit int) int {
+	return (int(total) + limit - 1) / limit
+}
+
+// errInvalidCursor - A cursor query parameter that was not issued by encodeCursor
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor - Opaque keyset cursor pointing just past the item with this timestamp and ID.
+// MongoDB stores times to the millisecond, so that is the precision kept.
+func encodeCursor(at time.Time, id primitive.ObjectID) string {
+	raw := strconv.FormatInt(at.UnixMilli(), 10) + "_" + id.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor - Reverse of encodeCursor
+func decodeCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+	millis, hex, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+	return time.UnixMilli(ms), id, nil
+}
+
+// afterCursor - Filter clause for items after the cursor in (field desc, _id desc) order.
+// Sorting on _id as well makes the order total, so items sharing a timestamp are neither
+// repeated nor skipped, and items added at the top never shift later pages.
+func afterCursor(field string, at time.Time, id primitive.ObjectID) bson.M {
+	return bson.M{"$or": []bson.M{
+		{field: bson.M{"$lt": at}},
+		{field: at, "_id": bson.M{"$lt": id}},
+	}}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+)
+
+func TestParsePagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query     string
+		wantPage  int
+		wantLimit int
+	}{
+		{"", 1, 20},
+		{"?page=3&limit=50", 3, 50},
+		{"?page=0&limit=0", 1, 20},
+		{"?page=-2&limit=-5", 1, 20},
+		{"?page=abc&limit=xyz", 1, 20},
+		{"?limit=100", 1, 100},
+		{"?limit=101", 1, 20},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+
+		page, limit := parsePagination(c, 20)
+		if page != tt.wantPage || limit != tt.wantLimit {
+			t.Errorf("parsePagination(%q) = %d, %d; want %d, %d", tt.query, page, limit, tt.wantPage, tt.wantLimit)
+		}
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	tests := []struct {
+		total int64
+		limit int
+		want  int
+	}{
+		{0, 20, 0},
+		{1, 20, 1},
+		{20, 20, 1},
+		{21, 20, 2},
+		{100, 10, 10},
+	}
+
+	for _, tt := range tests {
+		if got := pageCount(tt.total, tt.limit); got != tt.want {
+			t.Errorf("pageCount(%d, %d) = %d, want %d", tt.total, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2025, 3, 1, 9, 30, 15, 123456789, time.UTC)
+	id := primitive.NewObjectID()
+
+	gotAt, gotID, err := decodeCursor(encodeCursor(at, id))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if !gotAt.Equal(at.Truncate(time.Millisecond)) || gotID != id {
+		t.Errorf("round trip = %v, %s; want %v, %s", gotAt, gotID.Hex(), at.Truncate(time.Millisecond), id.Hex())
+	}
+}
+
+func TestDecodeCursorRejectsForgeries(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	for _, cursor := range []string{
+		"",
+		"not base64!",
+		encode("1740821415123"),
+		encode("yesterday_" + primitive.NewObjectID().Hex()),
+		encode("1740821415123_not-an-id"),
+	} {
+		if _, _, err := decodeCursor(cursor); !errors.Is(err, errInvalidCursor) {
+			t.Errorf("decodeCursor(%q) err = %v, want errInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestAfterCursor(t *testing.T) {
+	at := time.UnixMilli(1740821415123)
+	id := primitive.NewObjectID()
+
+	want := bson.M{"$or": []bson.M{
+		{"sent_at": bson.M{"$lt": at}},
+		{"sent_at": at, "_id": bson.M{"$lt": id}},
+	}}
+	if got := afterCursor("sent_at", at, id); !reflect.DeepEqual(got, want) {
+		t.Errorf("afterCursor = %v, want %v", got, want)
+	}
+}
+
+func TestNotificationHistoryCursorPaging(t *testing.T) {
+	ctx := useTestDatabase(t)
+	r := notificationRouter("admin_1")
+
+	// Five notifications sharing a timestamp, so only _id orders them
+	sentAt := time.Now().Truncate(time.Millisecond)
+	var docs []interface{}
+	for i := 0; i < 5; i++ {
+		docs = append(docs, bson.M{"_id": primitive.NewObjectID(), "type": "test", "message": fmt.Sprint(i), "sent_at": sentAt})
+	}
+	config.GetNotificationsCollection().InsertMany(ctx, docs)
+
+	type page struct {
+		Notifications []struct {
+			Message string `json:"message"`
+		} `json:"notifications"`
+		Pagination struct {
+			CurrentPage *int   `json:"current_page"`
+			NextCursor  string `json:"next_cursor"`
+			HasMore     bool   `json:"has_more"`
+		} `json:"pagination"`
+	}
+	get := func(query string) (int, page) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/notifications?limit=2"+query, nil))
+		var p page
+		json.Unmarshal(w.Body.Bytes(), &p)
+		return w.Code, p
+	}
+
+	seen := map[string]bool{}
+	code, p := get("")
+	if code != http.StatusOK || p.Pagination.CurrentPage == nil || !p.Pagination.HasMore {
+		t.Fatalf("first page = %d %+v, want a numbered page with more to come", code, p.Pagination)
+	}
+	for pages := 1; ; pages++ {
+		for _, n := range p.Notifications {
+			if seen[n.Message] {
+				t.Errorf("notification %s repeated", n.Message)
+			}
+			seen[n.Message] = true
+		}
+		if !p.Pagination.HasMore {
+			break
+		}
+		if pages == 1 {
+			// Notifications arriving mid-way don't shift later pages
+			config.GetNotificationsCollection().InsertOne(ctx, bson.M{"type": "test", "message": "new", "sent_at": time.Now().Add(time.Second)})
+		}
+		if code, p = get("&cursor=" + p.Pagination.NextCursor); code != http.StatusOK || p.Pagination.CurrentPage != nil {
+			t.Fatalf("cursor page = %d %+v", code, p.Pagination)
+		}
+	}
+	if len(seen) != 5 || seen["new"] {
+		t.Errorf("paged through %v, want each of the five original notifications once", seen)
+	}
+
+	if code, _ := get("&cursor=bogus"); code != http.StatusBadRequest {
+		t.Errorf("bad cursor: status = %d, want 400", code)
+	}
+}
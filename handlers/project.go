@@ -3,251 +3,361 @@ package handlers
 import (
 	"context"
 	"crypto/rand"
-	"strconv"
 	"fmt"
-	"log"
-	"math/big"
-	"net/http"
-	"os"
-	"math"
-"go.mongodb.org/mongo-driver/mongo/options"
-	"time"
-	"path/filepath"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-      "go.mongodb.org/mongo-driver/mongo"  
+	"go.mongodb.org/mongo-driver/mongo"
 	"jevi-chat/config"
+	"jevi-chat/middleware"
 	"jevi-chat/models"
-
-
+	"log"
+	"math/big"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // CreateProject - Enhanced project creation with OpenAI integration
 func CreateProject(c *gin.Context) {
-    userID := c.GetString("user_id")
-    userEmail := c.GetString("user_email")
-    userRole := c.GetString("user_role")
-    
-    if userID == "" || userRole != "admin" {
-        c.JSON(http.StatusUnauthorized, gin.H{
-            "error": "Admin authentication required",
-        })
-        return
-    }
-
-    // ✅ Handle multipart form data properly
-    err := c.Request.ParseMultipartForm(32 << 20) // 32MB max
-    if err != nil {
-        log.Printf("❌ Failed to parse multipart form: %v", err)
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "Failed to parse form data",
-        })
-        return
-    }
-
-    // ✅ Extract form values directly
-    name := c.PostForm("name")
-    description := c.PostForm("description")
-    clientEmail := c.PostForm("client_email")
-    welcomeMessage := c.PostForm("welcome_message")
-    theme := c.PostForm("theme")
-    primaryColor := c.PostForm("primary_color")
-    
-    // Parse monthly token limit
-    monthlyTokenLimit := int64(100000) // default
-    if limitStr := c.PostForm("monthly_token_limit"); limitStr != "" {
-        if parsed, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-            monthlyTokenLimit = parsed
-        }
-    }
-
-    // ✅ Validate required fields
-    if name == "" {
-        log.Printf("❌ Project name is empty")
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "Project name is required",
-        })
-        return
-    }
-
-    // Set defaults
-    if welcomeMessage == "" {
-        welcomeMessage = "Hello! How can I help you today?"
-    }
-    if theme == "" {
-        theme = "default"
-    }
-    if primaryColor == "" {
-        primaryColor = "#4f46e5"
-    }
-
-    // ✅ Handle PDF file uploads and processing
-    form, _ := c.MultipartForm()
-    files := form.File["pdf_files"]
-    
-    var pdfFiles []models.PDFFile
-    var combinedPDFContent string
-    
-    for _, file := range files {
-        // Validate file type
-        if file.Header.Get("Content-Type") != "application/pdf" {
-            c.JSON(http.StatusBadRequest, gin.H{
-                "error": fmt.Sprintf("File %s is not a PDF", file.Filename),
-            })
-            return
-        }
-        
-        // Validate file size (10MB max)
-        if file.Size > 10*1024*1024 {
-            c.JSON(http.StatusBadRequest, gin.H{
-                "error": fmt.Sprintf("File %s exceeds 10MB limit", file.Filename),
-            })
-            return
-        }
-        
-        // Generate unique filename and save
-        fileID := primitive.NewObjectID().Hex()
-        fileName := fmt.Sprintf("%s_%s", fileID, file.Filename)
-        filePath := filepath.Join("uploads", "pdfs", fileName)
-        
-        // Create upload directory
-        uploadDir := filepath.Dir(filePath)
-        if err := os.MkdirAll(uploadDir, 0755); err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{
-                "error": "Failed to create upload directory",
-            })
-            return
-        }
-        
-        // Save file
-        if err := c.SaveUploadedFile(file, filePath); err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{
-                "error": fmt.Sprintf("Failed to save file %s", file.Filename),
-            })
-            return
-        }
-        
-        // ✅ Extract PDF content for OpenAI processing
-        pdfContent, err := extractPDFContent(filePath)
-        if err != nil {
-            log.Printf("⚠️ Failed to extract content from %s: %v", file.Filename, err)
-            pdfContent = fmt.Sprintf("Content from %s could not be extracted", file.Filename)
-        }
-        
-        // ✅ Process content with OpenAI for embeddings
-        embeddings, err := generateOpenAIEmbeddings(pdfContent)
-        if err != nil {
-            log.Printf("⚠️ Failed to generate embeddings for %s: %v", file.Filename, err)
-        }
-        
-        // Create PDF file record
-        pdfFile := models.PDFFile{
-            ID:           fileID,
-            FileName:     file.Filename,
-            FilePath:     filePath,
-            FileSize:     file.Size,
-            ContentType:  file.Header.Get("Content-Type"),
-            Content:      pdfContent,
-            Embeddings:   embeddings,
-            UploadedAt:   time.Now(),
-            ProcessedAt:  time.Now(),
-            Status:       "processed",
-        }
-        
-        pdfFiles = append(pdfFiles, pdfFile)
-        combinedPDFContent += pdfContent + "\n\n"
-    }
-
-    // Generate unique project ID
-    projectID := fmt.Sprintf("proj_%d_%s", time.Now().Unix(), generateRandomString(8))
-    embedCode := generateEmbedCode(projectID)
-
-    // Create project object
-    project := models.Project{
-        ID:                primitive.NewObjectID(),
-        ProjectID:         projectID,
-        Name:              name,
-        Description:       description,
-        Category:          "chatbot",
-        ClientID:          clientEmail,
-        StartDate:         time.Now(),
-        ExpiryDate:        time.Now().AddDate(1, 0, 0),
-        Status:            "active",
-        TotalTokensUsed:   0,
-        MonthlyTokenLimit: monthlyTokenLimit,
-        EmbedCode:         embedCode,
-        WidgetSettings: models.ProjectWidgetConfig{
-            Theme:            theme,
-            PrimaryColor:     primaryColor,
-            WelcomeMessage:   welcomeMessage,
-            Position:         "bottom-right",
-            ShowBranding:     true,
-            EnableFileUpload: len(pdfFiles) > 0,
-            EnableRating:     true,
-        },
-        AIProvider:        "openai",
-        OpenAIModel:       "gpt-4o",
-        PDFFiles:          pdfFiles,
-        PDFContent:        combinedPDFContent,
-        CreatedAt:         time.Now(),
-        UpdatedAt:         time.Now(),
-        IsActive:          true,
-    }
-
-    // Insert project into database
-    collection := config.GetProjectsCollection()
-    result, err := collection.InsertOne(context.Background(), project)
-    if err != nil {
-        log.Printf("❌ Failed to create project: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "Failed to create project",
-        })
-        return
-    }
-
-    project.ID = result.InsertedID.(primitive.ObjectID)
-
-    log.Printf("✅ Project created with %d PDF files: %s by %s", len(pdfFiles), project.Name, userEmail)
-
-    c.JSON(http.StatusCreated, gin.H{
-        "message": "Project created successfully",
-        "project": gin.H{
-            "id":                  project.ID.Hex(),
-            "project_id":          project.ProjectID,
-            "name":                project.Name,
-            "description":         project.Description,
-            "status":              project.Status,
-            "total_tokens_used":   project.TotalTokensUsed,
-            "monthly_token_limit": project.MonthlyTokenLimit,
-            "pdf_files_count":     len(pdfFiles),
-            "created_at":          project.CreatedAt,
-            "expiry_date":         project.ExpiryDate,
-        },
-    })
-}
+	userID := c.GetString("user_id")
+	userEmail := c.GetString("user_email")
+	userRole := c.GetString("user_role")
+
+	if userID == "" || userRole != "admin" {
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Admin authentication required")
+		return
+	}
+
+	// ✅ Handle multipart form data properly
+	err := c.Request.ParseMultipartForm(32 << 20) // 32MB max
+	if err != nil {
+		log.Printf("❌ Failed to parse multipart form: %v", err)
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Failed to parse form data")
+		return
+	}
+
+	// ✅ Extract form values directly
+	name := c.PostForm("name")
+	description := c.PostForm("description")
+	clientEmail := c.PostForm("client_email")
+	welcomeMessage := c.PostForm("welcome_message")
+	theme := c.PostForm("theme")
+	primaryColor := c.PostForm("primary_color")
+
+	// Subscription length and token limit: configured defaults unless the admin overrides them
+	subDefaults := config.GetSubscriptionDefaults()
+	monthlyTokenLimit := subDefaults.MonthlyTokenLimit
+	if limitStr := c.PostForm("monthly_token_limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed <= 0 || parsed > config.MaxMonthlyTokenLimit {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+				fmt.Sprintf("monthly_token_limit must be between 1 and %d", config.MaxMonthlyTokenLimit))
+			return
+		}
+		monthlyTokenLimit = parsed
+	}
+	subscriptionMonths := subDefaults.Months
+	if monthsStr := c.PostForm("subscription_months"); monthsStr != "" {
+		parsed, err := strconv.Atoi(monthsStr)
+		if err != nil || parsed <= 0 || parsed > config.MaxSubscriptionMonths {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+				fmt.Sprintf("subscription_months must be between 1 and %d", config.MaxSubscriptionMonths))
+			return
+		}
+		subscriptionMonths = parsed
+	}
+
+	// Free trial: time-boxed with the reduced trial token limit; suspended by maintenance when it ends
+	isTrial, _ := strconv.ParseBool(c.PostForm("trial"))
+	var trialEndsAt *time.Time
+	plan := models.PlanPaid
+	if isTrial {
+		plan = models.PlanTrial
+		endsAt := subDefaults.TrialEndFrom(time.Now())
+		trialEndsAt = &endsAt
+		monthlyTokenLimit = min(monthlyTokenLimit, subDefaults.TrialTokenLimit)
+	}
+
+	embeddingModel := models.DefaultEmbeddingModel
+	if model := c.PostForm("embedding_model"); model != "" {
+		if !models.IsValidEmbeddingModel(model) {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+				"embedding_model must be text-embedding-ada-002, text-embedding-3-small or text-embedding-3-large")
+			return
+		}
+		embeddingModel = model
+	}
+
+	// ✅ Validate required fields
+	if name == "" {
+		log.Printf("❌ Project name is empty")
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Project name is required")
+		return
+	}
+
+	// Set defaults
+	if welcomeMessage == "" {
+		welcomeMessage = defaultWelcomeMessage
+	}
+	if theme == "" {
+		theme = "default"
+	}
+	if primaryColor == "" {
+		primaryColor = "#4f46e5"
+	}
 
+	// ✅ Handle PDF file uploads and processing
+	form, _ := c.MultipartForm()
+	files := form.File["pdf_files"]
+
+	var pdfFiles []models.PDFFile
+	var combinedPDFContent string
+
+	for _, file := range files {
+		// Validate file type
+		if file.Header.Get("Content-Type") != "application/pdf" {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("File %s is not a PDF", file.Filename))
+			return
+		}
+
+		// Validate file size (10MB max)
+		if file.Size > 10*1024*1024 {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("File %s exceeds 10MB limit", file.Filename))
+			return
+		}
+
+		// Generate unique filename and save
+		fileID := primitive.NewObjectID().Hex()
+		fileName := fmt.Sprintf("%s_%s", fileID, file.Filename)
+		filePath := filepath.Join("uploads", "pdfs", fileName)
+
+		// Create upload directory
+		uploadDir := filepath.Dir(filePath)
+		if err := os.MkdirAll(uploadDir, 0755); err != nil {
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create upload directory")
+			return
+		}
+
+		// Save file
+		if err := c.SaveUploadedFile(file, filePath); err != nil {
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save file %s", file.Filename))
+			return
+		}
+
+		// ✅ Extract PDF content for OpenAI processing
+		pdfContent, err := extractPDFContent(filePath)
+		if err != nil {
+			log.Printf("⚠️ Failed to extract content from %s: %v", file.Filename, err)
+			pdfContent = fmt.Sprintf("Content from %s could not be extracted", file.Filename)
+		}
+
+		// Create PDF file record (embedded below, all files in one batch)
+		pdfFile := models.PDFFile{
+			ID:          fileID,
+			FileName:    file.Filename,
+			FilePath:    filePath,
+			FileSize:    file.Size,
+			ContentType: file.Header.Get("Content-Type"),
+			Content:     pdfContent,
+			UploadedAt:  time.Now(),
+			ProcessedAt: time.Now(),
+			Status:      "processed",
+		}
+
+		pdfFiles = append(pdfFiles, pdfFile)
+		combinedPDFContent += pdfContent + "\n\n"
+	}
+
+	// ✅ Process content with OpenAI for embeddings: one vector per file, batched into as few
+	// requests as the API limits allow
+	if len(pdfFiles) > 0 && !openAIConfigured() {
+		log.Printf("⚠️ OPENAI_API_KEY not set, skipping embeddings for %d files (run a reindex once it is configured)", len(pdfFiles))
+	} else if len(pdfFiles) > 0 {
+		contents := make([]string, len(pdfFiles))
+		for i, pdfFile := range pdfFiles {
+			contents[i] = truncateEmbeddingInput(pdfFile.Content)
+		}
+		if vectors, err := generateBatchEmbeddings(contents, embeddingModel); err != nil {
+			log.Printf("⚠️ Failed to generate embeddings for %d files: %v", len(pdfFiles), err)
+		} else {
+			for i := range pdfFiles {
+				pdfFiles[i].Embeddings = vectors[i]
+				pdfFiles[i].EmbeddingModel = embeddingModel
+			}
+		}
+	}
+
+	// Creator: the authenticated admin, recorded for attribution
+	createdBy, _ := primitive.ObjectIDFromHex(userID)
+
+	// Owner: an explicit owner_id, else the registered user with the client email (if any)
+	ownerID, err := resolveProjectOwner(c.PostForm("owner_id"), clientEmail)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	// Generate unique project ID
+	projectID := fmt.Sprintf("proj_%d_%s", time.Now().Unix(), generateRandomString(8))
+	embedCode := generateEmbedCode(projectID)
+
+	// Create project object
+	project := models.Project{
+		ID:                primitive.NewObjectID(),
+		ProjectID:         projectID,
+		Name:              name,
+		Description:       description,
+		Category:          "chatbot",
+		ClientID:          clientEmail,
+		OwnerID:           ownerID,
+		CreatedByUserID:   createdBy,
+		StartDate:         time.Now(),
+		ExpiryDate:        time.Now().AddDate(0, subscriptionMonths, 0),
+		Status:            "active",
+		TotalTokensUsed:   0,
+		MonthlyTokenLimit: monthlyTokenLimit,
+		Plan:              plan,
+		TrialEndsAt:       trialEndsAt,
+		EmbedCode:         embedCode,
+		WidgetSettings: models.ProjectWidgetConfig{
+			Theme:            theme,
+			PrimaryColor:     primaryColor,
+			WelcomeMessage:   welcomeMessage,
+			Position:         "bottom-right",
+			ShowBranding:     true,
+			EnableFileUpload: len(pdfFiles) > 0,
+			EnableRating:     true,
+		},
+		AIProvider:     "openai",
+		OpenAIModel:    "gpt-4o",
+		EmbeddingModel: embeddingModel,
+		PDFFiles:       pdfFiles,
+		PDFContent:     combinedPDFContent,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		IsActive:       true,
+	}
+
+	// Insert project into database
+	collection := config.GetProjectsCollection()
+	result, err := collection.InsertOne(c.Request.Context(), project)
+	if err != nil {
+		log.Printf("❌ Failed to create project: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create project")
+		return
+	}
+
+	project.ID = result.InsertedID.(primitive.ObjectID)
+
+	// Chunk vectors for retrieval are built in the background so uploads return promptly
+	if len(pdfFiles) > 0 {
+		go indexProjectDocuments(&project)
+	}
+
+	log.Printf("✅ Project created with %d PDF files: %s by %s", len(pdfFiles), project.Name, userEmail)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Project created successfully",
+		"project": gin.H{
+			"id":                  project.ID.Hex(),
+			"project_id":          project.ProjectID,
+			"name":                project.Name,
+			"description":         project.Description,
+			"status":              project.Status,
+			"total_tokens_used":   project.TotalTokensUsed,
+			"monthly_token_limit": project.MonthlyTokenLimit,
+			"plan":                project.Plan,
+			"trial_ends_at":       project.TrialEndsAt,
+			"owner_id":            project.OwnerID,
+			"created_by_user_id":  project.CreatedByUserID.Hex(),
+			"pdf_files_count":     len(pdfFiles),
+			"created_at":          project.CreatedAt,
+			"expiry_date":         project.ExpiryDate,
+		},
+	})
+}
 
 // UpdateProject - Update project settings
 func UpdateProject(c *gin.Context) {
 	projectID := c.Param("id")
 
 	var updateData struct {
-		Name              string `json:"name"`
-		Description       string `json:"description"`
-		MonthlyTokenLimit int64  `json:"monthly_token_limit"`
-		WelcomeMessage    string `json:"welcome_message"`
-		Theme             string `json:"theme"`
-		PrimaryColor      string `json:"primary_color"`
-		Status            string `json:"status"`
+		Name                   string                 `json:"name"`
+		Description            string                 `json:"description"`
+		MonthlyTokenLimit      int64                  `json:"monthly_token_limit"`
+		WelcomeMessage         string                 `json:"welcome_message"`
+		Theme                  string                 `json:"theme"`
+		PrimaryColor           string                 `json:"primary_color"`
+		Status                 string                 `json:"status"`
+		RequireAuth            *bool                  `json:"require_auth"`
+		RequireCaptcha         *bool                  `json:"require_captcha"`
+		ProactiveGreeting      *bool                  `json:"proactive_greeting"`
+		CollectUserInfo        *bool                  `json:"collect_user_info"`
+		EmailLeads             *bool                  `json:"email_leads"`
+		QuickActions           []models.QuickAction   `json:"quick_actions"`
+		Schedule               *models.WidgetSchedule `json:"schedule"`
+		AllowedDomains         []string               `json:"allowed_domains"`
+		SystemPrompt           *string                `json:"system_prompt"`
+		CantAnswerMessage      *string                `json:"cant_answer_message"` // "" restores the default wording
+		RedactPII              *bool                  `json:"redact_pii"`
+		OveragePolicy          string                 `json:"overage_policy"`
+		UsageWarningThresholds *[]int                 `json:"usage_warning_thresholds"` // [] restores the plan default
+		OwnerID                *string                `json:"owner_id"`
+		NotificationEmail      *string                `json:"notification_email"`
+		WebhookURL             *string                `json:"notification_webhook_url"`
+		SlackURL               *string                `json:"notification_slack_url"`
+		AIProvider             string                 `json:"ai_provider"`
+		FallbackProvider       *string                `json:"fallback_provider"` // "" removes the fallback
+		EmbeddingModel         string                 `json:"embedding_model"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update data"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid update data")
 		return
 	}
 
-	collection := config.DB.Collection("projects")
+	if updateData.OveragePolicy != "" && !models.IsValidOveragePolicy(updateData.OveragePolicy) {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+			"overage_policy must be one of block, suspend or allow_overage")
+		return
+	}
+
+	if updateData.AIProvider != "" && !models.IsValidAIProvider(updateData.AIProvider) {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "ai_provider must be openai or gemini")
+		return
+	}
+	if updateData.FallbackProvider != nil && *updateData.FallbackProvider != "" {
+		if !models.IsValidAIProvider(*updateData.FallbackProvider) {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "fallback_provider must be openai or gemini")
+			return
+		}
+		if *updateData.FallbackProvider == updateData.AIProvider {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "fallback_provider must differ from ai_provider")
+			return
+		}
+	}
+
+	// Changing the embedding model re-embeds every document; until then retrieval is lexical
+	reembed := false
+	if updateData.EmbeddingModel != "" {
+		if !models.IsValidEmbeddingModel(updateData.EmbeddingModel) {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+				"embedding_model must be text-embedding-ada-002, text-embedding-3-small or text-embedding-3-large")
+			return
+		}
+		current, err := getProjectByID(c.Request.Context(), projectID)
+		if err != nil {
+			respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+			return
+		}
+		reembed = current.GetEmbeddingModel() != updateData.EmbeddingModel
+	}
 
 	update := bson.M{
 		"$set": bson.M{
@@ -277,19 +387,134 @@ func UpdateProject(c *gin.Context) {
 	if updateData.Status != "" && isValidStatus(updateData.Status) {
 		update["$set"].(bson.M)["status"] = updateData.Status
 	}
+	if updateData.RequireAuth != nil {
+		update["$set"].(bson.M)["widget_settings.require_auth"] = *updateData.RequireAuth
+	}
+	if updateData.RequireCaptcha != nil {
+		update["$set"].(bson.M)["widget_settings.require_captcha"] = *updateData.RequireCaptcha
+	}
+	if updateData.ProactiveGreeting != nil {
+		update["$set"].(bson.M)["widget_settings.proactive_greeting"] = *updateData.ProactiveGreeting
+	}
+	if updateData.CollectUserInfo != nil {
+		update["$set"].(bson.M)["widget_settings.collect_user_info"] = *updateData.CollectUserInfo
+	}
+	if updateData.EmailLeads != nil {
+		update["$set"].(bson.M)["widget_settings.email_leads"] = *updateData.EmailLeads
+	}
+	if updateData.QuickActions != nil {
+		update["$set"].(bson.M)["widget_settings.quick_actions"] = updateData.QuickActions
+	}
+	if updateData.Schedule != nil {
+		if err := updateData.Schedule.Validate(); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+		update["$set"].(bson.M)["widget_settings.schedule"] = updateData.Schedule
+	}
+	if updateData.OveragePolicy != "" {
+		update["$set"].(bson.M)["overage_policy"] = updateData.OveragePolicy
+	}
+	if updateData.UsageWarningThresholds != nil {
+		if err := validateThresholds(*updateData.UsageWarningThresholds); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+		update["$set"].(bson.M)["usage_warning_thresholds"] = normalizeThresholds(*updateData.UsageWarningThresholds)
+	}
+	if updateData.AIProvider != "" {
+		update["$set"].(bson.M)["ai_provider"] = updateData.AIProvider
+	}
+	if updateData.FallbackProvider != nil {
+		update["$set"].(bson.M)["fallback_provider"] = *updateData.FallbackProvider
+	}
+	if updateData.EmbeddingModel != "" {
+		update["$set"].(bson.M)["embedding_model"] = updateData.EmbeddingModel
+	}
+	if updateData.NotificationEmail != nil {
+		email := strings.TrimSpace(*updateData.NotificationEmail)
+		if email != "" {
+			if _, err := mail.ParseAddress(email); err != nil {
+				respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "notification_email is not a valid email address")
+				return
+			}
+		}
+		update["$set"].(bson.M)["notification_email"] = email
+	}
+	if updateData.WebhookURL != nil {
+		webhookURL := strings.TrimSpace(*updateData.WebhookURL)
+		if webhookURL != "" {
+			if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "notification_webhook_url must be an http(s) URL")
+				return
+			}
+		}
+		update["$set"].(bson.M)["notification_webhook_url"] = webhookURL
+	}
+	if updateData.SlackURL != nil {
+		slackURL := strings.TrimSpace(*updateData.SlackURL)
+		if slackURL != "" {
+			if u, err := url.Parse(slackURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "notification_slack_url must be an https URL")
+				return
+			}
+		}
+		update["$set"].(bson.M)["notification_slack_url"] = slackURL
+	}
+	if updateData.OwnerID != nil {
+		ownerID := strings.TrimSpace(*updateData.OwnerID)
+		if ownerID != "" {
+			if _, err := resolveProjectOwner(ownerID, ""); err != nil {
+				respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+				return
+			}
+		}
+		update["$set"].(bson.M)["owner_id"] = ownerID
+	}
+	if updateData.SystemPrompt != nil {
+		update["$set"].(bson.M)["system_prompt"] = strings.TrimSpace(*updateData.SystemPrompt)
+	}
+	if updateData.CantAnswerMessage != nil {
+		message := strings.TrimSpace(*updateData.CantAnswerMessage)
+		if len(message) > maxCantAnswerMessageLength {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+				fmt.Sprintf("cant_answer_message must be at most %d characters", maxCantAnswerMessageLength))
+			return
+		}
+		update["$set"].(bson.M)["cant_answer_message"] = message
+	}
+	if updateData.RedactPII != nil {
+		update["$set"].(bson.M)["redact_pii"] = *updateData.RedactPII
+	}
+	if updateData.AllowedDomains != nil {
+		domains := make([]string, 0, len(updateData.AllowedDomains))
+		for _, domain := range updateData.AllowedDomains {
+			if domain = middleware.NormalizeAllowedDomain(domain); domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		update["$set"].(bson.M)["widget_settings.allowed_domains"] = domains
+	}
 
-	result, err := collection.UpdateOne(context.Background(),
+	// Every field is validated before anything is written
+	collection := config.DB.Collection("projects")
+	result, err := collection.UpdateOne(c.Request.Context(),
 		bson.M{"project_id": projectID}, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update project")
 		return
 	}
 
 	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
+	middleware.InvalidateWidgetOrigins(projectID)
+	if reembed {
+		go reembedProject(projectID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Project updated successfully",
 	})
@@ -301,7 +526,7 @@ func SuspendProject(c *gin.Context) {
 
 	err := updateProjectStatus(projectID, "suspended")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend project"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to suspend project")
 		return
 	}
 
@@ -316,22 +541,20 @@ func ReactivateProject(c *gin.Context) {
 	projectID := c.Param("id")
 
 	// Check if project is not expired
-	project, err := getProjectByID(projectID)
+	project, err := getProjectByID(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
 	if time.Now().After(project.ExpiryDate) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Cannot reactivate expired project. Please renew first.",
-		})
+		respondError(c, http.StatusBadRequest, ErrCodeSubscriptionExpired, "Cannot reactivate expired project. Please renew first.")
 		return
 	}
 
 	err = updateProjectStatus(projectID, "active")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate project"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reactivate project")
 		return
 	}
 
@@ -341,101 +564,88 @@ func ReactivateProject(c *gin.Context) {
 	})
 }
 
-
 // getDomain returns the appropriate domain based on environment
 func getDomain() string {
-    if domain := os.Getenv("DOMAIN"); domain != "" {
-        return domain
-    }
-    if os.Getenv("ENVIRONMENT") == "production" {
-        return "https://completetroikabackend.onrender.com"
-    }
-    return "http://localhost:8080"
+	if domain := os.Getenv("DOMAIN"); domain != "" {
+		return domain
+	}
+	if os.Getenv("ENVIRONMENT") == "production" {
+		return "https://completetroikabackend.onrender.com"
+	}
+	return "http://localhost:8080"
 }
 
 // GetEmbedCode - Get embeddable widget code
 // GetEmbedCode - Get embeddable widget code with enhanced configuration
 func GetEmbedCode(c *gin.Context) {
-    projectID := c.Param("id")
-
-    // Get project details
-    project, err := getProjectByID(projectID)
-    if err != nil {
-        if err == mongo.ErrNoDocuments {
-            c.JSON(http.StatusNotFound, gin.H{
-                "error": "Project not found",
-            })
-            return
-        }
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "Failed to fetch project",
-        })
-        return
-    }
-
-    // Generate enhanced embed code
-    embedCode := generateEnhancedEmbedCode(projectID, project.WidgetSettings)
-    domain := getDomain()
-
-    c.JSON(http.StatusOK, gin.H{
-        "success":     true,
-        "embed_code":  embedCode,
-        "widget_url":  fmt.Sprintf("%s/widget.js", domain),
-        "project_id":  projectID,
-        "domain":      domain,
-    })
-}
+	projectID := c.Param("id")
+
+	// Get project details
+	project, err := getProjectByID(c.Request.Context(), projectID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch project")
+		return
+	}
 
+	// Generate enhanced embed code
+	embedCode := generateEnhancedEmbedCode(projectID, project.WidgetSettings)
+	domain := getDomain()
 
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"embed_code": embedCode,
+		"widget_url": WidgetScriptURL(),
+		"project_id": projectID,
+		"domain":     domain,
+	})
+}
 
 // RegenerateEmbedCode - Generate new embed code with enhanced configuration
 func RegenerateEmbedCode(c *gin.Context) {
-    projectID := c.Param("id")
-    userRole := c.GetString("user_role")
-
-    if userRole != "admin" {
-        c.JSON(http.StatusForbidden, gin.H{
-            "error": "Admin access required",
-        })
-        return
-    }
-
-    // Get project details to access widget settings
-    project, err := getProjectByID(projectID)
-    if err != nil {
-        if err == mongo.ErrNoDocuments {
-            c.JSON(http.StatusNotFound, gin.H{
-                "error": "Project not found",
-            })
-            return
-        }
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "Failed to fetch project",
-        })
-        return
-    }
-
-    // Generate enhanced embed code
-    embedCode := generateEnhancedEmbedCode(projectID, project.WidgetSettings)
-    
-    // Get domain
-    domain := getDomain()
-
-    c.JSON(http.StatusOK, gin.H{
-        "success":     true,
-        "embed_code":  embedCode,
-        "widget_url":  fmt.Sprintf("%s/widget.js", domain),
-        "project_id":  projectID,
-        "domain":      domain,
-        "iframe_url":  fmt.Sprintf("%s/embed/%s", domain, projectID),
-    })
+	projectID := c.Param("id")
+	userRole := c.GetString("user_role")
+
+	if userRole != "admin" {
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, "Admin access required")
+		return
+	}
+
+	// Get project details to access widget settings
+	project, err := getProjectByID(c.Request.Context(), projectID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch project")
+		return
+	}
+
+	// Generate enhanced embed code
+	embedCode := generateEnhancedEmbedCode(projectID, project.WidgetSettings)
+
+	// Get domain
+	domain := getDomain()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"embed_code": embedCode,
+		"widget_url": WidgetScriptURL(),
+		"project_id": projectID,
+		"domain":     domain,
+		"iframe_url": fmt.Sprintf("%s/embed/%s", domain, projectID),
+	})
 }
 
 // generateEnhancedEmbedCode - Generate embeddable widget code with full configuration
 func generateEnhancedEmbedCode(projectID string, widgetSettings models.ProjectWidgetConfig) string {
-    domain := getDomain()
-    
-    return fmt.Sprintf(`<!-- Troika Tech Chatbot Widget -->
+	domain := getDomain()
+
+	return fmt.Sprintf(`<!-- Troika Tech Chatbot Widget -->
 <div id="troika-chatbot-%s"></div>
 <script>
   (function() {
@@ -456,7 +666,7 @@ func generateEnhancedEmbedCode(projectID string, widgetSettings models.ProjectWi
     };
     
     var script = document.createElement('script');
-    script.src = '%s/widget.js';
+    script.src = '%s';
     script.setAttribute('data-project-id', '%s');
     script.onload = function() {
       if (typeof TroikaChatbot !== 'undefined') {
@@ -471,27 +681,25 @@ func generateEnhancedEmbedCode(projectID string, widgetSettings models.ProjectWi
     script.async = true;
     document.head.appendChild(script);
   })();
-</script>`, 
-        projectID,                           // div id
-        projectID,                           // config.projectId
-        widgetSettings.Theme,                // config.theme
-        widgetSettings.Position,             // config.position
-        widgetSettings.PrimaryColor,         // config.primaryColor
-        widgetSettings.WelcomeMessage,       // config.welcomeMessage
-        "Type your message...",              // config.placeholder (default)
-        "500px",                            // config.height (default)
-        "350px",                            // config.width (default)
-        widgetSettings.ShowBranding,         // config.showBranding
-        true,                               // config.enableSound (default)
-        false,                              // config.autoOpen (default)
-        3000,                               // config.triggerDelay (default)
-        domain,                             // config.apiUrl
-        domain,                             // script.src
-        projectID)                          // data-project-id
+</script>`,
+		projectID,                     // div id
+		projectID,                     // config.projectId
+		widgetSettings.Theme,          // config.theme
+		widgetSettings.Position,       // config.position
+		widgetSettings.PrimaryColor,   // config.primaryColor
+		widgetSettings.WelcomeMessage, // config.welcomeMessage
+		"Type your message...",        // config.placeholder (default)
+		"500px",                       // config.height (default)
+		"350px",                       // config.width (default)
+		widgetSettings.ShowBranding,   // config.showBranding
+		true,                          // config.enableSound (default)
+		false,                         // config.autoOpen (default)
+		3000,                          // config.triggerDelay (default)
+		domain,                        // config.apiUrl
+		versionedWidgetURL(domain),    // script.src
+		projectID)                     // data-project-id
 }
 
-
-
 // generateUniqueProjectID - Generate unique project identifier
 func generateUniqueProjectID() string {
 	timestamp := time.Now().Unix()
@@ -522,12 +730,12 @@ func generateEmbedCode(projectID string) string {
 	return fmt.Sprintf(`<script>
 (function() {
     var script = document.createElement('script');
-    script.src = '%s/widget.js';
+    script.src = '%s';
     script.setAttribute('data-project-id', '%s');
     script.async = true;
     document.head.appendChild(script);
 })();
-</script>`, baseURL, projectID)
+</script>`, versionedWidgetURL(baseURL), projectID)
 }
 
 // createClientRecord - Create client record
@@ -565,75 +773,45 @@ func getStringOrDefault(value, defaultValue string) string {
 
 // DeleteProject - Soft delete project
 func DeleteProject(c *gin.Context) {
-    projectID := c.Param("id")
-    
-    if projectID == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Project ID is required"})
-        return
-    }
-
-    collection := config.GetProjectsCollection()
-    
-    // Perform soft delete by updating status and is_active fields
-    update := bson.M{
-        "$set": bson.M{
-            "status":     "deleted",
-            "is_active":  false,
-            "updated_at": time.Now(),
-        },
-    }
-
-    result, err := collection.UpdateOne(context.Background(), 
-        bson.M{"project_id": projectID}, update)
-    if err != nil {
-        log.Printf("❌ Failed to delete project %s: %v", projectID, err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
-        return
-    }
-
-    if result.ModifiedCount == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-        return
-    }
-
-    // Log deletion action
-    config.LogNotification(primitive.NilObjectID, "deletion", 
-        fmt.Sprintf("Project %s was deleted", projectID))
-
-    log.Printf("⚠️ Project soft deleted: %s", projectID)
-
-    c.JSON(http.StatusOK, gin.H{
-        "message": "Project deleted successfully",
-        "project_id": projectID,
-    })
-}
-
+	projectID := c.Param("id")
 
+	if projectID == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Project ID is required")
+		return
+	}
 
-// GET /api/admin/projects?page=1&limit=10
-func GetProjects(c *gin.Context) {
-    page,  _ := strconv.Atoi(c.DefaultQuery("page",  "1"))
-    limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	collection := config.GetProjectsCollection()
 
-    skip := (page - 1) * limit
+	// Perform soft delete by updating status and is_active fields
+	update := bson.M{
+		"$set": bson.M{
+			"status":     "deleted",
+			"is_active":  false,
+			"updated_at": time.Now(),
+		},
+	}
 
-    opts  := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).
-                     SetSort(bson.D{{"created_at", -1}})
+	result, err := collection.UpdateOne(c.Request.Context(),
+		bson.M{"project_id": projectID}, update)
+	if err != nil {
+		log.Printf("❌ Failed to delete project %s: %v", projectID, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete project")
+		return
+	}
 
-    cur, _ := config.GetProjectsCollection().Find(context.Background(), bson.M{}, opts)
+	if result.ModifiedCount == 0 {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
 
-    var projects []models.Project
-    cur.All(c, &projects)
+	// Log deletion action
+	config.LogNotification(primitive.NilObjectID, "deletion",
+		fmt.Sprintf("Project %s was deleted", projectID))
 
-    total, _ := config.GetProjectsCollection().CountDocuments(c, bson.M{})
+	log.Printf("⚠️ Project soft deleted: %s", projectID)
 
-    c.JSON(http.StatusOK, gin.H{
-        "projects": projects,
-        "pagination": gin.H{
-            "page": page,
-            "limit": limit,
-            "total": total,
-            "pages": int(math.Ceil(float64(total)/float64(limit))),
-        },
-    })
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Project deleted successfully",
+		"project_id": projectID,
+	})
 }
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/middleware"
+	"jevi-chat/models"
+)
+
+// projectConfigVersion - Format version written by ExportProjectConfig; bump when fields change meaning
+const projectConfigVersion = 1
+
+// ProjectConfig - Portable project settings. Deliberately leaves out anything tied to the original
+// project or its client: IDs, embed code, owner, API keys, notification channels (their URLs carry
+// tokens), usage and cost counters, subscription dates and documents.
+type ProjectConfig struct {
+	Version         int       `json:"version"`
+	ExportedAt      time.Time `json:"exported_at,omitempty"`
+	SourceProjectID string    `json:"source_project_id,omitempty"`
+
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+
+	WidgetSettings models.ProjectWidgetConfig `json:"widget_settings"`
+
+	AIProvider        string `json:"ai_provider,omitempty"`
+	FallbackProvider  string `json:"fallback_provider,omitempty"`
+	OpenAIModel       string `json:"openai_model,omitempty"`
+	EmbeddingModel    string `json:"embedding_model,omitempty"`
+	SystemPrompt      string `json:"system_prompt,omitempty"`
+	CantAnswerMessage string `json:"cant_answer_message,omitempty"`
+	RedactPII         bool   `json:"redact_pii,omitempty"`
+
+	MonthlyTokenLimit      int64  `json:"monthly_token_limit,omitempty"`
+	OveragePolicy          string `json:"overage_policy,omitempty"`
+	UsageWarningThresholds []int  `json:"usage_warning_thresholds,omitempty"`
+
+	Tools []models.ProjectTool `json:"tools,omitempty"`
+}
+
+// ExportProjectConfig - GET /api/admin/projects/:id/export-config
+// Downloads the project's settings as JSON that ImportProjectConfig accepts unchanged
+func ExportProjectConfig(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	exported := ProjectConfig{
+		Version:                projectConfigVersion,
+		ExportedAt:             time.Now(),
+		SourceProjectID:        project.ProjectID,
+		Name:                   project.Name,
+		Description:            project.Description,
+		Category:               project.Category,
+		WidgetSettings:         project.WidgetSettings,
+		AIProvider:             project.AIProvider,
+		FallbackProvider:       project.FallbackProvider,
+		OpenAIModel:            project.OpenAIModel,
+		EmbeddingModel:         project.EmbeddingModel,
+		SystemPrompt:           project.SystemPrompt,
+		CantAnswerMessage:      project.CantAnswerMessage,
+		RedactPII:              project.RedactPII,
+		MonthlyTokenLimit:      project.MonthlyTokenLimit,
+		OveragePolicy:          project.OveragePolicy,
+		UsageWarningThresholds: project.UsageWarningThresholds,
+		Tools:                  project.Tools,
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("project-config-%s.json", project.ProjectID)))
+	c.JSON(http.StatusOK, exported)
+}
+
+// ImportProjectConfig - POST /api/admin/projects/import-config
+// Creates a new project (new project_id and embed code) from an exported configuration. The new
+// project starts active on the default subscription, with no owner, documents or usage.
+func ImportProjectConfig(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileBytes)
+
+	var imported ProjectConfig
+	if err := c.ShouldBindJSON(&imported); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid project configuration JSON")
+		return
+	}
+	if err := validateProjectConfig(&imported); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	subDefaults := config.GetSubscriptionDefaults()
+	if imported.MonthlyTokenLimit == 0 {
+		imported.MonthlyTokenLimit = subDefaults.MonthlyTokenLimit
+	}
+	createdBy, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+
+	now := time.Now()
+	projectID := fmt.Sprintf("proj_%d_%s", now.Unix(), generateRandomString(8))
+
+	project := models.Project{
+		ID:                     primitive.NewObjectID(),
+		ProjectID:              projectID,
+		Name:                   imported.Name,
+		Description:            imported.Description,
+		Category:               imported.Category,
+		CreatedByUserID:        createdBy,
+		StartDate:              now,
+		ExpiryDate:             now.AddDate(0, subDefaults.Months, 0),
+		Status:                 models.ProjectStatusActive,
+		MonthlyTokenLimit:      imported.MonthlyTokenLimit,
+		Plan:                   models.PlanPaid,
+		OveragePolicy:          imported.OveragePolicy,
+		UsageWarningThresholds: imported.UsageWarningThresholds,
+		EmbedCode:              generateEmbedCode(projectID),
+		WidgetSettings:         imported.WidgetSettings,
+		AIProvider:             imported.AIProvider,
+		FallbackProvider:       imported.FallbackProvider,
+		OpenAIModel:            imported.OpenAIModel,
+		EmbeddingModel:         imported.EmbeddingModel,
+		SystemPrompt:           imported.SystemPrompt,
+		CantAnswerMessage:      imported.CantAnswerMessage,
+		RedactPII:              imported.RedactPII,
+		Tools:                  imported.Tools,
+		PDFFiles:               []models.PDFFile{},
+		CreatedAt:              now,
+		UpdatedAt:              now,
+		IsActive:               true,
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		log.Printf("❌ Failed to import project config %q: %v", project.Name, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create project")
+		return
+	}
+
+	log.Printf("✅ Project %s imported from config of %s by %s", projectID, imported.SourceProjectID, c.GetString("user_email"))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Project created from configuration",
+		"project": gin.H{
+			"id":                  project.ID.Hex(),
+			"project_id":          project.ProjectID,
+			"name":                project.Name,
+			"status":              project.Status,
+			"monthly_token_limit": project.MonthlyTokenLimit,
+			"embed_code":          project.EmbedCode,
+			"source_project_id":   imported.SourceProjectID,
+			"expiry_date":         project.ExpiryDate,
+		},
+	})
+}
+
+// validateProjectConfig - Apply the same rules UpdateProject and the tools endpoint enforce,
+// filling in defaults for settings an older or hand-written export leaves out
+func validateProjectConfig(cfg *ProjectConfig) error {
+	if cfg.Version < 1 || cfg.Version > projectConfigVersion {
+		return fmt.Errorf("Unsupported configuration version %d (expected %d)", cfg.Version, projectConfigVersion)
+	}
+
+	cfg.Name = strings.TrimSpace(cfg.Name)
+	if cfg.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if cfg.Category == "" {
+		cfg.Category = "chatbot"
+	}
+
+	if cfg.AIProvider == "" {
+		cfg.AIProvider = models.AIProviderOpenAI
+	}
+	if !models.IsValidAIProvider(cfg.AIProvider) {
+		return fmt.Errorf("ai_provider must be openai or gemini")
+	}
+	if cfg.FallbackProvider != "" {
+		if !models.IsValidAIProvider(cfg.FallbackProvider) {
+			return fmt.Errorf("fallback_provider must be openai or gemini")
+		}
+		if cfg.FallbackProvider == cfg.AIProvider {
+			return fmt.Errorf("fallback_provider must differ from ai_provider")
+		}
+	}
+
+	if cfg.OpenAIModel == "" {
+		cfg.OpenAIModel = "gpt-4o"
+	}
+	if !supportedChatModels[cfg.OpenAIModel] {
+		return fmt.Errorf("openai_model %q is not supported", cfg.OpenAIModel)
+	}
+	if cfg.EmbeddingModel != "" && !models.IsValidEmbeddingModel(cfg.EmbeddingModel) {
+		return fmt.Errorf("embedding_model %q is not supported", cfg.EmbeddingModel)
+	}
+
+	cfg.SystemPrompt = strings.TrimSpace(cfg.SystemPrompt)
+	cfg.CantAnswerMessage = strings.TrimSpace(cfg.CantAnswerMessage)
+	if len(cfg.CantAnswerMessage) > maxCantAnswerMessageLength {
+		return fmt.Errorf("cant_answer_message must be at most %d characters", maxCantAnswerMessageLength)
+	}
+
+	if cfg.MonthlyTokenLimit < 0 || cfg.MonthlyTokenLimit > config.MaxMonthlyTokenLimit {
+		return fmt.Errorf("monthly_token_limit must be between 1 and %d", config.MaxMonthlyTokenLimit)
+	}
+	if cfg.OveragePolicy != "" && !models.IsValidOveragePolicy(cfg.OveragePolicy) {
+		return fmt.Errorf("overage_policy must be one of block, suspend or allow_overage")
+	}
+	if cfg.UsageWarningThresholds != nil {
+		if err := validateThresholds(cfg.UsageWarningThresholds); err != nil {
+			return err
+		}
+		cfg.UsageWarningThresholds = normalizeThresholds(cfg.UsageWarningThresholds)
+	}
+
+	if cfg.Tools != nil {
+		if err := validateProjectTools(cfg.Tools); err != nil {
+			return err
+		}
+	}
+
+	widget := &cfg.WidgetSettings
+	if widget.Schedule != nil {
+		if err := widget.Schedule.Validate(); err != nil {
+			return err
+		}
+	}
+	if widget.AllowedDomains != nil {
+		domains := make([]string, 0, len(widget.AllowedDomains))
+		for _, domain := range widget.AllowedDomains {
+			if domain = middleware.NormalizeAllowedDomain(domain); domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		widget.AllowedDomains = domains
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestValidateProjectConfigDefaults(t *testing.T) {
+	cfg := ProjectConfig{
+		Version:                1,
+		Name:                   "  Acme support  ",
+		CantAnswerMessage:      "  Please email us.  ",
+		UsageWarningThresholds: []int{90, 50, 90},
+		WidgetSettings:         models.ProjectWidgetConfig{AllowedDomains: []string{"https://Acme.example/shop", "  ", "*.shop.example"}},
+	}
+	if err := validateProjectConfig(&cfg); err != nil {
+		t.Fatalf("validateProjectConfig: %v", err)
+	}
+
+	if cfg.Name != "Acme support" || cfg.Category != "chatbot" || cfg.AIProvider != models.AIProviderOpenAI || cfg.OpenAIModel != "gpt-4o" {
+		t.Errorf("defaults = %+v", cfg)
+	}
+	if cfg.CantAnswerMessage != "Please email us." {
+		t.Errorf("cant_answer_message = %q, want it trimmed", cfg.CantAnswerMessage)
+	}
+	if !reflect.DeepEqual(cfg.UsageWarningThresholds, []int{50, 90}) {
+		t.Errorf("thresholds = %v, want them sorted and deduplicated", cfg.UsageWarningThresholds)
+	}
+	if !reflect.DeepEqual(cfg.WidgetSettings.AllowedDomains, []string{"acme.example", "shop.example"}) {
+		t.Errorf("allowed domains = %q, want them normalised", cfg.WidgetSettings.AllowedDomains)
+	}
+}
+
+func TestValidateProjectConfigRejects(t *testing.T) {
+	valid := func(edit func(*ProjectConfig)) ProjectConfig {
+		cfg := ProjectConfig{Version: 1, Name: "Acme"}
+		edit(&cfg)
+		return cfg
+	}
+	tests := []struct {
+		name    string
+		cfg     ProjectConfig
+		wantErr string
+	}{
+		{"missing version", valid(func(c *ProjectConfig) { c.Version = 0 }), "Unsupported configuration version 0"},
+		{"newer version", valid(func(c *ProjectConfig) { c.Version = 2 }), "Unsupported configuration version 2"},
+		{"blank name", valid(func(c *ProjectConfig) { c.Name = "   " }), "name is required"},
+		{"unknown provider", valid(func(c *ProjectConfig) { c.AIProvider = "claude" }), "ai_provider must be openai or gemini"},
+		{"unknown fallback", valid(func(c *ProjectConfig) { c.FallbackProvider = "claude" }), "fallback_provider must be openai or gemini"},
+		{"fallback to itself", valid(func(c *ProjectConfig) { c.FallbackProvider = models.AIProviderOpenAI }), "fallback_provider must differ from ai_provider"},
+		{"unknown model", valid(func(c *ProjectConfig) { c.OpenAIModel = "gpt-5" }), `openai_model "gpt-5" is not supported`},
+		{"unknown embedding model", valid(func(c *ProjectConfig) { c.EmbeddingModel = "text-embedding-4" }), `embedding_model "text-embedding-4" is not supported`},
+		{"long can't-answer message", valid(func(c *ProjectConfig) { c.CantAnswerMessage = strings.Repeat("x", 301) }), "cant_answer_message must be at most 300 characters"},
+		{"negative token limit", valid(func(c *ProjectConfig) { c.MonthlyTokenLimit = -1 }), "monthly_token_limit must be between 1 and"},
+		{"token limit too large", valid(func(c *ProjectConfig) { c.MonthlyTokenLimit = config.MaxMonthlyTokenLimit + 1 }), "monthly_token_limit must be between 1 and"},
+		{"unknown overage policy", valid(func(c *ProjectConfig) { c.OveragePolicy = "allow" }), "overage_policy must be one of"},
+		{"threshold over 100", valid(func(c *ProjectConfig) { c.UsageWarningThresholds = []int{120} }), "usage_warning_thresholds must be percentages"},
+		{"tool without https", valid(func(c *ProjectConfig) {
+			c.Tools = []models.ProjectTool{{Name: "lookup", Description: "Look up orders", WebhookURL: "http://hooks.example.com"}}
+		}), `Tool "lookup" webhook_url must be an https URL`},
+		{"bad schedule", valid(func(c *ProjectConfig) {
+			c.WidgetSettings.Schedule = &models.WidgetSchedule{Timezone: "Mars/Olympus"}
+		}), `unknown timezone "Mars/Olympus"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateProjectConfig(&tt.cfg); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// projectConfigRouter - The export and import routes, acting as admin@example.com
+func projectConfigRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_email", "admin@example.com") })
+	r.GET("/projects/:id/export-config", ExportProjectConfig)
+	r.POST("/projects/import-config", ImportProjectConfig)
+	return r
+}
+
+func importProjectConfig(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/projects/import-config", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportProjectConfigRejectsInvalidInput(t *testing.T) {
+	r := projectConfigRouter()
+	tests := []struct {
+		name, body, wantMessage string
+	}{
+		{"malformed", `{"version":1,`, "Invalid project configuration JSON"},
+		{"wrong type", `{"version":"1","name":"Acme"}`, "Invalid project configuration JSON"},
+		{"unsupported version", `{"version":9,"name":"Acme"}`, "Unsupported configuration version 9"},
+		{"no name", `{"version":1}`, "name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Refused before the project is created, so no database is needed
+			w := importProjectConfig(r, tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("got %d %s, want 400 %q", w.Code, w.Body, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestProjectConfigRoundTrip(t *testing.T) {
+	ctx := useTestDatabase(t)
+	r := projectConfigRouter()
+
+	source := models.Project{
+		ProjectID:              "proj_source",
+		Name:                   "Acme support",
+		Category:               "support",
+		ClientID:               "client_1",
+		OpenAIAPIKey:           "sk-secret",
+		OpenAIModel:            "gpt-4o-mini",
+		SystemPrompt:           "You are Acme's bot.",
+		CantAnswerMessage:      "Please email us.",
+		MonthlyTokenLimit:      250000,
+		TotalTokensUsed:        12345,
+		OveragePolicy:          models.OveragePolicySuspend,
+		UsageWarningThresholds: []int{50, 90},
+		WidgetSettings:         models.ProjectWidgetConfig{WelcomeMessage: "Hi!", AllowedDomains: []string{"acme.example"}},
+		Tools:                  []models.ProjectTool{{Name: "lookup", Description: "Look up orders", WebhookURL: "https://hooks.acme.example/orders", Enabled: true}},
+	}
+	config.GetProjectsCollection().InsertOne(ctx, source)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_source/export-config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export = %d %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="project-config-proj_source.json"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	for _, leaked := range []string{"sk-secret", "client_1", "12345"} {
+		if strings.Contains(w.Body.String(), leaked) {
+			t.Errorf("export contains %q: %s", leaked, w.Body)
+		}
+	}
+	exported := w.Body.String()
+
+	w = importProjectConfig(r, exported)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("import = %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Project struct {
+			ProjectID       string `json:"project_id"`
+			SourceProjectID string `json:"source_project_id"`
+		} `json:"project"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Project.ProjectID == "" || resp.Project.ProjectID == "proj_source" || resp.Project.SourceProjectID != "proj_source" {
+		t.Errorf("imported project = %+v, want a new project_id", resp.Project)
+	}
+
+	var imported models.Project
+	if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": resp.Project.ProjectID}).Decode(&imported); err != nil {
+		t.Fatalf("imported project not stored: %v", err)
+	}
+	if imported.Name != source.Name || imported.OpenAIModel != source.OpenAIModel || imported.SystemPrompt != source.SystemPrompt ||
+		imported.CantAnswerMessage != source.CantAnswerMessage || imported.MonthlyTokenLimit != source.MonthlyTokenLimit ||
+		imported.OveragePolicy != source.OveragePolicy || !reflect.DeepEqual(imported.UsageWarningThresholds, source.UsageWarningThresholds) ||
+		imported.WidgetSettings.WelcomeMessage != "Hi!" || len(imported.Tools) != 1 {
+		t.Errorf("imported settings = %+v, want the source's", imported)
+	}
+	if imported.OpenAIAPIKey != "" || imported.ClientID != "" || imported.TotalTokensUsed != 0 || imported.Status != models.ProjectStatusActive ||
+		!strings.Contains(imported.EmbedCode, resp.Project.ProjectID) {
+		t.Errorf("imported project carried over per-project state: %+v", imported)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_missing/export-config", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// Bulk import limits
+const (
+	maxImportFileBytes = 1 << 20 // 1MB
+	maxImportRows      = 500
+)
+
+// supportedChatModels - OpenAI chat models a project may be configured with
+var supportedChatModels = map[string]bool{
+	"gpt-4o":        true,
+	"gpt-4o-mini":   true,
+	"gpt-4-turbo":   true,
+	"gpt-3.5-turbo": true,
+}
+
+// importColumns - CSV columns understood by ImportProjects; only name is required
+var importColumns = []string{"name", "client_email", "monthly_token_limit", "months", "model"}
+
+// ImportRowResult - Outcome of one CSV row
+type ImportRowResult struct {
+	Row       int    `json:"row"` // 1-based line number in the file, header included
+	Name      string `json:"name"`
+	Success   bool   `json:"success"`
+	ProjectID string `json:"project_id,omitempty"`
+	EmbedCode string `json:"embed_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportProjects - POST /api/admin/projects/import
+// Multipart "file" (or a text/csv body) with a header row naming the columns
+// name, client_email, monthly_token_limit, months, model. Each row is validated and created
+// independently: a bad row is reported and skipped, the rest are still imported.
+func ImportProjects(c *gin.Context) {
+	reader, err := importCSVReader(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "CSV file is empty or unreadable")
+		return
+	}
+	columns, err := importColumnIndex(header)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	subDefaults := config.GetSubscriptionDefaults()
+	createdBy, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+
+	results := []ImportRowResult{}
+	created := 0
+	line := 1
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if len(results) >= maxImportRows {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+				fmt.Sprintf("CSV has more than %d rows; split it into smaller files", maxImportRows))
+			return
+		}
+		if err != nil {
+			results = append(results, ImportRowResult{Row: line, Error: "Malformed CSV row"})
+			continue
+		}
+
+		field := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		result := ImportRowResult{Row: line, Name: field("name")}
+		project, err := importProjectFromRow(field, subDefaults, createdBy)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		ctx, cancel := requestContext(c)
+		_, err = config.GetProjectsCollection().InsertOne(ctx, project)
+		cancel()
+		if err != nil {
+			log.Printf("❌ Import row %d: failed to create project %q: %v", line, project.Name, err)
+			result.Error = "Failed to create project"
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		result.ProjectID = project.ProjectID
+		result.EmbedCode = project.EmbedCode
+		results = append(results, result)
+		created++
+	}
+
+	log.Printf("✅ Project import by %s: %d created, %d failed", c.GetString("user_email"), created, len(results)-created)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Imported %d of %d projects", created, len(results)),
+		"created": created,
+		"failed":  len(results) - created,
+		"results": results,
+	})
+}
+
+// importCSVReader - The uploaded "file" part, or the raw body for text/csv requests
+func importCSVReader(c *gin.Context) (io.Reader, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileBytes+(64<<10))
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New("CSV file is required in the \"file\" field")
+		}
+		if fileHeader.Size > maxImportFileBytes {
+			return nil, fmt.Errorf("CSV file exceeds %d bytes", maxImportFileBytes)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, errors.New("Failed to read CSV file")
+		}
+		return file, nil
+	}
+
+	if c.ContentType() != "text/csv" {
+		return nil, errors.New("Upload a CSV file as multipart \"file\" or send a text/csv body")
+	}
+	return io.LimitReader(c.Request.Body, maxImportFileBytes), nil
+}
+
+// importColumnIndex - Map header names (case-insensitive) to positions; unknown columns are rejected
+// so a typo doesn't silently drop a setting
+func importColumnIndex(header []string) (map[string]int, error) {
+	known := make(map[string]bool, len(importColumns))
+	for _, column := range importColumns {
+		known[column] = true
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[name] {
+			return nil, fmt.Errorf("Unknown CSV column %q (expected %s)", name, strings.Join(importColumns, ", "))
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("CSV header must include a name column")
+	}
+	return columns, nil
+}
+
+// importProjectFromRow - Validate one row and build the project it describes
+func importProjectFromRow(field func(string) string, subDefaults config.SubscriptionDefaults, createdBy primitive.ObjectID) (*models.Project, error) {
+	name := field("name")
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	clientEmail := field("client_email")
+	if clientEmail != "" {
+		if _, err := mail.ParseAddress(clientEmail); err != nil {
+			return nil, errors.New("client_email is not a valid email address")
+		}
+	}
+
+	monthlyTokenLimit := subDefaults.MonthlyTokenLimit
+	if value := field("monthly_token_limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 || parsed > config.MaxMonthlyTokenLimit {
+			return nil, fmt.Errorf("monthly_token_limit must be between 1 and %d", config.MaxMonthlyTokenLimit)
+		}
+		monthlyTokenLimit = parsed
+	}
+
+	months := subDefaults.Months
+	if value := field("months"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > config.MaxSubscriptionMonths {
+			return nil, fmt.Errorf("months must be between 1 and %d", config.MaxSubscriptionMonths)
+		}
+		months = parsed
+	}
+
+	model := "gpt-4o"
+	if value := strings.ToLower(field("model")); value != "" {
+		if !supportedChatModels[value] {
+			return nil, fmt.Errorf("model %q is not supported", value)
+		}
+		model = value
+	}
+
+	ownerID, _ := resolveProjectOwner("", clientEmail)
+
+	now := time.Now()
+	projectID := fmt.Sprintf("proj_%d_%s", now.Unix(), generateRandomString(8))
+
+	return &models.Project{
+		ID:                primitive.NewObjectID(),
+		ProjectID:         projectID,
+		Name:              name,
+		Category:          "chatbot",
+		ClientID:          clientEmail,
+		OwnerID:           ownerID,
+		CreatedByUserID:   createdBy,
+		StartDate:         now,
+		ExpiryDate:        now.AddDate(0, months, 0),
+		Status:            "active",
+		MonthlyTokenLimit: monthlyTokenLimit,
+		Plan:              models.PlanPaid,
+		EmbedCode:         generateEmbedCode(projectID),
+		WidgetSettings: models.ProjectWidgetConfig{
+			Theme:          "default",
+			PrimaryColor:   "#4f46e5",
+			WelcomeMessage: "Hello! How can I help you today?",
+			Position:       "bottom-right",
+			ShowBranding:   true,
+			EnableRating:   true,
+		},
+		AIProvider:  "openai",
+		OpenAIModel: model,
+		PDFFiles:    []models.PDFFile{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		IsActive:    true,
+	}, nil
+}
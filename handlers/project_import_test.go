@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// postImport - Send body to ImportProjects with the given content type
+func postImport(contentType string, body []byte) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/import", func(c *gin.Context) {
+		c.Set("user_id", primitive.NewObjectID().Hex())
+		c.Set("user_email", "admin@example.com")
+	}, ImportProjects)
+
+	req := httptest.NewRequest(http.MethodPost, "/import", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportColumnIndex(t *testing.T) {
+	columns, err := importColumnIndex([]string{"\ufeffName", " MODEL ", "client_email"})
+	if err != nil {
+		t.Fatalf("importColumnIndex: %v", err)
+	}
+	if columns["name"] != 0 || columns["model"] != 1 || columns["client_email"] != 2 {
+		t.Errorf("columns = %v", columns)
+	}
+
+	if _, err := importColumnIndex([]string{"name", "monthly_limit"}); err == nil || !strings.Contains(err.Error(), "monthly_limit") {
+		t.Errorf("unknown column: err = %v, want it named", err)
+	}
+	if _, err := importColumnIndex([]string{"client_email"}); err == nil {
+		t.Error("header without name should be rejected")
+	}
+}
+
+func TestImportProjectFromRowRejectsInvalidFields(t *testing.T) {
+	defaults := config.SubscriptionDefaults{MonthlyTokenLimit: 1000, Months: 1}
+
+	tests := []struct {
+		name string
+		row  map[string]string
+		want string
+	}{
+		{"missing name", map[string]string{"client_email": "a@example.com"}, "name is required"},
+		{"bad email", map[string]string{"name": "Acme", "client_email": "not-an-email"}, "client_email"},
+		{"zero limit", map[string]string{"name": "Acme", "monthly_token_limit": "0"}, "monthly_token_limit"},
+		{"non-numeric limit", map[string]string{"name": "Acme", "monthly_token_limit": "lots"}, "monthly_token_limit"},
+		{"limit over max", map[string]string{"name": "Acme", "monthly_token_limit": "99999999999999"}, "monthly_token_limit"},
+		{"negative months", map[string]string{"name": "Acme", "months": "-1"}, "months"},
+		{"months over max", map[string]string{"name": "Acme", "months": "1000"}, "months"},
+		{"unsupported model", map[string]string{"name": "Acme", "model": "gpt-2"}, "not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := func(column string) string { return tt.row[column] }
+			project, err := importProjectFromRow(field, defaults, primitive.NilObjectID)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+			if project != nil {
+				t.Error("invalid row should not build a project")
+			}
+		})
+	}
+}
+
+func TestImportProjectsRejectsBadUploads(t *testing.T) {
+	var emptyForm bytes.Buffer
+	formWriter := multipart.NewWriter(&emptyForm)
+	formWriter.WriteField("name", "Acme")
+	formWriter.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json body", "application/json", `{"name":"Acme"}`, "text/csv"},
+		{"multipart without file", formWriter.FormDataContentType(), emptyForm.String(), "\\\"file\\\""},
+		{"empty csv", "text/csv", "", "empty"},
+		{"unknown column", "text/csv", "name,plan\nAcme,pro\n", "plan"},
+		{"no name column", "text/csv", "client_email\na@example.com\n", "name column"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postImport(tt.contentType, []byte(tt.body))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrCodeValidationFailed) {
+				t.Fatalf("got %d %s, want 400 %s", w.Code, w.Body, ErrCodeValidationFailed)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body %s should mention %s", w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportProjectsRejectsTooManyRows(t *testing.T) {
+	var body strings.Builder
+	body.WriteString("name,model\n")
+	for i := 0; i <= maxImportRows; i++ {
+		// Every row fails validation, so the limit is reached without touching the database
+		body.WriteString("Acme,gpt-2\n")
+	}
+
+	w := postImport("text/csv", []byte(body.String()))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "more than") {
+		t.Errorf("got %d %s, want 400 for too many rows", w.Code, w.Body)
+	}
+}
+
+func TestImportProjects(t *testing.T) {
+	ctx := useTestDatabase(t)
+	defaults := config.GetSubscriptionDefaults()
+
+	csvBody := "name,client_email,monthly_token_limit,months,model\n" +
+		"Acme,acme@example.com,20000,6,GPT-4o-mini\n" +
+		",missing@example.com,,,\n" +
+		"\"Unclosed,quote\n"
+	var form bytes.Buffer
+	formWriter := multipart.NewWriter(&form)
+	part, _ := formWriter.CreateFormFile("file", "projects.csv")
+	part.Write([]byte("name\nDefaults Co\n"))
+	formWriter.Close()
+
+	w := postImport("text/csv", []byte(csvBody))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want 200", w.Code, w.Body)
+	}
+	var resp struct {
+		Created int               `json:"created"`
+		Failed  int               `json:"failed"`
+		Results []ImportRowResult `json:"results"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Created != 1 || resp.Failed != 2 || len(resp.Results) != 3 {
+		t.Fatalf("created=%d failed=%d results=%+v, want 1 created and 2 failed", resp.Created, resp.Failed, resp.Results)
+	}
+	if first := resp.Results[0]; !first.Success || first.Row != 2 || first.ProjectID == "" || first.EmbedCode == "" {
+		t.Errorf("first row = %+v, want a created project on line 2", first)
+	}
+	if second := resp.Results[1]; second.Success || second.Row != 3 || second.Error != "name is required" {
+		t.Errorf("second row = %+v, want a name error on line 3", second)
+	}
+	if third := resp.Results[2]; third.Success || third.Error != "Malformed CSV row" {
+		t.Errorf("third row = %+v, want a malformed row", third)
+	}
+
+	var stored models.Project
+	if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": resp.Results[0].ProjectID}).Decode(&stored); err != nil {
+		t.Fatalf("imported project not stored: %v", err)
+	}
+	if stored.MonthlyTokenLimit != 20000 || stored.OpenAIModel != "gpt-4o-mini" || stored.ClientID != "acme@example.com" {
+		t.Errorf("stored = limit %d model %q client %q", stored.MonthlyTokenLimit, stored.OpenAIModel, stored.ClientID)
+	}
+	if days := stored.ExpiryDate.Sub(stored.StartDate).Hours() / 24; days < 180 || days > 185 {
+		t.Errorf("expiry is %.0f days after start, want about 6 months", days)
+	}
+
+	// A multipart upload with only a name falls back to the subscription defaults
+	w = postImport(formWriter.FormDataContentType(), form.Bytes())
+	if w.Code != http.StatusOK {
+		t.Fatalf("multipart import: got %d %s", w.Code, w.Body)
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Created != 1 {
+		t.Fatalf("multipart import results = %+v", resp.Results)
+	}
+	var defaulted models.Project
+	config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": resp.Results[0].ProjectID}).Decode(&defaulted)
+	if defaulted.MonthlyTokenLimit != defaults.MonthlyTokenLimit || defaulted.OpenAIModel != "gpt-4o" {
+		t.Errorf("defaulted project = limit %d model %q, want %d gpt-4o", defaulted.MonthlyTokenLimit, defaulted.OpenAIModel, defaults.MonthlyTokenLimit)
+	}
+}
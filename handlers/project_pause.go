@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// maxPauseMessageLength - Longest notice shown to visitors of a paused project
+const maxPauseMessageLength = 500
+
+// PauseProjectChat - POST /api/admin/projects/:id/pause (and /api/user/projects/:id/pause)
+// Switches the bot off without touching the subscription: status, expiry and usage stay as they
+// are and the project still counts as active. Chat requests get 503 with the pause message.
+func PauseProjectChat(c *gin.Context) {
+	var body struct {
+		Message string `json:"message"`
+	}
+	// The message is optional
+	_ = c.ShouldBindJSON(&body)
+
+	message := strings.TrimSpace(body.Message)
+	if len(message) > maxPauseMessageLength {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "message is too long")
+		return
+	}
+
+	set := bson.M{"chat_paused": true, "paused_at": time.Now(), "updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	if message != "" {
+		set["pause_message"] = message
+	} else {
+		update["$unset"] = bson.M{"pause_message": ""}
+	}
+	setProjectChatPaused(c, update, "chat_paused")
+}
+
+// ResumeProjectChat - POST /api/admin/projects/:id/resume (and /api/user/projects/:id/resume)
+func ResumeProjectChat(c *gin.Context) {
+	setProjectChatPaused(c, bson.M{
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{"chat_paused": "", "paused_at": "", "pause_message": ""},
+	}, "chat_resumed")
+}
+
+// setProjectChatPaused - Apply a pause/resume update and log it on the project's timeline
+func setProjectChatPaused(c *gin.Context, update bson.M, notificationType string) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	// Behind ProjectOwnershipMiddleware the :id may be either form of the ID
+	projectID := c.Param("id")
+	if owned := c.GetString("owned_project_id"); owned != "" {
+		projectID = owned
+	}
+
+	var project models.Project
+	err := config.GetProjectsCollection().FindOneAndUpdate(ctx,
+		bson.M{"project_id": projectID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&project)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update project")
+		return
+	}
+
+	state := "resumed"
+	if project.ChatPaused {
+		state = "paused"
+	}
+	config.RecordNotification(project.ID, notificationType,
+		fmt.Sprintf("Chat %s by %s", state, c.GetString("user_email")))
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id":    project.ProjectID,
+		"status":        project.Status,
+		"chat_paused":   project.ChatPaused,
+		"paused_at":     project.PausedAt,
+		"pause_message": project.PauseMessage,
+	})
+}
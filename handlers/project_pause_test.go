@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// pauseRouter - Pause and resume as the admin routes mount them; owned, when set, stands in for
+// ProjectOwnershipMiddleware on the user routes
+func pauseRouter(owned string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_email", "owner@example.com")
+		if owned != "" {
+			c.Set("owned_project_id", owned)
+		}
+	})
+	r.POST("/projects/:id/pause", PauseProjectChat)
+	r.POST("/projects/:id/resume", ResumeProjectChat)
+	return r
+}
+
+func postPause(r *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPauseProjectChatRejectsLongMessage(t *testing.T) {
+	// Refused before the project is updated, so no database is needed
+	body := `{"message":"` + strings.Repeat("x", maxPauseMessageLength+1) + `"}`
+	if w := postPause(pauseRouter(""), "/projects/proj_1/pause", body); w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestPauseAndResumeProjectChat(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	expiry := time.Now().AddDate(0, 1, 0).Truncate(time.Millisecond)
+	project := models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_pause", Status: "active", ExpiryDate: expiry, TotalTokensUsed: 42}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	stored := func() models.Project {
+		var p models.Project
+		config.GetProjectsCollection().FindOne(ctx, bson.M{"_id": project.ID}).Decode(&p)
+		return p
+	}
+
+	r := pauseRouter("")
+	w := postPause(r, "/projects/proj_pause/pause", `{"message":"  Closed for the holidays "}`)
+	var resp struct {
+		Status       string `json:"status"`
+		ChatPaused   bool   `json:"chat_paused"`
+		PauseMessage string `json:"pause_message"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || !resp.ChatPaused || resp.PauseMessage != "Closed for the holidays" || resp.Status != "active" {
+		t.Errorf("pause = %d %+v, want paused with the trimmed message and the status untouched", w.Code, resp)
+	}
+	if p := stored(); !p.ChatPaused || p.PausedAt == nil || !p.ExpiryDate.Equal(expiry) || p.TotalTokensUsed != 42 {
+		t.Errorf("stored = %+v, want paused with the subscription unchanged", p)
+	}
+
+	// Pausing again without a message drops the old one
+	postPause(r, "/projects/proj_pause/pause", "")
+	if p := stored(); !p.ChatPaused || p.PauseMessage != "" {
+		t.Errorf("re-paused = %+v, want the message cleared", p)
+	}
+
+	// The owner's route resolves the project through the ownership middleware
+	if w := postPause(pauseRouter("proj_pause"), "/projects/"+project.ID.Hex()+"/resume", ""); w.Code != http.StatusOK {
+		t.Fatalf("resume: %d %s", w.Code, w.Body)
+	}
+	if p := stored(); p.ChatPaused || p.PausedAt != nil || p.Status != "active" {
+		t.Errorf("resumed = %+v, want the pause fields removed", p)
+	}
+
+	for _, typ := range []string{"chat_paused", "chat_resumed"} {
+		if count, _ := config.GetNotificationsCollection().CountDocuments(ctx, bson.M{"project_id": project.ID, "type": typ}); count == 0 {
+			t.Errorf("no %s entry on the project's timeline", typ)
+		}
+	}
+
+	if w := postPause(r, "/projects/proj_missing/pause", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
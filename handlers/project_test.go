@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// postProjectForm - POST fields as multipart form data to CreateProject as the admin adminID
+func postProjectForm(t *testing.T, adminID string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	form.Close()
+
+	r := gin.New()
+	r.POST("/projects", func(c *gin.Context) {
+		c.Set("user_id", adminID)
+		c.Set("user_role", "admin")
+	}, CreateProject)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/projects", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateProjectRejectsOutOfRangeSubscriptionOverrides(t *testing.T) {
+	tests := []struct {
+		field, value, wantMessage string
+	}{
+		{"monthly_token_limit", "0", "monthly_token_limit must be between 1 and 100000000"},
+		{"monthly_token_limit", "-500", "monthly_token_limit must be between"},
+		{"monthly_token_limit", "100000001", "monthly_token_limit must be between"},
+		{"monthly_token_limit", "lots", "monthly_token_limit must be between"},
+		{"subscription_months", "0", "subscription_months must be between 1 and 60"},
+		{"subscription_months", "61", "subscription_months must be between"},
+		{"subscription_months", "1.5", "subscription_months must be between"},
+		{"embedding_model", "text-embedding-4", "embedding_model must be text-embedding-ada-002"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field+"="+tt.value, func(t *testing.T) {
+			// Refused before anything is stored, so no database is needed
+			w := postProjectForm(t, "admin_1", map[string]string{"name": "Acme", tt.field: tt.value})
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), ErrCodeValidationFailed) || !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("body = %s, want %s with %q", w.Body, ErrCodeValidationFailed, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestUpdateProjectRejectsInvalidSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/projects/:id", UpdateProject)
+
+	tests := []struct {
+		name, body, wantMessage string
+	}{
+		{"malformed", `{"name":`, "Invalid update data"},
+		{"unknown overage policy", `{"overage_policy":"allow"}`, "overage_policy must be one of"},
+		{"malformed notification email", `{"notification_email":"alerts at example"}`, "notification_email is not a valid email address"},
+		{"webhook without a scheme", `{"notification_webhook_url":"hooks.example.com/n"}`, "notification_webhook_url must be an http(s) URL"},
+		{"webhook with another scheme", `{"notification_webhook_url":"ftp://hooks.example.com/n"}`, "notification_webhook_url must be an http(s) URL"},
+		{"plain http Slack webhook", `{"notification_slack_url":"http://hooks.slack.com/services/T0/B0/x"}`, "notification_slack_url must be an https URL"},
+		{"Slack webhook without a host", `{"notification_slack_url":"https:///services/T0"}`, "notification_slack_url must be an https URL"},
+		{"usage threshold over 100", `{"usage_warning_thresholds":[80,120]}`, "usage_warning_thresholds must be percentages between 1 and 100"},
+		{"too many usage thresholds", `{"usage_warning_thresholds":[1,2,3,4,5,6,7,8,9,10,11]}`, "usage_warning_thresholds may have at most 10 entries"},
+		{"unknown embedding model", `{"embedding_model":"text-embedding-4"}`, "embedding_model must be text-embedding-ada-002"},
+		{"long can't-answer message", `{"cant_answer_message":"` + strings.Repeat("x", 301) + `"}`, "cant_answer_message must be at most 300 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Refused before the update is written, so no database is needed
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/projects/proj_1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("got %d %s, want 400 mentioning %q", w.Code, w.Body, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestCreateProjectRecordsCreatorAndOwner(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	admin := primitive.NewObjectID()
+	owner := models.User{ID: primitive.NewObjectID(), Email: "client@example.com"}
+	if _, err := config.GetCollection("users").InsertOne(ctx, owner); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	w := postProjectForm(t, admin.Hex(), map[string]string{"name": "Acme", "client_email": "Client@Example.com"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Project struct {
+			ProjectID       string `json:"project_id"`
+			OwnerID         string `json:"owner_id"`
+			CreatedByUserID string `json:"created_by_user_id"`
+		} `json:"project"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Project.CreatedByUserID != admin.Hex() || resp.Project.OwnerID != owner.ID.Hex() {
+		t.Errorf("response = %+v, want creator %s and the registered client as owner", resp.Project, admin.Hex())
+	}
+
+	var stored models.Project
+	if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": resp.Project.ProjectID}).Decode(&stored); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if stored.CreatedByUserID != admin {
+		t.Errorf("stored created_by_user_id = %s, want %s", stored.CreatedByUserID.Hex(), admin.Hex())
+	}
+
+	if w := postProjectForm(t, admin.Hex(), map[string]string{"name": "Acme", "owner_id": "nobody"}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid owner_id: status = %d, want 400", w.Code)
+	}
+}
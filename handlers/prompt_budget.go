@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+// chatResponseTokens - Completion budget of a chat answer, kept free in the context window
+const chatResponseTokens = 500
+
+// defaultContextWindow - Assumed window for models missing from modelContextWindows
+const defaultContextWindow = 8192
+
+// messageOverheadTokens - Role and framing tokens the API adds per message
+const messageOverheadTokens = 4
+
+// modelContextWindows - Context window (prompt + completion tokens) of the models projects use.
+// Prefixes match dated variants such as gpt-4o-2024-08-06; longer prefixes are checked first.
+var modelContextWindows = []struct {
+	Prefix string
+	Tokens int
+}{
+	{"gpt-4o-mini", 128000},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"gemini-1.5-flash", 1048576},
+	{"gemini-1.5-pro", 2097152},
+}
+
+// errPromptTooLarge - Even the trimmed prompt doesn't fit the model's context window
+var errPromptTooLarge = errors.New("message is too long for the model's context window")
+
+// contextWindow - Context window of model
+func contextWindow(model string) int {
+	for _, window := range modelContextWindows {
+		if strings.HasPrefix(model, window.Prefix) {
+			return window.Tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// fitPromptToWindow - Check the prompt for model before calling the provider and, when it would
+// overflow the context window (leaving chatResponseTokens for the answer), trim it: oldest history
+// turns go first, then the tail of the document context. Returns errPromptTooLarge when the system
+// prompt and message alone don't fit. Sizes are countTokens estimates, so a margin is kept for error.
+func fitPromptToWindow(model, systemPrompt string, req *chatRequest) error {
+	budget := contextWindow(model)*9/10 - chatResponseTokens
+
+	promptTokens := func() int {
+		tokens := estimateTextTokens(req.systemMessage(systemPrompt), req.History.Summary, req.Message)
+		tokens += messageOverheadTokens * (2 + 2*len(req.History.Turns))
+		return tokens + estimateTurnTokens(req.History.Turns)
+	}
+
+	tokens := promptTokens()
+	if tokens <= budget {
+		return nil
+	}
+
+	for tokens > budget && len(req.History.Turns) > 0 {
+		req.History.Turns = req.History.Turns[1:]
+		tokens = promptTokens()
+	}
+
+	// Cut the document's tail by about the overflow (a token is rarely more than four
+	// characters), re-counting until it fits or the document is gone
+	for tokens > budget && hasDocumentContext(req.DocumentContext) {
+		keep := []rune(req.DocumentContext)
+		over := (tokens - budget) * 4
+		if over < len(keep) {
+			req.DocumentContext = string(keep[:len(keep)-over])
+		} else {
+			req.DocumentContext = ""
+		}
+		tokens = promptTokens()
+	}
+
+	if tokens > budget {
+		return errPromptTooLarge
+	}
+	log.Printf("✂️ Prompt trimmed to ~%d tokens to fit %s", tokens, model)
+	return nil
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"jevi-chat/models"
+)
+
+func TestCountTokens(t *testing.T) {
+	// Counts from OpenAI's cl100k_base tokenizer
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"1234567", 3},
+		{"Order 42 shipped", 4}, // the space before a number is a token of its own
+	}
+	for _, tt := range tests {
+		if got := countTokens(tt.text); got != tt.want {
+			t.Errorf("countTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+
+	// Scripts without Latin word pieces cost about a token per character, not one per four
+	if got := countTokens("こんにちは世界"); got < 7 {
+		t.Errorf("countTokens of 7 CJK characters = %d, want at least 7", got)
+	}
+	if long := countTokens("internationalization"); long < 2 || long > 4 {
+		t.Errorf("countTokens of a long word = %d, want 2-4", long)
+	}
+}
+
+func TestFitPromptToWindowTrimsOldestTurnsFirst(t *testing.T) {
+	const systemPrompt = "You are the Acme support assistant."
+	sentence := strings.Repeat("word ", 300)
+
+	turns := make([]models.ChatMessage, 40)
+	for i := range turns {
+		turns[i] = models.ChatMessage{Message: fmt.Sprintf("question %d %s", i, sentence), Response: sentence}
+	}
+	req := chatRequest{Message: "latest question", History: conversationHistory{Turns: turns}}
+
+	if err := fitPromptToWindow("gpt-4", systemPrompt, &req); err != nil {
+		t.Fatalf("fitPromptToWindow: %v", err)
+	}
+
+	kept := req.History.Turns
+	if len(kept) == 0 || len(kept) == len(turns) {
+		t.Fatalf("kept %d of %d turns, want some trimmed", len(kept), len(turns))
+	}
+	if last := kept[len(kept)-1].Message; !strings.HasPrefix(last, "question 39 ") {
+		t.Errorf("newest turn dropped, last kept is %.12q", last)
+	}
+	if first := kept[0].Message; !strings.HasPrefix(first, fmt.Sprintf("question %d ", len(turns)-len(kept))) {
+		t.Errorf("kept turns are not the newest ones, first kept is %.12q", first)
+	}
+	if !strings.Contains(req.systemMessage(systemPrompt), systemPrompt) || req.Message != "latest question" {
+		t.Error("system prompt or visitor message was trimmed")
+	}
+
+	budget := contextWindow("gpt-4")*9/10 - chatResponseTokens
+	used := estimateTextTokens(req.systemMessage(systemPrompt), req.Message) + estimateTurnTokens(kept)
+	if used > budget {
+		t.Errorf("trimmed prompt is %d tokens, over the %d budget", used, budget)
+	}
+}
+
+func TestFitPromptToWindowTrimsDocumentAfterHistory(t *testing.T) {
+	req := chatRequest{
+		Message:         "what does the manual say?",
+		DocumentContext: "Manual start. " + strings.Repeat("detail ", 20000),
+		History:         conversationHistory{Turns: []models.ChatMessage{{Message: "hi", Response: "hello"}}},
+	}
+	if err := fitPromptToWindow("gpt-4", "", &req); err != nil {
+		t.Fatalf("fitPromptToWindow: %v", err)
+	}
+	if len(req.History.Turns) != 0 {
+		t.Error("history kept while the document was cut")
+	}
+	if !strings.HasPrefix(req.DocumentContext, "Manual start.") || len(req.DocumentContext) >= len("Manual start. ")+7*20000 {
+		t.Error("document was not cut from its tail")
+	}
+}
+
+func TestFitPromptToWindowRejectsOversizeMessage(t *testing.T) {
+	req := chatRequest{Message: strings.Repeat("word ", 10000)}
+	if err := fitPromptToWindow("gpt-4", "", &req); !errors.Is(err, errPromptTooLarge) {
+		t.Errorf("error = %v, want errPromptTooLarge", err)
+	}
+	req = chatRequest{Message: strings.Repeat("word ", 10000)}
+	if err := fitPromptToWindow("gpt-4o", "", &req); err != nil {
+		t.Errorf("message rejected for a 128k window: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jevi-chat/config"
+	"jevi-chat/middleware"
+	"jevi-chat/models"
+)
+
+// Quota display defaults (overridable via QUOTA_* env vars)
+const (
+	defaultTokensPerMessage   = 500 // used until a project has enough history of its own
+	quotaAverageSampleSize    = 200 // most recent messages averaged
+	quotaAverageMinSample     = 20  // fewer messages than this and the default is used
+	quotaAverageTTL           = 10 * time.Minute
+	defaultQuotaPerIPMinute   = 30
+	quotaLowMessagesThreshold = 10
+)
+
+type cachedTokenAverage struct {
+	average int64
+	expires time.Time
+}
+
+var (
+	tokenAverageMu    sync.Mutex
+	tokenAverageCache = make(map[string]cachedTokenAverage)
+)
+
+// GetProjectQuota - GET /api/projects/:projectId/quota
+// Remaining quota in messages rather than tokens, for the widget's "X messages left".
+// Same per-IP throttling and neutral unavailable answer as GetSubscriptionStatus.
+func GetProjectQuota(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	clientIP := c.ClientIP()
+	if !middleware.AllowRequest("quota:ip:"+clientIP, envInt("QUOTA_PER_IP_MINUTE", defaultQuotaPerIPMinute), time.Minute) {
+		log.Printf("🚫 Quota lookups throttled for %s", clientIP)
+		c.Header("Retry-After", "60")
+		respondError(c, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many requests. Please try again later.")
+		return
+	}
+
+	project, err := getProjectByID(c.Request.Context(), projectID)
+	if err != nil || !project.IsActive || project.Status != "active" || project.TrialExpired() || time.Now().After(project.ExpiryDate) {
+		c.JSON(http.StatusOK, gin.H{
+			"project_id":    projectID,
+			"status":        "unavailable",
+			"messages_left": 0,
+		})
+		return
+	}
+
+	tokensPerMessage := projectTokensPerMessage(project.ProjectID)
+	messagesLeft := messagesFromTokens(project.MonthlyTokenLimit-project.TotalTokensUsed, tokensPerMessage)
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id":         projectID,
+		"status":             "active",
+		"messages_left":      messagesLeft,
+		"approximate":        true,
+		"tokens_per_message": tokensPerMessage,
+		"low_quota":          messagesLeft <= quotaLowMessagesThreshold,
+		"resets_at":          quotaResetDate(project),
+	})
+}
+
+// messagesFromTokens - Whole messages the remaining tokens cover at tokensPerMessage each
+func messagesFromTokens(remainingTokens, tokensPerMessage int64) int64 {
+	if remainingTokens <= 0 || tokensPerMessage <= 0 {
+		return 0
+	}
+	return remainingTokens / tokensPerMessage
+}
+
+// quotaResetDate - Tokens are only replenished on renewal, so the quota resets when the
+// current period (the trial, for trial projects) ends
+func quotaResetDate(project *models.Project) time.Time {
+	if project.IsTrial() && project.TrialEndsAt != nil {
+		return *project.TrialEndsAt
+	}
+	return project.ExpiryDate
+}
+
+// projectTokensPerMessage - The project's observed average tokens per message (cached), or
+// QUOTA_TOKENS_PER_MESSAGE while it has too little history to be representative
+func projectTokensPerMessage(projectID string) int64 {
+	fallback := int64(envInt("QUOTA_TOKENS_PER_MESSAGE", defaultTokensPerMessage))
+	now := time.Now()
+
+	tokenAverageMu.Lock()
+	entry, ok := tokenAverageCache[projectID]
+	tokenAverageMu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.average
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	average, err := observedTokensPerMessage(ctx, projectID)
+	if err != nil {
+		log.Printf("⚠️ Failed to compute token average for %s: %v", projectID, err)
+		return fallback
+	}
+	if average <= 0 {
+		average = fallback
+	}
+
+	tokenAverageMu.Lock()
+	tokenAverageCache[projectID] = cachedTokenAverage{average: average, expires: now.Add(quotaAverageTTL)}
+	tokenAverageMu.Unlock()
+
+	return average
+}
+
+// observedTokensPerMessage - Average tokens_used over the project's recent messages;
+// 0 when there are fewer than quotaAverageMinSample of them
+func observedTokensPerMessage(ctx context.Context, projectID string) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"project_id": projectID, "tokens_used": bson.M{"$gt": 0}}}},
+		{{Key: "$sort", Value: bson.M{"created_at": -1}}},
+		{{Key: "$limit", Value: quotaAverageSampleSize}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"average": bson.M{"$avg": "$tokens_used"},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := config.GetChatMessagesCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+
+	var results []struct {
+		Average float64 `bson:"average"`
+		Count   int     `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 || results[0].Count < quotaAverageMinSample {
+		return 0, nil
+	}
+	return int64(results[0].Average + 0.5), nil
+}
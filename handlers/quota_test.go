@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/middleware"
+	"jevi-chat/models"
+)
+
+// resetTokenAverages - Drop cached per-project token averages before and after a test
+func resetTokenAverages(t *testing.T) {
+	t.Helper()
+	clear := func() {
+		tokenAverageMu.Lock()
+		tokenAverageCache = make(map[string]cachedTokenAverage)
+		tokenAverageMu.Unlock()
+	}
+	clear()
+	t.Cleanup(clear)
+}
+
+func TestMessagesFromTokens(t *testing.T) {
+	tests := []struct {
+		remaining, perMessage, want int64
+	}{
+		{10000, 500, 20},
+		{10499, 500, 20},
+		{499, 500, 0},
+		{0, 500, 0},
+		{-200, 500, 0},
+		{10000, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := messagesFromTokens(tt.remaining, tt.perMessage); got != tt.want {
+			t.Errorf("messagesFromTokens(%d, %d) = %d, want %d", tt.remaining, tt.perMessage, got, tt.want)
+		}
+	}
+}
+
+func TestQuotaResetDate(t *testing.T) {
+	expiry := time.Now().AddDate(1, 0, 0)
+	trialEnd := time.Now().AddDate(0, 0, 14)
+
+	tests := []struct {
+		name    string
+		project *models.Project
+		want    time.Time
+	}{
+		{"paid", &models.Project{ExpiryDate: expiry}, expiry},
+		{"trial", &models.Project{ExpiryDate: expiry, Plan: models.PlanTrial, TrialEndsAt: &trialEnd}, trialEnd},
+		{"trial without an end date", &models.Project{ExpiryDate: expiry, Plan: models.PlanTrial}, expiry},
+	}
+	for _, tt := range tests {
+		if got := quotaResetDate(tt.project); !got.Equal(tt.want) {
+			t.Errorf("%s: quotaResetDate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjectTokensPerMessageUsesCache(t *testing.T) {
+	resetTokenAverages(t)
+	tokenAverageCache["proj_1"] = cachedTokenAverage{average: 321, expires: time.Now().Add(time.Minute)}
+
+	// A fresh entry is served without touching the database
+	if got := projectTokensPerMessage("proj_1"); got != 321 {
+		t.Errorf("projectTokensPerMessage = %d, want the cached 321", got)
+	}
+}
+
+func TestGetProjectQuotaThrottlesPerIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("QUOTA_PER_IP_MINUTE", "1")
+
+	// Use up the only lookup allowed for this address this minute
+	clientIP := fmt.Sprintf("192.0.2.%d", time.Now().UnixNano()%250+1)
+	middleware.AllowRequest("quota:ip:"+clientIP, 1, time.Minute)
+
+	r := gin.New()
+	r.GET("/projects/:projectId/quota", GetProjectQuota)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/projects/proj_1/quota", nil)
+	req.RemoteAddr = clientIP + ":40000"
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("got %d (Retry-After %q), want 429 with Retry-After 60", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestGetProjectQuota(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+	resetTokenAverages(t)
+	t.Setenv("QUOTA_PER_IP_MINUTE", "1000")
+	t.Setenv("QUOTA_TOKENS_PER_MESSAGE", "400")
+
+	expiry := time.Now().AddDate(0, 1, 0)
+	project := func(projectID, status string, used int64) models.Project {
+		return models.Project{
+			ProjectID: projectID, Status: status, IsActive: true, ExpiryDate: expiry,
+			MonthlyTokenLimit: 10000, TotalTokensUsed: used,
+		}
+	}
+	for _, p := range []models.Project{
+		project("busy", "active", 2000),
+		project("new", "active", 6000),
+		project("nearly_out", "active", 9500),
+		project("suspended", "suspended", 0),
+	} {
+		if _, err := config.GetProjectsCollection().InsertOne(ctx, p); err != nil {
+			t.Fatalf("insert %s: %v", p.ProjectID, err)
+		}
+	}
+	// busy has enough history for its own average; new does not
+	var messages []interface{}
+	for i := 0; i < quotaAverageMinSample; i++ {
+		messages = append(messages, bson.M{"project_id": "busy", "tokens_used": 200, "created_at": time.Now()})
+	}
+	messages = append(messages, bson.M{"project_id": "new", "tokens_used": 50, "created_at": time.Now()})
+	if _, err := config.GetChatMessagesCollection().InsertMany(ctx, messages); err != nil {
+		t.Fatalf("insert messages: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/projects/:projectId/quota", GetProjectQuota)
+
+	tests := []struct {
+		projectID        string
+		wantStatus       string
+		wantMessagesLeft float64
+		wantPerMessage   float64
+		wantLow          bool
+	}{
+		{"busy", "active", 40, 200, false},
+		{"new", "active", 10, 400, true},
+		{"nearly_out", "active", 1, 400, true},
+		{"suspended", "unavailable", 0, 0, false},
+		{"missing", "unavailable", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.projectID, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/"+tt.projectID+"/quota", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			var resp map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+
+			if resp["status"] != tt.wantStatus || resp["messages_left"] != tt.wantMessagesLeft {
+				t.Errorf("status %v, messages_left %v; want %s, %v", resp["status"], resp["messages_left"], tt.wantStatus, tt.wantMessagesLeft)
+			}
+			if tt.wantStatus != "active" {
+				if _, leaked := resp["tokens_per_message"]; leaked {
+					t.Errorf("unavailable answer carries usage details: %v", resp)
+				}
+				return
+			}
+			if resp["tokens_per_message"] != tt.wantPerMessage || resp["low_quota"] != tt.wantLow {
+				t.Errorf("tokens_per_message %v, low_quota %v; want %v, %v", resp["tokens_per_message"], resp["low_quota"], tt.wantPerMessage, tt.wantLow)
+			}
+		})
+	}
+}
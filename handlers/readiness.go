@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+// Readiness - GET /readyz
+// 200 once startup verification has completed and the database still answers a ping,
+// 503 otherwise, so load balancers only route traffic to instances that can serve it.
+func Readiness(c *gin.Context) {
+	ready, reason := config.IsReady()
+	if ready {
+		if err := pingDatabase(c.Request.Context()); err != nil {
+			ready, reason = false, "database unreachable"
+		}
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"ready":  false,
+			"reason": reason,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ready": true})
+}
+
+// pingDatabase - Connectivity check for readiness probes, bounded by the route's deadline
+func pingDatabase(ctx context.Context) error {
+	if config.Client == nil {
+		return errors.New("database not initialized")
+	}
+	return config.Client.Ping(ctx, nil)
+}
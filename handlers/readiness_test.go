@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+func TestReadinessBeforeStartupCompletes(t *testing.T) {
+	if ready, _ := config.IsReady(); ready {
+		t.Skip("startup verification already completed in this process")
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/readyz", Readiness)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body struct {
+		Ready  bool   `json:"ready"`
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusServiceUnavailable || body.Ready || body.Reason == "" {
+		t.Errorf("got %d %s, want 503 with a reason", w.Code, w.Body)
+	}
+}
+
+func TestPingDatabaseWithoutClient(t *testing.T) {
+	previous := config.Client
+	config.Client = nil
+	t.Cleanup(func() { config.Client = previous })
+
+	if err := pingDatabase(context.Background()); err == nil {
+		t.Error("pingDatabase should fail before the client is initialized")
+	}
+}
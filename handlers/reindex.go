@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// defaultReindexEmbeddingsPerMinute - OpenAI embedding calls per minute during a reindex
+const defaultReindexEmbeddingsPerMinute = 60
+
+// StartReindex - POST /api/admin/maintenance/reindex
+// Body (optional): {"statuses": ["active", "expired"]}; defaults to active projects only.
+func StartReindex(c *gin.Context) {
+	var body struct {
+		Statuses []string `json:"statuses"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+			return
+		}
+	}
+	if len(body.Statuses) == 0 {
+		body.Statuses = []string{"active"}
+	}
+	for _, status := range body.Statuses {
+		if !isValidStatus(status) {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, fmt.Sprintf("Invalid project status %q", status))
+			return
+		}
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	jobs := config.GetMaintenanceJobsCollection()
+
+	// Only one reindex at a time, so concurrent runs can't double the OpenAI load
+	running, err := jobs.CountDocuments(ctx, bson.M{
+		"type":   models.MaintenanceJobReindex,
+		"status": bson.M{"$in": []string{models.MaintenanceJobQueued, models.MaintenanceJobRunning}},
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to check running jobs")
+		return
+	}
+	if running > 0 {
+		respondError(c, http.StatusConflict, ErrCodeInvalidState, "A reindex job is already in progress")
+		return
+	}
+
+	cursor, err := config.GetProjectsCollection().Find(ctx,
+		bson.M{"status": bson.M{"$in": body.Statuses}, "is_active": true},
+		options.Find().SetProjection(bson.M{"project_id": 1}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load projects")
+		return
+	}
+	var projects []struct {
+		ProjectID string `bson:"project_id"`
+	}
+	if err := cursor.All(ctx, &projects); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load projects")
+		return
+	}
+
+	projectIDs := make([]string, 0, len(projects))
+	for _, p := range projects {
+		projectIDs = append(projectIDs, p.ProjectID)
+	}
+
+	job := models.MaintenanceJob{
+		ID:              primitive.NewObjectID(),
+		Type:            models.MaintenanceJobReindex,
+		Status:          models.MaintenanceJobQueued,
+		ProjectStatuses: body.Statuses,
+		ProjectIDs:      projectIDs,
+		TotalProjects:   len(projectIDs),
+		CreatedBy:       c.GetString("user_email"),
+		CreatedAt:       time.Now(),
+	}
+	if _, err := jobs.InsertOne(ctx, job); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create reindex job")
+		return
+	}
+
+	log.Printf("🔁 Reindex job %s queued for %d projects (statuses %v)", job.ID.Hex(), job.TotalProjects, job.ProjectStatuses)
+	go runReindexJob(job)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Reindex job queued",
+		"job":     job,
+	})
+}
+
+// GetReindexJob - GET /api/admin/maintenance/reindex/:jobId ("latest" for the most recent job)
+func GetReindexJob(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	filter := bson.M{"type": models.MaintenanceJobReindex}
+	opts := options.FindOne().SetSort(bson.M{"created_at": -1})
+	if jobID := c.Param("jobId"); jobID != "latest" {
+		objectID, err := primitive.ObjectIDFromHex(jobID)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid job ID")
+			return
+		}
+		filter["_id"] = objectID
+	}
+
+	var job models.MaintenanceJob
+	if err := config.GetMaintenanceJobsCollection().FindOne(ctx, filter, opts).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			respondError(c, http.StatusNotFound, ErrCodeJobNotFound, "Reindex job not found")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load reindex job")
+		return
+	}
+
+	percent := 100.0
+	if job.TotalProjects > 0 {
+		percent = float64(job.ProcessedProjects) / float64(job.TotalProjects) * 100
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":              job,
+		"progress_percent": percent,
+	})
+}
+
+// runReindexJob - Re-embed every document of the job's projects, throttled to
+// REINDEX_EMBEDDINGS_PER_MINUTE OpenAI calls so a full run can't exhaust the API quota.
+func runReindexJob(job models.MaintenanceJob) {
+	jobs := config.GetMaintenanceJobsCollection()
+	started := time.Now()
+	updateJob(jobs, job.ID, bson.M{"$set": bson.M{"status": models.MaintenanceJobRunning, "started_at": started}})
+
+	throttle := time.NewTicker(time.Minute / time.Duration(envInt("REINDEX_EMBEDDINGS_PER_MINUTE", defaultReindexEmbeddingsPerMinute)))
+	defer throttle.Stop()
+
+	for _, projectID := range job.ProjectIDs {
+		documents, err := reindexProject(projectID, throttle.C)
+
+		update := bson.M{"$inc": bson.M{"processed_projects": 1, "documents_reindexed": documents}}
+		if err != nil {
+			log.Printf("❌ Reindex of project %s failed: %v", projectID, err)
+			update["$inc"].(bson.M)["failed_projects"] = 1
+			update["$push"] = bson.M{"errors": bson.M{
+				"$each":  []string{fmt.Sprintf("%s: %v", projectID, err)},
+				"$slice": -models.MaxMaintenanceJobErrors,
+			}}
+		}
+		updateJob(jobs, job.ID, update)
+	}
+
+	completed := time.Now()
+	updateJob(jobs, job.ID, bson.M{"$set": bson.M{"status": models.MaintenanceJobCompleted, "completed_at": completed}})
+	log.Printf("✅ Reindex job %s completed: %d projects in %v", job.ID.Hex(), len(job.ProjectIDs), completed.Sub(started).Round(time.Second))
+}
+
+// reembedProject - Re-embed one project's documents in the background after its embedding model
+// changed, throttled like a reindex job
+func reembedProject(projectID string) {
+	throttle := time.NewTicker(time.Minute / time.Duration(envInt("REINDEX_EMBEDDINGS_PER_MINUTE", defaultReindexEmbeddingsPerMinute)))
+	defer throttle.Stop()
+
+	documents, err := reindexProject(projectID, throttle.C)
+	if err != nil {
+		log.Printf("❌ Re-embedding project %s with its new model failed: %v", projectID, err)
+		return
+	}
+	log.Printf("🔁 Re-embedded %d documents of project %s with its new model", documents, projectID)
+}
+
+// reindexProject - Regenerate the document and chunk embeddings for each document with content;
+// returns how many succeeded
+func reindexProject(projectID string, throttle <-chan time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	project, err := findProjectByAnyID(ctx, projectID)
+	cancel()
+	if err != nil {
+		return 0, fmt.Errorf("project not found")
+	}
+
+	reindexed, failed := 0, 0
+	for _, file := range project.PDFFiles {
+		if file.Content == "" {
+			continue
+		}
+
+		<-throttle
+		embeddings, err := generateOpenAIEmbeddings(file.Content, project.GetEmbeddingModel())
+		if err != nil {
+			log.Printf("⚠️ Reindex: embeddings for %s/%s failed: %v", projectID, file.FileName, err)
+			failed++
+			continue
+		}
+
+		<-throttle
+		if _, err := indexDocumentChunks(project, file); err != nil {
+			log.Printf("⚠️ Reindex: chunk vectors for %s/%s failed: %v", projectID, file.FileName, err)
+			failed++
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = config.GetProjectsCollection().UpdateOne(ctx,
+			bson.M{"_id": project.ID, "pdf_files.id": file.ID},
+			bson.M{"$set": bson.M{
+				"pdf_files.$.embeddings":      embeddings,
+				"pdf_files.$.embedding_model": project.GetEmbeddingModel(),
+				"pdf_files.$.processed_at":    time.Now(),
+			}},
+		)
+		cancel()
+		if err != nil {
+			failed++
+			continue
+		}
+		reindexed++
+	}
+
+	if failed > 0 {
+		return reindexed, fmt.Errorf("%d of %d documents failed", failed, failed+reindexed)
+	}
+	return reindexed, nil
+}
+
+// updateJob - Best-effort progress write; a failed write only loses progress detail
+func updateJob(jobs *mongo.Collection, jobID primitive.ObjectID, update bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := jobs.UpdateOne(ctx, bson.M{"_id": jobID}, update); err != nil {
+		log.Printf("⚠️ Failed to update maintenance job %s: %v", jobID.Hex(), err)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestReindexEndpointsValidateInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/maintenance/reindex", StartReindex)
+	r.GET("/maintenance/reindex/:jobId", GetReindexJob)
+
+	// Rejected before any job or project is looked up, so no database is needed
+	tests := []struct {
+		name, method, path, body string
+	}{
+		{"malformed body", http.MethodPost, "/maintenance/reindex", `{"statuses":`},
+		{"unknown status", http.MethodPost, "/maintenance/reindex", `{"statuses":["active","archived"]}`},
+		{"malformed job id", http.MethodGet, "/maintenance/reindex/not-a-job", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrCodeValidationFailed) {
+				t.Errorf("got %d %s, want 400 %s", w.Code, w.Body, ErrCodeValidationFailed)
+			}
+		})
+	}
+}
+
+func TestStartReindexRefusesConcurrentJobs(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	running := models.MaintenanceJob{Type: models.MaintenanceJobReindex, Status: models.MaintenanceJobRunning, CreatedAt: time.Now()}
+	if _, err := config.GetMaintenanceJobsCollection().InsertOne(ctx, running); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/maintenance/reindex", StartReindex)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/maintenance/reindex", nil))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), ErrCodeInvalidState) {
+		t.Errorf("got %d %s, want 409 %s", w.Code, w.Body, ErrCodeInvalidState)
+	}
+}
+
+func TestRunReindexJobTracksProgress(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("REINDEX_EMBEDDINGS_PER_MINUTE", "60000")
+
+	// Documents without content are skipped, so no embedding calls are made
+	project := models.Project{ProjectID: "proj_1", PDFFiles: []models.PDFFile{{ID: "doc_1", FileName: "empty.pdf"}}}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	job := models.MaintenanceJob{
+		ID:            primitive.NewObjectID(),
+		Type:          models.MaintenanceJobReindex,
+		Status:        models.MaintenanceJobQueued,
+		ProjectIDs:    []string{"proj_1", "proj_gone"},
+		TotalProjects: 2,
+		CreatedAt:     time.Now(),
+	}
+	if _, err := config.GetMaintenanceJobsCollection().InsertOne(ctx, job); err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+
+	runReindexJob(job)
+
+	var stored models.MaintenanceJob
+	if err := config.GetMaintenanceJobsCollection().FindOne(ctx, bson.M{"_id": job.ID}).Decode(&stored); err != nil {
+		t.Fatalf("find job: %v", err)
+	}
+	if stored.Status != models.MaintenanceJobCompleted || stored.StartedAt == nil || stored.CompletedAt == nil {
+		t.Errorf("job = %+v, want completed with start and end times", stored)
+	}
+	if stored.ProcessedProjects != 2 || stored.FailedProjects != 1 || stored.DocumentsReindexed != 0 {
+		t.Errorf("progress = %d processed, %d failed, %d documents", stored.ProcessedProjects, stored.FailedProjects, stored.DocumentsReindexed)
+	}
+	if len(stored.Errors) != 1 || !strings.HasPrefix(stored.Errors[0], "proj_gone:") {
+		t.Errorf("errors = %v, want one for the missing project", stored.Errors)
+	}
+}
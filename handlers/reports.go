@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jevi-chat/config"
+)
+
+// topProjectMetrics - Ranking metric -> the usage total it sorts on
+var topProjectMetrics = map[string]string{
+	"tokens":   "tokens",
+	"cost":     "cost",
+	"messages": "messages",
+}
+
+// TopProjectRow - One entry of the top-projects report
+type TopProjectRow struct {
+	Rank      int     `json:"rank"`
+	ProjectID string  `json:"project_id" bson:"_id"`
+	Name      string  `json:"name" bson:"name"`
+	ClientID  string  `json:"client_id" bson:"client_id"`
+	Tokens    int64   `json:"tokens" bson:"tokens"`
+	Cost      float64 `json:"cost" bson:"cost"`
+	Messages  int64   `json:"messages" bson:"messages"`
+	Value     float64 `json:"value" bson:"-"` // The ranked metric
+}
+
+// GetTopProjects - GET /api/admin/reports/top-projects?metric=tokens|cost|messages&from=&to=&limit=10
+// Projects ranked by usage logged in [from, to); from and to take RFC 3339 timestamps or
+// YYYY-MM-DD dates and default to the last 30 days.
+func GetTopProjects(c *gin.Context) {
+	metric := c.DefaultQuery("metric", "tokens")
+	sortField, ok := topProjectMetrics[metric]
+	if !ok {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "metric must be tokens, cost or messages")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		limit = 10
+	}
+
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		if to, err = parseUsageTime(raw); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "to must be an RFC 3339 time or YYYY-MM-DD date")
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if raw := c.Query("from"); raw != "" {
+		if from, err = parseUsageTime(raw); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "from must be an RFC 3339 time or YYYY-MM-DD date")
+			return
+		}
+	}
+	if !from.Before(to) {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "from must be before to")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$project_id",
+			"tokens":   bson.M{"$sum": "$total_tokens"},
+			"cost":     bson.M{"$sum": "$cost"},
+			"messages": bson.M{"$sum": 1},
+		}}},
+		// Project ID breaks ties so the ranking is stable
+		{{Key: "$sort", Value: bson.D{{Key: sortField, Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "projects",
+			"localField":   "_id",
+			"foreignField": "project_id",
+			"as":           "project",
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"name":      bson.M{"$arrayElemAt": bson.A{"$project.name", 0}},
+			"client_id": bson.M{"$arrayElemAt": bson.A{"$project.client_id", 0}},
+		}}},
+		{{Key: "$unset", Value: "project"}},
+	}
+
+	cursor, err := config.GetOpenAIUsageLogsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to build the report")
+		return
+	}
+	rows := []TopProjectRow{}
+	if err := cursor.All(ctx, &rows); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to read the report")
+		return
+	}
+
+	for i := range rows {
+		rows[i].Rank = i + 1
+		switch metric {
+		case "tokens":
+			rows[i].Value = float64(rows[i].Tokens)
+		case "cost":
+			rows[i].Value = rows[i].Cost
+		case "messages":
+			rows[i].Value = float64(rows[i].Messages)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metric":   metric,
+		"from":     from,
+		"to":       to,
+		"projects": rows,
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func getTopProjects(query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/reports/top-projects", GetTopProjects)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/top-projects"+query, nil))
+	return w
+}
+
+func TestGetTopProjectsValidatesInput(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"?metric=sessions", "metric must be tokens, cost or messages"},
+		{"?to=tomorrow", "to must be an RFC 3339 time or YYYY-MM-DD date"},
+		{"?from=2025-13-01", "from must be an RFC 3339 time or YYYY-MM-DD date"},
+		{"?from=2025-03-02&to=2025-03-01", "from must be before to"},
+		{"?from=2025-03-01&to=2025-03-01", "from must be before to"},
+	}
+	for _, tt := range tests {
+		// Refused before the report is built, so no database is needed
+		w := getTopProjects(tt.query)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: got %d %s, want 400 %q", tt.query, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+func TestGetTopProjects(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	config.GetProjectsCollection().InsertMany(ctx, []interface{}{
+		models.Project{ProjectID: "big", Name: "Big", ClientID: "client_big"},
+		models.Project{ProjectID: "chatty", Name: "Chatty", ClientID: "client_chatty"},
+		models.Project{ProjectID: "pricey", Name: "Pricey"},
+	})
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	usage := func(projectID string, tokens int, cost float64, at time.Time) interface{} {
+		return bson.M{"project_id": projectID, "total_tokens": tokens, "cost": cost, "timestamp": at}
+	}
+	config.GetOpenAIUsageLogsCollection().InsertMany(ctx, []interface{}{
+		usage("big", 5000, 0.01, day),
+		usage("chatty", 100, 0.001, day),
+		usage("chatty", 100, 0.001, day),
+		usage("chatty", 100, 0.001, day),
+		usage("pricey", 1000, 0.5, day),
+		usage("deleted", 200, 0.002, day),             // no project document left
+		usage("big", 90000, 9, day.AddDate(0, -2, 0)), // outside the window
+	})
+
+	type report struct {
+		Metric   string          `json:"metric"`
+		Projects []TopProjectRow `json:"projects"`
+	}
+	get := func(query string) report {
+		t.Helper()
+		w := getTopProjects("?from=2025-03-01&to=2025-03-31" + query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+		}
+		var r report
+		json.Unmarshal(w.Body.Bytes(), &r)
+		return r
+	}
+	ranking := func(r report) string {
+		var ids []string
+		for _, row := range r.Projects {
+			ids = append(ids, row.ProjectID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	tokens := get("")
+	if tokens.Metric != "tokens" || ranking(tokens) != "big,pricey,chatty,deleted" {
+		t.Errorf("by tokens = %s, want big,pricey,chatty,deleted", ranking(tokens))
+	}
+	if top := tokens.Projects[0]; top.Rank != 1 || top.Name != "Big" || top.ClientID != "client_big" || top.Tokens != 5000 || top.Value != 5000 {
+		t.Errorf("top row = %+v", top)
+	}
+	if last := tokens.Projects[3]; last.Rank != 4 || last.Name != "" {
+		t.Errorf("deleted project row = %+v, want it ranked without a name", last)
+	}
+
+	if r := get("&metric=cost&limit=1"); ranking(r) != "pricey" || r.Projects[0].Value != 0.5 {
+		t.Errorf("by cost, top 1 = %s %+v", ranking(r), r.Projects)
+	}
+	if r := get("&metric=messages"); !strings.HasPrefix(ranking(r), "chatty,") || r.Projects[0].Value != 3 {
+		t.Errorf("by messages = %s, want chatty first with 3", ranking(r))
+	}
+
+	// A window with no usage is an empty list, not null
+	if w := getTopProjects("?from=2020-01-01&to=2020-01-02"); !strings.Contains(w.Body.String(), `"projects":[]`) {
+		t.Errorf("empty window = %s", w.Body)
+	}
+}
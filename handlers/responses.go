@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"jevi-chat/models"
+	"jevi-chat/utils"
+)
+
+// Response DTOs for the main resources. Handlers build these instead of returning models or
+// ad-hoc maps, so every endpoint serializes a resource the same way and secrets (API keys,
+// document contents, embeddings) never leave the server by accident.
+
+// ProjectResponse - A project as returned by the admin and user project endpoints
+type ProjectResponse struct {
+	ID              string     `json:"id"`
+	ProjectID       string     `json:"project_id"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	Status          string     `json:"status"`
+	Plan            string     `json:"plan"`
+	TrialEndsAt     *time.Time `json:"trial_ends_at,omitempty"`
+	ClientID        string     `json:"client_id"`
+	OwnerID         string     `json:"owner_id,omitempty"`
+	CreatedByUserID string     `json:"created_by_user_id,omitempty"`
+
+	StartDate         time.Time `json:"start_date"`
+	ExpiryDate        time.Time `json:"expiry_date"`
+	DaysUntilExpiry   float64   `json:"days_until_expiry"`
+	TotalTokensUsed   int64     `json:"total_tokens_used"`
+	MonthlyTokenLimit int64     `json:"monthly_token_limit"`
+	UsagePercentage   float64   `json:"usage_percentage"`
+	EstimatedCost     float64   `json:"estimated_cost"`
+	OveragePolicy     string    `json:"overage_policy"`
+	OverageTokens     int64     `json:"overage_tokens"`
+
+	AIProvider       string                     `json:"ai_provider"`
+	FallbackProvider string                     `json:"fallback_provider,omitempty"`
+	OpenAIModel      string                     `json:"openai_model"`
+	EmbeddingModel   string                     `json:"embedding_model"`
+	WidgetSettings   models.ProjectWidgetConfig `json:"widget_settings"`
+	EmbedCode        string                     `json:"embed_code,omitempty"`
+	PDFFilesCount    int                        `json:"pdf_files_count"`
+	Documents        []DocumentResponse         `json:"documents,omitempty"`
+
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DocumentResponse - An uploaded document without its extracted text or embeddings
+type DocumentResponse struct {
+	ID            string    `json:"id"`
+	FileName      string    `json:"file_name"`
+	FileSize      int64     `json:"file_size"`
+	Status        string    `json:"status"`
+	Weight        float64   `json:"weight"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	HasContent    bool      `json:"has_content"`
+	ContentSize   int       `json:"content_size"`
+	ChunksIndexed int       `json:"chunks_indexed"`
+}
+
+// UsageResponse - Token usage report for a project
+type UsageResponse struct {
+	ProjectID       string                   `json:"project_id"`
+	Status          string                   `json:"status"`
+	TokensUsed      int64                    `json:"tokens_used"`
+	TokenLimit      int64                    `json:"token_limit"`
+	RemainingTokens int64                    `json:"remaining_tokens"`
+	UsagePercentage float64                  `json:"usage_percentage"`
+	DaysUntilExpiry float64                  `json:"days_until_expiry"`
+	EstimatedCost   float64                  `json:"estimated_cost"`
+	DailyAverage    int64                    `json:"daily_average"`
+	OveragePolicy   string                   `json:"overage_policy"`
+	Overage         map[string]interface{}   `json:"overage"`
+	UsageHistory    []map[string]interface{} `json:"usage_history"`
+	Warnings        []string                 `json:"warnings,omitempty"`
+	ChatStatistics  map[string]interface{}   `json:"chat_statistics,omitempty"`
+	LastUpdated     time.Time                `json:"last_updated"`
+}
+
+// SubscriptionResponse - Public subscription status used by the widget
+type SubscriptionResponse struct {
+	ProjectID         string    `json:"project_id"`
+	Status            string    `json:"status"`
+	StartDate         time.Time `json:"start_date"`
+	ExpiryDate        time.Time `json:"expiry_date"`
+	TotalTokensUsed   int64     `json:"total_tokens_used"`
+	MonthlyTokenLimit int64     `json:"monthly_token_limit"`
+	RemainingTokens   int64     `json:"remaining_tokens"`
+	UsagePercentage   float64   `json:"usage_percentage"`
+	DaysUntilExpiry   float64   `json:"days_until_expiry"`
+	IsActive          bool      `json:"is_active"`
+	NeedsRenewal      bool      `json:"needs_renewal"`
+	CaptchaRequired   bool      `json:"captcha_required"`
+}
+
+// NewProjectResponse - Summary of a project; documents are listed only when withDocuments is set
+func NewProjectResponse(project *models.Project, withDocuments bool) ProjectResponse {
+	response := ProjectResponse{
+		ID:                project.ID.Hex(),
+		ProjectID:         project.ProjectID,
+		Name:              project.Name,
+		Description:       project.Description,
+		Status:            project.Status,
+		Plan:              project.Plan,
+		TrialEndsAt:       project.TrialEndsAt,
+		ClientID:          project.ClientID,
+		OwnerID:           project.OwnerID,
+		StartDate:         project.StartDate,
+		ExpiryDate:        project.ExpiryDate,
+		DaysUntilExpiry:   project.GetDaysUntilExpiry(),
+		TotalTokensUsed:   project.TotalTokensUsed,
+		MonthlyTokenLimit: project.MonthlyTokenLimit,
+		UsagePercentage:   project.GetUsagePercentage(),
+		EstimatedCost:     calculateEstimatedCost(project.TotalTokensUsed),
+		OveragePolicy:     project.GetOveragePolicy(),
+		OverageTokens:     project.OverageTokens,
+		AIProvider:        project.AIProvider,
+		FallbackProvider:  project.FallbackProvider,
+		OpenAIModel:       project.OpenAIModel,
+		EmbeddingModel:    project.GetEmbeddingModel(),
+		WidgetSettings:    project.WidgetSettings,
+		EmbedCode:         project.EmbedCode,
+		PDFFilesCount:     len(project.PDFFiles),
+		IsActive:          project.IsActive,
+		CreatedAt:         project.CreatedAt,
+		UpdatedAt:         project.UpdatedAt,
+	}
+	if response.Plan == "" {
+		response.Plan = models.PlanPaid
+	}
+	if !project.CreatedByUserID.IsZero() {
+		response.CreatedByUserID = project.CreatedByUserID.Hex()
+	}
+	if withDocuments {
+		response.Documents = make([]DocumentResponse, 0, len(project.PDFFiles))
+		for _, file := range project.PDFFiles {
+			response.Documents = append(response.Documents, NewDocumentResponse(file))
+		}
+	}
+	return response
+}
+
+// NewProjectResponses - NewProjectResponse for a list, without documents
+func NewProjectResponses(projects []models.Project) []ProjectResponse {
+	responses := make([]ProjectResponse, 0, len(projects))
+	for i := range projects {
+		responses = append(responses, NewProjectResponse(&projects[i], false))
+	}
+	return responses
+}
+
+// NewDocumentResponse - Metadata of one uploaded document
+func NewDocumentResponse(file models.PDFFile) DocumentResponse {
+	return DocumentResponse{
+		ID:            file.ID,
+		FileName:      file.FileName,
+		FileSize:      file.FileSize,
+		Status:        file.Status,
+		Weight:        file.EffectiveWeight(),
+		UploadedAt:    file.UploadedAt,
+		HasContent:    strings.TrimSpace(file.Content) != "",
+		ContentSize:   len(file.Content),
+		ChunksIndexed: file.ChunksIndexed,
+	}
+}
+
+// NewUsageResponse - Usage report shared by the admin and subscription usage endpoints
+func NewUsageResponse(project *models.Project, usageHistory []map[string]interface{}, overage map[string]interface{}) UsageResponse {
+	dailyAverage := int64(0)
+	if daysSinceStart := time.Since(project.StartDate).Hours() / 24; daysSinceStart > 0 {
+		dailyAverage = int64(float64(project.TotalTokensUsed) / daysSinceStart)
+	}
+	if usageHistory == nil {
+		usageHistory = []map[string]interface{}{}
+	}
+
+	return UsageResponse{
+		ProjectID:       project.ProjectID,
+		Status:          project.Status,
+		TokensUsed:      project.TotalTokensUsed,
+		TokenLimit:      project.MonthlyTokenLimit,
+		RemainingTokens: max(0, project.MonthlyTokenLimit-project.TotalTokensUsed),
+		UsagePercentage: project.GetUsagePercentage(),
+		DaysUntilExpiry: project.GetDaysUntilExpiry(),
+		EstimatedCost:   calculateEstimatedCost(project.TotalTokensUsed),
+		DailyAverage:    dailyAverage,
+		OveragePolicy:   project.GetOveragePolicy(),
+		Overage:         overage,
+		UsageHistory:    usageHistory,
+		LastUpdated:     project.UpdatedAt,
+	}
+}
+
+// NewSubscriptionResponse - Subscription status of an active project
+func NewSubscriptionResponse(project *models.Project) SubscriptionResponse {
+	daysUntilExpiry := project.GetDaysUntilExpiry()
+	return SubscriptionResponse{
+		ProjectID:         project.ProjectID,
+		Status:            project.Status,
+		StartDate:         project.StartDate,
+		ExpiryDate:        project.ExpiryDate,
+		TotalTokensUsed:   project.TotalTokensUsed,
+		MonthlyTokenLimit: project.MonthlyTokenLimit,
+		RemainingTokens:   max(0, project.MonthlyTokenLimit-project.TotalTokensUsed),
+		UsagePercentage:   project.GetUsagePercentage(),
+		DaysUntilExpiry:   daysUntilExpiry,
+		IsActive:          project.Status == "active" && daysUntilExpiry > 0,
+		NeedsRenewal:      daysUntilExpiry <= 3,
+		CaptchaRequired:   project.WidgetSettings.RequireCaptcha && utils.CaptchaConfigured(),
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/models"
+)
+
+func TestNewProjectResponseOmitsSecrets(t *testing.T) {
+	project := &models.Project{
+		ID:                primitive.NewObjectID(),
+		ProjectID:         "proj_dto",
+		Name:              "DTO",
+		Status:            "active",
+		ExpiryDate:        time.Now().AddDate(0, 0, 10),
+		TotalTokensUsed:   250,
+		MonthlyTokenLimit: 1000,
+		OpenAIAPIKey:      "sk-secret-key",
+		PDFContent:        "confidential handbook text",
+		PDFFiles: []models.PDFFile{
+			{ID: "doc_1", FileName: "handbook.pdf", Content: "confidential page text", ChunksIndexed: 3},
+			{ID: "doc_2", FileName: "empty.pdf", Content: "   "},
+		},
+	}
+
+	response := NewProjectResponse(project, true)
+	if response.Plan != models.PlanPaid {
+		t.Errorf("Plan = %q, want %q for projects without one", response.Plan, models.PlanPaid)
+	}
+	if response.CreatedByUserID != "" {
+		t.Errorf("CreatedByUserID = %q, want empty when unset", response.CreatedByUserID)
+	}
+	if response.UsagePercentage != 25 || response.PDFFilesCount != 2 {
+		t.Errorf("usage %.0f%%, %d files; want 25%% and 2", response.UsagePercentage, response.PDFFilesCount)
+	}
+	if len(response.Documents) != 2 || !response.Documents[0].HasContent || response.Documents[1].HasContent {
+		t.Errorf("documents = %+v, want content flags per file", response.Documents)
+	}
+
+	body, _ := json.Marshal(response)
+	for _, secret := range []string{"sk-secret-key", "confidential"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("response leaks %q: %s", secret, body)
+		}
+	}
+
+	if listed := NewProjectResponses([]models.Project{*project}); len(listed) != 1 || listed[0].Documents != nil {
+		t.Errorf("list responses = %+v, want one project without documents", listed)
+	}
+	if empty := NewProjectResponses(nil); empty == nil {
+		t.Error("NewProjectResponses(nil) should serialize as [] rather than null")
+	}
+}
+
+func TestNewUsageResponse(t *testing.T) {
+	project := &models.Project{
+		ProjectID:         "proj_usage",
+		StartDate:         time.Now().AddDate(0, 0, -10),
+		ExpiryDate:        time.Now().AddDate(0, 0, 20),
+		TotalTokensUsed:   1500,
+		MonthlyTokenLimit: 1000,
+	}
+
+	usage := NewUsageResponse(project, nil, nil)
+	if usage.RemainingTokens != 0 {
+		t.Errorf("RemainingTokens = %d, want 0 once over the limit", usage.RemainingTokens)
+	}
+	if usage.DailyAverage < 149 || usage.DailyAverage > 150 {
+		t.Errorf("DailyAverage = %d, want about 150", usage.DailyAverage)
+	}
+	if usage.UsageHistory == nil {
+		t.Error("UsageHistory should default to an empty list")
+	}
+
+	project.StartDate = time.Now().Add(time.Hour)
+	if usage := NewUsageResponse(project, nil, nil); usage.DailyAverage != 0 {
+		t.Errorf("DailyAverage = %d for a future start date, want 0", usage.DailyAverage)
+	}
+}
+
+func TestNewSubscriptionResponse(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		expiry        time.Time
+		wantActive    bool
+		wantRenewal   bool
+		wantRemaining int64
+	}{
+		{"active with time left", "active", time.Now().AddDate(0, 0, 30), true, false, 600},
+		{"active near expiry", "active", time.Now().AddDate(0, 0, 2), true, true, 600},
+		{"suspended", "suspended", time.Now().AddDate(0, 0, 30), false, false, 600},
+		{"past expiry", "active", time.Now().Add(-time.Hour), false, true, 600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := NewSubscriptionResponse(&models.Project{
+				ProjectID: "proj_sub", Status: tt.status, ExpiryDate: tt.expiry,
+				TotalTokensUsed: 400, MonthlyTokenLimit: 1000,
+			})
+			if response.IsActive != tt.wantActive || response.NeedsRenewal != tt.wantRenewal || response.RemainingTokens != tt.wantRemaining {
+				t.Errorf("active=%v renewal=%v remaining=%d, want %v %v %d",
+					response.IsActive, response.NeedsRenewal, response.RemainingTokens, tt.wantActive, tt.wantRenewal, tt.wantRemaining)
+			}
+		})
+	}
+}
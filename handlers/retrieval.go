@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+	"jevi-chat/utils"
+)
+
+// Retrieval defaults (overridable via RETRIEVAL_* env vars)
+const (
+	defaultChunkSize        = 1200 // characters per chunk
+	defaultRetrievalChunks  = 5
+	defaultRetrievalMaxChar = 6000 // total context budget sent to the model
+)
+
+// documentChunk - A slice of one PDF with its retrieval score
+type documentChunk struct {
+	FileID   string
+	FileName string
+	Text     string
+	Score    float64
+}
+
+// Retrieval methods reported by retrieveChunks
+const (
+	retrievalVector      = "vector"
+	retrievalLexical     = "lexical"
+	retrievalFullContent = "full_content"
+)
+
+// selectDocumentContext - Pick the chunks most relevant to query across all of a project's PDFs.
+// Uses the vector store when the project's chunks are indexed, otherwise lexical overlap. Either
+// way each chunk's score is multiplied by its document's weight, so a higher-weighted document
+// wins near-ties. Falls back to the combined PDFContent when no per-file text or no chunk matches.
+func selectDocumentContext(project *models.Project, query string) string {
+	maxChunks := envInt("RETRIEVAL_MAX_CHUNKS", defaultRetrievalChunks)
+	maxChars := envInt("RETRIEVAL_MAX_CHARS", defaultRetrievalMaxChar)
+
+	chunks, method := retrieveChunks(project, query, maxChunks)
+	if method == retrievalFullContent {
+		return project.PDFContent
+	}
+	return formatChunks(rankChunks(chunks, maxChunks, maxChars))
+}
+
+// retrieveChunks - Weighted candidate chunks for query (unranked) and the method that found them;
+// retrievalFullContent with no chunks when nothing matched
+func retrieveChunks(project *models.Project, query string, maxChunks int) ([]documentChunk, string) {
+	if chunks := vectorDocumentChunks(project, query, maxChunks); len(chunks) > 0 {
+		return chunks, retrievalVector
+	}
+
+	terms := queryTerms(query)
+
+	var chunks []documentChunk
+	for _, file := range project.PDFFiles {
+		if strings.TrimSpace(file.Content) == "" {
+			continue
+		}
+		weight := file.EffectiveWeight()
+		for _, text := range splitIntoChunks(file.Content, envInt("RETRIEVAL_CHUNK_SIZE", defaultChunkSize)) {
+			score := scoreChunk(text, terms)
+			if score == 0 {
+				continue
+			}
+			chunks = append(chunks, documentChunk{
+				FileID:   file.ID,
+				FileName: file.FileName,
+				Text:     text,
+				Score:    score * weight,
+			})
+		}
+	}
+
+	if len(chunks) == 0 {
+		return nil, retrievalFullContent
+	}
+	return chunks, retrievalLexical
+}
+
+// vectorDocumentChunks - Nearest indexed chunks for query, weighted by document; nil when the
+// project has no indexed chunks or the query can't be embedded (callers fall back to lexical)
+func vectorDocumentChunks(project *models.Project, query string, maxChunks int) []documentChunk {
+	weights := make(map[string]float64)
+	for _, file := range project.PDFFiles {
+		if file.ChunksIndexed > 0 {
+			weights[file.ID] = file.EffectiveWeight()
+		}
+	}
+	if len(weights) == 0 || !openAIConfigured() {
+		return nil
+	}
+
+	model := project.GetEmbeddingModel()
+	vector, err := generateOpenAIEmbeddings(query, model)
+	if err != nil {
+		log.Printf("⚠️ Query embedding failed for %s, using lexical retrieval: %v", project.ProjectID, err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Over-fetch so document weights can reorder near-ties
+	matches, err := utils.GetVectorStore().Query(ctx, project.ProjectID, model, vector, maxChunks*3)
+	if err != nil {
+		log.Printf("⚠️ Vector query failed for %s, using lexical retrieval: %v", project.ProjectID, err)
+		return nil
+	}
+
+	chunks := make([]documentChunk, 0, len(matches))
+	for _, match := range matches {
+		weight, ok := weights[match.DocumentID]
+		if !ok {
+			continue // stale chunks of a removed document
+		}
+		chunks = append(chunks, documentChunk{
+			FileID:   match.DocumentID,
+			FileName: match.FileName,
+			Text:     match.Text,
+			Score:    match.Score * weight,
+		})
+	}
+	return chunks
+}
+
+// indexDocumentChunks - Chunk, embed and store one document in the vector store, replacing its
+// previous chunks, and record the chunk count on the project
+func indexDocumentChunks(project *models.Project, file models.PDFFile) (int, error) {
+	texts := splitIntoChunks(file.Content, envInt("RETRIEVAL_CHUNK_SIZE", defaultChunkSize))
+	if len(texts) == 0 {
+		return 0, nil
+	}
+
+	model := project.GetEmbeddingModel()
+	vectors, err := generateBatchEmbeddings(texts, model)
+	if err != nil {
+		return 0, err
+	}
+
+	records := make([]utils.VectorRecord, len(texts))
+	for i, text := range texts {
+		records[i] = utils.VectorRecord{
+			ID:         fmt.Sprintf("%s:%s:%d", project.ProjectID, file.ID, i),
+			ProjectID:  project.ProjectID,
+			DocumentID: file.ID,
+			FileName:   file.FileName,
+			ChunkIndex: i,
+			Text:       text,
+			Vector:     vectors[i],
+			Model:      model,
+			Dimensions: len(vectors[i]),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	store := utils.GetVectorStore()
+	if err := store.DeleteDocument(ctx, project.ProjectID, file.ID); err != nil {
+		return 0, err
+	}
+	if err := store.Upsert(ctx, records); err != nil {
+		return 0, err
+	}
+
+	_, err = config.GetProjectsCollection().UpdateOne(ctx,
+		bson.M{"_id": project.ID, "pdf_files.id": file.ID},
+		bson.M{"$set": bson.M{"pdf_files.$.chunks_indexed": len(records)}},
+	)
+	return len(records), err
+}
+
+// indexProjectDocuments - Index every document with content (used after upload; errors are logged)
+func indexProjectDocuments(project *models.Project) {
+	if !openAIConfigured() {
+		return // CreateProject already logged that embeddings are skipped
+	}
+	for _, file := range project.PDFFiles {
+		if strings.TrimSpace(file.Content) == "" {
+			continue
+		}
+		if count, err := indexDocumentChunks(project, file); err != nil {
+			log.Printf("⚠️ Failed to index chunks for %s/%s: %v", project.ProjectID, file.FileName, err)
+		} else {
+			log.Printf("🧩 Indexed %d chunks for %s/%s", count, project.ProjectID, file.FileName)
+		}
+	}
+}
+
+// rankChunks - Highest score first, bounded by count and total characters
+func rankChunks(chunks []documentChunk, maxChunks, maxChars int) []documentChunk {
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].Score > chunks[j].Score })
+
+	selected := make([]documentChunk, 0, maxChunks)
+	total := 0
+	for _, chunk := range chunks {
+		if len(selected) >= maxChunks {
+			break
+		}
+		if total+len(chunk.Text) > maxChars && len(selected) > 0 {
+			continue
+		}
+		selected = append(selected, chunk)
+		total += len(chunk.Text)
+	}
+	return selected
+}
+
+// formatChunks - Render chunks with [Source: file] markers so the model can cite them
+func formatChunks(chunks []documentChunk) string {
+	var b strings.Builder
+	for i, chunk := range chunks {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[Source: %s]\n%s", chunk.FileName, chunk.Text)
+	}
+	return b.String()
+}
+
+// splitIntoChunks - Paragraph-aligned chunks of roughly size characters
+func splitIntoChunks(content string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			chunks = append(chunks, text)
+		}
+		current.Reset()
+	}
+
+	for _, paragraph := range strings.Split(content, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if current.Len() > 0 && current.Len()+len(paragraph) > size {
+			flush()
+		}
+		// Very long paragraphs are cut at size so one chunk can't swallow the budget
+		for len(paragraph) > size {
+			current.WriteString(paragraph[:size])
+			flush()
+			paragraph = paragraph[size:]
+		}
+		current.WriteString(paragraph)
+		current.WriteString("\n")
+	}
+	flush()
+
+	return chunks
+}
+
+// queryTerms - Lowercased words of 3+ letters from the visitor's question
+func queryTerms(query string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) >= 3 {
+			terms[word] = true
+		}
+	}
+	return terms
+}
+
+// scoreChunk - Fraction of query terms present in the chunk
+func scoreChunk(text string, terms map[string]bool) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	lower := strings.ToLower(text)
+	matched := 0
+	for term := range terms {
+		if strings.Contains(lower, term) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(terms))
+}
+
+// GetProjectDocuments - GET /api/admin/projects/:id/documents
+func GetProjectDocuments(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	documents := make([]DocumentResponse, 0, len(project.PDFFiles))
+	for _, file := range project.PDFFiles {
+		documents = append(documents, NewDocumentResponse(file))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"documents":  documents,
+	})
+}
+
+// UpdateDocumentWeight - PATCH /api/admin/projects/:id/documents/:docId
+func UpdateDocumentWeight(c *gin.Context) {
+	var body struct {
+		Weight float64 `json:"weight" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "weight is required")
+		return
+	}
+	if body.Weight < models.MinPDFWeight || body.Weight > models.MaxPDFWeight {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+			fmt.Sprintf("weight must be between %.1f and %.1f", models.MinPDFWeight, models.MaxPDFWeight))
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	docID := c.Param("docId")
+	result, err := config.GetProjectsCollection().UpdateOne(ctx,
+		bson.M{"_id": project.ID, "pdf_files.id": docID},
+		bson.M{"$set": bson.M{
+			"pdf_files.$.weight": body.Weight,
+			"updated_at":         time.Now(),
+		}},
+	)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update document")
+		return
+	}
+	if result.MatchedCount == 0 {
+		respondError(c, http.StatusNotFound, ErrCodeDocumentNotFound, "Document not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Document weight updated",
+		"id":      docID,
+		"weight":  body.Weight,
+	})
+}
+
+// GetEmbeddingStatus - GET /api/admin/projects/:id/embeddings/status
+// Each document has a summary vector (over its first maxEmbeddingInputChars characters) and, once
+// indexed, per-chunk vectors in the vector store; chunks not yet indexed are reported as missing.
+func GetEmbeddingStatus(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, embeddingStatus(project, envInt("RETRIEVAL_CHUNK_SIZE", defaultChunkSize)))
+}
+
+// embeddingStatus - Per-document vector coverage and a project-wide summary for GetEmbeddingStatus
+func embeddingStatus(project *models.Project, chunkSize int) gin.H {
+	projectModel := project.GetEmbeddingModel()
+	dimensions := make(map[int]int)
+	documents := make([]gin.H, 0, len(project.PDFFiles))
+	withEmbeddings, totalChunks, missingChunks, staleModel := 0, 0, 0, 0
+
+	for _, file := range project.PDFFiles {
+		chunkCount := len(splitIntoChunks(file.Content, chunkSize))
+		dimension := len(file.Embeddings)
+		totalChunks += chunkCount
+
+		model := file.EmbeddingModel
+		if model == "" && dimension > 0 {
+			// Documents embedded before the model was recorded used the same default
+			model = models.DefaultEmbeddingModel
+		}
+
+		if dimension > 0 {
+			withEmbeddings++
+			dimensions[dimension]++
+			if model != projectModel {
+				staleModel++
+			}
+		}
+		missing := max(chunkCount-file.ChunksIndexed, 0)
+		missingChunks += missing
+
+		documents = append(documents, gin.H{
+			"id":                     file.ID,
+			"file_name":              file.FileName,
+			"status":                 file.Status,
+			"chunk_count":            chunkCount,
+			"has_embedding":          dimension > 0,
+			"embedding_dimension":    dimension,
+			"embedding_model":        model,
+			"chunks_missing_vectors": missing,
+			"chunks_indexed":         file.ChunksIndexed,
+			"embedded_chars":         min(len(file.Content), maxEmbeddingInputChars),
+			"truncated":              len(file.Content) > maxEmbeddingInputChars,
+		})
+	}
+
+	return gin.H{
+		"project_id":      project.ProjectID,
+		"embedding_model": projectModel,
+		"documents":       documents,
+		"summary": gin.H{
+			"documents_total":              len(project.PDFFiles),
+			"documents_with_embeddings":    withEmbeddings,
+			"documents_missing_embeddings": len(project.PDFFiles) - withEmbeddings,
+			"chunks_total":                 totalChunks,
+			"chunks_missing_vectors":       missingChunks,
+			"dimensions":                   dimensions,
+			"consistent_dimensions":        len(dimensions) <= 1,
+			"documents_other_model":        staleModel, // embedded before the project's model changed; reindex to fix
+		},
+	}
+}
+
+// maxPreviewChunks - Upper bound on k for retrieval previews
+const maxPreviewChunks = 50
+
+// PreviewRetrieval - POST /api/admin/projects/:id/retrieve/preview
+// Runs the chat retrieval for query without calling the completion model and returns the top k
+// chunks by score. in_context marks the chunks a chat would actually send under the current
+// RETRIEVAL_MAX_CHUNKS / RETRIEVAL_MAX_CHARS settings.
+func PreviewRetrieval(c *gin.Context) {
+	var body struct {
+		Query string `json:"query" binding:"required"`
+		K     int    `json:"k"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Query) == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "query is required")
+		return
+	}
+	maxChunks := envInt("RETRIEVAL_MAX_CHUNKS", defaultRetrievalChunks)
+	k := body.K
+	if k <= 0 {
+		k = maxChunks
+	}
+	if k > maxPreviewChunks {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+			fmt.Sprintf("k must be between 1 and %d", maxPreviewChunks))
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	candidates, method := retrieveChunks(project, body.Query, max(k, maxChunks))
+
+	// Chunks the chat would send, ranked on a copy since rankChunks sorts in place
+	inContext := make(map[documentChunk]bool)
+	for _, chunk := range rankChunks(append([]documentChunk(nil), candidates...), maxChunks, envInt("RETRIEVAL_MAX_CHARS", defaultRetrievalMaxChar)) {
+		inContext[chunk] = true
+	}
+
+	top := rankChunks(candidates, k, math.MaxInt)
+	chunks := make([]gin.H, 0, len(top))
+	for i, chunk := range top {
+		chunks = append(chunks, gin.H{
+			"rank":        i + 1,
+			"document_id": chunk.FileID,
+			"file_name":   chunk.FileName,
+			"score":       chunk.Score,
+			"text":        chunk.Text,
+			"in_context":  inContext[chunk],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"query":      body.Query,
+		"k":          k,
+		"method":     method,
+		"chunks":     chunks,
+	})
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestSplitIntoChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		size    int
+		want    []string
+	}{
+		{"empty", "", 10, nil},
+		{"blank lines only", "\n \n\t\n", 10, nil},
+		{"paragraphs merged up to size", "aaa\nbbb\n\nccc", 8, []string{"aaa\nbbb", "ccc"}},
+		{"each paragraph fits alone", "aaaa\nbbbb", 5, []string{"aaaa", "bbbb"}},
+		{"long paragraph is cut", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"surrounding whitespace trimmed", "  one  \n  two  ", 100, []string{"one\ntwo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitIntoChunks(tt.content, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitIntoChunks(%q, %d) = %q, want %q", tt.content, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankChunks(t *testing.T) {
+	chunk := func(name string, score float64) documentChunk {
+		return documentChunk{FileName: name, Text: strings.Repeat(name, 10), Score: score}
+	}
+
+	tests := []struct {
+		name      string
+		maxChunks int
+		maxChars  int
+		want      []string
+	}{
+		{"best first, bounded by count", 2, 100, []string{"b", "c"}},
+		{"all when within limits", 5, 100, []string{"b", "c", "a"}},
+		{"character budget skips what does not fit", 3, 25, []string{"b", "c"}},
+		{"top chunk kept even over budget", 3, 5, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := []documentChunk{chunk("a", 0.2), chunk("b", 0.9), chunk("c", 0.5)}
+			var got []string
+			for _, c := range rankChunks(chunks, tt.maxChunks, tt.maxChars) {
+				got = append(got, c.FileName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankChunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddingStatus(t *testing.T) {
+	vector := func(n int) []float64 { return make([]float64, n) }
+	project := &models.Project{
+		ProjectID:      "proj_1",
+		EmbeddingModel: models.EmbeddingModel3Small,
+		PDFFiles: []models.PDFFile{
+			// Three 10-byte paragraphs at chunk size 12: three chunks, two indexed
+			{FileName: "current.pdf", Content: "aaaaaaaaaa\nbbbbbbbbbb\ncccccccccc", Embeddings: vector(1536),
+				EmbeddingModel: models.EmbeddingModel3Small, ChunksIndexed: 2},
+			// Embedded before the model was recorded, so with the default model
+			{FileName: "legacy.pdf", Content: "dddddddddd", Embeddings: vector(1536)},
+			{FileName: "large.pdf", Content: strings.Repeat("e", maxEmbeddingInputChars+1), Embeddings: vector(3072),
+				EmbeddingModel: models.EmbeddingModel3Large, ChunksIndexed: 1000},
+			{FileName: "pending.pdf", Content: "ffffffffff"},
+		},
+	}
+
+	status := embeddingStatus(project, 12)
+	summary := status["summary"].(gin.H)
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{"documents_total", 4},
+		{"documents_with_embeddings", 3},
+		{"documents_missing_embeddings", 1},
+		{"chunks_missing_vectors", 1 + 1 + 0 + 1},
+		{"consistent_dimensions", false},
+		{"documents_other_model", 2},
+	}
+	for _, tt := range tests {
+		if summary[tt.key] != tt.want {
+			t.Errorf("summary[%s] = %v, want %v", tt.key, summary[tt.key], tt.want)
+		}
+	}
+	if dims := summary["dimensions"].(map[int]int); dims[1536] != 2 || dims[3072] != 1 {
+		t.Errorf("dimensions = %v", dims)
+	}
+	if status["embedding_model"] != models.EmbeddingModel3Small {
+		t.Errorf("embedding_model = %v", status["embedding_model"])
+	}
+
+	documents := status["documents"].([]gin.H)
+	if documents[0]["chunk_count"] != 3 || documents[0]["chunks_missing_vectors"] != 1 {
+		t.Errorf("current.pdf = %v", documents[0])
+	}
+	if documents[1]["embedding_model"] != models.DefaultEmbeddingModel {
+		t.Errorf("legacy.pdf model = %v, want the default", documents[1]["embedding_model"])
+	}
+	if documents[2]["truncated"] != true || documents[2]["embedded_chars"] != maxEmbeddingInputChars || documents[2]["chunks_missing_vectors"] != 0 {
+		t.Errorf("large.pdf = %v", documents[2])
+	}
+	if documents[3]["has_embedding"] != false || documents[3]["embedding_model"] != "" {
+		t.Errorf("pending.pdf = %v", documents[3])
+	}
+}
+
+func TestEmbeddingStatusWithoutDocuments(t *testing.T) {
+	summary := embeddingStatus(&models.Project{ProjectID: "proj_1"}, defaultChunkSize)["summary"].(gin.H)
+	if summary["documents_total"] != 0 || summary["consistent_dimensions"] != true {
+		t.Errorf("summary = %v", summary)
+	}
+}
+
+func TestRetrieveChunksLexical(t *testing.T) {
+	project := &models.Project{
+		PDFContent: "full text of every document",
+		PDFFiles: []models.PDFFile{
+			{ID: "doc_hours", FileName: "hours.pdf", Content: "Opening hours are nine to five."},
+			{ID: "doc_faq", FileName: "faq.pdf", Content: "Shipping takes three days. Opening soon in Pune.", Weight: 2},
+			{ID: "doc_blank", FileName: "blank.pdf", Content: "   "},
+		},
+	}
+
+	chunks, method := retrieveChunks(project, "opening hours", 5)
+	if method != retrievalLexical || len(chunks) != 2 {
+		t.Fatalf("retrieveChunks = %d chunks via %s, want 2 lexical", len(chunks), method)
+	}
+	scores := map[string]float64{}
+	for _, chunk := range chunks {
+		scores[chunk.FileID] = chunk.Score
+	}
+	if scores["doc_hours"] != 1 || scores["doc_faq"] != 1 {
+		t.Errorf("scores = %v, want the term fraction times the document weight", scores)
+	}
+
+	if chunks, method := retrieveChunks(project, "refund policy", 5); method != retrievalFullContent || chunks != nil {
+		t.Errorf("no match = %v via %s, want the full-content fallback", chunks, method)
+	}
+}
+
+func postRetrievalPreview(projectID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/projects/:id/retrieve/preview", PreviewRetrieval)
+
+	req := httptest.NewRequest(http.MethodPost, "/projects/"+projectID+"/retrieve/preview", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPreviewRetrievalValidatesInput(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no body", "", "query is required"},
+		{"blank query", `{"query":"   "}`, "query is required"},
+		{"k too large", `{"query":"hours","k":51}`, "k must be between 1 and 50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postRetrievalPreview("proj_1", tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("got %d %s, want 400 %q", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewRetrieval(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("RETRIEVAL_MAX_CHUNKS", "1")
+
+	project := models.Project{
+		ProjectID: "proj_preview",
+		PDFFiles: []models.PDFFile{
+			{ID: "doc_hours", FileName: "hours.pdf", Content: "Opening hours are nine to five."},
+			{ID: "doc_faq", FileName: "faq.pdf", Content: "Opening soon in Pune.", Weight: 0.5},
+		},
+	}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	w := postRetrievalPreview("proj_preview", `{"query":"opening hours","k":3}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Method string `json:"method"`
+		K      int    `json:"k"`
+		Chunks []struct {
+			Rank       int     `json:"rank"`
+			DocumentID string  `json:"document_id"`
+			Score      float64 `json:"score"`
+			InContext  bool    `json:"in_context"`
+		} `json:"chunks"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Method != retrievalLexical || resp.K != 3 || len(resp.Chunks) != 2 {
+		t.Fatalf("response = %+v, want 2 lexical chunks", resp)
+	}
+	if first := resp.Chunks[0]; first.Rank != 1 || first.DocumentID != "doc_hours" || !first.InContext {
+		t.Errorf("first chunk = %+v, want the full match, sent to the model", first)
+	}
+	if second := resp.Chunks[1]; second.DocumentID != "doc_faq" || second.Score != 0.25 || second.InContext {
+		t.Errorf("second chunk = %+v, want the weighted partial match, outside RETRIEVAL_MAX_CHUNKS", second)
+	}
+
+	if w := postRetrievalPreview("proj_missing", `{"query":"hours"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+	"jevi-chat/utils"
+)
+
+// scheduleStatus - Business-hours state for the widget config
+func scheduleStatus(schedule *models.WidgetSchedule, now time.Time) gin.H {
+	if schedule == nil || !schedule.Enabled {
+		return gin.H{"enabled": false, "open": true}
+	}
+	status := gin.H{
+		"enabled":         true,
+		"open":            schedule.IsOpen(now),
+		"timezone":        schedule.Timezone,
+		"hours":           schedule.Hours,
+		"offline_message": schedule.GetOfflineMessage(),
+		"capture_leads":   schedule.CaptureLeads,
+	}
+	if next := schedule.NextOpen(now); !next.IsZero() {
+		status["next_open"] = next
+	}
+	return status
+}
+
+// offlineReply - Answer a chat message received outside business hours with the offline message,
+// without calling the AI. The message is saved and the session kept, so the conversation shows up
+// in history and a lead can be attached to it.
+func offlineReply(c *gin.Context, project *models.Project, sessionID, userID, message string, page pageContext, askForLead bool) {
+	schedule := project.WidgetSettings.Schedule
+	response := schedule.GetOfflineMessage()
+	if project.RedactPII {
+		message = utils.RedactPII(message)
+	}
+
+	chatMessage := models.ChatMessage{
+		ID:        primitive.NewObjectID(),
+		ProjectID: project.ProjectID,
+		SessionID: sessionID,
+		UserID:    userID,
+		VisitorID: c.GetString("visitor_id"),
+		Message:   message,
+		Response:  response,
+		Offline:   true,
+		PageURL:   page.URL,
+		PageTitle: page.Title,
+		IPAddress: storedIP(c.ClientIP()),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: time.Now(),
+	}
+	if _, err := config.GetChatMessagesCollection().InsertOne(context.Background(), chatMessage); err != nil {
+		log.Printf("⚠️ Failed to save offline message for session %s: %v", sessionID, err)
+	}
+	updateWidgetSession(project.ProjectID, sessionID, userID, c.GetString("visitor_id"), c.ClientIP(), c.Request.UserAgent(), c.Request.Referer(), 0)
+
+	body := gin.H{
+		"status":            "offline",
+		"offline":           true,
+		"session_id":        sessionID,
+		"visitor_token":     c.GetString("visitor_token"),
+		"response":          response,
+		"tokens_used":       0,
+		"collect_user_info": askForLead && schedule.CaptureLeads,
+	}
+	if next := schedule.NextOpen(time.Now()); !next.IsZero() {
+		body["next_open"] = next
+	}
+	setRequestUsageHeaders(c, 0)
+	c.JSON(http.StatusOK, body)
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// resolveSessionID - Reuse the widget's session id when it is valid for this project, otherwise mint a new one.
+// session_id is globally unique, so an id belonging to another project (or, for visitors, to
+// another visitor) must never be reused.
+// The bool reports whether the session already exists.
+func resolveSessionID(ctx context.Context, scope tenantScope, projectID, requested string) (string, bool) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" || len(requested) > 128 {
+		return generateSessionID(), false
+	}
+
+	var existing models.WidgetSession
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		return config.GetWidgetSessionsCollection().FindOne(ctx,
+			bson.M{"session_id": requested},
+			options.FindOne().SetProjection(bson.M{"project_id": 1, "visitor_id": 1}),
+		).Decode(&existing)
+	})
+	if err != nil {
+		return requested, false
+	}
+	if existing.ProjectID != projectID {
+		log.Printf("⚠️ Session %s belongs to another project, issuing a new one", requested)
+		return generateSessionID(), false
+	}
+	// Continuing someone else's session would expose their conversation history
+	if !scope.canAccess(existing.ProjectID, existing.VisitorID) {
+		log.Printf("⚠️ Session %s belongs to another visitor, issuing a new one", requested)
+		return generateSessionID(), false
+	}
+
+	return requested, true
+}
+
+// CloseWidgetSession - End a widget session when the visitor closes the chat
+func CloseWidgetSession(c *gin.Context) {
+	projectID := c.Param("projectId")
+	sessionID := c.Param("sessionId")
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	collection := config.GetWidgetSessionsCollection()
+	filter, err := requestScope(c).projectData(projectID, bson.M{"session_id": sessionID})
+	if err != nil {
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
+		return
+	}
+
+	var session models.WidgetSession
+	err = config.RetryRead(ctx, func(ctx context.Context) error {
+		return collection.FindOne(ctx, filter).Decode(&session)
+	})
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeSessionNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load session")
+		return
+	}
+
+	// Closing twice is harmless: keep the original end time and reason
+	if !session.IsActive && !session.EndedAt.IsZero() {
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": sessionID,
+			"ended_at":   session.EndedAt,
+			"end_reason": session.EndReason,
+			"duration":   session.Duration,
+		})
+		return
+	}
+
+	now := time.Now()
+	duration := int64(now.Sub(session.StartedAt).Seconds())
+	if duration < 0 {
+		duration = 0
+	}
+
+	_, err = collection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{
+			"is_active":     false,
+			"ended_at":      now,
+			"end_reason":    "user_closed",
+			"duration":      duration,
+			"last_activity": now,
+		},
+	})
+	if err != nil {
+		log.Printf("❌ Failed to close widget session %s: %v", sessionID, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to close session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"ended_at":   now,
+		"end_reason": "user_closed",
+		"duration":   duration,
+	})
+}
+
+// GetSessionTranscript - Full conversation for one widget session with its metadata
+func GetSessionTranscript(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	var session models.WidgetSession
+	err = config.RetryRead(ctx, func(ctx context.Context) error {
+		return config.GetWidgetSessionsCollection().FindOne(ctx, bson.M{
+			"session_id": sessionID,
+			"project_id": project.ProjectID,
+		}).Decode(&session)
+	})
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeSessionNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load session")
+		return
+	}
+
+	messages := []models.ChatMessage{}
+	err = config.RetryRead(ctx, func(ctx context.Context) error {
+		cursor, err := config.GetChatMessagesCollection().Find(ctx,
+			bson.M{"project_id": project.ProjectID, "session_id": sessionID},
+			options.Find().SetSort(bson.M{"created_at": 1}))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		return cursor.All(ctx, &messages)
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load session messages")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session":  session,
+		"messages": messages,
+		"summary":  transcriptSummary(messages),
+	})
+}
+
+// transcriptSummary - Message count, tokens, average latency and feedback across a conversation
+func transcriptSummary(messages []models.ChatMessage) gin.H {
+	var totalTokens int
+	var totalProcessing int64
+	ratings := map[string]int{"positive": 0, "negative": 0, "unrated": 0}
+	for _, msg := range messages {
+		totalTokens += msg.TokensUsed
+		totalProcessing += msg.ProcessingTime
+		switch msg.Rating {
+		case "positive", "negative":
+			ratings[msg.Rating]++
+		default:
+			ratings["unrated"]++
+		}
+	}
+
+	avgProcessing := float64(0)
+	if len(messages) > 0 {
+		avgProcessing = float64(totalProcessing) / float64(len(messages))
+	}
+
+	return gin.H{
+		"message_count":          len(messages),
+		"total_tokens":           totalTokens,
+		"avg_processing_time_ms": avgProcessing,
+		"ratings":                ratings,
+	}
+}
+
+// ListProjectSessions - Paginated widget sessions for a project, newest first.
+// Filters: status=active|ended, from/to (YYYY-MM-DD, on started_at), user_id.
+func ListProjectSessions(c *gin.Context) {
+	page, limit := parsePagination(c, 50)
+
+	filter, err := sessionListFilter(c.Query("status"), c.Query("from"), c.Query("to"), c.Query("user_id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+	filter["project_id"] = project.ProjectID
+
+	collection := config.GetWidgetSessionsCollection()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count sessions")
+		return
+	}
+
+	cursor, err := collection.Find(ctx, filter,
+		options.Find().
+			SetSort(bson.M{"started_at": -1}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get sessions")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []models.WidgetSession
+	if err := cursor.All(ctx, &sessions); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to decode sessions")
+		return
+	}
+
+	summaries := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		// Active sessions have no stored duration yet; report time engaged so far
+		duration := session.Duration
+		if session.IsActive {
+			duration = int64(session.LastActivity.Sub(session.StartedAt).Seconds())
+		}
+
+		summaries = append(summaries, gin.H{
+			"session_id":    session.SessionID,
+			"user_id":       session.UserID,
+			"domain":        session.Domain,
+			"message_count": session.MessageCount,
+			"tokens_used":   session.TokensUsed,
+			"duration":      duration,
+			"started_at":    session.StartedAt,
+			"last_activity": session.LastActivity,
+			"ended_at":      session.EndedAt,
+			"is_active":     session.IsActive,
+			"end_reason":    session.EndReason,
+		})
+	}
+
+	totalPages := pageCount(totalCount, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": summaries,
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  totalPages,
+			"total_count":  totalCount,
+			"limit":        limit,
+		},
+	})
+}
+
+// sessionListFilter - The widget session list filter for status, a started_at date range and user
+func sessionListFilter(status, from, to, userID string) (bson.M, error) {
+	filter := bson.M{}
+
+	switch status {
+	case "":
+	case "active":
+		filter["is_active"] = true
+	case "ended":
+		filter["is_active"] = false
+	default:
+		return nil, fmt.Errorf("status must be 'active' or 'ended'")
+	}
+
+	startedAt := bson.M{}
+	if from != "" {
+		fromDate, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'from' date, expected YYYY-MM-DD")
+		}
+		startedAt["$gte"] = fromDate
+	}
+	if to != "" {
+		toDate, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'to' date, expected YYYY-MM-DD")
+		}
+		startedAt["$lt"] = toDate.AddDate(0, 0, 1) // inclusive of the whole day
+	}
+	if len(startedAt) > 0 {
+		filter["started_at"] = startedAt
+	}
+
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestCloseWidgetSession(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	started := time.Now().Add(-10 * time.Minute)
+	sessions := []interface{}{
+		bson.M{"session_id": "open", "project_id": "proj_1", "visitor_id": "visitor_1", "is_active": true, "started_at": started},
+		bson.M{"session_id": "theirs", "project_id": "proj_1", "visitor_id": "visitor_2", "is_active": true, "started_at": started},
+	}
+	if _, err := config.GetWidgetSessionsCollection().InsertMany(ctx, sessions); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/api/projects/:projectId/session/:sessionId/close", func(c *gin.Context) { c.Set("visitor_id", "visitor_1") }, CloseWidgetSession)
+	closeSession := func(sessionID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/projects/proj_1/session/"+sessionID+"/close", nil))
+		return w
+	}
+
+	tests := []struct {
+		name, sessionID string
+		wantStatus      int
+	}{
+		{"own session", "open", http.StatusOK},
+		{"closing again", "open", http.StatusOK},
+		{"another visitor's session", "theirs", http.StatusNotFound},
+		{"unknown session", "missing", http.StatusNotFound},
+	}
+	var firstEnd time.Time
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := closeSession(tt.sessionID)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp struct {
+				EndedAt   time.Time `json:"ended_at"`
+				EndReason string    `json:"end_reason"`
+				Duration  int64     `json:"duration"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.EndReason != "user_closed" || resp.Duration < 599 {
+				t.Errorf("response = %+v, want user_closed after about 600s", resp)
+			}
+			if firstEnd.IsZero() {
+				firstEnd = resp.EndedAt
+			} else if !resp.EndedAt.Equal(firstEnd) {
+				t.Errorf("closing again moved ended_at from %v to %v", firstEnd, resp.EndedAt)
+			}
+		})
+	}
+
+	var other models.WidgetSession
+	config.GetWidgetSessionsCollection().FindOne(ctx, bson.M{"session_id": "theirs"}).Decode(&other)
+	if !other.IsActive {
+		t.Error("another visitor's session was closed")
+	}
+}
+
+func TestUpdateWidgetSessionReopensClosedSession(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	ended := time.Now().Add(-time.Hour)
+	_, err := config.GetWidgetSessionsCollection().InsertOne(ctx, bson.M{
+		"session_id": "closed", "project_id": "proj_1", "is_active": false, "message_count": 2,
+		"ended_at": ended, "end_reason": "timeout", "duration": int64(300),
+	})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if created := updateWidgetSession("proj_1", "closed", "", "visitor_1", "203.0.113.1", "test", "", 10); created {
+		t.Error("reopening reported a new session")
+	}
+
+	var session bson.M
+	if err := config.GetWidgetSessionsCollection().FindOne(ctx, bson.M{"session_id": "closed"}).Decode(&session); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if session["is_active"] != true || session["message_count"] != int32(3) {
+		t.Errorf("session not reopened: %v", session)
+	}
+	for _, field := range []string{"ended_at", "end_reason", "duration"} {
+		if _, ok := session[field]; ok {
+			t.Errorf("reopened session still has %s = %v", field, session[field])
+		}
+	}
+
+	if created := updateWidgetSession("proj_1", "fresh", "", "visitor_1", "203.0.113.1", "test", "", 10); !created {
+		t.Error("first message did not report a new session")
+	}
+}
+
+func TestTranscriptSummary(t *testing.T) {
+	messages := []models.ChatMessage{
+		{TokensUsed: 30, ProcessingTime: 400, Rating: "positive"},
+		{TokensUsed: 50, ProcessingTime: 800, Rating: "negative"},
+		{TokensUsed: 20, ProcessingTime: 300, Rating: "neutral"},
+		{TokensUsed: 0, ProcessingTime: 100},
+	}
+	summary := transcriptSummary(messages)
+	if summary["message_count"] != 4 || summary["total_tokens"] != 100 || summary["avg_processing_time_ms"] != 400.0 {
+		t.Errorf("summary = %v", summary)
+	}
+	ratings := summary["ratings"].(map[string]int)
+	if ratings["positive"] != 1 || ratings["negative"] != 1 || ratings["unrated"] != 2 {
+		t.Errorf("ratings = %v, want neutral and missing ratings counted as unrated", ratings)
+	}
+
+	if empty := transcriptSummary(nil); empty["message_count"] != 0 || empty["avg_processing_time_ms"] != 0.0 {
+		t.Errorf("empty summary = %v", empty)
+	}
+}
+
+func TestGetSessionTranscript(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	project := models.Project{ProjectID: "proj_1", Name: "Shop"}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	sessions := []interface{}{
+		bson.M{"session_id": "sess_1", "project_id": "proj_1", "is_active": true},
+		bson.M{"session_id": "sess_other", "project_id": "proj_2", "is_active": true},
+	}
+	if _, err := config.GetWidgetSessionsCollection().InsertMany(ctx, sessions); err != nil {
+		t.Fatalf("insert sessions: %v", err)
+	}
+	now := time.Now()
+	messages := []interface{}{
+		bson.M{"project_id": "proj_1", "session_id": "sess_1", "message": "second", "created_at": now, "tokens_used": 20},
+		bson.M{"project_id": "proj_1", "session_id": "sess_1", "message": "first", "created_at": now.Add(-time.Minute), "tokens_used": 10},
+		bson.M{"project_id": "proj_1", "session_id": "sess_2", "message": "elsewhere", "created_at": now},
+	}
+	if _, err := config.GetChatMessagesCollection().InsertMany(ctx, messages); err != nil {
+		t.Fatalf("insert messages: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/projects/:id/sessions/:sessionId", GetSessionTranscript)
+
+	tests := []struct {
+		name, path string
+		wantStatus int
+		wantCode   string
+	}{
+		{"own session", "/projects/proj_1/sessions/sess_1", http.StatusOK, ""},
+		{"another project's session", "/projects/proj_1/sessions/sess_other", http.StatusNotFound, ErrCodeSessionNotFound},
+		{"unknown project", "/projects/proj_missing/sessions/sess_1", http.StatusNotFound, ErrCodeProjectNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			var resp struct {
+				Code     string               `json:"code"`
+				Messages []models.ChatMessage `json:"messages"`
+				Summary  struct {
+					MessageCount int `json:"message_count"`
+					TotalTokens  int `json:"total_tokens"`
+				} `json:"summary"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", resp.Code, tt.wantCode)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if len(resp.Messages) != 2 || resp.Messages[0].Message != "first" || resp.Messages[1].Message != "second" {
+				t.Errorf("messages = %+v, want the session's two messages oldest first", resp.Messages)
+			}
+			if resp.Summary.MessageCount != 2 || resp.Summary.TotalTokens != 30 {
+				t.Errorf("summary = %+v", resp.Summary)
+			}
+		})
+	}
+}
+
+func TestSessionListFilter(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	tests := []struct {
+		name                     string
+		status, from, to, userID string
+		want                     bson.M
+		wantErr                  bool
+	}{
+		{"no filters", "", "", "", "", bson.M{}, false},
+		{"active", "active", "", "", "", bson.M{"is_active": true}, false},
+		{"ended", "ended", "", "", "", bson.M{"is_active": false}, false},
+		{"unknown status", "open", "", "", "", nil, true},
+		{"date range covers the whole last day", "", "2025-03-01", "2025-03-31", "",
+			bson.M{"started_at": bson.M{"$gte": day("2025-03-01"), "$lt": day("2025-04-01")}}, false},
+		{"from only", "", "2025-03-01", "", "", bson.M{"started_at": bson.M{"$gte": day("2025-03-01")}}, false},
+		{"bad from", "", "03/01/2025", "", "", nil, true},
+		{"bad to", "", "", "yesterday", "", nil, true},
+		{"user", "ended", "", "", "user_1", bson.M{"is_active": false, "user_id": "user_1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sessionListFilter(tt.status, tt.from, tt.to, tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListProjectSessionsRejectsBadFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/projects/:id/sessions", ListProjectSessions)
+
+	// Rejected before the project is looked up, so no database is needed
+	for _, query := range []string{"status=closed", "from=2025-13-01", "to=soon"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_1/sessions?"+query, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrCodeValidationFailed) {
+			t.Errorf("%s: status = %d, body = %s; want 400 %s", query, w.Code, w.Body, ErrCodeValidationFailed)
+		}
+	}
+}
+
+func TestListProjectSessions(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, models.Project{ProjectID: "proj_1"}); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	at := func(s string) time.Time {
+		d, _ := time.Parse(time.RFC3339, s)
+		return d
+	}
+	sessions := []interface{}{
+		bson.M{"session_id": "march_active", "project_id": "proj_1", "user_id": "user_1", "is_active": true,
+			"started_at": at("2025-03-10T10:00:00Z"), "last_activity": at("2025-03-10T10:05:00Z")},
+		bson.M{"session_id": "march_ended", "project_id": "proj_1", "user_id": "user_2", "is_active": false,
+			"started_at": at("2025-03-31T23:00:00Z"), "duration": int64(90)},
+		bson.M{"session_id": "april", "project_id": "proj_1", "user_id": "user_1", "is_active": false,
+			"started_at": at("2025-04-02T09:00:00Z")},
+		bson.M{"session_id": "other_project", "project_id": "proj_2", "is_active": true,
+			"started_at": at("2025-03-10T10:00:00Z")},
+	}
+	if _, err := config.GetWidgetSessionsCollection().InsertMany(ctx, sessions); err != nil {
+		t.Fatalf("insert sessions: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/projects/:id/sessions", ListProjectSessions)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"april", "march_ended", "march_active"}},
+		{"status=active", []string{"march_active"}},
+		{"from=2025-03-01&to=2025-03-31", []string{"march_ended", "march_active"}},
+		{"user_id=user_1&status=ended", []string{"april"}},
+		{"limit=1&page=2", []string{"march_ended"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_1/sessions?"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Sessions []struct {
+					SessionID string `json:"session_id"`
+					Duration  int64  `json:"duration"`
+				} `json:"sessions"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			var got []string
+			for _, s := range resp.Sessions {
+				got = append(got, s.SessionID)
+				if s.SessionID == "march_active" && s.Duration != 300 {
+					t.Errorf("active session duration = %d, want the 300s engaged so far", s.Duration)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("sessions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSessionIDMintsMissingOrOversizedIDs(t *testing.T) {
+	scope := tenantScope{visitorID: "visitor_1"}
+	for _, requested := range []string{"", "   ", strings.Repeat("s", 129)} {
+		got, existing := resolveSessionID(context.Background(), scope, "proj_1", requested)
+		if existing || got == strings.TrimSpace(requested) || !strings.HasPrefix(got, "sess_") {
+			t.Errorf("resolveSessionID(%q) = %q, %v; want a fresh sess_ id", requested, got, existing)
+		}
+	}
+	if a, b := generateSessionID(), generateSessionID(); a == b {
+		t.Errorf("generateSessionID returned %q twice", a)
+	}
+}
+
+func TestResolveSessionID(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	sessions := []interface{}{
+		bson.M{"session_id": "mine", "project_id": "proj_1", "visitor_id": "visitor_1"},
+		bson.M{"session_id": "other_visitor", "project_id": "proj_1", "visitor_id": "visitor_2"},
+		bson.M{"session_id": "other_project", "project_id": "proj_2", "visitor_id": "visitor_1"},
+	}
+	if _, err := config.GetWidgetSessionsCollection().InsertMany(ctx, sessions); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	tests := []struct {
+		name, requested string
+		scope           tenantScope
+		wantReused      bool
+		wantExisting    bool
+	}{
+		{"own session", "mine", tenantScope{visitorID: "visitor_1"}, true, true},
+		{"padded own session", "  mine ", tenantScope{visitorID: "visitor_1"}, true, true},
+		{"new id chosen by the widget", "fresh", tenantScope{visitorID: "visitor_1"}, true, false},
+		{"another visitor's session", "other_visitor", tenantScope{visitorID: "visitor_1"}, false, false},
+		{"another project's session", "other_project", tenantScope{visitorID: "visitor_1"}, false, false},
+		{"admin continues any session of the project", "other_visitor", tenantScope{admin: true}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, existing := resolveSessionID(ctx, tt.scope, "proj_1", tt.requested)
+			if reused := got == strings.TrimSpace(tt.requested); reused != tt.wantReused {
+				t.Errorf("session id = %q, reused = %v, want %v", got, reused, tt.wantReused)
+			}
+			if existing != tt.wantExisting {
+				t.Errorf("existing = %v, want %v", existing, tt.wantExisting)
+			}
+		})
+	}
+}
@@ -12,51 +12,53 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 
 	"jevi-chat/config"
+	"jevi-chat/middleware"
 	"jevi-chat/models"
 )
 
-// GetSubscriptionStatus - Get comprehensive subscription status for a project
+// defaultSubscriptionStatusPerIPMinute - Public subscription status lookups allowed per IP per minute
+const defaultSubscriptionStatusPerIPMinute = 30
+
+// GetSubscriptionStatus - Get comprehensive subscription status for a project.
+// Public (used by the widget UI), so it is throttled per IP, and unknown, deleted and
+// non-active projects all get the same neutral "unavailable" answer so the endpoint
+// can't be used to probe which project IDs exist.
 func GetSubscriptionStatus(c *gin.Context) {
 	projectID := c.Param("projectId")
 
-	project, err := getProjectByID(projectID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	clientIP := c.ClientIP()
+	if !middleware.AllowRequest("subscription_status:ip:"+clientIP, envInt("SUBSCRIPTION_STATUS_PER_IP_MINUTE", defaultSubscriptionStatusPerIPMinute), time.Minute) {
+		log.Printf("🚫 Subscription status lookups throttled for %s", clientIP)
+		c.Header("Retry-After", "60")
+		respondError(c, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many requests. Please try again later.")
+		return
+	}
+
+	project, err := getProjectByID(c.Request.Context(), projectID)
+	if err != nil || !project.IsActive || project.Status != "active" || project.TrialExpired() {
+		respondSubscriptionUnavailable(c, projectID)
 		return
 	}
 
 	// Calculate real-time status
-	status := project.Status
-	if time.Now().After(project.ExpiryDate) && status != "expired" {
-		status = "expired"
+	if time.Now().After(project.ExpiryDate) {
 		// Auto-update status in database
 		updateProjectStatus(projectID, "expired")
+		respondSubscriptionUnavailable(c, projectID)
+		return
 	}
 
-	// Calculate usage metrics
-	usagePercent := float64(0)
-	if project.MonthlyTokenLimit > 0 {
-		usagePercent = float64(project.TotalTokensUsed) / float64(project.MonthlyTokenLimit) * 100
-	}
-
-	daysUntilExpiry := time.Until(project.ExpiryDate).Hours() / 24
-	remainingTokens := project.MonthlyTokenLimit - project.TotalTokensUsed
-	if remainingTokens < 0 {
-		remainingTokens = 0
-	}
+	c.JSON(http.StatusOK, NewSubscriptionResponse(project))
+}
 
+// respondSubscriptionUnavailable - The single response for missing and non-active projects,
+// identical in status and shape whichever it is
+func respondSubscriptionUnavailable(c *gin.Context, projectID string) {
 	c.JSON(http.StatusOK, gin.H{
-		"project_id":          projectID,
-		"status":              status,
-		"start_date":          project.StartDate,
-		"expiry_date":         project.ExpiryDate,
-		"total_tokens_used":   project.TotalTokensUsed,
-		"monthly_token_limit": project.MonthlyTokenLimit,
-		"remaining_tokens":    remainingTokens,
-		"usage_percentage":    usagePercent,
-		"days_until_expiry":   daysUntilExpiry,
-		"is_active":           status == "active" && daysUntilExpiry > 0,
-		"needs_renewal":       daysUntilExpiry <= 3,
+		"project_id":       projectID,
+		"status":           "unavailable",
+		"is_active":        false,
+		"captcha_required": false,
 	})
 }
 
@@ -79,14 +81,14 @@ func RenewSubscription(c *gin.Context) {
 
 	// Validate renewal period
 	if renewData.Months <= 0 || renewData.Months > 12 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Months must be between 1 and 12"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Months must be between 1 and 12")
 		return
 	}
 
 	// Get current project
-	project, err := getProjectByID(projectID)
+	project, err := getProjectByID(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
@@ -107,6 +109,7 @@ func RenewSubscription(c *gin.Context) {
 		"status":        "active",
 		"reminder_sent": false,
 		"updated_at":    time.Now(),
+		"plan":          models.PlanPaid, // Renewing converts a trial into a paid subscription
 	}
 
 	// Reset token usage if requested
@@ -117,19 +120,22 @@ func RenewSubscription(c *gin.Context) {
 	// Update token limit if provided
 	if renewData.NewTokenLimit > 0 {
 		updateFields["monthly_token_limit"] = renewData.NewTokenLimit
+	} else if project.IsTrial() {
+		// A converted trial moves up from the reduced trial limit
+		updateFields["monthly_token_limit"] = config.GetSubscriptionDefaults().MonthlyTokenLimit
 	}
 
-	update := bson.M{"$set": updateFields}
+	update := bson.M{"$set": updateFields, "$unset": bson.M{"trial_ends_at": ""}}
 
-	result, err := collection.UpdateOne(context.Background(),
+	result, err := collection.UpdateOne(c.Request.Context(),
 		bson.M{"project_id": projectID}, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to renew subscription"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to renew subscription")
 		return
 	}
 
 	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
@@ -159,15 +165,15 @@ func SuspendSubscription(c *gin.Context) {
 	c.ShouldBindJSON(&suspendData)
 
 	// Get project for logging
-	project, err := getProjectByID(projectID)
+	project, err := getProjectByID(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
 	err = updateProjectStatus(projectID, "suspended")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend subscription"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to suspend subscription")
 		return
 	}
 
@@ -191,9 +197,9 @@ func SuspendSubscription(c *gin.Context) {
 func ReactivateSubscription(c *gin.Context) {
 	projectID := c.Param("projectId")
 
-	project, err := getProjectByID(projectID)
+	project, err := getProjectByID(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
@@ -201,6 +207,7 @@ func ReactivateSubscription(c *gin.Context) {
 	if time.Now().After(project.ExpiryDate) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":        "Cannot reactivate expired subscription. Please renew first.",
+			"code":         ErrCodeSubscriptionExpired,
 			"expiry_date":  project.ExpiryDate,
 			"days_expired": time.Since(project.ExpiryDate).Hours() / 24,
 		})
@@ -209,13 +216,13 @@ func ReactivateSubscription(c *gin.Context) {
 
 	// Check current status
 	if project.Status == "active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Subscription is already active"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidState, "Subscription is already active")
 		return
 	}
 
 	err = updateProjectStatus(projectID, "active")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate subscription"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reactivate subscription")
 		return
 	}
 
@@ -237,55 +244,23 @@ func GetSubscriptionUsage(c *gin.Context) {
 	projectID := c.Param("projectId")
 	days := c.DefaultQuery("days", "30")
 
-	project, err := getProjectByID(projectID)
+	project, err := getProjectByID(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
-	// Calculate usage metrics
-	usagePercent := float64(0)
-	if project.MonthlyTokenLimit > 0 {
-		usagePercent = float64(project.TotalTokensUsed) / float64(project.MonthlyTokenLimit) * 100
-	}
-
-	remainingTokens := project.MonthlyTokenLimit - project.TotalTokensUsed
-	if remainingTokens < 0 {
-		remainingTokens = 0
-	}
-
-	daysUntilExpiry := time.Until(project.ExpiryDate).Hours() / 24
-	estimatedCost := calculateEstimatedCost(project.TotalTokensUsed)
-
 	// Get usage history if requested
 	daysInt, _ := strconv.Atoi(days)
-	usageHistory := getUsageHistory(projectID, daysInt)
-
-	// Calculate daily average
-	daysSinceStart := time.Since(project.StartDate).Hours() / 24
-	dailyAverage := int64(0)
-	if daysSinceStart > 0 {
-		dailyAverage = int64(float64(project.TotalTokensUsed) / daysSinceStart)
-	}
+	usage := NewUsageResponse(project, getUsageHistory(projectID, daysInt), getOverageSummary(c.Request.Context(), projectID))
+	usage.Warnings = getUsageWarnings(usage.UsagePercentage, usage.DaysUntilExpiry)
 
-	c.JSON(http.StatusOK, gin.H{
-		"project_id":        projectID,
-		"tokens_used":       project.TotalTokensUsed,
-		"token_limit":       project.MonthlyTokenLimit,
-		"remaining_tokens":  remainingTokens,
-		"usage_percentage":  usagePercent,
-		"days_until_expiry": daysUntilExpiry,
-		"estimated_cost":    estimatedCost,
-		"daily_average":     dailyAverage,
-		"status":            project.Status,
-		"usage_history":     usageHistory,
-		"warnings":          getUsageWarnings(usagePercent, daysUntilExpiry),
-	})
+	c.JSON(http.StatusOK, usage)
 }
 
 // GetSubscriptionStats - Get comprehensive subscription statistics
 func GetSubscriptionStats(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c)
 	defer cancel()
 
 	collection := config.GetProjectsCollection()
@@ -304,14 +279,14 @@ func GetSubscriptionStats(c *gin.Context) {
 
 	cursor, err := collection.Aggregate(ctx, pipeline)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription stats"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get subscription stats")
 		return
 	}
 	defer cursor.Close(ctx)
 
 	var stats []bson.M
 	if err := cursor.All(ctx, &stats); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse subscription stats"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse subscription stats")
 		return
 	}
 
@@ -375,12 +350,12 @@ func UpdateTokenLimit(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&limitData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit data"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid limit data")
 		return
 	}
 
 	if limitData.NewLimit <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Token limit must be greater than 0"})
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Token limit must be greater than 0")
 		return
 	}
 
@@ -393,20 +368,20 @@ func UpdateTokenLimit(c *gin.Context) {
 		},
 	}
 
-	result, err := collection.UpdateOne(context.Background(),
+	result, err := collection.UpdateOne(c.Request.Context(),
 		bson.M{"project_id": projectID}, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update token limit"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update token limit")
 		return
 	}
 
 	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
 	// Get project for logging
-	project, _ := getProjectByID(projectID)
+	project, _ := getProjectByID(c.Request.Context(), projectID)
 	if project != nil {
 		config.LogNotification(project.ID, "limit_update",
 			fmt.Sprintf("Token limit updated to %d for project: %s", limitData.NewLimit, project.Name))
@@ -420,31 +395,25 @@ func UpdateTokenLimit(c *gin.Context) {
 
 // ResetTokenUsage - Reset token usage for a project
 func ResetTokenUsage(c *gin.Context) {
-	projectID := c.Param("projectId")
-
-	collection := config.GetProjectsCollection()
-
-	update := bson.M{
-		"$set": bson.M{
-			"total_tokens_used": int64(0),
-			"updated_at":        time.Now(),
-		},
+	// Mounted as /api/admin/projects/:id/usage/reset
+	projectID := c.Param("id")
+	if projectID == "" {
+		projectID = c.Param("projectId")
 	}
 
-	result, err := collection.UpdateOne(context.Background(),
-		bson.M{"project_id": projectID}, update)
+	matched, err := resetTokenCounter(c.Request.Context(), projectID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset token usage"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reset token usage")
 		return
 	}
 
-	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	if !matched {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
 		return
 	}
 
 	// Get project for logging
-	project, _ := getProjectByID(projectID)
+	project, _ := getProjectByID(c.Request.Context(), projectID)
 	if project != nil {
 		config.LogNotification(project.ID, "usage_reset",
 			fmt.Sprintf("Token usage reset for project: %s", project.Name))
@@ -460,13 +429,29 @@ func ResetTokenUsage(c *gin.Context) {
 
 // Helper Functions
 
-// getProjectByID - Get project by project ID
-func getProjectByID(projectID string) (*models.Project, error) {
+// resetTokenCounter - Zero a project's token counter; reports whether the project exists
+func resetTokenCounter(ctx context.Context, projectID string) (bool, error) {
+	result, err := config.GetProjectsCollection().UpdateOne(ctx,
+		bson.M{"project_id": projectID},
+		bson.M{"$set": bson.M{
+			"total_tokens_used": int64(0),
+			"updated_at":        time.Now(),
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// getProjectByID - Get project by project ID; ctx is normally the request context
+func getProjectByID(ctx context.Context, projectID string) (*models.Project, error) {
 	collection := config.GetProjectsCollection()
 
 	var project models.Project
-	err := collection.FindOne(context.Background(),
-		bson.M{"project_id": projectID}).Decode(&project)
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		return collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -490,11 +475,39 @@ func calculateEstimatedCost(tokensUsed int64) float64 {
 	return totalCostINR
 }
 
+// setRequestUsageHeaders - X-Request-Tokens and X-Request-Cost for the request being answered,
+// priced the same way as the usage it is billed as (INR)
+func setRequestUsageHeaders(c *gin.Context, tokensUsed int) {
+	c.Header("X-Request-Tokens", strconv.Itoa(tokensUsed))
+	c.Header("X-Request-Cost", strconv.FormatFloat(calculateEstimatedCost(int64(tokensUsed)), 'f', 6, 64))
+}
+
 // getUsageHistory - Get token usage history for specified days
 func getUsageHistory(projectID string, days int) []map[string]interface{} {
-	// This would typically query usage logs collection
-	// For now, return empty array - implement based on your logging structure
-	return []map[string]interface{}{}
+	if days <= 0 || days > maxUsageBuckets {
+		days = 30
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	to := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	series, err := usageSeries(ctx, projectID, usageIntervalDay, to.AddDate(0, 0, -days), to)
+	if err != nil {
+		log.Printf("⚠️ Failed to load usage history for %s: %v", projectID, err)
+		return []map[string]interface{}{}
+	}
+
+	history := make([]map[string]interface{}, 0, len(series))
+	for _, point := range series {
+		history = append(history, map[string]interface{}{
+			"date":     point.Timestamp.Format("2006-01-02"),
+			"tokens":   point.Tokens,
+			"cost":     point.Cost,
+			"requests": point.Requests,
+		})
+	}
+	return history
 }
 
 // getUsageWarnings - Get usage warnings based on percentage and expiry
@@ -519,3 +532,26 @@ func getUsageWarnings(usagePercent, daysUntilExpiry float64) []string {
 
 	return warnings
 }
+
+// TriggerSubscriptionMaintenance - POST /api/admin/maintenance/subscriptions[?dry_run=true]
+// Runs the daily maintenance on demand; with dry_run it only reports what would change.
+func TriggerSubscriptionMaintenance(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	report, err := config.RunSubscriptionMaintenanceWithOptions(dryRun)
+	if err != nil {
+		log.Printf("❌ Manual subscription maintenance failed: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Subscription maintenance failed")
+		return
+	}
+
+	message := "Subscription maintenance completed"
+	if dryRun {
+		message = "Dry run: no changes were made"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"report":  report,
+	})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+func TestTriggerSubscriptionMaintenanceWithoutDatabase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := config.DB
+	config.DB = nil
+	t.Cleanup(func() { config.DB = previous })
+
+	r := gin.New()
+	r.POST("/maintenance/subscriptions", TriggerSubscriptionMaintenance)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/maintenance/subscriptions?dry_run=true", nil))
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), ErrCodeInternal) {
+		t.Errorf("got %d %s, want 500 %s", w.Code, w.Body, ErrCodeInternal)
+	}
+}
+
+func TestTriggerSubscriptionMaintenanceDryRun(t *testing.T) {
+	useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/maintenance/subscriptions", TriggerSubscriptionMaintenance)
+
+	tests := []struct {
+		query      string
+		wantDryRun bool
+	}{
+		{"?dry_run=true", true},
+		{"?dry_run=1", true},
+		{"?dry_run=maybe", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/maintenance/subscriptions"+tt.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d: %s", tt.query, w.Code, w.Body)
+		}
+		var resp struct {
+			Message string `json:"message"`
+			Report  struct {
+				DryRun bool `json:"dry_run"`
+			} `json:"report"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Report.DryRun != tt.wantDryRun || strings.HasPrefix(resp.Message, "Dry run") != tt.wantDryRun {
+			t.Errorf("%q: dry_run = %v, message = %q; want dry run %v", tt.query, resp.Report.DryRun, resp.Message, tt.wantDryRun)
+		}
+	}
+}
+
+func TestSetRequestUsageHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		tokens               int
+		wantTokens, wantCost string
+	}{
+		{0, "0", "0.000000"}, // answered without a model call
+		{1000, "1000", "0.365200"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		setRequestUsageHeaders(c, tt.tokens)
+		if got := w.Header().Get("X-Request-Tokens"); got != tt.wantTokens {
+			t.Errorf("%d tokens: X-Request-Tokens = %q, want %q", tt.tokens, got, tt.wantTokens)
+		}
+		if got := w.Header().Get("X-Request-Cost"); got != tt.wantCost {
+			t.Errorf("%d tokens: X-Request-Cost = %q, want %q", tt.tokens, got, tt.wantCost)
+		}
+	}
+}
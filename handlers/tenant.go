@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// errCrossTenant - The caller asked for data outside its tenant boundary
+var errCrossTenant = errors.New("access to this project's data is not allowed")
+
+// tenantScope - The data boundary of a request. Admins see every project; a signed-in user sees
+// the projects they own (and, behind ProjectOwnershipMiddleware, that project's data); an
+// anonymous widget visitor sees only their own sessions and messages in the project they chat
+// with. Non-admin handlers build their project, chat, session and usage filters through it
+// rather than trusting a project_id from the URL.
+type tenantScope struct {
+	admin          bool
+	userID         string
+	email          string
+	ownedProjectID string // set by ProjectOwnershipMiddleware
+	visitorID      string // set by VisitorIdentity
+}
+
+// requestScope - Scope of the caller, from what the auth, ownership and visitor middlewares set
+func requestScope(c *gin.Context) tenantScope {
+	return tenantScope{
+		admin:          c.GetString("user_role") == "admin",
+		userID:         c.GetString("user_id"),
+		email:          c.GetString("user_email"),
+		ownedProjectID: c.GetString("owned_project_id"),
+		visitorID:      c.GetString("visitor_id"),
+	}
+}
+
+// projects - Restrict a projects-collection filter to the projects the caller may see
+func (s tenantScope) projects(filter bson.M) (bson.M, error) {
+	switch {
+	case s.admin:
+		return filter, nil
+	case s.userID != "" || s.email != "":
+		return bson.M{"$and": []bson.M{filter, userProjectsFilter(s.userID, s.email)}}, nil
+	}
+	return nil, errCrossTenant
+}
+
+// projectData - Filter for one project's chat messages, sessions or usage records, narrowed to
+// the caller's own records when the caller is a visitor
+func (s tenantScope) projectData(projectID string, filter bson.M) (bson.M, error) {
+	scoped := bson.M{"project_id": projectID}
+	for key, value := range filter {
+		if key != "project_id" {
+			scoped[key] = value
+		}
+	}
+
+	switch {
+	case projectID == "":
+		return nil, errCrossTenant
+	case s.admin || s.ownedProjectID == projectID:
+		return scoped, nil
+	case s.visitorID != "":
+		scoped["visitor_id"] = s.visitorID
+		return scoped, nil
+	}
+	return nil, errCrossTenant
+}
+
+// canAccess - Whether a record of projectID created by visitorID is inside the scope (for
+// records looked up by a globally unique key such as a session id)
+func (s tenantScope) canAccess(projectID, visitorID string) bool {
+	switch {
+	case s.admin || (projectID != "" && s.ownedProjectID == projectID):
+		return true
+	case s.visitorID != "":
+		return visitorID == s.visitorID
+	}
+	return false
+}
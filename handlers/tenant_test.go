@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestRequestScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user_role", "admin")
+	c.Set("user_id", "user_1")
+	c.Set("user_email", "owner@example.com")
+	c.Set("owned_project_id", "proj_1")
+	c.Set("visitor_id", "visitor_1")
+
+	want := tenantScope{admin: true, userID: "user_1", email: "owner@example.com", ownedProjectID: "proj_1", visitorID: "visitor_1"}
+	if got := requestScope(c); got != want {
+		t.Errorf("requestScope = %+v, want %+v", got, want)
+	}
+
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user_role", "user")
+	if got := requestScope(c); got.admin {
+		t.Error("a non-admin role should not get an admin scope")
+	}
+}
+
+func TestTenantScopeProjects(t *testing.T) {
+	status := bson.M{"status": "active"}
+
+	if got, err := (tenantScope{admin: true}).projects(status); err != nil || !reflect.DeepEqual(got, status) {
+		t.Errorf("admin: projects = %v, %v; want the filter unchanged", got, err)
+	}
+
+	user := tenantScope{userID: "user_1", email: "owner@example.com"}
+	want := bson.M{"$and": []bson.M{status, userProjectsFilter("user_1", "owner@example.com")}}
+	if got, err := user.projects(status); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("user: projects = %v, %v; want the filter narrowed to their projects", got, err)
+	}
+
+	for _, scope := range []tenantScope{{}, {visitorID: "visitor_1"}, {ownedProjectID: "proj_1"}} {
+		if _, err := scope.projects(status); !errors.Is(err, errCrossTenant) {
+			t.Errorf("%+v: err = %v, want errCrossTenant", scope, err)
+		}
+	}
+}
+
+func TestTenantScopeProjectData(t *testing.T) {
+	tests := []struct {
+		name      string
+		scope     tenantScope
+		projectID string
+		want      bson.M // nil: rejected
+	}{
+		{"admin", tenantScope{admin: true}, "proj_1", bson.M{"project_id": "proj_1", "session_id": "sess_1"}},
+		{"owner", tenantScope{userID: "user_1", ownedProjectID: "proj_1"}, "proj_1", bson.M{"project_id": "proj_1", "session_id": "sess_1"}},
+		{"visitor", tenantScope{visitorID: "visitor_1"}, "proj_1", bson.M{"project_id": "proj_1", "session_id": "sess_1", "visitor_id": "visitor_1"}},
+		{"owner of another project", tenantScope{userID: "user_1", ownedProjectID: "proj_2"}, "proj_1", nil},
+		{"no identity", tenantScope{}, "proj_1", nil},
+		{"no project", tenantScope{admin: true}, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A project_id in the filter never overrides the scoped one
+			got, err := tt.scope.projectData(tt.projectID, bson.M{"project_id": "proj_other", "session_id": "sess_1"})
+			if tt.want == nil {
+				if !errors.Is(err, errCrossTenant) {
+					t.Errorf("projectData = %v, %v; want errCrossTenant", got, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("projectData = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantScopeCanAccess(t *testing.T) {
+	tests := []struct {
+		name                 string
+		scope                tenantScope
+		projectID, visitorID string
+		want                 bool
+	}{
+		{"admin", tenantScope{admin: true}, "proj_1", "visitor_2", true},
+		{"owner", tenantScope{ownedProjectID: "proj_1"}, "proj_1", "visitor_2", true},
+		{"own session", tenantScope{visitorID: "visitor_1"}, "proj_1", "visitor_1", true},
+		{"another visitor's session", tenantScope{visitorID: "visitor_1"}, "proj_1", "visitor_2", false},
+		{"owner of another project", tenantScope{ownedProjectID: "proj_2"}, "proj_1", "visitor_2", false},
+		{"record without a project", tenantScope{ownedProjectID: ""}, "", "visitor_2", false},
+		{"no identity", tenantScope{}, "proj_1", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.scope.canAccess(tt.projectID, tt.visitorID); got != tt.want {
+			t.Errorf("%s: canAccess = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVisitorsOnlySeeTheirOwnSessions(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	config.GetChatMessagesCollection().InsertMany(ctx, []interface{}{
+		models.ChatMessage{ProjectID: "proj_1", SessionID: "sess_1", VisitorID: "visitor_1", Message: "mine"},
+		models.ChatMessage{ProjectID: "proj_1", SessionID: "sess_2", VisitorID: "visitor_2", Message: "theirs"},
+	})
+	config.GetWidgetSessionsCollection().InsertOne(ctx, models.WidgetSession{SessionID: "sess_2", ProjectID: "proj_1", VisitorID: "visitor_2"})
+
+	history := func(visitorID, query string) (int, []models.ChatMessage) {
+		t.Helper()
+		r := gin.New()
+		r.GET("/projects/:projectId/history", func(c *gin.Context) {
+			if visitorID != "" {
+				c.Set("visitor_id", visitorID)
+			}
+			GetChatHistory(c)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/proj_1/history"+query, nil))
+		var resp struct {
+			Messages []models.ChatMessage `json:"messages"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Messages
+	}
+
+	if code, messages := history("visitor_1", ""); code != http.StatusOK || len(messages) != 1 || messages[0].Message != "mine" {
+		t.Errorf("visitor history = %d %+v, want only their own message", code, messages)
+	}
+	if code, messages := history("visitor_1", "?session_id=sess_2"); code != http.StatusOK || len(messages) != 0 {
+		t.Errorf("another visitor's session = %d %+v, want nothing", code, messages)
+	}
+	if code, _ := history("", ""); code != http.StatusForbidden {
+		t.Errorf("no visitor identity: status = %d, want 403", code)
+	}
+
+	// Another visitor's session id is never continued
+	if id, existing := resolveSessionID(ctx, tenantScope{visitorID: "visitor_1"}, "proj_1", "sess_2"); id == "sess_2" || existing {
+		t.Errorf("resolveSessionID reused another visitor's session")
+	}
+	if id, existing := resolveSessionID(ctx, tenantScope{visitorID: "visitor_2"}, "proj_1", "sess_2"); id != "sess_2" || !existing {
+		t.Errorf("resolveSessionID = %s, %v; want the visitor's own session", id, existing)
+	}
+}
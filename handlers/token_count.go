@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"unicode"
+)
+
+// Token counting without the tokenizer's vocabulary. OpenAI's BPE tokenizers first split text
+// into words, number groups, punctuation and whitespace, then encode each piece; countTokens does
+// the same split and charges each piece what it usually costs. It lands close to the real count
+// for prose and errs high for code and non-Latin scripts, which is the safe side for a budget.
+const (
+	tokenWordRunes   = 7 // letters a word can have and still be a single token
+	tokenDigitsGroup = 3 // numbers are split into groups of up to three digits
+)
+
+// countTokens - Estimated token count of text
+func countTokens(text string) int {
+	tokens := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' && i+1 < len(runes) && isWordRune(runes[i+1]):
+			// A single space is part of the word after it
+			i++
+
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens += wordTokens(runes[start:i])
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			tokens += (i - start + tokenDigitsGroup - 1) / tokenDigitsGroup
+
+		case unicode.IsSpace(r):
+			for i < len(runes) && unicode.IsSpace(runes[i]) {
+				i++
+			}
+			tokens++
+
+		default:
+			// Punctuation, symbols and emoji: about one token each
+			i++
+			tokens++
+		}
+	}
+	return tokens
+}
+
+// isWordRune - Letters and the marks combined with them
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)
+}
+
+// wordTokens - Tokens of one word: common Latin-script words are a single token and longer ones
+// split every few letters; other scripts take about a token per character
+func wordTokens(word []rune) int {
+	latin := 0
+	for _, r := range word {
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+		}
+	}
+	tokens := len(word) - latin
+	if latin > 0 {
+		tokens += 1 + (latin-1)/tokenWordRunes
+	}
+	return tokens
+}
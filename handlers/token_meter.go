@@ -0,0 +1,29 @@
+package handlers
+
+// tokenMeter - Token count of one model call that is known even when the call doesn't finish.
+// The prompt is estimated up front; once the provider reports real usage, that replaces the
+// estimate. A call cut short by a client disconnect is still billed for the prompt it sent.
+type tokenMeter struct {
+	promptTokens int
+	final        int
+}
+
+// newTokenMeter - Meter for a call whose prompt is about promptTokens
+func newTokenMeter(promptTokens int) *tokenMeter {
+	return &tokenMeter{promptTokens: promptTokens}
+}
+
+// Final - Record the provider's reported total; it wins over the estimate
+func (m *tokenMeter) Final(totalTokens int) {
+	if totalTokens > 0 {
+		m.final = totalTokens
+	}
+}
+
+// Tokens - Reported usage when available, otherwise the prompt estimate
+func (m *tokenMeter) Tokens() int {
+	if m.final > 0 {
+		return m.final
+	}
+	return m.promptTokens
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// stubOpenAI - Send OpenAI calls to handler for the duration of a test
+func stubOpenAI(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	previous := openAIBaseURL
+	openAIBaseURL = server.URL
+	t.Cleanup(func() { openAIBaseURL = previous })
+	t.Setenv("OPENAI_API_KEY", "test-key")
+}
+
+// completion - A chat completion response with one assistant message
+func completion(message openai.ChatCompletionMessage, totalTokens int) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		Object:  "chat.completion",
+		Choices: []openai.ChatCompletionChoice{{Message: message, FinishReason: openai.FinishReasonStop}},
+		Usage:   openai.Usage{TotalTokens: totalTokens},
+	}
+}
+
+func TestTokenMeter(t *testing.T) {
+	meter := newTokenMeter(120)
+	if got := meter.Tokens(); got != 120 {
+		t.Errorf("Tokens() before usage = %d, want the prompt estimate 120", got)
+	}
+	meter.Final(0)
+	if got := meter.Tokens(); got != 120 {
+		t.Errorf("Tokens() after an empty usage report = %d, want 120", got)
+	}
+	meter.Final(157)
+	if got := meter.Tokens(); got != 157 {
+		t.Errorf("Tokens() after usage = %d, want the reported 157", got)
+	}
+}
+
+func TestGenerateOpenAIResponseMetersFullCompletion(t *testing.T) {
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(completion(openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "We open at nine."}, 157))
+	})
+
+	response, tokens, err := generateOpenAIResponse(context.Background(), "When do you open?", "You are helpful.", "gpt-4o", conversationHistory{}, nil)
+	if err != nil {
+		t.Fatalf("generateOpenAIResponse: %v", err)
+	}
+	if response != "We open at nine." {
+		t.Errorf("response = %q", response)
+	}
+	if tokens != 157 {
+		t.Errorf("tokens = %d, want the provider's 157", tokens)
+	}
+}
+
+func TestGenerateOpenAIResponseMetersInterruptedCall(t *testing.T) {
+	leave := make(chan struct{})
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		// The visitor leaves before the model answers
+		select {
+		case <-r.Context().Done():
+		case <-leave:
+		}
+	})
+	t.Cleanup(func() { close(leave) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	message, system := "When do you open?", "You are helpful."
+	_, tokens, err := generateOpenAIResponse(ctx, message, system, "gpt-4o", conversationHistory{}, nil)
+	if err == nil {
+		t.Fatal("expected the call to fail")
+	}
+	if want := countTokens(system) + countTokens(message); tokens != want {
+		t.Errorf("tokens = %d, want the prompt estimate %d", tokens, want)
+	}
+}
+
+func TestGenerateOpenAIResponseProviderErrorIsNotBilled(t *testing.T) {
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"message":"server error"}}`))
+	})
+
+	_, tokens, err := generateOpenAIResponse(context.Background(), "hi", "You are helpful.", "gpt-4o", conversationHistory{}, nil)
+	if err == nil {
+		t.Fatal("expected the provider error")
+	}
+	if tokens != 0 {
+		t.Errorf("tokens = %d, want 0 for a call the provider rejected", tokens)
+	}
+}
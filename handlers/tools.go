@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+	"jevi-chat/utils"
+)
+
+// Tool calling limits
+const (
+	maxProjectTools        = 10
+	maxToolRounds          = 3        // model → tools → model round trips per chat message
+	maxToolResponseBytes   = 16 << 10 // webhook response passed back to the model
+	defaultToolTimeoutSecs = 10
+)
+
+// toolNamePattern - Names OpenAI accepts for functions
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// sharedAddressSpace - Carrier-grade NAT range (RFC 6598), not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// toolWebhookClient - Client for tool webhooks. Webhook URLs are configured per project, so
+// every connection is checked at dial time (after DNS resolution) against internal addresses,
+// redirects are not followed and proxies from the environment are ignored.
+var toolWebhookClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: rejectInternalDial,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        20,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// GetProjectTools - GET /api/admin/projects/:id/tools
+func GetProjectTools(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	tools := project.Tools
+	if tools == nil {
+		tools = []models.ProjectTool{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"tools":      tools,
+	})
+}
+
+// UpdateProjectTools - PUT /api/admin/projects/:id/tools
+// Replaces the project's tool list: {"tools": [{"name", "description", "parameters", "webhook_url", "enabled"}]}
+func UpdateProjectTools(c *gin.Context) {
+	var body struct {
+		Tools []models.ProjectTool `json:"tools"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid tools payload")
+		return
+	}
+	if err := validateProjectTools(body.Tools); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	_, err = config.GetProjectsCollection().UpdateOne(ctx,
+		bson.M{"_id": project.ID},
+		bson.M{"$set": bson.M{"tools": body.Tools, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update tools")
+		return
+	}
+
+	log.Printf("✅ Project %s tools updated (%d configured)", project.ProjectID, len(body.Tools))
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Tools updated successfully",
+		"project_id": project.ProjectID,
+		"tools":      body.Tools,
+	})
+}
+
+// validateProjectTools - Check names, descriptions, schemas and webhook URLs; fills in an
+// empty parameter schema where none is given
+func validateProjectTools(tools []models.ProjectTool) error {
+	if len(tools) > maxProjectTools {
+		return fmt.Errorf("A project can have at most %d tools", maxProjectTools)
+	}
+
+	seen := make(map[string]bool, len(tools))
+	for i := range tools {
+		tool := &tools[i]
+		tool.Name = strings.TrimSpace(tool.Name)
+		tool.Description = strings.TrimSpace(tool.Description)
+
+		if !toolNamePattern.MatchString(tool.Name) {
+			return fmt.Errorf("Tool name %q must be 1-64 letters, digits, underscores or hyphens", tool.Name)
+		}
+		if seen[tool.Name] {
+			return fmt.Errorf("Duplicate tool name %q", tool.Name)
+		}
+		seen[tool.Name] = true
+
+		if tool.Description == "" {
+			return fmt.Errorf("Tool %q needs a description so the model knows when to use it", tool.Name)
+		}
+
+		parsed, err := url.Parse(tool.WebhookURL)
+		if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+			return fmt.Errorf("Tool %q webhook_url must be an https URL", tool.Name)
+		}
+		if host := strings.ToLower(parsed.Hostname()); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return fmt.Errorf("Tool %q webhook_url must not point to an internal address", tool.Name)
+		}
+		if ip := net.ParseIP(parsed.Hostname()); ip != nil && internalAddress(ip) {
+			return fmt.Errorf("Tool %q webhook_url must not point to an internal address", tool.Name)
+		}
+
+		if tool.Parameters == nil {
+			tool.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		} else if tool.Parameters["type"] != "object" {
+			return fmt.Errorf("Tool %q parameters must be a JSON Schema with type \"object\"", tool.Name)
+		}
+	}
+	return nil
+}
+
+// chatTools - A project's enabled tools for one chat message
+type chatTools struct {
+	ProjectID string
+	SessionID string
+	Tools     []models.ProjectTool
+	Called    int // webhook calls made so far; their side effects must not be repeated
+}
+
+// ran - Whether any tool webhook has been called, so replaying the message would call it again
+func (t *chatTools) ran() bool {
+	return t != nil && t.Called > 0
+}
+
+// definitions - The tools in OpenAI's request format
+func (t *chatTools) definitions() []openai.Tool {
+	definitions := make([]openai.Tool, 0, len(t.Tools))
+	for _, tool := range t.Tools {
+		definitions = append(definitions, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// call - Run one tool call and return the content for the tool message. Failures are
+// reported to the model as {"error": ...} so it can tell the visitor instead of failing the chat.
+func (t *chatTools) call(ctx context.Context, call openai.ToolCall) string {
+	for _, tool := range t.Tools {
+		if tool.Name == call.Function.Name {
+			t.Called++
+			result, err := invokeToolWebhook(ctx, tool, t.ProjectID, t.SessionID, call)
+			if err != nil {
+				log.Printf("⚠️ Tool %s for project %s failed: %v", tool.Name, t.ProjectID, err)
+				return toolError(err.Error())
+			}
+			log.Printf("🔧 Tool %s called for project %s", tool.Name, t.ProjectID)
+			return result
+		}
+	}
+	return toolError(fmt.Sprintf("unknown tool %q", call.Function.Name))
+}
+
+// invokeToolWebhook - POST the call to the tool's webhook, signed like notification webhooks
+// (X-Signature-256 with TOOL_WEBHOOK_SECRET)
+func invokeToolWebhook(ctx context.Context, tool models.ProjectTool, projectID, sessionID string, call openai.ToolCall) (string, error) {
+	arguments := json.RawMessage(call.Function.Arguments)
+	if !json.Valid(arguments) {
+		return "", fmt.Errorf("model sent invalid JSON arguments")
+	}
+
+	payload, err := json.Marshal(gin.H{
+		"project_id": projectID,
+		"session_id": sessionID,
+		"tool":       tool.Name,
+		"call_id":    call.ID,
+		"arguments":  arguments,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(envInt("TOOL_WEBHOOK_TIMEOUT_SECONDS", defaultToolTimeoutSecs))*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tool.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := os.Getenv("TOOL_WEBHOOK_SECRET"); secret != "" {
+		req.Header.Set("X-Signature-256", utils.SignPayload(secret, payload))
+	}
+
+	resp, err := toolWebhookClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("webhook request failed")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxToolResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read webhook response")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return `{"ok":true}`, nil
+	}
+	return string(body), nil
+}
+
+// rejectInternalDial - net.Dialer Control hook refusing loopback, private, link-local and other
+// non-public addresses, whatever the webhook host name resolved to
+func rejectInternalDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || internalAddress(ip) {
+		return fmt.Errorf("tool webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+// internalAddress - Addresses a tool webhook must never reach. IsGlobalUnicast already excludes
+// loopback, link-local, multicast and unspecified addresses.
+func internalAddress(ip net.IP) bool {
+	return !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip)
+}
+
+// toolError - Tool message content describing a failed call
+func toolError(msg string) string {
+	encoded, _ := json.Marshal(gin.H{"error": msg})
+	return string(encoded)
+}
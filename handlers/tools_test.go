@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	"jevi-chat/models"
+)
+
+func TestValidateProjectTools(t *testing.T) {
+	tool := func(name, webhook string) models.ProjectTool {
+		return models.ProjectTool{Name: name, Description: "Look up an order", WebhookURL: webhook}
+	}
+
+	tests := []struct {
+		name    string
+		tools   []models.ProjectTool
+		wantErr string
+	}{
+		{"public https webhook", []models.ProjectTool{tool("lookup_order", "https://hooks.example.com/order")}, ""},
+		{"plain http", []models.ProjectTool{tool("lookup_order", "http://hooks.example.com/order")}, "https URL"},
+		{"no host", []models.ProjectTool{tool("lookup_order", "https:///order")}, "https URL"},
+		{"localhost", []models.ProjectTool{tool("lookup_order", "https://localhost:8080/x")}, "internal address"},
+		{"loopback ip", []models.ProjectTool{tool("lookup_order", "https://127.0.0.1/x")}, "internal address"},
+		{"private ip", []models.ProjectTool{tool("lookup_order", "https://10.1.2.3/x")}, "internal address"},
+		{"metadata service", []models.ProjectTool{tool("lookup_order", "https://169.254.169.254/latest")}, "internal address"},
+		{"ipv6 loopback", []models.ProjectTool{tool("lookup_order", "https://[::1]/x")}, "internal address"},
+		{"bad name", []models.ProjectTool{tool("lookup order", "https://hooks.example.com")}, "letters, digits"},
+		{"duplicate name", []models.ProjectTool{
+			tool("lookup_order", "https://hooks.example.com"),
+			tool("lookup_order", "https://hooks.example.com"),
+		}, "Duplicate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProjectTools(tt.tools)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if tt.tools[0].Parameters["type"] != "object" {
+					t.Errorf("empty parameter schema not filled in: %v", tt.tools[0].Parameters)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInternalAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+		{"127.0.0.1", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+	}
+	for _, tt := range tests {
+		if got := internalAddress(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("internalAddress(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestInvokeToolWebhookRefusesInternalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	// Validation would refuse this URL; the dialer must refuse it too, e.g. after DNS rebinding
+	tool := models.ProjectTool{Name: "lookup_order", WebhookURL: server.URL}
+	call := openai.ToolCall{ID: "call_1", Function: openai.FunctionCall{Name: "lookup_order", Arguments: "{}"}}
+
+	if _, err := invokeToolWebhook(context.Background(), tool, "proj_1", "sess_1", call); err == nil {
+		t.Fatal("expected the webhook call to be refused")
+	}
+	if called {
+		t.Error("webhook on a loopback address was reached")
+	}
+}
+
+func TestToolWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "https://hooks.example.com/order", nil)
+	if err := toolWebhookClient.CheckRedirect(req, []*http.Request{req}); err != http.ErrUseLastResponse {
+		t.Errorf("CheckRedirect = %v, want http.ErrUseLastResponse", err)
+	}
+}
+
+// stubToolWebhook - A tool whose webhook answers with result, reached without the internal-address
+// dialer (the stub listens on loopback); returns the arguments of every call
+func stubToolWebhook(t *testing.T, result string) (models.ProjectTool, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		calls = append(calls, string(payload.Arguments))
+		mu.Unlock()
+		w.Write([]byte(result))
+	}))
+	t.Cleanup(server.Close)
+
+	previous := toolWebhookClient
+	toolWebhookClient = server.Client()
+	t.Cleanup(func() { toolWebhookClient = previous })
+
+	tool := models.ProjectTool{Name: "lookup_order", Description: "Look up an order", WebhookURL: server.URL}
+	return tool, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+// toolCallReply - An assistant message asking for lookup_order
+func toolCallReply(round int) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{{
+			ID:       fmt.Sprintf("call_%d", round),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: "lookup_order", Arguments: fmt.Sprintf(`{"order_id":"A%d"}`, round)},
+		}},
+	}
+}
+
+func TestGenerateOpenAIResponseFeedsToolResultsBack(t *testing.T) {
+	tool, webhookCalls := stubToolWebhook(t, `{"status":"shipped"}`)
+
+	var requests []openai.ChatCompletionRequest
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		if len(requests) == 1 {
+			json.NewEncoder(w).Encode(completion(toolCallReply(1), 50))
+			return
+		}
+		json.NewEncoder(w).Encode(completion(openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "Your order has shipped."}, 70))
+	})
+
+	tools := &chatTools{ProjectID: "proj_1", SessionID: "sess_1", Tools: []models.ProjectTool{tool}}
+	response, tokens, err := generateOpenAIResponse(context.Background(), "Where is order A1?", "You are helpful.", "gpt-4o", conversationHistory{}, tools)
+	if err != nil {
+		t.Fatalf("generateOpenAIResponse: %v", err)
+	}
+	if response != "Your order has shipped." || tokens != 120 {
+		t.Errorf("response = %q, tokens = %d; want the final answer and 120 tokens over both rounds", response, tokens)
+	}
+	if calls := webhookCalls(); len(calls) != 1 || calls[0] != `{"order_id":"A1"}` {
+		t.Errorf("webhook calls = %v, want one with the model's arguments", calls)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("model called %d times, want 2", len(requests))
+	}
+
+	followUp := requests[1].Messages
+	last := followUp[len(followUp)-1]
+	if last.Role != openai.ChatMessageRoleTool || last.ToolCallID != "call_1" || last.Content != `{"status":"shipped"}` {
+		t.Errorf("last message of the follow-up = %+v, want the webhook result as a tool message", last)
+	}
+	if asked := followUp[len(followUp)-2]; len(asked.ToolCalls) != 1 {
+		t.Errorf("follow-up does not repeat the model's tool call: %+v", asked)
+	}
+}
+
+func TestGenerateOpenAIResponseBoundsToolRounds(t *testing.T) {
+	tool, webhookCalls := stubToolWebhook(t, `{"status":"unknown"}`)
+
+	rounds := 0
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		rounds++
+		// The model keeps asking for the tool as long as it is offered
+		if len(req.Tools) > 0 {
+			json.NewEncoder(w).Encode(completion(toolCallReply(rounds), 10))
+			return
+		}
+		json.NewEncoder(w).Encode(completion(openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "I couldn't find it."}, 10))
+	})
+
+	tools := &chatTools{ProjectID: "proj_1", SessionID: "sess_1", Tools: []models.ProjectTool{tool}}
+	response, tokens, err := generateOpenAIResponse(context.Background(), "Where is my order?", "You are helpful.", "gpt-4o", conversationHistory{}, tools)
+	if err != nil {
+		t.Fatalf("generateOpenAIResponse: %v", err)
+	}
+	if rounds != maxToolRounds+1 {
+		t.Errorf("model called %d times, want %d", rounds, maxToolRounds+1)
+	}
+	if calls := webhookCalls(); len(calls) != maxToolRounds || tools.Called != maxToolRounds {
+		t.Errorf("webhook called %d times (counted %d), want %d", len(calls), tools.Called, maxToolRounds)
+	}
+	if response != "I couldn't find it." || tokens != 10*(maxToolRounds+1) {
+		t.Errorf("response = %q, tokens = %d", response, tokens)
+	}
+}
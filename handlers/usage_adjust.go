@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// AdjustTokenUsage - POST /api/admin/projects/:id/usage/adjust
+// Body: {"delta": -5000, "reason": "Goodwill credit for outage"}. The delta is added to the
+// project's token usage: negative grants tokens back, positive corrects an undercount.
+// Usage never goes below zero.
+func AdjustTokenUsage(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var body struct {
+		Delta  int64  `json:"delta"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Delta == 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "delta must be a non-zero number of tokens")
+		return
+	}
+	if body.Delta > config.MaxMonthlyTokenLimit || body.Delta < -config.MaxMonthlyTokenLimit {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+			fmt.Sprintf("delta must be between -%d and %d", config.MaxMonthlyTokenLimit, config.MaxMonthlyTokenLimit))
+		return
+	}
+	if body.Reason == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "reason is required")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	previous, updated, err := adjustTokenCounter(ctx, projectID, body.Delta)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to adjust token usage for %s: %v", projectID, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to adjust token usage")
+		return
+	}
+
+	// Audit trail: who changed the counter, by how much and why
+	admin := c.GetString("user_email")
+	config.LogNotification(updated.ID, "usage_adjusted", fmt.Sprintf(
+		"Token usage adjusted by %+d (%d → %d) by %s: %s",
+		body.Delta, previous, updated.TotalTokensUsed, admin, body.Reason))
+	log.Printf("✅ Token usage adjusted for %s by %s: %+d (%d → %d)", projectID, admin, body.Delta, previous, updated.TotalTokensUsed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Token usage adjusted successfully",
+		"delta":               body.Delta,
+		"previous_tokens":     previous,
+		"tokens_used":         updated.TotalTokensUsed,
+		"monthly_token_limit": updated.MonthlyTokenLimit,
+		"clamped":             previous+body.Delta < 0,
+	})
+}
+
+// adjustTokenCounter - Atomically add delta to a project's token counter, clamped at zero;
+// returns the counter before the change and the updated project
+func adjustTokenCounter(ctx context.Context, projectID string, delta int64) (int64, *models.Project, error) {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"total_tokens_used": bson.M{"$max": bson.A{int64(0), bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$total_tokens_used", int64(0)}}, delta}}}},
+			"updated_at":        time.Now(),
+		}}},
+	}
+
+	var before models.Project
+	err := config.GetProjectsCollection().FindOneAndUpdate(ctx,
+		bson.M{"project_id": projectID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&before)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	after := before
+	after.TotalTokensUsed = max(0, before.TotalTokensUsed+delta)
+	return before.TotalTokensUsed, &after, nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// adjustUsageRouter - AdjustTokenUsage behind an admin identity; returns a function posting a body for a project
+func adjustUsageRouter() func(projectID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/projects/:id/usage/adjust", func(c *gin.Context) { c.Set("user_email", "admin@example.com") }, AdjustTokenUsage)
+	return func(projectID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/projects/"+projectID+"/usage/adjust", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+}
+
+func TestAdjustTokenUsageValidatesInput(t *testing.T) {
+	adjust := adjustUsageRouter()
+
+	tests := []struct {
+		name, body, wantMessage string
+	}{
+		{"malformed", `{"delta":`, "Invalid request body"},
+		{"fractional delta", `{"delta":1.5,"reason":"x"}`, "Invalid request body"},
+		{"zero delta", `{"delta":0,"reason":"Goodwill"}`, "non-zero"},
+		{"missing delta", `{"reason":"Goodwill"}`, "non-zero"},
+		{"credit too large", `{"delta":-100000001,"reason":"Goodwill"}`, "delta must be between"},
+		{"debit too large", `{"delta":100000001,"reason":"Goodwill"}`, "delta must be between"},
+		{"no reason", `{"delta":-500}`, "reason is required"},
+		{"blank reason", `{"delta":-500,"reason":"   "}`, "reason is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Refused before the project is looked up, so no database is needed
+			w := adjust("proj_1", tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("got %d %s, want 400 mentioning %q", w.Code, w.Body, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAdjustTokenUsage(t *testing.T) {
+	ctx := useTestDatabase(t)
+	adjust := adjustUsageRouter()
+
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, models.Project{
+		ProjectID: "proj_1", MonthlyTokenLimit: 10000, TotalTokensUsed: 3000,
+	}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	tests := []struct {
+		name, body              string
+		wantPrevious, wantAfter float64
+		wantClamped             bool
+	}{
+		{"debit an undercount", `{"delta":500,"reason":"Missed usage"}`, 3000, 3500, false},
+		{"credit back", `{"delta":-1500,"reason":"Goodwill credit for outage"}`, 3500, 2000, false},
+		{"credit more than used", `{"delta":-5000,"reason":"Full refund"}`, 2000, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := adjust("proj_1", tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var resp map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp["previous_tokens"] != tt.wantPrevious || resp["tokens_used"] != tt.wantAfter || resp["clamped"] != tt.wantClamped {
+				t.Errorf("response = %v, want %v → %v (clamped %v)", resp, tt.wantPrevious, tt.wantAfter, tt.wantClamped)
+			}
+
+			var stored models.Project
+			if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": "proj_1"}).Decode(&stored); err != nil {
+				t.Fatalf("find: %v", err)
+			}
+			if float64(stored.TotalTokensUsed) != tt.wantAfter {
+				t.Errorf("stored total_tokens_used = %d, want %v", stored.TotalTokensUsed, tt.wantAfter)
+			}
+		})
+	}
+
+	// Every adjustment leaves an audit entry naming the admin and the reason
+	var audit []struct {
+		Message string `bson:"message"`
+	}
+	cursor, err := config.GetNotificationsCollection().Find(ctx, bson.M{"type": "usage_adjusted"})
+	if err != nil {
+		t.Fatalf("find notifications: %v", err)
+	}
+	if err := cursor.All(ctx, &audit); err != nil || len(audit) != len(tests) {
+		t.Fatalf("usage_adjusted notifications = %d (%v), want %d", len(audit), err, len(tests))
+	}
+	for _, entry := range audit {
+		if !strings.Contains(entry.Message, "admin@example.com") {
+			t.Errorf("audit entry does not name the admin: %q", entry.Message)
+		}
+	}
+
+	if w := adjust("missing", `{"delta":-500,"reason":"Goodwill"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+}
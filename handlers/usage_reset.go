@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jevi-chat/config"
+)
+
+// Archive collections for data cleared by a full usage reset
+const (
+	usageLogsArchiveCollection    = "openai_usage_logs_archive"
+	chatMessagesArchiveCollection = "chat_messages_archive"
+)
+
+// ResetUsageAndHistory - POST /api/admin/projects/:id/usage/reset-all
+// Starts a clean billing period: zeroes the token counter and moves the project's usage logs
+// (and, with include_chat_history, its chat messages) into archive collections.
+// The body must repeat the project ID in confirm_project_id to guard against accidental resets.
+func ResetUsageAndHistory(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var body struct {
+		ConfirmProjectID   string `json:"confirm_project_id"`
+		IncludeChatHistory bool   `json:"include_chat_history"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.ConfirmProjectID != projectID {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+			"confirm_project_id must match the project ID to reset usage history")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := getProjectByID(ctx, projectID)
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	resetID := primitive.NewObjectID()
+	resetAt := time.Now()
+
+	archivedLogs, err := archiveProjectDocuments(ctx, config.GetOpenAIUsageLogsCollection(), usageLogsArchiveCollection, "timestamp", projectID, resetID, resetAt)
+	if err != nil {
+		log.Printf("❌ Failed to archive usage logs for %s: %v", projectID, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to archive usage logs")
+		return
+	}
+
+	var archivedMessages int64
+	if body.IncludeChatHistory {
+		archivedMessages, err = archiveProjectDocuments(ctx, config.GetChatMessagesCollection(), chatMessagesArchiveCollection, "created_at", projectID, resetID, resetAt)
+		if err != nil {
+			log.Printf("❌ Failed to archive chat messages for %s: %v", projectID, err)
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to archive chat history")
+			return
+		}
+	}
+
+	if _, err := resetTokenCounter(ctx, projectID); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reset token usage")
+		return
+	}
+
+	// Audit trail: who reset what, and the reset ID to find the archived documents
+	admin := c.GetString("user_email")
+	config.LogNotification(project.ID, "usage_reset_all", fmt.Sprintf(
+		"Usage reset with history by %s (reset %s): %d usage logs archived, %d chat messages archived",
+		admin, resetID.Hex(), archivedLogs, archivedMessages))
+	log.Printf("✅ Usage and history reset for %s by %s: %d logs, %d messages archived", projectID, admin, archivedLogs, archivedMessages)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":                "Token usage and history reset successfully",
+		"reset_id":               resetID.Hex(),
+		"tokens_used":            0,
+		"usage_logs_archived":    archivedLogs,
+		"chat_messages_archived": archivedMessages,
+		"chat_history_cleared":   body.IncludeChatHistory,
+		"archived_at":            resetAt,
+	})
+}
+
+// archiveProjectDocuments - Copy a project's documents written up to resetAt (by timeField) into
+// archive, tagged with the reset, then delete the originals. Later writes stay in the live collection.
+func archiveProjectDocuments(ctx context.Context, source *mongo.Collection, archive, timeField, projectID string, resetID primitive.ObjectID, resetAt time.Time) (int64, error) {
+	filter := bson.M{"project_id": projectID, timeField: bson.M{"$lte": resetAt}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$set", Value: bson.M{"archived_at": resetAt, "reset_id": resetID}}},
+		{{Key: "$merge", Value: bson.M{"into": archive, "whenMatched": "keepExisting", "whenNotMatched": "insert"}}},
+	}
+	cursor, err := source.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	cursor.Close(ctx)
+
+	result, err := source.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestResetUsageAndHistoryRequiresConfirmation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/projects/:id/usage/reset-all", ResetUsageAndHistory)
+
+	// Refused before the project is looked up, so no database is needed
+	for _, body := range []string{``, `{}`, `{"confirm_project_id":"proj_2"}`, `{"confirm_project_id":" proj_1"}`} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/projects/proj_1/usage/reset-all", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrCodeValidationFailed) {
+			t.Errorf("body %q: got %d %s, want 400", body, w.Code, w.Body)
+		}
+	}
+}
+
+func TestResetUsageAndHistory(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	project := models.Project{ProjectID: "proj_1", TotalTokensUsed: 5000}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour) // written after the reset started
+	if _, err := config.GetOpenAIUsageLogsCollection().InsertMany(ctx, []interface{}{
+		bson.M{"project_id": "proj_1", "timestamp": past, "tokens": 100},
+		bson.M{"project_id": "proj_1", "timestamp": past, "tokens": 200},
+		bson.M{"project_id": "proj_1", "timestamp": future, "tokens": 300},
+		bson.M{"project_id": "proj_2", "timestamp": past, "tokens": 400},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+	if _, err := config.GetChatMessagesCollection().InsertMany(ctx, []interface{}{
+		bson.M{"project_id": "proj_1", "created_at": past, "message": "hi"},
+	}); err != nil {
+		t.Fatalf("insert messages: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/projects/:id/usage/reset-all", func(c *gin.Context) { c.Set("user_email", "admin@example.com") }, ResetUsageAndHistory)
+	reset := func(body string) map[string]interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/projects/proj_1/usage/reset-all", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	count := func(collection string, filter bson.M) int64 {
+		t.Helper()
+		n, err := config.GetCollection(collection).CountDocuments(ctx, filter)
+		if err != nil {
+			t.Fatalf("count %s: %v", collection, err)
+		}
+		return n
+	}
+
+	resp := reset(`{"confirm_project_id":"proj_1"}`)
+	if resp["usage_logs_archived"] != 2.0 || resp["chat_messages_archived"] != 0.0 || resp["chat_history_cleared"] != false {
+		t.Errorf("response = %v", resp)
+	}
+	if n := count("openai_usage_logs", bson.M{"project_id": "proj_1"}); n != 1 {
+		t.Errorf("%d live usage logs left, want only the one written after the reset", n)
+	}
+	if n := count(usageLogsArchiveCollection, bson.M{"project_id": "proj_1", "reset_id": bson.M{"$exists": true}}); n != 2 {
+		t.Errorf("%d archived usage logs tagged with the reset, want 2", n)
+	}
+	if n := count("openai_usage_logs", bson.M{"project_id": "proj_2"}); n != 1 {
+		t.Errorf("other project's usage logs touched: %d left", n)
+	}
+	if n := count("chat_messages", bson.M{"project_id": "proj_1"}); n != 1 {
+		t.Errorf("chat history cleared without include_chat_history")
+	}
+
+	var stored models.Project
+	if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": "proj_1"}).Decode(&stored); err != nil {
+		t.Fatalf("find project: %v", err)
+	}
+	if stored.TotalTokensUsed != 0 {
+		t.Errorf("total_tokens_used = %d, want 0", stored.TotalTokensUsed)
+	}
+	if n := count("notifications", bson.M{"type": "usage_reset_all"}); n != 1 {
+		t.Errorf("%d usage_reset_all notifications, want one audit entry", n)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/projects/missing/usage/reset-all", strings.NewReader(`{"confirm_project_id":"missing"}`))
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown project: status = %d, want 404", w.Code)
+	}
+
+	resp = reset(`{"confirm_project_id":"proj_1","include_chat_history":true}`)
+	if resp["chat_messages_archived"] != 1.0 || count("chat_messages", bson.M{"project_id": "proj_1"}) != 0 ||
+		count(chatMessagesArchiveCollection, bson.M{"project_id": "proj_1"}) != 1 {
+		t.Errorf("chat history not archived: %v", resp)
+	}
+}
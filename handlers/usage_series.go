@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jevi-chat/config"
+)
+
+// Chart buckets are UTC hours or days. The bucket count is capped so a wide range can't
+// produce an unbounded response.
+const (
+	usageIntervalHour = "hour"
+	usageIntervalDay  = "day"
+
+	maxUsageBuckets = 31 * 24
+)
+
+// UsagePoint - Totals for one bucket of a usage series
+type UsagePoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Tokens    int64     `json:"tokens"`
+	Cost      float64   `json:"cost"`
+	Requests  int64     `json:"requests"`
+}
+
+// usageBucketSize - Width of one bucket of the interval
+func usageBucketSize(interval string) time.Duration {
+	if interval == usageIntervalHour {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// parseUsageTime - RFC 3339 timestamp or YYYY-MM-DD date (midnight UTC)
+func parseUsageTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// usageSeries - Token usage of a project in [from, to) bucketed by interval, oldest first.
+// Buckets without usage are present with zero totals so charts don't need to fill gaps.
+func usageSeries(ctx context.Context, projectID, interval string, from, to time.Time) ([]UsagePoint, error) {
+	size := usageBucketSize(interval)
+	from = from.UTC().Truncate(size)
+
+	// $dateToString (unlike $dateTrunc) works on every MongoDB version we deploy to
+	format := "%Y-%m-%d"
+	if interval == usageIntervalHour {
+		format = "%Y-%m-%dT%H"
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"project_id": projectID, "timestamp": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      bson.M{"$dateToString": bson.M{"format": format, "date": "$timestamp", "timezone": "UTC"}},
+			"tokens":   bson.M{"$sum": "$total_tokens"},
+			"cost":     bson.M{"$sum": "$cost"},
+			"requests": bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := config.GetOpenAIUsageLogsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Bucket   string  `bson:"_id"`
+		Tokens   int64   `bson:"tokens"`
+		Cost     float64 `bson:"cost"`
+		Requests int64   `bson:"requests"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	goFormat := "2006-01-02"
+	if interval == usageIntervalHour {
+		goFormat = "2006-01-02T15"
+	}
+	byBucket := make(map[int64]UsagePoint, len(rows))
+	for _, row := range rows {
+		bucket, err := time.Parse(goFormat, row.Bucket)
+		if err != nil {
+			continue
+		}
+		byBucket[bucket.Unix()] = UsagePoint{Timestamp: bucket, Tokens: row.Tokens, Cost: row.Cost, Requests: row.Requests}
+	}
+
+	series := []UsagePoint{}
+	for bucket := from; bucket.Before(to); bucket = bucket.Add(size) {
+		point, ok := byBucket[bucket.Unix()]
+		if !ok {
+			point = UsagePoint{Timestamp: bucket}
+		}
+		series = append(series, point)
+	}
+	return series, nil
+}
+
+// GetProjectUsageSeries - GET /api/admin/projects/:id/usage/series?interval=hour|day&from=&to=
+// from and to take RFC 3339 timestamps or YYYY-MM-DD dates. Defaults: the last 24 hours by hour,
+// the last 30 days by day.
+func GetProjectUsageSeries(c *gin.Context) {
+	interval := c.DefaultQuery("interval", usageIntervalDay)
+	if interval != usageIntervalHour && interval != usageIntervalDay {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "interval must be hour or day")
+		return
+	}
+	size := usageBucketSize(interval)
+
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := parseUsageTime(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "to must be an RFC 3339 time or YYYY-MM-DD date")
+			return
+		}
+		to = parsed
+	}
+	// Include the bucket "to" falls in
+	to = to.Truncate(size).Add(size)
+
+	from := to.Add(-24 * time.Hour)
+	if interval == usageIntervalDay {
+		from = to.AddDate(0, 0, -30)
+	}
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := parseUsageTime(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "from must be an RFC 3339 time or YYYY-MM-DD date")
+			return
+		}
+		from = parsed.Truncate(size)
+	}
+	if !from.Before(to) {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "from must be before to")
+		return
+	}
+	if to.Sub(from)/size > maxUsageBuckets {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed,
+			fmt.Sprintf("range too large: at most %d %s buckets", maxUsageBuckets, interval))
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := getProjectByID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	series, err := usageSeries(ctx, project.ProjectID, interval, from, to)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to aggregate usage")
+		return
+	}
+
+	totals := UsagePoint{}
+	for _, point := range series {
+		totals.Tokens += point.Tokens
+		totals.Cost += point.Cost
+		totals.Requests += point.Requests
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": project.ProjectID,
+		"interval":   interval,
+		"from":       from,
+		"to":         to,
+		"series":     series,
+		"totals": gin.H{
+			"tokens":   totals.Tokens,
+			"cost":     totals.Cost,
+			"requests": totals.Requests,
+		},
+	})
+}
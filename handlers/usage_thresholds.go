@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// defaultUsageWarningThresholds - Percentages of the monthly limit that trigger a notification;
+// 100 and above are "limit reached", the rest usage warnings
+var defaultUsageWarningThresholds = []int{80, 100}
+
+// maxUsageWarningThresholds - Most thresholds a project may define
+const maxUsageWarningThresholds = 10
+
+// usageWarningThresholds - The project's own thresholds, else its plan's
+// (USAGE_WARNING_THRESHOLDS_<PLAN>, e.g. USAGE_WARNING_THRESHOLDS_TRIAL=50,80,100), else
+// USAGE_WARNING_THRESHOLDS, else the defaults
+func usageWarningThresholds(project *models.Project) []int {
+	if len(project.UsageWarningThresholds) > 0 {
+		return normalizeThresholds(project.UsageWarningThresholds)
+	}
+
+	plan := project.Plan
+	if plan == "" {
+		plan = models.PlanPaid
+	}
+	for _, name := range []string{"USAGE_WARNING_THRESHOLDS_" + strings.ToUpper(plan), "USAGE_WARNING_THRESHOLDS"} {
+		if thresholds := parseThresholds(os.Getenv(name)); len(thresholds) > 0 {
+			return thresholds
+		}
+	}
+	return defaultUsageWarningThresholds
+}
+
+// parseThresholds - Comma-separated percentages; invalid entries are skipped
+func parseThresholds(value string) []int {
+	var thresholds []int
+	for _, part := range strings.Split(value, ",") {
+		if threshold, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	return normalizeThresholds(thresholds)
+}
+
+// normalizeThresholds - Sorted, without duplicates or values outside 1-100
+func normalizeThresholds(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	thresholds := make([]int, 0, len(values))
+	for _, value := range values {
+		if value < 1 || value > 100 || seen[value] {
+			continue
+		}
+		seen[value] = true
+		thresholds = append(thresholds, value)
+	}
+	sort.Ints(thresholds)
+	return thresholds
+}
+
+// validateThresholds - Check thresholds sent by an admin before they are stored
+func validateThresholds(values []int) error {
+	if len(values) > maxUsageWarningThresholds {
+		return fmt.Errorf("usage_warning_thresholds may have at most %d entries", maxUsageWarningThresholds)
+	}
+	for _, value := range values {
+		if value < 1 || value > 100 {
+			return fmt.Errorf("usage_warning_thresholds must be percentages between 1 and 100")
+		}
+	}
+	return nil
+}
+
+// crossedThresholds - Thresholds passed when usage went from before to after tokens
+func crossedThresholds(thresholds []int, before, after, limit int64) []int {
+	if limit <= 0 {
+		return nil
+	}
+	var crossed []int
+	for _, threshold := range thresholds {
+		mark := limit * int64(threshold) / 100
+		if before < mark && after >= mark {
+			crossed = append(crossed, threshold)
+		}
+	}
+	return crossed
+}
+
+// notifyUsageThresholds - One notification per threshold crossed by a usage increase, at most once
+// per threshold per usage cycle (since the last reset or renewal). project holds the usage after
+// the increase; before is the total until then.
+func notifyUsageThresholds(project models.Project, before int64) {
+	crossed := crossedThresholds(usageWarningThresholds(&project), before, project.TotalTokensUsed, project.MonthlyTokenLimit)
+	if len(crossed) == 0 {
+		return
+	}
+
+	cycleStart, err := config.LastUsageReset(project.ID)
+	if err != nil {
+		log.Printf("⚠️ Failed to find the usage cycle of %s: %v", project.ProjectID, err)
+		return
+	}
+	if cycleStart.Before(project.StartDate) {
+		cycleStart = project.StartDate
+	}
+	if cycleStart.IsZero() {
+		cycleStart = time.Now().AddDate(0, -1, 0)
+	}
+
+	for _, threshold := range crossed {
+		notificationType := config.NotificationUsageWarning
+		message := fmt.Sprintf("Token usage passed %d%% of the monthly limit for project: %s", threshold, project.Name)
+		if threshold >= 100 {
+			notificationType = config.NotificationMonthlyLimit
+			message = fmt.Sprintf("Monthly token limit reached for project: %s", project.Name)
+		}
+
+		details := bson.M{"threshold": threshold}
+		sent, err := config.WasNotificationSentSince(project.ID, notificationType, cycleStart, details)
+		if err != nil || sent {
+			continue
+		}
+		config.LogNotificationDetails(project.ID, notificationType, message, details)
+		log.Printf("⚠️ Usage threshold %d%% notification logged for project: %s", threshold, project.Name)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestUsageWarningThresholds(t *testing.T) {
+	t.Setenv("USAGE_WARNING_THRESHOLDS", "")
+	t.Setenv("USAGE_WARNING_THRESHOLDS_TRIAL", "")
+	t.Setenv("USAGE_WARNING_THRESHOLDS_PAID", "")
+
+	if got := usageWarningThresholds(&models.Project{}); !reflect.DeepEqual(got, defaultUsageWarningThresholds) {
+		t.Errorf("no configuration = %v, want the defaults", got)
+	}
+
+	t.Setenv("USAGE_WARNING_THRESHOLDS", "90, 75,oops,100")
+	if got := usageWarningThresholds(&models.Project{Plan: models.PlanTrial}); !reflect.DeepEqual(got, []int{75, 90, 100}) {
+		t.Errorf("global setting = %v, want it parsed and sorted", got)
+	}
+
+	t.Setenv("USAGE_WARNING_THRESHOLDS_TRIAL", "50,80,100")
+	if got := usageWarningThresholds(&models.Project{Plan: models.PlanTrial}); !reflect.DeepEqual(got, []int{50, 80, 100}) {
+		t.Errorf("trial plan = %v, want the plan's own setting", got)
+	}
+	// Projects without a plan are paid projects
+	t.Setenv("USAGE_WARNING_THRESHOLDS_PAID", "95")
+	if got := usageWarningThresholds(&models.Project{}); !reflect.DeepEqual(got, []int{95}) {
+		t.Errorf("legacy project = %v, want the paid plan's setting", got)
+	}
+
+	project := &models.Project{Plan: models.PlanTrial, UsageWarningThresholds: []int{100, 60, 60}}
+	if got := usageWarningThresholds(project); !reflect.DeepEqual(got, []int{60, 100}) {
+		t.Errorf("project override = %v, want it normalized", got)
+	}
+}
+
+func TestNormalizeAndValidateThresholds(t *testing.T) {
+	if got := normalizeThresholds([]int{100, 0, 50, 101, 50, -5, 1}); !reflect.DeepEqual(got, []int{1, 50, 100}) {
+		t.Errorf("normalizeThresholds = %v", got)
+	}
+	if got := parseThresholds(" , x"); len(got) != 0 {
+		t.Errorf("parseThresholds of nothing valid = %v", got)
+	}
+
+	if err := validateThresholds([]int{50, 80, 100}); err != nil {
+		t.Errorf("valid thresholds rejected: %v", err)
+	}
+	if err := validateThresholds(nil); err != nil {
+		t.Errorf("empty thresholds (restore the default) rejected: %v", err)
+	}
+	for _, values := range [][]int{{0}, {101}, {1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}} {
+		if err := validateThresholds(values); err == nil {
+			t.Errorf("validateThresholds(%v) accepted", values)
+		}
+	}
+}
+
+func TestCrossedThresholds(t *testing.T) {
+	thresholds := []int{50, 80, 100}
+
+	tests := []struct {
+		name          string
+		before, after int64
+		limit         int64
+		want          []int
+	}{
+		{"below every threshold", 100, 400, 1000, nil},
+		{"crosses one", 400, 500, 1000, []int{50}},
+		{"jumps several", 400, 1200, 1000, []int{50, 80, 100}},
+		{"already past", 850, 900, 1000, nil},
+		{"lands exactly on the limit", 990, 1000, 1000, []int{100}},
+		{"no limit", 0, 5000, 0, nil},
+	}
+	for _, tt := range tests {
+		if got := crossedThresholds(thresholds, tt.before, tt.after, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: crossedThresholds = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNotifyUsageThresholds(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	project := models.Project{
+		ID: primitive.NewObjectID(), ProjectID: "proj_usage", Name: "Acme",
+		MonthlyTokenLimit: 1000, UsageWarningThresholds: []int{50, 80, 100},
+		StartDate: time.Now().AddDate(0, 0, -10),
+	}
+	count := func(filter bson.M) int64 {
+		filter["project_id"] = project.ID
+		n, _ := config.GetNotificationsCollection().CountDocuments(ctx, filter)
+		return n
+	}
+
+	project.TotalTokensUsed = 850
+	notifyUsageThresholds(project, 400)
+	if count(bson.M{"type": config.NotificationUsageWarning}) != 2 || count(bson.M{"threshold": 80}) != 1 {
+		t.Errorf("crossing 50%% and 80%% logged %d usage warnings, want one each", count(bson.M{"type": config.NotificationUsageWarning}))
+	}
+
+	// Falling back under a threshold and crossing it again in the same cycle stays quiet
+	notifyUsageThresholds(project, 700)
+	project.TotalTokensUsed = 1000
+	notifyUsageThresholds(project, 900)
+	if count(bson.M{"type": config.NotificationUsageWarning}) != 2 || count(bson.M{"type": config.NotificationMonthlyLimit, "threshold": 100}) != 1 {
+		t.Errorf("got %d usage warnings and %d limit notifications, want 2 and 1",
+			count(bson.M{"type": config.NotificationUsageWarning}), count(bson.M{"type": config.NotificationMonthlyLimit}))
+	}
+
+	// A usage reset starts a new cycle
+	time.Sleep(10 * time.Millisecond)
+	if _, err := config.RecordNotification(project.ID, "usage_reset", "Usage reset"); err != nil {
+		t.Fatalf("record reset: %v", err)
+	}
+	project.TotalTokensUsed = 600
+	notifyUsageThresholds(project, 0)
+	if got := count(bson.M{"threshold": 50}); got != 2 {
+		t.Errorf("50%% notified %d times across two cycles, want 2", got)
+	}
+}
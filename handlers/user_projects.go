@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// userProjectExcludedFields - Heavy or secret fields never loaded for the user project list
+var userProjectExcludedFields = bson.M{
+	"openai_api_key":       0,
+	"pdf_content":          0,
+	"pdf_files.content":    0,
+	"pdf_files.embeddings": 0,
+}
+
+// GetUserProjects - GET /api/user/projects?page=1&limit=20&status=active
+// Projects owned by the signed-in user, in the admin list's summary shape without sensitive fields.
+func GetUserProjects(c *gin.Context) {
+	userID := c.GetString("user_id")
+	email := c.GetString("user_email")
+	if userID == "" && email == "" {
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	page, limit := parsePagination(c, 20)
+	status := c.Query("status")
+
+	statusFilter := bson.M{}
+	if status != "" && status != "deleted" {
+		statusFilter["status"] = status
+	}
+	filter, err := requestScope(c).projects(statusFilter)
+	if err != nil {
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
+		return
+	}
+
+	collection := config.GetProjectsCollection()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to count projects")
+		return
+	}
+
+	opts := options.Find().
+		SetProjection(userProjectExcludedFields).
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to get projects")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var projects []models.Project
+	if err := cursor.All(ctx, &projects); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse projects")
+		return
+	}
+
+	totalPages := pageCount(totalCount, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": NewProjectResponses(projects),
+		"pagination": gin.H{
+			"current_page": page,
+			"total_pages":  totalPages,
+			"total_count":  totalCount,
+			"limit":        limit,
+			"has_next":     page < totalPages,
+			"has_prev":     page > 1,
+		},
+		"filters": gin.H{
+			"status": status,
+		},
+	})
+}
+
+// userProjectsFilter - Projects the user owns: owner_id is the user, or, for projects without an
+// owner yet, client_id is the user's email or id (same rule as models.Project.IsOwnedBy)
+func userProjectsFilter(userID, email string) bson.M {
+	clients := []string{}
+	if userID != "" {
+		clients = append(clients, userID)
+	}
+	if email != "" {
+		clients = append(clients, email, strings.ToLower(email))
+	}
+
+	or := []bson.M{{
+		"owner_id":  bson.M{"$in": []interface{}{"", nil}},
+		"client_id": bson.M{"$in": clients},
+	}}
+	if userID != "" {
+		or = append(or, bson.M{"owner_id": userID})
+	}
+
+	return bson.M{
+		"$or":       or,
+		"status":    bson.M{"$ne": "deleted"},
+		"is_active": true,
+	}
+}
+
+// GetUserProject - GET /api/user/projects/:id (behind ProjectOwnershipMiddleware)
+func GetUserProject(c *gin.Context) {
+	project, ok := c.MustGet("project").(*models.Project)
+	if !ok {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Invalid project data in context")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project": NewProjectResponse(project, true),
+	})
+}
+
+// resolveProjectOwner - Owner for a new project: ownerID if given (must be an existing user),
+// else the registered user whose email is clientEmail, else none
+func resolveProjectOwner(ownerID, clientEmail string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	users := config.GetCollection("users")
+
+	if ownerID != "" {
+		objectID, err := primitive.ObjectIDFromHex(ownerID)
+		if err != nil {
+			return "", fmt.Errorf("owner_id is not a valid user id")
+		}
+		count, err := users.CountDocuments(ctx, bson.M{"_id": objectID})
+		if err != nil || count == 0 {
+			return "", fmt.Errorf("owner_id does not match a user")
+		}
+		return ownerID, nil
+	}
+
+	if clientEmail == "" {
+		return "", nil
+	}
+
+	var user models.User
+	clientEmail = strings.TrimSpace(clientEmail)
+	err := users.FindOne(ctx, bson.M{"email": bson.M{"$in": []string{clientEmail, strings.ToLower(clientEmail)}}}).Decode(&user)
+	if err != nil {
+		return "", nil
+	}
+	return user.ID.Hex(), nil
+}
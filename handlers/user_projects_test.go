@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// userProjectsRouter - GetUserProjects behind the given identity (empty values are left unset)
+func userProjectsRouter(userID, email string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/user/projects", func(c *gin.Context) {
+		if userID != "" {
+			c.Set("user_id", userID)
+		}
+		if email != "" {
+			c.Set("user_email", email)
+		}
+	}, GetUserProjects)
+	return r
+}
+
+func TestGetUserProjectsRequiresUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	userProjectsRouter("", "").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/projects", nil))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), ErrCodeUnauthorized) {
+		t.Errorf("got %d %s, want 401", w.Code, w.Body)
+	}
+}
+
+func TestGetUserProjects(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	expiry := time.Now().AddDate(0, 1, 0)
+	project := func(projectID, clientID, status string, active bool, created time.Time) models.Project {
+		return models.Project{
+			ProjectID: projectID, Name: projectID, ClientID: clientID, Status: status, IsActive: active,
+			ExpiryDate: expiry, CreatedAt: created, MonthlyTokenLimit: 1000,
+			OpenAIAPIKey: "sk-secret-key", PDFContent: "confidential handbook text",
+		}
+	}
+	now := time.Now()
+	for _, p := range []models.Project{
+		project("by_email", "Client@Example.com", "active", true, now.Add(-3*time.Hour)),
+		project("by_id", "user_1", "suspended", true, now.Add(-2*time.Hour)),
+		project("newest", "client@example.com", "active", true, now.Add(-time.Hour)),
+		project("deleted", "client@example.com", "deleted", true, now),
+		project("deactivated", "client@example.com", "active", false, now),
+		project("someone_else", "other@example.com", "active", true, now),
+	} {
+		if _, err := config.GetProjectsCollection().InsertOne(ctx, p); err != nil {
+			t.Fatalf("insert %s: %v", p.ProjectID, err)
+		}
+	}
+
+	r := userProjectsRouter("user_1", "Client@Example.com")
+	list := func(query string) (ids []string, body string, pagination map[string]interface{}) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/projects"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d: %s", query, w.Code, w.Body)
+		}
+		var resp struct {
+			Projects []struct {
+				ProjectID string `json:"project_id"`
+			} `json:"projects"`
+			Pagination map[string]interface{} `json:"pagination"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		for _, p := range resp.Projects {
+			ids = append(ids, p.ProjectID)
+		}
+		return ids, w.Body.String(), resp.Pagination
+	}
+
+	ids, body, pagination := list("")
+	if strings.Join(ids, ",") != "newest,by_id,by_email" {
+		t.Errorf("projects = %v, want the user's live projects, newest first", ids)
+	}
+	if pagination["total_count"] != 3.0 {
+		t.Errorf("total_count = %v, want 3", pagination["total_count"])
+	}
+	for _, secret := range []string{"sk-secret-key", "confidential handbook text"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response leaks %q", secret)
+		}
+	}
+
+	if ids, _, _ := list("?status=suspended"); strings.Join(ids, ",") != "by_id" {
+		t.Errorf("status=suspended: projects = %v, want by_id", ids)
+	}
+	// Deleted projects stay hidden even when asked for
+	if ids, _, _ := list("?status=deleted"); len(ids) != 3 {
+		t.Errorf("status=deleted: projects = %v, want the status filter ignored", ids)
+	}
+
+	ids, _, pagination = list("?page=2&limit=2")
+	if strings.Join(ids, ",") != "by_email" || pagination["has_prev"] != true || pagination["has_next"] != false {
+		t.Errorf("page 2: projects = %v, pagination = %v", ids, pagination)
+	}
+}
+
+func TestResolveProjectOwner(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	user := models.User{ID: primitive.NewObjectID(), Email: "client@example.com"}
+	if _, err := config.GetCollection("users").InsertOne(ctx, user); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	tests := []struct {
+		name, ownerID, clientEmail string
+		want, wantErr              string
+	}{
+		{"explicit owner", user.ID.Hex(), "", user.ID.Hex(), ""},
+		{"explicit owner wins over the email", user.ID.Hex(), "someone@example.com", user.ID.Hex(), ""},
+		{"malformed owner id", "not-an-id", "", "", "not a valid user id"},
+		{"unknown owner id", primitive.NewObjectID().Hex(), "", "", "does not match a user"},
+		{"registered client email in another case", "", " Client@Example.com ", user.ID.Hex(), ""},
+		{"client email in stored case", "", "client@example.com", user.ID.Hex(), ""},
+		{"unregistered client email", "", "stranger@example.com", "", ""},
+		{"neither", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveProjectOwner(tt.ownerID, tt.clientEmail)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("owner = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
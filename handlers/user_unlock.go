@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// UnlockUser - POST /api/admin/users/:id/unlock
+// Clears failed login attempts and any lockout, as models.User.ResetLoginAttempts does on a
+// successful login. Unlocking a user who is not locked is a no-op that still succeeds.
+func UnlockUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid user ID")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	admin := c.GetString("user_email")
+	now := time.Now()
+
+	var before models.User
+	err = config.GetCollection("users").FindOneAndUpdate(ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set": bson.M{
+				"login_attempts": 0,
+				"unlocked_at":    now,
+				"unlocked_by":    admin,
+				"updated_at":     now,
+			},
+			"$unset": bson.M{"locked_until": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to unlock user")
+		return
+	}
+
+	// Audit trail: who unlocked whom, and what the lockout state was
+	config.RecordNotification(primitive.NilObjectID, config.NotificationUserUnlocked, fmt.Sprintf(
+		"User %s unlocked by %s (%d failed attempts, locked: %t)",
+		before.Email, admin, before.LoginAttempts, before.IsLocked()))
+	log.Printf("🔓 User %s unlocked by %s", before.Email, admin)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "User unlocked",
+		"user_id":        userID.Hex(),
+		"was_locked":     before.IsLocked(),
+		"login_attempts": 0,
+		"unlocked_at":    now,
+	})
+}
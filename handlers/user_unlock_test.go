@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func unlockUser(userID string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/users/:id/unlock", func(c *gin.Context) { c.Set("user_email", "admin@example.com") }, UnlockUser)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/"+userID+"/unlock", nil))
+	return w
+}
+
+func TestUnlockUserRejectsBadID(t *testing.T) {
+	// Refused before the database is touched
+	w := unlockUser("user_1")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid user ID") {
+		t.Errorf("got %d %s, want 400", w.Code, w.Body)
+	}
+}
+
+func TestUnlockUser(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	locked := models.User{ID: primitive.NewObjectID(), Email: "locked@example.com", IsActive: true, EmailVerified: true, LoginAttempts: models.MaxLoginAttempts, LockedUntil: time.Now().Add(time.Hour)}
+	fine := models.User{ID: primitive.NewObjectID(), Email: "fine@example.com", LoginAttempts: 1}
+	if _, err := config.GetCollection("users").InsertMany(ctx, []interface{}{locked, fine}); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+
+	var resp struct {
+		WasLocked     bool `json:"was_locked"`
+		LoginAttempts int  `json:"login_attempts"`
+	}
+	w := unlockUser(locked.ID.Hex())
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || !resp.WasLocked || resp.LoginAttempts != 0 {
+		t.Errorf("got %d %+v, want the locked user unlocked", w.Code, resp)
+	}
+
+	var stored models.User
+	config.GetCollection("users").FindOne(ctx, bson.M{"_id": locked.ID}).Decode(&stored)
+	if stored.IsLocked() || stored.LoginAttempts != 0 || !stored.CanLogin() {
+		t.Errorf("stored user = %+v, want the lockout cleared", stored)
+	}
+	var audit bson.M
+	err := config.GetNotificationsCollection().FindOne(ctx, bson.M{"type": config.NotificationUserUnlocked}).Decode(&audit)
+	if err != nil || !strings.Contains(audit["message"].(string), "locked@example.com unlocked by admin@example.com (5 failed attempts, locked: true)") {
+		t.Errorf("audit notification = %v (%v)", audit, err)
+	}
+
+	// Unlocking a user who isn't locked still succeeds
+	w = unlockUser(fine.ID.Hex())
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.WasLocked {
+		t.Errorf("got %d %+v, want success with was_locked false", w.Code, resp)
+	}
+
+	if w := unlockUser(primitive.NewObjectID().Hex()); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), ErrCodeUserNotFound) {
+		t.Errorf("unknown user: got %d %s, want 404", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// VisitorErasure - What an erase request removed. Usage logs are kept for billing but cut loose
+// from the visitor's sessions and messages.
+type VisitorErasure struct {
+	Users           int64 `json:"users_deleted"`
+	Sessions        int64 `json:"sessions_deleted"`
+	Messages        int64 `json:"messages_deleted"`
+	ArchivedMsgs    int64 `json:"archived_messages_deleted"`
+	KnowledgeGaps   int64 `json:"knowledge_gaps_deleted"`
+	ModerationFlags int64 `json:"moderation_flags_deleted"`
+	UsageLogs       int64 `json:"usage_logs_anonymized"`
+}
+
+// EraseChatUser - POST /api/admin/projects/:id/users/:userId/erase
+// Data-subject erasure: deletes the widget user, their messages and the sessions only they took
+// part in (with archived messages, knowledge gaps and moderation flags from those sessions) and
+// anonymizes their usage logs. Other visitors' messages, including in shared sessions, are untouched. The audit entry
+// records the user ID and counts only, never the erased personal data.
+func EraseChatUser(c *gin.Context) {
+	userObjID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid user ID")
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeProjectNotFound, "Project not found")
+		return
+	}
+
+	// Embed registrations store the project's ObjectID hex, widget chats its project_id
+	var user models.ChatUser
+	err = config.GetChatUsersCollection().FindOne(ctx, bson.M{
+		"_id":        userObjID,
+		"project_id": bson.M{"$in": []string{project.ID.Hex(), project.ProjectID}},
+	}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		respondError(c, http.StatusNotFound, ErrCodeUserNotFound, "User not found in this project")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to load user")
+		return
+	}
+
+	erased, err := eraseChatUserData(ctx, project.ProjectID, user.ID)
+	if err != nil {
+		log.Printf("❌ Erasure of chat user %s in %s failed: %v", user.ID.Hex(), project.ProjectID, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to erase user data; retry to finish")
+		return
+	}
+
+	admin := c.GetString("user_email")
+	config.LogNotification(project.ID, "visitor_erased", fmt.Sprintf(
+		"Chat user %s erased by %s: %d sessions, %d messages (%d archived), %d knowledge gaps and %d moderation flags deleted, %d usage logs anonymized",
+		user.ID.Hex(), admin, erased.Sessions, erased.Messages, erased.ArchivedMsgs, erased.KnowledgeGaps, erased.ModerationFlags, erased.UsageLogs))
+	log.Printf("🗑️ Chat user %s of %s erased by %s", user.ID.Hex(), project.ProjectID, admin)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "User data erased",
+		"user_id":   user.ID.Hex(),
+		"erased":    erased,
+		"erased_at": time.Now(),
+	})
+}
+
+// eraseChatUserData - Remove everything tied to one chat user. Whole sessions (with their
+// anonymous pre-sign-in messages, knowledge gaps and flags) are only removed when the user owns
+// them and no other chat user wrote in them; in shared sessions only the user's own messages go. The user
+// document goes last so a failed run can be retried with the same request until it completes.
+func eraseChatUserData(ctx context.Context, projectID string, userID primitive.ObjectID) (VisitorErasure, error) {
+	var erased VisitorErasure
+	userHex := userID.Hex()
+
+	sessionIDs, err := ownedSessionIDs(ctx, projectID, userHex)
+	if err != nil {
+		return erased, err
+	}
+
+	// The user's own messages, wherever they are, for the usage logs and flags pointing at them
+	messageIDs := []primitive.ObjectID{}
+	for _, collection := range []*mongo.Collection{config.GetChatMessagesCollection(), config.DB.Collection(chatMessagesArchiveCollection)} {
+		ids, err := collection.Distinct(ctx, "_id", bson.M{"project_id": projectID, "user_id": userHex})
+		if err != nil {
+			return erased, err
+		}
+		for _, id := range ids {
+			if objID, ok := id.(primitive.ObjectID); ok {
+				messageIDs = append(messageIDs, objID)
+			}
+		}
+	}
+
+	inSessions := bson.M{"project_id": projectID, "session_id": bson.M{"$in": sessionIDs}}
+	ownMessages := bson.M{"project_id": projectID, "$or": []bson.M{
+		{"user_id": userHex},
+		{"session_id": bson.M{"$in": sessionIDs}},
+	}}
+	aboutOwnMessages := bson.M{"project_id": projectID, "$or": []bson.M{
+		{"session_id": bson.M{"$in": sessionIDs}},
+		{"message_id": bson.M{"$in": messageIDs}},
+	}}
+
+	// Usage logs carry no personal data once unlinked, and billing history must still add up
+	result, err := config.GetOpenAIUsageLogsCollection().UpdateMany(ctx, aboutOwnMessages, bson.M{
+		"$unset": bson.M{"session_id": "", "message_id": ""},
+		"$set":   bson.M{"anonymized": true},
+	})
+	if err != nil {
+		return erased, err
+	}
+	erased.UsageLogs = result.ModifiedCount
+
+	deletions := []struct {
+		collection *mongo.Collection
+		filter     bson.M
+		count      *int64
+	}{
+		{config.GetKnowledgeGapsCollection(), inSessions, &erased.KnowledgeGaps},
+		{config.GetModerationFlagsCollection(), aboutOwnMessages, &erased.ModerationFlags},
+		{config.GetChatMessagesCollection(), ownMessages, &erased.Messages},
+		{config.DB.Collection(chatMessagesArchiveCollection), ownMessages, &erased.ArchivedMsgs},
+		{config.GetWidgetSessionsCollection(), inSessions, &erased.Sessions},
+		{config.GetChatUsersCollection(), bson.M{"_id": userID}, &erased.Users},
+	}
+	for _, deletion := range deletions {
+		result, err := deletion.collection.DeleteMany(ctx, deletion.filter)
+		if err != nil {
+			return erased, fmt.Errorf("%s: %w", deletion.collection.Name(), err)
+		}
+		*deletion.count = result.DeletedCount
+	}
+	return erased, nil
+}
+
+// chatUserIDPattern - user_id values naming a chat user; anonymous widget ids never match it
+const chatUserIDPattern = "^[0-9a-f]{24}$"
+
+// ownedSessionIDs - Sessions recorded for the user in which no other chat user sent a message
+func ownedSessionIDs(ctx context.Context, projectID, userHex string) ([]string, error) {
+	cursor, err := config.GetWidgetSessionsCollection().Find(ctx,
+		bson.M{"project_id": projectID, "user_id": userHex},
+		options.Find().SetProjection(bson.M{"session_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var sessions []models.WidgetSession
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	candidates := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if session.SessionID != "" {
+			candidates = append(candidates, session.SessionID)
+		}
+	}
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	shared := map[string]bool{}
+	for _, collection := range []*mongo.Collection{config.GetChatMessagesCollection(), config.DB.Collection(chatMessagesArchiveCollection)} {
+		ids, err := collection.Distinct(ctx, "session_id", bson.M{
+			"project_id": projectID,
+			"session_id": bson.M{"$in": candidates},
+			"user_id":    bson.M{"$regex": chatUserIDPattern, "$ne": userHex},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if sessionID, ok := id.(string); ok {
+				shared[sessionID] = true
+			}
+		}
+	}
+
+	owned := make([]string, 0, len(candidates))
+	for _, sessionID := range candidates {
+		if !shared[sessionID] {
+			owned = append(owned, sessionID)
+		}
+	}
+	return owned, nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+)
+
+// useTestDatabase - Point config.DB at a throwaway database on MONGODB_TEST_URI, dropped when the
+// test ends. Tests that need MongoDB are skipped when the variable is unset.
+func useTestDatabase(t *testing.T) context.Context {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	previous := config.DB
+	config.DB = client.Database(fmt.Sprintf("troika_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		config.DB.Drop(context.Background())
+		client.Disconnect(context.Background())
+		config.DB = previous
+	})
+	return ctx
+}
+
+func TestEraseChatUserDataKeepsOtherVisitorsData(t *testing.T) {
+	ctx := useTestDatabase(t)
+
+	const projectID = "proj_erase"
+	user := primitive.NewObjectID()
+	other := primitive.NewObjectID()
+
+	insert := func(collection *mongo.Collection, docs ...interface{}) {
+		t.Helper()
+		if _, err := collection.InsertMany(ctx, docs); err != nil {
+			t.Fatalf("insert into %s: %v", collection.Name(), err)
+		}
+	}
+	insert(config.GetChatUsersCollection(),
+		bson.M{"_id": user, "project_id": projectID, "email": "erase@example.com"},
+		bson.M{"_id": other, "project_id": projectID, "email": "keep@example.com"},
+	)
+	insert(config.GetWidgetSessionsCollection(),
+		bson.M{"session_id": "own", "project_id": projectID, "user_id": user.Hex()},
+		bson.M{"session_id": "shared", "project_id": projectID, "user_id": user.Hex()},
+		bson.M{"session_id": "theirs", "project_id": projectID, "user_id": other.Hex()},
+	)
+	sharedOther := primitive.NewObjectID()
+	insert(config.GetChatMessagesCollection(),
+		bson.M{"project_id": projectID, "session_id": "own", "user_id": user.Hex(), "message": "mine"},
+		bson.M{"project_id": projectID, "session_id": "own", "user_id": "", "message": "mine before sign-in"},
+		bson.M{"project_id": projectID, "session_id": "shared", "user_id": user.Hex(), "message": "mine too"},
+		bson.M{"_id": sharedOther, "project_id": projectID, "session_id": "shared", "user_id": other.Hex(), "message": "theirs"},
+		bson.M{"project_id": projectID, "session_id": "theirs", "user_id": other.Hex(), "message": "theirs too"},
+	)
+	insert(config.GetKnowledgeGapsCollection(),
+		bson.M{"project_id": projectID, "session_id": "own", "question": "mine"},
+		bson.M{"project_id": projectID, "session_id": "shared", "question": "theirs"},
+	)
+	insert(config.GetModerationFlagsCollection(),
+		bson.M{"project_id": projectID, "session_id": "shared", "message_id": sharedOther, "content": "theirs"},
+	)
+
+	erased, err := eraseChatUserData(ctx, projectID, user)
+	if err != nil {
+		t.Fatalf("eraseChatUserData: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		collection *mongo.Collection
+		filter     bson.M
+		want       int64
+	}{
+		{"erased user", config.GetChatUsersCollection(), bson.M{"_id": user}, 0},
+		{"other user", config.GetChatUsersCollection(), bson.M{"_id": other}, 1},
+		{"own session", config.GetWidgetSessionsCollection(), bson.M{"session_id": "own"}, 0},
+		{"shared session", config.GetWidgetSessionsCollection(), bson.M{"session_id": "shared"}, 1},
+		{"other's session", config.GetWidgetSessionsCollection(), bson.M{"session_id": "theirs"}, 1},
+		{"messages in own session", config.GetChatMessagesCollection(), bson.M{"session_id": "own"}, 0},
+		{"own message in shared session", config.GetChatMessagesCollection(), bson.M{"session_id": "shared", "user_id": user.Hex()}, 0},
+		{"other's message in shared session", config.GetChatMessagesCollection(), bson.M{"session_id": "shared", "user_id": other.Hex()}, 1},
+		{"other's own session messages", config.GetChatMessagesCollection(), bson.M{"session_id": "theirs"}, 1},
+		{"knowledge gap in own session", config.GetKnowledgeGapsCollection(), bson.M{"session_id": "own"}, 0},
+		{"knowledge gap in shared session", config.GetKnowledgeGapsCollection(), bson.M{"session_id": "shared"}, 1},
+		{"flag on other's message", config.GetModerationFlagsCollection(), bson.M{"message_id": sharedOther}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := tt.collection.CountDocuments(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("count: %v", err)
+			}
+			if count != tt.want {
+				t.Errorf("%d documents left, want %d", count, tt.want)
+			}
+		})
+	}
+
+	if erased.Messages != 3 || erased.Sessions != 1 || erased.Users != 1 {
+		t.Errorf("unexpected erasure counts: %+v", erased)
+	}
+}
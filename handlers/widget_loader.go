@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const widgetScriptPath = "./static/widget.js"
+
+// widgetVersionedMaxAge - ?v=<hash> URLs never change, so they can be cached for a year
+const widgetVersionedMaxAge = 365 * 24 * time.Hour
+
+// widgetVersionCache - Hash of the /widget.js body, recomputed when widget.js or the API URL changes
+var widgetVersionCache struct {
+	sync.Mutex
+	modTime time.Time
+	size    int64
+	apiURL  string
+	version string
+}
+
+// widgetScriptDefaults - window.TroikaChatbotDefaults injected into /widget.js
+func widgetScriptDefaults() map[string]interface{} {
+	return map[string]interface{}{
+		"apiUrl": getDomain() + "/api",
+	}
+}
+
+// widgetScriptVersion - Short content hash of the exact bytes /widget.js serves, injected
+// defaults included ("" if static/widget.js is missing)
+func widgetScriptVersion() string {
+	info, err := os.Stat(widgetScriptPath)
+	if err != nil {
+		return ""
+	}
+	defaults := widgetScriptDefaults()
+	apiURL := defaults["apiUrl"].(string)
+
+	widgetVersionCache.Lock()
+	defer widgetVersionCache.Unlock()
+
+	if widgetVersionCache.version != "" && info.ModTime().Equal(widgetVersionCache.modTime) &&
+		info.Size() == widgetVersionCache.size && apiURL == widgetVersionCache.apiURL {
+		return widgetVersionCache.version
+	}
+
+	body, err := widgetScriptBody(defaults)
+	if err != nil {
+		return ""
+	}
+
+	widgetVersionCache.modTime = info.ModTime()
+	widgetVersionCache.size = info.Size()
+	widgetVersionCache.apiURL = apiURL
+	widgetVersionCache.version = widgetBodyVersion(body)
+	return widgetVersionCache.version
+}
+
+// widgetScriptBody - static/widget.js prefixed with window.TroikaChatbotDefaults
+func widgetScriptBody(defaults map[string]interface{}) ([]byte, error) {
+	script, err := os.ReadFile(widgetScriptPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// json.Marshal escapes <, > and &, so the values are safe inside a script
+	prelude, err := json.Marshal(defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	body.WriteString("window.TroikaChatbotDefaults = ")
+	body.Write(prelude)
+	body.WriteString(";\n")
+	body.Write(script)
+	return body.Bytes(), nil
+}
+
+// widgetBodyVersion - The ?v= value for a served widget body
+func widgetBodyVersion(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:6])
+}
+
+// versionedWidgetURL - baseURL/widget.js pinned to the current content hash
+func versionedWidgetURL(baseURL string) string {
+	if version := widgetScriptVersion(); version != "" {
+		return baseURL + "/widget.js?v=" + version
+	}
+	return baseURL + "/widget.js"
+}
+
+// WidgetScriptURL - Cache-busting widget.js URL for embed snippets
+func WidgetScriptURL() string {
+	return versionedWidgetURL(getDomain())
+}
+
+// ServeWidgetScript - GET /widget.js[?v=<hash>]
+// Serves static/widget.js with the API base URL injected, so embeds never hardcode it.
+func ServeWidgetScript(c *gin.Context) {
+	serveWidgetScript(c, widgetScriptDefaults(), time.Hour)
+}
+
+// ServeProjectWidgetScript - GET /widget/:projectId.js[?v=<hash>]
+// Same script, bound to one project: it fetches that project's widget config and initializes itself.
+func ServeProjectWidgetScript(c *gin.Context) {
+	projectID := strings.TrimSuffix(c.Param("projectId"), ".js")
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	project, err := findProjectByAnyID(ctx, projectID)
+	if err != nil {
+		c.Data(http.StatusNotFound, "application/javascript; charset=utf-8",
+			[]byte("console.error('Troika Chatbot: unknown project');\n"))
+		return
+	}
+
+	serveWidgetScript(c, map[string]interface{}{
+		"apiUrl":    getDomain() + "/api",
+		"projectId": project.ProjectID,
+		"configUrl": getDomain() + "/api/embed/" + project.ProjectID + "/config",
+	}, 5*time.Minute)
+}
+
+// serveWidgetScript - Prefix widget.js with window.TroikaChatbotDefaults and send it with caching headers.
+// Requests whose ?v= matches the hash of this exact body are cached as immutable; anything else gets maxAge.
+func serveWidgetScript(c *gin.Context, defaults map[string]interface{}, maxAge time.Duration) {
+	body, err := widgetScriptBody(defaults)
+	if os.IsNotExist(err) {
+		log.Printf("⚠️ Widget.js file not found at %s: %v", widgetScriptPath, err)
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Widget file not found")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to build widget script: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to build widget script")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	if version := c.Query("v"); version != "" && version == widgetBodyVersion(body) {
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(widgetVersionedMaxAge.Seconds()))+", immutable")
+	} else {
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	}
+	c.Header("ETag", etag)
+	c.Header("Access-Control-Allow-Origin", "*") // Allow embedding on any domain
+	c.Header("Access-Control-Allow-Methods", "GET")
+	c.Header("Access-Control-Allow-Headers", "Content-Type")
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", body)
+}
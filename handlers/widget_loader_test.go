@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWidgetScriptVersionMatchesServedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Chdir("..") // static/widget.js is resolved from the repository root
+
+	r := gin.New()
+	r.GET("/widget.js", ServeWidgetScript)
+	serve := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/widget.js"+query, nil))
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		domain string
+	}{
+		{"first domain", "https://chat.example.com"},
+		{"domain change alters the served prelude", "https://bots.example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOMAIN", tt.domain)
+
+			version := widgetScriptVersion()
+			w := serve("?v=" + version)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.domain+"/api") {
+				t.Fatalf("served script does not carry %s", tt.domain)
+			}
+			if got := widgetBodyVersion(w.Body.Bytes()); got != version {
+				t.Errorf("widgetScriptVersion() = %s, served body hashes to %s", version, got)
+			}
+			if cache := w.Header().Get("Cache-Control"); !strings.Contains(cache, "immutable") {
+				t.Errorf("current version not cached as immutable: %q", cache)
+			}
+			if cache := serve("?v=stale").Header().Get("Cache-Control"); strings.Contains(cache, "immutable") {
+				t.Errorf("stale version cached as immutable: %q", cache)
+			}
+		})
+	}
+}
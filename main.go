@@ -57,66 +57,94 @@ func main() {
 	config.InitMongoDB()
 	defer config.CloseMongoDB()
 
+	// Every logged notification is also delivered to its project's (or the admins') channels
+	config.SetNotificationDispatcher(utils.DispatchNotification)
+
+	// Gemini is optional: projects on it fail over to their fallback provider without a key
+	config.InitGeminiIfConfigured()
+
 	// Create default admin user
 	if err := utils.CreateDefaultAdmin(); err != nil {
 		log.Printf("❌ Failed to create default admin: %v", err)
 	}
 
+	// Jobs that were running when the previous process stopped will never finish
+	if err := config.FailInterruptedMaintenanceJobs(); err != nil {
+		log.Printf("⚠️ Failed to clean up maintenance jobs: %v", err)
+	}
+
 	/*───────────────────────────────────────────*
 	| 2. GIN ENGINE & GLOBAL MIDDLEWARE         |
 	*───────────────────────────────────────────*/
 	gin.SetMode(os.Getenv("GIN_MODE")) // release | debug (default)
 	r := gin.New()
+	if err := middleware.ConfigureTrustedProxies(r); err != nil {
+		log.Fatalf("❌ Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Widget API CORS (per-project allowed domains) must run before the dashboard allowlist
+	r.Use(middleware.WidgetCORSMiddleware())
+
+	// Add this BEFORE your existing middleware in main.go
+	r.Use(func(c *gin.Context) {
+		if c.GetBool(middleware.WidgetCORSKey) {
+			c.Next()
+			return
+		}
 
-// Add this BEFORE your existing middleware in main.go
-r.Use(func(c *gin.Context) {
-    origin := c.Request.Header.Get("Origin")
-    
-    // Log CORS requests for debugging
-    log.Printf("🌐 CORS Request - Origin: %s, Method: %s, Path: %s", 
-        origin, c.Request.Method, c.Request.URL.Path)
-    
-    // Define allowed origins
-    allowedOrigins := []string{
-        "https://troika-admin-dashborad.onrender.com",
-        "https://troikacompletefrontend.onrender.com",
-        "https://admin.troikatech.com",
-        "http://localhost:3000",
-        "http://localhost:3001",
-    }
-    
-    // Check if origin is allowed
-    isAllowed := false
-    for _, allowedOrigin := range allowedOrigins {
-        if origin == allowedOrigin {
-            isAllowed = true
-            break
-        }
-    }
-    
-    if isAllowed || os.Getenv("ENVIRONMENT") == "development" {
-        c.Header("Access-Control-Allow-Origin", origin)
-        log.Printf("✅ CORS Allowed for origin: %s", origin)
-    }
-    
-    c.Header("Access-Control-Allow-Credentials", "true")
-    c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, Origin, Cache-Control")
-    
-    // 🔥 CRITICAL FIX: Include ALL HTTP methods including PATCH
-    c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS, HEAD")
-    
-    c.Header("Access-Control-Max-Age", "86400")
-    
-    // Handle preflight requests
-    if c.Request.Method == "OPTIONS" {
-        log.Printf("🔄 CORS Preflight request handled for %s", c.Request.URL.Path)
-        c.AbortWithStatus(http.StatusNoContent)
-        return
-    }
-    
-    c.Next()
-})
+		origin := c.Request.Header.Get("Origin")
 
+		// Log CORS requests for debugging
+		log.Printf("🌐 CORS Request - Origin: %s, Method: %s, Path: %s",
+			origin, c.Request.Method, c.Request.URL.Path)
+
+		// Define allowed origins
+		allowedOrigins := []string{
+			"https://troika-admin-dashborad.onrender.com",
+			"https://troikacompletefrontend.onrender.com",
+			"https://admin.troikatech.com",
+			"http://localhost:3000",
+			"http://localhost:3001",
+		}
+
+		// Check if origin is allowed
+		isAllowed := false
+		for _, allowedOrigin := range allowedOrigins {
+			if origin == allowedOrigin {
+				isAllowed = true
+				break
+			}
+		}
+
+		if isAllowed || os.Getenv("ENVIRONMENT") == "development" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			log.Printf("✅ CORS Allowed for origin: %s", origin)
+		}
+
+		c.Header("Access-Control-Allow-Credentials", "true")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, Origin, Cache-Control, X-Visitor-Token, X-CSRF-Token")
+		c.Header("Access-Control-Expose-Headers", "X-Visitor-Token, X-Request-Tokens, X-Request-Cost")
+
+		// 🔥 CRITICAL FIX: Include ALL HTTP methods including PATCH
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS, HEAD")
+
+		c.Header("Access-Control-Max-Age", "86400")
+
+		// Handle preflight requests
+		if c.Request.Method == "OPTIONS" {
+			log.Printf("🔄 CORS Preflight request handled for %s", c.Request.URL.Path)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	})
+
+	// Request deadlines (seconds, overridable via env)
+	requestTimeout := middleware.EnvTimeout("REQUEST_TIMEOUT_SECONDS", 10*time.Second)
+	lookupTimeout := middleware.EnvTimeout("LOOKUP_TIMEOUT_SECONDS", 2*time.Second)
+	chatTimeout := middleware.EnvTimeout("CHAT_TIMEOUT_SECONDS", 30*time.Second)
+	adminTimeout := middleware.EnvTimeout("ADMIN_TIMEOUT_SECONDS", 30*time.Second)
 
 	// Global middleware – order matters
 	r.Use(
@@ -125,8 +153,12 @@ r.Use(func(c *gin.Context) {
 		middleware.CORSMiddleware(),            // Your existing CORS middleware (backup)
 		middleware.SecurityHeadersMiddleware(), // basic hardening
 		middleware.RefreshTokenMiddleware(),    // auto refresh soon-to-expire JWT
+		middleware.Timeout(requestTimeout),     // default deadline; groups/routes override it
 	)
 
+	// Server-rendered embed pages (prechat / chat / error)
+	r.LoadHTMLGlob("templates/embed/*.html")
+
 	/*───────────────────────────────────────────*
 	| 3. PUBLIC ENDPOINTS                       |
 	*───────────────────────────────────────────*/
@@ -134,8 +166,16 @@ r.Use(func(c *gin.Context) {
 	{
 		// 🔥 ENHANCED: Health check with more detailed information
 		public.GET("/health", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"ok":        true,
+			// Not ready until the database has been verified at startup (see /readyz)
+			ready, reason := config.IsReady()
+			status := http.StatusOK
+			if !ready {
+				status = http.StatusServiceUnavailable
+			}
+			c.JSON(status, gin.H{
+				"ok":        ready,
+				"ready":     ready,
+				"reason":    reason,
 				"timestamp": time.Now(),
 				"service":   "troika-chatbot-api",
 				"version":   "1.0.0",
@@ -159,54 +199,68 @@ r.Use(func(c *gin.Context) {
 		})
 
 		// Authentication routes
-		public.POST("/auth/login", handlers.Login)
+		public.POST("/auth/login", middleware.LoginRateLimit(), handlers.Login)
 		public.POST("/auth/register", handlers.Register)
 		public.POST("/auth/logout", handlers.Logout)
 		public.GET("/auth/verify", handlers.VerifyToken)
+		public.GET("/auth/csrf", handlers.GetCSRFToken)
 
 		// Chat / widget (project-first). Extra middle-wares per request:
 		public.POST("/projects/:projectId/chat",
+			middleware.Timeout(chatTimeout),
+			middleware.MaintenanceGate(),
+			middleware.VisitorIdentity(),
 			middleware.SubscriptionValidator(),
+			middleware.AbuseDetector(),
 			middleware.TokenLimitValidator(),
 			middleware.RateLimitValidator(),
 			middleware.SubscriptionHeaders(),
 			handlers.ProjectChatMessage,
 		)
 
-		public.GET("/projects/:projectId/history", handlers.GetChatHistory)
+		// Welcome message, or a generated opener when the project enables proactive greetings
+		public.POST("/projects/:projectId/greeting",
+			middleware.Timeout(chatTimeout),
+			middleware.MaintenanceGate(),
+			middleware.VisitorIdentity(),
+			middleware.SubscriptionValidator(),
+			middleware.TokenLimitValidator(),
+			middleware.RateLimitValidator(),
+			handlers.ProjectGreeting,
+		)
+
+		// Visitor name/email captured mid-conversation when the project collects user info
+		public.POST("/projects/:projectId/lead", middleware.Timeout(lookupTimeout), middleware.MaintenanceGate(), middleware.VisitorIdentity(), handlers.CaptureLead)
+
+		// Visitor identity scopes these to the visitor's own sessions
+		public.GET("/projects/:projectId/history", middleware.VisitorIdentity(), handlers.GetChatHistory)
+		public.POST("/projects/:projectId/session/:sessionId/close", middleware.VisitorIdentity(), handlers.CloseWidgetSession)
 
 		// Subscription status (used by widget UI)
-		public.GET("/projects/:projectId/subscription", handlers.GetSubscriptionStatus)
-
-		// Embed routes
-		public.GET("/embed/:projectId", handlers.EmbedChat)
-		public.POST("/embed/:projectId/auth", handlers.EmbedAuth)
-		public.GET("/embed/:projectId/chat", handlers.IframeChatInterface)
-		public.GET("/embed/:projectId/auth", handlers.ShowEmbedAuth)
-		public.GET("/embed/health", handlers.EmbedHealth)
+		public.GET("/projects/:projectId/subscription", middleware.Timeout(lookupTimeout), handlers.GetSubscriptionStatus)
+		public.GET("/projects/:projectId/quota", middleware.Timeout(lookupTimeout), handlers.GetProjectQuota)
+
+		// Embed routes (health and version stay up during maintenance)
+		public.GET("/embed/:projectId", middleware.MaintenanceGate(), handlers.EmbedChat)
+		public.POST("/embed/:projectId/auth", middleware.MaintenanceGate(), handlers.EmbedAuth)
+		public.GET("/embed/:projectId/chat", middleware.MaintenanceGate(), handlers.IframeChatInterface)
+		public.GET("/embed/:projectId/auth", middleware.MaintenanceGate(), handlers.ShowEmbedAuth)
+		public.GET("/embed/:projectId/config", middleware.Timeout(lookupTimeout), middleware.MaintenanceGate(), handlers.EmbedConfig)
+		public.GET("/embed/health", middleware.Timeout(lookupTimeout), handlers.EmbedHealth)
+		public.GET("/embed/version", middleware.Timeout(lookupTimeout), handlers.EmbedHealth)
 	}
 
 	// 🔥 ENHANCED: Widget.js route with proper CORS headers for embedding
 	r.Static("/static", "./static")
-	r.GET("/widget.js", func(c *gin.Context) {
-		c.Header("Content-Type", "application/javascript")
-		c.Header("Cache-Control", "public, max-age=3600")
-		c.Header("Access-Control-Allow-Origin", "*") // Allow embedding on any domain
-		c.Header("Access-Control-Allow-Methods", "GET")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
-
-		// Check if widget.js file exists
-		if _, err := os.Stat("./static/widget.js"); os.IsNotExist(err) {
-			log.Printf("⚠️ Widget.js file not found at ./static/widget.js")
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Widget file not found",
-				"path":  "./static/widget.js",
-			})
-			return
-		}
+	r.GET("/widget.js", handlers.ServeWidgetScript)
+	r.GET("/widget/:projectId", middleware.Timeout(lookupTimeout), handlers.ServeProjectWidgetScript) // /widget/<projectId>.js
 
-		c.File("./static/widget.js")
-	})
+	// Readiness probe for load balancers
+	r.GET("/readyz", middleware.Timeout(lookupTimeout), handlers.Readiness)
+
+	// API documentation (OpenAPI 3, generated from the registered routes)
+	r.GET("/swagger", handlers.SwaggerUI)
+	r.GET("/swagger/doc.json", handlers.OpenAPISpec(r))
 
 	/*───────────────────────────────────────────*
 	| 4. AUTHENTICATED ROUTES (USER PANEL)      |
@@ -214,12 +268,17 @@ r.Use(func(c *gin.Context) {
 	user := r.Group("/api/user")
 	user.Use(
 		middleware.AuthMiddleware(), // require JWT
+		middleware.SessionValidationMiddleware(),
 		middleware.SubscriptionLogger(),
 	)
 	{
 		user.GET("/profile", handlers.GetUserProfile)
 		user.PUT("/profile", handlers.UpdateUserProfile)
 		user.POST("/change-password", handlers.ChangePassword)
+		user.GET("/projects", handlers.GetUserProjects)
+		user.GET("/projects/:id", middleware.ProjectOwnershipMiddleware("id"), handlers.GetUserProject)
+		user.POST("/projects/:id/pause", middleware.ProjectOwnershipMiddleware("id"), handlers.PauseProjectChat)
+		user.POST("/projects/:id/resume", middleware.ProjectOwnershipMiddleware("id"), handlers.ResumeProjectChat)
 	}
 
 	/*───────────────────────────────────────────*
@@ -227,21 +286,34 @@ r.Use(func(c *gin.Context) {
 	*───────────────────────────────────────────*/
 	admin := r.Group("/api/admin")
 	admin.Use(
-		middleware.AuthMiddleware(),  // JWT
-		middleware.AdminMiddleware(), // must be role = admin
+		middleware.Timeout(adminTimeout), // admin reports and bulk operations run longer
+		middleware.AuthMiddleware(),      // JWT
+		middleware.AdminMiddleware(),     // must be role = admin
+		middleware.SessionValidationMiddleware(),
 	)
 	{
 		// Dashboard & system
 		admin.GET("/dashboard", handlers.AdminDashboard)
 		admin.GET("/stats", handlers.GetSystemStats)
 		admin.GET("/notifications", handlers.GetNotificationHistory)
+		admin.GET("/reports/top-projects", handlers.GetTopProjects)
+		admin.GET("/maintenance", handlers.GetMaintenanceMode)
+		admin.PUT("/maintenance", handlers.SetMaintenanceMode)
+		admin.POST("/notifications/read-all", handlers.MarkAllNotificationsRead)
+		admin.POST("/notifications/:id/read", handlers.MarkNotificationRead)
+
+		// Accounts
+		admin.POST("/users/:id/unlock", handlers.UnlockUser)
 
 		// Project CRUD
 		admin.GET("/projects", handlers.GetProjectsDashboard)
 		admin.POST("/projects", handlers.CreateProject)
+		admin.POST("/projects/import", handlers.ImportProjects)
+		admin.POST("/projects/import-config", handlers.ImportProjectConfig)
 		admin.GET("/projects/:id", handlers.GetProjectDetails)
 		admin.PATCH("/projects/:id", handlers.UpdateProject)
 		admin.DELETE("/projects/:id", handlers.DeleteProject)
+		admin.GET("/projects/:id/export-config", handlers.ExportProjectConfig)
 
 		// 🔥 ENHANCED: Embed / docs with proper domain handling
 		admin.GET("/projects/:id/embed", func(c *gin.Context) {
@@ -252,22 +324,23 @@ r.Use(func(c *gin.Context) {
 			embedCode := fmt.Sprintf(`<script>
 (function() {
     var script = document.createElement('script');
-    script.src = '%s/widget.js';
+    script.src = '%s';
     script.setAttribute('data-project-id', '%s');
     script.async = true;
     document.head.appendChild(script);
 })();
-</script>`, domain, projectID)
+</script>`, handlers.WidgetScriptURL(), projectID)
 
 			c.JSON(http.StatusOK, gin.H{
 				"embed_code": embedCode,
-				"widget_url": fmt.Sprintf("%s/widget.js", domain),
+				"widget_url": handlers.WidgetScriptURL(),
 				"project_id": projectID,
 				"domain":     domain,
 				"iframe_url": fmt.Sprintf("%s/embed/%s", domain, projectID),
 			})
 		})
 
+		admin.GET("/projects/:id/embed/preview", handlers.PreviewEmbedWidget)
 		admin.POST("/projects/:id/embed/regenerate", handlers.RegenerateEmbedCode)
 
 		// Subscription actions
@@ -275,15 +348,54 @@ r.Use(func(c *gin.Context) {
 		admin.PATCH("/projects/:id/status", handlers.UpdateProjectStatus)
 		admin.POST("/projects/:id/suspend", handlers.SuspendProject)
 		admin.POST("/projects/:id/reactivate", handlers.ReactivateProject)
+		admin.POST("/projects/:id/pause", handlers.PauseProjectChat)
+		admin.POST("/projects/:id/resume", handlers.ResumeProjectChat)
 
 		// Token / usage tools
 		admin.GET("/projects/:id/usage", handlers.GetProjectUsage)
+		admin.GET("/projects/:id/usage/series", handlers.GetProjectUsageSeries)
 		admin.POST("/projects/:id/limit", handlers.UpdateTokenLimit)
 		admin.POST("/projects/:id/usage/reset", handlers.ResetTokenUsage)
+		admin.POST("/projects/:id/usage/reset-all", middleware.Timeout(2*adminTimeout), handlers.ResetUsageAndHistory)
+		admin.POST("/projects/:id/usage/adjust", handlers.AdjustTokenUsage)
 
 		// Notifications
 		admin.GET("/projects/:id/notifications", handlers.GetProjectNotifications)
 		admin.POST("/projects/:id/notifications/test", handlers.TestNotification)
+		admin.GET("/projects/:id/activity", handlers.GetProjectActivity)
+
+		// Bot actions (OpenAI tool calling via client webhooks)
+		admin.GET("/projects/:id/tools", handlers.GetProjectTools)
+		admin.PUT("/projects/:id/tools", handlers.UpdateProjectTools)
+		admin.POST("/projects/:id/ai/validate", handlers.ValidateProjectAI)
+
+		// Widget users
+		admin.GET("/projects/:id/users", handlers.GetProjectChatUsers)
+		admin.POST("/projects/:id/users/:userId/erase", handlers.EraseChatUser)
+		admin.GET("/projects/:id/leads", handlers.GetProjectLeads)
+
+		// Documents (retrieval weighting)
+		admin.GET("/projects/:id/documents", handlers.GetProjectDocuments)
+		admin.PATCH("/projects/:id/documents/:docId", handlers.UpdateDocumentWeight)
+		admin.GET("/projects/:id/embeddings/status", handlers.GetEmbeddingStatus)
+		admin.POST("/projects/:id/retrieve/preview", handlers.PreviewRetrieval)
+		admin.GET("/projects/:id/knowledge-gaps", handlers.GetKnowledgeGaps)
+		admin.PATCH("/projects/:id/knowledge-gaps/:gapId", handlers.UpdateKnowledgeGap)
+		admin.GET("/projects/:id/moderation", handlers.GetModerationQueue)
+		admin.PATCH("/projects/:id/moderation/:flagId", handlers.ReviewModerationFlag)
+
+		// Clients
+		admin.PATCH("/clients/:clientId/notification-prefs", handlers.UpdateClientNotificationPrefs)
+
+		// Maintenance
+		admin.POST("/maintenance/subscriptions", handlers.TriggerSubscriptionMaintenance)
+		admin.POST("/maintenance/reindex", handlers.StartReindex)
+		admin.GET("/maintenance/reindex/:jobId", handlers.GetReindexJob)
+
+		// Widget sessions
+		admin.GET("/projects/:id/sessions", handlers.ListProjectSessions)
+		admin.GET("/projects/:id/sessions/:sessionId", handlers.GetSessionTranscript)
+		admin.GET("/projects/:id/messages/export", middleware.Timeout(10*adminTimeout), handlers.ExportChatHistory)
 	}
 
 	/*───────────────────────────────────────────*
@@ -295,9 +407,36 @@ r.Use(func(c *gin.Context) {
 		defer ticker.Stop()
 
 		for range ticker.C {
+			// Roll up sessions into widget_analytics before TTL indexes purge them
+			if err := config.AggregateWidgetAnalytics(); err != nil {
+				log.Printf("⚠️  Widget analytics rollup failed: %v", err)
+			}
+
 			if err := config.RunSubscriptionMaintenance(); err != nil {
 				log.Printf("⚠️  Subscription maintenance failed: %v", err)
 			}
+
+			// Projects whose last day of usage spiked far above their usual level
+			if _, err := config.DetectUsageAnomalies(time.Now()); err != nil {
+				log.Printf("⚠️  Usage anomaly detection failed: %v", err)
+			}
+
+			// Low-priority alerts batched for clients on the daily digest
+			if _, err := utils.SendNotificationDigests(); err != nil {
+				log.Printf("⚠️  Notification digests failed: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		// Close widget sessions that went idle so durations and bounce rates are accurate
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := config.CloseIdleWidgetSessions(); err != nil {
+				log.Printf("⚠️  Idle session sweep failed: %v", err)
+			}
 		}
 	}()
 
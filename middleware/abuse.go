@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// Abuse detection defaults (overridable via ABUSE_* env vars)
+const (
+	defaultAbuseMinRequests     = 30 // per minute before any heuristic applies
+	defaultAbuseSpikeMultiplier = 5  // current minute vs. the project's baseline
+	defaultAbuseIPSharePercent  = 80 // share of a minute's traffic from a single IP
+	defaultAbuseCooldownMinutes = 15
+
+	// baselineAlpha weights the latest minute in the per-project moving average
+	baselineAlpha = 0.2
+)
+
+// projectTraffic - Per-project counters for the current minute plus a rolling baseline
+type projectTraffic struct {
+	minute       time.Time
+	count        int
+	ipCounts     map[string]int
+	baseline     float64
+	blockedUntil time.Time
+	blockedIPs   map[string]time.Time
+}
+
+// abuseVerdict - Outcome of recording one request. ipOnly means just the client IP is throttled,
+// not the whole project; reason is only non-empty on the request that trips the detector.
+type abuseVerdict struct {
+	blocked    bool
+	ipOnly     bool
+	reason     string
+	retryAfter time.Duration
+}
+
+// abuseDetector - In-memory burst detector shared by all chat requests on this instance
+type abuseDetector struct {
+	mu       sync.Mutex
+	projects map[string]*projectTraffic
+
+	minRequests     int
+	spikeMultiplier float64
+	ipSharePercent  int
+	cooldown        time.Duration
+}
+
+var chatAbuseDetector = newAbuseDetector()
+
+// notifyAbuse - Tell the project's owners about a detection; swapped out in tests
+var notifyAbuse = func(projectID primitive.ObjectID, message string) {
+	if err := config.LogNotification(projectID, "abuse_detected", message); err != nil {
+		log.Printf("⚠️ Failed to log abuse notification: %v", err)
+	}
+}
+
+func newAbuseDetector() *abuseDetector {
+	return &abuseDetector{
+		projects:        make(map[string]*projectTraffic),
+		minRequests:     envInt("ABUSE_MIN_REQUESTS_PER_MINUTE", defaultAbuseMinRequests),
+		spikeMultiplier: float64(envInt("ABUSE_SPIKE_MULTIPLIER", defaultAbuseSpikeMultiplier)),
+		ipSharePercent:  envInt("ABUSE_IP_SHARE_PERCENT", defaultAbuseIPSharePercent),
+		cooldown:        time.Duration(envInt("ABUSE_COOLDOWN_MINUTES", defaultAbuseCooldownMinutes)) * time.Minute,
+	}
+}
+
+// record - Count a request and report whether the project or this client IP is (now) throttled.
+// An IP dominating a minute's traffic is throttled on its own and its requests are taken out of
+// the project count, so a single client cannot suspend chat for every other visitor.
+func (d *abuseDetector) record(projectID, clientIP string, now time.Time) abuseVerdict {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, ok := d.projects[projectID]
+	if !ok {
+		t = &projectTraffic{ipCounts: make(map[string]int), blockedIPs: make(map[string]time.Time)}
+		d.projects[projectID] = t
+	}
+
+	if now.Before(t.blockedUntil) {
+		return abuseVerdict{blocked: true, retryAfter: t.blockedUntil.Sub(now)}
+	}
+	if until, ok := t.blockedIPs[clientIP]; ok && now.Before(until) {
+		return abuseVerdict{blocked: true, ipOnly: true, retryAfter: until.Sub(now)}
+	}
+
+	minute := now.Truncate(time.Minute)
+	if !minute.Equal(t.minute) {
+		if !t.minute.IsZero() {
+			// Fold the finished minute into the baseline, then decay for any idle minutes since
+			t.baseline = baselineAlpha*float64(t.count) + (1-baselineAlpha)*t.baseline
+			if idle := int(minute.Sub(t.minute)/time.Minute) - 1; idle > 0 {
+				t.baseline *= math.Pow(1-baselineAlpha, float64(idle))
+			}
+		}
+		t.minute = minute
+		t.count = 0
+		t.ipCounts = make(map[string]int)
+		for ip, until := range t.blockedIPs {
+			if !now.Before(until) {
+				delete(t.blockedIPs, ip)
+			}
+		}
+	}
+
+	t.count++
+	t.ipCounts[clientIP]++
+
+	if t.count < d.minRequests {
+		return abuseVerdict{}
+	}
+
+	if ipCount := t.ipCounts[clientIP]; ipCount*100 >= d.ipSharePercent*t.count {
+		t.blockedIPs[clientIP] = now.Add(d.cooldown)
+		t.count -= ipCount
+		delete(t.ipCounts, clientIP)
+		return abuseVerdict{
+			blocked:    true,
+			ipOnly:     true,
+			reason:     fmt.Sprintf("%d of %d requests in one minute from IP %s", ipCount, t.count+ipCount, clientIP),
+			retryAfter: d.cooldown,
+		}
+	}
+
+	threshold := math.Max(float64(d.minRequests), t.baseline*d.spikeMultiplier)
+	if float64(t.count) <= threshold {
+		return abuseVerdict{}
+	}
+
+	t.blockedUntil = now.Add(d.cooldown)
+	return abuseVerdict{
+		blocked:    true,
+		reason:     fmt.Sprintf("%d requests in one minute (baseline %.1f/min)", t.count, t.baseline),
+		retryAfter: d.cooldown,
+	}
+}
+
+// AbuseDetector - Temporarily suspend chat for a project whose traffic bursts far above its baseline,
+// and throttle a single IP that dominates it. Client IPs come from c.ClientIP(), which only honours
+// X-Forwarded-For from TRUSTED_PROXIES. Must run after SubscriptionValidator (needs "project" in context).
+func AbuseDetector() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectInterface, exists := c.Get("project")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		project, ok := projectInterface.(*models.Project)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		verdict := chatAbuseDetector.record(project.ProjectID, c.ClientIP(), time.Now())
+		if !verdict.blocked {
+			c.Next()
+			return
+		}
+
+		if verdict.ipOnly {
+			if verdict.reason != "" {
+				log.Printf("🚨 Abuse detected on project %s: %s; IP throttled for %v", project.ProjectID, verdict.reason, chatAbuseDetector.cooldown)
+				go notifyAbuse(project.ID,
+					fmt.Sprintf("Chat throttled for one IP for %v: %s", chatAbuseDetector.cooldown, verdict.reason))
+			}
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many messages from your network. Please try again later.",
+				"code":        "ABUSE_THROTTLED",
+				"status":      "throttled",
+				"retry_after": int(verdict.retryAfter.Seconds()),
+			})
+			c.Abort()
+			return
+		}
+
+		if verdict.reason != "" {
+			log.Printf("🚨 Abuse detected on project %s: %s; chat suspended for %v", project.ProjectID, verdict.reason, chatAbuseDetector.cooldown)
+			go notifyAbuse(project.ID,
+				fmt.Sprintf("Chat temporarily suspended for %v: %s", chatAbuseDetector.cooldown, verdict.reason))
+		}
+
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Chat is temporarily unavailable for this site due to unusual traffic.",
+			"code":        "ABUSE_SUSPENDED",
+			"status":      "temporarily_suspended",
+			"retry_after": int(verdict.retryAfter.Seconds()),
+		})
+		c.Abort()
+	}
+}
+
+// envInt - Read a positive integer env var, falling back to defaultValue
+func envInt(key string, defaultValue int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return defaultValue
+}
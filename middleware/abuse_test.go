@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/models"
+)
+
+func testAbuseDetector() *abuseDetector {
+	return &abuseDetector{
+		projects:        make(map[string]*projectTraffic),
+		minRequests:     30,
+		spikeMultiplier: 5,
+		ipSharePercent:  80,
+		cooldown:        15 * time.Minute,
+	}
+}
+
+func TestAbuseDetectorThrottlesDominantIPOnly(t *testing.T) {
+	d := testAbuseDetector()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var verdict abuseVerdict
+	for i := 0; i < 40 && !verdict.blocked; i++ {
+		verdict = d.record("proj_1", "198.51.100.1", now)
+	}
+	if !verdict.blocked || !verdict.ipOnly || verdict.reason == "" {
+		t.Fatalf("dominant IP was not throttled on its own: %+v", verdict)
+	}
+
+	if v := d.record("proj_1", "198.51.100.1", now.Add(time.Second)); !v.blocked || !v.ipOnly {
+		t.Errorf("throttled IP got through: %+v", v)
+	}
+	for i := 0; i < 10; i++ {
+		if v := d.record("proj_1", fmt.Sprintf("203.0.113.%d", i), now.Add(time.Second)); v.blocked {
+			t.Fatalf("other visitor %d blocked: %+v", i, v)
+		}
+	}
+	if v := d.record("proj_2", "198.51.100.1", now.Add(time.Second)); v.blocked {
+		t.Errorf("IP throttled on an unrelated project: %+v", v)
+	}
+	if v := d.record("proj_1", "198.51.100.1", now.Add(16*time.Minute)); v.blocked {
+		t.Errorf("IP still throttled after cooldown: %+v", v)
+	}
+}
+
+func TestAbuseDetectorSuspendsProjectOnDistributedSpike(t *testing.T) {
+	d := testAbuseDetector()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		requests    int
+		wantBlocked bool
+	}{
+		{"below minimum", 29, false},
+		{"at threshold", 30, false},
+		{"above threshold", 31, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d.projects = make(map[string]*projectTraffic)
+			var verdict abuseVerdict
+			for i := 0; i < tt.requests; i++ {
+				verdict = d.record("proj_1", fmt.Sprintf("203.0.113.%d", i), now)
+			}
+			if verdict.blocked != tt.wantBlocked {
+				t.Fatalf("blocked = %v, want %v", verdict.blocked, tt.wantBlocked)
+			}
+			if verdict.blocked && verdict.ipOnly {
+				t.Errorf("distributed spike throttled a single IP: %+v", verdict)
+			}
+		})
+	}
+}
+
+func TestAbuseDetectorMiddlewareNotifies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	notifications := make(chan string, 10)
+	previousNotify, previousDetector := notifyAbuse, chatAbuseDetector
+	notifyAbuse = func(projectID primitive.ObjectID, message string) { notifications <- message }
+	t.Cleanup(func() { notifyAbuse, chatAbuseDetector = previousNotify, previousDetector })
+
+	project := &models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_1"}
+	r := gin.New()
+	r.POST("/chat", func(c *gin.Context) { c.Set("project", project) }, AbuseDetector(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	send := func(ip string) int {
+		req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tests := []struct {
+		name        string
+		ip          func(i int) string
+		wantMessage string
+	}{
+		{"dominating IP", func(i int) string { return "198.51.100.1" }, "throttled for one IP"},
+		{"distributed spike", func(i int) string { return fmt.Sprintf("203.0.%d.%d", i/250, i%250) }, "temporarily suspended"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatAbuseDetector = testAbuseDetector()
+			throttled := 0
+			for i := 0; i < 40; i++ {
+				if send(tt.ip(i)) == http.StatusTooManyRequests {
+					throttled++
+				}
+			}
+			if throttled == 0 {
+				t.Fatal("no request was throttled")
+			}
+
+			select {
+			case message := <-notifications:
+				if !strings.Contains(message, tt.wantMessage) {
+					t.Errorf("notification = %q, want one containing %q", message, tt.wantMessage)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no notification sent")
+			}
+			// Only the request that trips the detector notifies
+			select {
+			case message := <-notifications:
+				t.Errorf("second notification: %q", message)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,109 +10,130 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 
 	"jevi-chat/config"
 	"jevi-chat/models"
+	"jevi-chat/utils"
 )
 
+// rateLimiter - Shared limiter behind checkRateLimit
+var rateLimiter = utils.NewMemoryRateLimiter()
+
 // JWTClaims represents the JWT token claims
 type JWTClaims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
 	Name   string `json:"name"`
+	// ProjectID binds chat_user tokens to the widget project they were issued for
+	ProjectID string `json:"project_id,omitempty"`
+	// TokenType separates widget user tokens from platform tokens
+	TokenType string `json:"typ,omitempty"`
 	// Use RegisteredClaims instead of deprecated fields
 	jwt.RegisteredClaims
 }
 
-
 // AuthMiddleware - Main authentication middleware for protected routes
 // AuthMiddleware में admin के लिए special handling
 func AuthMiddleware() gin.HandlerFunc {
-    return func(c *gin.Context) {
-        // Skip authentication for public routes
-        if isPublicRoute(c.Request.URL.Path) {
-            c.Next()
-            return
-        }
-
-        // Extract token from header
-        token := extractTokenFromHeader(c)
-        if token == "" {
-            log.Printf("❌ No token provided for protected route: %s", c.Request.URL.Path)
-            c.JSON(http.StatusUnauthorized, gin.H{
-                "error": "Authentication required",
-                "code":  "NO_TOKEN",
-            })
-            c.Abort()
-            return
-        }
-
-        // Validate and parse token
-        claims, err := ValidateJWTToken(token)
-        if err != nil {
-            log.Printf("❌ Invalid token for route %s: %v", c.Request.URL.Path, err)
-            c.JSON(http.StatusUnauthorized, gin.H{
-                "error": "Invalid or expired token",
-                "code":  "INVALID_TOKEN",
-            })
-            c.Abort()
-            return
-        }
-
-        // ✅ Special handling for admin users
-        if claims.Role == "admin" {
-            // For admin users, set context directly without database lookup
-            c.Set("user_id", claims.UserID)
-            c.Set("user_email", claims.Email)
-            c.Set("user_role", claims.Role)
-            c.Set("user_name", claims.Name)
-            
-            log.Printf("✅ Admin authentication successful: %s (%s)", claims.Email, claims.Role)
-            c.Next()
-            return
-        }
-
-        // For regular users, verify user exists and is active
-        user, err := getUserByID(claims.UserID)
-        if err != nil {
-            log.Printf("❌ User not found for token: %s", claims.UserID)
-            c.JSON(http.StatusUnauthorized, gin.H{
-                "error": "User not found or inactive",
-                "code":  "USER_NOT_FOUND",
-            })
-            c.Abort()
-            return
-        }
-
-        // Check if user is active
-        if !user.IsActive {
-            log.Printf("❌ Inactive user attempted access: %s", user.Email)
-            c.JSON(http.StatusForbidden, gin.H{
-                "error": "Account is deactivated",
-                "code":  "ACCOUNT_DEACTIVATED",
-            })
-            c.Abort()
-            return
-        }
-
-        // Add user info to context
-        c.Set("user_id", claims.UserID)
-        c.Set("user_email", claims.Email)
-        c.Set("user_role", claims.Role)
-        c.Set("user_name", claims.Name)
-        c.Set("user", user)
-
-        log.Printf("✅ Authentication successful for user: %s (%s)", user.Email, claims.Role)
-        c.Next()
-    }
-}
+	return func(c *gin.Context) {
+		// Skip authentication for public routes
+		if isPublicRoute(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		// Extract token from header (or the auth cookie)
+		token, fromCookie := extractToken(c)
+		if token == "" {
+			log.Printf("❌ No token provided for protected route: %s", c.Request.URL.Path)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Authentication required",
+				"code":  "NO_TOKEN",
+			})
+			c.Abort()
+			return
+		}
+
+		// Cookie-authenticated writes must carry the CSRF token
+		if fromCookie && !validCSRF(c) {
+			rejectCSRF(c)
+			return
+		}
 
+		// Validate and parse token
+		claims, err := ValidateJWTToken(token)
+		if errors.Is(err, ErrChatUserToken) {
+			log.Printf("❌ Widget user token used on platform route %s", c.Request.URL.Path)
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Widget user tokens cannot access this route",
+				"code":  "CHAT_USER_TOKEN_NOT_ALLOWED",
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Invalid token for route %s: %v", c.Request.URL.Path, err)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or expired token",
+				"code":  "INVALID_TOKEN",
+			})
+			c.Abort()
+			return
+		}
+
+		// ✅ Special handling for admin users
+		if claims.Role == "admin" {
+			// For admin users, set context directly without database lookup
+			c.Set("user_id", claims.UserID)
+			c.Set("user_email", claims.Email)
+			c.Set("user_role", claims.Role)
+			c.Set("user_name", claims.Name)
+
+			log.Printf("✅ Admin authentication successful: %s (%s)", claims.Email, claims.Role)
+			c.Next()
+			return
+		}
+
+		// For regular users, verify user exists and is active
+		user, err := getUserByID(c.Request.Context(), claims.UserID)
+		if err != nil {
+			log.Printf("❌ User not found for token: %s", claims.UserID)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not found or inactive",
+				"code":  "USER_NOT_FOUND",
+			})
+			c.Abort()
+			return
+		}
+
+		// Check if user is active
+		if !user.IsActive {
+			log.Printf("❌ Inactive user attempted access: %s", user.Email)
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Account is deactivated",
+				"code":  "ACCOUNT_DEACTIVATED",
+			})
+			c.Abort()
+			return
+		}
+
+		// Add user info to context
+		c.Set("user_id", claims.UserID)
+		c.Set("user_email", claims.Email)
+		c.Set("user_role", claims.Role)
+		c.Set("user_name", claims.Name)
+		c.Set("user", user)
+
+		log.Printf("✅ Authentication successful for user: %s (%s)", user.Email, claims.Role)
+		c.Next()
+	}
+}
 
 // AdminMiddleware - Middleware to restrict access to admin-only routes
 func AdminMiddleware() gin.HandlerFunc {
@@ -147,13 +169,19 @@ func AdminMiddleware() gin.HandlerFunc {
 // OptionalAuthMiddleware - Middleware for routes that work with or without authentication
 func OptionalAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := extractTokenFromHeader(c)
+		token, fromCookie := extractToken(c)
 		if token == "" {
 			// No token provided, continue without authentication
 			c.Next()
 			return
 		}
 
+		// A cookie that fails the CSRF check may be a forged request; treat it as anonymous
+		if fromCookie && !validCSRF(c) {
+			c.Next()
+			return
+		}
+
 		// Validate token if provided
 		claims, err := ValidateJWTToken(token)
 		if err != nil {
@@ -164,7 +192,7 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Add user info to context if valid
-		user, err := getUserByID(claims.UserID)
+		user, err := getUserByID(c.Request.Context(), claims.UserID)
 		if err == nil && user.IsActive {
 			c.Set("user_id", claims.UserID)
 			c.Set("user_email", claims.Email)
@@ -180,61 +208,66 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 // CORSMiddleware - Enhanced CORS middleware with authentication support
 // CORSMiddleware - Enhanced CORS middleware with authentication support
 func CORSMiddleware() gin.HandlerFunc {
-    return func(c *gin.Context) {
-        origin := c.Request.Header.Get("Origin")
-        
-        // Log CORS requests for debugging
-        log.Printf("🌐 CORS Request - Origin: %s, Method: %s, Path: %s", 
-            origin, c.Request.Method, c.Request.URL.Path)
-
-        allowedOrigins := []string{
-            "http://localhost:3000",
-            "http://localhost:3001",
-            "http://127.0.0.1:3000",
-            "https://troikacompletefrontend.onrender.com",
-            "https://troika-admin-dashborad.onrender.com",
-            "https://admin.troikatech.com",
-        }
-
-        isAllowed := false
-        for _, allowedOrigin := range allowedOrigins {
-            if origin == allowedOrigin {
-                isAllowed = true
-                break
-            }
-        }
-
-        if isAllowed || os.Getenv("ENVIRONMENT") == "development" {
-            c.Header("Access-Control-Allow-Origin", origin)
-            log.Printf("✅ CORS Allowed for origin: %s", origin)
-        } else {
-            log.Printf("❌ CORS Blocked for origin: %s", origin)
-        }
-
-        c.Header("Access-Control-Allow-Credentials", "true")
-        c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-        
-        // 🔥 FIX: Explicitly include ALL HTTP methods including PATCH
-        c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS, HEAD")
-        
-        c.Header("Access-Control-Max-Age", "86400")
-
-        if c.Request.Method == "OPTIONS" {
-            log.Printf("🔄 CORS Preflight request handled for %s", c.Request.URL.Path)
-            c.AbortWithStatus(http.StatusNoContent)
-            return
-        }
-
-        c.Next()
-    }
-}
+	return func(c *gin.Context) {
+		// Widget API requests are handled by WidgetCORSMiddleware
+		if c.GetBool(WidgetCORSKey) {
+			c.Next()
+			return
+		}
+
+		origin := c.Request.Header.Get("Origin")
+
+		// Log CORS requests for debugging
+		log.Printf("🌐 CORS Request - Origin: %s, Method: %s, Path: %s",
+			origin, c.Request.Method, c.Request.URL.Path)
+
+		allowedOrigins := []string{
+			"http://localhost:3000",
+			"http://localhost:3001",
+			"http://127.0.0.1:3000",
+			"https://troikacompletefrontend.onrender.com",
+			"https://troika-admin-dashborad.onrender.com",
+			"https://admin.troikatech.com",
+		}
+
+		isAllowed := false
+		for _, allowedOrigin := range allowedOrigins {
+			if origin == allowedOrigin {
+				isAllowed = true
+				break
+			}
+		}
+
+		if isAllowed || os.Getenv("ENVIRONMENT") == "development" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			log.Printf("✅ CORS Allowed for origin: %s", origin)
+		} else {
+			log.Printf("❌ CORS Blocked for origin: %s", origin)
+		}
 
+		c.Header("Access-Control-Allow-Credentials", "true")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Visitor-Token")
+		c.Header("Access-Control-Expose-Headers", "X-Visitor-Token, X-Request-Tokens, X-Request-Cost")
 
+		// 🔥 FIX: Explicitly include ALL HTTP methods including PATCH
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS, HEAD")
+
+		c.Header("Access-Control-Max-Age", "86400")
+
+		if c.Request.Method == "OPTIONS" {
+			log.Printf("🔄 CORS Preflight request handled for %s", c.Request.URL.Path)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
 
 // RateLimitMiddleware - Rate limiting middleware with user-based limits
 func RateLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIP := getClientIP(c)
+		clientIP := c.ClientIP()
 		userID := c.GetString("user_id")
 
 		// Different rate limits for authenticated vs anonymous users
@@ -304,10 +337,27 @@ func extractTokenFromHeader(c *gin.Context) string {
 	return authHeader
 }
 
-// ValidateJWTToken - Validate and parse JWT token (exported for use in handlers)
+// ValidateJWTToken - Validate and parse a platform (admin/user) JWT token (exported for use in handlers).
+// Widget chat_user tokens are rejected with ErrChatUserToken.
 func ValidateJWTToken(tokenString string) (*JWTClaims, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
+	claims, err := parseJWT(tokenString, platformKeyRing())
+	if err != nil {
+		if isChatUserToken(tokenString) {
+			return nil, ErrChatUserToken
+		}
+		return nil, err
+	}
+
+	if claims.TokenType == TokenTypeChatUser || claims.Role == "chat_user" {
+		return nil, ErrChatUserToken
+	}
+
+	return claims, nil
+}
+
+// parseJWT - Verify an HMAC-signed token with the key its kid names and return its claims
+func parseJWT(tokenString string, keys keyRing) (*JWTClaims, error) {
+	if keys.current == "" {
 		return nil, fmt.Errorf("JWT secret not configured")
 	}
 
@@ -316,8 +366,13 @@ func ValidateJWTToken(tokenString string) (*JWTClaims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(jwtSecret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		secret, ok := keys.lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return []byte(secret), nil
+	}, jwt.WithIssuer(jwtIssuer())) // tokens minted by other systems sharing the secret are rejected
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %v", err)
@@ -337,8 +392,8 @@ func ValidateJWTToken(tokenString string) (*JWTClaims, error) {
 }
 
 // getUserByID - Get user by ID from database
-func getUserByID(userID string) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func getUserByID(parent context.Context, userID string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
 	defer cancel()
 
 	collection := config.GetCollection("users")
@@ -385,32 +440,47 @@ func isPublicRoute(path string) bool {
 
 // checkRateLimit - Basic rate limiting implementation
 func checkRateLimit(identifier string, limit int, window time.Duration) bool {
-	// This is a simplified implementation
-	// In production, use Redis or similar for distributed rate limiting
-	return true
+	// In-memory and per instance; swap for utils.RedisRateLimiter when running several replicas
+	return rateLimiter.Allow(identifier, limit, window)
+}
+
+// AllowRequest - Count a hit against identifier's limit (exported for handler-level throttling)
+func AllowRequest(identifier string, limit int, window time.Duration) bool {
+	return checkRateLimit(identifier, limit, window)
+}
+
+// defaultJWTIssuer and defaultJWTExpiry - Used when JWT_ISSUER / JWT_EXPIRY are unset
+const (
+	defaultJWTIssuer = "troika-tech"
+	defaultJWTExpiry = 24 * time.Hour
+)
+
+// jwtIssuer - iss claim written into and required of every token (JWT_ISSUER)
+func jwtIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return defaultJWTIssuer
 }
 
-// getClientIP - Get client IP address
-func getClientIP(c *gin.Context) string {
-	// Check for forwarded IP first
-	if ip := c.GetHeader("X-Forwarded-For"); ip != "" {
-		return strings.Split(ip, ",")[0]
+// jwtExpiry - Lifetime of platform tokens (JWT_EXPIRY, a Go duration such as "12h" or "30m")
+func jwtExpiry() time.Duration {
+	raw := os.Getenv("JWT_EXPIRY")
+	if raw == "" {
+		return defaultJWTExpiry
 	}
-	if ip := c.GetHeader("X-Real-IP"); ip != "" {
-		return ip
+	expiry, err := time.ParseDuration(raw)
+	if err != nil || expiry <= 0 {
+		log.Printf("⚠️ Invalid JWT_EXPIRY %q, using %s", raw, defaultJWTExpiry)
+		return defaultJWTExpiry
 	}
-	return c.ClientIP()
+	return expiry
 }
 
 // GenerateJWTToken - Generate JWT token for user
 func GenerateJWTToken(user *models.User) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return "", fmt.Errorf("JWT secret not configured")
-	}
-
-	// Set token expiration (24 hours)
-	expirationTime := time.Now().Add(24 * time.Hour)
+	// Set token expiration (JWT_EXPIRY, 24 hours by default)
+	expirationTime := time.Now().Add(jwtExpiry())
 
 	claims := &JWTClaims{
 		UserID: user.ID.Hex(),
@@ -421,18 +491,12 @@ func GenerateJWTToken(user *models.User) (string, error) {
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "troika-tech",
+			Issuer:    jwtIssuer(),
 			Subject:   user.ID.Hex(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jwtSecret))
-	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %v", err)
-	}
-
-	return tokenString, nil
+	return platformKeyRing().sign(claims)
 }
 
 // HashPassword - Hash password using bcrypt
@@ -464,7 +528,7 @@ func RefreshTokenMiddleware() gin.HandlerFunc {
 
 		// Check if token expires within 1 hour
 		if claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) < time.Hour {
-			user, err := getUserByID(claims.UserID)
+			user, err := getUserByID(c.Request.Context(), claims.UserID)
 			if err == nil && user.IsActive {
 				newToken, err := GenerateJWTToken(user)
 				if err == nil {
@@ -497,7 +561,7 @@ func LoggingMiddleware() gin.HandlerFunc {
 			c.Request.URL.Path,
 			c.Writer.Status(),
 			duration,
-			getClientIP(c),
+			c.ClientIP(),
 		)
 
 		if userEmail != "" {
@@ -508,7 +572,15 @@ func LoggingMiddleware() gin.HandlerFunc {
 	}
 }
 
+// lastSeenInterval - Shortest gap between two last_login_at writes for the same user
+const lastSeenInterval = time.Minute
+
+// lastSeenTimeout - Upper bound on the time a request waits for its last_login_at write
+const lastSeenTimeout = 2 * time.Second
+
 // SessionValidationMiddleware - Validate user session and update last activity
+// The write happens before the handler runs, bounded by lastSeenTimeout, and at most once per
+// lastSeenInterval per user; requests in between skip it.
 func SessionValidationMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := c.GetString("user_id")
@@ -517,30 +589,35 @@ func SessionValidationMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Update user's last activity asynchronously
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-
-			collection := config.GetCollection("users")
-			objID, err := primitive.ObjectIDFromHex(userID)
-			if err != nil {
-				return
-			}
-
-			update := bson.M{
-				"$set": bson.M{
-					"last_login_at": time.Now(),
-					"updated_at":    time.Now(),
-				},
-			}
-
-			collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
-		}()
+		if checkRateLimit("last_seen:"+userID, 1, lastSeenInterval) {
+			touchLastSeen(c.Request.Context(), userID)
+		}
 
 		c.Next()
 	}
 }
 
+// touchLastSeen - Record that the user was just active; swapped out in tests
+var touchLastSeen = recordLastSeen
 
+// recordLastSeen - Write last_login_at for the user
+func recordLastSeen(parent context.Context, userID string) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, lastSeenTimeout)
+	defer cancel()
 
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"last_login_at": now,
+			"updated_at":    now,
+		},
+	}
+	if _, err := config.GetCollection("users").UpdateOne(ctx, bson.M{"_id": objID}, update); err != nil {
+		log.Printf("⚠️ Failed to update last activity for user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/models"
+	"jevi-chat/utils"
+)
+
+// usePlatformSecret - A single platform JWT secret with default issuer and expiry
+func usePlatformSecret(t *testing.T) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "current-secret")
+	t.Setenv("JWT_PREVIOUS_SECRETS", "")
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_EXPIRY", "")
+	t.Setenv("CHAT_USER_JWT_SECRET", "")
+}
+
+func testAdmin() *models.User {
+	return &models.User{ID: primitive.NewObjectID(), Email: "admin@example.com", Role: "admin", Name: "Admin"}
+}
+
+func TestJWTExpiry(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want time.Duration
+	}{
+		{"", defaultJWTExpiry},
+		{"30m", 30 * time.Minute},
+		{"12h", 12 * time.Hour},
+		{"tomorrow", defaultJWTExpiry},
+		{"-1h", defaultJWTExpiry},
+		{"0s", defaultJWTExpiry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			usePlatformSecret(t)
+			t.Setenv("JWT_EXPIRY", tt.raw)
+			if got := jwtExpiry(); got != tt.want {
+				t.Errorf("jwtExpiry() = %v, want %v", got, tt.want)
+			}
+
+			claims, err := ValidateJWTToken(mustGenerateJWT(t, testAdmin()))
+			if err != nil {
+				t.Fatalf("ValidateJWTToken: %v", err)
+			}
+			if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime != tt.want {
+				t.Errorf("token lifetime = %v, want %v", lifetime, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJWTTokenChecksIssuer(t *testing.T) {
+	tests := []struct {
+		name        string
+		signIssuer  string
+		checkIssuer string
+		wantErr     bool
+	}{
+		{"default issuer", "", "", false},
+		{"configured issuer", "troika-prod", "troika-prod", false},
+		{"token from another issuer", "troika-staging", "troika-prod", true},
+		{"default token on configured server", "", "troika-prod", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePlatformSecret(t)
+			t.Setenv("JWT_ISSUER", tt.signIssuer)
+			token := mustGenerateJWT(t, testAdmin())
+
+			t.Setenv("JWT_ISSUER", tt.checkIssuer)
+			if _, err := ValidateJWTToken(token); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWTToken error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateJWTTokenRejectsTamperedAndExpired(t *testing.T) {
+	usePlatformSecret(t)
+	token := mustGenerateJWT(t, testAdmin())
+
+	t.Setenv("JWT_EXPIRY", "1ns")
+	expired := mustGenerateJWT(t, testAdmin())
+	time.Sleep(time.Millisecond)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"tampered signature", token[:len(token)-2] + "xx"},
+		{"expired", expired},
+		{"garbage", "not.a.token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateJWTToken(tt.token); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func mustGenerateJWT(t *testing.T, user *models.User) string {
+	t.Helper()
+	token, err := GenerateJWTToken(user)
+	if err != nil {
+		t.Fatalf("GenerateJWTToken: %v", err)
+	}
+	return token
+}
+
+// useTestClock - A fresh shared rate limiter driven by a clock the test moves forward
+func useTestClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	previous := rateLimiter
+	rateLimiter = utils.NewMemoryRateLimiterWithClock(func() time.Time { return now })
+	t.Cleanup(func() { rateLimiter = previous })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestSessionValidationMiddlewareThrottlesLastSeen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	advance := useTestClock(t)
+
+	writes := map[string]int{}
+	previous := touchLastSeen
+	touchLastSeen = func(_ context.Context, userID string) { writes[userID]++ }
+	t.Cleanup(func() { touchLastSeen = previous })
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", c.Query("user")) }, SessionValidationMiddleware())
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := func(user string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?user="+user, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d", w.Code)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		request("user_1")
+		advance(lastSeenInterval / 10)
+	}
+	request("user_2")
+	request("")
+	if writes["user_1"] != 1 || writes["user_2"] != 1 || len(writes) != 2 {
+		t.Fatalf("writes = %v, want one per user within the interval and none without a user", writes)
+	}
+
+	advance(lastSeenInterval)
+	request("user_1")
+	if writes["user_1"] != 2 {
+		t.Errorf("user_1 written %d times, want a second write once the interval has passed", writes["user_1"])
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"jevi-chat/models"
+)
+
+// Widget (chat_user) tokens are a separate trust domain from platform admin/user tokens:
+// they carry typ=chat_user, are signed with CHAT_USER_JWT_SECRET when set, and live shorter.
+const (
+	TokenTypeChatUser = "chat_user"
+
+	defaultChatUserTokenTTLHours = 4
+)
+
+// ErrChatUserToken - A widget user token was presented where a platform token is required
+var ErrChatUserToken = errors.New("chat user tokens cannot access platform routes")
+
+// chatUserKeyRing - CHAT_USER_JWT_SECRET and CHAT_USER_JWT_PREVIOUS_SECRETS, falling back to the
+// platform keys
+func chatUserKeyRing() keyRing {
+	if secret := os.Getenv("CHAT_USER_JWT_SECRET"); secret != "" {
+		return newKeyRing(secret, os.Getenv("CHAT_USER_JWT_PREVIOUS_SECRETS"))
+	}
+	return platformKeyRing()
+}
+
+// chatUserTokenTTL - Lifetime of widget user tokens (CHAT_USER_TOKEN_TTL_HOURS)
+func chatUserTokenTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("CHAT_USER_TOKEN_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = defaultChatUserTokenTTLHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GenerateChatUserToken - Mint a widget user token bound to the project the user registered with
+func GenerateChatUserToken(user *models.ChatUser, projectID string) (string, error) {
+	now := time.Now()
+	claims := &JWTClaims{
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		Role:      "chat_user",
+		Name:      user.Name,
+		ProjectID: projectID,
+		TokenType: TokenTypeChatUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(chatUserTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    jwtIssuer(),
+			Subject:   user.ID.Hex(),
+		},
+	}
+
+	return chatUserKeyRing().sign(claims)
+}
+
+// ValidateChatUserToken - Validate a widget user token and check it was issued for projectID
+func ValidateChatUserToken(tokenString, projectID string) (*JWTClaims, error) {
+	claims, err := parseJWT(tokenString, chatUserKeyRing())
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != TokenTypeChatUser {
+		return nil, fmt.Errorf("not a chat user token")
+	}
+
+	// Tokens minted for one project's widget must not be replayed on another
+	if claims.ProjectID == "" || claims.ProjectID != projectID {
+		return nil, fmt.Errorf("token was not issued for this project")
+	}
+
+	return claims, nil
+}
+
+// isChatUserToken - Peek (without verifying) whether a token claims to be a widget user token.
+// Only used to return a clearer error; never to grant access.
+func isChatUserToken(tokenString string) bool {
+	claims := &JWTClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return false
+	}
+	return claims.TokenType == TokenTypeChatUser
+}
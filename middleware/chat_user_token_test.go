@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/models"
+)
+
+// useChatUserSecrets - Separate platform and widget secrets for the duration of a test
+func useChatUserSecrets(t *testing.T) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "platform-secret")
+	t.Setenv("JWT_PREVIOUS_SECRETS", "")
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_EXPIRY", "")
+	t.Setenv("CHAT_USER_JWT_SECRET", "widget-secret")
+	t.Setenv("CHAT_USER_JWT_PREVIOUS_SECRETS", "")
+	t.Setenv("CHAT_USER_TOKEN_TTL_HOURS", "")
+}
+
+func TestValidateChatUserTokenIsBoundToProject(t *testing.T) {
+	useChatUserSecrets(t)
+
+	user := &models.ChatUser{ID: primitive.NewObjectID(), Email: "visitor@example.com"}
+	token, err := GenerateChatUserToken(user, "proj_1")
+	if err != nil {
+		t.Fatalf("GenerateChatUserToken: %v", err)
+	}
+	unbound, err := GenerateChatUserToken(user, "")
+	if err != nil {
+		t.Fatalf("GenerateChatUserToken: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		projectID string
+		wantErr   bool
+	}{
+		{"issued for this project", token, "proj_1", false},
+		{"replayed on another project", token, "proj_2", true},
+		{"token without a project", unbound, "", true},
+		{"garbage", "not-a-token", "proj_1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ValidateChatUserToken(tt.token, tt.projectID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateChatUserToken error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.UserID != user.ID.Hex() || claims.ProjectID != "proj_1") {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestChatUserTokensAreSeparateFromPlatformTokens(t *testing.T) {
+	useChatUserSecrets(t)
+
+	chatToken, err := GenerateChatUserToken(&models.ChatUser{ID: primitive.NewObjectID()}, "proj_1")
+	if err != nil {
+		t.Fatalf("GenerateChatUserToken: %v", err)
+	}
+	platformToken, err := GenerateJWTToken(&models.User{ID: primitive.NewObjectID(), Role: "admin"})
+	if err != nil {
+		t.Fatalf("GenerateJWTToken: %v", err)
+	}
+
+	if _, err := ValidateJWTToken(chatToken); !errors.Is(err, ErrChatUserToken) {
+		t.Errorf("platform validation of a chat token = %v, want ErrChatUserToken", err)
+	}
+	if _, err := ValidateChatUserToken(platformToken, "proj_1"); err == nil {
+		t.Error("platform token accepted as a chat user token")
+	}
+
+	// Signed with CHAT_USER_JWT_SECRET: rotating it must not touch platform tokens and vice versa
+	t.Setenv("CHAT_USER_JWT_SECRET", "rotated-widget-secret")
+	if _, err := ValidateChatUserToken(chatToken, "proj_1"); err == nil {
+		t.Error("chat token still valid after its secret changed")
+	}
+	if _, err := ValidateJWTToken(platformToken); err != nil {
+		t.Errorf("platform token broken by widget secret rotation: %v", err)
+	}
+}
+
+func TestChatUserTokenTTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		hours string
+		want  time.Duration
+	}{
+		{"default", "", defaultChatUserTokenTTLHours * time.Hour},
+		{"configured", "1", time.Hour},
+		{"invalid falls back", "soon", defaultChatUserTokenTTLHours * time.Hour},
+		{"negative falls back", "-3", defaultChatUserTokenTTLHours * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useChatUserSecrets(t)
+			t.Setenv("CHAT_USER_TOKEN_TTL_HOURS", tt.hours)
+
+			token, err := GenerateChatUserToken(&models.ChatUser{ID: primitive.NewObjectID()}, "proj_1")
+			if err != nil {
+				t.Fatalf("GenerateChatUserToken: %v", err)
+			}
+			claims, err := ValidateChatUserToken(token, "proj_1")
+			if err != nil {
+				t.Fatalf("ValidateChatUserToken: %v", err)
+			}
+			lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time)
+			if lifetime != tt.want {
+				t.Errorf("token lifetime = %v, want %v", lifetime, tt.want)
+			}
+			if lifetime >= defaultJWTExpiry && tt.hours == "" {
+				t.Errorf("chat token lifetime %v is not shorter than platform tokens", lifetime)
+			}
+		})
+	}
+}
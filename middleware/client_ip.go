@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultTrustedProxies - Loopback and private ranges, where the platform's load balancer sits.
+// X-Forwarded-For entries added by anyone else are ignored by c.ClientIP().
+const defaultTrustedProxies = "127.0.0.0/8,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,::1/128,fc00::/7"
+
+// ConfigureTrustedProxies - Tell gin which peers may set X-Forwarded-For / X-Real-IP, so
+// c.ClientIP() walks the header from the right and stops at the first untrusted hop. A client
+// prepending its own X-Forwarded-For entry therefore cannot choose the IP that rate limits,
+// abuse detection and audit logs see. TRUSTED_PROXIES is a comma-separated list of IPs or CIDRs;
+// "none" trusts no proxy and uses the socket address.
+func ConfigureTrustedProxies(r *gin.Engine) error {
+	value := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES"))
+	if value == "" {
+		value = defaultTrustedProxies
+	}
+	if strings.EqualFold(value, "none") {
+		return r.SetTrustedProxies(nil)
+	}
+
+	var proxies []string
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		return err
+	}
+	log.Printf("🌐 Trusting X-Forwarded-For from %d proxy ranges", len(proxies))
+	return nil
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConfigureTrustedProxiesIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		trusted    string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct client spoofing header", "", "203.0.113.7:5000", "1.2.3.4", "203.0.113.7"},
+		{"proxy appends real client", "", "10.0.0.5:5000", "1.2.3.4, 198.51.100.9", "198.51.100.9"},
+		{"proxy without header", "", "10.0.0.5:5000", "", "10.0.0.5"},
+		{"none trusts no proxy", "none", "10.0.0.5:5000", "198.51.100.9", "10.0.0.5"},
+		{"explicit proxy list", "192.0.2.1", "192.0.2.1:5000", "198.51.100.9", "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			r := gin.New()
+			if err := ConfigureTrustedProxies(r); err != nil {
+				t.Fatalf("ConfigureTrustedProxies: %v", err)
+			}
+			var got string
+			r.GET("/", func(c *gin.Context) { got = c.ClientIP() })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureTrustedProxiesRejectsInvalidRanges(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "not-an-ip")
+	if err := ConfigureTrustedProxies(gin.New()); err == nil {
+		t.Fatal("expected an error for an invalid proxy range")
+	}
+}
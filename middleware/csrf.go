@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Platform tokens may arrive in the AuthCookieName cookie instead of the Authorization header.
+// Browsers attach cookies to cross-site requests, so cookie-authenticated requests that change
+// state must also prove they come from our own frontend (double-submit): the X-CSRF-Token header
+// has to echo the CSRFCookieName cookie, which other sites can neither read nor set.
+// Bearer-token calls are not affected.
+const (
+	AuthCookieName = "troika_token"
+	CSRFCookieName = "troika_csrf"
+	CSRFHeaderName = "X-CSRF-Token"
+
+	csrfTokenMaxAge = 24 * 60 * 60 // one day, in seconds
+)
+
+// extractToken - Platform token from the Authorization header, else from the auth cookie.
+// fromCookie tells the caller CSRF checks apply.
+func extractToken(c *gin.Context) (token string, fromCookie bool) {
+	if token := extractTokenFromHeader(c); token != "" {
+		return token, false
+	}
+	if token, err := c.Cookie(AuthCookieName); err == nil && token != "" {
+		return token, true
+	}
+	return "", false
+}
+
+// isSafeMethod - Methods that must not change state and so need no CSRF token
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// validCSRF - Whether a cookie-authenticated request may proceed
+func validCSRF(c *gin.Context) bool {
+	if isSafeMethod(c.Request.Method) {
+		return true
+	}
+	cookie, err := c.Cookie(CSRFCookieName)
+	header := c.GetHeader(CSRFHeaderName)
+	if err != nil || cookie == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// rejectCSRF - Abort a cookie-authenticated request that failed the CSRF check
+func rejectCSRF(c *gin.Context) {
+	log.Printf("❌ Missing or invalid CSRF token for %s %s", c.Request.Method, c.Request.URL.Path)
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Missing or invalid CSRF token",
+		"code":  "CSRF_TOKEN_INVALID",
+	})
+	c.Abort()
+}
+
+// IssueCSRFToken - Set a fresh CSRF cookie and return its value for the frontend to send back
+// in the X-CSRF-Token header. The cookie is readable by scripts on purpose.
+func IssueCSRFToken(c *gin.Context) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(CSRFCookieName, token, csrfTokenMaxAge, "/", "", true, false)
+	return token, nil
+}
+
+// SetAuthCookies - Start a cookie session after login: the platform token in an HttpOnly
+// AuthCookieName cookie that scripts cannot read, plus a fresh CSRF token (returned) for the
+// frontend to echo on writes
+func SetAuthCookies(c *gin.Context, token string) (string, error) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(AuthCookieName, token, int(jwtExpiry().Seconds()), "/", "", true, true)
+	return IssueCSRFToken(c)
+}
+
+// ClearAuthCookies - End the cookie session
+func ClearAuthCookies(c *gin.Context) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(AuthCookieName, "", -1, "/", "", true, true)
+	c.SetCookie(CSRFCookieName, "", -1, "/", "", true, false)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthMiddlewareCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	usePlatformSecret(t)
+	token := mustGenerateJWT(t, testAdmin())
+
+	// Log in the way handlers.Login does, keeping the cookies it sets
+	w := httptest.NewRecorder()
+	login, _ := gin.CreateTestContext(w)
+	login.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	csrfToken, err := SetAuthCookies(login, token)
+	if err != nil {
+		t.Fatalf("SetAuthCookies: %v", err)
+	}
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	if auth := cookies[AuthCookieName]; auth == nil || auth.Value != token || !auth.HttpOnly || !auth.Secure {
+		t.Fatalf("auth cookie = %+v, want the token, HttpOnly and Secure", auth)
+	}
+	if csrf := cookies[CSRFCookieName]; csrf == nil || csrf.Value != csrfToken || csrf.HttpOnly {
+		t.Fatalf("CSRF cookie = %+v, want the returned token, readable by scripts", csrf)
+	}
+
+	r := gin.New()
+	r.Use(AuthMiddleware())
+	r.GET("/api/admin/projects", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/api/admin/projects", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		method     string
+		bearer     bool
+		authCookie bool
+		csrfCookie bool
+		csrfHeader string
+		wantStatus int
+	}{
+		{"bearer write needs no CSRF token", http.MethodPost, true, false, false, "", http.StatusOK},
+		{"cookie write without CSRF header", http.MethodPost, false, true, true, "", http.StatusForbidden},
+		{"cookie write with wrong CSRF header", http.MethodPost, false, true, true, "forged", http.StatusForbidden},
+		{"cookie write without CSRF cookie", http.MethodPost, false, true, false, csrfToken, http.StatusForbidden},
+		{"cookie write with matching CSRF header", http.MethodPost, false, true, true, csrfToken, http.StatusOK},
+		{"cookie read needs no CSRF token", http.MethodGet, false, true, false, "", http.StatusOK},
+		{"no credentials", http.MethodPost, false, false, true, csrfToken, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/admin/projects", nil)
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			if tt.authCookie {
+				req.AddCookie(cookies[AuthCookieName])
+			}
+			if tt.csrfCookie {
+				req.AddCookie(cookies[CSRFCookieName])
+			}
+			if tt.csrfHeader != "" {
+				req.Header.Set(CSRFHeaderName, tt.csrfHeader)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestClearAuthCookies(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+	ClearAuthCookies(c)
+
+	cleared := map[string]bool{}
+	for _, cookie := range w.Result().Cookies() {
+		cleared[cookie.Name] = cookie.MaxAge < 0 && cookie.Value == ""
+	}
+	if !cleared[AuthCookieName] || !cleared[CSRFCookieName] {
+		t.Errorf("cookies not cleared: %v", cleared)
+	}
+}
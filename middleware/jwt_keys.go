@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyRing - The secret new tokens are signed with plus recently retired secrets that are still
+// accepted. Each token names its key in the kid header, so rotating a secret is: move the old
+// value to the *_PREVIOUS_SECRETS list, set the new one, and drop the old one once every token
+// it signed has expired.
+type keyRing struct {
+	current  string
+	previous []string
+}
+
+// newKeyRing - Key ring from a current secret and a comma-separated list of previous ones
+func newKeyRing(current, previous string) keyRing {
+	ring := keyRing{current: current}
+	for _, secret := range strings.Split(previous, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" && secret != current {
+			ring.previous = append(ring.previous, secret)
+		}
+	}
+	return ring
+}
+
+// platformKeyRing - JWT_SECRET and JWT_PREVIOUS_SECRETS
+func platformKeyRing() keyRing {
+	return newKeyRing(os.Getenv("JWT_SECRET"), os.Getenv("JWT_PREVIOUS_SECRETS"))
+}
+
+// keyID - kid of a secret: a short fingerprint, so the secret itself never leaves the server
+func keyID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// lookup - Secret for a token's kid. Tokens without one predate key IDs and were signed with
+// the current secret.
+func (k keyRing) lookup(kid string) (string, bool) {
+	if kid == "" || kid == keyID(k.current) {
+		return k.current, k.current != ""
+	}
+	for _, secret := range k.previous {
+		if kid == keyID(secret) {
+			return secret, true
+		}
+	}
+	return "", false
+}
+
+// sign - Sign claims with the current secret, naming it in the kid header
+func (k keyRing) sign(claims jwt.Claims) (string, error) {
+	if k.current == "" {
+		return "", fmt.Errorf("JWT secret not configured")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = keyID(k.current)
+	tokenString, err := token.SignedString([]byte(k.current))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %v", err)
+	}
+	return tokenString, nil
+}
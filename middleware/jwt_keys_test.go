@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewKeyRing(t *testing.T) {
+	ring := newKeyRing("current", " old-1 , ,current,old-2")
+	if ring.current != "current" {
+		t.Errorf("current = %q", ring.current)
+	}
+	if len(ring.previous) != 2 || ring.previous[0] != "old-1" || ring.previous[1] != "old-2" {
+		t.Errorf("previous = %q, want [old-1 old-2]", ring.previous)
+	}
+}
+
+func TestKeyRingLookup(t *testing.T) {
+	ring := newKeyRing("current", "old")
+
+	tests := []struct {
+		name       string
+		kid        string
+		wantSecret string
+		wantOK     bool
+	}{
+		{"current key", keyID("current"), "current", true},
+		{"token without kid", "", "current", true},
+		{"previous key", keyID("old"), "old", true},
+		{"unknown key", keyID("retired"), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, ok := ring.lookup(tt.kid)
+			if secret != tt.wantSecret || ok != tt.wantOK {
+				t.Errorf("lookup = %q, %v; want %q, %v", secret, ok, tt.wantSecret, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := newKeyRing("", "").lookup(""); ok {
+		t.Error("empty key ring resolved a secret")
+	}
+}
+
+func TestKeyRingSignNamesKeyWithoutLeakingIt(t *testing.T) {
+	token, err := newKeyRing("current", "").sign(jwt.RegisteredClaims{Subject: "user"})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != keyID("current") || kid == "current" {
+		t.Errorf("kid = %v, want the fingerprint %s", kid, keyID("current"))
+	}
+
+	if _, err := newKeyRing("", "").sign(jwt.RegisteredClaims{}); err == nil {
+		t.Error("signing without a secret succeeded")
+	}
+}
+
+func TestPlatformTokenSurvivesRotation(t *testing.T) {
+	usePlatformSecret(t)
+	t.Setenv("JWT_SECRET", "old-secret")
+	token := mustGenerateJWT(t, testAdmin())
+
+	tests := []struct {
+		name     string
+		previous string
+		wantErr  bool
+	}{
+		{"old secret still listed", "old-secret", false},
+		{"old secret retired", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "new-secret")
+			t.Setenv("JWT_PREVIOUS_SECRETS", tt.previous)
+			if _, err := ValidateJWTToken(token); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWTToken error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := ValidateJWTToken(mustGenerateJWT(t, testAdmin())); err != nil {
+				t.Errorf("token signed with the new secret rejected: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginRouter - LoginRateLimit in front of a handler that echoes the body it received
+func loginRouter(t *testing.T) func(ip, email string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("LOGIN_ATTEMPTS_PER_IP_MINUTE", "3")
+	t.Setenv("LOGIN_ATTEMPTS_PER_EMAIL_MINUTE", "2")
+
+	r := gin.New()
+	r.POST("/login", LoginRateLimit(), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return func(ip, email string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"`+email+`","password":"secret"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":40000"
+		r.ServeHTTP(w, req)
+		return w
+	}
+}
+
+func TestLoginRateLimitPerIP(t *testing.T) {
+	useTestClock(t)
+	login := loginRouter(t)
+
+	// Different emails keep the per-email limit out of the way
+	for i, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if w := login("192.0.2.1", email); w.Code != http.StatusOK {
+			t.Fatalf("attempt %d: status = %d", i+1, w.Code)
+		}
+	}
+
+	w := login("192.0.2.1", "d@example.com")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("fourth attempt from one IP: status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || !strings.Contains(w.Body.String(), "RATE_LIMIT_EXCEEDED") {
+		t.Errorf("throttled response lacks Retry-After or code: %v %s", w.Header(), w.Body)
+	}
+	if w := login("198.51.100.7", "e@example.com"); w.Code != http.StatusOK {
+		t.Errorf("another IP was throttled: status = %d", w.Code)
+	}
+}
+
+func TestLoginRateLimitPerEmail(t *testing.T) {
+	useTestClock(t)
+	login := loginRouter(t)
+
+	if w := login("192.0.2.1", "victim@example.com"); w.Code != http.StatusOK {
+		t.Fatalf("first attempt: status = %d", w.Code)
+	}
+	// The email is normalised, so case and padding do not buy extra attempts
+	if w := login("192.0.2.2", " Victim@Example.com "); w.Code != http.StatusOK {
+		t.Fatalf("second attempt: status = %d", w.Code)
+	}
+	if w := login("192.0.2.3", "VICTIM@example.com"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third attempt on one email from a new IP: status = %d, want 429", w.Code)
+	}
+	if w := login("192.0.2.3", "other@example.com"); w.Code != http.StatusOK {
+		t.Errorf("other email was throttled: status = %d", w.Code)
+	}
+}
+
+func TestLoginRateLimitWindowResets(t *testing.T) {
+	advance := useTestClock(t)
+	login := loginRouter(t)
+
+	for i := 0; i < 2; i++ {
+		login("192.0.2.1", "user@example.com")
+	}
+	w := login("192.0.2.1", "user@example.com")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "61" {
+		t.Errorf("Retry-After = %q, want the rest of the minute", got)
+	}
+
+	advance(time.Minute + time.Second)
+	w = login("192.0.2.1", "user@example.com")
+	if w.Code != http.StatusOK {
+		t.Fatalf("after the window: status = %d, want 200", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"email":"user@example.com"`) {
+		t.Errorf("handler did not receive the login body: %q", w.Body)
+	}
+}
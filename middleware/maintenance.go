@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+// MaintenanceGate - Reject chat and widget requests with 503 while maintenance mode is on.
+// Only widget-facing routes use it; admin routes stay available to turn maintenance off.
+func MaintenanceGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := config.GetMaintenanceMode()
+		if !state.Enabled {
+			c.Next()
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = config.DefaultMaintenanceMessage
+		}
+
+		c.Header("Retry-After", "300")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":       message,
+			"code":        "MAINTENANCE",
+			"maintenance": true,
+			"retry_after": 300,
+		})
+		c.Abort()
+	}
+}
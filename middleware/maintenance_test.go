@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"jevi-chat/config"
+)
+
+func TestMaintenanceGate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := config.DB
+	config.DB = nil
+	t.Cleanup(func() { config.DB = previous })
+
+	r := gin.New()
+	r.GET("/chat", MaintenanceGate(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/chat", nil))
+		return w
+	}
+
+	t.Setenv("MAINTENANCE_MODE", "")
+	if w := get(); w.Code != http.StatusOK {
+		t.Errorf("maintenance off: status = %d, want 200", w.Code)
+	}
+
+	t.Setenv("MAINTENANCE_MODE", "true")
+	t.Setenv("MAINTENANCE_MESSAGE", "")
+	w := get()
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "300" {
+		t.Errorf("got %d Retry-After %q, want 503 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(w.Body.String(), `"code":"MAINTENANCE"`) || !strings.Contains(w.Body.String(), config.DefaultMaintenanceMessage) {
+		t.Errorf("body = %s, want the default message", w.Body)
+	}
+
+	t.Setenv("MAINTENANCE_MESSAGE", "Back at noon")
+	if w := get(); !strings.Contains(w.Body.String(), "Back at noon") {
+		t.Errorf("body = %s, want the configured message", w.Body)
+	}
+}
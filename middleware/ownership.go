@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// ProjectOwnershipMiddleware - Only let the project's owner (or an admin) through on user-facing
+// project routes. param names the route parameter holding the project id (project_id or _id).
+// Missing and foreign projects get the same 403 so the route can't be used to probe ids.
+// Must run after AuthMiddleware; puts the project in the context as "project", and its project_id
+// as "owned_project_id" (the tenant boundary handlers scope their queries to).
+func ProjectOwnershipMiddleware(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID := c.Param(param)
+		userID := c.GetString("user_id")
+		email := c.GetString("user_email")
+
+		project, err := findOwnedProject(c.Request.Context(), projectID)
+		if err == nil && (c.GetString("user_role") == "admin" || ownerCanAccess(project, userID, email)) {
+			c.Set("project", project)
+			c.Set("project_id", project.ProjectID)
+			c.Set("owned_project_id", project.ProjectID)
+			c.Next()
+			return
+		}
+
+		log.Printf("🚫 User %s denied access to project %s", email, projectID)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You don't have access to this project",
+			"code":  "PROJECT_FORBIDDEN",
+		})
+		c.Abort()
+	}
+}
+
+// ownerCanAccess - Owners see their projects until the project is deleted
+func ownerCanAccess(project *models.Project, userID, email string) bool {
+	return project.IsOwnedBy(userID, email) && project.IsActive && project.Status != models.ProjectStatusDeleted
+}
+
+// findOwnedProject - Look a project up by project_id, then by _id
+func findOwnedProject(parent context.Context, projectID string) (*models.Project, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"project_id": projectID}
+	if objectID, err := primitive.ObjectIDFromHex(projectID); err == nil {
+		filter = bson.M{"$or": []bson.M{{"project_id": projectID}, {"_id": objectID}}}
+	}
+
+	var project models.Project
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		return config.GetProjectsCollection().FindOne(ctx, filter).Decode(&project)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestOwnerCanAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		project models.Project
+		want    bool
+	}{
+		{"active", models.Project{OwnerID: "user_1", IsActive: true, Status: "active"}, true},
+		{"suspended", models.Project{OwnerID: "user_1", IsActive: true, Status: "suspended"}, true},
+		{"expired", models.Project{OwnerID: "user_1", IsActive: true, Status: "expired"}, true},
+		{"deleted", models.Project{OwnerID: "user_1", IsActive: true, Status: "deleted"}, false},
+		{"deactivated", models.Project{OwnerID: "user_1", IsActive: false, Status: "active"}, false},
+		{"not the owner", models.Project{OwnerID: "user_2", IsActive: true, Status: "active"}, false},
+	}
+	for _, tt := range tests {
+		if got := ownerCanAccess(&tt.project, "user_1", "a@example.com"); got != tt.want {
+			t.Errorf("%s: ownerCanAccess = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjectOwnershipMiddleware(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	owned := models.Project{
+		ID: primitive.NewObjectID(), ProjectID: "proj_owned", OwnerID: "user_1",
+		Status: "active", IsActive: true, ExpiryDate: time.Now().AddDate(0, 1, 0),
+	}
+	foreign := models.Project{
+		ID: primitive.NewObjectID(), ProjectID: "proj_foreign", OwnerID: "user_2",
+		Status: "active", IsActive: true, ExpiryDate: time.Now().AddDate(0, 1, 0),
+	}
+	for _, p := range []models.Project{owned, foreign} {
+		if _, err := config.GetProjectsCollection().InsertOne(ctx, p); err != nil {
+			t.Fatalf("insert %s: %v", p.ProjectID, err)
+		}
+	}
+
+	tests := []struct {
+		name, userID, role, projectID string
+		wantStatus                    int
+	}{
+		{"owner by project_id", "user_1", "user", "proj_owned", http.StatusOK},
+		{"owner by _id", "user_1", "user", owned.ID.Hex(), http.StatusOK},
+		{"someone else's project", "user_1", "user", "proj_foreign", http.StatusForbidden},
+		// A missing project looks the same as a foreign one
+		{"missing project", "user_1", "user", "proj_missing", http.StatusForbidden},
+		{"admin", "admin_1", "admin", "proj_foreign", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/user/projects/:id", func(c *gin.Context) {
+				c.Set("user_id", tt.userID)
+				c.Set("user_role", tt.role)
+			}, ProjectOwnershipMiddleware("id"), func(c *gin.Context) {
+				project := c.MustGet("project").(*models.Project)
+				c.JSON(http.StatusOK, gin.H{"project_id": project.ProjectID, "owned_project_id": c.GetString("owned_project_id")})
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/projects/"+tt.projectID, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			var body map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if tt.wantStatus == http.StatusForbidden {
+				if body["code"] != "PROJECT_FORBIDDEN" {
+					t.Errorf("code = %v, want PROJECT_FORBIDDEN", body["code"])
+				}
+				return
+			}
+			if body["owned_project_id"] != body["project_id"] || body["project_id"] == "" {
+				t.Errorf("context = %v, want the project and its tenant boundary set", body)
+			}
+		})
+	}
+}
+
+func TestFindOwnedProjectStopsWithTheRequest(t *testing.T) {
+	ctx := useTestDatabase(t)
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, models.Project{ProjectID: "proj_ctx", IsActive: true, Status: "active"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if project, err := findOwnedProject(ctx, "proj_ctx"); err != nil || project.ProjectID != "proj_ctx" {
+		t.Fatalf("findOwnedProject = %v, %v", project, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := findOwnedProject(cancelled, "proj_ctx"); err == nil {
+		t.Error("lookup with a cancelled request context should fail")
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"time"
-   
+
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 
@@ -40,12 +40,18 @@ func SubscriptionValidator() gin.HandlerFunc {
 		log.Printf("🔍 Validating subscription for project: %s", projectID)
 
 		// Get project with subscription validation
-		project, validationError := validateProjectSubscription(projectID)
+		project, validationError := validateProjectSubscription(c.Request.Context(), projectID)
 		if validationError != nil {
 			log.Printf("❌ Subscription validation failed for %s: %s", projectID, validationError.Error())
 
+			code := "SUBSCRIPTION_BLOCKED"
+			if subErr, ok := validationError.(*subscriptionError); ok {
+				code = subErr.Code
+			}
+
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":      validationError.Error(),
+				"code":       code,
 				"status":     "subscription_blocked",
 				"project_id": projectID,
 				"timestamp":  time.Now(),
@@ -54,6 +60,18 @@ func SubscriptionValidator() gin.HandlerFunc {
 			return
 		}
 
+		// A paused bot is still an active subscription; visitors just get a friendly notice
+		if project.ChatPaused {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":      project.ChatUnavailableMessage(),
+				"code":       "CHAT_PAUSED",
+				"status":     "paused",
+				"project_id": projectID,
+			})
+			c.Abort()
+			return
+		}
+
 		// Add project to context for use in handlers
 		c.Set("project", project)
 		c.Set("project_id", projectID)
@@ -74,28 +92,43 @@ func TokenLimitValidator() gin.HandlerFunc {
 
 		projectInterface, exists := c.Get("project")
 		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Project not found in context"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Project not found in context", "code": "INTERNAL_ERROR"})
 			c.Abort()
 			return
 		}
 
 		project, ok := projectInterface.(*models.Project)
 		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid project data in context"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid project data in context", "code": "INTERNAL_ERROR"})
 			c.Abort()
 			return
 		}
 
-		// Check if project has reached token limit
-		if project.TotalTokensUsed >= project.MonthlyTokenLimit {
+		// Check if project has reached token limit; what happens next is the project's overage policy
+		if project.IsOverLimit() {
 			usagePercent := float64(project.TotalTokensUsed) / float64(project.MonthlyTokenLimit) * 100
+			policy := project.GetOveragePolicy()
 
-			log.Printf("🚫 Token limit exceeded for project %s: %d/%d tokens (%.1f%%)",
-				project.ProjectID, project.TotalTokensUsed, project.MonthlyTokenLimit, usagePercent)
+			if policy == models.OveragePolicyAllow {
+				// Keep serving; the chat handler records the tokens beyond the limit as billable overage
+				c.Header("X-Usage-Warning", fmt.Sprintf("Over monthly limit: %.1f%% used, overage is billable", usagePercent))
+				c.Next()
+				return
+			}
+
+			log.Printf("🚫 Token limit exceeded for project %s: %d/%d tokens (%.1f%%), policy %s",
+				project.ProjectID, project.TotalTokensUsed, project.MonthlyTokenLimit, usagePercent, policy)
+
+			code := "LIMIT_EXCEEDED"
+			if policy == models.OveragePolicySuspend {
+				suspendProjectForOverage(project)
+				code = "PROJECT_SUSPENDED"
+			}
 
 			c.JSON(http.StatusOK, gin.H{
 				"response": "Monthly usage limit reached. Please upgrade your plan or contact support.",
 				"status":   "limit_exceeded",
+				"code":     code,
 				"usage": gin.H{
 					"tokens_used":   project.TotalTokensUsed,
 					"token_limit":   project.MonthlyTokenLimit,
@@ -140,12 +173,23 @@ func RateLimitValidator() gin.HandlerFunc {
 			return
 		}
 
+		// The IP bucket always applies: visitor tokens are free to mint, so a per-visitor bucket
+		// alone would reset every time a client dropped its token. Visitors whose signed token
+		// verified also get their own bucket, so one busy visitor can't starve a shared IP.
+		requester := c.ClientIP()
+		allowed := checkProjectIPRateLimit(project, requester)
+		if allowed && c.GetBool("visitor_verified") {
+			requester = c.GetString("visitor_id")
+			allowed = checkProjectRateLimit(project, requester)
+		}
+
 		// Check rate limits based on project status
-		if !checkProjectRateLimit(project, getClientIP(c)) {
-			log.Printf("🚫 Rate limit exceeded for project %s from IP %s", project.ProjectID, getClientIP(c))
+		if !allowed {
+			log.Printf("🚫 Rate limit exceeded for project %s from %s", project.ProjectID, requester)
 
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded. Please wait before sending another message.",
+				"code":        "RATE_LIMIT_EXCEEDED",
 				"status":      "rate_limited",
 				"retry_after": 60, // seconds
 			})
@@ -171,10 +215,11 @@ func ProjectAccessValidator() gin.HandlerFunc {
 		}
 
 		// Check if project exists and is accessible
-		project, err := getProjectForValidation(projectID)
+		project, err := getProjectForValidation(c.Request.Context(), projectID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":      "Project not found or access denied",
+				"code":       "PROJECT_NOT_FOUND",
 				"project_id": projectID,
 			})
 			c.Abort()
@@ -185,6 +230,7 @@ func ProjectAccessValidator() gin.HandlerFunc {
 		if project.Status == "deleted" || !project.IsActive {
 			c.JSON(http.StatusGone, gin.H{
 				"error":      "This project has been deleted or is no longer available",
+				"code":       "PROJECT_DELETED",
 				"project_id": projectID,
 			})
 			c.Abort()
@@ -215,30 +261,47 @@ func SubscriptionMaintenanceValidator() gin.HandlerFunc {
 
 // Helper Functions
 
+// subscriptionError - Validation failure carrying the machine-readable code sent to clients
+type subscriptionError struct {
+	Code    string
+	Message string
+}
+
+func (e *subscriptionError) Error() string {
+	return e.Message
+}
+
 // validateProjectSubscription - Comprehensive project subscription validation
-func validateProjectSubscription(projectID string) (*models.Project, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func validateProjectSubscription(parent context.Context, projectID string) (*models.Project, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
 	defer cancel()
 
 	collection := config.GetProjectsCollection()
 
 	var project models.Project
-	err := collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
+	err := config.RetryRead(ctx, func(ctx context.Context) error {
+		return collection.FindOne(ctx, bson.M{"project_id": projectID}).Decode(&project)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("Project not found or invalid")
+		return nil, &subscriptionError{"PROJECT_NOT_FOUND", "Project not found or invalid"}
+	}
+
+	// Ended trials are refused straight away; the maintenance job suspends them and notifies the client
+	if project.TrialExpired() {
+		return nil, &subscriptionError{"TRIAL_ENDED", "Your free trial has ended. Please upgrade to continue"}
 	}
 
 	// Check if project is active
 	if project.Status != "active" {
 		switch project.Status {
 		case "expired":
-			return nil, fmt.Errorf("Your subscription has expired. Please renew to continue")
+			return nil, &subscriptionError{"SUBSCRIPTION_EXPIRED", "Your subscription has expired. Please renew to continue"}
 		case "suspended":
-			return nil, fmt.Errorf("Your account is suspended. Please contact support")
+			return nil, &subscriptionError{"PROJECT_SUSPENDED", "Your account is suspended. Please contact support"}
 		case "deleted":
-			return nil, fmt.Errorf("This project has been deleted")
+			return nil, &subscriptionError{"PROJECT_DELETED", "This project has been deleted"}
 		default:
-			return nil, fmt.Errorf("Your account is inactive. Please contact support")
+			return nil, &subscriptionError{"PROJECT_INACTIVE", "Your account is inactive. Please contact support"}
 		}
 	}
 
@@ -246,22 +309,46 @@ func validateProjectSubscription(projectID string) (*models.Project, error) {
 	if time.Now().After(project.ExpiryDate) {
 		// Auto-update status to expired
 		go updateProjectStatusAsync(projectID, "expired")
-		return nil, fmt.Errorf("Your subscription has expired. Please renew to continue")
+		return nil, &subscriptionError{"SUBSCRIPTION_EXPIRED", "Your subscription has expired. Please renew to continue"}
 	}
 
 	// Check if project is soft deleted
 	if !project.IsActive {
-		return nil, fmt.Errorf("This project is no longer available")
+		return nil, &subscriptionError{"PROJECT_DELETED", "This project is no longer available"}
 	}
 
 	return &project, nil
 }
 
-// getProjectForValidation - Get project for basic validation
-func getProjectForValidation(projectID string) (*models.Project, error) {
+// suspendProjectForOverage - Suspend an active project that hit its limit under the suspend
+// policy; only the request that flips the status notifies the client
+func suspendProjectForOverage(project *models.Project) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	result, err := config.GetProjectsCollection().UpdateOne(ctx,
+		bson.M{"project_id": project.ProjectID, "status": "active"},
+		bson.M{"$set": bson.M{"status": "suspended", "updated_at": time.Now()}},
+	)
+	if err != nil {
+		log.Printf("❌ Failed to suspend project %s on overage: %v", project.ProjectID, err)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		return
+	}
+
+	log.Printf("⛔ Project %s suspended after reaching its token limit", project.ProjectID)
+	go config.LogNotification(project.ID, "overage_suspended", fmt.Sprintf(
+		"Project %s reached its monthly limit of %d tokens and has been suspended. Upgrade or renew to reactivate.",
+		project.Name, project.MonthlyTokenLimit))
+}
+
+// getProjectForValidation - Get project for basic validation
+func getProjectForValidation(parent context.Context, projectID string) (*models.Project, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
 	collection := config.GetProjectsCollection()
 
 	var project models.Project
@@ -295,24 +382,34 @@ func updateProjectStatusAsync(projectID, status string) {
 	}
 }
 
+func checkProjectRateLimit(project *models.Project, requester string) bool {
+	// Define identifier using project ID and the verified visitor
+	identifier := fmt.Sprintf("%s:%s", project.ProjectID, requester)
+
+	// Example rate limits by project status:
+	switch project.Status {
+	case "active":
+		return checkRateLimit(identifier, 60, time.Minute) // 60 requests per minute
+	case "suspended":
+		return false // No access for suspended projects
+	default:
+		return checkRateLimit(identifier, 30, time.Minute) // 30 requests per minute
+	}
+}
 
+// defaultChatPerIPMinute - Chat requests one IP may send to a project per minute
+// (CHAT_RATE_LIMIT_PER_IP_MINUTE); higher than the per-visitor limit to allow for shared IPs
+const defaultChatPerIPMinute = 120
 
-func checkProjectRateLimit(project *models.Project, clientIP string) bool {
-    // Define identifier using project ID and client IP
-    identifier := fmt.Sprintf("%s:%s", project.ProjectID, clientIP)
-    
-    // Example rate limits by project status:
-    switch project.Status {
-    case "active":
-        return !checkRateLimit(identifier, 60, time.Minute) // 60 requests per minute
-    case "suspended":
-        return false // No access for suspended projects
-    default:
-        return !checkRateLimit(identifier, 30, time.Minute) // 30 requests per minute
-    }
+// checkProjectIPRateLimit - Per-IP chat limit, applied whether or not the visitor is identified
+func checkProjectIPRateLimit(project *models.Project, clientIP string) bool {
+	if project.Status == "suspended" {
+		return false
+	}
+	identifier := fmt.Sprintf("%s:ip:%s", project.ProjectID, clientIP)
+	return checkRateLimit(identifier, envInt("CHAT_RATE_LIMIT_PER_IP_MINUTE", defaultChatPerIPMinute), time.Minute)
 }
 
-
 // performSubscriptionMaintenance - Perform automatic subscription maintenance
 func performSubscriptionMaintenance() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -426,7 +523,7 @@ func SubscriptionLogger() gin.HandlerFunc {
 				projectID,
 				c.Request.Method,
 				c.Request.URL.Path,
-				getClientIP(c),
+				c.ClientIP(),
 			)
 		}
 
@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// useTestDatabase - Point config.DB at a throwaway database on MONGODB_TEST_URI, dropped when the
+// test ends. Tests that need MongoDB are skipped when the variable is unset.
+func useTestDatabase(t *testing.T) context.Context {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	previous := config.DB
+	config.DB = client.Database(fmt.Sprintf("troika_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		config.DB.Drop(context.Background())
+		client.Disconnect(context.Background())
+		config.DB = previous
+	})
+	return ctx
+}
+
+// rateLimitedChat - A chat route behind RateLimitValidator with project and visitor preset
+func rateLimitedChat(project *models.Project, visitor func(c *gin.Context)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/projects/:projectId/chat", func(c *gin.Context) {
+		c.Set("project", project)
+		visitor(c)
+		c.Next()
+	}, RateLimitValidator(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func sendChats(r *gin.Engine, projectID string, n int) (lastStatus int) {
+	for i := 0; i < n; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/projects/"+projectID+"/chat", nil)
+		req.RemoteAddr = "203.0.113.10:4000"
+		r.ServeHTTP(w, req)
+		lastStatus = w.Code
+	}
+	return lastStatus
+}
+
+func TestRateLimitValidatorIgnoresRotatedVisitorIDs(t *testing.T) {
+	t.Setenv("CHAT_RATE_LIMIT_PER_IP_MINUTE", "5")
+	project := &models.Project{ProjectID: fmt.Sprintf("proj_rotate_%d", time.Now().UnixNano()), Status: "active"}
+
+	n := 0
+	r := rateLimitedChat(project, func(c *gin.Context) {
+		// A client dropping its token is issued a new, unverified visitor id every time
+		n++
+		c.Set("visitor_id", fmt.Sprintf("vis_%d", n))
+		c.Set("visitor_verified", false)
+	})
+
+	if status := sendChats(r, project.ProjectID, 5); status != http.StatusOK {
+		t.Fatalf("requests within the IP limit got %d", status)
+	}
+	if status := sendChats(r, project.ProjectID, 1); status != http.StatusTooManyRequests {
+		t.Errorf("request over the IP limit got %d, want 429", status)
+	}
+}
+
+func TestRateLimitValidatorLimitsVerifiedVisitor(t *testing.T) {
+	t.Setenv("CHAT_RATE_LIMIT_PER_IP_MINUTE", "1000")
+	project := &models.Project{ProjectID: fmt.Sprintf("proj_visitor_%d", time.Now().UnixNano()), Status: "active"}
+
+	r := rateLimitedChat(project, func(c *gin.Context) {
+		c.Set("visitor_id", "vis_same")
+		c.Set("visitor_verified", true)
+	})
+
+	if status := sendChats(r, project.ProjectID, 60); status != http.StatusOK {
+		t.Fatalf("requests within the visitor limit got %d", status)
+	}
+	if status := sendChats(r, project.ProjectID, 1); status != http.StatusTooManyRequests {
+		t.Errorf("request over the visitor limit got %d, want 429", status)
+	}
+}
+
+func TestVisitorIdentityMarksOnlyPresentedTokensVerified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("VISITOR_TOKEN_SECRET", "test-secret")
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"no token", "", false},
+		{"forged token", "vis_abc.deadbeef", false},
+		{"signed token", signVisitorID("vis_abc"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			var verified bool
+			r.GET("/", VisitorIdentity(), func(c *gin.Context) { verified = c.GetBool("visitor_verified") })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set(VisitorHeaderName, tt.token)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if verified != tt.want {
+				t.Errorf("visitor_verified = %v, want %v", verified, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenLimitValidatorErrorCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		project    interface{}
+		wantStatus int
+		wantCode   string
+	}{
+		{"no project in context", nil, http.StatusInternalServerError, "INTERNAL_ERROR"},
+		{"wrong type in context", "proj_1", http.StatusInternalServerError, "INTERNAL_ERROR"},
+		{"over the limit", &models.Project{ProjectID: "proj_1", MonthlyTokenLimit: 100, TotalTokensUsed: 100}, http.StatusOK, "LIMIT_EXCEEDED"},
+		{"within the limit", &models.Project{ProjectID: "proj_1", MonthlyTokenLimit: 100, TotalTokensUsed: 10}, http.StatusNoContent, ""},
+		{"explicit block policy", &models.Project{ProjectID: "proj_1", MonthlyTokenLimit: 100, TotalTokensUsed: 150, OveragePolicy: models.OveragePolicyBlock}, http.StatusOK, "LIMIT_EXCEEDED"},
+		{"over the limit with overage allowed", &models.Project{ProjectID: "proj_1", MonthlyTokenLimit: 100, TotalTokensUsed: 150, OveragePolicy: models.OveragePolicyAllow}, http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/api/projects/:projectId/chat", func(c *gin.Context) {
+				if tt.project != nil {
+					c.Set("project", tt.project)
+				}
+			}, TokenLimitValidator(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/projects/proj_1/chat", nil))
+
+			var body map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if code, _ := body["code"].(string); code != tt.wantCode {
+				t.Errorf("code = %q, want %q", code, tt.wantCode)
+			}
+			if project, _ := tt.project.(*models.Project); project != nil && project.GetOveragePolicy() == models.OveragePolicyAllow {
+				if w.Header().Get("X-Usage-Warning") == "" {
+					t.Error("billable overage served without an X-Usage-Warning header")
+				}
+			}
+		})
+	}
+}
+
+func TestTokenLimitValidatorSuspendsOnOverage(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	project := &models.Project{
+		ID: primitive.NewObjectID(), ProjectID: "proj_1", Name: "Acme", Status: "active",
+		MonthlyTokenLimit: 100, TotalTokensUsed: 100, OveragePolicy: models.OveragePolicySuspend,
+	}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/api/projects/:projectId/chat", func(c *gin.Context) {
+		c.Set("project", project)
+	}, TokenLimitValidator(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/projects/proj_1/chat", nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if body["code"] != "PROJECT_SUSPENDED" {
+			t.Errorf("request %d: code = %v, want PROJECT_SUSPENDED", i+1, body["code"])
+		}
+	}
+
+	var stored models.Project
+	if err := config.GetProjectsCollection().FindOne(ctx, bson.M{"project_id": "proj_1"}).Decode(&stored); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if stored.Status != "suspended" {
+		t.Errorf("status = %q, want suspended", stored.Status)
+	}
+
+	// Only the request that suspended the project notifies the client; the notification is logged in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, err := config.GetNotificationsCollection().CountDocuments(ctx, bson.M{"type": "overage_suspended"})
+		if err == nil && n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("overage_suspended notifications = %d (%v), want 1", n, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSubscriptionValidatorPausedChat(t *testing.T) {
+	ctx := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+
+	active := func(projectID string) models.Project {
+		return models.Project{ProjectID: projectID, Status: "active", IsActive: true, ExpiryDate: time.Now().AddDate(0, 1, 0)}
+	}
+	paused := active("paused")
+	paused.ChatPaused, paused.PauseMessage = true, "Closed for the holidays"
+	pausedDefault := active("paused_default")
+	pausedDefault.ChatPaused = true
+	if _, err := config.GetProjectsCollection().InsertMany(ctx, []interface{}{active("running"), paused, pausedDefault}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/api/projects/:projectId/chat", SubscriptionValidator(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	tests := []struct {
+		projectID   string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"running", http.StatusNoContent, ""},
+		{"paused", http.StatusServiceUnavailable, "Closed for the holidays"},
+		{"paused_default", http.StatusServiceUnavailable, models.DefaultPauseMessage},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/projects/"+tt.projectID+"/chat", nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.projectID, w.Code, tt.wantStatus)
+		}
+		if tt.wantMessage != "" && (body["error"] != tt.wantMessage || body["code"] != "CHAT_PAUSED") {
+			t.Errorf("%s: body = %v, want CHAT_PAUSED with %q", tt.projectID, body, tt.wantMessage)
+		}
+	}
+}
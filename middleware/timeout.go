@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutBaseKey - Request context before any Timeout was applied, so a route-level
+// Timeout replaces the group's deadline instead of being capped by it
+const timeoutBaseKey = "timeout_base_context"
+
+// Timeout - Give the request context a deadline of d. Handlers pass c.Request.Context() to
+// database and API calls, so work stops once the deadline passes; if the handler then hasn't
+// written a response, the client gets 504. The innermost Timeout on a route wins.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var base context.Context
+		if v, exists := c.Get(timeoutBaseKey); exists {
+			base = v.(context.Context)
+		} else {
+			base = c.Request.Context()
+			c.Set(timeoutBaseKey, base)
+		}
+
+		ctx, cancel := context.WithTimeout(base, d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			log.Printf("⏱️ %s %s exceeded its %v deadline", c.Request.Method, c.FullPath(), d)
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+				"error": "The request took too long to complete",
+				"code":  "REQUEST_TIMEOUT",
+			})
+		}
+	}
+}
+
+// EnvTimeout - Timeout read from an env var in seconds, or defaultValue
+func EnvTimeout(key string, defaultValue time.Duration) time.Duration {
+	return time.Duration(envInt(key, int(defaultValue/time.Second))) * time.Second
+}
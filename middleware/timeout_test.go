@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	waitForDeadline := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}
+
+	tests := []struct {
+		name       string
+		handler    gin.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{"fast handler", func(c *gin.Context) { c.String(http.StatusOK, "done") }, http.StatusOK, "done"},
+		{"slow handler without response", waitForDeadline, http.StatusGatewayTimeout, "REQUEST_TIMEOUT"},
+		{"slow handler that responded", func(c *gin.Context) {
+			waitForDeadline(c)
+			c.String(http.StatusServiceUnavailable, "gave up")
+		}, http.StatusServiceUnavailable, "gave up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", Timeout(20*time.Millisecond), tt.handler)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got %d %s, want %d %s", w.Code, w.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTimeoutInnermostWins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		group, route time.Duration
+	}{
+		{"route longer than group", 10 * time.Millisecond, time.Hour},
+		{"route shorter than group", time.Hour, 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+			r := gin.New()
+			r.GET("/", Timeout(tt.group), Timeout(tt.route), func(c *gin.Context) {
+				deadline, _ := c.Request.Context().Deadline()
+				remaining = time.Until(deadline)
+				c.Status(http.StatusNoContent)
+			})
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if remaining > tt.route || remaining < tt.route-time.Second {
+				t.Errorf("handler deadline in %v, want the route's %v", remaining, tt.route)
+			}
+		})
+	}
+}
+
+func TestEnvTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 30 * time.Second},
+		{"5", 5 * time.Second},
+		{"0", 30 * time.Second},
+		{"soon", 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("TEST_ROUTE_TIMEOUT", tt.value)
+		if got := EnvTimeout("TEST_ROUTE_TIMEOUT", 30*time.Second); got != tt.want {
+			t.Errorf("EnvTimeout(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
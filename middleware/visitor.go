@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Anonymous visitor identity: the widget keeps a signed token (cookie or header)
+// so returning visitors can be recognised without an account.
+const (
+	VisitorCookieName = "troika_vid"
+	VisitorHeaderName = "X-Visitor-Token"
+
+	visitorTokenMaxAge = 365 * 24 * 60 * 60 // one year, in seconds
+)
+
+// VisitorIdentity - Resolve or issue the anonymous visitor token for widget requests.
+// Sets "visitor_id", "visitor_token" and "visitor_new" in the context, and "visitor_verified" when
+// the request carried a valid token (rather than being issued a fresh one).
+func VisitorIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(VisitorHeaderName)
+		if token == "" {
+			token, _ = c.Cookie(VisitorCookieName)
+		}
+
+		visitorID, ok := ParseVisitorToken(token)
+		isNew := false
+		if !ok {
+			visitorID = newVisitorID()
+			token = signVisitorID(visitorID)
+			isNew = true
+		}
+
+		c.Set("visitor_id", visitorID)
+		c.Set("visitor_token", token)
+		c.Set("visitor_new", isNew)
+		c.Set("visitor_verified", ok)
+
+		// Widgets run cross-site, so the cookie must be SameSite=None; the header is the fallback
+		// for browsers that block third-party cookies.
+		c.Header(VisitorHeaderName, token)
+		if isNew {
+			c.SetSameSite(http.SameSiteNoneMode)
+			c.SetCookie(VisitorCookieName, token, visitorTokenMaxAge, "/", "", true, true)
+		}
+
+		c.Next()
+	}
+}
+
+// ParseVisitorToken - Verify a visitor token and return the visitor id it carries
+func ParseVisitorToken(token string) (string, bool) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", false
+	}
+
+	expected := signVisitorID(parts[0])
+	if !hmac.Equal([]byte(expected), []byte(token)) {
+		return "", false
+	}
+
+	return parts[0], true
+}
+
+// signVisitorID - Produce "<visitorID>.<hmac>" using the visitor secret
+func signVisitorID(visitorID string) string {
+	mac := hmac.New(sha256.New, visitorSecret())
+	mac.Write([]byte(visitorID))
+	return visitorID + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// visitorSecret - VISITOR_TOKEN_SECRET, falling back to JWT_SECRET
+func visitorSecret() []byte {
+	if secret := os.Getenv("VISITOR_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// newVisitorID - Random, URL-safe visitor identifier
+func newVisitorID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "vis_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import "testing"
+
+func TestParseVisitorToken(t *testing.T) {
+	t.Setenv("VISITOR_TOKEN_SECRET", "visitor-secret")
+	signed := signVisitorID("vis_abc")
+
+	tests := []struct {
+		name   string
+		token  string
+		wantID string
+		wantOK bool
+	}{
+		{"signed token", signed, "vis_abc", true},
+		{"tampered signature", signed[:len(signed)-1] + "0", "", false},
+		{"swapped visitor id", "vis_xyz" + signed[len("vis_abc"):], "", false},
+		{"no signature", "vis_abc", "", false},
+		{"empty id", "." + signed[len("vis_abc")+1:], "", false},
+		{"empty token", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ok := ParseVisitorToken(tt.token)
+			if id != tt.wantID || ok != tt.wantOK {
+				t.Errorf("ParseVisitorToken(%q) = %q, %v; want %q, %v", tt.token, id, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+
+	t.Setenv("VISITOR_TOKEN_SECRET", "rotated-secret")
+	if _, ok := ParseVisitorToken(signed); ok {
+		t.Error("token signed with another secret was accepted")
+	}
+}
+
+func TestNewVisitorIDIsUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := newVisitorID()
+		if len(id) != len("vis_")+24 || seen[id] {
+			t.Fatalf("bad or repeated visitor id %q", id)
+		}
+		seen[id] = true
+	}
+}
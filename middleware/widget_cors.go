@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WidgetCORSKey - Context flag set on widget API requests so the dashboard CORS middlewares stand aside
+const WidgetCORSKey = "widget_cors"
+
+// widgetOriginsTTL - How long a project's allowed domains are cached between lookups
+const widgetOriginsTTL = time.Minute
+
+// maxWidgetOriginsEntries - Upper bound on cached projects; expired entries are swept first
+const maxWidgetOriginsEntries = 10000
+
+// widgetOriginPolicy - How the widget API answers a given Origin
+type widgetOriginPolicy int
+
+const (
+	// originDenied - Unknown project, or an origin outside the project's allowed domains
+	originDenied widgetOriginPolicy = iota
+	// originAny - Project without allowed domains: "*" without credentials, identity via X-Visitor-Token
+	originAny
+	// originAllowed - Origin matches the project's allowed domains: reflected with credentials
+	originAllowed
+)
+
+type cachedWidgetOrigins struct {
+	domains []string
+	expires time.Time
+}
+
+var (
+	widgetOriginsMu    sync.Mutex
+	widgetOriginsCache = make(map[string]cachedWidgetOrigins)
+
+	// lookupWidgetDomains - Load a project's allowed domains by project_id or _id
+	lookupWidgetDomains = func(projectID string) ([]string, error) {
+		project, err := findOwnedProject(context.Background(), projectID)
+		if err != nil {
+			return nil, err
+		}
+		return project.WidgetSettings.AllowedDomains, nil
+	}
+)
+
+// WidgetCORSMiddleware - CORS for the project-scoped widget API, which is called from customer sites.
+// The Origin is checked against the project's widget_settings.allowed_domains instead of the
+// dashboard allowlist. A project with no allowed domains can be embedded anywhere, but only
+// without credentials; unknown projects are refused. Must be registered before the dashboard
+// CORS middlewares.
+func WidgetCORSMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isOpenEmbedPath(c.Request.URL.Path) {
+			// Read-only, credential-free endpoints any embedding site may call
+			c.Set(WidgetCORSKey, true)
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Content-Type, Accept")
+			c.Header("Access-Control-Max-Age", "600")
+			if c.Request.Method == "OPTIONS" {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		projectID, ok := widgetProjectFromPath(c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Set(WidgetCORSKey, true)
+
+		origin := c.Request.Header.Get("Origin")
+		c.Header("Vary", "Origin")
+
+		if origin != "" {
+			switch widgetOriginAllowed(projectID, origin) {
+			case originDenied:
+				log.Printf("❌ Widget CORS blocked origin %s for project %s", origin, projectID)
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "This site is not allowed to embed the chat widget",
+					"code":  "ORIGIN_NOT_ALLOWED",
+				})
+				return
+			case originAny:
+				c.Header("Access-Control-Allow-Origin", "*")
+			case originAllowed:
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+
+			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, Origin, X-Visitor-Token")
+			c.Header("Access-Control-Expose-Headers", "X-Visitor-Token, X-Request-Tokens, X-Request-Cost")
+			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Header("Access-Control-Max-Age", "600")
+		}
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// isOpenEmbedPath - Embed health/version endpoints, open to every origin
+func isOpenEmbedPath(path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	return path == "/api/embed/health" || path == "/api/embed/version"
+}
+
+// widgetProjectFromPath - Extract the project id from widget API paths:
+// /api/projects/:projectId/{chat,greeting,history,lead,session/...,subscription,quota} and /api/embed/:projectId/{config,auth}
+func widgetProjectFromPath(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "api" || parts[2] == "" {
+		return "", false
+	}
+
+	switch parts[1] {
+	case "projects":
+		switch parts[3] {
+		case "chat", "greeting", "history", "lead", "session", "subscription", "quota":
+			return parts[2], true
+		}
+	case "embed":
+		switch parts[3] {
+		case "config", "auth":
+			return parts[2], true
+		}
+	}
+
+	return "", false
+}
+
+// widgetOriginAllowed - Check an Origin header against the project's allowed embedding domains
+func widgetOriginAllowed(projectID, origin string) widgetOriginPolicy {
+	if os.Getenv("ENVIRONMENT") == "development" {
+		return originAllowed
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Hostname() == "" {
+		return originDenied
+	}
+	host := strings.ToLower(u.Hostname())
+
+	domains, found := widgetAllowedDomains(projectID)
+	if !found {
+		return originDenied
+	}
+	if len(domains) == 0 {
+		return originAny
+	}
+
+	for _, domain := range domains {
+		domain = NormalizeAllowedDomain(domain)
+		if domain == "" {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return originAllowed
+		}
+	}
+
+	return originDenied
+}
+
+// widgetAllowedDomains - Cached lookup of a project's allowed domains. Only existing projects are
+// cached, so requests for made-up project ids cannot fill the cache.
+func widgetAllowedDomains(projectID string) ([]string, bool) {
+	now := time.Now()
+
+	widgetOriginsMu.Lock()
+	entry, ok := widgetOriginsCache[projectID]
+	widgetOriginsMu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.domains, true
+	}
+
+	domains, err := lookupWidgetDomains(projectID)
+	if err != nil {
+		return nil, false
+	}
+	entry = cachedWidgetOrigins{domains: domains, expires: now.Add(widgetOriginsTTL)}
+
+	widgetOriginsMu.Lock()
+	if len(widgetOriginsCache) >= maxWidgetOriginsEntries {
+		sweepWidgetOrigins(now)
+	}
+	widgetOriginsCache[projectID] = entry
+	widgetOriginsMu.Unlock()
+
+	return entry.domains, true
+}
+
+// sweepWidgetOrigins - Drop expired entries, then arbitrary ones if the cache is still full.
+// Callers hold widgetOriginsMu.
+func sweepWidgetOrigins(now time.Time) {
+	for projectID, entry := range widgetOriginsCache {
+		if !now.Before(entry.expires) {
+			delete(widgetOriginsCache, projectID)
+		}
+	}
+	for projectID := range widgetOriginsCache {
+		if len(widgetOriginsCache) < maxWidgetOriginsEntries {
+			break
+		}
+		delete(widgetOriginsCache, projectID)
+	}
+}
+
+// InvalidateWidgetOrigins - Drop the cached allowed domains after a project's settings change
+func InvalidateWidgetOrigins(projectID string) {
+	widgetOriginsMu.Lock()
+	delete(widgetOriginsCache, projectID)
+	widgetOriginsMu.Unlock()
+}
+
+// NormalizeAllowedDomain - Reduce "https://*.Example.com:443/path" style input to "example.com"
+func NormalizeAllowedDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(domain, "://"); i >= 0 {
+		domain = domain[i+3:]
+	}
+	if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+		domain = domain[:i]
+	}
+	if i := strings.LastIndex(domain, ":"); i >= 0 {
+		domain = domain[:i]
+	}
+	domain = strings.TrimPrefix(domain, "*.")
+	return strings.Trim(domain, ".")
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubWidgetDomains - Serve allowed domains from a map for the duration of a test
+func stubWidgetDomains(t *testing.T, projects map[string][]string) *int {
+	t.Helper()
+	lookups := 0
+	previous := lookupWidgetDomains
+	lookupWidgetDomains = func(projectID string) ([]string, error) {
+		lookups++
+		domains, ok := projects[projectID]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return domains, nil
+	}
+	widgetOriginsMu.Lock()
+	widgetOriginsCache = make(map[string]cachedWidgetOrigins)
+	widgetOriginsMu.Unlock()
+	t.Cleanup(func() { lookupWidgetDomains = previous })
+	return &lookups
+}
+
+func TestNormalizeAllowedDomain(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"example.com", "example.com"},
+		{" Example.COM ", "example.com"},
+		{"https://*.Example.com:443/path", "example.com"},
+		{"http://shop.example.com?x=1", "shop.example.com"},
+		{"*.example.com.", "example.com"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeAllowedDomain(tt.in); got != tt.want {
+			t.Errorf("NormalizeAllowedDomain(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWidgetProjectFromPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"/api/projects/proj_1/chat", "proj_1", true},
+		{"/api/projects/proj_1/greeting", "proj_1", true},
+		{"/api/projects/proj_1/lead", "proj_1", true},
+		{"/api/projects/proj_1/session/abc/close", "proj_1", true},
+		{"/api/projects/proj_1/quota/", "proj_1", true},
+		{"/api/embed/proj_1/config", "proj_1", true},
+		{"/api/embed/proj_1/auth", "proj_1", true},
+		{"/api/projects/proj_1/usage", "", false},
+		{"/api/embed/health", "", false},
+		{"/api/projects//chat", "", false},
+		{"/projects/proj_1/chat", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := widgetProjectFromPath(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("widgetProjectFromPath(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestWidgetOriginAllowed(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	stubWidgetDomains(t, map[string][]string{
+		"open":       nil,
+		"restricted": {"https://example.com", "*.shop.io"},
+	})
+
+	tests := []struct {
+		name, projectID, origin string
+		want                    widgetOriginPolicy
+	}{
+		{"unknown project", "missing", "https://example.com", originDenied},
+		{"unrestricted project", "open", "https://anywhere.net", originAny},
+		{"exact domain", "restricted", "https://example.com", originAllowed},
+		{"subdomain", "restricted", "https://www.example.com:8443", originAllowed},
+		{"wildcard entry", "restricted", "https://eu.shop.io", originAllowed},
+		{"lookalike domain", "restricted", "https://evilexample.com", originDenied},
+		{"other domain", "restricted", "https://attacker.net", originDenied},
+		{"malformed origin", "restricted", "null", originDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := widgetOriginAllowed(tt.projectID, tt.origin); got != tt.want {
+				t.Errorf("widgetOriginAllowed(%q, %q) = %v, want %v", tt.projectID, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWidgetCORSMiddlewareHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENVIRONMENT", "production")
+	stubWidgetDomains(t, map[string][]string{
+		"open":       nil,
+		"restricted": {"example.com"},
+	})
+
+	r := gin.New()
+	r.Use(WidgetCORSMiddleware())
+	r.POST("/api/projects/:projectId/chat", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name, projectID, origin string
+		wantStatus              int
+		wantOrigin              string
+		wantCredentials         string
+	}{
+		{"unrestricted project sends wildcard", "open", "https://anywhere.net", http.StatusOK, "*", ""},
+		{"allowed origin is reflected", "restricted", "https://example.com", http.StatusOK, "https://example.com", "true"},
+		{"other origin is refused", "restricted", "https://attacker.net", http.StatusForbidden, "", ""},
+		{"unknown project is refused", "missing", "https://anywhere.net", http.StatusForbidden, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/projects/"+tt.projectID+"/chat", nil)
+			req.Header.Set("Origin", tt.origin)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+			if tt.wantOrigin != "" && !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-Request-Tokens, X-Request-Cost") {
+				t.Errorf("Expose-Headers = %q, want the request usage headers", w.Header().Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+}
+
+func TestWidgetAllowedDomainsDoesNotCacheMisses(t *testing.T) {
+	lookups := stubWidgetDomains(t, map[string][]string{"open": nil})
+
+	for i := 0; i < 3; i++ {
+		widgetAllowedDomains("open")
+		widgetAllowedDomains("missing")
+	}
+	if *lookups != 4 {
+		t.Errorf("lookups = %d, want 1 for the cached project and 3 for the missing one", *lookups)
+	}
+
+	widgetOriginsMu.Lock()
+	_, cached := widgetOriginsCache["missing"]
+	widgetOriginsMu.Unlock()
+	if cached {
+		t.Error("missing project was cached")
+	}
+}
+
+func TestWidgetCORSMiddlewareOpensEmbedHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENVIRONMENT", "production")
+	stubWidgetDomains(t, map[string][]string{})
+
+	r := gin.New()
+	r.Use(WidgetCORSMiddleware())
+	r.GET("/api/embed/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/embed/version", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{http.MethodGet, "/api/embed/health", http.StatusOK},
+		{http.MethodGet, "/api/embed/version", http.StatusOK},
+		{http.MethodOptions, "/api/embed/health", http.StatusNoContent},
+		{http.MethodOptions, "/api/embed/version/", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", "https://any-site.example")
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+				t.Errorf("Allow-Origin = %q, want *", got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, OPTIONS" {
+				t.Errorf("Allow-Methods = %q, want GET only", got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Errorf("credentials allowed on an open endpoint: %q", got)
+			}
+		})
+	}
+}
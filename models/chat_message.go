@@ -1,83 +1,93 @@
 package models
 
 import (
-    "time"
-    "go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 // ChatMessage represents a chat message between user and AI
 type ChatMessage struct {
-    ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-    ProjectID string             `bson:"project_id" json:"project_id"`
-    SessionID string             `bson:"session_id" json:"session_id"`
-    UserID    string             `bson:"user_id,omitempty" json:"user_id"`
-    UserName  string             `bson:"user_name,omitempty" json:"user_name"`
-    
-    // Message content
-    Message   string `bson:"message" json:"message"`
-    Response  string `bson:"response" json:"response"`
-    
-    // AI processing details
-    TokensUsed    int    `bson:"tokens_used" json:"tokens_used"`
-    Model         string `bson:"model,omitempty" json:"model"`
-    ProcessingTime int64 `bson:"processing_time,omitempty" json:"processing_time"` // milliseconds
-    
-    // User feedback
-    Rating    string `bson:"rating,omitempty" json:"rating"` // positive, negative, neutral
-    Feedback  string `bson:"feedback,omitempty" json:"feedback"`
-    
-    // Metadata
-    IPAddress string    `bson:"ip_address,omitempty" json:"ip_address"`
-    UserAgent string    `bson:"user_agent,omitempty" json:"user_agent"`
-    CreatedAt time.Time `bson:"created_at" json:"created_at"`
-    UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProjectID string             `bson:"project_id" json:"project_id"`
+	SessionID string             `bson:"session_id" json:"session_id"`
+	UserID    string             `bson:"user_id,omitempty" json:"user_id"`
+	UserName  string             `bson:"user_name,omitempty" json:"user_name"`
+	VisitorID string             `bson:"visitor_id,omitempty" json:"visitor_id"`
+
+	// Message content
+	Message  string `bson:"message" json:"message"`
+	Response string `bson:"response" json:"response"`
+	Greeting bool   `bson:"greeting,omitempty" json:"greeting,omitempty"` // Opener sent by the bot; Message is empty
+	Offline  bool   `bson:"offline,omitempty" json:"offline,omitempty"`   // Sent outside business hours; Response is the offline message
+
+	// Page the visitor was on, as reported by the widget
+	PageURL   string `bson:"page_url,omitempty" json:"page_url,omitempty"`
+	PageTitle string `bson:"page_title,omitempty" json:"page_title,omitempty"`
+
+	// AI processing details
+	TokensUsed     int    `bson:"tokens_used" json:"tokens_used"`
+	Model          string `bson:"model,omitempty" json:"model"`
+	Provider       string `bson:"provider,omitempty" json:"provider,omitempty"`           // AI provider that answered
+	FallbackUsed   bool   `bson:"fallback_used,omitempty" json:"fallback_used,omitempty"` // Primary provider failed
+	ProcessingTime int64  `bson:"processing_time,omitempty" json:"processing_time"`       // milliseconds
+
+	// User feedback
+	Rating       string `bson:"rating,omitempty" json:"rating"` // positive, negative, neutral
+	Feedback     string `bson:"feedback,omitempty" json:"feedback"`
+	KnowledgeGap bool   `bson:"knowledge_gap,omitempty" json:"knowledge_gap,omitempty"` // Recorded as a knowledge gap
+
+	// Metadata
+	IPAddress string    `bson:"ip_address,omitempty" json:"ip_address"`
+	UserAgent string    `bson:"user_agent,omitempty" json:"user_agent"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
 // ChatSession represents a chat session
 type ChatSession struct {
-    ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-    SessionID string             `bson:"session_id" json:"session_id"`
-    ProjectID string             `bson:"project_id" json:"project_id"`
-    UserID    string             `bson:"user_id,omitempty" json:"user_id"`
-    
-    // Session details
-    StartTime     time.Time `bson:"start_time" json:"start_time"`
-    EndTime       time.Time `bson:"end_time,omitempty" json:"end_time"`
-    MessageCount  int       `bson:"message_count" json:"message_count"`
-    TotalTokens   int       `bson:"total_tokens" json:"total_tokens"`
-    
-    // Session metadata
-    IPAddress string    `bson:"ip_address,omitempty" json:"ip_address"`
-    UserAgent string    `bson:"user_agent,omitempty" json:"user_agent"`
-    CreatedAt time.Time `bson:"created_at" json:"created_at"`
-    UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SessionID string             `bson:"session_id" json:"session_id"`
+	ProjectID string             `bson:"project_id" json:"project_id"`
+	UserID    string             `bson:"user_id,omitempty" json:"user_id"`
+
+	// Session details
+	StartTime    time.Time `bson:"start_time" json:"start_time"`
+	EndTime      time.Time `bson:"end_time,omitempty" json:"end_time"`
+	MessageCount int       `bson:"message_count" json:"message_count"`
+	TotalTokens  int       `bson:"total_tokens" json:"total_tokens"`
+
+	// Session metadata
+	IPAddress string    `bson:"ip_address,omitempty" json:"ip_address"`
+	UserAgent string    `bson:"user_agent,omitempty" json:"user_agent"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
 // DefaultChatMessage creates a new chat message with default values
 func DefaultChatMessage(projectID, sessionID, userID, message string) *ChatMessage {
-    return &ChatMessage{
-        ID:        primitive.NewObjectID(),
-        ProjectID: projectID,
-        SessionID: sessionID,
-        UserID:    userID,
-        Message:   message,
-        CreatedAt: time.Now(),
-        UpdatedAt: time.Now(),
-    }
+	return &ChatMessage{
+		ID:        primitive.NewObjectID(),
+		ProjectID: projectID,
+		SessionID: sessionID,
+		UserID:    userID,
+		Message:   message,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
 }
 
 // SetResponse sets the AI response and related metadata
 func (cm *ChatMessage) SetResponse(response string, tokensUsed int, model string, processingTime int64) {
-    cm.Response = response
-    cm.TokensUsed = tokensUsed
-    cm.Model = model
-    cm.ProcessingTime = processingTime
-    cm.UpdatedAt = time.Now()
+	cm.Response = response
+	cm.TokensUsed = tokensUsed
+	cm.Model = model
+	cm.ProcessingTime = processingTime
+	cm.UpdatedAt = time.Now()
 }
 
 // SetRating sets user feedback rating
 func (cm *ChatMessage) SetRating(rating, feedback string) {
-    cm.Rating = rating
-    cm.Feedback = feedback
-    cm.UpdatedAt = time.Now()
+	cm.Rating = rating
+	cm.Feedback = feedback
+	cm.UpdatedAt = time.Now()
 }
@@ -13,11 +13,11 @@ type ChatUser struct {
 	ProjectID string             `bson:"project_id"          json:"project_id"`
 
 	// Basic profile
-	Name   string `bson:"name,omitempty"    json:"name"`
-	Email  string `bson:"email,omitempty"   json:"email"`
-	
-	Password string `bson:"password,omitempty" json:"-"` 
-	Avatar string `bson:"avatar,omitempty"  json:"avatar"`
+	Name  string `bson:"name,omitempty"    json:"name"`
+	Email string `bson:"email,omitempty"   json:"email"`
+
+	Password string `bson:"password,omitempty" json:"-"`
+	Avatar   string `bson:"avatar,omitempty"  json:"avatar"`
 
 	// Analytics
 	TotalSessions  int       `bson:"total_sessions"   json:"total_sessions"`
@@ -26,58 +26,62 @@ type ChatUser struct {
 	LastSeenAt     time.Time `bson:"last_seen_at"     json:"last_seen_at"`
 	CreatedAt      time.Time `bson:"created_at"       json:"created_at"`
 	UpdatedAt      time.Time `bson:"updated_at"       json:"updated_at"`
-	IsActive  bool   `bson:"is_active" json:"is_active"`   
+	IsActive       bool      `bson:"is_active" json:"is_active"`
 	IsBlocked      bool      `bson:"is_blocked"       json:"is_blocked"`
+	Source         string    `bson:"source,omitempty" json:"source,omitempty"` // ChatUserSourceLead for captured leads; empty for registered accounts
 	BlockingReason string    `bson:"blocking_reason,omitempty" json:"blocking_reason,omitempty"`
 }
 
+// ChatUserSourceLead - A visitor who left their name and email in the chat, without an account
+const ChatUserSourceLead = "lead"
+
 // Helper Methods
 
 // IsValidUser checks if the chat user has valid required fields
 func (cu *ChatUser) IsValidUser() bool {
-    return cu.ProjectID != "" && cu.Email != ""
+	return cu.ProjectID != "" && cu.Email != ""
 }
 
 // CanChat checks if the user can participate in chat
 func (cu *ChatUser) CanChat() bool {
-    return cu.IsActive && !cu.IsBlocked
+	return cu.IsActive && !cu.IsBlocked
 }
 
 // IncrementSession increments the user's session count
 func (cu *ChatUser) IncrementSession() {
-    cu.TotalSessions++
-    cu.LastSeenAt = time.Now()
-    cu.UpdatedAt = time.Now()
+	cu.TotalSessions++
+	cu.LastSeenAt = time.Now()
+	cu.UpdatedAt = time.Now()
 }
 
 // IncrementMessage increments the user's message count
 func (cu *ChatUser) IncrementMessage(tokensUsed int64) {
-    cu.TotalMessages++
-    cu.TotalTokens += tokensUsed
-    cu.LastSeenAt = time.Now()
-    cu.UpdatedAt = time.Now()
+	cu.TotalMessages++
+	cu.TotalTokens += tokensUsed
+	cu.LastSeenAt = time.Now()
+	cu.UpdatedAt = time.Now()
 }
 
 // Block blocks the user from chatting
 func (cu *ChatUser) Block() {
-    cu.IsBlocked = true
-    cu.UpdatedAt = time.Now()
+	cu.IsBlocked = true
+	cu.UpdatedAt = time.Now()
 }
 
 // Unblock unblocks the user
 func (cu *ChatUser) Unblock() {
-    cu.IsBlocked = false
-    cu.UpdatedAt = time.Now()
+	cu.IsBlocked = false
+	cu.UpdatedAt = time.Now()
 }
 
 // Deactivate deactivates the user account
 func (cu *ChatUser) Deactivate() {
-    cu.IsActive = false
-    cu.UpdatedAt = time.Now()
+	cu.IsActive = false
+	cu.UpdatedAt = time.Now()
 }
 
 // Activate activates the user account
 func (cu *ChatUser) Activate() {
-    cu.IsActive = true
-    cu.UpdatedAt = time.Now()
-}
\ No newline at end of file
+	cu.IsActive = true
+	cu.UpdatedAt = time.Now()
+}
@@ -22,10 +22,11 @@ type Client struct {
 	ProjectIDs     []string `bson:"project_ids" json:"project_ids"`         // Associated project IDs
 
 	// Client Status & Preferences
-	Status            string            `bson:"status" json:"status"`                         // active, suspended, inactive
-	Timezone          string            `bson:"timezone,omitempty" json:"timezone"`           // Client timezone
-	Language          string            `bson:"language,omitempty" json:"language"`           // Preferred language
-	NotificationPrefs NotificationPrefs `bson:"notification_prefs" json:"notification_prefs"` // Notification preferences
+	Status            string            `bson:"status" json:"status"`                                         // active, suspended, inactive
+	Timezone          string            `bson:"timezone,omitempty" json:"timezone"`                           // Client timezone
+	Language          string            `bson:"language,omitempty" json:"language"`                           // Preferred language
+	NotificationPrefs NotificationPrefs `bson:"notification_prefs" json:"notification_prefs"`                 // Notification preferences
+	SMSOptedOutAt     time.Time         `bson:"sms_opted_out_at,omitempty" json:"sms_opted_out_at,omitempty"` // Replied STOP; no more texts until re-enabled
 
 	// Billing & Usage
 	TotalTokensUsed int64     `bson:"total_tokens_used" json:"total_tokens_used"` // Cumulative token usage
@@ -50,6 +51,12 @@ type NotificationPrefs struct {
 	ExpiryReminders    bool `bson:"expiry_reminders" json:"expiry_reminders"`
 	UsageAlerts        bool `bson:"usage_alerts" json:"usage_alerts"`
 	MaintenanceUpdates bool `bson:"maintenance_updates" json:"maintenance_updates"`
+	DailyDigest        bool `bson:"daily_digest" json:"daily_digest"` // Batch low-priority alerts into one daily email
+}
+
+// CanReceiveSMS reports whether the client wants, and can be sent, SMS alerts
+func (c *Client) CanReceiveSMS() bool {
+	return c.NotificationPrefs.SMSNotifications && c.SMSOptedOutAt.IsZero() && c.Phone != ""
 }
 
 // ClientSummary represents a simplified client view for listings
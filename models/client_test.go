@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientCanReceiveSMS(t *testing.T) {
+	enabled := NotificationPrefs{SMSNotifications: true}
+
+	tests := []struct {
+		name   string
+		client Client
+		want   bool
+	}{
+		{"enabled", Client{Phone: "+14155550100", NotificationPrefs: enabled}, true},
+		{"alerts off", Client{Phone: "+14155550100"}, false},
+		{"no phone", Client{NotificationPrefs: enabled}, false},
+		{"opted out", Client{Phone: "+14155550100", NotificationPrefs: enabled, SMSOptedOutAt: time.Now()}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.client.CanReceiveSMS(); got != tt.want {
+			t.Errorf("%s: CanReceiveSMS() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
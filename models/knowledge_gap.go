@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Knowledge gap reasons
+const (
+	KnowledgeGapNoAnswer       = "no_answer"       // the bot said it couldn't answer
+	KnowledgeGapNegativeRating = "negative_rating" // the visitor rated the answer down
+)
+
+// Knowledge gap statuses
+const (
+	KnowledgeGapOpen     = "open"
+	KnowledgeGapResolved = "resolved"
+)
+
+// KnowledgeGap is a visitor question the project's documents didn't answer well, so admins
+// know what to add. Its ID is the chat message ID: one gap per message, whatever the signals.
+type KnowledgeGap struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	ProjectID string             `bson:"project_id" json:"project_id"`
+	SessionID string             `bson:"session_id" json:"session_id"`
+	Question  string             `bson:"question" json:"question"`
+	Response  string             `bson:"response" json:"response"`
+	Reasons   []string           `bson:"reasons" json:"reasons"`
+	Feedback  string             `bson:"feedback,omitempty" json:"feedback,omitempty"` // from a negative rating
+
+	Status     string     `bson:"status" json:"status"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at" json:"updated_at"`
+	ResolvedAt *time.Time `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaintenanceJob tracks a long-running admin maintenance task such as re-embedding all projects.
+type MaintenanceJob struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type   string             `bson:"type" json:"type"`
+	Status string             `bson:"status" json:"status"`
+
+	// Target selection
+	ProjectStatuses []string `bson:"project_statuses" json:"project_statuses"`
+	ProjectIDs      []string `bson:"project_ids" json:"project_ids"`
+
+	// Progress
+	TotalProjects      int      `bson:"total_projects" json:"total_projects"`
+	ProcessedProjects  int      `bson:"processed_projects" json:"processed_projects"`
+	FailedProjects     int      `bson:"failed_projects" json:"failed_projects"`
+	DocumentsReindexed int      `bson:"documents_reindexed" json:"documents_reindexed"`
+	Errors             []string `bson:"errors,omitempty" json:"errors,omitempty"`
+
+	CreatedBy   string     `bson:"created_by" json:"created_by"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	StartedAt   *time.Time `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	CompletedAt *time.Time `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// Maintenance job types
+const (
+	MaintenanceJobReindex = "reindex"
+)
+
+// Maintenance job statuses
+const (
+	MaintenanceJobQueued    = "queued"
+	MaintenanceJobRunning   = "running"
+	MaintenanceJobCompleted = "completed"
+	MaintenanceJobFailed    = "failed"
+)
+
+// MaxMaintenanceJobErrors caps the per-job error log stored on the document
+const MaxMaintenanceJobErrors = 50
+
+// IsFinished reports whether the job has stopped running
+func (j *MaintenanceJob) IsFinished() bool {
+	return j.Status == MaintenanceJobCompleted || j.Status == MaintenanceJobFailed
+}
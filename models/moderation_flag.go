@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Moderation flag directions
+const (
+	ModerationInbound  = "inbound"  // visitor message
+	ModerationOutbound = "outbound" // bot response
+)
+
+// Moderation actions taken when a message was flagged
+const (
+	ModerationActionBlocked  = "blocked"  // not answered / not shown
+	ModerationActionRedacted = "redacted" // shown with the flagged part removed
+	ModerationActionLogged   = "logged"   // delivered unchanged, kept for review
+)
+
+// Moderation flag review statuses
+const (
+	ModerationPending     = "pending"
+	ModerationConfirmed   = "confirmed"   // reviewer agreed with the flag
+	ModerationWhitelisted = "whitelisted" // false positive; the same content is not flagged again
+)
+
+// ModerationFlag is a chat message that moderation flagged, queued for an admin to review
+type ModerationFlag struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	ProjectID   string             `bson:"project_id" json:"project_id"`
+	SessionID   string             `bson:"session_id" json:"session_id"`
+	MessageID   primitive.ObjectID `bson:"message_id,omitempty" json:"message_id,omitempty"`
+	Direction   string             `bson:"direction" json:"direction"`
+	Content     string             `bson:"content" json:"content"`
+	ContentHash string             `bson:"content_hash" json:"-"` // matches whitelisted content
+	Categories  []string           `bson:"categories,omitempty" json:"categories,omitempty"`
+	Reason      string             `bson:"reason" json:"reason"`
+	Action      string             `bson:"action" json:"action"`
+
+	Status     string     `bson:"status" json:"status"`
+	ReviewedBy string     `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+}
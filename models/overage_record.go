@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OverageRecord is the billable part of one chat message that went past the project's
+// token limit under the allow_overage policy. Its ID is the chat message ID, so a message
+// can't be billed twice.
+type OverageRecord struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	ProjectID string             `bson:"project_id" json:"project_id"`
+	SessionID string             `bson:"session_id" json:"session_id"`
+
+	Tokens           int64   `bson:"tokens" json:"tokens"` // tokens beyond the limit
+	Cost             float64 `bson:"cost" json:"cost"`     // INR, same basis as estimated_cost
+	TotalTokensAfter int64   `bson:"total_tokens_after" json:"total_tokens_after"`
+	TokenLimit       int64   `bson:"token_limit" json:"token_limit"`
+
+	Billed    bool      `bson:"billed" json:"billed"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
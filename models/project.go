@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -16,30 +17,43 @@ type Project struct {
 	Category    string             `bson:"category" json:"category"`
 
 	// Client Association
-	ClientID string `bson:"client_id,omitempty" json:"client_id"`
-	
+	ClientID        string             `bson:"client_id,omitempty" json:"client_id"`
+	OwnerID         string             `bson:"owner_id,omitempty" json:"owner_id,omitempty"`                     // User (hex id) who owns the project
+	CreatedByUserID primitive.ObjectID `bson:"created_by_user_id,omitempty" json:"created_by_user_id,omitempty"` // Admin/user who created it
 
 	// Subscription Management
-	StartDate         time.Time `bson:"start_date" json:"start_date"`
-	ExpiryDate        time.Time `bson:"expiry_date" json:"expiry_date"`
-	Status            string    `bson:"status" json:"status"`
-	TotalTokensUsed   int64     `bson:"total_tokens_used" json:"total_tokens_used"`
-	MonthlyTokenLimit int64     `bson:"monthly_token_limit" json:"monthly_token_limit"`
+	StartDate              time.Time  `bson:"start_date" json:"start_date"`
+	ExpiryDate             time.Time  `bson:"expiry_date" json:"expiry_date"`
+	Status                 string     `bson:"status" json:"status"`
+	TotalTokensUsed        int64      `bson:"total_tokens_used" json:"total_tokens_used"`
+	MonthlyTokenLimit      int64      `bson:"monthly_token_limit" json:"monthly_token_limit"`
+	Plan                   string     `bson:"plan,omitempty" json:"plan,omitempty"`                                         // PlanTrial or PlanPaid (empty = paid)
+	TrialEndsAt            *time.Time `bson:"trial_ends_at,omitempty" json:"trial_ends_at,omitempty"`                       // Set for trial projects only
+	OveragePolicy          string     `bson:"overage_policy,omitempty" json:"overage_policy,omitempty"`                     // What happens at the token limit (empty = block)
+	OverageTokens          int64      `bson:"overage_tokens,omitempty" json:"overage_tokens,omitempty"`                     // Billable tokens used beyond the limit
+	UsageWarningThresholds []int      `bson:"usage_warning_thresholds,omitempty" json:"usage_warning_thresholds,omitempty"` // Percentages of the limit that notify (empty = plan default)
+	ChatPaused             bool       `bson:"chat_paused,omitempty" json:"chat_paused"`                                     // Bot switched off by the client; status and billing are unaffected
+	PausedAt               *time.Time `bson:"paused_at,omitempty" json:"paused_at,omitempty"`
+	PauseMessage           string     `bson:"pause_message,omitempty" json:"pause_message,omitempty"` // Shown to visitors while paused
 
 	// Widget & Embedding Configuration
 	EmbedCode      string              `bson:"embed_code" json:"embed_code"`
 	WidgetSettings ProjectWidgetConfig `bson:"widget_settings" json:"widget_settings"` // Renamed to avoid conflict
 
 	// AI Provider Configuration
-	AIProvider   string `bson:"ai_provider" json:"ai_provider"`
-	OpenAIModel  string `bson:"openai_model" json:"openai_model"`
-	OpenAIAPIKey string `bson:"openai_api_key,omitempty" json:"openai_api_key,omitempty"`
+	AIProvider        string `bson:"ai_provider" json:"ai_provider"`
+	FallbackProvider  string `bson:"fallback_provider,omitempty" json:"fallback_provider,omitempty"` // Serves chat when AIProvider fails
+	OpenAIModel       string `bson:"openai_model" json:"openai_model"`
+	EmbeddingModel    string `bson:"embedding_model,omitempty" json:"embedding_model,omitempty"` // Model for document and query vectors
+	OpenAIAPIKey      string `bson:"openai_api_key,omitempty" json:"openai_api_key,omitempty"`
+	SystemPrompt      string `bson:"system_prompt,omitempty" json:"system_prompt,omitempty"`             // Custom assistant instructions
+	CantAnswerMessage string `bson:"cant_answer_message,omitempty" json:"cant_answer_message,omitempty"` // Exact reply when the documents don't answer a question
+	RedactPII         bool   `bson:"redact_pii,omitempty" json:"redact_pii"`                             // Store messages with emails, phone and card numbers masked
 
 	// Document Management
 	PDFFiles     []PDFFile `bson:"pdf_files" json:"pdf_files"`
 	PDFContent   string    `bson:"pdf_content" json:"pdf_content"`
 	DocumentPath string    `bson:"document_path" json:"document_path"`
-	
 
 	// Cost Tracking
 	EstimatedCostToday float64 `bson:"estimated_cost_today" json:"estimated_cost_today"`
@@ -47,46 +61,98 @@ type Project struct {
 	TotalCost          float64 `bson:"total_cost" json:"total_cost"`
 
 	// Notification Management
-	ReminderSent     bool      `bson:"reminder_sent" json:"reminder_sent"`
-	LastReminderDate time.Time `bson:"last_reminder_date" json:"last_reminder_date"`
+	NotificationEmail      string    `bson:"notification_email,omitempty" json:"notification_email,omitempty"`             // Defaults to ClientID when it is an email
+	NotificationWebhookURL string    `bson:"notification_webhook_url,omitempty" json:"notification_webhook_url,omitempty"` // POSTed JSON notifications
+	NotificationSlackURL   string    `bson:"notification_slack_url,omitempty" json:"notification_slack_url,omitempty"`     // Slack incoming webhook
+	ReminderSent           bool      `bson:"reminder_sent" json:"reminder_sent"`
+	LastReminderDate       time.Time `bson:"last_reminder_date" json:"last_reminder_date"`
+
+	// Actions the bot may trigger through OpenAI tool calling
+	Tools []ProjectTool `bson:"tools,omitempty" json:"tools,omitempty"`
 
 	// Metadata
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
-	IsActive              bool      `bson:"is_active" json:"is_active"` // Renamed field to avoid conflict
+	IsActive  bool      `bson:"is_active" json:"is_active"` // Renamed field to avoid conflict
 }
 
 // ProjectWidgetConfig represents the embeddable widget configuration (renamed to avoid conflict)
 // Add this to your models/project.go
 type ProjectWidgetConfig struct {
-    Theme            string `json:"theme" bson:"theme"`
-    PrimaryColor     string `json:"primary_color" bson:"primary_color"`
-    WelcomeMessage   string `json:"welcome_message" bson:"welcome_message"`
-    Position         string `json:"position" bson:"position"`
-    ShowBranding     bool   `json:"show_branding" bson:"show_branding"`
-    EnableFileUpload bool   `json:"enable_file_upload" bson:"enable_file_upload"`
-    EnableRating     bool   `json:"enable_rating" bson:"enable_rating"`
-    Placeholder      string `json:"placeholder" bson:"placeholder"`
-    Height           string `json:"height" bson:"height"`
-    Width            string `json:"width" bson:"width"`
-    EnableSound      bool   `json:"enable_sound" bson:"enable_sound"`
-    AutoOpen         bool   `json:"auto_open" bson:"auto_open"`
-    TriggerDelay     int    `json:"trigger_delay" bson:"trigger_delay"`
+	Theme             string          `json:"theme" bson:"theme"`
+	PrimaryColor      string          `json:"primary_color" bson:"primary_color"`
+	WelcomeMessage    string          `json:"welcome_message" bson:"welcome_message"`
+	Position          string          `json:"position" bson:"position"`
+	ShowBranding      bool            `json:"show_branding" bson:"show_branding"`
+	EnableFileUpload  bool            `json:"enable_file_upload" bson:"enable_file_upload"`
+	EnableRating      bool            `json:"enable_rating" bson:"enable_rating"`
+	Placeholder       string          `json:"placeholder" bson:"placeholder"`
+	Height            string          `json:"height" bson:"height"`
+	Width             string          `json:"width" bson:"width"`
+	EnableSound       bool            `json:"enable_sound" bson:"enable_sound"`
+	AutoOpen          bool            `json:"auto_open" bson:"auto_open"`
+	TriggerDelay      int             `json:"trigger_delay" bson:"trigger_delay"`
+	RequireAuth       bool            `json:"require_auth" bson:"require_auth"`       // Visitors must sign in before chatting
+	RequireCaptcha    bool            `json:"require_captcha" bson:"require_captcha"` // Challenge anonymous visitors on new sessions
+	QuickActions      []QuickAction   `json:"quick_actions,omitempty" bson:"quick_actions,omitempty"`
+	AllowedDomains    []string        `json:"allowed_domains,omitempty" bson:"allowed_domains,omitempty"` // Sites allowed to call the widget API (empty = any)
+	ProactiveGreeting bool            `json:"proactive_greeting" bson:"proactive_greeting,omitempty"`     // Open with a generated, page-aware greeting instead of WelcomeMessage
+	CollectUserInfo   bool            `json:"collect_user_info" bson:"collect_user_info,omitempty"`       // Ask visitors for their name and email mid-conversation
+	EmailLeads        bool            `json:"email_leads" bson:"email_leads,omitempty"`                   // Send each captured lead to the project's notification channels
+	Schedule          *WidgetSchedule `json:"schedule,omitempty" bson:"schedule,omitempty"`               // Business hours; outside them the bot sends an offline message
 }
 
-
 // PDFFile represents an uploaded PDF file
 type PDFFile struct {
-    ID           string    `bson:"id" json:"id"`
-    FileName     string    `bson:"file_name" json:"file_name"`
-    FilePath     string    `bson:"file_path" json:"file_path"`
-    FileSize     int64     `bson:"file_size" json:"file_size"`
-    ContentType  string    `bson:"content_type" json:"content_type"`
-    Content      string    `bson:"content" json:"content"`
-    Embeddings   []float64 `bson:"embeddings" json:"embeddings"`
-    UploadedAt   time.Time `bson:"uploaded_at" json:"uploaded_at"`
-    ProcessedAt  time.Time `bson:"processed_at" json:"processed_at"`
-    Status       string    `bson:"status" json:"status"`
+	ID             string    `bson:"id" json:"id"`
+	FileName       string    `bson:"file_name" json:"file_name"`
+	FilePath       string    `bson:"file_path" json:"file_path"`
+	FileSize       int64     `bson:"file_size" json:"file_size"`
+	ContentType    string    `bson:"content_type" json:"content_type"`
+	Content        string    `bson:"content" json:"content"`
+	Embeddings     []float64 `bson:"embeddings" json:"embeddings"`
+	EmbeddingModel string    `bson:"embedding_model,omitempty" json:"embedding_model,omitempty"`
+	UploadedAt     time.Time `bson:"uploaded_at" json:"uploaded_at"`
+	ProcessedAt    time.Time `bson:"processed_at" json:"processed_at"`
+	Status         string    `bson:"status" json:"status"`
+	Weight         float64   `bson:"weight,omitempty" json:"weight,omitempty"`                 // Retrieval priority (default 1.0)
+	ChunksIndexed  int       `bson:"chunks_indexed,omitempty" json:"chunks_indexed,omitempty"` // Chunks stored in the vector store
+}
+
+// ProjectTool is an action the bot can call (OpenAI function calling). When the model calls it,
+// the backend POSTs the arguments to WebhookURL and returns the response body to the model.
+type ProjectTool struct {
+	Name        string                 `bson:"name" json:"name"`
+	Description string                 `bson:"description" json:"description"`
+	Parameters  map[string]interface{} `bson:"parameters,omitempty" json:"parameters,omitempty"` // JSON Schema of the arguments
+	WebhookURL  string                 `bson:"webhook_url" json:"webhook_url"`
+	Enabled     bool                   `bson:"enabled" json:"enabled"`
+}
+
+// EnabledTools returns the tools the bot may currently call
+func (p *Project) EnabledTools() []ProjectTool {
+	var tools []ProjectTool
+	for _, tool := range p.Tools {
+		if tool.Enabled {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// Document weight bounds accepted by the admin API
+const (
+	DefaultPDFWeight = 1.0
+	MinPDFWeight     = 0.1
+	MaxPDFWeight     = 10.0
+)
+
+// EffectiveWeight returns the retrieval weight, treating unset as DefaultPDFWeight
+func (f PDFFile) EffectiveWeight() float64 {
+	if f.Weight <= 0 {
+		return DefaultPDFWeight
+	}
+	return f.Weight
 }
 
 // Project status constants
@@ -97,12 +163,68 @@ const (
 	ProjectStatusDeleted   = "deleted"
 )
 
+// Plan constants
+const (
+	PlanTrial = "trial"
+	PlanPaid  = "paid"
+)
+
+// Overage policy constants: behaviour once a project reaches its token limit
+const (
+	OveragePolicyBlock   = "block"         // refuse further chat requests
+	OveragePolicySuspend = "suspend"       // suspend the project and notify the client
+	OveragePolicyAllow   = "allow_overage" // keep serving and track billable overage
+)
+
 // AI Provider constants
 const (
 	AIProviderOpenAI = "openai"
 	AIProviderGemini = "gemini"
 )
 
+// IsValidAIProvider reports whether provider is a supported AI provider
+func IsValidAIProvider(provider string) bool {
+	return provider == AIProviderOpenAI || provider == AIProviderGemini
+}
+
+// GetAIProvider returns the primary provider, treating unset as OpenAI
+func (p *Project) GetAIProvider() string {
+	if IsValidAIProvider(p.AIProvider) {
+		return p.AIProvider
+	}
+	return AIProviderOpenAI
+}
+
+// Embedding model constants
+const (
+	EmbeddingModelAda002  = "text-embedding-ada-002"
+	EmbeddingModel3Small  = "text-embedding-3-small"
+	EmbeddingModel3Large  = "text-embedding-3-large"
+	DefaultEmbeddingModel = EmbeddingModelAda002
+)
+
+// EmbeddingDimensions maps each supported embedding model to its vector size
+var EmbeddingDimensions = map[string]int{
+	EmbeddingModelAda002: 1536,
+	EmbeddingModel3Small: 1536,
+	EmbeddingModel3Large: 3072,
+}
+
+// IsValidEmbeddingModel reports whether model is a supported embedding model
+func IsValidEmbeddingModel(model string) bool {
+	_, ok := EmbeddingDimensions[model]
+	return ok
+}
+
+// GetEmbeddingModel returns the project's embedding model, treating unset as the default
+// (every vector stored before the setting existed was made with it)
+func (p *Project) GetEmbeddingModel() string {
+	if IsValidEmbeddingModel(p.EmbeddingModel) {
+		return p.EmbeddingModel
+	}
+	return DefaultEmbeddingModel
+}
+
 // PDF processing status constants
 const (
 	PDFStatusUploaded   = "uploaded"
@@ -123,11 +245,72 @@ func (p *Project) IsProjectActive() bool {
 	return p.Status == ProjectStatusActive && p.IsActive && time.Now().Before(p.ExpiryDate)
 }
 
+// DefaultPauseMessage is shown to visitors of a paused project without a message of its own
+const DefaultPauseMessage = "Our chat assistant is temporarily unavailable. Please check back soon."
+
+// ChatUnavailableMessage returns what visitors see while the project's chat is paused
+func (p *Project) ChatUnavailableMessage() string {
+	if p.PauseMessage != "" {
+		return p.PauseMessage
+	}
+	return DefaultPauseMessage
+}
+
 // IsExpired checks if the project subscription has expired
 func (p *Project) IsExpired() bool {
 	return time.Now().After(p.ExpiryDate) || p.Status == ProjectStatusExpired
 }
 
+// IsOwnedBy checks if the user owns the project. Projects created before owner_id existed
+// fall back to the client email association.
+func (p *Project) IsOwnedBy(userID, email string) bool {
+	if p.OwnerID != "" {
+		return userID != "" && p.OwnerID == userID
+	}
+	return email != "" && p.ClientID != "" && strings.EqualFold(p.ClientID, email)
+}
+
+// GetNotificationEmail returns the address notifications are emailed to
+func (p *Project) GetNotificationEmail() string {
+	if p.NotificationEmail != "" {
+		return p.NotificationEmail
+	}
+	if strings.Contains(p.ClientID, "@") {
+		return p.ClientID
+	}
+	return ""
+}
+
+// IsTrial checks if the project is on a free trial
+func (p *Project) IsTrial() bool {
+	return p.Plan == PlanTrial
+}
+
+// TrialExpired checks if a trial project is past its trial end date
+func (p *Project) TrialExpired() bool {
+	return p.IsTrial() && p.TrialEndsAt != nil && time.Now().After(*p.TrialEndsAt)
+}
+
+// GetOveragePolicy returns the project's overage policy, defaulting to block
+func (p *Project) GetOveragePolicy() string {
+	switch p.OveragePolicy {
+	case OveragePolicySuspend, OveragePolicyAllow:
+		return p.OveragePolicy
+	default:
+		return OveragePolicyBlock
+	}
+}
+
+// IsValidOveragePolicy checks an admin-supplied overage policy
+func IsValidOveragePolicy(policy string) bool {
+	return policy == OveragePolicyBlock || policy == OveragePolicySuspend || policy == OveragePolicyAllow
+}
+
+// IsOverLimit checks if the project has used its whole token allowance
+func (p *Project) IsOverLimit() bool {
+	return p.TotalTokensUsed >= p.MonthlyTokenLimit
+}
+
 // GetUsagePercentage calculates the current token usage percentage
 func (p *Project) GetUsagePercentage() float64 {
 	if p.MonthlyTokenLimit == 0 {
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProjectTrialExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		project     Project
+		wantTrial   bool
+		wantExpired bool
+	}{
+		{"paid", Project{Plan: PlanPaid}, false, false},
+		{"legacy without a plan", Project{}, false, false},
+		{"paid with a stale trial end", Project{Plan: PlanPaid, TrialEndsAt: &past}, false, false},
+		{"running trial", Project{Plan: PlanTrial, TrialEndsAt: &future}, true, false},
+		{"ended trial", Project{Plan: PlanTrial, TrialEndsAt: &past}, true, true},
+		{"trial without an end date", Project{Plan: PlanTrial}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.project.IsTrial(); got != tt.wantTrial {
+				t.Errorf("IsTrial() = %v, want %v", got, tt.wantTrial)
+			}
+			if got := tt.project.TrialExpired(); got != tt.wantExpired {
+				t.Errorf("TrialExpired() = %v, want %v", got, tt.wantExpired)
+			}
+		})
+	}
+}
+
+func TestProjectOveragePolicy(t *testing.T) {
+	tests := []struct {
+		stored, want string
+		valid        bool
+	}{
+		{"", OveragePolicyBlock, false},
+		{"block", OveragePolicyBlock, true},
+		{"suspend", OveragePolicySuspend, true},
+		{"allow_overage", OveragePolicyAllow, true},
+		{"allow", OveragePolicyBlock, false},
+		{"SUSPEND", OveragePolicyBlock, false},
+	}
+	for _, tt := range tests {
+		p := Project{OveragePolicy: tt.stored}
+		if got := p.GetOveragePolicy(); got != tt.want {
+			t.Errorf("GetOveragePolicy() with %q = %q, want %q", tt.stored, got, tt.want)
+		}
+		if got := IsValidOveragePolicy(tt.stored); got != tt.valid {
+			t.Errorf("IsValidOveragePolicy(%q) = %v, want %v", tt.stored, got, tt.valid)
+		}
+	}
+}
+
+func TestProjectIsOverLimit(t *testing.T) {
+	tests := []struct {
+		used, limit int64
+		want        bool
+	}{
+		{1000, 10000, false},
+		{9999, 10000, false},
+		{10000, 10000, true},
+		{10500, 10000, true},
+		{0, 0, true}, // an intentionally-zero limit allows nothing
+	}
+	for _, tt := range tests {
+		p := Project{MonthlyTokenLimit: tt.limit, TotalTokensUsed: tt.used}
+		if got := p.IsOverLimit(); got != tt.want {
+			t.Errorf("IsOverLimit() with %d/%d = %v, want %v", tt.used, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestProjectIsOwnedBy(t *testing.T) {
+	tests := []struct {
+		name          string
+		project       Project
+		userID, email string
+		want          bool
+	}{
+		{"owner", Project{OwnerID: "user_1"}, "user_1", "a@example.com", true},
+		{"other user", Project{OwnerID: "user_1"}, "user_2", "a@example.com", false},
+		// Once an owner is set, the client email no longer grants access
+		{"client email of an owned project", Project{OwnerID: "user_1", ClientID: "a@example.com"}, "user_2", "a@example.com", false},
+		{"legacy project by client email", Project{ClientID: "A@Example.com"}, "user_2", "a@example.com", true},
+		{"legacy project, other email", Project{ClientID: "a@example.com"}, "user_2", "b@example.com", false},
+		{"no owner and no client", Project{}, "user_1", "", false},
+		{"anonymous caller", Project{OwnerID: "user_1"}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.project.IsOwnedBy(tt.userID, tt.email); got != tt.want {
+				t.Errorf("IsOwnedBy(%q, %q) = %v, want %v", tt.userID, tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectGetNotificationEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		project Project
+		want    string
+	}{
+		{"explicit address", Project{NotificationEmail: "alerts@example.com", ClientID: "client@example.com"}, "alerts@example.com"},
+		{"client email", Project{ClientID: "client@example.com"}, "client@example.com"},
+		{"client id that is not an email", Project{ClientID: "user_1"}, ""},
+		{"nothing configured", Project{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.project.GetNotificationEmail(); got != tt.want {
+			t.Errorf("%s: GetNotificationEmail() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjectGetEmbeddingModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"", DefaultEmbeddingModel},
+		{"text-embedding-4", DefaultEmbeddingModel},
+		{EmbeddingModel3Small, EmbeddingModel3Small},
+		{EmbeddingModel3Large, EmbeddingModel3Large},
+	}
+	for _, tt := range tests {
+		project := Project{EmbeddingModel: tt.model}
+		if got := project.GetEmbeddingModel(); got != tt.want {
+			t.Errorf("GetEmbeddingModel() with %q = %q, want %q", tt.model, got, tt.want)
+		}
+		if IsValidEmbeddingModel(tt.model) != (tt.model == tt.want) {
+			t.Errorf("IsValidEmbeddingModel(%q) disagrees with GetEmbeddingModel", tt.model)
+		}
+	}
+}
+
+func TestProjectChatUnavailableMessage(t *testing.T) {
+	if got := (&Project{ChatPaused: true}).ChatUnavailableMessage(); got != DefaultPauseMessage {
+		t.Errorf("without a message = %q, want the default", got)
+	}
+	if got := (&Project{ChatPaused: true, PauseMessage: "Closed for the holidays"}).ChatUnavailableMessage(); got != "Closed for the holidays" {
+		t.Errorf("with a message = %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultOfflineMessage is sent outside business hours when a project has no message of its own
+const DefaultOfflineMessage = "We're offline right now. Leave us a message and we'll get back to you during business hours."
+
+// maxScheduleWindows bounds the number of opening windows a schedule may define
+const maxScheduleWindows = 21
+
+// WidgetSchedule represents the hours during which the bot answers live
+type WidgetSchedule struct {
+	Enabled        bool             `json:"enabled" bson:"enabled"`
+	Timezone       string           `json:"timezone" bson:"timezone"` // IANA name, e.g. "Asia/Kolkata" (empty = UTC)
+	Hours          []ScheduleWindow `json:"hours" bson:"hours"`
+	OfflineMessage string           `json:"offline_message,omitempty" bson:"offline_message,omitempty"`
+	CaptureLeads   bool             `json:"capture_leads" bson:"capture_leads"` // Ask for name/email instead of only showing the message
+}
+
+// ScheduleWindow represents one opening window on a weekday, in the schedule's timezone.
+// A Close at or before Open runs past midnight into the next day.
+type ScheduleWindow struct {
+	Day   string `json:"day" bson:"day"`     // "monday" ... "sunday"
+	Open  string `json:"open" bson:"open"`   // "09:00"
+	Close string `json:"close" bson:"close"` // "17:30"
+}
+
+// Validate checks the timezone and every window
+func (s *WidgetSchedule) Validate() error {
+	if _, err := s.location(); err != nil {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	if s.Enabled && len(s.Hours) == 0 {
+		return fmt.Errorf("an enabled schedule needs at least one opening window")
+	}
+	if len(s.Hours) > maxScheduleWindows {
+		return fmt.Errorf("a schedule can have at most %d opening windows", maxScheduleWindows)
+	}
+	for _, w := range s.Hours {
+		if _, ok := parseWeekday(w.Day); !ok {
+			return fmt.Errorf("unknown day %q", w.Day)
+		}
+		if _, err := parseClock(w.Open); err != nil {
+			return fmt.Errorf("invalid open time %q for %s, use HH:MM", w.Open, w.Day)
+		}
+		if _, err := parseClock(w.Close); err != nil {
+			return fmt.Errorf("invalid close time %q for %s, use HH:MM", w.Close, w.Day)
+		}
+	}
+	return nil
+}
+
+// IsOpen reports whether the bot answers live at t. Disabled schedules are always open.
+func (s *WidgetSchedule) IsOpen(t time.Time) bool {
+	if s == nil || !s.Enabled {
+		return true
+	}
+	loc, err := s.location()
+	if err != nil {
+		return true
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	for _, w := range s.Hours {
+		day, ok := parseWeekday(w.Day)
+		open, err1 := parseClock(w.Open)
+		closeAt, err2 := parseClock(w.Close)
+		if !ok || err1 != nil || err2 != nil {
+			continue
+		}
+		if closeAt > open {
+			if local.Weekday() == day && minute >= open && minute < closeAt {
+				return true
+			}
+			continue
+		}
+		// Overnight window: the evening of day, or the early hours of the day after
+		if local.Weekday() == day && minute >= open {
+			return true
+		}
+		if local.Weekday() == (day+1)%7 && minute < closeAt {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns when the bot next answers live after t, or the zero time if it never does
+func (s *WidgetSchedule) NextOpen(t time.Time) time.Time {
+	if s.IsOpen(t) {
+		return t
+	}
+	loc, err := s.location()
+	if err != nil {
+		return time.Time{}
+	}
+	local := t.In(loc)
+
+	var next time.Time
+	for _, w := range s.Hours {
+		day, ok := parseWeekday(w.Day)
+		open, err := parseClock(w.Open)
+		if !ok || err != nil {
+			continue
+		}
+		daysAhead := (int(day) - int(local.Weekday()) + 7) % 7
+		candidate := time.Date(local.Year(), local.Month(), local.Day()+daysAhead, open/60, open%60, 0, 0, loc)
+		if !candidate.After(local) {
+			candidate = candidate.AddDate(0, 0, 7)
+		}
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+// GetOfflineMessage returns the message sent outside business hours
+func (s *WidgetSchedule) GetOfflineMessage() string {
+	if s != nil && s.OfflineMessage != "" {
+		return s.OfflineMessage
+	}
+	return DefaultOfflineMessage
+}
+
+func (s *WidgetSchedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+	return weekday, ok
+}
+
+// parseClock converts "HH:MM" to minutes after midnight
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWidgetScheduleIsOpen(t *testing.T) {
+	schedule := &WidgetSchedule{
+		Enabled:  true,
+		Timezone: "Asia/Kolkata",
+		Hours: []ScheduleWindow{
+			{Day: "monday", Open: "09:00", Close: "17:30"},
+			{Day: "Friday", Open: "22:00", Close: "02:00"}, // overnight into Saturday
+		},
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		// 2026-01-05 is a Monday
+		return time.Date(2026, 1, 5+day, hour, minute, 0, 0, kolkata)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"monday morning", at(0, 9, 0), true},
+		{"monday before opening", at(0, 8, 59), false},
+		{"monday at closing", at(0, 17, 30), false},
+		{"same instant in UTC", at(0, 12, 0).UTC(), true},
+		{"tuesday", at(1, 12, 0), false},
+		{"friday night", at(4, 23, 0), true},
+		{"saturday early hours", at(5, 1, 30), true},
+		{"saturday after overnight close", at(5, 2, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.IsOpen(tt.t); got != tt.want {
+				t.Errorf("IsOpen(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+
+	var disabled *WidgetSchedule
+	if !disabled.IsOpen(at(1, 3, 0)) {
+		t.Error("nil schedule should always be open")
+	}
+}
+
+func TestWidgetScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule WidgetSchedule
+		wantErr  bool
+	}{
+		{"valid", WidgetSchedule{Enabled: true, Hours: []ScheduleWindow{{Day: "monday", Open: "09:00", Close: "17:00"}}}, false},
+		{"disabled without hours", WidgetSchedule{}, false},
+		{"enabled without hours", WidgetSchedule{Enabled: true}, true},
+		{"unknown timezone", WidgetSchedule{Timezone: "Mars/Olympus"}, true},
+		{"unknown day", WidgetSchedule{Hours: []ScheduleWindow{{Day: "funday", Open: "09:00", Close: "17:00"}}}, true},
+		{"bad clock", WidgetSchedule{Hours: []ScheduleWindow{{Day: "monday", Open: "9am", Close: "17:00"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schedule.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -84,9 +84,10 @@ type QuickAction struct {
 // WidgetSession represents an active widget session
 type WidgetSession struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	SessionID string             `bson:"session_id" json:"session_id"`     // Unique session identifier
-	ProjectID string             `bson:"project_id" json:"project_id"`     // Associated project
-	UserID    string             `bson:"user_id,omitempty" json:"user_id"` // Optional user identifier
+	SessionID string             `bson:"session_id" json:"session_id"`           // Unique session identifier
+	ProjectID string             `bson:"project_id" json:"project_id"`           // Associated project
+	UserID    string             `bson:"user_id,omitempty" json:"user_id"`       // Optional user identifier
+	VisitorID string             `bson:"visitor_id,omitempty" json:"visitor_id"` // Anonymous visitor, stable across sessions
 
 	// Session Information
 	IPAddress string `bson:"ip_address" json:"ip_address"` // Client IP address
@@ -103,6 +104,10 @@ type WidgetSession struct {
 	TokensUsed   int64 `bson:"tokens_used" json:"tokens_used"`     // Tokens consumed
 	Duration     int64 `bson:"duration" json:"duration"`           // Session duration in seconds
 
+	// Conversation memory: older turns condensed into a running summary
+	Summary           string    `bson:"summary,omitempty" json:"summary,omitempty"`
+	SummarizedThrough time.Time `bson:"summarized_through,omitempty" json:"summarized_through,omitempty"` // created_at of the last summarized message
+
 	// Timestamps
 	StartedAt    time.Time `bson:"started_at" json:"started_at"`
 	LastActivity time.Time `bson:"last_activity" json:"last_activity"`
@@ -122,6 +127,7 @@ type WidgetAnalytics struct {
 	// Usage Metrics
 	TotalSessions   int     `bson:"total_sessions" json:"total_sessions"`
 	UniqueSessions  int     `bson:"unique_sessions" json:"unique_sessions"`
+	UniqueVisitors  int     `bson:"unique_visitors" json:"unique_visitors"`
 	TotalMessages   int     `bson:"total_messages" json:"total_messages"`
 	AverageMessages float64 `bson:"average_messages" json:"average_messages"`
 	TotalTokens     int64   `bson:"total_tokens" json:"total_tokens"`
@@ -2,6 +2,9 @@
 (function() {
     'use strict';
     
+    // Injected by the server when this file is served from /widget.js or /widget/:projectId.js
+    var serverDefaults = window.TroikaChatbotDefaults || {};
+    
     window.TroikaChatbot = {
         init: function(config) {
             console.log('🚀 Troika Chatbot initializing...', config);
@@ -15,7 +18,7 @@
                 placeholder: 'Type your message...',
                 height: '500px',
                 width: '350px',
-                apiUrl: 'https://completetroikabackend.onrender.com/api'
+                apiUrl: serverDefaults.apiUrl || 'https://completetroikabackend.onrender.com/api'
             };
             
             // Merge configurations
@@ -200,9 +203,10 @@
             var sendBtn = container.querySelector('.troika-send-btn');
             var messageInput = container.querySelector('.troika-message-input');
             
-            // Toggle chat window
+            // Toggle chat window; the first open fetches the greeting
             chatButton.onclick = function() {
                 chatWindow.style.display = chatWindow.style.display === 'none' ? 'block' : 'none';
+                if (chatWindow.style.display === 'block') greet();
             };
             
             // Close chat window
@@ -210,15 +214,217 @@
                 chatWindow.style.display = 'none';
             };
             
+            var messagesArea = container.querySelector('.troika-messages');
+            var sessionKey = 'troika_session_' + config.projectId;
+            var visitorKey = 'troika_visitor_' + config.projectId;
+            var captchaToken = '';
+            var sending = false;
+            
+            // Bot replies and user input are shown as text, never parsed as HTML
+            var appendMessage = function(text, from) {
+                var bubble = document.createElement('div');
+                bubble.className = 'troika-message ' + from + '-message';
+                bubble.style.cssText = 'padding: 12px; border-radius: 8px; margin-bottom: 12px; white-space: pre-wrap; ' +
+                    (from === 'user'
+                        ? 'background: ' + config.primaryColor + '; color: white; margin-left: 40px;'
+                        : 'background: white; box-shadow: 0 2px 8px rgba(0,0,0,0.1); margin-right: 40px;');
+                bubble.textContent = text;
+                messagesArea.appendChild(bubble);
+                messagesArea.scrollTop = messagesArea.scrollHeight;
+            };
+            
+            // Anonymous visitors solve the project's CAPTCHA before the first message of a session
+            var captchaNeeded = function() {
+                return config.captcha && config.captcha.site_key && !config.userToken && !storage.get(sessionKey);
+            };
+            var renderCaptcha = function() {
+                if (!captchaNeeded() || container.querySelector('.troika-captcha')) return;
+                var holder = document.createElement('div');
+                holder.className = 'troika-captcha';
+                holder.style.cssText = 'margin-bottom: 12px;';
+                messagesArea.appendChild(holder);
+                loadCaptcha(config.captcha.provider, function(api) {
+                    api.render(holder, {
+                        sitekey: config.captcha.site_key,
+                        callback: function(token) { captchaToken = token; },
+                        'expired-callback': function() { captchaToken = ''; }
+                    });
+                });
+            };
+            var resetCaptcha = function() {
+                captchaToken = '';
+                var holder = container.querySelector('.troika-captcha');
+                if (holder) holder.remove();
+                renderCaptcha();
+            };
+            
+            // Identity travels in headers only: unrestricted projects answer CORS without credentials
+            var requestHeaders = function() {
+                var headers = { 'Content-Type': 'application/json' };
+                var visitorToken = storage.get(visitorKey);
+                if (visitorToken) headers['X-Visitor-Token'] = visitorToken;
+                if (config.userToken) headers['Authorization'] = 'Bearer ' + config.userToken;
+                return headers;
+            };
+            
+            // The server's greeting replaces the built-in welcome: the project's welcome message, the
+            // offline message outside business hours, or a generated opener that starts the session
+            var greeted = false;
+            var greet = function() {
+                if (greeted || !window.fetch) return;
+                greeted = true;
+                var welcome = messagesArea.querySelector('.bot-message');
+                fetch(config.apiUrl + '/projects/' + encodeURIComponent(config.projectId) + '/greeting', {
+                    method: 'POST',
+                    credentials: 'omit',
+                    headers: requestHeaders(),
+                    body: JSON.stringify({
+                        session_id: storage.get(sessionKey) || '',
+                        page_url: window.location.href,
+                        page_title: document.title
+                    })
+                })
+                    .then(function(res) {
+                        var token = res.headers.get('X-Visitor-Token');
+                        if (token) storage.set(visitorKey, token);
+                        return res.ok ? res.json() : {};
+                    })
+                    .then(function(data) {
+                        if (data.visitor_token) storage.set(visitorKey, data.visitor_token);
+                        // Only a generated opener is recorded; otherwise the first message starts the session
+                        if (data.generated && data.session_id) {
+                            storage.set(sessionKey, data.session_id);
+                            var holder = container.querySelector('.troika-captcha');
+                            if (holder) holder.remove();
+                        }
+                        if (data.greeting && welcome) welcome.textContent = data.greeting;
+                    })
+                    .catch(function() {});
+            };
+            
+            // Projects that collect visitor details ask for them once the conversation has started;
+            // the server links the lead to the session
+            var leadKey = 'troika_lead_' + config.projectId;
+            var renderLeadForm = function() {
+                if (!config.collectUserInfo || config.userToken || storage.get(leadKey) || !storage.get(sessionKey)) return;
+                if (container.querySelector('.troika-lead-form')) return;
+                
+                var form = document.createElement('form');
+                form.className = 'troika-lead-form';
+                form.style.cssText = 'background: white; padding: 12px; border-radius: 8px; margin: 0 40px 12px 0; ' +
+                    'box-shadow: 0 2px 8px rgba(0,0,0,0.1); display: flex; flex-direction: column; gap: 8px;';
+                var intro = document.createElement('div');
+                intro.textContent = 'Leave your details so we can follow up:';
+                var field = function(type, placeholder) {
+                    var input = document.createElement('input');
+                    input.type = type;
+                    input.placeholder = placeholder;
+                    input.style.cssText = 'padding: 8px; border: 1px solid #e1e5e9; border-radius: 6px;';
+                    return input;
+                };
+                var nameInput = field('text', 'Name');
+                var emailInput = field('email', 'Email');
+                emailInput.required = true;
+                var status = document.createElement('div');
+                status.style.cssText = 'font-size: 12px; color: #c0392b;';
+                var submit = document.createElement('button');
+                submit.type = 'submit';
+                submit.textContent = 'Send details';
+                submit.style.cssText = 'background: ' + config.primaryColor + '; color: white; border: none; padding: 8px; border-radius: 6px; cursor: pointer;';
+                [intro, nameInput, emailInput, status, submit].forEach(function(el) { form.appendChild(el); });
+                
+                form.onsubmit = function(e) {
+                    e.preventDefault();
+                    submit.disabled = true;
+                    status.textContent = '';
+                    fetch(config.apiUrl + '/projects/' + encodeURIComponent(config.projectId) + '/lead', {
+                        method: 'POST',
+                        credentials: 'omit',
+                        headers: requestHeaders(),
+                        body: JSON.stringify({
+                            session_id: storage.get(sessionKey) || '',
+                            name: nameInput.value.trim(),
+                            email: emailInput.value.trim()
+                        })
+                    })
+                        .then(function(res) {
+                            return res.json().catch(function() { return {}; }).then(function(data) {
+                                return { ok: res.ok, data: data };
+                            });
+                        })
+                        .then(function(result) {
+                            if (!result.ok) {
+                                status.textContent = result.data.error || 'Something went wrong. Please try again.';
+                                submit.disabled = false;
+                                return;
+                            }
+                            storage.set(leadKey, result.data.user_id || '1');
+                            form.remove();
+                            appendMessage('Thanks! We will be in touch.', 'bot');
+                        })
+                        .catch(function() {
+                            status.textContent = 'Unable to reach the server. Please try again.';
+                            submit.disabled = false;
+                        });
+                };
+                messagesArea.appendChild(form);
+                messagesArea.scrollTop = messagesArea.scrollHeight;
+            };
+            
             // Send message
             var sendMessage = function() {
                 var message = messageInput.value.trim();
-                if (message) {
-                    console.log('Sending message:', message);
-                    // Add user message to chat
-                    // Here you would typically send to your backend API
-                    messageInput.value = '';
+                if (!message || sending) return;
+                if (captchaNeeded() && !captchaToken) {
+                    renderCaptcha();
+                    appendMessage('Please complete the verification above first.', 'bot');
+                    return;
                 }
+                
+                appendMessage(message, 'user');
+                messageInput.value = '';
+                sending = true;
+                
+                fetch(config.apiUrl + '/projects/' + encodeURIComponent(config.projectId) + '/chat', {
+                    method: 'POST',
+                    credentials: 'omit',
+                    headers: requestHeaders(),
+                    body: JSON.stringify({
+                        message: message,
+                        session_id: storage.get(sessionKey) || '',
+                        captcha_token: captchaToken,
+                        page_url: window.location.href,
+                        page_title: document.title
+                    })
+                })
+                    .then(function(res) {
+                        var token = res.headers.get('X-Visitor-Token');
+                        if (token) storage.set(visitorKey, token);
+                        return res.json().catch(function() { return {}; }).then(function(data) {
+                            return { ok: res.ok, data: data };
+                        });
+                    })
+                    .then(function(result) {
+                        var data = result.data;
+                        if (data.visitor_token) storage.set(visitorKey, data.visitor_token);
+                        if (!result.ok) {
+                            if (data.code === 'CAPTCHA_REQUIRED') resetCaptcha();
+                            appendMessage(data.error || 'Something went wrong. Please try again.', 'bot');
+                            return;
+                        }
+                        if (data.session_id) {
+                            storage.set(sessionKey, data.session_id);
+                            var holder = container.querySelector('.troika-captcha');
+                            if (holder) holder.remove();
+                            captchaToken = '';
+                        }
+                        appendMessage(data.response || '', 'bot');
+                        renderLeadForm();
+                    })
+                    .catch(function() {
+                        appendMessage('Unable to reach the server. Please try again.', 'bot');
+                    })
+                    .then(function() { sending = false; });
             };
             
             sendBtn.onclick = sendMessage;
@@ -227,17 +433,82 @@
                     sendMessage();
                 }
             };
+            renderCaptcha();
         }
     };
     
-    // Auto-initialize if data-project-id is present
-    var scripts = document.querySelectorAll('script[data-project-id]');
-    scripts.forEach(function(script) {
+    // Session and visitor tokens survive page loads; storage may be unavailable in sandboxed frames
+    var storage = {
+        get: function(key) {
+            try { return window.localStorage.getItem(key); } catch (e) { return null; }
+        },
+        set: function(key, value) {
+            try { window.localStorage.setItem(key, value); } catch (e) {}
+        }
+    };
+    
+    // Provider scripts, loaded once and in explicit render mode
+    var captchaScripts = {
+        turnstile: { src: 'https://challenges.cloudflare.com/turnstile/v0/api.js?render=explicit', global: 'turnstile' },
+        hcaptcha: { src: 'https://js.hcaptcha.com/1/api.js?render=explicit', global: 'hcaptcha' },
+        recaptcha: { src: 'https://www.google.com/recaptcha/api.js?render=explicit', global: 'grecaptcha' }
+    };
+    var loadCaptcha = function(provider, ready) {
+        var script = captchaScripts[provider];
+        if (!script) return;
+        var whenLoaded = function() {
+            var api = window[script.global];
+            if (api && api.render) {
+                ready(api);
+            } else {
+                setTimeout(whenLoaded, 100);
+            }
+        };
+        if (!document.querySelector('script[src="' + script.src + '"]')) {
+            var tag = document.createElement('script');
+            tag.src = script.src;
+            tag.async = true;
+            document.head.appendChild(tag);
+        }
+        whenLoaded();
+    };
+    
+    // Load the project's saved widget settings, then initialize unless the page already did
+    var autoInit = function(projectId) {
+        var apiUrl = serverDefaults.apiUrl || 'https://completetroikabackend.onrender.com/api';
+        var initWith = function(config) {
+            if (document.getElementById('troika-widget-' + projectId)) return;
+            window.TroikaChatbot.init(Object.assign({}, config, { projectId: projectId, userToken: userTokens[projectId] }));
+        };
+        
+        if (!window.fetch) {
+            initWith({});
+            return;
+        }
+        
+        fetch(apiUrl + '/embed/' + encodeURIComponent(projectId) + '/config')
+            .then(function(res) { return res.ok ? res.json() : {}; })
+            .then(function(data) {
+                initWith(Object.assign({}, data.widget, { captcha: data.captcha, collectUserInfo: data.collect_user_info }));
+            })
+            .catch(function() { initWith({}); });
+    };
+    
+    // Auto-initialize for /widget/:projectId.js or any script tag with data-project-id;
+    // data-user-token carries a signed-in embed user's chat token
+    var projectIds = [];
+    var userTokens = {};
+    if (serverDefaults.projectId) {
+        projectIds.push(serverDefaults.projectId);
+    }
+    document.querySelectorAll('script[data-project-id]').forEach(function(script) {
         var projectId = script.getAttribute('data-project-id');
-        if (projectId) {
-            window.TroikaChatbot.init({
-                projectId: projectId
-            });
+        if (projectId && projectIds.indexOf(projectId) === -1) {
+            projectIds.push(projectId);
+        }
+        if (projectId && script.getAttribute('data-user-token')) {
+            userTokens[projectId] = script.getAttribute('data-user-token');
         }
     });
+    projectIds.forEach(autoInit);
 })();
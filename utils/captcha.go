@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Server-side verification endpoints for supported CAPTCHA providers
+var captchaVerifyURLs = map[string]string{
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+// CaptchaConfigured reports whether CAPTCHA_PROVIDER, CAPTCHA_SECRET and CAPTCHA_SITE_KEY are set to
+// something usable. Without a site key the widget cannot render a challenge, so none is demanded.
+func CaptchaConfigured() bool {
+	_, ok := captchaVerifyURLs[CaptchaProvider()]
+	return ok && os.Getenv("CAPTCHA_SECRET") != "" && CaptchaSiteKey() != ""
+}
+
+// CaptchaProvider returns the configured provider name (hcaptcha, recaptcha or turnstile)
+func CaptchaProvider() string {
+	return strings.ToLower(os.Getenv("CAPTCHA_PROVIDER"))
+}
+
+// CaptchaSiteKey returns the public key the widget renders the challenge with
+func CaptchaSiteKey() string {
+	return os.Getenv("CAPTCHA_SITE_KEY")
+}
+
+// VerifyCaptcha checks a client CAPTCHA token with the configured provider
+func VerifyCaptcha(ctx context.Context, token, remoteIP string) (bool, error) {
+	provider := CaptchaProvider()
+	verifyURL, ok := captchaVerifyURLs[provider]
+	if !ok {
+		return false, fmt.Errorf("unsupported CAPTCHA provider %q", provider)
+	}
+
+	secret := os.Getenv("CAPTCHA_SECRET")
+	if secret == "" {
+		return false, fmt.Errorf("CAPTCHA_SECRET not configured")
+	}
+
+	if token == "" {
+		return false, nil
+	}
+
+	form := url.Values{}
+	form.Set("secret", secret)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("CAPTCHA verification request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("invalid CAPTCHA verification response: %v", err)
+	}
+
+	return result.Success, nil
+}
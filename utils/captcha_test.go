@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubCaptchaProvider - Point the turnstile verify URL at a server accepting only "good-token"
+func stubCaptchaProvider(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		ok := r.PostForm.Get("secret") == "test-secret" && r.PostForm.Get("response") == "good-token"
+		json.NewEncoder(w).Encode(map[string]bool{"success": ok})
+	}))
+	t.Cleanup(server.Close)
+
+	previous := captchaVerifyURLs["turnstile"]
+	captchaVerifyURLs["turnstile"] = server.URL
+	t.Cleanup(func() { captchaVerifyURLs["turnstile"] = previous })
+
+	t.Setenv("CAPTCHA_PROVIDER", "Turnstile")
+	t.Setenv("CAPTCHA_SECRET", "test-secret")
+	t.Setenv("CAPTCHA_SITE_KEY", "test-site-key")
+}
+
+func TestVerifyCaptcha(t *testing.T) {
+	stubCaptchaProvider(t)
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"valid token passes", "good-token", true},
+		{"wrong token fails", "bad-token", false},
+		{"missing token fails", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyCaptcha(context.Background(), tt.token, "203.0.113.1")
+			if err != nil {
+				t.Fatalf("VerifyCaptcha: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyCaptcha(%q) = %v, want %v", tt.token, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptchaConfigured(t *testing.T) {
+	tests := []struct {
+		name, provider, secret, siteKey string
+		want                            bool
+	}{
+		{"fully configured", "hcaptcha", "secret", "site", true},
+		{"unknown provider", "mycaptcha", "secret", "site", false},
+		{"missing secret", "hcaptcha", "", "site", false},
+		{"missing site key", "hcaptcha", "secret", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CAPTCHA_PROVIDER", tt.provider)
+			t.Setenv("CAPTCHA_SECRET", tt.secret)
+			t.Setenv("CAPTCHA_SITE_KEY", tt.siteKey)
+			if got := CaptchaConfigured(); got != tt.want {
+				t.Errorf("CaptchaConfigured() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
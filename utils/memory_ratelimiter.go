@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// MemoryRateLimiter is a fixed-window, per-key rate limiter held in process memory.
+// It is the default when Redis is not configured; limits are per instance.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+	now     func() time.Time
+}
+
+// NewMemoryRateLimiter creates an in-memory rate limiter and starts its cleanup loop
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return NewMemoryRateLimiterWithClock(time.Now)
+}
+
+// NewMemoryRateLimiterWithClock creates an in-memory rate limiter whose windows are measured
+// against now, so tests can move time forward
+func NewMemoryRateLimiterWithClock(now func() time.Time) *MemoryRateLimiter {
+	rl := &MemoryRateLimiter{windows: make(map[string]*rateWindow), now: now}
+	go rl.cleanupLoop(time.Minute)
+	return rl
+}
+
+// Allow records a hit for key and reports whether it is within limit for the current window
+func (rl *MemoryRateLimiter) Allow(key string, limit int, window time.Duration) bool {
+	now := rl.now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	w, ok := rl.windows[key]
+	if !ok || now.After(w.resetAt) {
+		rl.windows[key] = &rateWindow{count: 1, resetAt: now.Add(window)}
+		return 1 <= limit
+	}
+
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// RetryAfter returns how long until key's current window resets
+func (rl *MemoryRateLimiter) RetryAfter(key string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if w, ok := rl.windows[key]; ok {
+		if d := w.resetAt.Sub(rl.now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// cleanupLoop drops expired windows so idle keys don't accumulate
+func (rl *MemoryRateLimiter) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := rl.now()
+		rl.mu.Lock()
+		for key, w := range rl.windows {
+			if now.After(w.resetAt) {
+				delete(rl.windows, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// Digest statuses of a notification
+const (
+	notificationQueued   = "queued"   // waiting for the client's next digest
+	notificationDigested = "digested" // sent as part of a digest
+)
+
+// maxDigestItems - Most notifications listed in one digest; the rest wait for the next one
+const maxDigestItems = 200
+
+// digestNotificationTypes - Low-priority notifications that clients on the daily digest receive
+// batched; everything else (limits reached, expiry, suspensions) is still delivered immediately
+var digestNotificationTypes = map[string]bool{
+	config.NotificationUsageWarning: true,
+}
+
+// queueForDigest - Hold a notification for the client's next digest instead of delivering it
+func queueForDigest(ctx context.Context, notificationID primitive.ObjectID, client *models.Client, project *models.Project) {
+	_, err := config.GetNotificationsCollection().UpdateOne(ctx,
+		bson.M{"_id": notificationID},
+		bson.M{"$set": bson.M{
+			"status":              notificationQueued,
+			"digest_client_id":    client.ClientID,
+			"digest_project_name": project.Name,
+		}},
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to queue notification %s for the digest of %s: %v", notificationID.Hex(), client.ClientID, err)
+	}
+}
+
+// SendNotificationDigests emails every client with queued notifications one digest listing them,
+// and reports how many digests were sent. Notifications stay queued if their digest can't be
+// delivered, so they go out with the next run. Run once a day by the maintenance job.
+func SendNotificationDigests() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	notifications := config.GetNotificationsCollection()
+	clientIDs, err := notifications.Distinct(ctx, "digest_client_id", bson.M{"status": notificationQueued})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list clients with queued notifications: %v", err)
+	}
+
+	sent := 0
+	for _, value := range clientIDs {
+		clientID, ok := value.(string)
+		if !ok || clientID == "" {
+			continue
+		}
+		if err := sendClientDigest(ctx, clientID); err != nil {
+			log.Printf("⚠️ Digest for client %s not sent: %v", clientID, err)
+			continue
+		}
+		sent++
+	}
+
+	log.Printf("📬 Sent %d notification digests", sent)
+	return sent, nil
+}
+
+// sendClientDigest - One digest email with the client's queued notifications, oldest first
+func sendClientDigest(ctx context.Context, clientID string) error {
+	var client models.Client
+	if err := config.GetClientsCollection().FindOne(ctx, bson.M{"client_id": clientID}).Decode(&client); err != nil {
+		return fmt.Errorf("client not found: %v", err)
+	}
+	if client.Email == "" || !SMTPConfigured() {
+		return fmt.Errorf("no email address or SMTP not configured")
+	}
+
+	var queued []struct {
+		ID          primitive.ObjectID `bson:"_id"`
+		Type        string             `bson:"type"`
+		Message     string             `bson:"message"`
+		ProjectName string             `bson:"digest_project_name"`
+		SentAt      time.Time          `bson:"sent_at"`
+	}
+	cursor, err := config.GetNotificationsCollection().Find(ctx,
+		bson.M{"status": notificationQueued, "digest_client_id": clientID},
+		options.Find().SetSort(bson.M{"sent_at": 1}).SetLimit(maxDigestItems),
+	)
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(ctx, &queued); err != nil {
+		return err
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d notifications since your last digest:\n\n", len(queued))
+	ids := make([]primitive.ObjectID, 0, len(queued))
+	for _, item := range queued {
+		fmt.Fprintf(&body, "- %s [%s] %s\n", item.SentAt.UTC().Format("2006-01-02 15:04 MST"), item.ProjectName, item.Message)
+		ids = append(ids, item.ID)
+	}
+
+	channel := &EmailChannel{To: client.Email}
+	err = channel.Send(ctx, Notification{
+		ProjectName: "Daily digest",
+		Type:        config.NotificationDigest,
+		Message:     body.String(),
+		SentAt:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = config.GetNotificationsCollection().UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"status": notificationDigested, "digest_sent_at": time.Now()}},
+	)
+	return err
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// fakeSMTP - A minimal SMTP server on localhost that accepts every message, configured as
+// SMTP_HOST/SMTP_PORT for the test. The returned channel receives each message's data.
+func fakeSMTP(t *testing.T) <-chan string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_FROM", "alerts@example.com")
+	t.Setenv("SMTP_USERNAME", "")
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(textproto.NewConn(conn), messages)
+		}
+	}()
+	return messages
+}
+
+func serveSMTP(conn *textproto.Conn, messages chan<- string) {
+	defer conn.Close()
+	conn.PrintfLine("220 localhost ready")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+		case "DATA":
+			conn.PrintfLine("354 go ahead")
+			data, _ := conn.ReadDotLines()
+			messages <- strings.Join(data, "\n")
+			conn.PrintfLine("250 queued")
+		case "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestNotificationDigest(t *testing.T) {
+	ctx := useTestDatabase(t)
+	messages := fakeSMTP(t)
+
+	clients := []interface{}{
+		models.Client{ID: primitive.NewObjectID(), ClientID: "digest", Email: "owner@example.com", NotificationPrefs: models.NotificationPrefs{DailyDigest: true}},
+		models.Client{ID: primitive.NewObjectID(), ClientID: "immediate", Email: "other@example.com"},
+	}
+	config.GetClientsCollection().InsertMany(ctx, clients)
+	digestProject := models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_digest", Name: "Acme", ClientID: "digest"}
+	immediateProject := models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_now", Name: "Beta", ClientID: "immediate"}
+	config.GetProjectsCollection().InsertMany(ctx, []interface{}{digestProject, immediateProject})
+
+	dispatch := func(project models.Project, notificationType, message string) primitive.ObjectID {
+		t.Helper()
+		id, err := config.RecordNotification(project.ID, notificationType, message)
+		if err != nil {
+			t.Fatalf("RecordNotification: %v", err)
+		}
+		DispatchNotification(id, project.ID, notificationType, message)
+		return id
+	}
+	status := func(id primitive.ObjectID) string {
+		var n struct {
+			Status string `bson:"status"`
+		}
+		config.GetNotificationsCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&n)
+		return n.Status
+	}
+
+	first := dispatch(digestProject, config.NotificationUsageWarning, "80% of tokens used")
+	second := dispatch(digestProject, config.NotificationUsageWarning, "90% of tokens used")
+	urgent := dispatch(digestProject, config.NotificationExpired, "Subscription expired")
+	other := dispatch(immediateProject, config.NotificationUsageWarning, "80% of tokens used")
+
+	if status(first) != notificationQueued || status(second) != notificationQueued {
+		t.Errorf("usage warnings for a digest client = %s, %s; want queued", status(first), status(second))
+	}
+	if status(urgent) == notificationQueued || status(other) == notificationQueued {
+		t.Errorf("expiry = %s, other client = %s; want both delivered immediately", status(urgent), status(other))
+	}
+	drain(messages)
+
+	sent, err := SendNotificationDigests()
+	if err != nil || sent != 1 {
+		t.Fatalf("SendNotificationDigests = %d, %v; want one digest", sent, err)
+	}
+	select {
+	case message := <-messages:
+		if !strings.Contains(message, "To: owner@example.com") || !strings.Contains(message, "2 notifications since your last digest") ||
+			strings.Index(message, "80%") > strings.Index(message, "90%") {
+			t.Errorf("digest = %q, want both warnings oldest first", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no digest email sent")
+	}
+	if status(first) != notificationDigested || status(second) != notificationDigested {
+		t.Errorf("after the digest: %s, %s; want digested", status(first), status(second))
+	}
+
+	if sent, _ := SendNotificationDigests(); sent != 0 {
+		t.Errorf("second run sent %d digests, want none with nothing queued", sent)
+	}
+}
+
+func TestNotificationDigestKeepsQueueWhenUndeliverable(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("SMTP_HOST", "")
+
+	config.GetClientsCollection().InsertOne(ctx, models.Client{ClientID: "digest", Email: "owner@example.com"})
+	id := primitive.NewObjectID()
+	config.GetNotificationsCollection().InsertOne(ctx, bson.M{
+		"_id": id, "type": config.NotificationUsageWarning, "message": "80% of tokens used",
+		"status": notificationQueued, "digest_client_id": "digest", "sent_at": time.Now(),
+	})
+
+	if sent, err := SendNotificationDigests(); err != nil || sent != 0 {
+		t.Errorf("SendNotificationDigests = %d, %v; want nothing sent without SMTP", sent, err)
+	}
+	var n bson.M
+	config.GetNotificationsCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&n)
+	if n["status"] != notificationQueued {
+		t.Errorf("status = %v, want the notification left queued for the next run", n["status"])
+	}
+}
+
+// drain - Discard whatever messages have arrived so far
+func drain(messages <-chan string) {
+	for {
+		select {
+		case <-messages:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
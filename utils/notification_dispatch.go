@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+// criticalNotificationTypes - Notifications worth a text message to clients who enabled SMS
+var criticalNotificationTypes = map[string]bool{
+	config.NotificationExpired:       true,
+	config.NotificationPaymentFailed: true,
+}
+
+// DispatchNotification delivers a logged notification to its target's channels (the project's,
+// or AdminChannels for a nil project ID) and records the per-channel outcome on the notification.
+// Installed with config.SetNotificationDispatcher so every LogNotification fans out.
+func DispatchNotification(notificationID, projectID primitive.ObjectID, notificationType, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	n := Notification{Type: notificationType, Message: message, SentAt: time.Now()}
+
+	var channels []NotificationChannel
+	if projectID.IsZero() {
+		channels = AdminChannels()
+	} else {
+		var project models.Project
+		err := config.GetProjectsCollection().FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+		if err != nil {
+			log.Printf("⚠️ Notification %s: project %s not found: %v", notificationID.Hex(), projectID.Hex(), err)
+			return
+		}
+		n.ProjectID, n.ProjectName = project.ProjectID, project.Name
+
+		// Clients on the daily digest get low-priority alerts in SendNotificationDigests instead
+		if digestNotificationTypes[notificationType] {
+			if client, err := projectClient(ctx, &project); err == nil && client.NotificationPrefs.DailyDigest {
+				queueForDigest(ctx, notificationID, client, &project)
+				return
+			}
+		}
+
+		channels = ProjectChannels(project.GetNotificationEmail(), project.NotificationWebhookURL, project.NotificationSlackURL)
+		if criticalNotificationTypes[notificationType] {
+			if sms := clientSMSChannel(ctx, &project); sms != nil {
+				channels = append(channels, sms)
+			}
+		}
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	results := Deliver(ctx, channels, n)
+
+	delivered := 0
+	for _, result := range results {
+		if result.Success {
+			delivered++
+			continue
+		}
+		log.Printf("⚠️ %s notification via %s to %s failed: %s", notificationType, result.Channel, result.Target, result.Error)
+	}
+	status := "delivered"
+	switch {
+	case delivered == 0:
+		status = "failed"
+	case delivered < len(results):
+		status = "partially_delivered"
+	}
+
+	_, err := config.GetNotificationsCollection().UpdateOne(ctx,
+		bson.M{"_id": notificationID},
+		bson.M{"$set": bson.M{"status": status, "channels": results}},
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to record delivery of notification %s: %v", notificationID.Hex(), err)
+	}
+}
+
+// projectClient - The client record of a project, which references it by client_id or by email
+func projectClient(ctx context.Context, project *models.Project) (*models.Client, error) {
+	if project.ClientID == "" {
+		return nil, mongo.ErrNoDocuments
+	}
+	var client models.Client
+	err := config.GetClientsCollection().FindOne(ctx, bson.M{"$or": []bson.M{
+		{"client_id": project.ClientID},
+		{"email": project.ClientID},
+	}}).Decode(&client)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// clientSMSChannel - An SMS channel to the project's client when Twilio is configured and the
+// client has SMS alerts on, a valid phone number and hasn't opted out; nil otherwise
+func clientSMSChannel(ctx context.Context, project *models.Project) NotificationChannel {
+	if !SMSConfigured() {
+		return nil
+	}
+
+	client, err := projectClient(ctx, project)
+	if err != nil || !client.CanReceiveSMS() {
+		return nil
+	}
+	if !IsE164(client.Phone) {
+		log.Printf("⚠️ Client %s has SMS alerts on but phone %q is not in E.164 format", client.ClientID, client.Phone)
+		return nil
+	}
+
+	return &SMSChannel{
+		To:     client.Phone,
+		Sender: NewTwilioClientFromEnv(),
+		OnOptOut: func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := config.GetClientsCollection().UpdateOne(ctx,
+				bson.M{"_id": client.ID},
+				bson.M{"$set": bson.M{"sms_opted_out_at": time.Now(), "updated_at": time.Now()}},
+			)
+			if err != nil {
+				log.Printf("⚠️ Failed to record SMS opt-out for client %s: %v", client.ClientID, err)
+				return
+			}
+			log.Printf("📵 Client %s opted out of SMS alerts", client.ClientID)
+		},
+	}
+}
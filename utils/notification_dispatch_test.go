@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"jevi-chat/config"
+	"jevi-chat/models"
+)
+
+func TestDispatchNotification(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("SMTP_HOST", "")
+
+	received := 0
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { received++ }))
+	defer webhook.Close()
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer slack.Close()
+
+	project := models.Project{ID: primitive.NewObjectID(), ProjectID: "proj_notify", Name: "Acme",
+		NotificationWebhookURL: webhook.URL, NotificationSlackURL: slack.URL}
+	if _, err := config.GetProjectsCollection().InsertOne(ctx, project); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+
+	status := func(id primitive.ObjectID) (string, int) {
+		var n struct {
+			Status   string          `bson:"status"`
+			Channels []ChannelResult `bson:"channels"`
+		}
+		config.GetNotificationsCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&n)
+		return n.Status, len(n.Channels)
+	}
+
+	id, err := config.RecordNotification(project.ID, config.NotificationExpired, "Subscription expired")
+	if err != nil {
+		t.Fatalf("RecordNotification: %v", err)
+	}
+	DispatchNotification(id, project.ID, config.NotificationExpired, "Subscription expired")
+	if got, channels := status(id); got != "partially_delivered" || channels != 2 || received != 1 {
+		t.Errorf("status = %s with %d channels (%d webhook calls), want partially_delivered over 2", got, channels, received)
+	}
+
+	// Platform notifications go to the admin channels
+	t.Setenv("NOTIFICATION_WEBHOOK_URL", webhook.URL)
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	id, _ = config.RecordNotification(primitive.NilObjectID, "maintenance", "Nightly job failed")
+	DispatchNotification(id, primitive.NilObjectID, "maintenance", "Nightly job failed")
+	if got, channels := status(id); got != "delivered" || channels != 1 || received != 2 {
+		t.Errorf("admin status = %s with %d channels, want delivered over the admin webhook", got, channels)
+	}
+
+	// A project that no longer exists is skipped without delivering anything
+	id, _ = config.RecordNotification(primitive.NewObjectID(), "test", "orphan")
+	DispatchNotification(id, primitive.NewObjectID(), "test", "orphan")
+	if got, _ := status(id); got != "sent" || received != 2 {
+		t.Errorf("orphaned notification status = %q, want it left undelivered", got)
+	}
+}
+
+func TestClientSMSChannel(t *testing.T) {
+	ctx := useTestDatabase(t)
+	t.Setenv("TWILIO_ACCOUNT_SID", "AC123")
+	t.Setenv("TWILIO_AUTH_TOKEN", "token")
+	t.Setenv("TWILIO_FROM_NUMBER", "+14155550100")
+
+	sms := models.NotificationPrefs{SMSNotifications: true}
+	clients := []interface{}{
+		models.Client{ID: primitive.NewObjectID(), ClientID: "texts", Phone: "+447911123456", NotificationPrefs: sms},
+		models.Client{ID: primitive.NewObjectID(), ClientID: "local_format", Phone: "07911 123456", NotificationPrefs: sms},
+		models.Client{ID: primitive.NewObjectID(), ClientID: "no_alerts", Phone: "+447911123456"},
+	}
+	if _, err := config.GetClientsCollection().InsertMany(ctx, clients); err != nil {
+		t.Fatalf("insert clients: %v", err)
+	}
+
+	channel := clientSMSChannel(ctx, &models.Project{ClientID: "texts"})
+	if channel == nil || channel.Target() != "*********3456" {
+		t.Fatalf("channel = %v, want an SMS channel to the client's phone", channel)
+	}
+	for _, clientID := range []string{"local_format", "no_alerts", "unknown", ""} {
+		if channel := clientSMSChannel(ctx, &models.Project{ClientID: clientID}); channel != nil {
+			t.Errorf("client %q: got an SMS channel, want none", clientID)
+		}
+	}
+
+	// Replying STOP is recorded so the client isn't texted again
+	channel.(*SMSChannel).OnOptOut()
+	if channel := clientSMSChannel(ctx, &models.Project{ClientID: "texts"}); channel != nil {
+		t.Error("opted-out client still gets an SMS channel")
+	}
+
+	t.Setenv("TWILIO_AUTH_TOKEN", "")
+	if channel := clientSMSChannel(ctx, &models.Project{ClientID: "texts"}); channel != nil {
+		t.Error("SMS channel built without Twilio configured")
+	}
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification is one message delivered to a project's configured channels
+type Notification struct {
+	ProjectID   string    `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Type        string    `json:"type"`
+	Message     string    `json:"message"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// NotificationChannel delivers notifications to one destination
+type NotificationChannel interface {
+	// Name identifies the channel in delivery results ("email", "webhook")
+	Name() string
+	// Target is the destination shown to admins (address or URL)
+	Target() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// ChannelResult is the outcome of delivering to one channel
+type ChannelResult struct {
+	Channel string `json:"channel"`
+	Target  string `json:"target"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Deliver sends n through every channel at once and reports each result in channel order; a
+// failing, slow or panicking channel doesn't hold up or stop the others
+func Deliver(ctx context.Context, channels []NotificationChannel, n Notification) []ChannelResult {
+	results := make([]ChannelResult, len(channels))
+	var wg sync.WaitGroup
+	for i, channel := range channels {
+		wg.Add(1)
+		go func(i int, channel NotificationChannel) {
+			defer wg.Done()
+			result := ChannelResult{Channel: channel.Name(), Target: channel.Target(), Success: true}
+			defer func() {
+				if r := recover(); r != nil {
+					result.Success = false
+					result.Error = fmt.Sprintf("channel panicked: %v", r)
+				}
+				results[i] = result
+			}()
+			if err := channel.Send(ctx, n); err != nil {
+				result.Success = false
+				result.Error = err.Error()
+			}
+		}(i, channel)
+	}
+	wg.Wait()
+	return results
+}
+
+// ProjectChannels returns the channels configured for a project: email when SMTP is set up
+// and the project has a recipient, webhook and Slack when the project has their URLs
+func ProjectChannels(email, webhookURL, slackURL string) []NotificationChannel {
+	var channels []NotificationChannel
+	if email != "" && SMTPConfigured() {
+		channels = append(channels, &EmailChannel{To: email})
+	}
+	if webhookURL != "" {
+		channels = append(channels, &WebhookChannel{URL: webhookURL, Secret: os.Getenv("NOTIFICATION_WEBHOOK_SECRET")})
+	}
+	if slackURL != "" {
+		channels = append(channels, &SlackChannel{WebhookURL: slackURL})
+	}
+	return channels
+}
+
+// AdminChannels returns the platform-wide channels for notifications that aren't about one
+// project: NOTIFICATION_EMAIL, NOTIFICATION_WEBHOOK_URL and SLACK_WEBHOOK_URL, each of which can
+// be switched off with EMAIL_NOTIFICATIONS, WEBHOOK_NOTIFICATIONS or SLACK_NOTIFICATIONS=false
+func AdminChannels() []NotificationChannel {
+	enabled := func(name string) bool { return os.Getenv(name) != "false" }
+
+	var email, webhookURL, slackURL string
+	if enabled("EMAIL_NOTIFICATIONS") {
+		email = os.Getenv("NOTIFICATION_EMAIL")
+	}
+	if enabled("WEBHOOK_NOTIFICATIONS") {
+		webhookURL = os.Getenv("NOTIFICATION_WEBHOOK_URL")
+	}
+	if enabled("SLACK_NOTIFICATIONS") {
+		slackURL = os.Getenv("SLACK_WEBHOOK_URL")
+	}
+	return ProjectChannels(email, webhookURL, slackURL)
+}
+
+// SMTPConfigured reports whether SMTP_HOST and SMTP_FROM are set
+func SMTPConfigured() bool {
+	return os.Getenv("SMTP_HOST") != "" && os.Getenv("SMTP_FROM") != ""
+}
+
+// EmailChannel sends notifications by SMTP (SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM)
+type EmailChannel struct {
+	To string
+}
+
+func (e *EmailChannel) Name() string   { return "email" }
+func (e *EmailChannel) Target() string { return e.To }
+
+func (e *EmailChannel) Send(ctx context.Context, n Notification) error {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return fmt.Errorf("SMTP_HOST/SMTP_FROM not configured")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	subject := fmt.Sprintf("[%s] %s notification", n.ProjectName, n.Type)
+	body := strings.Join([]string{
+		"From: " + from,
+		"To: " + e.To,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		n.Message,
+	}, "\r\n")
+
+	// net/smtp has no context support; bound the wait instead
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(host+":"+port, auth, from, []string{e.To}, []byte(body))
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("SMTP delivery failed: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("SMTP delivery timed out")
+	}
+}
+
+// WebhookChannel POSTs notifications as JSON. With a secret, the body is signed with
+// HMAC-SHA256 in the X-Signature-256 header ("sha256=<hex>").
+type WebhookChannel struct {
+	URL    string
+	Secret string
+}
+
+func (w *WebhookChannel) Name() string   { return "webhook" }
+func (w *WebhookChannel) Target() string { return w.URL }
+
+func (w *WebhookChannel) Send(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set("X-Signature-256", SignPayload(w.Secret, payload))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SlackChannel posts notifications to a Slack incoming webhook
+type SlackChannel struct {
+	WebhookURL string
+}
+
+func (s *SlackChannel) Name() string { return "slack" }
+
+// Target hides the webhook's secret path; the host is enough to tell channels apart
+func (s *SlackChannel) Target() string {
+	if parsed, err := url.Parse(s.WebhookURL); err == nil && parsed.Host != "" {
+		return parsed.Scheme + "://" + parsed.Host
+	}
+	return "slack"
+}
+
+func (s *SlackChannel) Send(ctx context.Context, n Notification) error {
+	title := n.Type
+	if n.ProjectName != "" {
+		title = fmt.Sprintf("[%s] %s", n.ProjectName, n.Type)
+	}
+	payload, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", title, n.Message),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid Slack webhook URL: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Slack request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Slack returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignPayload returns the X-Signature-256 value for a webhook body: "sha256=<hex HMAC-SHA256>"
+func SignPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
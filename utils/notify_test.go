@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubChannel - A NotificationChannel that records what it was sent and returns err
+type stubChannel struct {
+	name string
+	err  error
+	sent []Notification
+}
+
+func (s *stubChannel) Name() string   { return s.name }
+func (s *stubChannel) Target() string { return s.name + "-target" }
+func (s *stubChannel) Send(ctx context.Context, n Notification) error {
+	s.sent = append(s.sent, n)
+	return s.err
+}
+
+func TestDeliverReportsEveryChannel(t *testing.T) {
+	failing := &stubChannel{name: "email", err: errors.New("mailbox full")}
+	working := &stubChannel{name: "webhook"}
+	n := Notification{ProjectID: "proj_1", Type: "test", Message: "hello"}
+
+	results := Deliver(context.Background(), []NotificationChannel{failing, working}, n)
+
+	want := []ChannelResult{
+		{Channel: "email", Target: "email-target", Success: false, Error: "mailbox full"},
+		{Channel: "webhook", Target: "webhook-target", Success: true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v, want %+v", results, want)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+	if len(working.sent) != 1 || working.sent[0].Message != "hello" {
+		t.Errorf("a failing channel stopped delivery to the next: %+v", working.sent)
+	}
+}
+
+func TestProjectChannels(t *testing.T) {
+	names := func(channels []NotificationChannel) string {
+		var out []string
+		for _, c := range channels {
+			out = append(out, c.Name()+"="+c.Target())
+		}
+		return strings.Join(out, ",")
+	}
+
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("SMTP_FROM", "")
+	if got := names(ProjectChannels("a@example.com", "", "")); got != "" {
+		t.Errorf("email channel without SMTP configured: %s", got)
+	}
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "alerts@example.com")
+	t.Setenv("NOTIFICATION_WEBHOOK_SECRET", "shh")
+	channels := ProjectChannels("a@example.com", "https://hooks.example.com/n", "")
+	if got := names(channels); got != "email=a@example.com,webhook=https://hooks.example.com/n" {
+		t.Errorf("channels = %s", got)
+	}
+	if webhook := channels[1].(*WebhookChannel); webhook.Secret != "shh" {
+		t.Errorf("webhook secret = %q, want NOTIFICATION_WEBHOOK_SECRET", webhook.Secret)
+	}
+	if got := names(ProjectChannels("", "", "https://hooks.slack.com/services/T0/B0/secret")); got != "slack=https://hooks.slack.com" {
+		t.Errorf("Slack channel = %s, want the host only as target", got)
+	}
+	if got := names(ProjectChannels("", "", "")); got != "" {
+		t.Errorf("channels without any destination: %s", got)
+	}
+}
+
+func TestAdminChannels(t *testing.T) {
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "alerts@example.com")
+	t.Setenv("NOTIFICATION_EMAIL", "ops@example.com")
+	t.Setenv("NOTIFICATION_WEBHOOK_URL", "https://hooks.example.com/n")
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T0/B0/secret")
+
+	if got := AdminChannels(); len(got) != 3 || got[0].Name() != "email" || got[1].Name() != "webhook" || got[2].Name() != "slack" {
+		t.Errorf("AdminChannels() = %d channels, want email, webhook and Slack", len(got))
+	}
+
+	t.Setenv("EMAIL_NOTIFICATIONS", "false")
+	t.Setenv("SLACK_NOTIFICATIONS", "false")
+	if got := AdminChannels(); len(got) != 1 || got[0].Name() != "webhook" {
+		t.Errorf("AdminChannels() with email and Slack off = %d channels, want the webhook only", len(got))
+	}
+}
+
+func TestDeliverRecoversFromPanics(t *testing.T) {
+	working := &stubChannel{name: "webhook"}
+	results := Deliver(context.Background(), []NotificationChannel{panickingChannel{}, working}, Notification{Message: "hello"})
+
+	if results[0].Success || !strings.Contains(results[0].Error, "channel panicked") {
+		t.Errorf("panicking channel result = %+v", results[0])
+	}
+	if !results[1].Success || len(working.sent) != 1 {
+		t.Errorf("a panicking channel stopped delivery to the next: %+v", results[1])
+	}
+}
+
+// panickingChannel - A NotificationChannel whose Send panics
+type panickingChannel struct{}
+
+func (panickingChannel) Name() string   { return "broken" }
+func (panickingChannel) Target() string { return "broken-target" }
+func (panickingChannel) Send(ctx context.Context, n Notification) error {
+	panic("nil map")
+}
+
+func TestSlackChannel(t *testing.T) {
+	var payload map[string]string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	slack := &SlackChannel{WebhookURL: server.URL + "/services/T0/B0/secret"}
+
+	if err := slack.Send(context.Background(), Notification{ProjectName: "Acme", Type: "lead", Message: "New lead"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if payload["text"] != "*[Acme] lead*\nNew lead" {
+		t.Errorf("text = %q", payload["text"])
+	}
+
+	// Platform notifications have no project name
+	slack.Send(context.Background(), Notification{Type: "expired", Message: "Trial ended"})
+	if payload["text"] != "*expired*\nTrial ended" {
+		t.Errorf("text = %q", payload["text"])
+	}
+
+	status = http.StatusForbidden
+	if err := slack.Send(context.Background(), Notification{Type: "test"}); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("error = %v, want the HTTP status", err)
+	}
+
+	if target := slack.Target(); strings.Contains(target, "secret") {
+		t.Errorf("Target() = %q exposes the webhook path", target)
+	}
+	if target := (&SlackChannel{WebhookURL: "not a url"}).Target(); target != "slack" {
+		t.Errorf("Target() for an unparseable URL = %q, want slack", target)
+	}
+}
+
+func TestWebhookChannelSignsPayload(t *testing.T) {
+	var body []byte
+	var signature, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Signature-256")
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	n := Notification{ProjectID: "proj_1", ProjectName: "Acme", Type: "test", Message: "hello", SentAt: time.Now()}
+	if err := (&WebhookChannel{URL: server.URL, Secret: "shh"}).Send(context.Background(), n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var got Notification
+	if err := json.Unmarshal(body, &got); err != nil || got.ProjectID != "proj_1" || got.Message != "hello" {
+		t.Errorf("payload = %s (%v)", body, err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+
+	// What a receiver computes to verify the body
+	mac := hmac.New(sha256.New, []byte("shh"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("X-Signature-256 = %q, want %q", signature, want)
+	}
+
+	if err := (&WebhookChannel{URL: server.URL}).Send(context.Background(), n); err != nil {
+		t.Fatalf("unsigned Send: %v", err)
+	}
+	if signature != "" {
+		t.Errorf("payload signed without a secret: %q", signature)
+	}
+}
+
+func TestWebhookChannelReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	n := Notification{Type: "test"}
+	if err := (&WebhookChannel{URL: server.URL}).Send(context.Background(), n); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("error = %v, want the HTTP status", err)
+	}
+	if err := (&WebhookChannel{URL: "http://127.0.0.1:1/unreachable"}).Send(context.Background(), n); err == nil {
+		t.Error("unreachable webhook reported as delivered")
+	}
+}
+
+func TestEmailChannelRequiresSMTP(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("SMTP_FROM", "")
+	if err := (&EmailChannel{To: "a@example.com"}).Send(context.Background(), Notification{}); err == nil {
+		t.Error("email sent without SMTP configured")
+	}
+}
+
+func TestEmailChannelSends(t *testing.T) {
+	messages := fakeSMTP(t)
+
+	n := Notification{ProjectName: "Acme", Type: "lead", Message: "New lead: Jane"}
+	if err := (&EmailChannel{To: "owner@example.com"}).Send(context.Background(), n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	select {
+	case message := <-messages:
+		for _, want := range []string{"From: alerts@example.com", "To: owner@example.com", "Subject: [Acme] lead notification", "New lead: Jane"} {
+			if !strings.Contains(message, want) {
+				t.Errorf("message missing %q:\n%s", want, message)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+}
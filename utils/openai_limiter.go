@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OpenAILimiter paces requests to the shared OpenAI account with two token buckets, one for
+// requests per minute and one for tokens per minute, so bursts across projects queue here
+// instead of coming back as account-level 429s. Limits are per instance.
+type OpenAILimiter struct {
+	mu          sync.Mutex
+	requests    tokenBucket
+	tokens      tokenBucket
+	pausedUntil time.Time
+}
+
+// tokenBucket refills continuously at capacity per minute; capacity 0 means unlimited
+type tokenBucket struct {
+	capacity  float64
+	available float64
+	updated   time.Time
+}
+
+func newTokenBucket(perMinute int, now time.Time) tokenBucket {
+	return tokenBucket{capacity: float64(perMinute), available: float64(perMinute), updated: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.capacity == 0 {
+		return
+	}
+	b.available = min(b.capacity, b.available+now.Sub(b.updated).Minutes()*b.capacity)
+	b.updated = now
+}
+
+// wait returns how long until n units are available
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.capacity == 0 || b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.capacity * float64(time.Minute))
+}
+
+// NewOpenAILimiter creates a limiter for rpm requests and tpm tokens per minute (0 = unlimited)
+func NewOpenAILimiter(rpm, tpm int) *OpenAILimiter {
+	now := time.Now()
+	return &OpenAILimiter{requests: newTokenBucket(rpm, now), tokens: newTokenBucket(tpm, now)}
+}
+
+// Wait blocks until one request of about estimatedTokens fits within both limits, then
+// reserves it. It returns early with ctx's error if ctx ends first.
+func (l *OpenAILimiter) Wait(ctx context.Context, estimatedTokens int) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.requests.refill(now)
+		l.tokens.refill(now)
+
+		// A request bigger than the whole bucket would never fit; let it through once the bucket is full
+		need := float64(estimatedTokens)
+		if l.tokens.capacity > 0 {
+			need = min(need, l.tokens.capacity)
+		}
+
+		delay := max(time.Until(l.pausedUntil), l.requests.wait(1), l.tokens.wait(need))
+		if delay <= 0 {
+			if l.requests.capacity > 0 {
+				l.requests.available--
+			}
+			if l.tokens.capacity > 0 {
+				l.tokens.available -= need
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Adjust corrects the token reservation once the real usage is known (positive when the
+// request used more than estimated)
+func (l *OpenAILimiter) Adjust(extraTokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.tokens.capacity > 0 {
+		l.tokens.refill(time.Now())
+		l.tokens.available -= float64(extraTokens)
+	}
+}
+
+// Pause holds back every request for d, used when OpenAI answers 429 despite the pacing
+func (l *OpenAILimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+var (
+	openAILimiterOnce sync.Once
+	openAILimiter     *OpenAILimiter
+)
+
+// OpenAIRateLimiter returns the process-wide limiter sized by OPENAI_RPM_LIMIT and
+// OPENAI_TPM_LIMIT (unset or 0 = unlimited)
+func OpenAIRateLimiter() *OpenAILimiter {
+	openAILimiterOnce.Do(func() {
+		rpm, _ := strconv.Atoi(os.Getenv("OPENAI_RPM_LIMIT"))
+		tpm, _ := strconv.Atoi(os.Getenv("OPENAI_TPM_LIMIT"))
+		openAILimiter = NewOpenAILimiter(max(rpm, 0), max(tpm, 0))
+	})
+	return openAILimiter
+}
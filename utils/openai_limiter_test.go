@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blocks reports whether Wait would still be waiting after a short deadline
+func blocks(l *OpenAILimiter, estimatedTokens int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	return errors.Is(l.Wait(ctx, estimatedTokens), context.DeadlineExceeded)
+}
+
+func TestOpenAILimiterUnlimited(t *testing.T) {
+	l := NewOpenAILimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if blocks(l, 100000) {
+			t.Fatalf("request %d waited with no limits configured", i)
+		}
+	}
+}
+
+func TestOpenAILimiterRequestsPerMinute(t *testing.T) {
+	l := NewOpenAILimiter(2, 0)
+	if blocks(l, 10) || blocks(l, 10) {
+		t.Fatal("requests within the RPM limit should not wait")
+	}
+	if !blocks(l, 10) {
+		t.Error("a request past the RPM limit should wait")
+	}
+}
+
+func TestOpenAILimiterTokensPerMinute(t *testing.T) {
+	l := NewOpenAILimiter(0, 100)
+	if blocks(l, 80) {
+		t.Fatal("a request within the TPM limit should not wait")
+	}
+	if !blocks(l, 80) {
+		t.Error("a request past the TPM limit should wait")
+	}
+	if blocks(l, 10) {
+		t.Error("a request that fits the remaining tokens should not wait")
+	}
+}
+
+func TestOpenAILimiterOversizeRequest(t *testing.T) {
+	l := NewOpenAILimiter(0, 100)
+	if blocks(l, 500) {
+		t.Error("a request bigger than the bucket should pass once the bucket is full")
+	}
+	if !blocks(l, 1) {
+		t.Error("the oversize request should have used the whole bucket")
+	}
+}
+
+func TestOpenAILimiterAdjust(t *testing.T) {
+	l := NewOpenAILimiter(0, 100)
+	if blocks(l, 10) {
+		t.Fatal("first request should not wait")
+	}
+
+	// The request used 90 more tokens than estimated
+	l.Adjust(90)
+	if !blocks(l, 10) {
+		t.Error("after a larger-than-estimated request the bucket should be empty")
+	}
+
+	// Over-estimates hand tokens back
+	l.Adjust(-50)
+	if blocks(l, 40) {
+		t.Error("tokens returned by a negative adjustment should be usable")
+	}
+}
+
+func TestOpenAILimiterPause(t *testing.T) {
+	l := NewOpenAILimiter(0, 0)
+	l.Pause(time.Hour)
+	if !blocks(l, 1) {
+		t.Error("requests should wait while paused, even without limits")
+	}
+
+	// A shorter pause does not cut an existing one short
+	l.Pause(time.Millisecond)
+	if !blocks(l, 1) {
+		t.Error("a shorter pause should not shorten the current one")
+	}
+}
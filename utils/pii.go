@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Placeholders that replace redacted values
+const (
+	RedactedEmail = "[email]"
+	RedactedCard  = "[card]"
+	RedactedPhone = "[phone]"
+)
+
+var (
+	piiEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	// Runs of digits broken only by spaces, dots, dashes or parentheses, optionally with a leading +
+	piiNumberPattern = regexp.MustCompile(`\+?\(?\d[\d\s().\-]{6,}\d`)
+)
+
+// RedactPII replaces email addresses, card numbers (13-19 digits passing the Luhn check) and
+// phone numbers (10-15 digits) with placeholders. Shorter numbers such as prices, dates and
+// order quantities are left alone.
+func RedactPII(text string) string {
+	text = piiEmailPattern.ReplaceAllString(text, RedactedEmail)
+	return piiNumberPattern.ReplaceAllStringFunc(text, func(match string) string {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, match)
+
+		switch {
+		case len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits):
+			return RedactedCard
+		case len(digits) >= 10 && len(digits) <= 15:
+			return RedactedPhone
+		}
+		return match
+	})
+}
+
+// luhnValid reports whether a digit string passes the Luhn checksum used by payment cards
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "testing"
+
+func TestRedactPII(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"email", "Reach me at jane.doe+test@mail.example.co.uk please", "Reach me at [email] please"},
+		{"card with spaces", "Card 4111 1111 1111 1111 exp 12/27", "Card [card] exp 12/27"},
+		{"card with dashes", "5500-0000-0000-0004", "[card]"},
+		{"failed luhn long number", "Ref 4111 1111 1111 1112", "Ref 4111 1111 1111 1112"},
+		{"international phone", "Call +1 (555) 123-4567 today", "Call [phone] today"},
+		{"local phone", "My number is 98765 43210", "My number is [phone]"},
+		{"price", "It costs 1299.99 dollars", "It costs 1299.99 dollars"},
+		{"date", "Delivered on 2024-01-15", "Delivered on 2024-01-15"},
+		{"order quantity", "Order 12 items, ref 4521", "Order 12 items, ref 4521"},
+		{"nothing to redact", "Hello there", "Hello there"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactPII(tt.in); got != tt.want {
+				t.Errorf("RedactPII(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"5500000000000004", true},
+		{"378282246310005", true},
+		{"4111111111111112", false},
+		{"1234567890123", false},
+	}
+	for _, tt := range tests {
+		if got := luhnValid(tt.digits); got != tt.want {
+			t.Errorf("luhnValid(%s) = %v, want %v", tt.digits, got, tt.want)
+		}
+	}
+}
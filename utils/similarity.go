@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"math"
+)
+
+// Errors returned by the vector similarity helpers
+var (
+	ErrVectorLengthMismatch = errors.New("vectors have different lengths")
+	ErrEmptyVector          = errors.New("vector is empty")
+)
+
+// DotProduct of two equal-length vectors
+func DotProduct(a, b []float64) (float64, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, ErrEmptyVector
+	}
+	if len(a) != len(b) {
+		return 0, ErrVectorLengthMismatch
+	}
+
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot, nil
+}
+
+// CosineSimilarity of two equal-length vectors, in [-1, 1].
+// A zero vector has no direction, so its similarity to anything is 0.
+func CosineSimilarity(a, b []float64) (float64, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, ErrEmptyVector
+	}
+	if len(a) != len(b) {
+		return 0, ErrVectorLengthMismatch
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+
+	// Clamp rounding error so identical vectors give exactly 1 and opposite ones -1
+	similarity := dot / (math.Sqrt(normA) * math.Sqrt(normB))
+	return math.Max(-1, math.Min(1, similarity)), nil
+}
+
+// Normalize returns v scaled to unit length (a zero vector is returned unchanged)
+func Normalize(v []float64) []float64 {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+
+	out := make([]float64, len(v))
+	if norm == 0 {
+		copy(out, v)
+		return out
+	}
+
+	norm = math.Sqrt(norm)
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+const similarityEpsilon = 1e-9
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []float64
+		want    float64
+		wantErr error
+	}{
+		{"identical", []float64{0.1, 0.2, 0.3}, []float64{0.1, 0.2, 0.3}, 1, nil},
+		{"same direction", []float64{1, 2, 3}, []float64{2, 4, 6}, 1, nil},
+		{"orthogonal", []float64{1, 0}, []float64{0, 1}, 0, nil},
+		{"opposite", []float64{1, -2, 3}, []float64{-1, 2, -3}, -1, nil},
+		{"zero vector", []float64{0, 0, 0}, []float64{1, 2, 3}, 0, nil},
+		{"mismatched length", []float64{1, 2}, []float64{1, 2, 3}, 0, ErrVectorLengthMismatch},
+		{"empty", nil, []float64{1}, 0, ErrEmptyVector},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CosineSimilarity(tt.a, tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > similarityEpsilon {
+				t.Errorf("CosineSimilarity = %v, want %v", got, tt.want)
+			}
+			if got < -1 || got > 1 {
+				t.Errorf("CosineSimilarity = %v, outside [-1, 1]", got)
+			}
+		})
+	}
+}
+
+func TestDotProduct(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []float64
+		want    float64
+		wantErr error
+	}{
+		{"simple", []float64{1, 2, 3}, []float64{4, 5, 6}, 32, nil},
+		{"orthogonal", []float64{1, 0}, []float64{0, 1}, 0, nil},
+		{"mismatched length", []float64{1}, []float64{1, 2}, 0, ErrVectorLengthMismatch},
+		{"empty", []float64{}, []float64{}, 0, ErrEmptyVector},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DotProduct(tt.a, tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DotProduct = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	v := []float64{3, 4}
+	got := Normalize(v)
+	if math.Abs(got[0]-0.6) > similarityEpsilon || math.Abs(got[1]-0.8) > similarityEpsilon {
+		t.Errorf("Normalize(%v) = %v, want [0.6 0.8]", v, got)
+	}
+	if v[0] != 3 || v[1] != 4 {
+		t.Errorf("input modified: %v", v)
+	}
+
+	// Normalised vectors make the dot product equal the cosine similarity
+	a, b := Normalize([]float64{1, 2, 3}), Normalize([]float64{-2, 0.5, 4})
+	dot, _ := DotProduct(a, b)
+	cos, _ := CosineSimilarity([]float64{1, 2, 3}, []float64{-2, 0.5, 4})
+	if math.Abs(dot-cos) > similarityEpsilon {
+		t.Errorf("dot of normalised vectors = %v, cosine = %v", dot, cos)
+	}
+
+	zero := Normalize([]float64{0, 0})
+	if zero[0] != 0 || zero[1] != 0 {
+		t.Errorf("Normalize(zero) = %v", zero)
+	}
+}
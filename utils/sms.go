@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// twilioOptedOutCode - Twilio's error for a recipient who replied STOP
+const twilioOptedOutCode = 21610
+
+// maxSMSLength - Longest SMS body sent (two concatenated segments)
+const maxSMSLength = 306
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// IsE164 reports whether phone is an E.164 number (+ and country code, no spaces)
+func IsE164(phone string) bool {
+	return e164Pattern.MatchString(phone)
+}
+
+// SMSConfigured reports whether TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER are set
+func SMSConfigured() bool {
+	return os.Getenv("TWILIO_ACCOUNT_SID") != "" && os.Getenv("TWILIO_AUTH_TOKEN") != "" && os.Getenv("TWILIO_FROM_NUMBER") != ""
+}
+
+// SMSSender sends one text message
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, body string) error
+}
+
+// TwilioError is an error reported by the Twilio API
+type TwilioError struct {
+	Status  int    `json:"status"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *TwilioError) Error() string {
+	return fmt.Sprintf("Twilio returned HTTP %d (code %d): %s", e.Status, e.Code, e.Message)
+}
+
+// OptedOut reports whether the recipient has unsubscribed from our messages
+func (e *TwilioError) OptedOut() bool {
+	return e.Code == twilioOptedOutCode
+}
+
+// TwilioClient sends SMS through Twilio's Messages API
+type TwilioClient struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string // defaults to https://api.twilio.com
+}
+
+// NewTwilioClientFromEnv builds a client from TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER
+func NewTwilioClientFromEnv() *TwilioClient {
+	return &TwilioClient{
+		AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+		AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+		From:       os.Getenv("TWILIO_FROM_NUMBER"),
+	}
+}
+
+func (t *TwilioClient) SendSMS(ctx context.Context, to, body string) error {
+	baseURL := t.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.twilio.com"
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", baseURL, url.PathEscape(t.AccountSID))
+	form := url.Values{"To": {to}, "From": {t.From}, "Body": {body}}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Twilio request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		twilioErr := &TwilioError{Status: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(twilioErr); err != nil || twilioErr.Message == "" {
+			twilioErr.Message = http.StatusText(resp.StatusCode)
+		}
+		twilioErr.Status = resp.StatusCode
+		return twilioErr
+	}
+	return nil
+}
+
+// SMSChannel texts notifications to one phone number. OnOptOut, if set, is called when the
+// recipient turns out to have unsubscribed, so they aren't texted again.
+type SMSChannel struct {
+	To       string
+	Sender   SMSSender
+	OnOptOut func()
+}
+
+func (s *SMSChannel) Name() string { return "sms" }
+
+// Target shows only the last digits of the number
+func (s *SMSChannel) Target() string {
+	if len(s.To) <= 4 {
+		return s.To
+	}
+	return strings.Repeat("*", len(s.To)-4) + s.To[len(s.To)-4:]
+}
+
+func (s *SMSChannel) Send(ctx context.Context, n Notification) error {
+	body := n.Message
+	if n.ProjectName != "" {
+		body = fmt.Sprintf("[%s] %s", n.ProjectName, n.Message)
+	}
+	if runes := []rune(body); len(runes) > maxSMSLength {
+		body = string(runes[:maxSMSLength-1]) + "…"
+	}
+
+	err := s.Sender.SendSMS(ctx, s.To, body)
+	if twilioErr, ok := err.(*TwilioError); ok && twilioErr.OptedOut() && s.OnOptOut != nil {
+		s.OnOptOut()
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIsE164(t *testing.T) {
+	tests := map[string]bool{
+		"+14155550100":      true,
+		"+447911123456":     true,
+		"14155550100":       false,
+		"+1 415 5550100":    false,
+		"+0123456789":       false,
+		"+12345":            false,
+		"+1234567890123456": false,
+		"":                  false,
+	}
+	for phone, want := range tests {
+		if got := IsE164(phone); got != want {
+			t.Errorf("IsE164(%q) = %v, want %v", phone, got, want)
+		}
+	}
+}
+
+func TestSMSConfigured(t *testing.T) {
+	t.Setenv("TWILIO_ACCOUNT_SID", "AC123")
+	t.Setenv("TWILIO_AUTH_TOKEN", "token")
+	t.Setenv("TWILIO_FROM_NUMBER", "")
+	if SMSConfigured() {
+		t.Error("SMS configured without a sender number")
+	}
+	t.Setenv("TWILIO_FROM_NUMBER", "+14155550100")
+	if !SMSConfigured() {
+		t.Error("SMS not configured with all three Twilio variables set")
+	}
+}
+
+func TestTwilioClientSendSMS(t *testing.T) {
+	var path, user, password, to, from, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, password, _ = r.BasicAuth()
+		r.ParseForm()
+		to, from, body = r.PostForm.Get("To"), r.PostForm.Get("From"), r.PostForm.Get("Body")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := &TwilioClient{AccountSID: "AC123", AuthToken: "token", From: "+14155550100", BaseURL: server.URL}
+	if err := client.SendSMS(context.Background(), "+447911123456", "Renew soon"); err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if path != "/2010-04-01/Accounts/AC123/Messages.json" || user != "AC123" || password != "token" {
+		t.Errorf("request to %s as %s:%s", path, user, password)
+	}
+	if to != "+447911123456" || from != "+14155550100" || body != "Renew soon" {
+		t.Errorf("form = To %q From %q Body %q", to, from, body)
+	}
+}
+
+func TestTwilioClientErrors(t *testing.T) {
+	response := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+	client := &TwilioClient{AccountSID: "AC123", BaseURL: server.URL}
+
+	response = `{"code": 21610, "message": "Attempt to send to unsubscribed recipient"}`
+	var twilioErr *TwilioError
+	err := client.SendSMS(context.Background(), "+447911123456", "hi")
+	if !errors.As(err, &twilioErr) || !twilioErr.OptedOut() || twilioErr.Status != http.StatusBadRequest {
+		t.Errorf("err = %v, want an opted-out TwilioError", err)
+	}
+
+	response = "<html>bad gateway</html>"
+	err = client.SendSMS(context.Background(), "+447911123456", "hi")
+	if !errors.As(err, &twilioErr) || twilioErr.OptedOut() || twilioErr.Message != "Bad Request" {
+		t.Errorf("err = %v, want the HTTP status text for an unreadable body", err)
+	}
+}
+
+// stubSMSSender - An SMSSender that records the last message and returns err
+type stubSMSSender struct {
+	to, body string
+	err      error
+}
+
+func (s *stubSMSSender) SendSMS(ctx context.Context, to, body string) error {
+	s.to, s.body = to, body
+	return s.err
+}
+
+func TestSMSChannel(t *testing.T) {
+	sender := &stubSMSSender{}
+	channel := &SMSChannel{To: "+447911123456", Sender: sender}
+
+	if target := channel.Target(); target != "*********3456" {
+		t.Errorf("Target() = %q, want all but the last four digits masked", target)
+	}
+
+	if err := channel.Send(context.Background(), Notification{ProjectName: "Acme", Message: "Subscription expired"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if sender.to != "+447911123456" || sender.body != "[Acme] Subscription expired" {
+		t.Errorf("sent %q to %s", sender.body, sender.to)
+	}
+
+	channel.Send(context.Background(), Notification{Message: strings.Repeat("é", maxSMSLength+50)})
+	if n := utf8.RuneCountInString(sender.body); n != maxSMSLength || !strings.HasSuffix(sender.body, "…") {
+		t.Errorf("long message sent as %d characters, want %d ending in an ellipsis", n, maxSMSLength)
+	}
+}
+
+func TestSMSChannelOptOut(t *testing.T) {
+	optedOut := 0
+	sender := &stubSMSSender{err: &TwilioError{Status: http.StatusBadRequest, Code: twilioOptedOutCode}}
+	channel := &SMSChannel{To: "+447911123456", Sender: sender, OnOptOut: func() { optedOut++ }}
+
+	if err := channel.Send(context.Background(), Notification{Message: "hi"}); err == nil {
+		t.Error("an opted-out recipient was reported as delivered")
+	}
+	if optedOut != 1 {
+		t.Errorf("OnOptOut called %d times, want 1", optedOut)
+	}
+
+	sender.err = &TwilioError{Status: http.StatusInternalServerError, Code: 20500}
+	channel.Send(context.Background(), Notification{Message: "hi"})
+	if optedOut != 1 {
+		t.Error("OnOptOut called for an unrelated error")
+	}
+}
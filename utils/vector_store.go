@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+)
+
+// VectorRecord is one embedded document chunk
+type VectorRecord struct {
+	ID         string    `bson:"_id" json:"id"`
+	ProjectID  string    `bson:"project_id" json:"project_id"`
+	DocumentID string    `bson:"document_id" json:"document_id"`
+	FileName   string    `bson:"file_name" json:"file_name"`
+	ChunkIndex int       `bson:"chunk_index" json:"chunk_index"`
+	Text       string    `bson:"text" json:"text"`
+	Vector     []float64 `bson:"vector" json:"-"`
+	Model      string    `bson:"embedding_model" json:"embedding_model"` // Embedding model that produced Vector
+	Dimensions int       `bson:"dimensions" json:"dimensions"`
+}
+
+// VectorMatch is a record returned by Query with its similarity score (higher is closer)
+type VectorMatch struct {
+	VectorRecord `bson:",inline"`
+	Score        float64 `bson:"score" json:"score"`
+}
+
+// VectorStore stores chunk embeddings and finds the nearest ones for a query vector
+type VectorStore interface {
+	// Upsert replaces the given chunks (by ID)
+	Upsert(ctx context.Context, records []VectorRecord) error
+	// Query returns up to k chunks of projectID embedded with model that are closest to vector;
+	// chunks from other models live in a different vector space and are never compared
+	Query(ctx context.Context, projectID, model string, vector []float64, k int) ([]VectorMatch, error)
+	// DeleteDocument drops every chunk of one document before it is re-chunked
+	DeleteDocument(ctx context.Context, projectID, documentID string) error
+}
+
+var (
+	vectorStoreOnce sync.Once
+	vectorStore     VectorStore
+)
+
+// GetVectorStore returns the backend selected by VECTOR_STORE:
+//   - "scan" (default): chunks in the document_chunks collection, cosine similarity computed in Go
+//   - "atlas": same collection, queried with MongoDB Atlas $vectorSearch
+func GetVectorStore() VectorStore {
+	vectorStoreOnce.Do(func() {
+		switch strings.ToLower(os.Getenv("VECTOR_STORE")) {
+		case "atlas":
+			index := os.Getenv("ATLAS_VECTOR_INDEX")
+			if index == "" {
+				index = "document_chunks_vector"
+			}
+			log.Printf("🧭 Vector store: MongoDB Atlas Vector Search (index %s)", index)
+			vectorStore = &AtlasVectorStore{Index: index}
+		default:
+			vectorStore = &ScanVectorStore{}
+		}
+	})
+	return vectorStore
+}
+
+// ScanVectorStore keeps vectors in MongoDB and ranks a project's chunks in memory.
+// Fine for a few thousand chunks per project; use Atlas beyond that.
+type ScanVectorStore struct{}
+
+func (s *ScanVectorStore) Upsert(ctx context.Context, records []VectorRecord) error {
+	return upsertVectorRecords(ctx, records)
+}
+
+func (s *ScanVectorStore) DeleteDocument(ctx context.Context, projectID, documentID string) error {
+	return deleteVectorDocument(ctx, projectID, documentID)
+}
+
+func (s *ScanVectorStore) Query(ctx context.Context, projectID, model string, vector []float64, k int) ([]VectorMatch, error) {
+	cursor, err := config.GetDocumentChunksCollection().Find(ctx, bson.M{"project_id": projectID, "embedding_model": model})
+	if err != nil {
+		return nil, err
+	}
+	var records []VectorRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return RankByCosine(records, model, vector, k), nil
+}
+
+// RankByCosine scores the records embedded with model against vector and returns the k most similar
+func RankByCosine(records []VectorRecord, model string, vector []float64, k int) []VectorMatch {
+	matches := make([]VectorMatch, 0, len(records))
+	for _, record := range records {
+		if record.Model != model {
+			continue
+		}
+		score, err := CosineSimilarity(record.Vector, vector)
+		if err != nil {
+			// Chunks embedded with a different model (dimension) can't be compared
+			continue
+		}
+		matches = append(matches, VectorMatch{VectorRecord: record, Score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// AtlasVectorStore queries document_chunks through an Atlas Vector Search index.
+// The index must be created in Atlas on the "vector" path with "project_id" and "embedding_model"
+// as filter fields. An index has one dimension, so projects using text-embedding-3-large (3072)
+// need their own deployment or index:
+//
+//	{"fields": [
+//	  {"type": "vector", "path": "vector", "numDimensions": 1536, "similarity": "cosine"},
+//	  {"type": "filter", "path": "project_id"},
+//	  {"type": "filter", "path": "embedding_model"}
+//	]}
+type AtlasVectorStore struct {
+	Index string
+}
+
+func (s *AtlasVectorStore) Upsert(ctx context.Context, records []VectorRecord) error {
+	return upsertVectorRecords(ctx, records)
+}
+
+func (s *AtlasVectorStore) DeleteDocument(ctx context.Context, projectID, documentID string) error {
+	return deleteVectorDocument(ctx, projectID, documentID)
+}
+
+func (s *AtlasVectorStore) Query(ctx context.Context, projectID, model string, vector []float64, k int) ([]VectorMatch, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$vectorSearch", Value: bson.M{
+			"index":         s.Index,
+			"path":          "vector",
+			"queryVector":   vector,
+			"numCandidates": k * 20,
+			"limit":         k,
+			"filter":        bson.M{"project_id": projectID, "embedding_model": model},
+		}}},
+		{{Key: "$set", Value: bson.M{"score": bson.M{"$meta": "vectorSearchScore"}}}},
+	}
+
+	cursor, err := config.GetDocumentChunksCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("atlas vector search failed: %v", err)
+	}
+	var matches []VectorMatch
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func upsertVectorRecords(ctx context.Context, records []VectorRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(records))
+	for _, record := range records {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": record.ID}).
+			SetReplacement(record).
+			SetUpsert(true))
+	}
+
+	_, err := config.GetDocumentChunksCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func deleteVectorDocument(ctx context.Context, projectID, documentID string) error {
+	_, err := config.GetDocumentChunksCollection().DeleteMany(ctx, bson.M{
+		"project_id":  projectID,
+		"document_id": documentID,
+	})
+	return err
+}
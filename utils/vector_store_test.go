@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"jevi-chat/config"
+)
+
+// useTestDatabase - Point config.DB at a throwaway database on MONGODB_TEST_URI, dropped when the
+// test ends. Tests that need MongoDB are skipped when the variable is unset.
+func useTestDatabase(t *testing.T) context.Context {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	previous := config.DB
+	config.DB = client.Database(fmt.Sprintf("troika_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		config.DB.Drop(context.Background())
+		client.Disconnect(context.Background())
+		config.DB = previous
+	})
+	return ctx
+}
+
+func chunk(id, document, model string, vector ...float64) VectorRecord {
+	return VectorRecord{ID: id, ProjectID: "proj_1", DocumentID: document, Text: id, Vector: vector, Model: model, Dimensions: len(vector)}
+}
+
+func TestRankByCosine(t *testing.T) {
+	records := []VectorRecord{
+		chunk("orthogonal", "doc", "small", 0, 1),
+		chunk("close", "doc", "small", 1, 0.1),
+		chunk("exact", "doc", "small", 1, 0),
+		chunk("other model", "doc", "large", 1, 0),
+		chunk("wrong dimension", "doc", "small", 1, 0, 0),
+	}
+
+	tests := []struct {
+		name string
+		k    int
+		want []string
+	}{
+		{"all comparable, closest first", 10, []string{"exact", "close", "orthogonal"}},
+		{"top k", 2, []string{"exact", "close"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := RankByCosine(records, "small", []float64{1, 0}, tt.k)
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ranked %v, want %v", got, tt.want)
+			}
+			if matches[0].Score < 0.999 {
+				t.Errorf("exact match scored %v", matches[0].Score)
+			}
+		})
+	}
+}
+
+func TestScanVectorStore(t *testing.T) {
+	ctx := useTestDatabase(t)
+	store := &ScanVectorStore{}
+
+	records := []VectorRecord{
+		chunk("doc_1:0", "doc_1", "small", 1, 0),
+		chunk("doc_1:1", "doc_1", "small", 0, 1),
+		chunk("doc_2:0", "doc_2", "small", 0.9, 0.1),
+		chunk("doc_3:0", "doc_3", "large", 1, 0),
+	}
+	other := chunk("other:0", "doc_1", "small", 1, 0)
+	other.ProjectID = "proj_2"
+	if err := store.Upsert(ctx, append(records, other)); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	// Upserting again replaces by ID
+	records[1].Vector = []float64{0.8, 0.2}
+	if err := store.Upsert(ctx, records[1:2]); err != nil {
+		t.Fatalf("Upsert again: %v", err)
+	}
+
+	query := func() []string {
+		t.Helper()
+		matches, err := store.Query(ctx, "proj_1", "small", []float64{1, 0}, 10)
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		var ids []string
+		for _, m := range matches {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	if got := fmt.Sprint(query()); got != "[doc_1:0 doc_2:0 doc_1:1]" {
+		t.Errorf("query = %s, want the project's small-model chunks, closest first", got)
+	}
+
+	if err := store.DeleteDocument(ctx, "proj_1", "doc_1"); err != nil {
+		t.Fatalf("DeleteDocument: %v", err)
+	}
+	if got := fmt.Sprint(query()); got != "[doc_2:0]" {
+		t.Errorf("query after delete = %s", got)
+	}
+	count, err := config.GetDocumentChunksCollection().CountDocuments(ctx, map[string]string{"project_id": "proj_2"})
+	if err != nil || count != 1 {
+		t.Errorf("other project's chunks = %d (%v), want untouched", count, err)
+	}
+}